@@ -0,0 +1,187 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"google.golang.org/api/option"
+
+	"github.com/google/github_actions_on_gcp/pkg/image"
+	"github.com/google/github_actions_on_gcp/pkg/version"
+	"github.com/google/github_actions_on_gcp/pkg/webhook"
+)
+
+var (
+	_ cli.Command = (*ImageBuildCommand)(nil)
+	_ cli.Command = (*ImagePruneCommand)(nil)
+)
+
+type ImageBuildCommand struct {
+	cli.BaseCommand
+
+	cfg *image.Config
+
+	// only used for testing
+	testFlagSetOpts []cli.Option
+
+	// only used for testing
+	testCloudBuildOverride image.CloudBuildClient
+}
+
+func (c *ImageBuildCommand) Desc() string {
+	return `Build and push the runner images of the image manifest`
+}
+
+func (c *ImageBuildCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+  Build the runner image of every profile in the image manifest from its base
+  image and toolsets with Cloud Build, push them to the runner repository and
+  print the pushed images.
+`
+}
+
+func (c *ImageBuildCommand) Flags() *cli.FlagSet {
+	c.cfg = &image.Config{}
+	set := cli.NewFlagSet(c.testFlagSetOpts...)
+	return c.cfg.ToFlags(set)
+}
+
+func (c *ImageBuildCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	args = f.Args()
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %q", args)
+	}
+
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	b, err := os.ReadFile(c.cfg.ManifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to read image manifest: %w", err)
+	}
+	m, err := image.ParseManifest(b)
+	if err != nil {
+		return err //nolint:wrapcheck // Want passthrough
+	}
+	for _, p := range c.cfg.Profiles {
+		if _, ok := m.Profiles[p]; !ok {
+			return fmt.Errorf("profile %q is not in the image manifest", p)
+		}
+	}
+
+	client := c.testCloudBuildOverride
+	if client == nil {
+		agent := fmt.Sprintf("google:github-actions-on-gcp/%s", version.Version)
+		cb, err := webhook.NewCloudBuild(ctx, option.WithUserAgent(agent))
+		if err != nil {
+			return fmt.Errorf("failed to create cloudbuild client: %w", err)
+		}
+		defer cb.Close()
+		client = cb
+	}
+
+	images, err := image.NewBuilder(client).Build(ctx, c.cfg, m)
+	if err != nil {
+		return err //nolint:wrapcheck // Want passthrough
+	}
+	for _, img := range images {
+		c.Outf("%s", img)
+	}
+	return nil
+}
+
+type ImagePruneCommand struct {
+	cli.BaseCommand
+
+	cfg *image.PruneConfig
+
+	// only used for testing
+	testFlagSetOpts []cli.Option
+
+	// only used for testing
+	testArtifactRegistryOverride image.ArtifactRegistryClient
+	testPullRequestsOverride     image.PullRequestClient
+}
+
+func (c *ImagePruneCommand) Desc() string {
+	return `Delete the runner image tags of closed and stale pull requests`
+}
+
+func (c *ImagePruneCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+  Delete the pr-<number> tags of the runner repository whose pull request is
+  merged or closed, or that were last pushed longer than the TTL ago, and print
+  the deleted tags. Run it on a schedule to keep the repository from growing
+  with every pull request. The untagged versions are removed by the cleanup
+  policies of the repository.
+`
+}
+
+func (c *ImagePruneCommand) Flags() *cli.FlagSet {
+	c.cfg = &image.PruneConfig{}
+	set := cli.NewFlagSet(c.testFlagSetOpts...)
+	return c.cfg.ToFlags(set)
+}
+
+func (c *ImagePruneCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	args = f.Args()
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %q", args)
+	}
+
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	registry := c.testArtifactRegistryOverride
+	if registry == nil {
+		agent := fmt.Sprintf("google:github-actions-on-gcp/%s", version.Version)
+		ar, err := image.NewArtifactRegistry(ctx, option.WithUserAgent(agent))
+		if err != nil {
+			return err //nolint:wrapcheck // Want passthrough
+		}
+		registry = ar
+	}
+
+	pullRequests := c.testPullRequestsOverride
+	if pullRequests == nil {
+		pullRequests = image.NewGitHubPullRequests(c.cfg.GitHubToken)
+	}
+
+	pruned, err := image.NewJanitor(registry, pullRequests).Prune(ctx, c.cfg, time.Now())
+	for _, ref := range pruned {
+		c.Outf("%s", ref)
+	}
+	if err != nil {
+		return err //nolint:wrapcheck // Want passthrough
+	}
+	return nil
+}
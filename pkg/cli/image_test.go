@@ -0,0 +1,187 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/sethvargo/go-envconfig"
+	"google.golang.org/api/artifactregistry/v1"
+
+	"github.com/google/github_actions_on_gcp/pkg/image"
+)
+
+func TestImageBuildCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+	manifest := filepath.Join(t.TempDir(), "images.yaml")
+	if err := os.WriteFile(manifest, []byte(`
+base: ghcr.io/actions/actions-runner:latest
+toolsets:
+  docker: {}
+profiles:
+  default:
+    toolsets: [docker]
+  docker:
+    toolsets: [docker]
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"PROJECT_ID":           "p",
+		"RUNNER_REPOSITORY_ID": "us-docker.pkg.dev/p/runners",
+		"IMAGE_MANIFEST_PATH":  manifest,
+	}
+
+	cases := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		expErr    string
+		expStdout string
+	}{
+		{
+			name:   "too_many_args",
+			args:   []string{"foo"},
+			env:    env,
+			expErr: `unexpected arguments: ["foo"]`,
+		},
+		{
+			name:   "invalid_config_manifest",
+			args:   []string{"-manifest", ""},
+			env:    env,
+			expErr: `IMAGE_MANIFEST_PATH is required`,
+		},
+		{
+			name:   "missing_manifest",
+			args:   []string{"-manifest", filepath.Join(t.TempDir(), "missing.yaml")},
+			env:    env,
+			expErr: `failed to read image manifest`,
+		},
+		{
+			name:   "unknown_profile",
+			args:   []string{"-profile", "python"},
+			env:    env,
+			expErr: `profile "python" is not in the image manifest`,
+		},
+		{
+			name: "happy_path",
+			env:  env,
+			expStdout: "us-docker.pkg.dev/p/runners/default-runner:latest\n" +
+				"us-docker.pkg.dev/p/runners/default-runner-docker:latest\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cmd ImageBuildCommand
+			cmd.testFlagSetOpts = []cli.Option{cli.WithLookupEnv(envconfig.MapLookuper(tc.env).Lookup)}
+			cmd.testCloudBuildOverride = &image.MockCloudBuildClient{}
+
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(ctx, tc.args)
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := stdout.String(), tc.expStdout; got != want {
+				t.Errorf("expected stdout %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestImagePruneCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+	env := map[string]string{
+		"RUNNER_REPOSITORY_ID":          "us-docker.pkg.dev/p/runners",
+		"IMAGE_PRUNE_GITHUB_REPOSITORY": "google/webhook",
+	}
+
+	cases := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		expErr    string
+		expStdout string
+	}{
+		{
+			name:   "too_many_args",
+			args:   []string{"foo"},
+			env:    env,
+			expErr: `unexpected arguments: ["foo"]`,
+		},
+		{
+			name:   "invalid_config_repository",
+			args:   []string{"-runner-repository-id", ""},
+			env:    env,
+			expErr: `RUNNER_REPOSITORY_ID is required`,
+		},
+		{
+			name: "happy_path",
+			env:  env,
+			expStdout: "us-docker.pkg.dev/p/runners/default-runner:pr-1\n" +
+				"us-docker.pkg.dev/p/runners/default-runner:pr-2\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cmd ImagePruneCommand
+			cmd.testFlagSetOpts = []cli.Option{cli.WithLookupEnv(envconfig.MapLookuper(tc.env).Lookup)}
+			cmd.testArtifactRegistryOverride = &image.MockArtifactRegistryClient{
+				Images: []*artifactregistry.DockerImage{
+					{
+						Name:       "projects/p/locations/us/repositories/runners/dockerImages/default-runner@sha256:aaa",
+						Tags:       []string{"latest", "pr-1", "pr-3"},
+						UploadTime: time.Now().Format(time.RFC3339Nano),
+					},
+					{
+						Name:       "projects/p/locations/us/repositories/runners/dockerImages/default-runner@sha256:bbb",
+						Tags:       []string{"pr-2"},
+						UploadTime: "2020-01-01T00:00:00Z",
+					},
+				},
+			}
+			cmd.testPullRequestsOverride = &image.MockPullRequestClient{Closed: []int{1}}
+
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(ctx, tc.args)
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := stdout.String(), tc.expStdout; got != want {
+				t.Errorf("expected stdout %q to be %q", got, want)
+			}
+		})
+	}
+}
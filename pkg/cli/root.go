@@ -28,11 +28,31 @@ var rootCmd = func() cli.Command {
 		Name:    "github-actions-on-gcp",
 		Version: version.HumanVersion,
 		Commands: map[string]cli.CommandFactory{
+			"image": func() cli.Command {
+				return &cli.RootCommand{
+					Name:        "image",
+					Description: "Perform runner image operations",
+					Commands: map[string]cli.CommandFactory{
+						"build": func() cli.Command {
+							return &ImageBuildCommand{}
+						},
+						"prune": func() cli.Command {
+							return &ImagePruneCommand{}
+						},
+					},
+				}
+			},
+			"setup": func() cli.Command {
+				return &SetupCommand{}
+			},
 			"webhook": func() cli.Command {
 				return &cli.RootCommand{
 					Name:        "webhook",
 					Description: "Perform webhook operations",
 					Commands: map[string]cli.CommandFactory{
+						"report": func() cli.Command {
+							return &WebhookReportCommand{}
+						},
 						"server": func() cli.Command {
 							return &WebhookServerCommand{}
 						},
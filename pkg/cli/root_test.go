@@ -25,6 +25,8 @@ func TestRootCommand_Help(t *testing.T) {
 	exp := `
 Usage: github-actions-on-gcp COMMAND
 
+  image      Perform runner image operations
+  setup      Provision the Google Cloud resources for github-actions-on-gcp
   webhook    Perform webhook operations
 `
 
@@ -0,0 +1,95 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abcxyz/pkg/cli"
+	"google.golang.org/api/option"
+
+	"github.com/google/github_actions_on_gcp/pkg/setup"
+	"github.com/google/github_actions_on_gcp/pkg/version"
+)
+
+var _ cli.Command = (*SetupCommand)(nil)
+
+type SetupCommand struct {
+	cli.BaseCommand
+
+	cfg *setup.Config
+
+	// only used for testing
+	testFlagSetOpts []cli.Option
+
+	// only used for testing
+	testResourcesOverride setup.Resources
+}
+
+func (c *SetupCommand) Desc() string {
+	return `Provision the Google Cloud resources for github-actions-on-gcp`
+}
+
+func (c *SetupCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+  Create the runner image repository, KMS key, service accounts, IAM bindings
+  and optionally a private worker pool, then print the webhook configuration
+  for them. Existing resources are left as they are, so the command can be run
+  again after a failure.
+`
+}
+
+func (c *SetupCommand) Flags() *cli.FlagSet {
+	c.cfg = &setup.Config{}
+	set := cli.NewFlagSet(c.testFlagSetOpts...)
+	return c.cfg.ToFlags(set)
+}
+
+func (c *SetupCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	args = f.Args()
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %q", args)
+	}
+
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	resources := c.testResourcesOverride
+	if resources == nil {
+		agent := fmt.Sprintf("google:github-actions-on-gcp/%s", version.Version)
+		gcp, err := setup.NewGCPResources(ctx, option.WithUserAgent(agent))
+		if err != nil {
+			return fmt.Errorf("failed to create clients: %w", err)
+		}
+		defer gcp.Close()
+		resources = gcp
+	}
+
+	res, err := setup.Run(ctx, c.cfg, resources)
+	if err != nil {
+		return fmt.Errorf("failed to set up resources: %w", err)
+	}
+	if err := res.WriteEnv(c.Stdout()); err != nil {
+		return err //nolint:wrapcheck // Want passthrough
+	}
+	return nil
+}
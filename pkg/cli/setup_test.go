@@ -0,0 +1,78 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/sethvargo/go-envconfig"
+
+	"github.com/google/github_actions_on_gcp/pkg/setup"
+)
+
+func TestSetupCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+	cases := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		expErr    string
+		expStdout string
+	}{
+		{
+			name:   "too_many_args",
+			args:   []string{"foo"},
+			expErr: `unexpected arguments: ["foo"]`,
+		},
+		{
+			name:   "invalid_config_project_id",
+			env:    map[string]string{"LOCATION": "us-central1"},
+			expErr: `PROJECT_ID is required`,
+		},
+		{
+			name: "happy_path",
+			args: []string{"-project-id", "p", "-location", "us-central1", "-github-app-id", "123"},
+			expStdout: "RUNNER_REPOSITORY_ID=us-central1-docker.pkg.dev/p/runners\n" +
+				"RUNNER_SERVICE_ACCOUNT=action-dispatcher-runner-sa@p.iam.gserviceaccount.com\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cmd SetupCommand
+			cmd.testFlagSetOpts = []cli.Option{cli.WithLookupEnv(envconfig.MapLookuper(tc.env).Lookup)}
+			cmd.testResourcesOverride = &setup.MockResources{}
+
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(ctx, tc.args)
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := stdout.String(), tc.expStdout; !strings.Contains(got, want) {
+				t.Errorf("expected stdout %q to contain %q", got, want)
+			}
+		})
+	}
+}
@@ -16,26 +16,37 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/abcxyz/pkg/cli"
 	"github.com/abcxyz/pkg/logging"
 	"github.com/abcxyz/pkg/renderer"
 	"github.com/abcxyz/pkg/serving"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 
+	"github.com/google/github_actions_on_gcp/pkg/profiler"
 	"github.com/google/github_actions_on_gcp/pkg/version"
 	"github.com/google/github_actions_on_gcp/pkg/webhook"
 )
 
-var _ cli.Command = (*WebhookServerCommand)(nil)
+var (
+	_ cli.Command = (*WebhookServerCommand)(nil)
+	_ cli.Command = (*WebhookReportCommand)(nil)
+)
 
 type WebhookServerCommand struct {
 	cli.BaseCommand
 
 	cfg *webhook.Config
 
+	// grpcServing and grpcServer serve the dispatch API, when GRPC_PORT is set.
+	grpcServing *serving.Server
+	grpcServer  *grpc.Server
+
 	// only used for testing
 	testFlagSetOpts []cli.Option
 
@@ -72,7 +83,22 @@ func (c *WebhookServerCommand) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	return server.StartHTTPHandler(ctx, mux)
+	if c.grpcServer == nil {
+		return server.StartHTTPHandler(ctx, mux)
+	}
+
+	// Either server failing stops the other.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	grpcErr := make(chan error, 1)
+	go func() {
+		defer cancel()
+		grpcErr <- c.grpcServing.StartGRPC(ctx, c.grpcServer)
+	}()
+
+	httpErr := server.StartHTTPHandler(ctx, mux)
+	cancel()
+	return errors.Join(httpErr, <-grpcErr)
 }
 
 func (c *WebhookServerCommand) RunUnstarted(ctx context.Context, args []string) (*serving.Server, http.Handler, error) {
@@ -108,6 +134,20 @@ func (c *WebhookServerCommand) RunUnstarted(ctx context.Context, args []string)
 	opts := []option.ClientOption{option.WithUserAgent(agent)}
 	webhookClientOptions := &webhook.WebhookClientOptions{
 		KeyManagementClientOpts: opts,
+		LoggingClientOpts:       opts,
+		PubSubClientOpts:        opts,
+	}
+
+	if c.cfg.CloudProfiler {
+		agent, err := profiler.NewAgent(ctx, &profiler.Config{
+			ProjectID: c.cfg.CloudProfilerProjectID,
+			Target:    version.Name,
+			Version:   version.Version,
+		}, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create cloud profiler agent: %w", err)
+		}
+		go agent.Run(ctx)
 	}
 
 	// expect tests to pass overide
@@ -130,6 +170,7 @@ func (c *WebhookServerCommand) RunUnstarted(ctx context.Context, args []string)
 		return nil, nil, fmt.Errorf("failed to create server: %w", err)
 	}
 
+	webhookServer.StartBackground(ctx)
 	mux := webhookServer.Routes(ctx)
 
 	server, err := serving.New(c.cfg.Port)
@@ -137,5 +178,92 @@ func (c *WebhookServerCommand) RunUnstarted(ctx context.Context, args []string)
 		return nil, nil, fmt.Errorf("failed to create serving infrastructure: %w", err)
 	}
 
+	if c.cfg.GRPCPort != "" {
+		grpcServing, err := serving.New(c.cfg.GRPCPort)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create grpc serving infrastructure: %w", err)
+		}
+		c.grpcServing = grpcServing
+		c.grpcServer = webhookServer.GRPCServer(ctx)
+	}
+
 	return server, mux, nil
 }
+
+type WebhookReportCommand struct {
+	cli.BaseCommand
+
+	cfg *webhook.ReportConfig
+
+	// only used for testing
+	testFlagSetOpts []cli.Option
+}
+
+func (c *WebhookReportCommand) Desc() string {
+	return `Print the scaling recommendations of a webhook server`
+}
+
+func (c *WebhookReportCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+  Print the scaling recommendations a webhook server computed from the
+  provisioning latency and dispatch queue depth of the runners it dispatched:
+  the warm pool size per set of labels, the dispatch concurrency and the Cloud
+  Build concurrent build quota. The server must run with RECOMMENDATION_INTERVAL
+  and an admin token set.
+`
+}
+
+func (c *WebhookReportCommand) Flags() *cli.FlagSet {
+	c.cfg = &webhook.ReportConfig{}
+	set := cli.NewFlagSet(c.testFlagSetOpts...)
+	return c.cfg.ToFlags(set)
+}
+
+func (c *WebhookReportCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+	args = f.Args()
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %q", args)
+	}
+
+	if err := c.cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	report, err := webhook.FetchRecommendations(ctx, &http.Client{Timeout: time.Minute}, c.cfg)
+	if err != nil {
+		return err //nolint:wrapcheck // Want passthrough
+	}
+
+	window := time.Duration(report.WindowSeconds * float64(time.Second)).Round(time.Second)
+	c.Outf("recommendations generated at %s over the last %s", report.GeneratedAt.Format(time.RFC3339), window)
+	for _, rec := range report.WarmPools {
+		c.Outf("warm pool [%s]: size %d (%d runners, p50 latency %.0fs, p90 latency %.0fs)%s",
+			rec.Labels, rec.Size, rec.Runners, rec.P50LatencySeconds, rec.P90LatencySeconds, reportReason(rec.Reason))
+	}
+	if rec := report.Concurrency; rec != nil {
+		c.Outf("dispatch concurrency: %d, currently %d (p90 queue depth %d, max %d)%s",
+			rec.Recommended, rec.Current, rec.P90QueueDepth, rec.MaxQueueDepth, reportReason(rec.Reason))
+	}
+	if rec := report.Quota; rec != nil {
+		if rec.RecommendedBuilds > 0 {
+			c.Outf("concurrent build quota: request %d (peak %d runners, %d quota exhaustions)%s",
+				rec.RecommendedBuilds, rec.PeakRunners, rec.Exhaustions, reportReason(rec.Reason))
+		} else {
+			c.Outf("concurrent build quota: sufficient (peak %d runners)", rec.PeakRunners)
+		}
+	}
+	return nil
+}
+
+// reportReason formats the reason of a recommendation, if any.
+func reportReason(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ": " + reason
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -138,6 +139,7 @@ func TestWebhookServerCommand(t *testing.T) {
 				"RUNNER_PROJECT_ID":      "runner-project-id",
 				"RUNNER_REPOSITORY_ID":   "runner-repo-id",
 				"RUNNER_SERVICE_ACCOUNT": "runner-service-account",
+				"SKIP_STARTUP_CHECKS":    "true",
 			},
 			fileMock: &webhook.MockFileReader{
 				ReadFileMock: &webhook.ReadFileResErr{
@@ -214,3 +216,92 @@ func TestWebhookServerCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhookReportCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/recommendations" || r.Header.Get("Authorization") != "Bearer admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"error":"missing or invalid admin token"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{
+			"generated_at": "2025-01-01T12:00:00Z",
+			"window_seconds": 86400,
+			"warm_pools": [
+				{"labels": "gpu,self-hosted", "runners": 36, "p50_latency_seconds": 90, "p90_latency_seconds": 100, "size": 1, "reason": "p90 provisioning latency is above the target latency"}
+			],
+			"concurrency": {"current": 4, "p90_queue_depth": 2, "max_queue_depth": 3, "recommended": 4},
+			"quota": {"peak_runners": 8, "exhaustions": 2, "recommended_concurrent_builds": 10, "reason": "Cloud Build refused runner builds for exhausted quota"}
+		}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	env := map[string]string{
+		"WEBHOOK_SERVER_URL": srv.URL,
+		"ADMIN_TOKEN":        "admin-token",
+	}
+
+	cases := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		expErr    string
+		expStdout string
+	}{
+		{
+			name:   "too_many_args",
+			args:   []string{"foo"},
+			env:    env,
+			expErr: `unexpected arguments: ["foo"]`,
+		},
+		{
+			name:   "missing_token",
+			args:   []string{"-admin-token", ""},
+			env:    env,
+			expErr: `ADMIN_TOKEN is required`,
+		},
+		{
+			name:   "invalid_url",
+			args:   []string{"-server-url", "webhook.example.com"},
+			env:    env,
+			expErr: `WEBHOOK_SERVER_URL must be an http or https URL`,
+		},
+		{
+			name:   "invalid_token",
+			args:   []string{"-admin-token", "not-the-token"},
+			env:    env,
+			expErr: `401 Unauthorized: missing or invalid admin token`,
+		},
+		{
+			name: "happy_path",
+			env:  env,
+			expStdout: "recommendations generated at 2025-01-01T12:00:00Z over the last 24h0m0s\n" +
+				"warm pool [gpu,self-hosted]: size 1 (36 runners, p50 latency 90s, p90 latency 100s): p90 provisioning latency is above the target latency\n" +
+				"dispatch concurrency: 4, currently 4 (p90 queue depth 2, max 3)\n" +
+				"concurrent build quota: request 10 (peak 8 runners, 2 quota exhaustions): Cloud Build refused runner builds for exhausted quota\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var cmd WebhookReportCommand
+			cmd.testFlagSetOpts = []cli.Option{cli.WithLookupEnv(envconfig.MapLookuper(tc.env).Lookup)}
+
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(ctx, tc.args)
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := stdout.String(), tc.expStdout; got != want {
+				t.Errorf("expected stdout %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/option"
+)
+
+// ArtifactRegistry lists and untags the images of a Docker repository.
+type ArtifactRegistry struct {
+	svc *artifactregistry.Service
+}
+
+// NewArtifactRegistry creates a new instance of an Artifact Registry client.
+func NewArtifactRegistry(ctx context.Context, opts ...option.ClientOption) (*ArtifactRegistry, error) {
+	svc, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new artifact registry client: %w", err)
+	}
+
+	return &ArtifactRegistry{
+		svc: svc,
+	}, nil
+}
+
+// ListDockerImages returns the images of the repository, in the form
+// projects/<project>/locations/<location>/repositories/<repository>.
+func (ar *ArtifactRegistry) ListDockerImages(ctx context.Context, repository string) ([]*artifactregistry.DockerImage, error) {
+	var images []*artifactregistry.DockerImage
+	if err := ar.svc.Projects.Locations.Repositories.DockerImages.List(repository).
+		PageSize(1000).
+		Pages(ctx, func(resp *artifactregistry.ListDockerImagesResponse) error {
+			images = append(images, resp.DockerImages...)
+			return nil
+		}); err != nil {
+		return nil, fmt.Errorf("failed to list docker images: %w", err)
+	}
+	return images, nil
+}
+
+// DeleteTag deletes the tag, leaving the image version it points to.
+func (ar *ArtifactRegistry) DeleteTag(ctx context.Context, name string) error {
+	if _, err := ar.svc.Projects.Locations.Repositories.Packages.Tags.Delete(name).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
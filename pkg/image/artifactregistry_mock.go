@@ -0,0 +1,66 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"google.golang.org/api/artifactregistry/v1"
+)
+
+// MockArtifactRegistryClient lists Images and records the deleted tags.
+// DeleteTagErr fails every delete.
+type MockArtifactRegistryClient struct {
+	Images       []*artifactregistry.DockerImage
+	ListErr      error
+	DeleteTagErr error
+
+	mu          sync.Mutex
+	DeletedTags []string
+}
+
+func (m *MockArtifactRegistryClient) ListDockerImages(ctx context.Context, repository string) ([]*artifactregistry.DockerImage, error) {
+	if m.ListErr != nil {
+		return nil, m.ListErr
+	}
+	return m.Images, nil
+}
+
+func (m *MockArtifactRegistryClient) DeleteTag(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.DeleteTagErr != nil {
+		return m.DeleteTagErr
+	}
+	m.DeletedTags = append(m.DeletedTags, name)
+	return nil
+}
+
+// MockPullRequestClient reports the pull requests in Closed as closed and
+// every other pull request as open.
+type MockPullRequestClient struct {
+	Closed []int
+	Err    error
+}
+
+func (m *MockPullRequestClient) PullRequestClosed(ctx context.Context, owner, repo string, number int) (bool, error) {
+	if m.Err != nil {
+		return false, m.Err
+	}
+	return slices.Contains(m.Closed, number), nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/googleapis/gax-go/v2"
+)
+
+const (
+	// buildTimeout bounds the build, installing several runtimes takes longer
+	// than the Cloud Build default of 10 minutes.
+	buildTimeout = time.Hour
+
+	defaultPollInterval = 10 * time.Second
+)
+
+// Config is the configuration of an image build.
+type Config struct {
+	ProjectID      string
+	Location       string
+	RepositoryID   string
+	ImageName      string
+	Tag            string
+	ManifestPath   string
+	Profiles       []string
+	WorkerPoolID   string
+	ServiceAccount string
+}
+
+// Validate validates the image build config after load.
+func (cfg *Config) Validate() error {
+	if cfg.ProjectID == "" {
+		return fmt.Errorf("PROJECT_ID is required")
+	}
+	if cfg.Location == "" {
+		return fmt.Errorf("LOCATION is required")
+	}
+	if cfg.RepositoryID == "" {
+		return fmt.Errorf("RUNNER_REPOSITORY_ID is required")
+	}
+	if cfg.ManifestPath == "" {
+		return fmt.Errorf("IMAGE_MANIFEST_PATH is required")
+	}
+	return nil
+}
+
+// ToFlags binds the config to the [cli.FlagSet] and returns it.
+func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
+	f := set.NewSection("IMAGE BUILD OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:   "project-id",
+		Target: &cfg.ProjectID,
+		EnvVar: "PROJECT_ID",
+		Usage:  `The project the image build runs in.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "location",
+		Target:  &cfg.Location,
+		EnvVar:  "LOCATION",
+		Default: "global",
+		Usage:   `The Cloud Build location the image build runs in.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-repository-id",
+		Target:  &cfg.RepositoryID,
+		EnvVar:  "RUNNER_REPOSITORY_ID",
+		Example: "us-docker.pkg.dev/my-project/runners",
+		Usage:   `The repository the images are pushed to, the same as the webhook pulls from.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-image-name",
+		Target:  &cfg.ImageName,
+		EnvVar:  "RUNNER_IMAGE_NAME",
+		Default: "default-runner",
+		Usage: `The image name of the default profile. The images of the other profiles ` +
+			`are named <name>-<profile>.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-image-tag",
+		Target:  &cfg.Tag,
+		EnvVar:  "RUNNER_IMAGE_TAG",
+		Default: "latest",
+		Usage:   `The tag of the pushed images.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "manifest",
+		Target: &cfg.ManifestPath,
+		EnvVar: "IMAGE_MANIFEST_PATH",
+		Usage:  `The path of the YAML manifest declaring the base image, toolsets and profiles.`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:   "profile",
+		Target: &cfg.Profiles,
+		EnvVar: "IMAGE_PROFILES",
+		Usage:  `The profiles to build, defaults to all profiles in the manifest.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "worker-pool-id",
+		Target: &cfg.WorkerPoolID,
+		EnvVar: "RUNNER_WORKER_POOL_ID",
+		Usage:  `The private worker pool to run the image build in.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "service-account",
+		Target: &cfg.ServiceAccount,
+		EnvVar: "IMAGE_BUILD_SERVICE_ACCOUNT",
+		Usage:  `The email of the service account the image build runs as, defaults to the Cloud Build default.`,
+	})
+
+	return set
+}
+
+// CloudBuildClient is the subset of the Cloud Build API the builder uses.
+type CloudBuildClient interface {
+	CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
+	GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
+}
+
+// Builder builds runner images with Cloud Build.
+type Builder struct {
+	client       CloudBuildClient
+	pollInterval time.Duration
+}
+
+// NewBuilder creates a builder.
+func NewBuilder(client CloudBuildClient) *Builder {
+	return &Builder{
+		client:       client,
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// Build builds and pushes the images of the selected profiles in a single
+// Cloud Build build and waits for it to finish. It returns the pushed images.
+func (b *Builder) Build(ctx context.Context, cfg *Config, m *Manifest) ([]string, error) {
+	build, images, err := cloudBuild(cfg, m)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := b.client.CreateBuild(ctx, &cloudbuildpb.CreateBuildRequest{
+		Parent:    fmt.Sprintf("projects/%s/locations/%s", cfg.ProjectID, cfg.Location),
+		ProjectId: cfg.ProjectID,
+		Build:     build,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image build: %w", err)
+	}
+
+	logger := logging.FromContext(ctx)
+	logger.InfoContext(ctx, "image build started",
+		"build_id", created.GetId(),
+		"log_url", created.GetLogUrl(),
+		"images", images)
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for {
+		got, err := b.client.GetBuild(ctx, &cloudbuildpb.GetBuildRequest{
+			Name:      fmt.Sprintf("projects/%s/locations/%s/builds/%s", cfg.ProjectID, cfg.Location, created.GetId()),
+			ProjectId: cfg.ProjectID,
+			Id:        created.GetId(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image build %s: %w", created.GetId(), err)
+		}
+
+		switch got.GetStatus() {
+		case cloudbuildpb.Build_STATUS_UNKNOWN, cloudbuildpb.Build_PENDING, cloudbuildpb.Build_QUEUED, cloudbuildpb.Build_WORKING:
+		case cloudbuildpb.Build_SUCCESS:
+			return images, nil
+		default:
+			return nil, fmt.Errorf("image build %s finished with status %s, see %s",
+				created.GetId(), got.GetStatus(), created.GetLogUrl())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to wait for image build %s: %w", created.GetId(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// cloudBuild returns the build building the images of the selected profiles
+// in parallel, one step per profile, and the images it pushes.
+func cloudBuild(cfg *Config, m *Manifest) (*cloudbuildpb.Build, []string, error) {
+	profiles := cfg.Profiles
+	if len(profiles) == 0 {
+		for name := range m.Profiles {
+			profiles = append(profiles, name)
+		}
+		slices.Sort(profiles)
+	}
+
+	build := &cloudbuildpb.Build{
+		ServiceAccount: cfg.ServiceAccount,
+		Options: &cloudbuildpb.BuildOptions{
+			Logging: cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY,
+		},
+		Timeout: durationpb.New(buildTimeout),
+		Tags:    []string{"runner-image-build"},
+	}
+	if cfg.WorkerPoolID != "" {
+		build.Options.Pool = &cloudbuildpb.BuildOptions_PoolOption{Name: cfg.WorkerPoolID}
+	}
+
+	images := make([]string, 0, len(profiles))
+	for _, profile := range profiles {
+		dockerfile, err := m.Dockerfile(profile)
+		if err != nil {
+			return nil, nil, err
+		}
+		image := fmt.Sprintf("%s/%s:%s", cfg.RepositoryID, ImageName(cfg.ImageName, profile), cfg.Tag)
+		images = append(images, image)
+
+		// The Dockerfile is passed encoded, so Cloud Build does not substitute
+		// the shell variables in it. The build has no context, the toolsets
+		// are downloaded.
+		build.Steps = append(build.Steps, &cloudbuildpb.BuildStep{
+			Id:         "build-" + profile,
+			Name:       "gcr.io/cloud-builders/docker",
+			Entrypoint: "bash",
+			Args: []string{
+				"-c",
+				fmt.Sprintf("echo %s | base64 -d | docker build -t %s -",
+					base64.StdEncoding.EncodeToString([]byte(dockerfile)), image),
+			},
+			WaitFor: []string{"-"},
+		})
+	}
+	// Cloud Build pushes the images once all steps succeeded.
+	build.Images = images
+	return build, images, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuilderBuild(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		ProjectID:    "p",
+		Location:     "us-central1",
+		RepositoryID: "us-docker.pkg.dev/p/runners",
+		ImageName:    "default-runner",
+		Tag:          "v1",
+		WorkerPoolID: "projects/p/locations/us-central1/workerPools/pool",
+	}
+
+	cases := []struct {
+		name       string
+		profiles   []string
+		statuses   []cloudbuildpb.Build_Status
+		createErr  error
+		wantImages []string
+		wantErr    string
+	}{
+		{
+			name:     "all_profiles",
+			statuses: []cloudbuildpb.Build_Status{cloudbuildpb.Build_QUEUED, cloudbuildpb.Build_WORKING, cloudbuildpb.Build_SUCCESS},
+			wantImages: []string{
+				"us-docker.pkg.dev/p/runners/default-runner:v1",
+				"us-docker.pkg.dev/p/runners/default-runner-web:v1",
+			},
+		},
+		{
+			name:       "selected_profile",
+			profiles:   []string{"web"},
+			wantImages: []string{"us-docker.pkg.dev/p/runners/default-runner-web:v1"},
+		},
+		{
+			name:     "build_failed",
+			statuses: []cloudbuildpb.Build_Status{cloudbuildpb.Build_WORKING, cloudbuildpb.Build_FAILURE},
+			wantErr:  "image build mock-build-id finished with status FAILURE",
+		},
+		{
+			name:      "create_failed",
+			createErr: errors.New("permission denied"),
+			wantErr:   "failed to create image build: permission denied",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := *cfg
+			cfg.Profiles = tc.profiles
+
+			client := &MockCloudBuildClient{Statuses: tc.statuses, CreateBuildErr: tc.createErr}
+			b := NewBuilder(client)
+			b.pollInterval = time.Millisecond
+
+			images, err := b.Build(t.Context(), &cfg, m)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.wantImages, images); diff != "" {
+				t.Errorf("unexpected images (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCloudBuild(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build, _, err := cloudBuild(&Config{
+		RepositoryID: "us-docker.pkg.dev/p/runners",
+		ImageName:    "default-runner",
+		Tag:          "latest",
+		Profiles:     []string{"default"},
+	}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := build.GetImages(), []string{"us-docker.pkg.dev/p/runners/default-runner:latest"}; !cmp.Equal(got, want) {
+		t.Errorf("expected images %q to be %q", got, want)
+	}
+	if got := build.GetOptions().GetPool(); got != nil {
+		t.Errorf("expected no worker pool, got %v", got)
+	}
+	if got, want := len(build.GetSteps()), 1; got != want {
+		t.Fatalf("expected %d steps to be %d", got, want)
+	}
+
+	// The Dockerfile is encoded into the step, decode it back.
+	script := build.GetSteps()[0].GetArgs()[1]
+	encoded := strings.Fields(script)[1]
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("failed to decode Dockerfile from %q: %v", script, err)
+	}
+	want, err := m.Dockerfile("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Errorf("unexpected Dockerfile (-want, +got):\n%s", diff)
+	}
+	if !strings.HasSuffix(script, "| docker build -t us-docker.pkg.dev/p/runners/default-runner:latest -") {
+		t.Errorf("expected script %q to build the image", script)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+
+	"github.com/googleapis/gax-go/v2"
+)
+
+// MockCloudBuildClient records the created build and reports the statuses in
+// Statuses, one per GetBuild call. The last status repeats.
+type MockCloudBuildClient struct {
+	CreateBuildErr error
+	Statuses       []cloudbuildpb.Build_Status
+
+	mu             sync.Mutex
+	CreateBuildReq *cloudbuildpb.CreateBuildRequest
+	getBuildCalls  int
+}
+
+func (m *MockCloudBuildClient) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.CreateBuildReq = req
+	if m.CreateBuildErr != nil {
+		return nil, m.CreateBuildErr
+	}
+	return &cloudbuildpb.Build{Id: "mock-build-id", LogUrl: "https://console.cloud.google.com/cloud-build/builds/mock-build-id"}, nil
+}
+
+func (m *MockCloudBuildClient) GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := cloudbuildpb.Build_SUCCESS
+	if len(m.Statuses) > 0 {
+		st = m.Statuses[min(m.getBuildCalls, len(m.Statuses)-1)]
+	}
+	m.getBuildCalls++
+	return &cloudbuildpb.Build{Id: req.GetId(), Status: st}, nil
+}
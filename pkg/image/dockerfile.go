@@ -0,0 +1,103 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+	"strings"
+)
+
+// basePackages are needed by the toolset install steps.
+var basePackages = []string{"ca-certificates", "curl", "gnupg", "jq", "tar", "xz-utils"}
+
+// Dockerfile renders the Dockerfile of a profile.
+func (m *Manifest) Dockerfile(profile string) (string, error) {
+	p, ok := m.Profiles[profile]
+	if !ok {
+		return "", fmt.Errorf("unknown profile %q", profile)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", m.Base)
+	b.WriteString("USER root\n")
+	// The setup-* actions find preinstalled versions through these variables.
+	fmt.Fprintf(&b, "ENV RUNNER_TOOL_CACHE=%[1]s AGENT_TOOLSDIRECTORY=%[1]s\n", m.ToolCache)
+	fmt.Fprintf(&b, "RUN %s\n", aptInstall(basePackages))
+
+	for _, name := range p.Toolsets {
+		ts := m.Toolsets[name]
+		fmt.Fprintf(&b, "# toolset %s\n", name)
+		for _, cmd := range ts.commands(m.ToolCache) {
+			fmt.Fprintf(&b, "RUN %s\n", cmd)
+		}
+	}
+
+	// The setup-* actions add versions missing from the image to the tool
+	// cache at run time.
+	fmt.Fprintf(&b, "RUN mkdir -p %[1]s && chown -R %[2]s %[1]s\n", m.ToolCache, m.User)
+	fmt.Fprintf(&b, "USER %s\n", m.User)
+	return b.String(), nil
+}
+
+// commands returns the shell commands installing the toolset, one per RUN
+// instruction.
+func (ts *Toolset) commands(toolCache string) []string {
+	switch ts.Kind {
+	case KindApt:
+		return []string{aptInstall(ts.Packages)}
+	case KindDocker:
+		return []string{"curl -fsSL https://get.docker.com | sh"}
+	case KindGcloud:
+		return []string{
+			"curl -fsSL https://packages.cloud.google.com/apt/doc/apt-key.gpg | gpg --dearmor -o /usr/share/keyrings/cloud.google.gpg" +
+				` && echo "deb [signed-by=/usr/share/keyrings/cloud.google.gpg] https://packages.cloud.google.com/apt cloud-sdk main" > /etc/apt/sources.list.d/google-cloud-sdk.list` +
+				" && " + aptInstall([]string{"google-cloud-cli"}),
+		}
+	case KindGo:
+		return toolCacheCommands(toolCache, "go", ts.Versions, "https://go.dev/dl/go%[1]s.linux-amd64.tar.gz")
+	case KindNode:
+		return toolCacheCommands(toolCache, "node", ts.Versions, "https://nodejs.org/dist/v%[1]s/node-v%[1]s-linux-x64.tar.gz")
+	case KindPython:
+		// The actions/python-versions builds are the ones setup-python
+		// downloads, their setup.sh installs them into the tool cache.
+		cmds := make([]string, 0, len(ts.Versions))
+		for _, v := range ts.Versions {
+			cmds = append(cmds, fmt.Sprintf(`url="$(curl -fsSL https://raw.githubusercontent.com/actions/python-versions/main/versions-manifest.json`+
+				` | jq -r --arg v %s --arg os "$(. /etc/os-release && echo "$VERSION_ID")"`+
+				` '.[] | select(.version == $v) | .files[] | select(.platform == "linux" and .arch == "x64" and .platform_version == $os) | .download_url'`+
+				` | head -n 1)"`+
+				` && test -n "$url" && mkdir -p /tmp/python && curl -fsSL "$url" | tar -xz -C /tmp/python`+
+				` && (cd /tmp/python && ./setup.sh) && rm -rf /tmp/python`, v))
+		}
+		return cmds
+	}
+	return nil
+}
+
+// toolCacheCommands installs release archives into the tool cache layout,
+// <tool cache>/<tool>/<version>/x64 with an x64.complete marker.
+func toolCacheCommands(toolCache, tool string, versions []string, urlFormat string) []string {
+	cmds := make([]string, 0, len(versions))
+	for _, v := range versions {
+		dir := fmt.Sprintf("%s/%s/%s", toolCache, tool, v)
+		url := fmt.Sprintf(urlFormat, v)
+		cmds = append(cmds, fmt.Sprintf("mkdir -p %[1]s/x64 && curl -fsSL %[2]s | tar -xz --strip-components=1 -C %[1]s/x64 && touch %[1]s/x64.complete", dir, url))
+	}
+	return cmds
+}
+
+func aptInstall(packages []string) string {
+	return "apt-get update && apt-get install -y --no-install-recommends " + strings.Join(packages, " ") + " && rm -rf /var/lib/apt/lists/*"
+}
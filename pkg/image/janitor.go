@@ -0,0 +1,266 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/api/artifactregistry/v1"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// prTagPattern matches the tags of the images built for pull requests, which
+// autopush runners select with a pr-<number> label.
+var prTagPattern = regexp.MustCompile(`^pr-(\d+)$`)
+
+// PruneConfig is the configuration of a pruning of pull request image tags.
+type PruneConfig struct {
+	RepositoryID     string
+	TTL              time.Duration
+	GitHubRepository string
+	GitHubToken      string
+	DryRun           bool
+}
+
+// Validate validates the prune config after load.
+func (cfg *PruneConfig) Validate() error {
+	if cfg.RepositoryID == "" {
+		return fmt.Errorf("RUNNER_REPOSITORY_ID is required")
+	}
+	if _, err := repositoryName(cfg.RepositoryID); err != nil {
+		return err
+	}
+	if cfg.TTL < 0 {
+		return fmt.Errorf("IMAGE_PRUNE_TTL must be positive")
+	}
+	if cfg.GitHubRepository != "" {
+		if _, _, ok := strings.Cut(cfg.GitHubRepository, "/"); !ok {
+			return fmt.Errorf("IMAGE_PRUNE_GITHUB_REPOSITORY must be of the form <owner>/<repo>, got %q", cfg.GitHubRepository)
+		}
+	}
+	if cfg.TTL == 0 && cfg.GitHubRepository == "" {
+		return fmt.Errorf("IMAGE_PRUNE_TTL or IMAGE_PRUNE_GITHUB_REPOSITORY is required")
+	}
+	return nil
+}
+
+// ToFlags binds the config to the [cli.FlagSet] and returns it.
+func (cfg *PruneConfig) ToFlags(set *cli.FlagSet) *cli.FlagSet {
+	f := set.NewSection("IMAGE PRUNE OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-repository-id",
+		Target:  &cfg.RepositoryID,
+		EnvVar:  "RUNNER_REPOSITORY_ID",
+		Example: "us-docker.pkg.dev/my-project/runners",
+		Usage:   `The repository of the runner images to prune.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "ttl",
+		Target:  &cfg.TTL,
+		EnvVar:  "IMAGE_PRUNE_TTL",
+		Default: 14 * 24 * time.Hour,
+		Usage: `How long after its last push a pull request tag is deleted regardless of the ` +
+			`state of the pull request, 0 to only delete the tags of closed pull requests.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "github-repository",
+		Target:  &cfg.GitHubRepository,
+		EnvVar:  "IMAGE_PRUNE_GITHUB_REPOSITORY",
+		Example: "google/github_actions_on_gcp",
+		Usage: `The repository of the pull requests the images are built for. When set, the ` +
+			`tags of merged and closed pull requests are deleted.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "github-token",
+		Target: &cfg.GitHubToken,
+		EnvVar: "GITHUB_TOKEN",
+		Usage:  `The token to read the pull requests with, required for private repositories.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "dry-run",
+		Target: &cfg.DryRun,
+		EnvVar: "IMAGE_PRUNE_DRY_RUN",
+		Usage:  `Whether to only print the tags that would be deleted.`,
+	})
+
+	return set
+}
+
+// ArtifactRegistryClient is the subset of the Artifact Registry API the
+// janitor uses.
+type ArtifactRegistryClient interface {
+	ListDockerImages(ctx context.Context, repository string) ([]*artifactregistry.DockerImage, error)
+	DeleteTag(ctx context.Context, name string) error
+}
+
+// PullRequestClient reports whether a pull request is merged or closed.
+type PullRequestClient interface {
+	PullRequestClosed(ctx context.Context, owner, repo string, number int) (bool, error)
+}
+
+// GitHubPullRequests reads pull requests with the GitHub API.
+type GitHubPullRequests struct {
+	client *github.Client
+}
+
+// NewGitHubPullRequests creates a pull request client, authenticated with the
+// token if it is set.
+func NewGitHubPullRequests(token string) *GitHubPullRequests {
+	client := github.NewClient(nil)
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	return &GitHubPullRequests{client: client}
+}
+
+// PullRequestClosed reports whether the pull request is merged or closed.
+func (g *GitHubPullRequests) PullRequestClosed(ctx context.Context, owner, repo string, number int) (bool, error) {
+	pr, _, err := g.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return false, fmt.Errorf("failed to get pull request %d: %w", number, err)
+	}
+	return pr.GetState() == "closed", nil
+}
+
+// Janitor deletes the tags of the images built for pull requests once the pull
+// request is closed or the tag outlived its TTL. Since every pull request
+// produces uniquely tagged images, the repository grows unboundedly otherwise.
+type Janitor struct {
+	registry     ArtifactRegistryClient
+	pullRequests PullRequestClient
+}
+
+// NewJanitor creates a janitor. The pull request client may be nil if the
+// prune config has no GitHub repository.
+func NewJanitor(registry ArtifactRegistryClient, pullRequests PullRequestClient) *Janitor {
+	return &Janitor{
+		registry:     registry,
+		pullRequests: pullRequests,
+	}
+}
+
+// Prune deletes the stale pull request tags of the repository and returns
+// them as <image>:<tag> references. Only the tags are deleted, the untagged
+// versions are left to the cleanup policies of the repository.
+func (j *Janitor) Prune(ctx context.Context, cfg *PruneConfig, now time.Time) ([]string, error) {
+	logger := logging.FromContext(ctx)
+
+	repository, err := repositoryName(cfg.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := j.registry.ListDockerImages(ctx, repository)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Want passthrough
+	}
+
+	owner, repo, _ := strings.Cut(cfg.GitHubRepository, "/")
+	// Pull requests usually have several images, one per profile.
+	closed := make(map[int]bool)
+
+	var pruned []string
+	for _, img := range images {
+		pkg, err := packageName(img.Name)
+		if err != nil {
+			return pruned, err
+		}
+
+		for _, tag := range img.Tags {
+			match := prTagPattern.FindStringSubmatch(tag)
+			if match == nil {
+				continue
+			}
+
+			stale := false
+			if cfg.TTL > 0 {
+				uploaded, err := time.Parse(time.RFC3339Nano, img.UploadTime)
+				if err != nil {
+					return pruned, fmt.Errorf("failed to parse upload time of %s: %w", img.Uri, err)
+				}
+				stale = now.Sub(uploaded) >= cfg.TTL
+			}
+			if !stale && cfg.GitHubRepository != "" {
+				number, err := strconv.Atoi(match[1])
+				if err != nil {
+					return pruned, fmt.Errorf("invalid pull request number in tag %q: %w", tag, err)
+				}
+				isClosed, ok := closed[number]
+				if !ok {
+					isClosed, err = j.pullRequests.PullRequestClosed(ctx, owner, repo, number)
+					if err != nil {
+						return pruned, err //nolint:wrapcheck // Want passthrough
+					}
+					closed[number] = isClosed
+				}
+				stale = isClosed
+			}
+			if !stale {
+				continue
+			}
+
+			ref := fmt.Sprintf("%s/%s:%s", cfg.RepositoryID, imagePath(pkg), tag)
+			if !cfg.DryRun {
+				if err := j.registry.DeleteTag(ctx, fmt.Sprintf("%s/packages/%s/tags/%s", repository, pkg, tag)); err != nil {
+					return pruned, fmt.Errorf("failed to delete %s: %w", ref, err)
+				}
+			}
+			logger.InfoContext(ctx, "pruned pull request image tag",
+				"image", ref,
+				"dry_run", cfg.DryRun)
+			pruned = append(pruned, ref)
+		}
+	}
+	return pruned, nil
+}
+
+// repositoryName returns the resource name of a repository given as
+// <location>-docker.pkg.dev/<project>/<repository>.
+func repositoryName(repositoryID string) (string, error) {
+	parts := strings.Split(repositoryID, "/")
+	location, ok := strings.CutSuffix(parts[0], "-docker.pkg.dev")
+	if len(parts) != 3 || !ok || location == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("repository %q must be of the form <location>-docker.pkg.dev/<project>/<repository>", repositoryID)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s", parts[1], location, parts[2]), nil
+}
+
+// packageName returns the escaped package of a Docker image resource name,
+// .../dockerImages/<package>@<digest>.
+func packageName(imageName string) (string, error) {
+	_, image, ok := strings.Cut(imageName, "/dockerImages/")
+	if !ok {
+		return "", fmt.Errorf("invalid docker image name %q", imageName)
+	}
+	pkg, _, _ := strings.Cut(image, "@")
+	return pkg, nil
+}
+
+// imagePath returns the image path of an escaped package name.
+func imagePath(pkg string) string {
+	return strings.ReplaceAll(pkg, "%2F", "/")
+}
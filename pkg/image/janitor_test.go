@@ -0,0 +1,174 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/artifactregistry/v1"
+)
+
+func TestJanitorPrune(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	const repository = "projects/p/locations/us/repositories/runners"
+	images := []*artifactregistry.DockerImage{
+		{
+			Name:       repository + "/dockerImages/default-runner@sha256:aaa",
+			Uri:        "us-docker.pkg.dev/p/runners/default-runner@sha256:aaa",
+			Tags:       []string{"latest", "pr-1"},
+			UploadTime: now.Add(-time.Hour).Format(time.RFC3339Nano),
+		},
+		{
+			Name:       repository + "/dockerImages/default-runner@sha256:bbb",
+			Uri:        "us-docker.pkg.dev/p/runners/default-runner@sha256:bbb",
+			Tags:       []string{"pr-2"},
+			UploadTime: now.Add(-30 * 24 * time.Hour).Format(time.RFC3339Nano),
+		},
+		{
+			Name:       repository + "/dockerImages/team%2Fdefault-runner-web@sha256:ccc",
+			Uri:        "us-docker.pkg.dev/p/runners/team/default-runner-web@sha256:ccc",
+			Tags:       []string{"pr-1", "pr-3", "pr-x"},
+			UploadTime: now.Add(-time.Hour).Format(time.RFC3339Nano),
+		},
+	}
+
+	cases := []struct {
+		name        string
+		cfg         *PruneConfig
+		deleteErr   error
+		prErr       error
+		wantPruned  []string
+		wantDeleted []string
+		wantErr     string
+	}{
+		{
+			name: "ttl",
+			cfg:  &PruneConfig{TTL: 14 * 24 * time.Hour},
+			wantPruned: []string{
+				"us-docker.pkg.dev/p/runners/default-runner:pr-2",
+			},
+			wantDeleted: []string{
+				repository + "/packages/default-runner/tags/pr-2",
+			},
+		},
+		{
+			name: "closed_pull_requests",
+			cfg:  &PruneConfig{TTL: 14 * 24 * time.Hour, GitHubRepository: "google/webhook"},
+			wantPruned: []string{
+				"us-docker.pkg.dev/p/runners/default-runner:pr-1",
+				"us-docker.pkg.dev/p/runners/default-runner:pr-2",
+				"us-docker.pkg.dev/p/runners/team/default-runner-web:pr-1",
+			},
+			wantDeleted: []string{
+				repository + "/packages/default-runner/tags/pr-1",
+				repository + "/packages/default-runner/tags/pr-2",
+				repository + "/packages/team%2Fdefault-runner-web/tags/pr-1",
+			},
+		},
+		{
+			name: "dry_run",
+			cfg:  &PruneConfig{GitHubRepository: "google/webhook", DryRun: true},
+			wantPruned: []string{
+				"us-docker.pkg.dev/p/runners/default-runner:pr-1",
+				"us-docker.pkg.dev/p/runners/team/default-runner-web:pr-1",
+			},
+		},
+		{
+			name:    "pull_request_error",
+			cfg:     &PruneConfig{GitHubRepository: "google/webhook"},
+			prErr:   errors.New("rate limited"),
+			wantErr: "rate limited",
+		},
+		{
+			name:      "delete_error",
+			cfg:       &PruneConfig{TTL: 14 * 24 * time.Hour},
+			deleteErr: errors.New("permission denied"),
+			wantErr:   "failed to delete us-docker.pkg.dev/p/runners/default-runner:pr-2: permission denied",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			registry := &MockArtifactRegistryClient{Images: images, DeleteTagErr: tc.deleteErr}
+			pullRequests := &MockPullRequestClient{Closed: []int{1}, Err: tc.prErr}
+
+			cfg := *tc.cfg
+			cfg.RepositoryID = "us-docker.pkg.dev/p/runners"
+			pruned, err := NewJanitor(registry, pullRequests).Prune(t.Context(), &cfg, now)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.wantPruned, pruned); diff != "" {
+				t.Errorf("unexpected pruned tags (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeleted, registry.DeletedTags); diff != "" {
+				t.Errorf("unexpected deleted tags (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPruneConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		cfg     *PruneConfig
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cfg:  &PruneConfig{RepositoryID: "us-docker.pkg.dev/p/runners", TTL: time.Hour},
+		},
+		{
+			name:    "missing_repository",
+			cfg:     &PruneConfig{TTL: time.Hour},
+			wantErr: "RUNNER_REPOSITORY_ID is required",
+		},
+		{
+			name:    "invalid_repository",
+			cfg:     &PruneConfig{RepositoryID: "gcr.io/p/runners", TTL: time.Hour},
+			wantErr: `repository "gcr.io/p/runners" must be of the form`,
+		},
+		{
+			name:    "invalid_github_repository",
+			cfg:     &PruneConfig{RepositoryID: "us-docker.pkg.dev/p/runners", GitHubRepository: "webhook"},
+			wantErr: "IMAGE_PRUNE_GITHUB_REPOSITORY must be of the form <owner>/<repo>",
+		},
+		{
+			name:    "nothing_to_prune",
+			cfg:     &PruneConfig{RepositoryID: "us-docker.pkg.dev/p/runners"},
+			wantErr: "IMAGE_PRUNE_TTL or IMAGE_PRUNE_GITHUB_REPOSITORY is required",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(tc.cfg.Validate(), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package image builds runner images from a base image and declarative
+// toolset manifests, one image per runner profile.
+package image
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultProfileName is the profile whose image has the plain image name.
+	// It matches the webhook's default runner profile.
+	defaultProfileName = "default"
+
+	defaultUser      = "runner"
+	defaultToolCache = "/opt/hostedtoolcache"
+)
+
+// Toolset kinds.
+const (
+	KindApt    = "apt"
+	KindDocker = "docker"
+	KindGcloud = "gcloud"
+	KindGo     = "go"
+	KindNode   = "node"
+	KindPython = "python"
+)
+
+var (
+	// profileNameRegexp matches profile names, which become part of image
+	// names.
+	profileNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+	// versionRegexp matches the exact versions the setup-* actions look up in
+	// the tool cache.
+	versionRegexp = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+	// packageRegexp matches Debian package names.
+	packageRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+
+	// userRegexp matches user names and numeric IDs.
+	userRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]*$|^[0-9]+$`)
+)
+
+// Manifest declares the runner images to build.
+type Manifest struct {
+	// Base is the image every profile is built from, e.g.
+	// ghcr.io/actions/actions-runner:latest.
+	Base string `yaml:"base"`
+
+	// User is the user the base image runs as. Toolsets are installed as root
+	// and the image switches back to this user. Defaults to "runner".
+	User string `yaml:"user"`
+
+	// ToolCache is the directory language runtimes are installed in, laid out
+	// the way the setup-* actions expect. Defaults to /opt/hostedtoolcache.
+	ToolCache string `yaml:"tool_cache"`
+
+	// Toolsets are the installable toolsets, by name.
+	Toolsets map[string]*Toolset `yaml:"toolsets"`
+
+	// Profiles are the images to build, by runner profile.
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// Toolset is a set of tools installed into an image.
+type Toolset struct {
+	// Kind selects how the toolset is installed, defaults to the toolset name.
+	Kind string `yaml:"kind"`
+
+	// Versions are the exact runtime versions to install for the go, node and
+	// python kinds.
+	Versions []string `yaml:"versions"`
+
+	// Packages are the Debian packages to install for the apt kind.
+	Packages []string `yaml:"packages"`
+}
+
+// Profile is the image of a runner profile.
+type Profile struct {
+	// Toolsets are the names of the toolsets installed, in order.
+	Toolsets []string `yaml:"toolsets"`
+}
+
+// ParseManifest parses and validates a manifest.
+func ParseManifest(b []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse image manifest: %w", err)
+	}
+
+	if m.User == "" {
+		m.User = defaultUser
+	}
+	if m.ToolCache == "" {
+		m.ToolCache = defaultToolCache
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	if m.Base == "" {
+		return fmt.Errorf("image manifest: base is required")
+	}
+	if !userRegexp.MatchString(m.User) {
+		return fmt.Errorf("image manifest: invalid user %q", m.User)
+	}
+	if !path.IsAbs(m.ToolCache) || path.Clean(m.ToolCache) != m.ToolCache {
+		return fmt.Errorf("image manifest: tool_cache must be a clean absolute path, got %q", m.ToolCache)
+	}
+
+	for name, ts := range m.Toolsets {
+		if ts == nil {
+			return fmt.Errorf("toolset %q is empty", name)
+		}
+		if ts.Kind == "" {
+			ts.Kind = name
+		}
+		if err := ts.validate(); err != nil {
+			return fmt.Errorf("toolset %q: %w", name, err)
+		}
+	}
+
+	if len(m.Profiles) == 0 {
+		return fmt.Errorf("image manifest: at least one profile is required")
+	}
+	for name, p := range m.Profiles {
+		if !profileNameRegexp.MatchString(name) {
+			return fmt.Errorf("profile name %q must match %s", name, profileNameRegexp)
+		}
+		if p == nil {
+			return fmt.Errorf("profile %q is empty", name)
+		}
+		for i, ts := range p.Toolsets {
+			if _, ok := m.Toolsets[ts]; !ok {
+				return fmt.Errorf("profile %q: unknown toolset %q", name, ts)
+			}
+			if slices.Contains(p.Toolsets[:i], ts) {
+				return fmt.Errorf("profile %q: duplicate toolset %q", name, ts)
+			}
+		}
+	}
+	return nil
+}
+
+func (ts *Toolset) validate() error {
+	switch ts.Kind {
+	case KindGo, KindNode, KindPython:
+		if len(ts.Versions) == 0 {
+			return fmt.Errorf("%s toolset requires versions", ts.Kind)
+		}
+		for _, v := range ts.Versions {
+			if !versionRegexp.MatchString(v) {
+				return fmt.Errorf("version %q must be an exact version like 1.2.3", v)
+			}
+		}
+	case KindApt:
+		if len(ts.Packages) == 0 {
+			return fmt.Errorf("apt toolset requires packages")
+		}
+		for _, p := range ts.Packages {
+			if !packageRegexp.MatchString(p) {
+				return fmt.Errorf("invalid package name %q", p)
+			}
+		}
+	case KindDocker, KindGcloud:
+	default:
+		return fmt.Errorf("unknown kind %q", ts.Kind)
+	}
+	return nil
+}
+
+// ImageName returns the name of the image of a profile. The default profile
+// uses the image name itself, the other profiles append their name to it, so
+// jobs select them with an image=<name>-<profile> label.
+func ImageName(imageName, profile string) string {
+	if profile == defaultProfileName {
+		return imageName
+	}
+	return imageName + "-" + profile
+}
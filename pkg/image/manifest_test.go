@@ -0,0 +1,192 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testManifest = `
+base: ghcr.io/actions/actions-runner:latest
+toolsets:
+  node:
+    versions: ["20.18.1"]
+  python:
+    versions: ["3.12.8", "3.11.11"]
+  gcloud: {}
+  build:
+    kind: apt
+    packages: [build-essential]
+profiles:
+  default:
+    toolsets: [gcloud]
+  web:
+    toolsets: [build, node, python]
+`
+
+func TestParseManifest(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			data: testManifest,
+		},
+		{
+			name:    "invalid_yaml",
+			data:    "base: [",
+			wantErr: "failed to parse image manifest",
+		},
+		{
+			name:    "no_base",
+			data:    "profiles: {default: {}}",
+			wantErr: "base is required",
+		},
+		{
+			name:    "no_profiles",
+			data:    "base: ubuntu",
+			wantErr: "at least one profile is required",
+		},
+		{
+			name:    "relative_tool_cache",
+			data:    "base: ubuntu\ntool_cache: opt/tools\nprofiles: {default: {}}",
+			wantErr: "tool_cache must be a clean absolute path",
+		},
+		{
+			name:    "invalid_user",
+			data:    "base: ubuntu\nuser: 'runner; rm -rf /'\nprofiles: {default: {}}",
+			wantErr: "invalid user",
+		},
+		{
+			name:    "unknown_kind",
+			data:    "base: ubuntu\ntoolsets: {rust: {}}\nprofiles: {default: {}}",
+			wantErr: `toolset "rust": unknown kind "rust"`,
+		},
+		{
+			name:    "missing_versions",
+			data:    "base: ubuntu\ntoolsets: {node: {}}\nprofiles: {default: {}}",
+			wantErr: "node toolset requires versions",
+		},
+		{
+			name:    "partial_version",
+			data:    "base: ubuntu\ntoolsets: {go: {versions: ['1.24']}}\nprofiles: {default: {}}",
+			wantErr: `version "1.24" must be an exact version`,
+		},
+		{
+			name:    "invalid_package",
+			data:    "base: ubuntu\ntoolsets: {tools: {kind: apt, packages: ['jq && curl']}}\nprofiles: {default: {}}",
+			wantErr: `invalid package name "jq && curl"`,
+		},
+		{
+			name:    "invalid_profile_name",
+			data:    "base: ubuntu\nprofiles: {Default: {}}",
+			wantErr: `profile name "Default" must match`,
+		},
+		{
+			name:    "unknown_toolset",
+			data:    "base: ubuntu\nprofiles: {default: {toolsets: [node]}}",
+			wantErr: `profile "default": unknown toolset "node"`,
+		},
+		{
+			name:    "duplicate_toolset",
+			data:    "base: ubuntu\ntoolsets: {docker: {}}\nprofiles: {default: {toolsets: [docker, docker]}}",
+			wantErr: `profile "default": duplicate toolset "docker"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseManifest([]byte(tc.data))
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestManifestDockerfile(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Dockerfile("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+
+	want := []string{
+		"FROM ghcr.io/actions/actions-runner:latest",
+		"USER root",
+		"ENV RUNNER_TOOL_CACHE=/opt/hostedtoolcache AGENT_TOOLSDIRECTORY=/opt/hostedtoolcache",
+		"RUN apt-get update && apt-get install -y --no-install-recommends ca-certificates curl gnupg jq tar xz-utils && rm -rf /var/lib/apt/lists/*",
+		"# toolset build",
+		"RUN apt-get update && apt-get install -y --no-install-recommends build-essential && rm -rf /var/lib/apt/lists/*",
+		"# toolset node",
+		"RUN mkdir -p /opt/hostedtoolcache/node/20.18.1/x64" +
+			" && curl -fsSL https://nodejs.org/dist/v20.18.1/node-v20.18.1-linux-x64.tar.gz | tar -xz --strip-components=1 -C /opt/hostedtoolcache/node/20.18.1/x64" +
+			" && touch /opt/hostedtoolcache/node/20.18.1/x64.complete",
+		"# toolset python",
+	}
+	if diff := cmp.Diff(want, lines[:len(want)]); diff != "" {
+		t.Errorf("unexpected Dockerfile (-want, +got):\n%s", diff)
+	}
+
+	python := lines[len(want) : len(lines)-2]
+	if got, want := len(python), 2; got != want {
+		t.Fatalf("expected %d python install steps to be %d", got, want)
+	}
+	for i, v := range []string{"3.12.8", "3.11.11"} {
+		if !strings.Contains(python[i], "--arg v "+v+" ") {
+			t.Errorf("expected python step %q to install %s", python[i], v)
+		}
+	}
+
+	tail := []string{
+		"RUN mkdir -p /opt/hostedtoolcache && chown -R runner /opt/hostedtoolcache",
+		"USER runner",
+	}
+	if diff := cmp.Diff(tail, lines[len(lines)-2:]); diff != "" {
+		t.Errorf("unexpected Dockerfile end (-want, +got):\n%s", diff)
+	}
+
+	if _, err := m.Dockerfile("missing"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestImageName(t *testing.T) {
+	t.Parallel()
+
+	if got, want := ImageName("default-runner", "default"), "default-runner"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := ImageName("default-runner", "web"), "default-runner-web"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lifecycle models the states a job and the runner provisioned for it
+// move through, from the queued event until the job completes or the runner is
+// abandoned, and validates the transitions between them.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// State is a point in the life of a job and its runner.
+type State string
+
+const (
+	// StateQueued is a job waiting for a runner.
+	StateQueued State = "queued"
+
+	// StateDispatching is a runner being registered with GitHub and its build
+	// being created.
+	StateDispatching State = "dispatching"
+
+	// StateProvisioning is a runner whose build was created but is not running
+	// yet.
+	StateProvisioning State = "provisioning"
+
+	// StateOnline is a runner whose build is running and waiting for a job.
+	StateOnline State = "online"
+
+	// StateRunning is a runner that picked up a job.
+	StateRunning State = "running"
+
+	// StateCompleted is a runner whose job finished.
+	StateCompleted State = "completed"
+
+	// StateFailed is a runner that could not be dispatched or whose build
+	// failed.
+	StateFailed State = "failed"
+
+	// StateOrphaned is a runner whose build finished without its job being
+	// reported as complete.
+	StateOrphaned State = "orphaned"
+)
+
+// States lists all states in lifecycle order.
+var States = [...]State{
+	StateQueued,
+	StateDispatching,
+	StateProvisioning,
+	StateOnline,
+	StateRunning,
+	StateCompleted,
+	StateFailed,
+	StateOrphaned,
+}
+
+// transitions lists the states each non-terminal state can move to. A runner
+// can start running before its build was observed online. A reusable runner
+// goes back online after each of its jobs, and completes once drained while
+// online.
+var transitions = map[State][]State{
+	StateQueued:       {StateDispatching, StateFailed},
+	StateDispatching:  {StateProvisioning, StateFailed},
+	StateProvisioning: {StateOnline, StateRunning, StateFailed, StateOrphaned},
+	StateOnline:       {StateRunning, StateCompleted, StateFailed, StateOrphaned},
+	StateRunning:      {StateOnline, StateCompleted, StateFailed, StateOrphaned},
+}
+
+// ErrInvalidTransition is returned when moving between two states that are not
+// connected.
+var ErrInvalidTransition = errors.New("invalid lifecycle transition")
+
+// Terminal reports whether no further transitions are possible from s.
+func (s State) Terminal() bool {
+	_, ok := transitions[s]
+	return !ok
+}
+
+// CanTransition reports whether a job can move from one state to the other.
+func CanTransition(from, to State) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Lifecycle is the current state of a job and its runner and when each state
+// was entered. The zero value is not usable, create one with New. A Lifecycle
+// is a value and can be copied.
+type Lifecycle struct {
+	state   State
+	entered [len(States)]time.Time
+}
+
+// New returns a lifecycle of a job that was queued at the given time.
+func New(queuedAt time.Time) Lifecycle {
+	var l Lifecycle
+	l.state = StateQueued
+	l.entered[0] = queuedAt
+	return l
+}
+
+// Restore returns the lifecycle of a job in the given state, which entered
+// its states at the given times, such as one persisted before a restart.
+// States that were never entered are left out of enteredAt.
+func Restore(state State, enteredAt map[State]time.Time) (Lifecycle, error) {
+	i := slices.Index(States[:], state)
+	if i < 0 {
+		return Lifecycle{}, fmt.Errorf("unknown lifecycle state %q", state)
+	}
+	var l Lifecycle
+	l.state = state
+	for j, s := range States {
+		l.entered[j] = enteredAt[s]
+	}
+	return l, nil
+}
+
+// State returns the current state.
+func (l *Lifecycle) State() State {
+	return l.state
+}
+
+// EnteredAt returns when the given state was entered, or the zero time if it
+// never was.
+func (l *Lifecycle) EnteredAt(s State) time.Time {
+	i := slices.Index(States[:], s)
+	if i < 0 {
+		return time.Time{}
+	}
+	return l.entered[i]
+}
+
+// Transition moves to the given state at the given time. It returns an error
+// wrapping ErrInvalidTransition if the current state cannot move to it.
+func (l *Lifecycle) Transition(to State, at time.Time) error {
+	if !CanTransition(l.state, to) {
+		return fmt.Errorf("%w from %q to %q", ErrInvalidTransition, l.state, to)
+	}
+	l.state = to
+	l.entered[slices.Index(States[:], to)] = at
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLifecycle_Transition(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		states    []State
+		wantState State
+		wantErr   bool
+	}{
+		{
+			name:      "completed",
+			states:    []State{StateDispatching, StateProvisioning, StateOnline, StateRunning, StateCompleted},
+			wantState: StateCompleted,
+		},
+		{
+			name:      "running_before_online",
+			states:    []State{StateDispatching, StateProvisioning, StateRunning},
+			wantState: StateRunning,
+		},
+		{
+			name:      "reused",
+			states:    []State{StateDispatching, StateProvisioning, StateRunning, StateOnline, StateRunning, StateCompleted},
+			wantState: StateCompleted,
+		},
+		{
+			name:      "drained",
+			states:    []State{StateDispatching, StateProvisioning, StateRunning, StateOnline, StateCompleted},
+			wantState: StateCompleted,
+		},
+		{
+			name:      "dispatch_failed",
+			states:    []State{StateDispatching, StateFailed},
+			wantState: StateFailed,
+		},
+		{
+			name:      "orphaned",
+			states:    []State{StateDispatching, StateProvisioning, StateOnline, StateOrphaned},
+			wantState: StateOrphaned,
+		},
+		{
+			name:      "skips_dispatch",
+			states:    []State{StateRunning},
+			wantState: StateQueued,
+			wantErr:   true,
+		},
+		{
+			name:      "leaves_terminal",
+			states:    []State{StateDispatching, StateFailed, StateProvisioning},
+			wantState: StateFailed,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := New(start)
+			var err error
+			for i, s := range tc.states {
+				if err = l.Transition(s, start.Add(time.Duration(i+1)*time.Minute)); err != nil {
+					break
+				}
+			}
+
+			if got, want := err != nil, tc.wantErr; got != want {
+				t.Errorf("expected error %t to be %t: %v", got, want, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("expected error %v to be ErrInvalidTransition", err)
+			}
+			if got, want := l.State(), tc.wantState; got != want {
+				t.Errorf("expected state %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLifecycle_EnteredAt(t *testing.T) {
+	t.Parallel()
+
+	queuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	dispatchedAt := queuedAt.Add(time.Second)
+
+	l := New(queuedAt)
+	if err := l.Transition(StateDispatching, dispatchedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copies must not share timestamps with the original.
+	c := l
+	if err := c.Transition(StateProvisioning, dispatchedAt.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := l.EnteredAt(StateQueued), queuedAt; !got.Equal(want) {
+		t.Errorf("expected queued at %s to be %s", got, want)
+	}
+	if got, want := l.EnteredAt(StateDispatching), dispatchedAt; !got.Equal(want) {
+		t.Errorf("expected dispatching at %s to be %s", got, want)
+	}
+	if got := l.EnteredAt(StateProvisioning); !got.IsZero() {
+		t.Errorf("expected provisioning to not be entered, got %s", got)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	queuedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	runningAt := queuedAt.Add(time.Minute)
+
+	l, err := Restore(StateRunning, map[State]time.Time{
+		StateQueued:  queuedAt,
+		StateRunning: runningAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.State(), StateRunning; got != want {
+		t.Errorf("expected state %q to be %q", got, want)
+	}
+	if got, want := l.EnteredAt(StateRunning), runningAt; !got.Equal(want) {
+		t.Errorf("expected running at %s to be %s", got, want)
+	}
+	if got := l.EnteredAt(StateOnline); !got.IsZero() {
+		t.Errorf("expected online to not be entered, got %s", got)
+	}
+	if err := l.Transition(StateCompleted, runningAt.Add(time.Minute)); err != nil {
+		t.Errorf("expected restored lifecycle to transition: %v", err)
+	}
+
+	if _, err := Restore("unknown", nil); err == nil {
+		t.Errorf("expected an error restoring an unknown state")
+	}
+}
+
+func TestState_Terminal(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range States {
+		want := s == StateCompleted || s == StateFailed || s == StateOrphaned
+		if got := s.Terminal(); got != want {
+			t.Errorf("expected %q terminal %t to be %t", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics is a small in-process metrics registry that is served in the
+// Prometheus text exposition format, so it can be scraped by Google Cloud
+// Managed Service for Prometheus or any other Prometheus compatible collector.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labelSeparator joins label values into map keys. It cannot appear in valid
+// UTF-8 label values.
+const labelSeparator = "\xff"
+
+// collector is a metric that can write itself in the text exposition format.
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics and serves them over HTTP.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter registers a counter with the given name, help text and label
+// names. A nil registry returns a nil counter, which discards all updates.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	c := &Counter{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+	r.register(c)
+	return c
+}
+
+// NewGauge registers a gauge with the given name, help text and label names.
+// A nil registry returns a nil gauge, which discards all updates.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	if r == nil {
+		return nil
+	}
+
+	g := &Gauge{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+	r.register(g)
+	return g
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// ServeHTTP writes all registered metrics in the text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Write writes all registered metrics in the text exposition format.
+func (r *Registry) Write(w io.Writer) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Counter is a monotonically increasing value, partitioned by label values.
+// All methods are safe to call on a nil counter.
+type Counter struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by v. Negative values
+// are ignored. The number of label values must match the label names the
+// counter was registered with.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if c == nil || v < 0 {
+		return
+	}
+	if len(labelValues) != len(c.labelNames) {
+		panic(fmt.Sprintf("metrics: counter %s expects %d label values, got %d", c.name, len(c.labelNames), len(labelValues)))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labelValues, labelSeparator)] += v
+}
+
+// Value returns the current value of the counter for the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[strings.Join(labelValues, labelSeparator)]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	keys, values := snapshot(c.values)
+	c.mu.Unlock()
+
+	writeFamily(w, c.name, c.help, "counter", c.labelNames, keys, values)
+}
+
+// Gauge is a value that can go up and down, partitioned by label values. All
+// methods are safe to call on a nil gauge.
+type Gauge struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Set sets the gauge for the given label values to v. The number of label
+// values must match the label names the gauge was registered with.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	if g == nil {
+		return
+	}
+	if len(labelValues) != len(g.labelNames) {
+		panic(fmt.Sprintf("metrics: gauge %s expects %d label values, got %d", g.name, len(g.labelNames), len(labelValues)))
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[strings.Join(labelValues, labelSeparator)] = v
+}
+
+// Value returns the current value of the gauge for the given label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	if g == nil {
+		return 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[strings.Join(labelValues, labelSeparator)]
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	keys, values := snapshot(g.values)
+	g.mu.Unlock()
+
+	writeFamily(w, g.name, g.help, "gauge", g.labelNames, keys, values)
+}
+
+// snapshot returns the series keys of values, sorted, and their values.
+func snapshot(m map[string]float64) ([]string, []float64) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	values := make([]float64, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return keys, values
+}
+
+// writeFamily writes a metric family of the given type in the text exposition
+// format.
+func writeFamily(w io.Writer, name, help, typ string, labelNames, keys []string, values []float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(labelNames, k), strconv.FormatFloat(values[i], 'g', -1, 64))
+	}
+}
+
+// formatLabels renders the label set of a series, key holds the label values
+// joined by labelSeparator.
+func formatLabels(names []string, key string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	values := strings.Split(key, labelSeparator)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
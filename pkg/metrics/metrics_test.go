@@ -0,0 +1,94 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	events := r.NewCounter("events_total", "Events received.", "event_type")
+	events.Inc("workflow_job")
+	events.Inc("workflow_job")
+	events.Add(3, "ping")
+	events.Add(-1, "ping")
+
+	quoted := r.NewCounter("quoted_total", "Help with a \\ backslash.", "value")
+	quoted.Inc("a \"quoted\"\nvalue")
+
+	plain := r.NewCounter("plain_total", "No labels.")
+	plain.Inc()
+
+	depth := r.NewGauge("queue_depth", "Items in the queue.", "queue")
+	depth.Set(5, "launch")
+	depth.Set(2, "launch")
+
+	if got, want := events.Value("workflow_job"), 2.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+	if got, want := depth.Value("launch"), 2.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := `# HELP events_total Events received.
+# TYPE events_total counter
+events_total{event_type="ping"} 3
+events_total{event_type="workflow_job"} 2
+# HELP quoted_total Help with a \\ backslash.
+# TYPE quoted_total counter
+quoted_total{value="a \"quoted\"\nvalue"} 1
+# HELP plain_total No labels.
+# TYPE plain_total counter
+plain_total 1
+# HELP queue_depth Items in the queue.
+# TYPE queue_depth gauge
+queue_depth{queue="launch"} 2
+`
+	if diff := cmp.Diff(want, resp.Body.String()); diff != "" {
+		t.Errorf("unexpected exposition (-want, +got):\n%s", diff)
+	}
+}
+
+func TestNilCounter(t *testing.T) {
+	t.Parallel()
+
+	var r *Registry
+	c := r.NewCounter("events_total", "Events received.", "event_type")
+	c.Inc("workflow_job")
+	if got := c.Value("workflow_job"); got != 0 {
+		t.Errorf("expected nil counter value to be 0, got %v", got)
+	}
+}
+
+func TestNilGauge(t *testing.T) {
+	t.Parallel()
+
+	var r *Registry
+	g := r.NewGauge("queue_depth", "Items in the queue.")
+	g.Set(1)
+	if got := g.Value(); got != 0 {
+		t.Errorf("expected nil gauge value to be 0, got %v", got)
+	}
+}
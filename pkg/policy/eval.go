@@ -0,0 +1,562 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// scope holds the variables visible to an expression. Macros bind their
+// variable in a nested scope.
+type scope struct {
+	vars   map[string]any
+	name   string
+	val    any
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for ; s != nil; s = s.parent {
+		if s.vars != nil {
+			v, ok := s.vars[name]
+			return v, ok
+		}
+		if s.name == name {
+			return s.val, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Program) eval(n *node, sc *scope) (any, error) {
+	switch n.kind {
+	case nodeLiteral:
+		return n.val, nil
+
+	case nodeIdent:
+		v, ok := sc.lookup(n.op)
+		if !ok {
+			return nil, fmt.Errorf("no value for variable %q", n.op)
+		}
+		return v, nil
+
+	case nodeUnary:
+		v, err := p.eval(n.args[0], sc)
+		if err != nil {
+			return nil, err
+		}
+		return unary(n, v)
+
+	case nodeBinary:
+		if n.op == "&&" || n.op == "||" {
+			return p.logical(n, sc)
+		}
+		l, err := p.eval(n.args[0], sc)
+		if err != nil {
+			return nil, err
+		}
+		r, err := p.eval(n.args[1], sc)
+		if err != nil {
+			return nil, err
+		}
+		return binary(n, l, r)
+
+	case nodeCond:
+		cond, err := p.eval(n.args[0], sc)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return nil, noOverload(n, cond)
+		}
+		if b {
+			return p.eval(n.args[1], sc)
+		}
+		return p.eval(n.args[2], sc)
+
+	case nodeList:
+		list := make([]any, 0, len(n.args))
+		for _, arg := range n.args {
+			v, err := p.eval(arg, sc)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+
+	case nodeIndex:
+		v, err := p.eval(n.args[0], sc)
+		if err != nil {
+			return nil, err
+		}
+		index, err := p.eval(n.args[1], sc)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := v.([]any)
+		i, iok := index.(int64)
+		if !ok || !iok {
+			return nil, noOverload(n, v, index)
+		}
+		if i < 0 || i >= int64(len(list)) {
+			return nil, fmt.Errorf("index %d out of range at offset %d", i, n.pos)
+		}
+		return list[i], nil
+
+	case nodeCall:
+		if n.receiver && (n.op == "exists" || n.op == "all") {
+			return p.macro(n, sc)
+		}
+		args := make([]any, 0, len(n.args))
+		for _, arg := range n.args {
+			v, err := p.eval(arg, sc)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		return p.call(n, args)
+	}
+	return nil, fmt.Errorf("unexpected node at offset %d", n.pos)
+}
+
+// logical evaluates && and || left to right, short-circuiting on the left
+// operand.
+func (p *Program) logical(n *node, sc *scope) (any, error) {
+	l, err := p.eval(n.args[0], sc)
+	if err != nil {
+		return nil, err
+	}
+	lb, ok := l.(bool)
+	if !ok {
+		return nil, noOverload(n, l)
+	}
+	if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+		return lb, nil
+	}
+
+	r, err := p.eval(n.args[1], sc)
+	if err != nil {
+		return nil, err
+	}
+	rb, ok := r.(bool)
+	if !ok {
+		return nil, noOverload(n, l, r)
+	}
+	return rb, nil
+}
+
+// macro evaluates exists and all, which bind each element of the list to the
+// variable named by the first argument while evaluating the predicate.
+func (p *Program) macro(n *node, sc *scope) (any, error) {
+	v, err := p.eval(n.args[0], sc)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, noOverload(n, v)
+	}
+
+	for _, elem := range list {
+		r, err := p.eval(n.args[2], &scope{name: n.args[1].op, val: elem, parent: sc})
+		if err != nil {
+			return nil, err
+		}
+		b, ok := r.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s predicate at offset %d returned %s, want bool", n.op, n.pos, typeName(r))
+		}
+		if n.op == "exists" && b {
+			return true, nil
+		}
+		if n.op == "all" && !b {
+			return false, nil
+		}
+	}
+	return n.op == "all", nil
+}
+
+func unary(n *node, v any) (any, error) {
+	switch n.op {
+	case "!":
+		if b, ok := v.(bool); ok {
+			return !b, nil
+		}
+	case "-":
+		switch v := v.(type) {
+		case int64:
+			if v == math.MinInt64 {
+				return nil, overflow(n)
+			}
+			return -v, nil
+		case time.Duration:
+			return -v, nil
+		}
+	}
+	return nil, noOverload(n, v)
+}
+
+func binary(n *node, l, r any) (any, error) {
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+
+	case "in":
+		list, ok := r.([]any)
+		if !ok {
+			return nil, noOverload(n, l, r)
+		}
+		for _, elem := range list {
+			if equal(l, elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "<", "<=", ">", ">=":
+		c, ok := compare(l, r)
+		if !ok {
+			return nil, noOverload(n, l, r)
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	}
+
+	switch l := l.(type) {
+	case int64:
+		r, ok := r.(int64)
+		if !ok {
+			break
+		}
+		return arithmetic(n, l, r)
+
+	case string:
+		if r, ok := r.(string); ok && n.op == "+" {
+			return l + r, nil
+		}
+
+	case []any:
+		if r, ok := r.([]any); ok && n.op == "+" {
+			return append(append(make([]any, 0, len(l)+len(r)), l...), r...), nil
+		}
+
+	case time.Time:
+		switch r := r.(type) {
+		case time.Duration:
+			if n.op == "+" {
+				return l.Add(r), nil
+			}
+			if n.op == "-" {
+				return l.Add(-r), nil
+			}
+		case time.Time:
+			if n.op == "-" {
+				return l.Sub(r), nil
+			}
+		}
+
+	case time.Duration:
+		switch r := r.(type) {
+		case time.Duration:
+			if n.op == "+" {
+				return l + r, nil
+			}
+			if n.op == "-" {
+				return l - r, nil
+			}
+		case time.Time:
+			if n.op == "+" {
+				return r.Add(l), nil
+			}
+		}
+	}
+	return nil, noOverload(n, l, r)
+}
+
+// arithmetic applies an integer operator, failing on overflow and division
+// by zero like CEL does.
+func arithmetic(n *node, l, r int64) (any, error) {
+	switch n.op {
+	case "+":
+		if (r > 0 && l > math.MaxInt64-r) || (r < 0 && l < math.MinInt64-r) {
+			return nil, overflow(n)
+		}
+		return l + r, nil
+	case "-":
+		if (r < 0 && l > math.MaxInt64+r) || (r > 0 && l < math.MinInt64+r) {
+			return nil, overflow(n)
+		}
+		return l - r, nil
+	case "*":
+		if l != 0 && (l*r/l != r || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64)) {
+			return nil, overflow(n)
+		}
+		return l * r, nil
+	case "/", "%":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero at offset %d", n.pos)
+		}
+		if l == math.MinInt64 && r == -1 {
+			return nil, overflow(n)
+		}
+		if n.op == "/" {
+			return l / r, nil
+		}
+		return l % r, nil
+	}
+	return nil, noOverload(n, l, r)
+}
+
+// equal reports whether two values are equal. Values of different types are
+// never equal.
+func equal(l, r any) bool {
+	switch l := l.(type) {
+	case []any:
+		r, ok := r.([]any)
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for i := range l {
+			if !equal(l[i], r[i]) {
+				return false
+			}
+		}
+		return true
+	case time.Time:
+		r, ok := r.(time.Time)
+		return ok && l.Equal(r)
+	default:
+		return l == r
+	}
+}
+
+// compare orders two values of the same ordered type.
+func compare(l, r any) (int, bool) {
+	switch l := l.(type) {
+	case int64:
+		if r, ok := r.(int64); ok {
+			return cmpOrdered(l, r), true
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	case time.Duration:
+		if r, ok := r.(time.Duration); ok {
+			return cmpOrdered(l, r), true
+		}
+	case time.Time:
+		if r, ok := r.(time.Time); ok {
+			return l.Compare(r), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | time.Duration](l, r T) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// call applies the function of a call node to the evaluated arguments. For
+// method calls args[0] is the receiver.
+func (p *Program) call(n *node, args []any) (any, error) {
+	fn := n.op
+	if n.receiver {
+		fn = "." + fn
+	}
+
+	switch fn {
+	case "size", ".size":
+		switch v := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(v)), nil
+		case []any:
+			return int64(len(v)), nil
+		}
+
+	case "timestamp":
+		if s, ok := args[0].(string); ok {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q at offset %d", s, n.pos)
+			}
+			return t, nil
+		}
+		if t, ok := args[0].(time.Time); ok {
+			return t, nil
+		}
+
+	case "duration":
+		if s, ok := args[0].(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration %q at offset %d", s, n.pos)
+			}
+			return d, nil
+		}
+		if d, ok := args[0].(time.Duration); ok {
+			return d, nil
+		}
+
+	case "string":
+		switch v := args[0].(type) {
+		case string:
+			return v, nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano), nil
+		case time.Duration:
+			return strconv.FormatFloat(v.Seconds(), 'f', -1, 64) + "s", nil
+		}
+
+	case "int":
+		switch v := args[0].(type) {
+		case int64:
+			return v, nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer %q at offset %d", v, n.pos)
+			}
+			return i, nil
+		case time.Time:
+			return v.Unix(), nil
+		}
+
+	case ".startsWith", ".endsWith", ".contains", ".matches":
+		s, ok := args[0].(string)
+		arg, aok := args[1].(string)
+		if !ok || !aok {
+			break
+		}
+		switch fn {
+		case ".startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case ".endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case ".contains":
+			return strings.Contains(s, arg), nil
+		default:
+			re, err := p.compiledRegexp(arg)
+			if err != nil {
+				return nil, fmt.Errorf("matches at offset %d: %w", n.pos, err)
+			}
+			return re.MatchString(s), nil
+		}
+
+	case ".lowerAscii", ".upperAscii":
+		if s, ok := args[0].(string); ok {
+			return mapASCII(s, fn == ".lowerAscii"), nil
+		}
+
+	case ".getHours", ".getMinutes", ".getDayOfWeek":
+		t, ok := args[0].(time.Time)
+		if !ok {
+			break
+		}
+		loc := time.UTC
+		if len(args) == 2 {
+			name, ok := args[1].(string)
+			if !ok {
+				break
+			}
+			var err error
+			if loc, err = location(name); err != nil {
+				return nil, fmt.Errorf("%s at offset %d: %w", n.op, n.pos, err)
+			}
+		}
+		t = t.In(loc)
+		switch fn {
+		case ".getHours":
+			return int64(t.Hour()), nil
+		case ".getMinutes":
+			return int64(t.Minute()), nil
+		default:
+			return int64(t.Weekday()), nil
+		}
+	}
+	return nil, noOverload(n, args...)
+}
+
+// location resolves an IANA time zone name or a fixed UTC offset such as
+// "+05:30", which are the time zones CEL accepts.
+func location(name string) (*time.Location, error) {
+	if name != "" && (name[0] == '+' || name[0] == '-') {
+		t, err := time.Parse("-07:00", name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone offset %q", name)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	return loc, nil
+}
+
+// mapASCII changes the case of ASCII letters only, like the CEL string
+// extensions.
+func mapASCII(s string, lower bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case lower && r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case !lower && r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		default:
+			return r
+		}
+	}, s)
+}
+
+func noOverload(n *node, args ...any) error {
+	types := make([]string, 0, len(args))
+	for _, a := range args {
+		types = append(types, typeName(a))
+	}
+	return fmt.Errorf("no such overload for %s(%s) at offset %d", n.op, strings.Join(types, ", "), n.pos)
+}
+
+func overflow(n *node) error {
+	return fmt.Errorf("integer overflow in %s at offset %d", n.op, n.pos)
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokString
+	tokOp
+)
+
+// token is a lexical token of an expression. pos is its byte offset.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	val  any
+}
+
+// operators are matched in order, so two character operators come first.
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", ".", "?", ":",
+}
+
+// lex splits the expression into tokens, ending with a tokEOF token.
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case (c == 'r' || c == 'R') && i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\''):
+			s, n, err := lexString(src[i+1:], true)
+			if err != nil {
+				return nil, fmt.Errorf("%w at offset %d", err, i)
+			}
+			toks = append(toks, token{kind: tokString, text: src[i : i+1+n], pos: i, val: s})
+			i += 1 + n
+
+		case isLetter(c):
+			j := i + 1
+			for j < len(src) && (isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+
+		case isDigit(c):
+			j := i + 1
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			n, err := strconv.ParseInt(src[i:j], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer %q at offset %d", src[i:j], i)
+			}
+			toks = append(toks, token{kind: tokInt, text: src[i:j], pos: i, val: n})
+			i = j
+
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:], false)
+			if err != nil {
+				return nil, fmt.Errorf("%w at offset %d", err, i)
+			}
+			toks = append(toks, token{kind: tokString, text: src[i : i+n], pos: i, val: s})
+			i += n
+
+		default:
+			var op string
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads the quoted string literal at the start of src and returns
+// its value and length. Escape sequences are not interpreted in raw strings.
+func lexString(src string, raw bool) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			return "", 0, errors.New("newline in string literal")
+		case c == '\\' && !raw:
+			i++
+			if i == len(src) {
+				return "", 0, errors.New("unterminated string literal")
+			}
+			switch src[i] {
+			case '\\', '"', '\'':
+				b.WriteByte(src[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence \\%c", src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated string literal")
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
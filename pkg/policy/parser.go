@@ -0,0 +1,300 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"slices"
+)
+
+type nodeKind int
+
+const (
+	nodeLiteral nodeKind = iota
+	nodeIdent
+	nodeUnary
+	nodeBinary
+	nodeCond
+	nodeList
+	nodeIndex
+	nodeCall
+)
+
+// node is a node of the syntax tree. op is the operator, identifier or
+// function name. For method calls args[0] is the receiver.
+type node struct {
+	kind     nodeKind
+	pos      int
+	op       string
+	val      any
+	args     []*node
+	receiver bool
+}
+
+// relations are the relational operators, which bind tighter than && and ||.
+var relations = []string{"==", "!=", "<", "<=", ">", ">=", "in"}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+// parse parses the expression following the CEL grammar:
+//
+//	Expr           = ConditionalOr ["?" ConditionalOr ":" Expr]
+//	ConditionalOr  = [ConditionalOr "||"] ConditionalAnd
+//	ConditionalAnd = [ConditionalAnd "&&"] Relation
+//	Relation       = [Relation Relop] Addition
+//	Addition       = [Addition ("+" | "-")] Multiplication
+//	Multiplication = [Multiplication ("*" | "/" | "%")] Unary
+//	Unary          = Member | "!" Unary | "-" Unary
+//	Member         = Primary | Member "." IDENT "(" [ExprList] ")" | Member "[" Expr "]"
+//	Primary        = IDENT ["(" [ExprList] ")"] | "(" Expr ")" | "[" [ExprList] "]" | LITERAL
+//
+// Field selection, maps, floats, bytes and null are not supported.
+func parse(src string) (*node, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at offset %d, got %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) expr() (*node, error) {
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	pos := p.peek().pos
+	if !p.accept("?") {
+		return cond, nil
+	}
+
+	then, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &node{kind: nodeCond, pos: pos, op: "?", args: []*node{cond, then, otherwise}}, nil
+}
+
+// binary parses a left associative chain of the given operators, with
+// operands parsed by operand.
+func (p *parser) binary(operand func() (*node, error), ops ...string) (*node, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		isOp := (t.kind == tokOp || (t.kind == tokIdent && t.text == "in")) && slices.Contains(ops, t.text)
+		if !isOp {
+			return l, nil
+		}
+		p.next()
+
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l = &node{kind: nodeBinary, pos: t.pos, op: t.text, args: []*node{l, r}}
+	}
+}
+
+func (p *parser) or() (*node, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *parser) and() (*node, error) {
+	return p.binary(p.relation, "&&")
+}
+
+func (p *parser) relation() (*node, error) {
+	return p.binary(p.addition, relations...)
+}
+
+func (p *parser) addition() (*node, error) {
+	return p.binary(p.multiplication, "+", "-")
+}
+
+func (p *parser) multiplication() (*node, error) {
+	return p.binary(p.unary, "*", "/", "%")
+}
+
+func (p *parser) unary() (*node, error) {
+	t := p.peek()
+	if p.accept("!") || p.accept("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nodeUnary, pos: t.pos, op: t.text, args: []*node{operand}}, nil
+	}
+	return p.member()
+}
+
+func (p *parser) member() (*node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, fmt.Errorf("expected method name at offset %d", name.pos)
+			}
+			if !p.accept("(") {
+				return nil, fmt.Errorf("field selection %q at offset %d is not supported", name.text, name.pos)
+			}
+			args, err := p.exprList(")")
+			if err != nil {
+				return nil, err
+			}
+			n = &node{kind: nodeCall, pos: name.pos, op: name.text, args: append([]*node{n}, args...), receiver: true}
+
+		case p.accept("["):
+			index, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &node{kind: nodeIndex, pos: t.pos, op: "[]", args: []*node{n, index}}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) primary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInt, tokString:
+		return &node{kind: nodeLiteral, pos: t.pos, val: t.val}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return &node{kind: nodeLiteral, pos: t.pos, val: t.text == "true"}, nil
+		case "in", "null":
+			return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+		}
+		if !p.accept("(") {
+			return &node{kind: nodeIdent, pos: t.pos, op: t.text}, nil
+		}
+		args, err := p.exprList(")")
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nodeCall, pos: t.pos, op: t.text, args: args}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			n, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			elems, err := p.exprList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &node{kind: nodeList, pos: t.pos, op: "[", args: elems}, nil
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+
+	default:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+}
+
+// exprList parses comma separated expressions up to and including the
+// closing operator.
+func (p *parser) exprList(closing string) ([]*node, error) {
+	if p.accept(closing) {
+		return nil, nil
+	}
+	var list []*node
+	for {
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+		if p.accept(closing) {
+			return list, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy evaluates dispatch policy expressions.
+//
+// Expressions are written in a subset of the Common Expression Language
+// (https://github.com/google/cel-spec), so policies stay valid CEL. The
+// supported types are bool, int, string, list, timestamp and duration, with
+// the operators ! - * / % + == != < <= > >= in && || ?: and list indexing.
+// The functions are:
+//
+//	size(string|list) int, also as a method
+//	timestamp(string) timestamp, from RFC 3339
+//	duration(string) duration, e.g. "90m"
+//	string(int|timestamp|duration) string
+//	int(string|timestamp) int, seconds since the epoch for timestamps
+//	string.startsWith(string) bool
+//	string.endsWith(string) bool
+//	string.contains(string) bool
+//	string.matches(string) bool, RE2 syntax
+//	string.lowerAscii() string
+//	string.upperAscii() string
+//	timestamp.getHours([string]) int, in UTC or the given time zone
+//	timestamp.getMinutes([string]) int
+//	timestamp.getDayOfWeek([string]) int, 0 for Sunday
+//	list.exists(x, predicate) bool
+//	list.all(x, predicate) bool
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	// Time zones are resolved from the embedded database, the webhook image
+	// does not ship one.
+	_ "time/tzdata"
+)
+
+// globalFunctions are the functions called without a receiver, by the number
+// of arguments they take.
+var globalFunctions = map[string]int{
+	"size":      1,
+	"timestamp": 1,
+	"duration":  1,
+	"string":    1,
+	"int":       1,
+}
+
+// methods are the functions called on a receiver, by the minimum and maximum
+// number of arguments they take besides the receiver.
+var methods = map[string][2]int{
+	"size":         {0, 0},
+	"startsWith":   {1, 1},
+	"endsWith":     {1, 1},
+	"contains":     {1, 1},
+	"matches":      {1, 1},
+	"lowerAscii":   {0, 0},
+	"upperAscii":   {0, 0},
+	"getHours":     {0, 1},
+	"getMinutes":   {0, 1},
+	"getDayOfWeek": {0, 1},
+}
+
+// macros are the methods whose first argument binds a variable in the
+// predicate that follows.
+var macros = []string{"exists", "all"}
+
+// Program is a compiled expression. It is safe for concurrent use.
+type Program struct {
+	src  string
+	root *node
+
+	mu      sync.Mutex
+	regexps map[string]*regexp.Regexp
+}
+
+// Compile parses the expression and checks that it only refers to the given
+// variables and the supported functions.
+func Compile(src string, vars ...string) (*Program, error) {
+	root, err := parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", src, err)
+	}
+	if err := check(root, vars); err != nil {
+		return nil, fmt.Errorf("failed to check %q: %w", src, err)
+	}
+	return &Program{
+		src:     src,
+		root:    root,
+		regexps: make(map[string]*regexp.Regexp),
+	}, nil
+}
+
+// String returns the source of the expression.
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval evaluates the expression. Variables may be bool, int, int64, string,
+// []string, []any, time.Time or time.Duration values.
+func (p *Program) Eval(vars map[string]any) (any, error) {
+	values := make(map[string]any, len(vars))
+	for name, v := range vars {
+		nv, err := normalize(v)
+		if err != nil {
+			return nil, fmt.Errorf("variable %q: %w", name, err)
+		}
+		values[name] = nv
+	}
+	return p.eval(p.root, &scope{vars: values})
+}
+
+// EvalBool evaluates an expression that must return a bool.
+func (p *Program) EvalBool(vars map[string]any) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %s, want bool", typeName(v))
+	}
+	return b, nil
+}
+
+// compiledRegexp returns the compiled pattern, compiling it on first use.
+func (p *Program) compiledRegexp(pattern string) (*regexp.Regexp, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if re, ok := p.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	p.regexps[pattern] = re
+	return re, nil
+}
+
+// check reports references to undeclared variables and unknown functions, and
+// calls with the wrong number of arguments.
+func check(n *node, vars []string) error {
+	switch n.kind {
+	case nodeIdent:
+		if !slices.Contains(vars, n.op) {
+			return fmt.Errorf("undeclared reference to %q at offset %d", n.op, n.pos)
+		}
+		return nil
+
+	case nodeCall:
+		if n.receiver && slices.Contains(macros, n.op) {
+			if len(n.args) != 3 || n.args[1].kind != nodeIdent {
+				return fmt.Errorf("%s at offset %d takes a variable name and a predicate", n.op, n.pos)
+			}
+			if err := check(n.args[0], vars); err != nil {
+				return err
+			}
+			return check(n.args[2], append(slices.Clip(vars), n.args[1].op))
+		}
+
+		switch {
+		case n.receiver:
+			arity, ok := methods[n.op]
+			if !ok {
+				return fmt.Errorf("unknown method %q at offset %d", n.op, n.pos)
+			}
+			if got := len(n.args) - 1; got < arity[0] || got > arity[1] {
+				return fmt.Errorf("%s at offset %d takes %s, got %d", n.op, n.pos, arguments(arity[0], arity[1]), got)
+			}
+		default:
+			arity, ok := globalFunctions[n.op]
+			if !ok {
+				return fmt.Errorf("unknown function %q at offset %d", n.op, n.pos)
+			}
+			if got := len(n.args); got != arity {
+				return fmt.Errorf("%s at offset %d takes %s, got %d", n.op, n.pos, arguments(arity, arity), got)
+			}
+		}
+	}
+
+	for _, arg := range n.args {
+		if err := check(arg, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func arguments(minArgs, maxArgs int) string {
+	switch {
+	case minArgs == maxArgs && minArgs == 1:
+		return "1 argument"
+	case minArgs == maxArgs:
+		return fmt.Sprintf("%d arguments", minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", minArgs, maxArgs)
+	}
+}
+
+// normalize converts a Go value to the value types of expressions.
+func normalize(v any) (any, error) {
+	switch v := v.(type) {
+	case bool, int64, string, time.Time, time.Duration:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case []string:
+		list := make([]any, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list, nil
+	case []any:
+		list := make([]any, len(v))
+		for i, e := range v {
+			ne, err := normalize(e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = ne
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+// typeName returns the expression type of a value for error messages.
+func typeName(v any) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case time.Time:
+		return "timestamp"
+	case time.Duration:
+		return "duration"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testVars = []string{"org", "repo", "labels", "actor", "branch", "created_at", "attempt"}
+
+func testValues() map[string]any {
+	return map[string]any{
+		"org":        "google",
+		"repo":       "sandbox-webhook",
+		"labels":     []string{"self-hosted", "gpu"},
+		"actor":      "octocat",
+		"branch":     "release/v1",
+		"created_at": time.Date(2025, 6, 7, 22, 30, 0, 0, time.UTC), // a Saturday
+		"attempt":    2,
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			expr: `org == "google" && labels.exists(l, l.startsWith("gpu"))`,
+		},
+		{
+			name:    "undeclared",
+			expr:    `owner == "google"`,
+			wantErr: `undeclared reference to "owner" at offset 0`,
+		},
+		{
+			name:    "macro_variable_out_of_scope",
+			expr:    `labels.exists(l, l == "gpu") && l == "gpu"`,
+			wantErr: `undeclared reference to "l"`,
+		},
+		{
+			name:    "unknown_function",
+			expr:    `lower(repo) == "x"`,
+			wantErr: `unknown function "lower"`,
+		},
+		{
+			name:    "unknown_method",
+			expr:    `repo.trim() == "x"`,
+			wantErr: `unknown method "trim"`,
+		},
+		{
+			name:    "arity",
+			expr:    `repo.startsWith()`,
+			wantErr: "startsWith at offset 5 takes 1 argument, got 0",
+		},
+		{
+			name:    "field_selection",
+			expr:    `event.repo == "x"`,
+			wantErr: `field selection "repo" at offset 6 is not supported`,
+		},
+		{
+			name:    "unterminated_string",
+			expr:    `repo == "x`,
+			wantErr: "unterminated string literal at offset 8",
+		},
+		{
+			name:    "trailing_tokens",
+			expr:    `repo == "x" "y"`,
+			wantErr: `unexpected "\"y\"" at offset 12`,
+		},
+		{
+			name:    "unbalanced",
+			expr:    `(repo == "x"`,
+			wantErr: `expected ")" at end of expression`,
+		},
+		{
+			name:    "invalid_escape",
+			expr:    `repo.matches("\d+")`,
+			wantErr: `invalid escape sequence \d`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Compile(tc.expr, testVars...)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		expr    string
+		want    any
+		wantErr string
+	}{
+		{name: "equal", expr: `org == "google"`, want: true},
+		{name: "not_equal_types", expr: `attempt == "2"`, want: false},
+		{name: "in", expr: `"gpu" in labels`, want: true},
+		{name: "not_in", expr: `!("arm64" in labels)`, want: true},
+		{name: "precedence", expr: `org == "other" || repo == "sandbox-webhook" && attempt > 1`, want: true},
+		{name: "short_circuit", expr: `org == "other" && labels[5] == "x"`, want: false},
+		{name: "conditional", expr: `attempt > 1 ? "retry" : "first"`, want: "retry"},
+		{name: "arithmetic", expr: `(attempt + 4) * 3 / 2 % 5 - -1`, want: int64(5)},
+		{name: "concat", expr: `org + "/" + repo`, want: "google/sandbox-webhook"},
+		{name: "list_concat", expr: `size(labels + ["x"])`, want: int64(3)},
+		{name: "index", expr: `labels[1]`, want: "gpu"},
+		{name: "starts_with", expr: `branch.startsWith("release/")`, want: true},
+		{name: "ends_with", expr: `repo.endsWith("webhook")`, want: true},
+		{name: "contains", expr: `repo.contains("box")`, want: true},
+		{name: "matches_raw", expr: `branch.matches(r"^release/v\d+$")`, want: true},
+		{name: "lower_ascii", expr: `"GPU".lowerAscii() in labels`, want: true},
+		{name: "size_method", expr: `repo.size()`, want: int64(15)},
+		{name: "exists", expr: `labels.exists(l, l.startsWith("gp"))`, want: true},
+		{name: "all", expr: `labels.all(l, l.contains("-"))`, want: false},
+		{name: "nested_macro", expr: `labels.exists(l, [1, 2].all(n, n > 0) && l == "gpu")`, want: true},
+		{name: "timestamp_compare", expr: `created_at > timestamp("2025-01-01T00:00:00Z")`, want: true},
+		{name: "timestamp_arithmetic", expr: `created_at + duration("2h") - created_at == duration("120m")`, want: true},
+		{name: "hours_utc", expr: `created_at.getHours()`, want: int64(22)},
+		{name: "hours_zone", expr: `created_at.getHours("Asia/Tokyo")`, want: int64(7)},
+		{name: "hours_offset", expr: `created_at.getHours("-05:00")`, want: int64(17)},
+		{name: "day_of_week", expr: `created_at.getDayOfWeek()`, want: int64(6)},
+		{name: "string_duration", expr: `string(duration("90m"))`, want: "5400s"},
+		{name: "int_timestamp", expr: `int(timestamp("1970-01-01T00:01:00Z"))`, want: int64(60)},
+		{
+			name:    "no_overload",
+			expr:    `attempt + "x"`,
+			wantErr: "no such overload for +(int, string) at offset 8",
+		},
+		{
+			name:    "division_by_zero",
+			expr:    `attempt / 0`,
+			wantErr: "division by zero",
+		},
+		{
+			name:    "overflow",
+			expr:    `9223372036854775807 + attempt`,
+			wantErr: "integer overflow",
+		},
+		{
+			name:    "index_out_of_range",
+			expr:    `labels[2]`,
+			wantErr: "index 2 out of range",
+		},
+		{
+			name:    "invalid_regexp",
+			expr:    `repo.matches("(")`,
+			wantErr: "invalid regular expression",
+		},
+		{
+			name:    "invalid_time_zone",
+			expr:    `created_at.getHours("Mars/Olympus")`,
+			wantErr: `invalid time zone "Mars/Olympus"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := Compile(tc.expr, testVars...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Eval(testValues())
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected result (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	t.Parallel()
+
+	p, err := Compile(`repo`, testVars...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.EvalBool(testValues())
+	if diff := testutil.DiffErrString(err, "expression returned string, want bool"); diff != "" {
+		t.Error(diff)
+	}
+}
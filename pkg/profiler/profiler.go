@@ -0,0 +1,252 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profiler continuously collects CPU and heap profiles of the process
+// and uploads them to Cloud Profiler. It implements the agent protocol: the
+// agent asks the API which profile to collect, which blocks until the API
+// decides a profile of this deployment is due, collects it and uploads it.
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/api/cloudprofiler/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Profile types the agent collects.
+const (
+	ProfileTypeCPU  = "CPU"
+	ProfileTypeHeap = "HEAP"
+)
+
+const (
+	// initialBackoff and maxBackoff bound the delay between failed requests
+	// the API did not give a retry delay for.
+	initialBackoff = time.Minute
+	maxBackoff     = time.Hour
+
+	// retryInfoType is the type of the error detail holding the retry delay.
+	retryInfoType = "type.googleapis.com/google.rpc.RetryInfo"
+)
+
+// targetRegexp matches the deployment targets Cloud Profiler accepts.
+var targetRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9_.]{0,253}[a-z0-9])?$`)
+
+// Config identifies the deployment the profiles belong to.
+type Config struct {
+	// ProjectID is the project the profiles are uploaded to.
+	ProjectID string
+
+	// Target is the name of the deployment, for example the service name.
+	Target string
+
+	// Version is the version of the deployment, profiles can be compared
+	// across versions.
+	Version string
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if cfg.ProjectID == "" {
+		return fmt.Errorf("project ID is required")
+	}
+	if !targetRegexp.MatchString(cfg.Target) {
+		return fmt.Errorf("target %q must be lowercase letters, numbers, hyphens, underscores and dots", cfg.Target)
+	}
+	return nil
+}
+
+// ProfilesClient adheres to the interaction the agent has with the Cloud
+// Profiler API.
+type ProfilesClient interface {
+	// CreateProfile blocks until a profile is due and returns it without data.
+	CreateProfile(ctx context.Context, parent string, req *cloudprofiler.CreateProfileRequest) (*cloudprofiler.Profile, error)
+
+	// UpdateProfile uploads the data of a profile returned by CreateProfile.
+	UpdateProfile(ctx context.Context, profile *cloudprofiler.Profile) error
+}
+
+// Agent uploads the profiles of the process.
+type Agent struct {
+	client ProfilesClient
+	cfg    *Config
+}
+
+// NewAgent creates an agent uploading profiles through the Cloud Profiler
+// API.
+func NewAgent(ctx context.Context, cfg *Config, opts ...option.ClientOption) (*Agent, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profiler config: %w", err)
+	}
+
+	svc, err := cloudprofiler.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud profiler client: %w", err)
+	}
+	return NewAgentWithClient(cfg, &apiClient{svc: svc}), nil
+}
+
+// NewAgentWithClient creates an agent uploading profiles through the client.
+func NewAgentWithClient(cfg *Config, client ProfilesClient) *Agent {
+	return &Agent{
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// Run collects and uploads profiles until the context is done. Failures are
+// logged and retried, profiling never stops the process.
+func (a *Agent) Run(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	backoff := initialBackoff
+	for ctx.Err() == nil {
+		err := a.profileOnce(ctx)
+		if err == nil {
+			backoff = initialBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delay, ok := retryDelay(err)
+		if !ok {
+			delay = backoff
+			backoff = min(2*backoff, maxBackoff)
+		}
+		logger.DebugContext(ctx, "cloud profiler request failed, retrying",
+			"error", err,
+			"delay", delay.String())
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// profileOnce waits for the API to ask for a profile, collects and uploads
+// it.
+func (a *Agent) profileOnce(ctx context.Context) error {
+	req := &cloudprofiler.CreateProfileRequest{
+		Deployment: &cloudprofiler.Deployment{
+			ProjectId: a.cfg.ProjectID,
+			Target:    a.cfg.Target,
+			Labels:    map[string]string{"version": a.cfg.Version},
+		},
+		ProfileType: []string{ProfileTypeCPU, ProfileTypeHeap},
+	}
+	profile, err := a.client.CreateProfile(ctx, "projects/"+a.cfg.ProjectID, req)
+	if err != nil {
+		return fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	b, err := collect(ctx, profile)
+	if err != nil {
+		return fmt.Errorf("failed to collect %s profile: %w", profile.ProfileType, err)
+	}
+	profile.ProfileBytes = base64.StdEncoding.EncodeToString(b)
+
+	if err := a.client.UpdateProfile(ctx, profile); err != nil {
+		return fmt.Errorf("failed to upload %s profile: %w", profile.ProfileType, err)
+	}
+	return nil
+}
+
+// collect returns the gzipped pprof profile the API asked for.
+func collect(ctx context.Context, profile *cloudprofiler.Profile) ([]byte, error) {
+	var buf bytes.Buffer
+	switch profile.ProfileType {
+	case ProfileTypeCPU:
+		d, err := time.ParseDuration(profile.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", profile.Duration, err)
+		}
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+		case <-t.C:
+		}
+		pprof.StopCPUProfile()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	case ProfileTypeHeap:
+		// The heap profile is as of the last garbage collection.
+		runtime.GC()
+		if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+			return nil, fmt.Errorf("failed to write heap profile: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported profile type %q", profile.ProfileType)
+	}
+	return buf.Bytes(), nil
+}
+
+// retryDelay returns the delay the API asked to wait before the next request.
+// The API answers CreateProfile with a retry delay when no profile of the
+// deployment is due.
+func retryDelay(err error) (time.Duration, bool) {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return 0, false
+	}
+	for _, detail := range gerr.Details {
+		m, ok := detail.(map[string]any)
+		if !ok || m["@type"] != retryInfoType {
+			continue
+		}
+		s, ok := m["retryDelay"].(string)
+		if !ok {
+			continue
+		}
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// apiClient is the ProfilesClient of the Cloud Profiler API.
+type apiClient struct {
+	svc *cloudprofiler.Service
+}
+
+func (c *apiClient) CreateProfile(ctx context.Context, parent string, req *cloudprofiler.CreateProfileRequest) (*cloudprofiler.Profile, error) {
+	return c.svc.Projects.Profiles.Create(parent, req).Context(ctx).Do()
+}
+
+func (c *apiClient) UpdateProfile(ctx context.Context, profile *cloudprofiler.Profile) error {
+	_, err := c.svc.Projects.Profiles.Patch(profile.Name, profile).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiler
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/cloudprofiler/v2"
+)
+
+// MockProfilesClient hands out Profiles, then Err, in order. Once both are
+// used up, CreateProfile calls Done and blocks until the context is done.
+type MockProfilesClient struct {
+	Profiles []*cloudprofiler.Profile
+	Err      error
+	Done     func()
+
+	mu       sync.Mutex
+	Requests []*cloudprofiler.CreateProfileRequest
+	Uploaded []*cloudprofiler.Profile
+}
+
+func (m *MockProfilesClient) CreateProfile(ctx context.Context, parent string, req *cloudprofiler.CreateProfileRequest) (*cloudprofiler.Profile, error) {
+	m.mu.Lock()
+	m.Requests = append(m.Requests, req)
+	if len(m.Profiles) > 0 {
+		p := m.Profiles[0]
+		m.Profiles = m.Profiles[1:]
+		m.mu.Unlock()
+		return p, nil
+	}
+	if err := m.Err; err != nil {
+		m.Err = nil
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.mu.Unlock()
+
+	if m.Done != nil {
+		m.Done()
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *MockProfilesClient) UpdateProfile(ctx context.Context, profile *cloudprofiler.Profile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Uploaded = append(m.Uploaded, profile)
+	return nil
+}
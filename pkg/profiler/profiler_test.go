@@ -0,0 +1,186 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiler
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/api/cloudprofiler/v2"
+	"google.golang.org/api/googleapi"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cfg:  &Config{ProjectID: "my-project", Target: "github_actions_on_gcp"},
+		},
+		{
+			name:    "missing_project",
+			cfg:     &Config{Target: "webhook"},
+			wantErr: "project ID is required",
+		},
+		{
+			name:    "invalid_target",
+			cfg:     &Config{ProjectID: "my-project", Target: "Webhook"},
+			wantErr: `target "Webhook" must be`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(tc.cfg.Validate(), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAgentRun(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	client := &MockProfilesClient{
+		Profiles: []*cloudprofiler.Profile{
+			{Name: "projects/my-project/profiles/heap", ProfileType: ProfileTypeHeap},
+			{Name: "projects/my-project/profiles/cpu", ProfileType: ProfileTypeCPU, Duration: "0.05s"},
+		},
+		// Retried after the delay the API asked for.
+		Err: &googleapi.Error{
+			Code: 409,
+			Details: []any{
+				map[string]any{"@type": retryInfoType, "retryDelay": "0.01s"},
+			},
+		},
+		Done: cancel,
+	}
+
+	agent := NewAgentWithClient(&Config{
+		ProjectID: "my-project",
+		Target:    "github_actions_on_gcp",
+		Version:   "v1.0.0",
+	}, client)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agent.Run(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected agent to stop once the context is done")
+	}
+
+	var got []string
+	for _, p := range client.Uploaded {
+		got = append(got, p.Name)
+
+		b, err := base64.StdEncoding.DecodeString(p.ProfileBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// pprof profiles are gzipped.
+		if len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+			t.Errorf("expected %s profile to be gzipped", p.ProfileType)
+		}
+	}
+	if diff := cmp.Diff([]string{"projects/my-project/profiles/heap", "projects/my-project/profiles/cpu"}, got); diff != "" {
+		t.Errorf("unexpected uploaded profiles (-want, +got):\n%s", diff)
+	}
+
+	if got, want := len(client.Requests), 4; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	wantReq := &cloudprofiler.CreateProfileRequest{
+		Deployment: &cloudprofiler.Deployment{
+			ProjectId: "my-project",
+			Target:    "github_actions_on_gcp",
+			Labels:    map[string]string{"version": "v1.0.0"},
+		},
+		ProfileType: []string{ProfileTypeCPU, ProfileTypeHeap},
+	}
+	if diff := cmp.Diff(wantReq, client.Requests[0]); diff != "" {
+		t.Errorf("unexpected create profile request (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{
+			name: "retry_info",
+			err: fmt.Errorf("failed to create profile: %w", &googleapi.Error{
+				Code: 409,
+				Details: []any{
+					map[string]any{"@type": "type.googleapis.com/google.rpc.ErrorInfo"},
+					map[string]any{"@type": retryInfoType, "retryDelay": "1482.5s"},
+				},
+			}),
+			want:   1482500 * time.Millisecond,
+			wantOK: true,
+		},
+		{
+			name: "no_retry_info",
+			err:  &googleapi.Error{Code: 503},
+		},
+		{
+			name: "invalid_delay",
+			err: &googleapi.Error{
+				Code:    409,
+				Details: []any{map[string]any{"@type": retryInfoType, "retryDelay": 5}},
+			},
+		},
+		{
+			name: "not_an_api_error",
+			err:  fmt.Errorf("connection reset"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := retryDelay(tc.err)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("expected (%s, %t) to be (%s, %t)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	cloudbuild "cloud.google.com/go/cloudbuild/apiv1/v2"
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	gcpiam "cloud.google.com/go/iam"
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	artifactregistry "google.golang.org/api/artifactregistry/v1"
+	cloudresourcemanager "google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/googleapi"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// iamAttempts bounds the attempts to update an IAM policy. Updates race
+	// with concurrent changes, and new service accounts take a few seconds to
+	// be usable as members.
+	iamAttempts = 6
+
+	// workerPoolMachineType is the machine type of created worker pools.
+	workerPoolMachineType = "e2-standard-4"
+)
+
+var _ Resources = (*GCPResources)(nil)
+
+// GCPResources creates resources with the Google Cloud APIs.
+type GCPResources struct {
+	ar  *artifactregistry.Service
+	cbc *cloudbuild.Client
+	crm *cloudresourcemanager.Service
+	iam *iam.Service
+	kmc *kms.KeyManagementClient
+
+	// pollInterval is the delay between checks of long-running operations.
+	pollInterval time.Duration
+}
+
+// NewGCPResources creates the API clients.
+func NewGCPResources(ctx context.Context, opts ...option.ClientOption) (*GCPResources, error) {
+	ar, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifact registry client: %w", err)
+	}
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource manager client: %w", err)
+	}
+	iamService, err := iam.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create iam client: %w", err)
+	}
+	kmc, err := kms.NewKeyManagementClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key management client: %w", err)
+	}
+	cbc, err := cloudbuild.NewClient(ctx, opts...)
+	if err != nil {
+		kmc.Close()
+		return nil, fmt.Errorf("failed to create cloud build client: %w", err)
+	}
+
+	return &GCPResources{
+		ar:           ar,
+		cbc:          cbc,
+		crm:          crm,
+		iam:          iamService,
+		kmc:          kmc,
+		pollInterval: 2 * time.Second,
+	}, nil
+}
+
+// Close releases the resources held by the gRPC clients.
+func (g *GCPResources) Close() error {
+	return errors.Join(g.kmc.Close(), g.cbc.Close())
+}
+
+// EnsureRepository creates a docker repository and waits for it to be ready.
+func (g *GCPResources) EnsureRepository(ctx context.Context, project, location, id string) error {
+	parent := fmt.Sprintf("projects/%s/locations/%s", project, location)
+	op, err := g.ar.Projects.Locations.Repositories.Create(parent, &artifactregistry.Repository{
+		Format:      "DOCKER",
+		Description: "GitHub Actions runner images",
+	}).RepositoryId(id).Context(ctx).Do()
+	if isHTTPCode(err, http.StatusConflict) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for repository: %w", ctx.Err())
+		case <-time.After(g.pollInterval):
+		}
+		if op, err = g.ar.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("failed to get repository operation: %w", err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("failed to create repository: %s", op.Error.Message)
+	}
+	return nil
+}
+
+// EnsureKeyRing creates a KMS key ring.
+func (g *GCPResources) EnsureKeyRing(ctx context.Context, project, location, id string) (string, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", project, location)
+	name := fmt.Sprintf("%s/keyRings/%s", parent, id)
+	if _, err := g.kmc.CreateKeyRing(ctx, &kmspb.CreateKeyRingRequest{
+		Parent:    parent,
+		KeyRingId: id,
+	}); err != nil && status.Code(err) != codes.AlreadyExists {
+		return "", fmt.Errorf("failed to create key ring: %w", err)
+	}
+	return name, nil
+}
+
+// EnsureSigningKey creates the signing key for the GitHub App private key.
+func (g *GCPResources) EnsureSigningKey(ctx context.Context, keyRing, id string) (string, error) {
+	name := fmt.Sprintf("%s/cryptoKeys/%s", keyRing, id)
+	if _, err := g.kmc.CreateCryptoKey(ctx, &kmspb.CreateCryptoKeyRequest{
+		Parent:      keyRing,
+		CryptoKeyId: id,
+		CryptoKey: &kmspb.CryptoKey{
+			Purpose: kmspb.CryptoKey_ASYMMETRIC_SIGN,
+			VersionTemplate: &kmspb.CryptoKeyVersionTemplate{
+				Algorithm: kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256,
+			},
+		},
+		// The version is created by importing the GitHub App private key.
+		SkipInitialVersionCreation: true,
+	}); err != nil && status.Code(err) != codes.AlreadyExists {
+		return "", fmt.Errorf("failed to create crypto key: %w", err)
+	}
+	return name, nil
+}
+
+// EnsureServiceAccount creates a service account.
+func (g *GCPResources) EnsureServiceAccount(ctx context.Context, project, id, displayName string) (string, error) {
+	email := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", id, project)
+	_, err := g.iam.Projects.ServiceAccounts.Create("projects/"+project, &iam.CreateServiceAccountRequest{
+		AccountId:      id,
+		ServiceAccount: &iam.ServiceAccount{DisplayName: displayName},
+	}).Context(ctx).Do()
+	if err != nil && !isHTTPCode(err, http.StatusConflict) {
+		return "", fmt.Errorf("failed to create service account: %w", err)
+	}
+	return email, nil
+}
+
+// EnsureProjectIAMMember adds the member to the role binding of the project.
+func (g *GCPResources) EnsureProjectIAMMember(ctx context.Context, project, role, member string) error {
+	return g.retryIAM(ctx, func() error {
+		// Version 3 keeps conditional bindings intact when the policy is set.
+		policy, err := g.crm.Projects.GetIamPolicy(project, &cloudresourcemanager.GetIamPolicyRequest{
+			Options: &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 3},
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get project iam policy: %w", err)
+		}
+		for _, b := range policy.Bindings {
+			if b.Role == role && b.Condition == nil {
+				if slices.Contains(b.Members, member) {
+					return nil
+				}
+				b.Members = append(b.Members, member)
+				return g.setProjectIAMPolicy(ctx, project, policy)
+			}
+		}
+		policy.Bindings = append(policy.Bindings, &cloudresourcemanager.Binding{Role: role, Members: []string{member}})
+		return g.setProjectIAMPolicy(ctx, project, policy)
+	})
+}
+
+func (g *GCPResources) setProjectIAMPolicy(ctx context.Context, project string, policy *cloudresourcemanager.Policy) error {
+	if _, err := g.crm.Projects.SetIamPolicy(project, &cloudresourcemanager.SetIamPolicyRequest{Policy: policy}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to set project iam policy: %w", err)
+	}
+	return nil
+}
+
+// EnsureServiceAccountIAMMember adds the member to the role binding of the
+// service account.
+func (g *GCPResources) EnsureServiceAccountIAMMember(ctx context.Context, email, role, member string) error {
+	resource := "projects/-/serviceAccounts/" + email
+	return g.retryIAM(ctx, func() error {
+		policy, err := g.iam.Projects.ServiceAccounts.GetIamPolicy(resource).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to get service account iam policy: %w", err)
+		}
+		for _, b := range policy.Bindings {
+			if b.Role == role && b.Condition == nil {
+				if slices.Contains(b.Members, member) {
+					return nil
+				}
+				b.Members = append(b.Members, member)
+				return g.setServiceAccountIAMPolicy(ctx, resource, policy)
+			}
+		}
+		policy.Bindings = append(policy.Bindings, &iam.Binding{Role: role, Members: []string{member}})
+		return g.setServiceAccountIAMPolicy(ctx, resource, policy)
+	})
+}
+
+func (g *GCPResources) setServiceAccountIAMPolicy(ctx context.Context, resource string, policy *iam.Policy) error {
+	if _, err := g.iam.Projects.ServiceAccounts.SetIamPolicy(resource, &iam.SetIamPolicyRequest{Policy: policy}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to set service account iam policy: %w", err)
+	}
+	return nil
+}
+
+// EnsureCryptoKeyIAMMember adds the member to the role binding of the key.
+func (g *GCPResources) EnsureCryptoKeyIAMMember(ctx context.Context, key, role, member string) error {
+	handle := g.kmc.ResourceIAM(key)
+	return g.retryIAM(ctx, func() error {
+		policy, err := handle.Policy(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get crypto key iam policy: %w", err)
+		}
+		if policy.HasRole(member, gcpiam.RoleName(role)) {
+			return nil
+		}
+		policy.Add(member, gcpiam.RoleName(role))
+		if err := handle.SetPolicy(ctx, policy); err != nil {
+			return fmt.Errorf("failed to set crypto key iam policy: %w", err)
+		}
+		return nil
+	})
+}
+
+// EnsureWorkerPool creates a private worker pool and waits for it to be
+// ready.
+func (g *GCPResources) EnsureWorkerPool(ctx context.Context, project, location, id string) (string, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", project, location)
+	name := fmt.Sprintf("%s/workerPools/%s", parent, id)
+
+	op, err := g.cbc.CreateWorkerPool(ctx, &cloudbuildpb.CreateWorkerPoolRequest{
+		Parent:       parent,
+		WorkerPoolId: id,
+		WorkerPool: &cloudbuildpb.WorkerPool{
+			Config: &cloudbuildpb.WorkerPool_PrivatePoolV1Config{
+				PrivatePoolV1Config: &cloudbuildpb.PrivatePoolV1Config{
+					WorkerConfig: &cloudbuildpb.PrivatePoolV1Config_WorkerConfig{
+						MachineType: workerPoolMachineType,
+					},
+				},
+			},
+		},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return name, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create worker pool: %w", err)
+	}
+	if _, err := op.Wait(ctx); err != nil {
+		return "", fmt.Errorf("failed to wait for worker pool: %w", err)
+	}
+	return name, nil
+}
+
+// retryIAM retries a read-modify-write of an IAM policy with backoff.
+func (g *GCPResources) retryIAM(ctx context.Context, fn func() error) error {
+	delay := g.pollInterval
+	var err error
+	for range iamAttempts {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !retryableIAMError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// retryableIAMError reports whether an IAM policy update failed because of
+// a concurrent change, or a member that does not exist yet.
+func retryableIAMError(err error) bool {
+	if isHTTPCode(err, http.StatusConflict) || isHTTPCode(err, http.StatusBadRequest) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Aborted, codes.InvalidArgument, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+func isHTTPCode(err error, code int) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == code
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MockResources records the resources it is asked to create. The call whose
+// record matches FailOn returns Err.
+type MockResources struct {
+	FailOn string
+	Err    error
+
+	mu    sync.Mutex
+	Calls []string
+}
+
+func (m *MockResources) record(call string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Calls = append(m.Calls, call)
+	if call == m.FailOn {
+		return m.Err
+	}
+	return nil
+}
+
+func (m *MockResources) EnsureRepository(ctx context.Context, project, location, id string) error {
+	return m.record(fmt.Sprintf("repository %s/%s/%s", project, location, id))
+}
+
+func (m *MockResources) EnsureKeyRing(ctx context.Context, project, location, id string) (string, error) {
+	name := fmt.Sprintf("projects/%s/locations/%s/keyRings/%s", project, location, id)
+	return name, m.record("key ring " + name)
+}
+
+func (m *MockResources) EnsureSigningKey(ctx context.Context, keyRing, id string) (string, error) {
+	name := fmt.Sprintf("%s/cryptoKeys/%s", keyRing, id)
+	return name, m.record("signing key " + name)
+}
+
+func (m *MockResources) EnsureServiceAccount(ctx context.Context, project, id, displayName string) (string, error) {
+	email := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", id, project)
+	return email, m.record("service account " + email)
+}
+
+func (m *MockResources) EnsureProjectIAMMember(ctx context.Context, project, role, member string) error {
+	return m.record(fmt.Sprintf("project %s %s %s", project, role, member))
+}
+
+func (m *MockResources) EnsureServiceAccountIAMMember(ctx context.Context, email, role, member string) error {
+	return m.record(fmt.Sprintf("service account %s %s %s", email, role, member))
+}
+
+func (m *MockResources) EnsureCryptoKeyIAMMember(ctx context.Context, key, role, member string) error {
+	return m.record(fmt.Sprintf("crypto key %s %s", role, member))
+}
+
+func (m *MockResources) EnsureWorkerPool(ctx context.Context, project, location, id string) (string, error) {
+	name := fmt.Sprintf("projects/%s/locations/%s/workerPools/%s", project, location, id)
+	return name, m.record("worker pool " + name)
+}
@@ -0,0 +1,276 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package setup provisions the Google Cloud resources the webhook and its
+// runners need, mirroring the terraform modules for first-time adopters.
+package setup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+)
+
+// nameRe matches valid names, the same constraint the terraform module has.
+var nameRe = regexp.MustCompile(`^[A-Za-z][0-9A-Za-z-]+[0-9A-Za-z]$`)
+
+// Config is the configuration of the resources to provision.
+type Config struct {
+	ProjectID       string
+	RunnerProjectID string
+	Name            string
+	Location        string
+	KMSLocation     string
+	RepositoryID    string
+	WorkerPool      bool
+	GitHubAppID     string
+}
+
+// Validate validates the setup config after load.
+func (cfg *Config) Validate() error {
+	if cfg.ProjectID == "" {
+		return fmt.Errorf("PROJECT_ID is required")
+	}
+	if cfg.Location == "" {
+		return fmt.Errorf("LOCATION is required")
+	}
+	if !nameRe.MatchString(cfg.Name) {
+		return fmt.Errorf("NAME can only contain letters, numbers and hyphens and must start with a letter, got %q", cfg.Name)
+	}
+	return nil
+}
+
+// ToFlags binds the config to the [cli.FlagSet] and returns it.
+func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
+	f := set.NewSection("SETUP OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:   "project-id",
+		Target: &cfg.ProjectID,
+		EnvVar: "PROJECT_ID",
+		Usage:  `The project the webhook runs in. It holds the webhook service account and the KMS key.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-project-id",
+		Target: &cfg.RunnerProjectID,
+		EnvVar: "RUNNER_PROJECT_ID",
+		Usage:  `The project the runner builds run in, defaults to the webhook project.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "name",
+		Target:  &cfg.Name,
+		EnvVar:  "NAME",
+		Default: "action-dispatcher",
+		Usage:   `The prefix of the names of the created resources.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "location",
+		Target: &cfg.Location,
+		EnvVar: "LOCATION",
+		Usage:  `The region of the runner image repository, builds and worker pool, e.g. "us-central1".`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "kms-location",
+		Target:  &cfg.KMSLocation,
+		EnvVar:  "KMS_LOCATION",
+		Default: "global",
+		Usage:   `The location of the KMS key ring holding the GitHub App private key.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "repository-id",
+		Target:  &cfg.RepositoryID,
+		EnvVar:  "REPOSITORY_ID",
+		Default: "runners",
+		Usage:   `The ID of the Artifact Registry repository holding the runner images.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "worker-pool",
+		Target: &cfg.WorkerPool,
+		EnvVar: "WORKER_POOL",
+		Usage:  `Whether to create a private Cloud Build worker pool for the runners.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "github-app-id",
+		Target: &cfg.GitHubAppID,
+		EnvVar: "GITHUB_APP_ID",
+		Usage:  `The ID of the GitHub App, added to the emitted webhook configuration.`,
+	})
+
+	return set
+}
+
+// Resources creates Google Cloud resources. All methods succeed if the
+// resource or binding already exists, so setup can be run again after a
+// partial failure.
+type Resources interface {
+	// EnsureRepository creates a docker repository.
+	EnsureRepository(ctx context.Context, project, location, id string) error
+
+	// EnsureKeyRing creates a KMS key ring and returns its resource name.
+	EnsureKeyRing(ctx context.Context, project, location, id string) (string, error)
+
+	// EnsureSigningKey creates an RSA 2048 PKCS#1 v1.5 SHA-256 signing key
+	// without versions, the GitHub App private key is imported into it. It
+	// returns the resource name of the key.
+	EnsureSigningKey(ctx context.Context, keyRing, id string) (string, error)
+
+	// EnsureServiceAccount creates a service account and returns its email.
+	EnsureServiceAccount(ctx context.Context, project, id, displayName string) (string, error)
+
+	// EnsureProjectIAMMember grants the role on the project to the member.
+	EnsureProjectIAMMember(ctx context.Context, project, role, member string) error
+
+	// EnsureServiceAccountIAMMember grants the role on the service account to
+	// the member.
+	EnsureServiceAccountIAMMember(ctx context.Context, email, role, member string) error
+
+	// EnsureCryptoKeyIAMMember grants the role on the KMS key to the member.
+	EnsureCryptoKeyIAMMember(ctx context.Context, key, role, member string) error
+
+	// EnsureWorkerPool creates a private worker pool and returns its resource
+	// name.
+	EnsureWorkerPool(ctx context.Context, project, location, id string) (string, error)
+}
+
+// Result describes the provisioned resources.
+type Result struct {
+	WebhookServiceAccount string
+	RunnerServiceAccount  string
+	CryptoKey             string
+	WorkerPool            string
+
+	// Env is the webhook configuration for the resources, by environment
+	// variable.
+	Env map[string]string
+}
+
+// Run provisions the resources. The steps are the same as the terraform
+// modules take, without the Cloud Run service and load balancer.
+func Run(ctx context.Context, cfg *Config, r Resources) (*Result, error) {
+	logger := logging.FromContext(ctx)
+
+	runnerProject := cfg.RunnerProjectID
+	if runnerProject == "" {
+		runnerProject = cfg.ProjectID
+	}
+
+	var res Result
+	var err error
+
+	logger.InfoContext(ctx, "creating service accounts")
+	if res.WebhookServiceAccount, err = r.EnsureServiceAccount(ctx, cfg.ProjectID,
+		cfg.Name+"-webhook-sa", cfg.Name+"-webhook-sa Cloud Run Service Account"); err != nil {
+		return nil, fmt.Errorf("failed to create webhook service account: %w", err)
+	}
+	if res.RunnerServiceAccount, err = r.EnsureServiceAccount(ctx, runnerProject,
+		cfg.Name+"-runner-sa", cfg.Name+"-runner-sa Cloud Build Service Account"); err != nil {
+		return nil, fmt.Errorf("failed to create runner service account: %w", err)
+	}
+	webhookMember := "serviceAccount:" + res.WebhookServiceAccount
+	runnerMember := "serviceAccount:" + res.RunnerServiceAccount
+
+	logger.InfoContext(ctx, "creating kms key")
+	keyRing, err := r.EnsureKeyRing(ctx, cfg.ProjectID, cfg.KMSLocation, cfg.Name+"-keyring")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key ring: %w", err)
+	}
+	if res.CryptoKey, err = r.EnsureSigningKey(ctx, keyRing, cfg.Name+"-github-app-key"); err != nil {
+		return nil, fmt.Errorf("failed to create signing key: %w", err)
+	}
+	for _, role := range []string{"roles/cloudkms.publicKeyViewer", "roles/cloudkms.signer"} {
+		if err := r.EnsureCryptoKeyIAMMember(ctx, res.CryptoKey, role, webhookMember); err != nil {
+			return nil, fmt.Errorf("failed to grant %s on signing key: %w", role, err)
+		}
+	}
+
+	logger.InfoContext(ctx, "creating runner image repository")
+	if err := r.EnsureRepository(ctx, runnerProject, cfg.Location, cfg.RepositoryID); err != nil {
+		return nil, fmt.Errorf("failed to create repository: %w", err)
+	}
+
+	logger.InfoContext(ctx, "granting runner permissions")
+	bindings := []struct{ role, member string }{
+		{"roles/logging.logWriter", runnerMember},
+		{"roles/cloudbuild.builds.editor", webhookMember},
+		{"roles/artifactregistry.reader", webhookMember},
+	}
+	for _, b := range bindings {
+		if err := r.EnsureProjectIAMMember(ctx, runnerProject, b.role, b.member); err != nil {
+			return nil, fmt.Errorf("failed to grant %s on project %s: %w", b.role, runnerProject, err)
+		}
+	}
+	if err := r.EnsureServiceAccountIAMMember(ctx, res.RunnerServiceAccount, "roles/iam.serviceAccountUser", webhookMember); err != nil {
+		return nil, fmt.Errorf("failed to grant roles/iam.serviceAccountUser on runner service account: %w", err)
+	}
+
+	if cfg.WorkerPool {
+		logger.InfoContext(ctx, "creating worker pool")
+		if res.WorkerPool, err = r.EnsureWorkerPool(ctx, runnerProject, cfg.Location, cfg.Name+"-pool"); err != nil {
+			return nil, fmt.Errorf("failed to create worker pool: %w", err)
+		}
+	}
+
+	res.Env = map[string]string{
+		"GITHUB_APP_ID":          cfg.GitHubAppID,
+		"KMS_APP_PRIVATE_KEY_ID": res.CryptoKey + "/cryptoKeyVersions/1",
+		"RUNNER_LOCATION":        cfg.Location,
+		"RUNNER_PROJECT_ID":      runnerProject,
+		"RUNNER_REPOSITORY_ID":   fmt.Sprintf("%s-docker.pkg.dev/%s/%s", cfg.Location, runnerProject, cfg.RepositoryID),
+		"RUNNER_SERVICE_ACCOUNT": res.RunnerServiceAccount,
+	}
+	if res.WorkerPool != "" {
+		res.Env["RUNNER_WORKER_POOL_ID"] = res.WorkerPool
+	}
+	return &res, nil
+}
+
+// WriteEnv writes the webhook configuration as an env file, sorted by name.
+// Values that are not known yet are written as comments.
+func (r *Result) WriteEnv(w io.Writer) error {
+	keys := make([]string, 0, len(r.Env))
+	for k := range r.Env {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString("# Import the GitHub App private key as version 1 of the KMS key, then\n")
+	b.WriteString("# deploy the webhook as the service account " + r.WebhookServiceAccount + "\n")
+	b.WriteString("# with WEBHOOK_KEY_MOUNT_PATH and WEBHOOK_KEY_NAME set as well.\n")
+	for _, k := range keys {
+		if r.Env[k] == "" {
+			fmt.Fprintf(&b, "# %s=\n", k)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, r.Env[k])
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	return nil
+}
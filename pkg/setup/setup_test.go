@@ -0,0 +1,172 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{
+			name: "valid",
+			cfg:  &Config{ProjectID: "p", Location: "us-central1", Name: "action-dispatcher"},
+		},
+		{
+			name:    "no_project",
+			cfg:     &Config{Location: "us-central1", Name: "action-dispatcher"},
+			wantErr: "PROJECT_ID is required",
+		},
+		{
+			name:    "no_location",
+			cfg:     &Config{ProjectID: "p", Name: "action-dispatcher"},
+			wantErr: "LOCATION is required",
+		},
+		{
+			name:    "invalid_name",
+			cfg:     &Config{ProjectID: "p", Location: "us-central1", Name: "1-dispatcher"},
+			wantErr: "NAME can only contain",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(tc.cfg.Validate(), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{
+		ProjectID:       "webhook-project",
+		RunnerProjectID: "runner-project",
+		Name:            "ad",
+		Location:        "us-central1",
+		KMSLocation:     "global",
+		RepositoryID:    "runners",
+		WorkerPool:      true,
+		GitHubAppID:     "123",
+	}
+
+	r := &MockResources{}
+	res, err := Run(t.Context(), cfg, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		key     = "projects/webhook-project/locations/global/keyRings/ad-keyring/cryptoKeys/ad-github-app-key"
+		webhook = "serviceAccount:ad-webhook-sa@webhook-project.iam.gserviceaccount.com"
+		runner  = "ad-runner-sa@runner-project.iam.gserviceaccount.com"
+	)
+	wantCalls := []string{
+		"service account ad-webhook-sa@webhook-project.iam.gserviceaccount.com",
+		"service account " + runner,
+		"key ring projects/webhook-project/locations/global/keyRings/ad-keyring",
+		"signing key " + key,
+		"crypto key roles/cloudkms.publicKeyViewer " + webhook,
+		"crypto key roles/cloudkms.signer " + webhook,
+		"repository runner-project/us-central1/runners",
+		"project runner-project roles/logging.logWriter serviceAccount:" + runner,
+		"project runner-project roles/cloudbuild.builds.editor " + webhook,
+		"project runner-project roles/artifactregistry.reader " + webhook,
+		"service account " + runner + " roles/iam.serviceAccountUser " + webhook,
+		"worker pool projects/runner-project/locations/us-central1/workerPools/ad-pool",
+	}
+	if diff := cmp.Diff(wantCalls, r.Calls); diff != "" {
+		t.Errorf("unexpected calls (-want, +got):\n%s", diff)
+	}
+
+	wantEnv := map[string]string{
+		"GITHUB_APP_ID":          "123",
+		"KMS_APP_PRIVATE_KEY_ID": key + "/cryptoKeyVersions/1",
+		"RUNNER_LOCATION":        "us-central1",
+		"RUNNER_PROJECT_ID":      "runner-project",
+		"RUNNER_REPOSITORY_ID":   "us-central1-docker.pkg.dev/runner-project/runners",
+		"RUNNER_SERVICE_ACCOUNT": runner,
+		"RUNNER_WORKER_POOL_ID":  "projects/runner-project/locations/us-central1/workerPools/ad-pool",
+	}
+	if diff := cmp.Diff(wantEnv, res.Env); diff != "" {
+		t.Errorf("unexpected env (-want, +got):\n%s", diff)
+	}
+}
+
+func TestRun_Error(t *testing.T) {
+	t.Parallel()
+
+	r := &MockResources{
+		FailOn: "repository p/us-central1/runners",
+		Err:    errors.New("permission denied"),
+	}
+	_, err := Run(t.Context(), &Config{
+		ProjectID:    "p",
+		Name:         "ad",
+		Location:     "us-central1",
+		KMSLocation:  "global",
+		RepositoryID: "runners",
+	}, r)
+	if diff := testutil.DiffErrString(err, "failed to create repository: permission denied"); diff != "" {
+		t.Fatal(diff)
+	}
+	if got, want := r.Calls[len(r.Calls)-1], r.FailOn; got != want {
+		t.Errorf("expected last call %q to be %q", got, want)
+	}
+}
+
+func TestResultWriteEnv(t *testing.T) {
+	t.Parallel()
+
+	res := &Result{
+		WebhookServiceAccount: "ad-webhook-sa@p.iam.gserviceaccount.com",
+		Env: map[string]string{
+			"RUNNER_PROJECT_ID": "p",
+			"GITHUB_APP_ID":     "",
+			"RUNNER_LOCATION":   "us-central1",
+		},
+	}
+
+	var b strings.Builder
+	if err := res.WriteEnv(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Import the GitHub App private key as version 1 of the KMS key, then
+# deploy the webhook as the service account ad-webhook-sa@p.iam.gserviceaccount.com
+# with WEBHOOK_KEY_MOUNT_PATH and WEBHOOK_KEY_NAME set as well.
+# GITHUB_APP_ID=
+RUNNER_LOCATION=us-central1
+RUNNER_PROJECT_ID=p
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("unexpected env file (-want, +got):\n%s", diff)
+	}
+}
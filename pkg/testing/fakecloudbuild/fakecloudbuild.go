@@ -0,0 +1,403 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakecloudbuild is an in-memory Cloud Build gRPC server for tests.
+// It captures requests, keeps created builds so they can be listed, fetched
+// and cancelled, and can be scripted to fail calls.
+package fakecloudbuild
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Method names accepted by FailNext and Requests.
+const (
+	MethodCreateBuild   = "CreateBuild"
+	MethodGetBuild      = "GetBuild"
+	MethodListBuilds    = "ListBuilds"
+	MethodCancelBuild   = "CancelBuild"
+	MethodGetWorkerPool = "GetWorkerPool"
+)
+
+// Server is a fake implementation of the Cloud Build API.
+type Server struct {
+	cloudbuildpb.UnimplementedCloudBuildServer
+
+	mu          sync.Mutex
+	nextID      int
+	builds      []*cloudbuildpb.Build
+	workerPools map[string]*cloudbuildpb.WorkerPool
+	requests    map[string][]proto.Message
+	failures    map[string][]error
+}
+
+// NewServer creates an empty fake.
+func NewServer() *Server {
+	return &Server{
+		workerPools: make(map[string]*cloudbuildpb.WorkerPool),
+		requests:    make(map[string][]proto.Message),
+		failures:    make(map[string][]error),
+	}
+}
+
+// Start starts serving the fake on a local port until the test finishes. It
+// returns the client options to pass to cloudbuild.NewClient.
+func Start(tb testing.TB) (*Server, []option.ClientOption) {
+	tb.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("failed to listen: %v", err)
+	}
+
+	s := NewServer()
+	grpcServer := grpc.NewServer()
+	cloudbuildpb.RegisterCloudBuildServer(grpcServer, s)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	tb.Cleanup(grpcServer.Stop)
+
+	return s, []option.ClientOption{
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// FailNext makes the next call of method return err. Failures queue up, so
+// calling FailNext twice fails the next two calls. Use status errors to
+// control the gRPC code, e.g. status.Error(codes.ResourceExhausted, "quota").
+func (s *Server) FailNext(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], err)
+}
+
+// Requests returns the requests received by method, oldest first.
+func (s *Server) Requests(method string) []proto.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests[method])
+}
+
+// CreateBuildRequests returns the CreateBuild requests received, oldest first.
+func (s *Server) CreateBuildRequests() []*cloudbuildpb.CreateBuildRequest {
+	reqs := s.Requests(MethodCreateBuild)
+	out := make([]*cloudbuildpb.CreateBuildRequest, 0, len(reqs))
+	for _, req := range reqs {
+		out = append(out, req.(*cloudbuildpb.CreateBuildRequest))
+	}
+	return out
+}
+
+// Builds returns copies of all builds, oldest first.
+func (s *Server) Builds() []*cloudbuildpb.Build {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]*cloudbuildpb.Build, 0, len(s.builds))
+	for _, b := range s.builds {
+		out = append(out, proto.Clone(b).(*cloudbuildpb.Build))
+	}
+	return out
+}
+
+// AddBuild adds a build, for example one created before the code under test
+// started. A missing ID is generated. Builds without a name are listed in
+// every project and location.
+func (s *Server) AddBuild(build *cloudbuildpb.Build) *cloudbuildpb.Build {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := proto.Clone(build).(*cloudbuildpb.Build)
+	if b.GetId() == "" {
+		b.Id = s.newIDLocked()
+	}
+	s.builds = append(s.builds, b)
+	return proto.Clone(b).(*cloudbuildpb.Build)
+}
+
+// SetBuildStatus changes the status of a build, simulating its progress.
+func (s *Server) SetBuildStatus(id string, st cloudbuildpb.Build_Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.findLocked(id)
+	if b == nil {
+		return fmt.Errorf("build %q not found", id)
+	}
+	b.Status = st
+	if isTerminal(st) && b.GetFinishTime() == nil {
+		b.FinishTime = timestamppb.Now()
+	}
+	return nil
+}
+
+// AddWorkerPool adds a worker pool returned by GetWorkerPool.
+func (s *Server) AddWorkerPool(pool *cloudbuildpb.WorkerPool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workerPools[pool.GetName()] = proto.Clone(pool).(*cloudbuildpb.WorkerPool)
+}
+
+// CreateBuild stores the build as QUEUED and returns an operation carrying it
+// in its metadata. The operation is not done, like the real API.
+func (s *Server) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest) (*longrunningpb.Operation, error) {
+	if err := s.record(MethodCreateBuild, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	b := proto.Clone(req.GetBuild()).(*cloudbuildpb.Build)
+	b.Id = s.newIDLocked()
+	b.ProjectId = req.GetProjectId()
+	b.Status = cloudbuildpb.Build_QUEUED
+	b.CreateTime = timestamppb.Now()
+	if req.GetParent() != "" {
+		b.Name = req.GetParent() + "/builds/" + b.GetId()
+	}
+	s.builds = append(s.builds, b)
+	created := proto.Clone(b).(*cloudbuildpb.Build)
+	s.mu.Unlock()
+
+	md, err := anypb.New(&cloudbuildpb.BuildOperationMetadata{Build: created})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal metadata: %v", err)
+	}
+	return &longrunningpb.Operation{
+		Name:     "operations/build/" + created.GetProjectId() + "/" + created.GetId(),
+		Metadata: md,
+	}, nil
+}
+
+// GetBuild returns a build by ID.
+func (s *Server) GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest) (*cloudbuildpb.Build, error) {
+	if err := s.record(MethodGetBuild, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.findLocked(req.GetId())
+	if b == nil {
+		return nil, status.Errorf(codes.NotFound, "build %q not found", req.GetId())
+	}
+	return proto.Clone(b).(*cloudbuildpb.Build), nil
+}
+
+// ListBuilds returns the builds of the parent newest first, like the real
+// API. The filter supports terms of the form status="WORKING",
+// tags="my-tag" and create_time<"2025-01-01T00:00:00Z" joined by AND.
+func (s *Server) ListBuilds(ctx context.Context, req *cloudbuildpb.ListBuildsRequest) (*cloudbuildpb.ListBuildsResponse, error) {
+	if err := s.record(MethodListBuilds, req); err != nil {
+		return nil, err
+	}
+
+	match, err := parseFilter(req.GetFilter())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	offset := 0
+	if req.GetPageToken() != "" {
+		offset, err = strconv.Atoi(req.GetPageToken())
+		if err != nil || offset < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q", req.GetPageToken())
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*cloudbuildpb.Build
+	for i := len(s.builds) - 1; i >= 0; i-- {
+		if !inParent(s.builds[i], req.GetParent()) {
+			continue
+		}
+		if match(s.builds[i]) {
+			matched = append(matched, s.builds[i])
+		}
+	}
+
+	resp := &cloudbuildpb.ListBuildsResponse{}
+	if offset >= len(matched) {
+		return resp, nil
+	}
+	end := len(matched)
+	if size := int(req.GetPageSize()); size > 0 && offset+size < end {
+		end = offset + size
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	for _, b := range matched[offset:end] {
+		resp.Builds = append(resp.Builds, proto.Clone(b).(*cloudbuildpb.Build))
+	}
+	return resp, nil
+}
+
+// inParent reports whether the build belongs to the parent, of the form
+// projects/<project>/locations/<location>. Builds without a name, and listings
+// without a parent, match.
+func inParent(b *cloudbuildpb.Build, parent string) bool {
+	return parent == "" || b.GetName() == "" || strings.HasPrefix(b.GetName(), parent+"/builds/")
+}
+
+// CancelBuild marks a build as CANCELLED. Finished builds cannot be cancelled.
+func (s *Server) CancelBuild(ctx context.Context, req *cloudbuildpb.CancelBuildRequest) (*cloudbuildpb.Build, error) {
+	if err := s.record(MethodCancelBuild, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.findLocked(req.GetId())
+	if b == nil {
+		return nil, status.Errorf(codes.NotFound, "build %q not found", req.GetId())
+	}
+	if isTerminal(b.GetStatus()) {
+		return nil, status.Errorf(codes.FailedPrecondition, "build %q is already %s", req.GetId(), b.GetStatus())
+	}
+	b.Status = cloudbuildpb.Build_CANCELLED
+	b.FinishTime = timestamppb.Now()
+	return proto.Clone(b).(*cloudbuildpb.Build), nil
+}
+
+// GetWorkerPool returns a worker pool added with AddWorkerPool.
+func (s *Server) GetWorkerPool(ctx context.Context, req *cloudbuildpb.GetWorkerPoolRequest) (*cloudbuildpb.WorkerPool, error) {
+	if err := s.record(MethodGetWorkerPool, req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pool, ok := s.workerPools[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "worker pool %q not found", req.GetName())
+	}
+	return proto.Clone(pool).(*cloudbuildpb.WorkerPool), nil
+}
+
+// record captures the request and returns the next scripted failure of the
+// method, if any.
+func (s *Server) record(method string, req proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[method] = append(s.requests[method], proto.Clone(req))
+	if failures := s.failures[method]; len(failures) > 0 {
+		s.failures[method] = failures[1:]
+		return failures[0]
+	}
+	return nil
+}
+
+func (s *Server) newIDLocked() string {
+	s.nextID++
+	return fmt.Sprintf("build-%d", s.nextID)
+}
+
+func (s *Server) findLocked(id string) *cloudbuildpb.Build {
+	for _, b := range s.builds {
+		if b.GetId() == id {
+			return b
+		}
+	}
+	return nil
+}
+
+func isTerminal(st cloudbuildpb.Build_Status) bool {
+	switch st {
+	case cloudbuildpb.Build_STATUS_UNKNOWN, cloudbuildpb.Build_PENDING, cloudbuildpb.Build_QUEUED, cloudbuildpb.Build_WORKING:
+		return false
+	default:
+		return true
+	}
+}
+
+// parseFilter returns a predicate for the supported subset of the ListBuilds
+// filter syntax.
+func parseFilter(filter string) (func(*cloudbuildpb.Build) bool, error) {
+	var preds []func(*cloudbuildpb.Build) bool
+	for _, term := range strings.Split(filter, " AND ") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		if v, ok := strings.CutPrefix(term, "create_time<"); ok {
+			before, err := time.Parse(time.RFC3339, strings.Trim(v, `"`))
+			if err != nil {
+				return nil, fmt.Errorf("invalid create time in filter term %q: %w", term, err)
+			}
+			preds = append(preds, func(b *cloudbuildpb.Build) bool {
+				return b.GetCreateTime().AsTime().Before(before)
+			})
+			continue
+		}
+
+		key, value, ok := strings.Cut(term, "=")
+		value = strings.Trim(value, `"`)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("unsupported filter term %q", term)
+		}
+
+		switch key {
+		case "status":
+			st, ok := cloudbuildpb.Build_Status_value[value]
+			if !ok {
+				return nil, fmt.Errorf("unknown build status %q", value)
+			}
+			preds = append(preds, func(b *cloudbuildpb.Build) bool {
+				return b.GetStatus() == cloudbuildpb.Build_Status(st)
+			})
+		case "tags":
+			preds = append(preds, func(b *cloudbuildpb.Build) bool {
+				return slices.Contains(b.GetTags(), value)
+			})
+		default:
+			return nil, fmt.Errorf("unsupported filter key %q", key)
+		}
+	}
+
+	return func(b *cloudbuildpb.Build) bool {
+		for _, p := range preds {
+			if !p(b) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecloudbuild
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	cloudbuild "cloud.google.com/go/cloudbuild/apiv1/v2"
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	fake, opts := Start(t)
+
+	client, err := cloudbuild.NewClient(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	create := func(tag string) *cloudbuildpb.Build {
+		t.Helper()
+
+		op, err := client.CreateBuild(ctx, &cloudbuildpb.CreateBuildRequest{
+			Parent:    "projects/p/locations/us-central1",
+			ProjectId: "p",
+			Build:     &cloudbuildpb.Build{Tags: []string{tag}},
+		})
+		if err != nil {
+			t.Fatalf("failed to create build: %v", err)
+		}
+		md, err := op.Metadata()
+		if err != nil {
+			t.Fatalf("failed to read metadata: %v", err)
+		}
+		return md.GetBuild()
+	}
+
+	first := create("a")
+	second := create("b")
+	create("a")
+
+	if got, want := first.GetStatus(), cloudbuildpb.Build_QUEUED; got != want {
+		t.Errorf("expected status %s to be %s", got, want)
+	}
+	if got, want := len(fake.CreateBuildRequests()), 3; got != want {
+		t.Errorf("expected %d create requests, got %d", want, got)
+	}
+
+	if err := fake.SetBuildStatus(second.GetId(), cloudbuildpb.Build_WORKING); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetBuild(ctx, &cloudbuildpb.GetBuildRequest{ProjectId: "p", Id: second.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.GetStatus(), cloudbuildpb.Build_WORKING; got != want {
+		t.Errorf("expected status %s to be %s", got, want)
+	}
+
+	var listed []string
+	it := client.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{ProjectId: "p", Filter: `tags="a"`, PageSize: 1})
+	for {
+		b, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed = append(listed, b.GetId())
+	}
+	if diff := cmp.Diff([]string{"build-3", "build-1"}, listed); diff != "" {
+		t.Errorf("unexpected listed builds (-want, +got):\n%s", diff)
+	}
+
+	if _, err := client.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{
+		ProjectId: "p",
+		Filter:    fmt.Sprintf(`create_time<%q`, time.Now().Add(-time.Hour).Format(time.RFC3339)),
+	}).Next(); err != iterator.Done {
+		t.Errorf("expected no builds created an hour ago, got %v", err)
+	}
+
+	other, err := client.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{
+		Parent:    "projects/other/locations/us-central1",
+		ProjectId: "other",
+	}).Next()
+	if err != iterator.Done {
+		t.Errorf("expected no builds in another project, got %v, %v", other, err)
+	}
+
+	cancelled, err := client.CancelBuild(ctx, &cloudbuildpb.CancelBuildRequest{ProjectId: "p", Id: first.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cancelled.GetStatus(), cloudbuildpb.Build_CANCELLED; got != want {
+		t.Errorf("expected status %s to be %s", got, want)
+	}
+	if _, err := client.CancelBuild(ctx, &cloudbuildpb.CancelBuildRequest{ProjectId: "p", Id: first.GetId()}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected cancelling a finished build to fail with FailedPrecondition, got %v", err)
+	}
+}
+
+func TestServer_FailNext(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	fake, opts := Start(t)
+
+	client, err := cloudbuild.NewClient(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	fake.FailNext(MethodCreateBuild, status.Error(codes.PermissionDenied, "quota exceeded"))
+
+	req := &cloudbuildpb.CreateBuildRequest{ProjectId: "p", Build: &cloudbuildpb.Build{}}
+	if _, err := client.CreateBuild(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected scripted PermissionDenied, got %v", err)
+	}
+	if _, err := client.CreateBuild(ctx, req); err != nil {
+		t.Errorf("expected only the next call to fail, got %v", err)
+	}
+
+	if got, want := len(fake.CreateBuildRequests()), 2; got != want {
+		t.Errorf("expected failed requests to be captured, got %d requests, want %d", got, want)
+	}
+	if got, want := len(fake.Builds()), 1; got != want {
+		t.Errorf("expected %d builds, got %d", want, got)
+	}
+}
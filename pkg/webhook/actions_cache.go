@@ -0,0 +1,588 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+)
+
+// The actions cache proxy implements the REST protocol of the actions/cache
+// service (version 1) the @actions/cache toolkit speaks to ACTIONS_CACHE_URL:
+//
+//   - GET cache?keys=&version= looks up an entry, 204 on a miss.
+//   - POST caches reserves an upload.
+//   - PATCH caches/{id} uploads a chunk with a Content-Range header.
+//   - POST caches/{id} commits the upload.
+//
+// Entries are stored in a Cloud Storage bucket under the repository of the
+// runner, which is scoped by a token embedded in its ACTIONS_CACHE_URL, and
+// downloaded directly from Cloud Storage through signed URLs.
+const (
+	// actionsCacheTokenTTL is how long a runner can use the cache, the maximum
+	// Cloud Build build timeout.
+	actionsCacheTokenTTL = 24 * time.Hour
+
+	// actionsCacheURLTTL is how long the signed download URLs of entries are
+	// valid. Downloads only need to start before.
+	actionsCacheURLTTL = time.Hour
+
+	// maxActionsCacheKeyLength and maxActionsCacheKeys mirror the limits of the
+	// toolkit.
+	maxActionsCacheKeyLength = 512
+	maxActionsCacheKeys      = 10
+
+	// maxActionsCacheChunkSize bounds uploaded chunks, the toolkit uploads
+	// 32 MiB chunks by default.
+	maxActionsCacheChunkSize = 128 << 20
+
+	// maxActionsCacheSize is the largest entry GitHub accepts.
+	maxActionsCacheSize = 10 << 30
+
+	// maxComposeSources is the number of objects one compose request can
+	// concatenate.
+	maxComposeSources = 32
+
+	// maxActionsCacheReservationSize bounds the size of reservation objects.
+	maxActionsCacheReservationSize = 4096
+)
+
+// actionsCacheVersionRegexp matches the versions the toolkit computes, the
+// SHA-256 of the cache paths and compression method.
+var actionsCacheVersionRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// actionsCacheContentRangeRegexp matches the Content-Range of chunk uploads.
+var actionsCacheContentRangeRegexp = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\*|\d+)$`)
+
+// actionsCacheClaims is the scope of an actions cache token: the repository
+// whose caches the runner it was issued to may read and write.
+type actionsCacheClaims struct {
+	Scope     string `json:"scope"`
+	Runner    string `json:"runner"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// actionsCacheTokenKey derives the key of the actions cache tokens from the
+// webhook secret, separate from the key of the artifact tokens.
+func actionsCacheTokenKey(webhookSecret []byte) []byte {
+	mac := hmac.New(sha256.New, webhookSecret)
+	mac.Write([]byte("actions-cache"))
+	return mac.Sum(nil)
+}
+
+// issueActionsCacheToken returns a token scoping the cache to the repository
+// of the runner's job.
+func (s *Server) issueActionsCacheToken(req *runnerRequest, now time.Time) string {
+	return signToken(s.actionsCacheKey, &actionsCacheClaims{
+		Scope:     req.Org + "/" + req.Repo,
+		Runner:    req.RunnerName,
+		ExpiresAt: now.Add(actionsCacheTokenTTL).Unix(),
+	})
+}
+
+// verifyActionsCacheToken returns the claims of a token issued by this service
+// that has not expired.
+func (s *Server) verifyActionsCacheToken(token string, now time.Time) (*actionsCacheClaims, error) {
+	var claims actionsCacheClaims
+	if err := openToken(s.actionsCacheKey, token, &claims); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("token expired")
+	}
+	return &claims, nil
+}
+
+// addActionsCacheURL points the runner at the cache proxy of its repository.
+// The URL embeds the token since the toolkit authenticates with the runtime
+// token of the job.
+func (s *Server) addActionsCacheURL(build *cloudbuildpb.Build, req *runnerRequest, now time.Time) {
+	if s.actionsCacheBucket == "" {
+		return
+	}
+
+	build.Substitutions["_ACTIONS_CACHE_URL"] = fmt.Sprintf("%s/actions-cache/%s/",
+		s.actionsCacheEndpoint, s.issueActionsCacheToken(req, now))
+}
+
+// actionsCacheEntryObject is the object of an entry. Keys are escaped byte by
+// byte, so the escaped prefix of a key is the prefix of the escaped key.
+func actionsCacheEntryObject(scope, version, key string) string {
+	return scope + "/entries/" + version + "/" + url.PathEscape(key)
+}
+
+// actionsCacheUploadPrefix is the folder of the reservation and chunks of an
+// upload.
+func actionsCacheUploadPrefix(scope string, id int64) string {
+	return fmt.Sprintf("%s/uploads/%d/", scope, id)
+}
+
+// validateActionsCacheKey checks a key like the toolkit does.
+func validateActionsCacheKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if len(key) > maxActionsCacheKeyLength {
+		return fmt.Errorf("key must be at most %d bytes", maxActionsCacheKeyLength)
+	}
+	if strings.Contains(key, ",") {
+		return fmt.Errorf("key %q must not contain commas", key)
+	}
+	return nil
+}
+
+// validateActionsCacheVersion checks the version of an entry.
+func validateActionsCacheVersion(version string) error {
+	if !actionsCacheVersionRegexp.MatchString(version) {
+		return fmt.Errorf("version %q is invalid", version)
+	}
+	return nil
+}
+
+// actionsCacheRoutes serves the actions cache protocol under the token of the
+// runner.
+func (s *Server) actionsCacheRoutes() http.Handler {
+	const prefix = "/actions-cache/{token}/_apis/artifactcache"
+
+	mux := http.NewServeMux()
+	mux.Handle("GET "+prefix+"/cache", s.requireActionsCacheToken(s.handleActionsCacheLookup))
+	mux.Handle("POST "+prefix+"/caches", s.requireActionsCacheToken(s.handleActionsCacheReserve))
+	mux.Handle("PATCH "+prefix+"/caches/{id}", s.requireActionsCacheToken(s.handleActionsCacheUpload))
+	mux.Handle("POST "+prefix+"/caches/{id}", s.requireActionsCacheToken(s.handleActionsCacheCommit))
+	return mux
+}
+
+// requireActionsCacheToken rejects requests without a valid token in their
+// path.
+func (s *Server) requireActionsCacheToken(next func(http.ResponseWriter, *http.Request, *actionsCacheClaims)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := s.verifyActionsCacheToken(r.PathValue("token"), time.Now())
+		if err != nil {
+			s.h.RenderJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": fmt.Sprintf("missing or invalid actions cache token: %s", err),
+			})
+			return
+		}
+		next(w, r, claims)
+	})
+}
+
+// actionsCacheEntry is the response of a cache hit.
+type actionsCacheEntry struct {
+	CacheKey        string    `json:"cacheKey"`
+	Scope           string    `json:"scope"`
+	CreationTime    time.Time `json:"creationTime"`
+	ArchiveLocation string    `json:"archiveLocation"`
+}
+
+// handleActionsCacheLookup responds with a signed download URL of the entry
+// matching the keys, or 204 on a miss.
+func (s *Server) handleActionsCacheLookup(w http.ResponseWriter, r *http.Request, claims *actionsCacheClaims) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+	now := time.Now()
+
+	keys := strings.Split(r.URL.Query().Get("keys"), ",")
+	version := r.URL.Query().Get("version")
+	if len(keys) > maxActionsCacheKeys {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("at most %d keys are allowed", maxActionsCacheKeys),
+		})
+		return
+	}
+	for _, key := range keys {
+		if err := validateActionsCacheKey(key); err != nil {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	if err := validateActionsCacheVersion(version); err != nil {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	entry, err := s.findActionsCacheEntry(ctx, claims.Scope, keys, version)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up actions cache entry",
+			"runner_id", claims.Runner,
+			"scope", claims.Scope,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to look up cache entry",
+		})
+		return
+	}
+	if entry == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	signed, err := s.signedURL(ctx, http.MethodGet, s.actionsCacheBucket, entry.Name, s.actionsCacheServiceAccount, actionsCacheURLTTL, now)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to sign actions cache download URL",
+			"runner_id", claims.Runner,
+			"object", entry.Name,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to sign download URL",
+		})
+		return
+	}
+
+	s.h.RenderJSON(w, http.StatusOK, &actionsCacheEntry{
+		CacheKey:        entry.Metadata["cache-key"],
+		Scope:           claims.Scope,
+		CreationTime:    entry.Updated.UTC(),
+		ArchiveLocation: signed,
+	})
+}
+
+// findActionsCacheEntry returns the entry of the version matching the keys,
+// or nil. Like GitHub, the first key only matches exactly, the restore keys
+// that follow match exactly or else the most recent entry they prefix.
+func (s *Server) findActionsCacheEntry(ctx context.Context, scope string, keys []string, version string) (*CacheObject, error) {
+	for i, key := range keys {
+		object := actionsCacheEntryObject(scope, version, key)
+		objects, err := s.actionsCacheStore.ListObjects(ctx, s.actionsCacheBucket, object)
+		if err != nil {
+			return nil, err
+		}
+
+		var newest *CacheObject
+		for _, obj := range objects {
+			if obj.Name == object {
+				return obj, nil
+			}
+			if i > 0 && (newest == nil || obj.Updated.After(newest.Updated)) {
+				newest = obj
+			}
+		}
+		if newest != nil {
+			return newest, nil
+		}
+	}
+	return nil, nil
+}
+
+// actionsCacheReservation is the body of a reserve request, and is stored
+// in the folder of the upload until it is committed.
+type actionsCacheReservation struct {
+	Key       string `json:"key"`
+	Version   string `json:"version"`
+	CacheSize int64  `json:"cacheSize"`
+}
+
+// handleActionsCacheReserve reserves an upload for a new entry.
+func (s *Server) handleActionsCacheReserve(w http.ResponseWriter, r *http.Request, claims *actionsCacheClaims) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	var req actionsCacheReservation
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxActionsCacheReservationSize)).Decode(&req); err != nil {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("failed to decode request: %s", err),
+		})
+		return
+	}
+	if err := errors.Join(validateActionsCacheKey(req.Key), validateActionsCacheVersion(req.Version)); err != nil {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if req.CacheSize < 0 || req.CacheSize > maxActionsCacheSize {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("cache size must be at most %d bytes", maxActionsCacheSize),
+		})
+		return
+	}
+
+	entry, err := s.findActionsCacheEntry(ctx, claims.Scope, []string{req.Key}, req.Version)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to look up actions cache entry",
+			"runner_id", claims.Runner,
+			"scope", claims.Scope,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to reserve cache",
+		})
+		return
+	}
+	if entry != nil {
+		s.h.RenderJSON(w, http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("cache entry %q already exists", req.Key),
+		})
+		return
+	}
+
+	// Identifiers are numbers the toolkit parses in JavaScript, below 2^53.
+	id := rand.Int64N(1<<53-1) + 1
+	b, _ := json.Marshal(&req)
+	object := actionsCacheUploadPrefix(claims.Scope, id) + "reservation.json"
+	if err := s.actionsCacheStore.WriteObject(ctx, s.actionsCacheBucket, object, bytes.NewReader(b), nil); err != nil {
+		logger.ErrorContext(ctx, "failed to write actions cache reservation",
+			"runner_id", claims.Runner,
+			"object", object,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to reserve cache",
+		})
+		return
+	}
+
+	logger.InfoContext(ctx, "reserved actions cache upload",
+		"runner_id", claims.Runner,
+		"scope", claims.Scope,
+		"cache_key", req.Key,
+		"cache_id", id)
+	s.h.RenderJSON(w, http.StatusCreated, map[string]int64{
+		"cacheId": id,
+	})
+}
+
+// actionsCacheUpload returns the reservation of the upload in the path, or
+// renders the error.
+func (s *Server) actionsCacheUpload(w http.ResponseWriter, r *http.Request, claims *actionsCacheClaims) (int64, *actionsCacheReservation, bool) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid cache id %q", r.PathValue("id")),
+		})
+		return 0, nil, false
+	}
+
+	object := actionsCacheUploadPrefix(claims.Scope, id) + "reservation.json"
+	b, err := s.actionsCacheStore.ReadObject(ctx, s.actionsCacheBucket, object, maxActionsCacheReservationSize)
+	if errors.Is(err, errObjectNotFound) {
+		s.h.RenderJSON(w, http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("cache %d is not reserved", id),
+		})
+		return 0, nil, false
+	}
+	var reservation actionsCacheReservation
+	if err == nil {
+		err = json.Unmarshal(b, &reservation)
+	}
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "failed to read actions cache reservation",
+			"runner_id", claims.Runner,
+			"object", object,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to read cache reservation",
+		})
+		return 0, nil, false
+	}
+	return id, &reservation, true
+}
+
+// handleActionsCacheUpload stores a chunk of a reserved upload. Chunks are
+// named by their offset so the commit concatenates them in order.
+func (s *Server) handleActionsCacheUpload(w http.ResponseWriter, r *http.Request, claims *actionsCacheClaims) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	m := actionsCacheContentRangeRegexp.FindStringSubmatch(r.Header.Get("Content-Range"))
+	var start, end int64
+	if m != nil {
+		start, _ = strconv.ParseInt(m[1], 10, 64)
+		end, _ = strconv.ParseInt(m[2], 10, 64)
+	}
+	if m == nil || end < start || end-start+1 > maxActionsCacheChunkSize || end >= maxActionsCacheSize {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid content range %q", r.Header.Get("Content-Range")),
+		})
+		return
+	}
+
+	id, _, ok := s.actionsCacheUpload(w, r, claims)
+	if !ok {
+		return
+	}
+
+	// Reading a byte past the range detects chunks larger than it.
+	size := end - start + 1
+	body := &countingReader{r: io.LimitReader(r.Body, size+1)}
+	object := fmt.Sprintf("%schunks/%020d", actionsCacheUploadPrefix(claims.Scope, id), start)
+	if err := s.actionsCacheStore.WriteObject(ctx, s.actionsCacheBucket, object, body, nil); err != nil {
+		logger.ErrorContext(ctx, "failed to write actions cache chunk",
+			"runner_id", claims.Runner,
+			"object", object,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to upload chunk",
+		})
+		return
+	}
+	if body.n != size {
+		if err := s.actionsCacheStore.DeleteObject(ctx, s.actionsCacheBucket, object); err != nil {
+			logger.ErrorContext(ctx, "failed to delete truncated actions cache chunk",
+				"object", object,
+				"error", err)
+		}
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("chunk is %d bytes, content range is %d bytes", body.n, size),
+		})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleActionsCacheCommit concatenates the chunks of a reserved upload into
+// its entry.
+func (s *Server) handleActionsCacheCommit(w http.ResponseWriter, r *http.Request, claims *actionsCacheClaims) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	var req struct {
+		Size int64 `json:"size"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxActionsCacheReservationSize)).Decode(&req); err != nil {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("failed to decode request: %s", err),
+		})
+		return
+	}
+
+	id, reservation, ok := s.actionsCacheUpload(w, r, claims)
+	if !ok {
+		return
+	}
+
+	prefix := actionsCacheUploadPrefix(claims.Scope, id)
+	chunks, err := s.actionsCacheStore.ListObjects(ctx, s.actionsCacheBucket, prefix+"chunks/")
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list actions cache chunks",
+			"runner_id", claims.Runner,
+			"prefix", prefix,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit cache",
+		})
+		return
+	}
+
+	// Chunks are listed by name, in the order of their zero padded offsets,
+	// and must be contiguous.
+	sources := make([]string, 0, len(chunks))
+	var size int64
+	for _, chunk := range chunks {
+		if chunk.Name != fmt.Sprintf("%schunks/%020d", prefix, size) {
+			break
+		}
+		sources = append(sources, chunk.Name)
+		size += chunk.Size
+	}
+	if len(sources) == 0 || len(sources) != len(chunks) || size != req.Size {
+		s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("uploaded chunks do not add up to %d bytes", req.Size),
+		})
+		return
+	}
+
+	object := actionsCacheEntryObject(claims.Scope, reservation.Version, reservation.Key)
+	if err := s.composeActionsCache(ctx, prefix, sources, object, map[string]string{
+		"cache-key":     reservation.Key,
+		"cache-version": reservation.Version,
+	}); err != nil {
+		logger.ErrorContext(ctx, "failed to compose actions cache entry",
+			"runner_id", claims.Runner,
+			"object", object,
+			"error", err)
+		s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": "failed to commit cache",
+		})
+		return
+	}
+	s.deleteActionsCacheUpload(ctx, prefix)
+
+	logger.InfoContext(ctx, "committed actions cache entry",
+		"runner_id", claims.Runner,
+		"scope", claims.Scope,
+		"cache_key", reservation.Key,
+		"cache_id", id,
+		"size", size)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// composeActionsCache concatenates the sources into the object, through
+// intermediate objects in the upload folder when there are too many for a
+// single compose request.
+func (s *Server) composeActionsCache(ctx context.Context, prefix string, sources []string, object string, metadata map[string]string) error {
+	for i := 0; len(sources) > maxComposeSources; i++ {
+		intermediate := fmt.Sprintf("%scompose-%d", prefix, i)
+		if err := s.actionsCacheStore.ComposeObjects(ctx, s.actionsCacheBucket, intermediate, sources[:maxComposeSources], nil); err != nil {
+			return err
+		}
+		sources = append([]string{intermediate}, sources[maxComposeSources:]...)
+	}
+	return s.actionsCacheStore.ComposeObjects(ctx, s.actionsCacheBucket, object, sources, metadata)
+}
+
+// deleteActionsCacheUpload deletes the folder of a committed upload. Leftovers
+// of failed deletions and abandoned uploads are left to the lifecycle rules of
+// the bucket.
+func (s *Server) deleteActionsCacheUpload(ctx context.Context, prefix string) {
+	logger := logging.FromContext(ctx)
+
+	objects, err := s.actionsCacheStore.ListObjects(ctx, s.actionsCacheBucket, prefix)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to list actions cache upload",
+			"prefix", prefix,
+			"error", err)
+		return
+	}
+	for _, obj := range objects {
+		if err := s.actionsCacheStore.DeleteObject(ctx, s.actionsCacheBucket, obj.Name); err != nil {
+			logger.WarnContext(ctx, "failed to delete actions cache upload object",
+				"object", obj.Name,
+				"error", err)
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
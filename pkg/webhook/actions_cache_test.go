@@ -0,0 +1,376 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/renderer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func testActionsCacheServer(ctx context.Context, t *testing.T, store *MockActionsCacheStore) *Server {
+	t.Helper()
+
+	return &Server{
+		actionsCacheBucket:         "actions-cache",
+		actionsCacheEndpoint:       "https://webhook.example.com",
+		actionsCacheKey:            actionsCacheTokenKey([]byte("webhook-secret")),
+		actionsCacheServiceAccount: "signer@my-project.iam.gserviceaccount.com",
+		actionsCacheStore:          store,
+		blobSigner:                 &MockBlobSigner{Sig: []byte{0xca, 0xfe}},
+		h:                          renderer.NewTesting(ctx, t, nil),
+	}
+}
+
+func TestAddActionsCacheURL(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := testActionsCacheServer(ctx, t, &MockActionsCacheStore{})
+
+	build := &cloudbuildpb.Build{Substitutions: map[string]string{"_ACTIONS_CACHE_URL": ""}}
+	s.addActionsCacheURL(build, &runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, now)
+
+	got := build.Substitutions["_ACTIONS_CACHE_URL"]
+	token, ok := strings.CutPrefix(got, "https://webhook.example.com/actions-cache/")
+	if !ok || !strings.HasSuffix(token, "/") {
+		t.Fatalf("expected %q to be the actions cache URL of the service", got)
+	}
+	claims, err := s.verifyActionsCacheToken(strings.TrimSuffix(token, "/"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := claims.Scope, "google/webhook"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Artifact tokens are not actions cache tokens.
+	artifacts := &Server{artifactsKey: artifactTokenKey([]byte("webhook-secret"))}
+	if _, err := s.verifyActionsCacheToken(artifacts.issueArtifactToken(&runnerRequest{RunnerName: "GCP-2"}, now), now); err == nil {
+		t.Error("expected artifact token to be rejected")
+	}
+
+	disabled := &Server{}
+	build = &cloudbuildpb.Build{Substitutions: map[string]string{"_ACTIONS_CACHE_URL": ""}}
+	disabled.addActionsCacheURL(build, &runnerRequest{}, now)
+	if got := build.Substitutions["_ACTIONS_CACHE_URL"]; got != "" {
+		t.Errorf("expected %q to be empty", got)
+	}
+}
+
+func TestFindActionsCacheEntry(t *testing.T) {
+	t.Parallel()
+
+	// Entries are written in order, the last is the most recent.
+	entries := []struct{ scope, version, key string }{
+		{"google/webhook", "v1", "npm-linux-abc"},
+		{"google/webhook", "v1", "npm-linux-def"},
+		{"google/webhook", "v1", "npm-linux-abc/extra"},
+		{"google/webhook", "v2", "npm-linux-xyz"},
+		{"google/other", "v1", "npm-linux-zzz"},
+	}
+
+	cases := []struct {
+		name    string
+		keys    []string
+		version string
+		want    string
+	}{
+		{
+			name:    "exact",
+			keys:    []string{"npm-linux-abc"},
+			version: "v1",
+			want:    "google/webhook/entries/v1/npm-linux-abc",
+		},
+		{
+			name:    "primary_key_not_prefix_matched",
+			keys:    []string{"npm-linux"},
+			version: "v1",
+		},
+		{
+			name:    "restore_key_most_recent",
+			keys:    []string{"npm-linux-ghi", "npm-linux-"},
+			version: "v1",
+			want:    "google/webhook/entries/v1/npm-linux-abc%2Fextra",
+		},
+		{
+			name:    "restore_key_exact",
+			keys:    []string{"npm-linux-ghi", "npm-linux-def", "npm-"},
+			version: "v1",
+			want:    "google/webhook/entries/v1/npm-linux-def",
+		},
+		{
+			name:    "other_version",
+			keys:    []string{"npm-linux-ghi", "npm-linux-"},
+			version: "v2",
+			want:    "google/webhook/entries/v2/npm-linux-xyz",
+		},
+		{
+			name:    "other_scope",
+			keys:    []string{"npm-linux-zzz", "npm-linux-z"},
+			version: "v1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			store := &MockActionsCacheStore{}
+			for _, e := range entries {
+				if err := store.WriteObject(ctx, "actions-cache", actionsCacheEntryObject(e.scope, e.version, e.key),
+					strings.NewReader("archive"), map[string]string{"cache-key": e.key}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := testActionsCacheServer(ctx, t, store)
+
+			entry, err := s.findActionsCacheEntry(ctx, "google/webhook", tc.keys, tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if entry != nil {
+				got = entry.Name
+			}
+			if got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestActionsCacheRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		chunks int
+	}{
+		{
+			name:   "single_chunk",
+			chunks: 1,
+		},
+		{
+			name:   "intermediate_compositions",
+			chunks: 70,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			store := &MockActionsCacheStore{}
+			s := testActionsCacheServer(ctx, t, store)
+			routes := s.Routes(ctx)
+			base := "/actions-cache/" + s.issueActionsCacheToken(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, time.Now()) + "/_apis/artifactcache/"
+
+			do := func(method, path, contentRange, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, base+path, strings.NewReader(body))
+				if contentRange != "" {
+					req.Header.Set("Content-Range", contentRange)
+				}
+				resp := httptest.NewRecorder()
+				routes.ServeHTTP(resp, req)
+				return resp
+			}
+
+			if resp := do(http.MethodGet, "cache?keys=go-linux-abc,go-linux-&version=v1", "", ""); resp.Code != http.StatusNoContent {
+				t.Fatalf("expected %d to be %d: %s", resp.Code, http.StatusNoContent, resp.Body.String())
+			}
+
+			var archive strings.Builder
+			for i := range tc.chunks {
+				fmt.Fprintf(&archive, "chunk-%03d;", i)
+			}
+			resp := do(http.MethodPost, "caches", "", fmt.Sprintf(`{"key":"go-linux-abc","version":"v1","cacheSize":%d}`, archive.Len()))
+			if resp.Code != http.StatusCreated {
+				t.Fatalf("expected %d to be %d: %s", resp.Code, http.StatusCreated, resp.Body.String())
+			}
+			var reserved struct {
+				CacheID int64 `json:"cacheId"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &reserved); err != nil {
+				t.Fatal(err)
+			}
+
+			// Upload the chunks in reverse, the commit orders them by offset.
+			const chunkSize = len("chunk-000;")
+			for i := tc.chunks - 1; i >= 0; i-- {
+				start := i * chunkSize
+				chunk := archive.String()[start : start+chunkSize]
+				resp := do(http.MethodPatch, fmt.Sprintf("caches/%d", reserved.CacheID), fmt.Sprintf("bytes %d-%d/*", start, start+chunkSize-1), chunk)
+				if resp.Code != http.StatusNoContent {
+					t.Fatalf("expected %d to be %d: %s", resp.Code, http.StatusNoContent, resp.Body.String())
+				}
+			}
+
+			resp = do(http.MethodPost, fmt.Sprintf("caches/%d", reserved.CacheID), "", fmt.Sprintf(`{"size":%d}`, archive.Len()))
+			if resp.Code != http.StatusNoContent {
+				t.Fatalf("expected %d to be %d: %s", resp.Code, http.StatusNoContent, resp.Body.String())
+			}
+
+			// Only the entry is left once the upload is committed.
+			if diff := cmp.Diff([]string{"google/webhook/entries/v1/go-linux-abc"}, store.Names()); diff != "" {
+				t.Errorf("objects (-want, +got):\n%s", diff)
+			}
+			got, _ := store.Object("google/webhook/entries/v1/go-linux-abc")
+			if got, want := string(got), archive.String(); got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			resp = do(http.MethodGet, "cache?keys=go-linux-def,go-linux-&version=v1", "", "")
+			if resp.Code != http.StatusOK {
+				t.Fatalf("expected %d to be %d: %s", resp.Code, http.StatusOK, resp.Body.String())
+			}
+			var entry actionsCacheEntry
+			if err := json.Unmarshal(resp.Body.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := entry.CacheKey, "go-linux-abc"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if want := "https://storage.googleapis.com/actions-cache/google/webhook/entries/v1/go-linux-abc?"; !strings.HasPrefix(entry.ArchiveLocation, want) {
+				t.Errorf("expected %q to start with %q", entry.ArchiveLocation, want)
+			}
+
+			if resp := do(http.MethodPost, "caches", "", `{"key":"go-linux-abc","version":"v1","cacheSize":1}`); resp.Code != http.StatusConflict {
+				t.Errorf("expected %d to be %d: %s", resp.Code, http.StatusConflict, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleActionsCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		token        string
+		method       string
+		path         string
+		contentRange string
+		body         string
+		wantCode     int
+	}{
+		{
+			name:     "invalid_token",
+			token:    "not-a-token",
+			method:   http.MethodGet,
+			path:     "cache?keys=go&version=v1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid_version",
+			method:   http.MethodGet,
+			path:     "cache?keys=go&version=../v1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "empty_key",
+			method:   http.MethodPost,
+			path:     "caches",
+			body:     `{"key":"","version":"v1","cacheSize":1}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid_content_range",
+			method:       http.MethodPatch,
+			path:         "caches/1",
+			contentRange: "bytes 10-0/*",
+			body:         "chunk",
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:         "not_reserved",
+			method:       http.MethodPatch,
+			path:         "caches/2",
+			contentRange: "bytes 0-4/*",
+			body:         "chunk",
+			wantCode:     http.StatusNotFound,
+		},
+		{
+			name:         "chunk_larger_than_range",
+			method:       http.MethodPatch,
+			path:         "caches/1",
+			contentRange: "bytes 5-7/*",
+			body:         "chunk",
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			name:     "missing_chunks",
+			method:   http.MethodPost,
+			path:     "caches/1",
+			body:     `{"size":10}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid_id",
+			method:   http.MethodPost,
+			path:     "caches/abc",
+			body:     `{"size":5}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			// Upload 1 is reserved with its first chunk uploaded.
+			store := &MockActionsCacheStore{}
+			for object, data := range map[string]string{
+				"google/webhook/uploads/1/reservation.json":            `{"key":"go","version":"v1","cacheSize":10}`,
+				"google/webhook/uploads/1/chunks/00000000000000000000": "chunk",
+			} {
+				if err := store.WriteObject(ctx, "actions-cache", object, strings.NewReader(data), nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := testActionsCacheServer(ctx, t, store)
+
+			token := tc.token
+			if token == "" {
+				token = s.issueActionsCacheToken(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, time.Now())
+			}
+			req := httptest.NewRequest(tc.method, "/actions-cache/"+token+"/_apis/artifactcache/"+tc.path, strings.NewReader(tc.body))
+			if tc.contentRange != "" {
+				req.Header.Set("Content-Range", tc.contentRange)
+			}
+			resp := httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Errorf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+		})
+	}
+}
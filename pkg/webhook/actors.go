@@ -0,0 +1,103 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reasons a runner request is rejected for its actor, used as the reason label
+// of the actor rejection metric.
+const (
+	actorRejectBlocked = "blocked"
+	actorRejectLimit   = "limit"
+)
+
+// Responses to runner requests rejected for their actor.
+var (
+	runnerActorBlockedMsg = "no action taken for runner requested by a blocked actor"
+	runnerActorLimitMsg   = "no action taken for runner over the actor's runner limit"
+)
+
+// actorLimits caps the runners a single GitHub actor, the sender of the event
+// requesting them, may have at once and blocks some actors outright. Logins
+// are compared case-insensitively. A nil actorLimits allows everything.
+type actorLimits struct {
+	blocked    map[string]struct{}
+	maxRunners int
+	overrides  map[string]int
+}
+
+// newActorLimits returns the limits for the blocked actors, the default
+// maximum number of runners per actor and the per-actor maximums, given as
+// login=count. A maximum of 0 is unlimited. It returns nil if nothing is
+// limited.
+func newActorLimits(blocked []string, maxRunners int, overrides map[string]string) (*actorLimits, error) {
+	if maxRunners < 0 {
+		return nil, fmt.Errorf("maximum runners per actor must not be negative, got %d", maxRunners)
+	}
+
+	l := &actorLimits{
+		blocked:    make(map[string]struct{}, len(blocked)),
+		maxRunners: maxRunners,
+		overrides:  make(map[string]int, len(overrides)),
+	}
+	for _, actor := range blocked {
+		if actor = strings.TrimSpace(actor); actor != "" {
+			l.blocked[strings.ToLower(actor)] = struct{}{}
+		}
+	}
+	for actor, v := range overrides {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("maximum runners for actor %q is not a number: %w", actor, err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("maximum runners for actor %q must not be negative, got %d", actor, n)
+		}
+		l.overrides[strings.ToLower(strings.TrimSpace(actor))] = n
+	}
+
+	if len(l.blocked) == 0 && l.maxRunners == 0 && len(l.overrides) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// max returns the maximum number of runners of the actor, 0 if unlimited.
+func (l *actorLimits) max(actor string) int {
+	if n, ok := l.overrides[strings.ToLower(actor)]; ok {
+		return n
+	}
+	return l.maxRunners
+}
+
+// check returns the reason a new runner requested by the actor is rejected,
+// given the number of runners the actor already has, or "" if it is allowed.
+// Requests without an actor are allowed.
+func (l *actorLimits) check(actor string, running int) string {
+	if l == nil || actor == "" {
+		return ""
+	}
+	if _, ok := l.blocked[strings.ToLower(actor)]; ok {
+		return actorRejectBlocked
+	}
+	if n := l.max(actor); n > 0 && running >= n {
+		return actorRejectLimit
+	}
+	return ""
+}
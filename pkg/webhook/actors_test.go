@@ -0,0 +1,145 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestActorLimitsCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		blocked    []string
+		maxRunners int
+		overrides  map[string]string
+		actor      string
+		running    int
+		want       string
+		wantErr    string
+	}{
+		{
+			name:    "unlimited",
+			actor:   "octocat",
+			running: 1000,
+		},
+		{
+			name:    "blocked",
+			blocked: []string{"OctoCat"},
+			actor:   "octocat",
+			want:    actorRejectBlocked,
+		},
+		{
+			name:    "not_blocked",
+			blocked: []string{"octocat"},
+			actor:   "hubot",
+		},
+		{
+			name:       "under_limit",
+			maxRunners: 10,
+			actor:      "octocat",
+			running:    9,
+		},
+		{
+			name:       "at_limit",
+			maxRunners: 10,
+			actor:      "octocat",
+			running:    10,
+			want:       actorRejectLimit,
+		},
+		{
+			name:       "override_raises_limit",
+			maxRunners: 10,
+			overrides:  map[string]string{"Release-Bot": "50"},
+			actor:      "release-bot",
+			running:    10,
+		},
+		{
+			name:       "override_removes_limit",
+			maxRunners: 10,
+			overrides:  map[string]string{"release-bot": "0"},
+			actor:      "release-bot",
+			running:    1000,
+		},
+		{
+			name:      "override_only",
+			overrides: map[string]string{"octocat": "2"},
+			actor:     "octocat",
+			running:   2,
+			want:      actorRejectLimit,
+		},
+		{
+			name:       "no_actor",
+			blocked:    []string{"octocat"},
+			maxRunners: 1,
+			running:    1,
+		},
+		{
+			name:       "negative_max",
+			maxRunners: -1,
+			wantErr:    "must not be negative",
+		},
+		{
+			name:      "invalid_override",
+			overrides: map[string]string{"octocat": "many"},
+			wantErr:   `maximum runners for actor "octocat" is not a number`,
+		},
+		{
+			name:      "negative_override",
+			overrides: map[string]string{"octocat": "-1"},
+			wantErr:   `maximum runners for actor "octocat" must not be negative`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := newActorLimits(tc.blocked, tc.maxRunners, tc.overrides)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := l.check(tc.actor, tc.running), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunnerTrackerCountActor(t *testing.T) {
+	t.Parallel()
+
+	tracker := newRunnerTracker()
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-1", Actor: "octocat"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-2", Actor: "OctoCat"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-3", Actor: "hubot"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-4"})
+
+	if got, want := tracker.CountActor("octocat"), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	tracker.Remove("GCP-1")
+	if got, want := tracker.CountActor("octocat"), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
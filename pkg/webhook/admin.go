@@ -0,0 +1,107 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// adminRoutes returns the admin API, served under /admin/. Every request must
+// carry the admin token as a bearer token.
+func (s *Server) adminRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/jobs/{org}/{repo}/{run_id}/{job_id}/logs", s.handleAdminJobLogs())
+	mux.Handle("GET /admin/maintenance", s.handleAdminMaintenance())
+	mux.Handle("PUT /admin/maintenance", s.handleAdminMaintenance())
+	mux.Handle("DELETE /admin/maintenance", s.handleAdminMaintenance())
+	mux.Handle("POST /admin/prewarm", s.handleAdminPrewarm())
+	mux.Handle("POST /admin/reconcile", s.handleAdminReconcile())
+	mux.Handle("GET /admin/recommendations", s.handleAdminRecommendations())
+	mux.Handle("POST /admin/replay/{delivery_id}", s.handleAdminReplay())
+	return s.requireAdminToken(mux)
+}
+
+// requireAdminToken rejects requests without the admin token.
+func (s *Server) requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), s.adminToken) != 1 {
+			s.h.RenderJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": "missing or invalid admin token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jobLogs locates the runner logs of a job.
+type jobLogs struct {
+	// Prefix is the folder of the runner logs bucket holding the logs of the
+	// job's runners, one object per build.
+	Prefix string `json:"prefix"`
+
+	// ConsoleURL links to the folder in the Cloud console.
+	ConsoleURL string `json:"console_url"`
+
+	// Object is the logs object of the build of the runner this instance
+	// provisioned for the job, while the job runs. Runners provisioned ahead
+	// of demand write their logs outside of Prefix.
+	Object string `json:"object,omitempty"`
+}
+
+// handleAdminJobLogs responds with the location of the runner logs of a job.
+func (s *Server) handleAdminJobLogs() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.runnerLogsBucket == "" {
+			s.h.RenderJSON(w, http.StatusNotFound, map[string]string{
+				"error": "runner logs are not written to a bucket, RUNNER_LOGS_BUCKET is not set",
+			})
+			return
+		}
+
+		runID, err := strconv.ParseInt(r.PathValue("run_id"), 10, 64)
+		if err != nil {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("run ID %q is not a number", r.PathValue("run_id")),
+			})
+			return
+		}
+		jobID, err := strconv.ParseInt(r.PathValue("job_id"), 10, 64)
+		if err != nil {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("job ID %q is not a number", r.PathValue("job_id")),
+			})
+			return
+		}
+
+		prefix := jobLogsPrefix(r.PathValue("org"), r.PathValue("repo"), runID, jobID)
+		resp := &jobLogs{
+			Prefix:     fmt.Sprintf("gs://%s/%s/", s.runnerLogsBucket, prefix),
+			ConsoleURL: fmt.Sprintf("https://console.cloud.google.com/storage/browser/%s/%s", s.runnerLogsBucket, prefix),
+		}
+		for _, tr := range s.runners.List() {
+			if tr.JobID == jobID && tr.LogsObject != "" {
+				resp.Object = tr.LogsObject
+				break
+			}
+		}
+		s.h.RenderJSON(w, http.StatusOK, resp)
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abcxyz/pkg/renderer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleAdminJobLogs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		bucket   string
+		disabled bool
+		path     string
+		token    string
+		wantCode int
+		want     *jobLogs
+	}{
+		{
+			name:     "tracked_runner",
+			bucket:   "runner-logs",
+			path:     "/admin/jobs/google/webhook/1/2/logs",
+			token:    "admin-token",
+			wantCode: http.StatusOK,
+			want: &jobLogs{
+				Prefix:     "gs://runner-logs/google/webhook/1/2/",
+				ConsoleURL: "https://console.cloud.google.com/storage/browser/runner-logs/google/webhook/1/2",
+				Object:     "gs://runner-logs/google/webhook/1/2/log-build-id.txt",
+			},
+		},
+		{
+			name:     "untracked_runner",
+			bucket:   "runner-logs",
+			path:     "/admin/jobs/google/webhook/1/3/logs",
+			token:    "admin-token",
+			wantCode: http.StatusOK,
+			want: &jobLogs{
+				Prefix:     "gs://runner-logs/google/webhook/1/3/",
+				ConsoleURL: "https://console.cloud.google.com/storage/browser/runner-logs/google/webhook/1/3",
+			},
+		},
+		{
+			name:     "invalid_token",
+			bucket:   "runner-logs",
+			path:     "/admin/jobs/google/webhook/1/2/logs",
+			token:    "not-the-token",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "invalid_job_id",
+			bucket:   "runner-logs",
+			path:     "/admin/jobs/google/webhook/1/latest/logs",
+			token:    "admin-token",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "no_bucket",
+			path:     "/admin/jobs/google/webhook/1/2/logs",
+			token:    "admin-token",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "admin_disabled",
+			bucket:   "runner-logs",
+			disabled: true,
+			path:     "/admin/jobs/google/webhook/1/2/logs",
+			token:    "",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			s := &Server{
+				adminToken:       []byte("admin-token"),
+				h:                renderer.NewTesting(ctx, t, nil),
+				runnerLogsBucket: tc.bucket,
+				runners:          newRunnerTracker(),
+			}
+			if tc.disabled {
+				s.adminToken = nil
+			}
+			s.runners.Dispatched(&trackedRunner{
+				RunnerName: "GCP-2",
+				JobID:      2,
+				BuildID:    "build-id",
+				LogsObject: "gs://runner-logs/google/webhook/1/2/log-build-id.txt",
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp := httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.want == nil {
+				return
+			}
+
+			var got jobLogs
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, &got); diff != "" {
+				t.Errorf("unexpected logs (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
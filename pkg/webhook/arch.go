@@ -0,0 +1,56 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	// arm64Label is the runner label of jobs running on arm64, as GitHub
+	// labels hosted arm64 runners.
+	arm64Label = "ARM64"
+
+	// x64Label is the runner label of jobs running on x86-64.
+	x64Label = "X64"
+
+	// arm64PoolName is the worker pool name logged for arm64 runners, which
+	// run in the arm64 worker pool unless they select a pool.
+	arm64PoolName = "arm64"
+)
+
+// Runner architectures, in the form Kubernetes and Docker name them.
+const (
+	runnerArchAMD64 = "amd64"
+	runnerArchARM64 = "arm64"
+)
+
+// runnerArch returns the architecture the job runs on, arm64 when it has the
+// ARM64 label and amd64 otherwise.
+func runnerArch(labels []string) string {
+	if slices.ContainsFunc(labels, func(l string) bool { return strings.EqualFold(l, arm64Label) }) {
+		return runnerArchARM64
+	}
+	return runnerArchAMD64
+}
+
+// runnerArchLabel returns the architecture label the runner registers with.
+func runnerArchLabel(arch string) string {
+	if arch == runnerArchARM64 {
+		return arm64Label
+	}
+	return x64Label
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+)
+
+func TestRunnerArch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		labels []string
+		want   string
+	}{
+		{
+			name:   "default",
+			labels: []string{"self-hosted", "linux"},
+			want:   runnerArchAMD64,
+		},
+		{
+			name:   "arm64",
+			labels: []string{"self-hosted", "ARM64"},
+			want:   runnerArchARM64,
+		},
+		{
+			name:   "arm64_lowercase",
+			labels: []string{"self-hosted", "arm64"},
+			want:   runnerArchARM64,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := runnerArch(tc.labels), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
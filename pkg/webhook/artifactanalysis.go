@@ -0,0 +1,156 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/containeranalysis/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	// artifactRegistryHostSuffix is the suffix of the hosts of Artifact
+	// Registry Docker repositories, prefixed with the repository location.
+	artifactRegistryHostSuffix = "-docker.pkg.dev"
+
+	// severityCritical is the Artifact Analysis severity of critical
+	// vulnerabilities.
+	severityCritical = "CRITICAL"
+)
+
+// ImageScan is the result of the vulnerability scanning of an image.
+type ImageScan struct {
+	// Digest is the digest the image tag resolves to.
+	Digest string
+
+	// Critical is the number of critical vulnerabilities found in the image.
+	Critical int64
+}
+
+// ArtifactAnalysis looks up the container scanning results of Artifact
+// Registry images.
+type ArtifactAnalysis struct {
+	ar *artifactregistry.Service
+	ca *containeranalysis.Service
+}
+
+// NewArtifactAnalysis creates a new instance of an Artifact Analysis client.
+func NewArtifactAnalysis(ctx context.Context, opts ...option.ClientOption) (*ArtifactAnalysis, error) {
+	ar, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new artifact registry client: %w", err)
+	}
+	ca, err := containeranalysis.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new container analysis client: %w", err)
+	}
+
+	return &ArtifactAnalysis{
+		ar: ar,
+		ca: ca,
+	}, nil
+}
+
+// ScanImage resolves the tag of an image in the form
+// <location>-docker.pkg.dev/<project>/<repository>/<image>:<tag> to its
+// digest, and counts the critical vulnerabilities scanning found in it.
+func (a *ArtifactAnalysis) ScanImage(ctx context.Context, image string) (*ImageScan, error) {
+	ref, err := parseArtifactRegistryImage(image)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := a.ar.Projects.Locations.Repositories.Packages.Tags.Get(ref.tagName()).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	_, digest, ok := strings.Cut(tag.Version, "/versions/")
+	if !ok {
+		return nil, fmt.Errorf("tag %s has no version", tag.Name)
+	}
+
+	summary, err := a.ca.Projects.Occurrences.GetVulnerabilitySummary("projects/" + ref.project).
+		Filter(fmt.Sprintf("resourceUrl = %q", ref.resourceURL(digest))).
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vulnerability summary: %w", err)
+	}
+
+	scan := &ImageScan{Digest: digest}
+	for _, count := range summary.Counts {
+		if count.Severity == severityCritical {
+			scan.Critical += count.TotalCount
+		}
+	}
+	return scan, nil
+}
+
+// artifactRegistryImage is a tagged image of an Artifact Registry Docker
+// repository.
+type artifactRegistryImage struct {
+	host       string
+	location   string
+	project    string
+	repository string
+	image      string
+	tag        string
+}
+
+// parseArtifactRegistryImage parses an image in the form
+// <location>-docker.pkg.dev/<project>/<repository>/<image>:<tag>.
+func parseArtifactRegistryImage(image string) (*artifactRegistryImage, error) {
+	host, path, _ := strings.Cut(image, "/")
+	location, ok := strings.CutSuffix(host, artifactRegistryHostSuffix)
+	if !ok || location == "" {
+		return nil, fmt.Errorf("image %q is not in an artifact registry docker repository", image)
+	}
+
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("image %q must be in the form <host>/<project>/<repository>/<image>:<tag>", image)
+	}
+	i := strings.LastIndex(parts[2], ":")
+	if i <= 0 || i == len(parts[2])-1 {
+		return nil, fmt.Errorf("image %q has no tag", image)
+	}
+
+	return &artifactRegistryImage{
+		host:       host,
+		location:   location,
+		project:    parts[0],
+		repository: parts[1],
+		image:      parts[2][:i],
+		tag:        parts[2][i+1:],
+	}, nil
+}
+
+// tagName returns the Artifact Registry resource name of the image tag. Slashes
+// of the image name are escaped in its package name.
+func (r *artifactRegistryImage) tagName() string {
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s/packages/%s/tags/%s",
+		r.project, r.location, r.repository, url.PathEscape(r.image), r.tag)
+}
+
+// resourceURL returns the URL Artifact Analysis records the occurrences of the
+// image version with the digest under.
+func (r *artifactRegistryImage) resourceURL(digest string) string {
+	return fmt.Sprintf("https://%s/%s/%s/%s@%s", r.host, r.project, r.repository, r.image, digest)
+}
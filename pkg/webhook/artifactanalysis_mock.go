@@ -0,0 +1,41 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// MockImageScanner returns the scan of Scans, or Err, for every image and
+// records the scanned images.
+type MockImageScanner struct {
+	Scans map[string]*ImageScan
+	Err   error
+
+	mu      sync.Mutex
+	Scanned []string
+}
+
+func (m *MockImageScanner) ScanImage(ctx context.Context, image string) (*ImageScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Scanned = append(m.Scanned, image)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return m.Scans[image], nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/artifactregistry/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// ImageRegistry adheres to the interaction the webhook service has with
+// Artifact Registry to resolve runner images before dispatching runners.
+type ImageRegistry interface {
+	ImageDigest(ctx context.Context, image string) (string, error)
+}
+
+// ArtifactRegistry looks up the tags of Artifact Registry images.
+type ArtifactRegistry struct {
+	svc *artifactregistry.Service
+}
+
+// NewArtifactRegistry creates a new instance of an Artifact Registry client.
+func NewArtifactRegistry(ctx context.Context, opts ...option.ClientOption) (*ArtifactRegistry, error) {
+	svc, err := artifactregistry.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new artifact registry client: %w", err)
+	}
+
+	return &ArtifactRegistry{
+		svc: svc,
+	}, nil
+}
+
+// ImageDigest returns the digest the tag of an image in the form
+// <location>-docker.pkg.dev/<project>/<repository>/<image>:<tag> resolves to,
+// or "" if the tag does not exist.
+func (a *ArtifactRegistry) ImageDigest(ctx context.Context, image string) (string, error) {
+	ref, err := parseArtifactRegistryImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := a.svc.Projects.Locations.Repositories.Packages.Tags.Get(ref.tagName()).Context(ctx).Do()
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get tag: %w", err)
+	}
+	_, digest, ok := strings.Cut(tag.Version, "/versions/")
+	if !ok {
+		return "", fmt.Errorf("tag %s has no version", tag.Name)
+	}
+	return digest, nil
+}
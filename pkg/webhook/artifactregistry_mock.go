@@ -0,0 +1,41 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// MockImageRegistry resolves images to their digest in Digests, or returns
+// Err, and records the looked up images.
+type MockImageRegistry struct {
+	Digests map[string]string
+	Err     error
+
+	mu      sync.Mutex
+	Lookups []string
+}
+
+func (m *MockImageRegistry) ImageDigest(ctx context.Context, image string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Lookups = append(m.Lookups, image)
+	if m.Err != nil {
+		return "", m.Err
+	}
+	return m.Digests[image], nil
+}
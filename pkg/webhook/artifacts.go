@@ -0,0 +1,299 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+)
+
+const (
+	// artifactTokenTTL is how long a runner can request upload URLs, the
+	// maximum Cloud Build build timeout.
+	artifactTokenTTL = 24 * time.Hour
+
+	// maxArtifactNameLength bounds artifact names, object names are at most
+	// 1024 bytes including the job folder.
+	maxArtifactNameLength = 512
+
+	// storageHost is the host signed URLs point to.
+	storageHost = "storage.googleapis.com"
+)
+
+// BlobSigner adheres to the interaction the webhook service has with the IAM
+// Credentials API to sign Cloud Storage URLs.
+type BlobSigner interface {
+	SignBlob(ctx context.Context, serviceAccount string, payload []byte) ([]byte, error)
+}
+
+// artifactClaims is the scope of an artifact token: the folder of the runner
+// artifacts bucket the runner it was issued to may upload to.
+type artifactClaims struct {
+	Prefix    string `json:"prefix"`
+	Runner    string `json:"runner"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// artifactTokenKey derives the key of the artifact tokens from the webhook
+// secret, which every instance shares and which never leaves the service.
+func artifactTokenKey(webhookSecret []byte) []byte {
+	mac := hmac.New(sha256.New, webhookSecret)
+	mac.Write([]byte("runner-artifacts"))
+	return mac.Sum(nil)
+}
+
+// issueArtifactToken returns a token scoping uploads to the folder of the
+// runner's job. The runner is identified by its JIT runner name, the token is
+// handed to it in its build alongside its JIT configuration.
+func (s *Server) issueArtifactToken(req *runnerRequest, now time.Time) string {
+	return signToken(s.artifactsKey, &artifactClaims{
+		Prefix:    runnerLogsPrefix(req),
+		Runner:    req.RunnerName,
+		ExpiresAt: now.Add(artifactTokenTTL).Unix(),
+	})
+}
+
+// verifyArtifactToken returns the claims of a token issued by this service
+// that has not expired.
+func (s *Server) verifyArtifactToken(token string, now time.Time) (*artifactClaims, error) {
+	var claims artifactClaims
+	if err := openToken(s.artifactsKey, token, &claims); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("token expired")
+	}
+	return &claims, nil
+}
+
+// signToken returns the encoded claims followed by their HMAC with the key.
+func signToken(key []byte, claims any) string {
+	// Marshaling a struct of strings and numbers cannot fail.
+	b, _ := json.Marshal(claims)
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// openToken checks the HMAC of a token returned by signToken and decodes its
+// claims.
+func openToken(key []byte, token string, claims any) error {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return fmt.Errorf("malformed token")
+	}
+	gotMAC, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(gotMAC, mac.Sum(nil)) {
+		return fmt.Errorf("invalid token signature")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return fmt.Errorf("malformed token payload")
+	}
+	if err := json.Unmarshal(b, claims); err != nil {
+		return fmt.Errorf("malformed token payload")
+	}
+	return nil
+}
+
+// addArtifactsToken hands the runner the endpoint and token to request upload
+// URLs for the artifacts of its job.
+func (s *Server) addArtifactsToken(build *cloudbuildpb.Build, req *runnerRequest, now time.Time) {
+	if s.artifactsBucket == "" {
+		return
+	}
+
+	build.Substitutions["_ARTIFACTS_URL"] = s.artifactsEndpoint
+	build.Substitutions["_ARTIFACTS_TOKEN"] = s.issueArtifactToken(req, now)
+}
+
+// validateArtifactName checks the name of an artifact is a relative object
+// path that stays within the job folder.
+func validateArtifactName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(name) > maxArtifactNameLength {
+		return fmt.Errorf("name must be at most %d bytes", maxArtifactNameLength)
+	}
+	if strings.HasPrefix(name, "/") {
+		return fmt.Errorf("name %q must be a relative path", name)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("name %q must not contain empty, . or .. path segments", name)
+		}
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("name %q must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
+// uploadURLRequest is the body of an upload URL request.
+type uploadURLRequest struct {
+	// Name is the path of the artifact within the job folder.
+	Name string `json:"name"`
+}
+
+// uploadURL is a signed URL to upload an artifact with a PUT request.
+type uploadURL struct {
+	URL       string    `json:"url"`
+	Object    string    `json:"object"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleArtifactUploadURL responds with a short-lived signed URL uploading an
+// artifact to the folder of the job of the runner presenting the token.
+func (s *Server) handleArtifactUploadURL() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		now := time.Now()
+
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims, err := s.verifyArtifactToken(token, now)
+		if err != nil {
+			s.h.RenderJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": fmt.Sprintf("missing or invalid artifact token: %s", err),
+			})
+			return
+		}
+
+		var req uploadURLRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("failed to decode request: %s", err),
+			})
+			return
+		}
+		if err := validateArtifactName(req.Name); err != nil {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		object := claims.Prefix + "/" + req.Name
+		signed, err := s.signedUploadURL(ctx, object, now)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to sign artifact upload URL",
+				"runner_id", claims.Runner,
+				"object", object,
+				"error", err)
+			s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+				"error": "failed to sign upload URL",
+			})
+			return
+		}
+
+		logger.InfoContext(ctx, "issued artifact upload URL",
+			"runner_id", claims.Runner,
+			"object", object)
+		s.h.RenderJSON(w, http.StatusOK, &uploadURL{
+			URL:       signed,
+			Object:    fmt.Sprintf("gs://%s/%s", s.artifactsBucket, object),
+			ExpiresAt: now.Add(s.artifactsURLTTL).UTC(),
+		})
+	})
+}
+
+// signedUploadURL returns a V4 signed URL to PUT the object into the artifacts
+// bucket, signed with a Google-managed key of the artifacts service account.
+// See https://cloud.google.com/storage/docs/access-control/signing-urls-manually.
+func (s *Server) signedUploadURL(ctx context.Context, object string, now time.Time) (string, error) {
+	return s.signedURL(ctx, http.MethodPut, s.artifactsBucket, object, s.artifactsServiceAccount, s.artifactsURLTTL, now)
+}
+
+// signedURL returns a V4 signed URL for the method on the object, valid for
+// ttl.
+func (s *Server) signedURL(ctx context.Context, method, bucket, object, serviceAccount string, ttl time.Duration, now time.Time) (string, error) {
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+
+	path := "/" + bucket + "/" + escapeObjectName(object)
+	query := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {serviceAccount + "/" + scope},
+		"X-Goog-Date":          {timestamp},
+		"X-Goog-Expires":       {strconv.Itoa(int(ttl.Seconds()))},
+		"X-Goog-SignedHeaders": {"host"},
+	}
+	// Encode sorts by key, as the canonical request requires.
+	canonicalQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery,
+		"host:" + storageHost + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"GOOG4-RSA-SHA256",
+		timestamp,
+		scope,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+
+	sig, err := s.blobSigner.SignBlob(ctx, serviceAccount, []byte(stringToSign))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign url: %w", err)
+	}
+	return fmt.Sprintf("https://%s%s?%s&X-Goog-Signature=%s", storageHost, path, canonicalQuery, hex.EncodeToString(sig)), nil
+}
+
+// escapeObjectName percent-encodes the object name for the canonical request,
+// leaving only unreserved characters and the path separators.
+func escapeObjectName(name string) string {
+	var b strings.Builder
+	for i := range len(name) {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/renderer"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestArtifactToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := &Server{artifactsKey: artifactTokenKey([]byte("webhook-secret"))}
+	token := s.issueArtifactToken(&runnerRequest{
+		Org:        "google",
+		Repo:       "webhook",
+		RunnerName: "GCP-2",
+		Job: &github.WorkflowJobEvent{
+			WorkflowJob: &github.WorkflowJob{RunID: github.Ptr(int64(1)), ID: github.Ptr(int64(2))},
+		},
+	}, now)
+
+	cases := []struct {
+		name       string
+		token      string
+		key        []byte
+		now        time.Time
+		wantPrefix string
+		wantErr    string
+	}{
+		{
+			name:       "valid",
+			token:      token,
+			now:        now.Add(time.Hour),
+			wantPrefix: "google/webhook/1/2",
+		},
+		{
+			name:    "expired",
+			token:   token,
+			now:     now.Add(artifactTokenTTL),
+			wantErr: "token expired",
+		},
+		{
+			name:    "other_secret",
+			token:   token,
+			key:     artifactTokenKey([]byte("other-secret")),
+			now:     now,
+			wantErr: "invalid token signature",
+		},
+		{
+			name:    "tampered_scope",
+			token:   strings.Replace(token, token[:4], "eyJx", 1),
+			now:     now,
+			wantErr: "invalid token signature",
+		},
+		{
+			name:    "malformed",
+			token:   "not-a-token",
+			now:     now,
+			wantErr: "malformed token",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			verifier := s
+			if tc.key != nil {
+				verifier = &Server{artifactsKey: tc.key}
+			}
+			claims, err := verifier.verifyArtifactToken(tc.token, tc.now)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := claims.Prefix, tc.wantPrefix; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := claims.Runner, "GCP-2"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSignedUploadURL(t *testing.T) {
+	t.Parallel()
+
+	signer := &MockBlobSigner{Sig: []byte{0xca, 0xfe}}
+	s := &Server{
+		artifactsBucket:         "runner-artifacts",
+		artifactsServiceAccount: "signer@my-project.iam.gserviceaccount.com",
+		artifactsURLTTL:         15 * time.Minute,
+		blobSigner:              signer,
+	}
+
+	got, err := s.signedUploadURL(t.Context(), "google/webhook/1/2/dist/app v1.tar.gz", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "https://storage.googleapis.com/runner-artifacts/google/webhook/1/2/dist/app%20v1.tar.gz" +
+		"?X-Goog-Algorithm=GOOG4-RSA-SHA256" +
+		"&X-Goog-Credential=signer%40my-project.iam.gserviceaccount.com%2F20250102%2Fauto%2Fstorage%2Fgoog4_request" +
+		"&X-Goog-Date=20250102T030405Z&X-Goog-Expires=900&X-Goog-SignedHeaders=host&X-Goog-Signature=cafe"
+	if got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	if got, want := len(signer.Payloads), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	wantPrefix := "GOOG4-RSA-SHA256\n20250102T030405Z\n20250102/auto/storage/goog4_request\n"
+	if got := string(signer.Payloads[0]); !strings.HasPrefix(got, wantPrefix) {
+		t.Errorf("expected %q to start with %q", got, wantPrefix)
+	}
+}
+
+func TestHandleArtifactUploadURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		disabled   bool
+		token      string
+		body       string
+		signErr    error
+		wantCode   int
+		wantObject string
+	}{
+		{
+			name:       "upload_url",
+			body:       `{"name":"dist/app.tar.gz"}`,
+			wantCode:   http.StatusOK,
+			wantObject: "gs://runner-artifacts/google/webhook/runners/GCP-2/dist/app.tar.gz",
+		},
+		{
+			name:     "invalid_token",
+			token:    "not-a-token",
+			body:     `{"name":"dist/app.tar.gz"}`,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "path_traversal",
+			body:     `{"name":"../../other/app.tar.gz"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing_name",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "sign_failure",
+			body:     `{"name":"app.tar.gz"}`,
+			signErr:  fmt.Errorf("permission denied"),
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			body:     `{"name":"app.tar.gz"}`,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			s := &Server{
+				artifactsBucket:         "runner-artifacts",
+				artifactsKey:            artifactTokenKey([]byte("webhook-secret")),
+				artifactsServiceAccount: "signer@my-project.iam.gserviceaccount.com",
+				artifactsURLTTL:         15 * time.Minute,
+				blobSigner:              &MockBlobSigner{Sig: []byte{0xca, 0xfe}, Err: tc.signErr},
+				h:                       renderer.NewTesting(ctx, t, nil),
+			}
+			token := tc.token
+			if token == "" {
+				token = s.issueArtifactToken(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, time.Now())
+			}
+			if tc.disabled {
+				s.artifactsBucket = ""
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/artifacts/upload-url", strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.wantObject == "" {
+				return
+			}
+
+			var got uploadURL
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := got.Object, tc.wantObject; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if want := "https://storage.googleapis.com/runner-artifacts/google/webhook/runners/GCP-2/dist/app.tar.gz?"; !strings.HasPrefix(got.URL, want) {
+				t.Errorf("expected %q to start with %q", got.URL, want)
+			}
+		})
+	}
+}
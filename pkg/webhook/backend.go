@@ -0,0 +1,301 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Backends runners are run on. The default backend is selected with
+// RUNNER_BACKEND, or replaced with WithRunnerBackend, jobs with the batch label
+// run on Batch and runners fall back to the instance group when Cloud Build
+// quota is exhausted.
+const (
+	runnerBackendCloudBuild = "cloudbuild"
+	runnerBackendCompute    = "compute"
+	runnerBackendGKE        = "gke"
+	runnerBackendBatch      = "batch"
+	runnerBackendFallback   = "fallback"
+	runnerBackendCustom     = "custom"
+)
+
+// RunnerBackend starts and stops the runners the webhook service dispatches,
+// after it handled the request and generated the JIT configuration of the
+// runner.
+type RunnerBackend interface {
+	// Provision starts the runner and returns the ID it is cancelled by. It
+	// does not wait for the runner to come online. A codes.ResourceExhausted
+	// error is counted as exhausted quota.
+	Provision(ctx context.Context, spec *RunnerSpec) (string, error)
+
+	// Cancel stops the runner with the ID returned by Provision and releases
+	// its resources. The runner may already have exited.
+	Cancel(ctx context.Context, id string) error
+}
+
+// PreemptionChecker is implemented by backends that run runners on spot
+// capacity, which may be reclaimed at any time.
+type PreemptionChecker interface {
+	// Preempted reports whether the runner with the ID returned by Provision
+	// was stopped by a preemption.
+	Preempted(ctx context.Context, id string) (bool, error)
+}
+
+// RunnerStatusChecker is implemented by backends that can tell whether a
+// runner is still running.
+type RunnerStatusChecker interface {
+	// Active reports whether the runner with the ID returned by Provision is
+	// still starting or running.
+	Active(ctx context.Context, id string) (bool, error)
+}
+
+// RunnerSpec describes a runner to provision. Build is the Cloud Build build
+// running the runner, backends running the runner elsewhere start the runner
+// image of its run step, see Image, Env and JITConfig.
+type RunnerSpec struct {
+	RunnerName string
+	Labels     []string
+	ProjectID  string
+
+	// Location is the Cloud Build location of the build, the location of its
+	// worker pool if any.
+	Location string
+
+	// Spot requests spot capacity. Backends without spot capacity ignore it.
+	Spot bool
+
+	// Accelerator is the type of the GPU attached to the runner, "" for none.
+	// Only the Compute Engine and Batch backends attach GPUs.
+	Accelerator string
+
+	// Arch is the architecture the runner runs on, amd64 or arm64.
+	Arch string
+
+	Build *cloudbuildpb.Build
+}
+
+// runnerEnvSubstitutions maps the environment variables the runner image reads
+// to the substitutions of the runner build holding their values, as passed by
+// the run step of the build.
+var runnerEnvSubstitutions = map[string]string{
+	"DOCKER_REGISTRY_MIRRORS":    "_REGISTRY_MIRRORS",
+	"DOCKER_INSECURE_REGISTRIES": "_INSECURE_REGISTRIES",
+	"TOOLCACHE_GCS_BUCKET":       "_TOOLCACHE_BUCKET",
+	"RUNNER_CACHE_PATHS":         "_CACHE_PATHS",
+	"HTTP_PROXY":                 "_HTTP_PROXY",
+	"HTTPS_PROXY":                "_HTTPS_PROXY",
+	"NO_PROXY":                   "_NO_PROXY",
+	"ARTIFACTS_URL":              "_ARTIFACTS_URL",
+	"ARTIFACTS_TOKEN":            "_ARTIFACTS_TOKEN",
+	"ACTIONS_CACHE_URL":          "_ACTIONS_CACHE_URL",
+	"JOB_STARTED_HOOK":           "_JOB_STARTED_HOOK",
+	"JOB_COMPLETED_HOOK":         "_JOB_COMPLETED_HOOK",
+	"RUNNER_SECURITY_MODE":       "_SECURITY_MODE",
+	"RUNNER_METADATA_ENV":        "_METADATA_ENV",
+}
+
+// Image returns the runner image of the build.
+func (r *RunnerSpec) Image() string {
+	subs := r.Build.GetSubstitutions()
+	return fmt.Sprintf("%s/%s:%s", subs["_REPOSITORY_ID"], subs["_IMAGE_NAME"], subs["_IMAGE_TAG"])
+}
+
+// Env returns the environment the run step of the build passes the runner,
+// other than its JIT configuration, including the extra environment
+// variables. Unset variables are omitted.
+func (r *RunnerSpec) Env() map[string]string {
+	subs := r.Build.GetSubstitutions()
+	env := parseExtraEnvArgs(subs["_EXTRA_ENV_ARGS"])
+	for name, sub := range runnerEnvSubstitutions {
+		if v := subs[sub]; v != "" {
+			env[name] = v
+		}
+	}
+	return env
+}
+
+// JITConfig returns the encoded JIT configuration of the runner, and the
+// Secret Manager secret version holding it instead when JIT configuration
+// secrets are enabled. The runner reads it from ENCODED_JIT_CONFIG.
+func (r *RunnerSpec) JITConfig() (encoded, secretVersion string) {
+	if version, ok := buildSecretEnv(r.Build)[jitConfigEnv]; ok {
+		return "", version
+	}
+	return r.Build.GetSubstitutions()["_ENCODED_JIT_CONFIG"], ""
+}
+
+// cloudBuildBackend runs each runner in its own Cloud Build build. Its IDs are
+// the full names of the builds.
+type cloudBuildBackend struct {
+	cbc     CloudBuildClient
+	timeout time.Duration
+	// trigger is the ID or name of the build trigger runner builds are run
+	// from, in the project and location of each runner, instead of being
+	// created. Only the substitutions of the runner build are passed to it.
+	trigger string
+}
+
+func (b *cloudBuildBackend) Provision(ctx context.Context, spec *RunnerSpec) (string, error) {
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if b.trigger != "" {
+		return b.runTrigger(cctx, spec)
+	}
+	build, err := b.cbc.CreateBuild(cctx, &cloudbuildpb.CreateBuildRequest{
+		Parent:    fmt.Sprintf("projects/%s/locations/%s", spec.ProjectID, spec.Location),
+		ProjectId: spec.ProjectID,
+		Build:     spec.Build,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create build: %w", err)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/builds/%s", spec.ProjectID, spec.Location, build.GetId()), nil
+}
+
+// runTrigger runs the build trigger of the backend with the substitutions of
+// the runner build, and returns the full name of the build it started.
+func (b *cloudBuildBackend) runTrigger(ctx context.Context, spec *RunnerSpec) (string, error) {
+	build, err := b.cbc.RunBuildTrigger(ctx, &cloudbuildpb.RunBuildTriggerRequest{
+		Name:      fmt.Sprintf("projects/%s/locations/%s/triggers/%s", spec.ProjectID, spec.Location, b.trigger),
+		ProjectId: spec.ProjectID,
+		TriggerId: b.trigger,
+		Source: &cloudbuildpb.RepoSource{
+			ProjectId:     spec.ProjectID,
+			Substitutions: spec.Build.GetSubstitutions(),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to run build trigger: %w", err)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/builds/%s", spec.ProjectID, spec.Location, build.GetId()), nil
+}
+
+func (b *cloudBuildBackend) Cancel(ctx context.Context, id string) error {
+	projectID, buildID, err := parseBuildName(id)
+	if err != nil {
+		return err
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	_, err = b.cbc.CancelBuild(cctx, &cloudbuildpb.CancelBuildRequest{
+		Name:      id,
+		ProjectId: projectID,
+		Id:        buildID,
+	})
+	// A build that already finished cannot be cancelled.
+	if err != nil && status.Code(err) != codes.FailedPrecondition {
+		return fmt.Errorf("failed to cancel build: %w", err)
+	}
+	return nil
+}
+
+// Active reports whether the build has not finished.
+func (b *cloudBuildBackend) Active(ctx context.Context, id string) (bool, error) {
+	projectID, buildID, err := parseBuildName(id)
+	if err != nil {
+		return false, err
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	build, err := b.cbc.GetBuild(cctx, &cloudbuildpb.GetBuildRequest{
+		Name:      id,
+		ProjectId: projectID,
+		Id:        buildID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get build: %w", err)
+	}
+	return slices.Contains(activeBuildStatuses, build.GetStatus()), nil
+}
+
+// parseBuildName returns the project and ID of the build with the given full
+// name.
+func parseBuildName(name string) (string, string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "builds" {
+		return "", "", fmt.Errorf("%q is not of the form projects/<project>/locations/<location>/builds/<id>", name)
+	}
+	return parts[1], parts[5], nil
+}
+
+// buildIDFromName returns the ID of the build with the given full name.
+func buildIDFromName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// runnerBackendFor returns the backend the runner with the given labels is
+// provisioned by, and its name.
+func (s *Server) runnerBackendFor(labels []string) (string, RunnerBackend) {
+	if s.batchBackend != nil && slices.Contains(labels, batchLabel) {
+		return runnerBackendBatch, s.batchBackend
+	}
+	if _, ok := labelValue(labels, gpuLabelPrefix); ok {
+		return s.gpuBackend()
+	}
+	if s.backend != nil {
+		return s.runnerBackend, s.backend
+	}
+	return runnerBackendCloudBuild, s.cloudBuildBackend()
+}
+
+// cloudBuildBackend returns the backend that runs runners in Cloud Build.
+func (s *Server) cloudBuildBackend() *cloudBuildBackend {
+	return &cloudBuildBackend{cbc: s.cbc, timeout: s.createBuildTimeout, trigger: s.runnerBuildTrigger}
+}
+
+// backendNamed returns the backend with the given name, or nil when it is not
+// configured.
+func (s *Server) backendNamed(name string) RunnerBackend {
+	switch {
+	case name == runnerBackendBatch:
+		return s.batchBackend
+	case name == runnerBackendFallback:
+		return s.fallbackBackend
+	case s.backend != nil && name == s.runnerBackend:
+		return s.backend
+	case name == runnerBackendCloudBuild:
+		return s.cloudBuildBackend()
+	}
+	return nil
+}
+
+// releaseRunner cancels the runner in its backend once its job completed, so
+// VMs and jobs that outlive their runner do not hold capacity. Builds end with
+// their runner and are left alone. VMs power off and jobs finish once their
+// ephemeral runner exits, so a failure is only logged.
+func (s *Server) releaseRunner(ctx context.Context, r trackedRunner) {
+	if r.BackendID == "" || r.Backend == runnerBackendCloudBuild {
+		return
+	}
+	backend := s.backendNamed(r.Backend)
+	if backend == nil {
+		return
+	}
+	if err := backend.Cancel(ctx, r.BackendID); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to release runner",
+			"runner_id", r.RunnerName, "backend", r.Backend, "backend_id", r.BackendID, "error", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerSpec(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerImageName:    "default-runner",
+		runnerImageTag:     "latest",
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+		runnerHTTPProxy:    "http://proxy:3128",
+	}
+	spec := &RunnerSpec{
+		RunnerName: "GCP-2",
+		Build:      s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config"),
+	}
+
+	if got, want := spec.Image(), "us-docker.pkg.dev/p/runners/default-runner:latest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := spec.Env()["HTTP_PROXY"], "http://proxy:3128"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if _, ok := spec.Env()["HTTPS_PROXY"]; ok {
+		t.Errorf("expected unset HTTPS_PROXY to be omitted")
+	}
+
+	encoded, secretVersion := spec.JITConfig()
+	if got, want := encoded, "encoded-jit-config"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := secretVersion, ""; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	spec.Build.AvailableSecrets = &cloudbuildpb.Secrets{
+		SecretManager: []*cloudbuildpb.SecretManagerSecret{{VersionName: "projects/p/secrets/s/versions/1", Env: jitConfigEnv}},
+	}
+	encoded, secretVersion = spec.JITConfig()
+	if got, want := encoded, ""; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := secretVersion, "projects/p/secrets/s/versions/1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestCloudBuildBackend(t *testing.T) {
+	t.Parallel()
+
+	cbc := &MockCloudBuildClient{createBuildRes: &cloudbuildpb.Build{Id: "build-1"}}
+	b := &cloudBuildBackend{cbc: cbc}
+
+	id, err := b.Provision(t.Context(), &RunnerSpec{
+		RunnerName: "GCP-2",
+		ProjectID:  "runner-project",
+		Location:   "us-central1",
+		Build:      &cloudbuildpb.Build{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "projects/runner-project/locations/us-central1/builds/build-1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := cbc.createBuildReq.GetParent(), "projects/runner-project/locations/us-central1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	if err := b.Cancel(t.Context(), id); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cbc.cancelBuildReqs[0].GetId(), "build-1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// A build that already finished cannot be cancelled.
+	cbc.cancelBuildErr = status.Error(codes.FailedPrecondition, "build already finished")
+	if err := b.Cancel(t.Context(), id); err != nil {
+		t.Errorf("expected finished build to be cancelled without error, got %v", err)
+	}
+
+	if diff := testutil.DiffErrString(b.Cancel(t.Context(), "build-1"), "is not of the form"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCloudBuildBackend_Trigger(t *testing.T) {
+	t.Parallel()
+
+	cbc := &MockCloudBuildClient{createBuildRes: &cloudbuildpb.Build{Id: "build-1"}}
+	b := &cloudBuildBackend{cbc: cbc, trigger: "github-runner"}
+
+	id, err := b.Provision(t.Context(), &RunnerSpec{
+		RunnerName: "GCP-2",
+		ProjectID:  "runner-project",
+		Location:   "us-central1",
+		Build: &cloudbuildpb.Build{
+			Substitutions: map[string]string{"_ENCODED_JIT_CONFIG": "jit", "_IMAGE_TAG": "latest"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, "projects/runner-project/locations/us-central1/builds/build-1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := len(cbc.createBuildReqs), 0; got != want {
+		t.Errorf("expected %d builds to be created, got %d", want, got)
+	}
+	if got, want := len(cbc.runBuildTriggerReqs), 1; got != want {
+		t.Fatalf("expected %d triggers to be run, got %d", want, got)
+	}
+	req := cbc.runBuildTriggerReqs[0]
+	if got, want := req.GetName(), "projects/runner-project/locations/us-central1/triggers/github-runner"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if diff := cmp.Diff(map[string]string{"_ENCODED_JIT_CONFIG": "jit", "_IMAGE_TAG": "latest"}, req.GetSource().GetSubstitutions()); diff != "" {
+		t.Errorf("substitutions (-want,+got):\n%s", diff)
+	}
+
+	cbc.createBuildErr = status.Error(codes.PermissionDenied, "denied")
+	_, err = b.Provision(t.Context(), &RunnerSpec{ProjectID: "runner-project", Location: "us-central1", Build: &cloudbuildpb.Build{}})
+	if diff := testutil.DiffErrString(err, "failed to run build trigger"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestReleaseRunner(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name             string
+		runner           trackedRunner
+		deleteErr        error
+		wantDeletedVMs   []string
+		wantDeletedGroup []string
+		wantDeletedBatch []string
+	}{
+		{
+			name:           "vm_runner",
+			runner:         trackedRunner{RunnerName: "GCP-2", Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-2"},
+			wantDeletedVMs: []string{"projects/p/zones/us-central1-a/instances/gcp-2"},
+		},
+		{
+			name:             "fallback_runner",
+			runner:           trackedRunner{RunnerName: "GCP-2", Backend: runnerBackendFallback, BackendID: "gcp-2"},
+			wantDeletedGroup: []string{"gcp-2"},
+		},
+		{
+			name:             "batch_runner",
+			runner:           trackedRunner{RunnerName: "GCP-2", Backend: runnerBackendBatch, BackendID: "projects/p/locations/us-central1/jobs/gcp-2"},
+			wantDeletedBatch: []string{"projects/p/locations/us-central1/jobs/gcp-2"},
+		},
+		{
+			name:   "build_runner",
+			runner: trackedRunner{RunnerName: "GCP-2", Backend: runnerBackendCloudBuild, BackendID: "projects/p/locations/us-central1/builds/build-1"},
+		},
+		{
+			name:           "delete_error",
+			runner:         trackedRunner{RunnerName: "GCP-2", Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-2"},
+			deleteErr:      fmt.Errorf("not found"),
+			wantDeletedVMs: []string{"projects/p/zones/us-central1-a/instances/gcp-2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			vms := &MockVMClient{DeleteErr: tc.deleteErr}
+			igc := &MockInstanceGroupClient{DeleteErr: tc.deleteErr}
+			batchJobs := &MockBatchClient{DeleteErr: tc.deleteErr}
+			cbc := &MockCloudBuildClient{}
+			s := &Server{
+				backend:         &vmBackend{vms: vms},
+				batchBackend:    &batchBackend{client: batchJobs},
+				cbc:             cbc,
+				fallbackBackend: &fallbackBackend{client: igc, group: &InstanceGroup{Project: "p", Zone: "us-central1-a", Name: "runners"}},
+				runnerBackend:   runnerBackendCompute,
+			}
+
+			s.releaseRunner(t.Context(), tc.runner)
+			if diff := cmp.Diff(tc.wantDeletedVMs, vms.Deleted); diff != "" {
+				t.Errorf("unexpected deleted vms (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeletedGroup, igc.Deleted); diff != "" {
+				t.Errorf("unexpected deleted instances (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeletedBatch, batchJobs.Deleted); diff != "" {
+				t.Errorf("unexpected deleted batch jobs (-want, +got):\n%s", diff)
+			}
+			if got, want := len(cbc.cancelBuildReqs), 0; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+		})
+	}
+}
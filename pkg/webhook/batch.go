@@ -0,0 +1,127 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/batch/v1"
+	"google.golang.org/api/option"
+)
+
+// Batch creates and deletes the Cloud Batch jobs heavy runners run in.
+type Batch struct {
+	svc *batch.Service
+}
+
+// NewBatch creates a new instance of a Cloud Batch client.
+func NewBatch(ctx context.Context, opts ...option.ClientOption) (*Batch, error) {
+	svc, err := batch.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new batch client: %w", err)
+	}
+
+	return &Batch{
+		svc: svc,
+	}, nil
+}
+
+// CreateBatchJob creates a Batch job of a single task running the runner
+// container on its own VM. It does not wait for the job to be scheduled.
+func (b *Batch) CreateBatchJob(ctx context.Context, job *RunnerBatchJob) error {
+	policy := &batch.InstancePolicy{
+		MachineType: job.MachineType,
+	}
+	if job.Spot {
+		policy.ProvisioningModel = "SPOT"
+	}
+	if job.AcceleratorType != "" {
+		policy.Accelerators = []*batch.Accelerator{
+			{Type: job.AcceleratorType, Count: job.AcceleratorCount},
+		}
+	}
+
+	spec := &batch.TaskSpec{
+		Runnables: []*batch.Runnable{
+			{
+				Container: &batch.Container{
+					ImageUri: job.Image,
+					Options:  job.ContainerOptions,
+				},
+				Environment: &batch.Environment{
+					Variables:       job.Env,
+					SecretVariables: job.SecretEnv,
+				},
+			},
+		},
+	}
+	if job.MaxRunSeconds > 0 {
+		spec.MaxRunDuration = fmt.Sprintf("%ds", job.MaxRunSeconds)
+	}
+
+	req := &batch.Job{
+		Labels: job.Labels,
+		TaskGroups: []*batch.TaskGroup{
+			{TaskCount: 1, TaskSpec: spec},
+		},
+		AllocationPolicy: &batch.AllocationPolicy{
+			Instances: []*batch.InstancePolicyOrTemplate{
+				{Policy: policy, InstallGpuDrivers: job.AcceleratorType != ""},
+			},
+		},
+		LogsPolicy: &batch.LogsPolicy{Destination: "CLOUD_LOGGING"},
+	}
+	if job.ServiceAccount != "" {
+		req.AllocationPolicy.ServiceAccount = &batch.ServiceAccount{Email: job.ServiceAccount}
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", job.Project, job.Location)
+	if _, err := b.svc.Projects.Locations.Jobs.Create(parent, req).JobId(job.Name).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to create batch job %s in %s: %w", job.Name, parent, err)
+	}
+	return nil
+}
+
+// batchPreemptedExitCode is the exit code Batch reports for a task whose spot
+// VM was preempted.
+const batchPreemptedExitCode = 50001
+
+// BatchJobPreempted reports whether the task of the Batch job with the given
+// full name failed because its spot VM was preempted.
+func (b *Batch) BatchJobPreempted(ctx context.Context, name string) (bool, error) {
+	job, err := b.svc.Projects.Locations.Jobs.Get(name).Context(ctx).Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to get batch job %s: %w", name, err)
+	}
+	if job.Status == nil {
+		return false, nil
+	}
+	for _, event := range job.Status.StatusEvents {
+		if event.TaskExecution != nil && event.TaskExecution.ExitCode == batchPreemptedExitCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteBatchJob deletes the Batch job with the given full name, stopping its
+// task. It does not wait for the task to stop.
+func (b *Batch) DeleteBatchJob(ctx context.Context, name string) error {
+	if _, err := b.svc.Projects.Locations.Jobs.Delete(name).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete batch job %s: %w", name, err)
+	}
+	return nil
+}
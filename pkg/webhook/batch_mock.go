@@ -0,0 +1,58 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// MockBatchClient records the Batch jobs created and deleted, failing with
+// CreateErr and DeleteErr. The jobs in Preempted were preempted.
+type MockBatchClient struct {
+	CreateErr error
+	DeleteErr error
+	Preempted map[string]bool
+
+	mu      sync.Mutex
+	Created []*RunnerBatchJob
+	Deleted []string
+}
+
+func (m *MockBatchClient) CreateBatchJob(ctx context.Context, job *RunnerBatchJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateErr != nil {
+		return m.CreateErr
+	}
+	m.Created = append(m.Created, job)
+	return nil
+}
+
+func (m *MockBatchClient) BatchJobPreempted(ctx context.Context, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Preempted[name], nil
+}
+
+func (m *MockBatchClient) DeleteBatchJob(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Deleted = append(m.Deleted, name)
+	return m.DeleteErr
+}
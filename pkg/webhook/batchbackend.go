@@ -0,0 +1,146 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// batchLabel is the runner label of jobs run in Cloud Batch jobs, on larger
+// machines and with longer timeouts than Cloud Build builds allow.
+const batchLabel = "batch"
+
+// BatchClient adheres to the interaction the webhook service has with Cloud
+// Batch to run heavy runners.
+type BatchClient interface {
+	CreateBatchJob(ctx context.Context, job *RunnerBatchJob) error
+	BatchJobPreempted(ctx context.Context, name string) (bool, error)
+	DeleteBatchJob(ctx context.Context, name string) error
+}
+
+// RunnerBatchJob is a Cloud Batch job running a runner container in a single
+// task.
+type RunnerBatchJob struct {
+	Project          string
+	Location         string
+	Name             string
+	Image            string
+	MachineType      string
+	AcceleratorType  string
+	AcceleratorCount int64
+	ServiceAccount   string
+	Spot             bool
+	Labels           map[string]string
+	Env              map[string]string
+
+	// ContainerOptions are the docker run flags of the runner container, which
+	// let the runner start its own Docker daemon in the privileged and rootless
+	// security modes.
+	ContainerOptions string
+
+	// SecretEnv are the environment variables read from Secret Manager, by
+	// secret version.
+	SecretEnv map[string]string
+
+	// MaxRunSeconds is how long the task may run before Batch stops it, 0 for
+	// the Batch default.
+	MaxRunSeconds int64
+}
+
+// batchBackend runs each runner in a Cloud Batch job of the runner project.
+// Its IDs are the full names of the Batch jobs.
+type batchBackend struct {
+	client           BatchClient
+	location         string
+	machineType      string
+	acceleratorType  string
+	acceleratorCount int
+	serviceAccount   string
+	maxRunDuration   time.Duration
+	timeout          time.Duration
+
+	// arm64MachineType is the machine type of arm64 runner jobs.
+	arm64MachineType string
+}
+
+// Provision creates the Batch job, with the environment the run step of the
+// build would pass the runner. The secrets of the runner, and its JIT
+// configuration when it was moved to a secret, are read from Secret Manager.
+// The other steps of the build are not run. The task may run for the longer of
+// the timeout of the build and the maximum run duration, and the job is
+// deleted when its job completes.
+func (b *batchBackend) Provision(ctx context.Context, spec *RunnerSpec) (string, error) {
+	env := spec.Env()
+	secretEnv := spec.SecretEnv()
+	if encoded, secretVersion := spec.JITConfig(); secretVersion == "" {
+		env[jitConfigEnv] = encoded
+	}
+
+	machineType := b.machineType
+	if spec.Arch == runnerArchARM64 {
+		machineType = b.arm64MachineType
+	}
+
+	// A GPU requested by the job replaces the configured accelerators.
+	acceleratorType, acceleratorCount := b.acceleratorType, b.acceleratorCount
+	if spec.Accelerator != "" {
+		acceleratorType, acceleratorCount = spec.Accelerator, 1
+	}
+
+	job := &RunnerBatchJob{
+		Project:          spec.ProjectID,
+		Location:         b.location,
+		Name:             runnerInstanceName(spec.RunnerName),
+		Image:            spec.Image(),
+		MachineType:      machineType,
+		AcceleratorType:  acceleratorType,
+		AcceleratorCount: int64(acceleratorCount),
+		ServiceAccount:   b.serviceAccount,
+		Spot:             spec.Spot,
+		Labels:           map[string]string{"managed-by": "github-actions-on-gcp"},
+		Env:              env,
+		ContainerOptions: spec.SecurityArgs(),
+		SecretEnv:        secretEnv,
+		MaxRunSeconds:    max(spec.Build.GetTimeout().GetSeconds(), int64(b.maxRunDuration.Seconds())),
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.client.CreateBatchJob(cctx, job); err != nil {
+		return "", fmt.Errorf("failed to create runner batch job: %w", err)
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/jobs/%s", job.Project, job.Location, job.Name), nil
+}
+
+func (b *batchBackend) Preempted(ctx context.Context, id string) (bool, error) {
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	preempted, err := b.client.BatchJobPreempted(cctx, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to check runner batch job preemption: %w", err)
+	}
+	return preempted, nil
+}
+
+func (b *batchBackend) Cancel(ctx context.Context, id string) error {
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.client.DeleteBatchJob(cctx, id); err != nil {
+		return fmt.Errorf("failed to delete runner batch job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBatchBackendProvision(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		timeout       time.Duration
+		secret        string
+		spot          bool
+		accelerator   string
+		createErr     error
+		wantJob       string
+		wantMaxRun    int64
+		wantGPU       string
+		wantGPUCount  int64
+		wantJITConfig string
+		wantSecretEnv map[string]string
+		wantErr       string
+	}{
+		{
+			name:          "jit_config",
+			wantJob:       "projects/runner-project/locations/us-central1/jobs/gcp-2",
+			wantMaxRun:    24 * 60 * 60,
+			wantGPU:       "nvidia-l4",
+			wantGPUCount:  2,
+			wantJITConfig: "encoded-jit-config",
+		},
+		{
+			name:          "gpu_label",
+			accelerator:   "nvidia-tesla-t4",
+			wantJob:       "projects/runner-project/locations/us-central1/jobs/gcp-2",
+			wantMaxRun:    24 * 60 * 60,
+			wantGPU:       "nvidia-tesla-t4",
+			wantGPUCount:  1,
+			wantJITConfig: "encoded-jit-config",
+		},
+		{
+			name:          "jit_config_secret",
+			secret:        "projects/runner-project/secrets/jit-config-GCP-2/versions/1",
+			wantJob:       "projects/runner-project/locations/us-central1/jobs/gcp-2",
+			wantMaxRun:    24 * 60 * 60,
+			wantGPU:       "nvidia-l4",
+			wantGPUCount:  2,
+			wantSecretEnv: map[string]string{"ENCODED_JIT_CONFIG": "projects/runner-project/secrets/jit-config-GCP-2/versions/1"},
+		},
+		{
+			name:          "longer_build_timeout",
+			timeout:       48 * time.Hour,
+			wantJob:       "projects/runner-project/locations/us-central1/jobs/gcp-2",
+			wantMaxRun:    48 * 60 * 60,
+			wantGPU:       "nvidia-l4",
+			wantGPUCount:  2,
+			wantJITConfig: "encoded-jit-config",
+		},
+		{
+			name:          "spot",
+			spot:          true,
+			wantJob:       "projects/runner-project/locations/us-central1/jobs/gcp-2",
+			wantMaxRun:    24 * 60 * 60,
+			wantGPU:       "nvidia-l4",
+			wantGPUCount:  2,
+			wantJITConfig: "encoded-jit-config",
+		},
+		{
+			name:      "create_error",
+			createErr: fmt.Errorf("quota exceeded"),
+			wantErr:   "failed to create runner batch job: quota exceeded",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			batchJobs := &MockBatchClient{CreateErr: tc.createErr}
+			b := &batchBackend{
+				client:           batchJobs,
+				location:         "us-central1",
+				machineType:      "g2-standard-24",
+				acceleratorType:  "nvidia-l4",
+				acceleratorCount: 2,
+				maxRunDuration:   24 * time.Hour,
+			}
+			s := &Server{
+				runnerHTTPProxy:    "http://proxy:3128",
+				runnerImageName:    "default-runner",
+				runnerImageTag:     "latest",
+				runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+			if tc.timeout > 0 {
+				build.Timeout = durationpb.New(tc.timeout)
+			}
+			if tc.secret != "" {
+				build.AvailableSecrets = &cloudbuildpb.Secrets{
+					SecretManager: []*cloudbuildpb.SecretManagerSecret{{VersionName: tc.secret, Env: jitConfigEnv}},
+				}
+			}
+
+			got, err := b.Provision(t.Context(), &RunnerSpec{RunnerName: "GCP-2", ProjectID: "runner-project", Spot: tc.spot, Accelerator: tc.accelerator, Build: build})
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := got, tc.wantJob; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+
+			if got, want := len(batchJobs.Created), 1; got != want {
+				t.Fatalf("expected %d to be %d", got, want)
+			}
+			job := batchJobs.Created[0]
+			if got, want := job.Image, "us-docker.pkg.dev/p/runners/default-runner:latest"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := job.MachineType, "g2-standard-24"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := job.AcceleratorType, tc.wantGPU; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := job.AcceleratorCount, tc.wantGPUCount; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := job.MaxRunSeconds, tc.wantMaxRun; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := job.Spot, tc.spot; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+			if got, want := job.Env["HTTP_PROXY"], "http://proxy:3128"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := job.Env["ENCODED_JIT_CONFIG"], tc.wantJITConfig; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if diff := cmp.Diff(tc.wantSecretEnv, job.SecretEnv); diff != "" {
+				t.Errorf("unexpected secret env (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
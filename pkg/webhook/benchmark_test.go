@@ -0,0 +1,147 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/googleapis/gax-go/v2"
+)
+
+// The benchmarks drive the webhook handler with signed synthetic deliveries
+// against in-process fakes, to catch regressions in the dispatch path. Run
+// them with:
+//
+//	go test ./pkg/webhook -run '^$' -bench BenchmarkHandleWebhook -benchmem -memprofile mem.out
+//
+// and compare runs with benchstat.
+
+// benchCloudBuildClient is a CloudBuildClient that is safe for concurrent use
+// and does no work.
+type benchCloudBuildClient struct {
+	builds atomic.Int64
+}
+
+func (c *benchCloudBuildClient) CancelBuild(ctx context.Context, req *cloudbuildpb.CancelBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	return &cloudbuildpb.Build{Id: req.GetId(), Status: cloudbuildpb.Build_CANCELLED}, nil
+}
+
+func (c *benchCloudBuildClient) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	return &cloudbuildpb.Build{Id: fmt.Sprintf("build-%d", c.builds.Add(1))}, nil
+}
+
+func (c *benchCloudBuildClient) GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	return &cloudbuildpb.Build{Id: req.GetId(), Status: cloudbuildpb.Build_WORKING}, nil
+}
+
+func (c *benchCloudBuildClient) ListBuilds(ctx context.Context, req *cloudbuildpb.ListBuildsRequest, opts ...gax.CallOption) ([]*cloudbuildpb.Build, error) {
+	return nil, nil
+}
+
+func (c *benchCloudBuildClient) GetWorkerPool(ctx context.Context, req *cloudbuildpb.GetWorkerPoolRequest, opts ...gax.CallOption) (*cloudbuildpb.WorkerPool, error) {
+	return &cloudbuildpb.WorkerPool{Name: req.GetName()}, nil
+}
+
+func (c *benchCloudBuildClient) RunBuildTrigger(ctx context.Context, req *cloudbuildpb.RunBuildTriggerRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	return &cloudbuildpb.Build{Id: fmt.Sprintf("build-%d", c.builds.Add(1))}, nil
+}
+
+func (c *benchCloudBuildClient) Close() error {
+	return nil
+}
+
+func BenchmarkHandleWebhook(b *testing.B) {
+	cases := []struct {
+		name      string
+		eventType string
+		payload   string
+	}{
+		{
+			name:      "queued",
+			eventType: "workflow_job",
+			payload:   `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "labels": ["self-hosted"]}, "installation": {"id": 123}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+		},
+		{
+			name:      "queued_other_label",
+			eventType: "workflow_job",
+			payload:   `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "labels": ["ubuntu-latest"]}, "installation": {"id": 123}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+		},
+		{
+			name:      "in_progress",
+			eventType: "workflow_job",
+			payload:   `{"action": "in_progress", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "runner_name": "GCP-1", "created_at": "2025-01-01T00:00:00Z", "started_at": "2025-01-01T00:01:00Z"}}`,
+		},
+		{
+			name:      "completed",
+			eventType: "workflow_job",
+			payload:   `{"action": "completed", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "runner_name": "GCP-1", "created_at": "2025-01-01T00:00:00Z", "started_at": "2025-01-01T00:01:00Z", "completed_at": "2025-01-01T00:05:00Z"}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			srv := newBenchServer(b)
+			handler := srv.handleWebhook()
+			payload := []byte(tc.payload)
+			signature := "sha256=" + createSignature(srv.webhookSecret, payload)
+			ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/webhook", bytes.NewReader(payload))
+				req.Header.Set(EventTypeHeader, tc.eventType)
+				req.Header.Set(ContentTypeHeader, "application/json")
+				req.Header.Set(SHA256SignatureHeader, signature)
+
+				resp := httptest.NewRecorder()
+				handler.ServeHTTP(resp, req)
+				if resp.Code != http.StatusOK {
+					b.Fatalf("unexpected response %d: %s", resp.Code, resp.Body.String())
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
+
+// newBenchServer returns a server provisioning runners against a fake GitHub
+// API and a no-op Cloud Build client.
+func newBenchServer(b *testing.B) *Server {
+	b.Helper()
+
+	app, ghURL := newFakeRunnerGitHub(b, func(string) {})
+	return &Server{
+		appClient:         app,
+		cbc:               &benchCloudBuildClient{},
+		ghAPIBaseURL:      ghURL,
+		installationRepos: newInstallationRepoCache(),
+		runnerImageName:   "default-runner",
+		runnerImageTag:    "latest",
+		runners:           newRunnerTracker(),
+		webhookSecret:     []byte("bench-secret"),
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"google.golang.org/api/binaryauthorization/v1"
+	"google.golang.org/api/containeranalysis/v1"
+	"google.golang.org/api/option"
+)
+
+// attestationVerified is the result of an attestation the attestor verified.
+const attestationVerified = "VERIFIED"
+
+// attestorRegexp matches the resource names of Binary Authorization attestors.
+var attestorRegexp = regexp.MustCompile(`^projects/[^/]+/attestors/[^/]+$`)
+
+// ImageVerifier adheres to the interaction the webhook service has with Binary
+// Authorization to only dispatch runners with trusted images.
+type ImageVerifier interface {
+	VerifyImage(ctx context.Context, attestor, image, digest string) (bool, error)
+}
+
+// BinaryAuthorization verifies the attestations of Artifact Registry images.
+type BinaryAuthorization struct {
+	ba *binaryauthorization.Service
+	ca *containeranalysis.Service
+}
+
+// NewBinaryAuthorization creates a new instance of a Binary Authorization
+// client.
+func NewBinaryAuthorization(ctx context.Context, opts ...option.ClientOption) (*BinaryAuthorization, error) {
+	ba, err := binaryauthorization.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new binary authorization client: %w", err)
+	}
+	ca, err := containeranalysis.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new container analysis client: %w", err)
+	}
+
+	return &BinaryAuthorization{
+		ba: ba,
+		ca: ca,
+	}, nil
+}
+
+// VerifyImage reports whether the attestor verifies one of the attestations of
+// the image version with the digest. The attestations are the occurrences of
+// the note of the attestor, whose signatures the attestor checks against its
+// public keys, like Binary Authorization does when enforcing a policy.
+func (b *BinaryAuthorization) VerifyImage(ctx context.Context, attestor, image, digest string) (bool, error) {
+	ref, err := parseArtifactRegistryImage(image)
+	if err != nil {
+		return false, err
+	}
+	resourceURL := ref.resourceURL(digest)
+
+	a, err := b.ba.Projects.Attestors.Get(attestor).Context(ctx).Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to get attestor: %w", err)
+	}
+	if a.UserOwnedGrafeasNote == nil {
+		return false, fmt.Errorf("attestor %s has no note", attestor)
+	}
+	note := a.UserOwnedGrafeasNote.NoteReference
+
+	var attestations []*containeranalysis.AttestationOccurrence
+	if err := b.ca.Projects.Notes.Occurrences.List(note).
+		Filter(fmt.Sprintf("resourceUrl = %q", resourceURL)).
+		Pages(ctx, func(page *containeranalysis.ListNoteOccurrencesResponse) error {
+			for _, occ := range page.Occurrences {
+				if occ.Attestation != nil && occ.ResourceUri == resourceURL {
+					attestations = append(attestations, occ.Attestation)
+				}
+			}
+			return nil
+		}); err != nil {
+		return false, fmt.Errorf("failed to list attestations: %w", err)
+	}
+
+	for _, attestation := range attestations {
+		// Both APIs serialize attestations the same way.
+		raw, err := json.Marshal(attestation)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert attestation: %w", err)
+		}
+		var converted binaryauthorization.AttestationOccurrence
+		if err := json.Unmarshal(raw, &converted); err != nil {
+			return false, fmt.Errorf("failed to convert attestation: %w", err)
+		}
+
+		resp, err := b.ba.Projects.Attestors.ValidateAttestationOccurrence(attestor, &binaryauthorization.ValidateAttestationOccurrenceRequest{
+			Attestation:           &converted,
+			OccurrenceNote:        note,
+			OccurrenceResourceUri: resourceURL,
+		}).Context(ctx).Do()
+		if err != nil {
+			return false, fmt.Errorf("failed to validate attestation: %w", err)
+		}
+		if resp.Result == attestationVerified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// MockImageVerifier verifies the digests of Verified, or returns Err, and
+// records the verified digests.
+type MockImageVerifier struct {
+	Verified map[string]bool
+	Err      error
+
+	mu       sync.Mutex
+	Verifies []string
+}
+
+func (m *MockImageVerifier) VerifyImage(ctx context.Context, attestor, image, digest string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Verifies = append(m.Verifies, digest)
+	if m.Err != nil {
+		return false, m.Err
+	}
+	return m.Verified[digest], nil
+}
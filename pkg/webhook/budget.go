@@ -0,0 +1,69 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+// minCallTimeout is the timeout of external calls made once the delivery
+// budget is used up, so processing can still finish after GitHub gave up on
+// the delivery.
+const minCallTimeout = time.Second
+
+type deliveryDeadlineContextKey struct{}
+
+// withDeliveryBudget returns a context carrying the time by which the
+// delivery must be answered. The context itself is not cancelled at the
+// deadline, the deadline only bounds the external calls made for it.
+func withDeliveryBudget(ctx context.Context, budget time.Duration, now time.Time) context.Context {
+	if budget <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, deliveryDeadlineContextKey{}, now.Add(budget))
+}
+
+// deliveryDeadline returns the time by which the delivery processed with ctx
+// must be answered, if any.
+func deliveryDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(deliveryDeadlineContextKey{}).(time.Time)
+	return deadline, ok
+}
+
+// callTimeout returns the timeout of an external call: the configured timeout
+// of the call, bounded by the remaining delivery budget, so a single slow
+// upstream call cannot use up the whole delivery. It returns 0 for no
+// timeout.
+func callTimeout(ctx context.Context, timeout time.Duration, now time.Time) time.Duration {
+	deadline, ok := deliveryDeadline(ctx)
+	if !ok {
+		return timeout
+	}
+	remaining := max(deadline.Sub(now), minCallTimeout)
+	if timeout <= 0 || remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
+
+// callContext returns the context of an external call, bounded by
+// callTimeout.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if t := callTimeout(ctx, timeout, time.Now()); t > 0 {
+		return context.WithTimeout(ctx, t)
+	}
+	return ctx, func() {}
+}
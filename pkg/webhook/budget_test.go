@@ -0,0 +1,82 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallTimeout(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		budget  time.Duration
+		elapsed time.Duration
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{
+			name:    "no_budget",
+			timeout: 3 * time.Second,
+			want:    3 * time.Second,
+		},
+		{
+			name: "no_budget_no_timeout",
+			want: 0,
+		},
+		{
+			name:    "within_budget",
+			budget:  9 * time.Second,
+			elapsed: 2 * time.Second,
+			timeout: 3 * time.Second,
+			want:    3 * time.Second,
+		},
+		{
+			name:    "bounded_by_budget",
+			budget:  9 * time.Second,
+			elapsed: 7 * time.Second,
+			timeout: 3 * time.Second,
+			want:    2 * time.Second,
+		},
+		{
+			name:    "budget_only",
+			budget:  9 * time.Second,
+			elapsed: 4 * time.Second,
+			want:    5 * time.Second,
+		},
+		{
+			name:    "budget_used_up",
+			budget:  9 * time.Second,
+			elapsed: 12 * time.Second,
+			timeout: 3 * time.Second,
+			want:    minCallTimeout,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := withDeliveryBudget(t.Context(), tc.budget, now)
+			if got := callTimeout(ctx, tc.timeout, now.Add(tc.elapsed)); got != tc.want {
+				t.Errorf("expected %s to be %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+// maxBuildDiskSizeGB is the largest disk Cloud Build accepts for a build in
+// the default pool.
+const maxBuildDiskSizeGB = 4000
+
+// buildMachineType parses a Cloud Build machine type name, for example
+// E2_HIGHCPU_8, case-insensitively.
+func buildMachineType(name string) (cloudbuildpb.BuildOptions_MachineType, error) {
+	v, ok := cloudbuildpb.BuildOptions_MachineType_value[strings.ToUpper(name)]
+	if !ok || v == int32(cloudbuildpb.BuildOptions_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown cloud build machine type %q", name)
+	}
+	return cloudbuildpb.BuildOptions_MachineType(v), nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestBuildMachineType(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		in      string
+		want    cloudbuildpb.BuildOptions_MachineType
+		wantErr string
+	}{
+		{
+			name: "valid",
+			in:   "E2_HIGHCPU_8",
+			want: cloudbuildpb.BuildOptions_E2_HIGHCPU_8,
+		},
+		{
+			name: "lowercase",
+			in:   "e2_highcpu_32",
+			want: cloudbuildpb.BuildOptions_E2_HIGHCPU_32,
+		},
+		{
+			name:    "unspecified",
+			in:      "UNSPECIFIED",
+			wantErr: `unknown cloud build machine type "UNSPECIFIED"`,
+		},
+		{
+			name:    "unknown",
+			in:      "n2-standard-8",
+			wantErr: `unknown cloud build machine type "n2-standard-8"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := buildMachineType(tc.in)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := got, tc.want; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRunnerBuildOptions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name            string
+		labels          []string
+		wantMachineType cloudbuildpb.BuildOptions_MachineType
+		wantDiskSizeGB  int64
+		wantPool        string
+	}{
+		{
+			name:            "default_pool",
+			labels:          []string{"self-hosted"},
+			wantMachineType: cloudbuildpb.BuildOptions_E2_HIGHCPU_32,
+			wantDiskSizeGB:  200,
+		},
+		{
+			name:     "private_pool",
+			labels:   []string{"self-hosted", "pool=private-xl"},
+			wantPool: "projects/p/locations/us-central1/workerPools/private-xl",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				runnerBuildDiskSizeGB:  200,
+				runnerBuildMachineType: cloudbuildpb.BuildOptions_E2_HIGHCPU_32,
+				runnerBuildTimeout:     3 * time.Hour,
+				runnerImageName:        "default-runner",
+				runnerImageTag:         "latest",
+				runnerRepositoryID:     "us-docker.pkg.dev/p/runners",
+				runnerWorkerPools:      map[string]string{"private-xl": "projects/p/locations/us-central1/workerPools/private-xl"},
+			}
+
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2", Labels: tc.labels}, "encoded-jit-config")
+			if got, want := build.GetOptions().GetMachineType(), tc.wantMachineType; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+			if got, want := build.GetOptions().GetDiskSizeGb(), tc.wantDiskSizeGB; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := build.GetOptions().GetPool().GetName(), tc.wantPool; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := build.GetTimeout().AsDuration(), 3*time.Hour; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+		})
+	}
+}
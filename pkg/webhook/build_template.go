@@ -0,0 +1,122 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// loadBuildTemplate reads the runner build template, a cloudbuild.yaml file
+// at a local path, for example a mounted config map, or in Cloud Storage in
+// the form gs://<bucket>/<object>. The template may only set steps, options,
+// timeout and substitutions, and must have a step with the id run, which runs
+// the runner and is the step other backends than Cloud Build run.
+func loadBuildTemplate(ctx context.Context, fr FileReader, store SettingsStore, location string) (*cloudbuildpb.Build, error) {
+	var b []byte
+	if strings.HasPrefix(location, "gs://") {
+		bucket, object, err := parseGCSObject(location)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := store.ReadSettings(ctx, bucket, object, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", location, err)
+		}
+		if obj == nil {
+			return nil, fmt.Errorf("failed to read %s: object has no generation", location)
+		}
+		b = obj.Data
+	} else {
+		var err error
+		if b, err = fr.ReadFile(location); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", location, err)
+		}
+	}
+	return parseBuildTemplate(b)
+}
+
+// parseBuildTemplate parses a cloudbuild.yaml build template. Fields are named
+// in camel case or snake case like in cloudbuild.yaml files.
+func parseBuildTemplate(b []byte) (*cloudbuildpb.Build, error) {
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse build template: %w", err)
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse build template: %w", err)
+	}
+	var tmpl cloudbuildpb.Build
+	if err := protojson.Unmarshal(j, &tmpl); err != nil {
+		return nil, fmt.Errorf("failed to parse build template: %w", err)
+	}
+
+	rest := proto.Clone(&tmpl).(*cloudbuildpb.Build)
+	rest.Steps, rest.Options, rest.Timeout, rest.Substitutions = nil, nil, nil, nil
+	if !proto.Equal(rest, &cloudbuildpb.Build{}) {
+		return nil, fmt.Errorf("build template may only set steps, options, timeout and substitutions")
+	}
+	runSteps := 0
+	for _, step := range tmpl.GetSteps() {
+		if step.GetId() == "run" {
+			runSteps++
+		}
+	}
+	if runSteps != 1 {
+		return nil, fmt.Errorf("build template must have exactly one step with id run, got %d", runSteps)
+	}
+	return &tmpl, nil
+}
+
+// applyBuildTemplate replaces the steps of the runner build with those of the
+// build template. The substitutions of the template are defaults the
+// substitutions of the webhook service override. Substitutions the template
+// does not use are allowed, so it may ignore those it does not need.
+func (s *Server) applyBuildTemplate(build *cloudbuildpb.Build) {
+	if s.buildTemplate == nil {
+		return
+	}
+	tmpl := proto.Clone(s.buildTemplate).(*cloudbuildpb.Build)
+
+	build.Steps = tmpl.GetSteps()
+	if tmpl.GetTimeout() != nil {
+		build.Timeout = tmpl.GetTimeout()
+	}
+	if tmpl.GetOptions() != nil {
+		pool := build.GetOptions().GetPool()
+		build.Options = tmpl.GetOptions()
+		if build.Options.GetLogging() == cloudbuildpb.BuildOptions_LOGGING_UNSPECIFIED {
+			build.Options.Logging = cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY
+		}
+		if pool != nil {
+			build.Options.Pool = pool
+		}
+	}
+	build.Options.SubstitutionOption = cloudbuildpb.BuildOptions_ALLOW_LOOSE
+
+	for key, value := range tmpl.GetSubstitutions() {
+		if _, ok := build.Substitutions[key]; !ok {
+			build.Substitutions[key] = value
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testBuildTemplate = `
+steps:
+  - id: prime-cache
+    name: gcr.io/cloud-builders/gsutil
+    args: ["cp", "gs://cache/warm.tar", "/workspace"]
+  - id: run
+    name: gcr.io/cloud-builders/docker
+    entrypoint: bash
+    env: ["ENCODED_JIT_CONFIG=$_ENCODED_JIT_CONFIG"]
+    args: ["-c", "docker run -e ENCODED_JIT_CONFIG $_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG"]
+  - id: ship-logs
+    name: gcr.io/cloud-builders/gcloud
+    args: ["logging", "write", "runners", "$_LOG_NAME"]
+    allow_failure: true
+timeout: 7200s
+options:
+  machineType: E2_HIGHCPU_8
+substitutions:
+  _LOG_NAME: runner-done
+  _IMAGE_TAG: ignored
+`
+
+func TestLoadBuildTemplate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		location  string
+		content   string
+		readErr   error
+		wantSteps []string
+		wantErr   string
+	}{
+		{
+			name:      "file",
+			location:  "/etc/webhook/cloudbuild.yaml",
+			content:   testBuildTemplate,
+			wantSteps: []string{"prime-cache", "run", "ship-logs"},
+		},
+		{
+			name:      "cloud_storage",
+			location:  "gs://templates/cloudbuild.yaml",
+			content:   testBuildTemplate,
+			wantSteps: []string{"prime-cache", "run", "ship-logs"},
+		},
+		{
+			name:     "read_error",
+			location: "gs://templates/cloudbuild.yaml",
+			readErr:  fmt.Errorf("permission denied"),
+			wantErr:  "failed to read gs://templates/cloudbuild.yaml: permission denied",
+		},
+		{
+			name:     "no_run_step",
+			location: "/etc/webhook/cloudbuild.yaml",
+			content: `
+steps:
+  - id: build
+    name: gcr.io/cloud-builders/docker
+`,
+			wantErr: "must have exactly one step with id run, got 0",
+		},
+		{
+			name:     "other_fields",
+			location: "/etc/webhook/cloudbuild.yaml",
+			content: `
+serviceAccount: projects/p/serviceAccounts/attacker@p.iam.gserviceaccount.com
+steps:
+  - id: run
+    name: gcr.io/cloud-builders/docker
+`,
+			wantErr: "may only set steps, options, timeout and substitutions",
+		},
+		{
+			name:     "unknown_field",
+			location: "/etc/webhook/cloudbuild.yaml",
+			content: `
+stepz:
+  - id: run
+`,
+			wantErr: "failed to parse build template",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			store := &MockSettingsStore{Object: &SettingsObject{Data: []byte(tc.content), Generation: 1}, Err: tc.readErr}
+			got, err := loadBuildTemplate(t.Context(), fr, store, tc.location)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			var gotSteps []string
+			for _, step := range got.GetSteps() {
+				gotSteps = append(gotSteps, step.GetId())
+			}
+			if diff := cmp.Diff(tc.wantSteps, gotSteps); diff != "" {
+				t.Errorf("unexpected steps (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyBuildTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := parseBuildTemplate([]byte(testBuildTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		buildTemplate:      tmpl,
+		runnerImageName:    "default-runner",
+		runnerImageTag:     "latest",
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+		runnerWorkerPoolID: "projects/p/locations/us-central1/workerPools/default",
+	}
+
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+	var gotSteps []string
+	for _, step := range build.GetSteps() {
+		gotSteps = append(gotSteps, step.GetId())
+	}
+	if diff := cmp.Diff([]string{"prime-cache", "run", "ship-logs"}, gotSteps); diff != "" {
+		t.Errorf("unexpected steps (-want, +got):\n%s", diff)
+	}
+	if got, want := build.GetTimeout().GetSeconds(), int64(7200); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := build.GetOptions().GetMachineType(), cloudbuildpb.BuildOptions_E2_HIGHCPU_8; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := build.GetOptions().GetLogging(), cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := build.GetOptions().GetSubstitutionOption(), cloudbuildpb.BuildOptions_ALLOW_LOOSE; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := build.GetOptions().GetPool().GetName(), "projects/p/locations/us-central1/workerPools/default"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := build.GetSubstitutions()["_LOG_NAME"], "runner-done"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := build.GetSubstitutions()["_IMAGE_TAG"], "latest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := (&RunnerSpec{Build: build}).Image(), "us-docker.pkg.dev/p/runners/default-runner:latest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// The template is not modified by the builds rendered from it.
+	build.Steps[0].Id = "changed"
+	if got, want := s.buildTemplate.GetSteps()[0].GetId(), "prime-cache"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/logging/logadmin"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// CloudLogging reads Cloud Build logs from Cloud Logging.
+type CloudLogging struct {
+	client *logadmin.Client
+}
+
+// NewCloudLogging creates a new instance of a CloudLogging client. The project
+// is only used as the default parent, each read names its own project.
+func NewCloudLogging(ctx context.Context, projectID string, opts ...option.ClientOption) (*CloudLogging, error) {
+	client, err := logadmin.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new logging client: %w", err)
+	}
+
+	return &CloudLogging{
+		client: client,
+	}, nil
+}
+
+// LatestEntryTime returns the timestamp of the newest log entry written by the
+// build, or the zero time if the build has not logged anything yet.
+func (cl *CloudLogging) LatestEntryTime(ctx context.Context, projectID, buildID string) (time.Time, error) {
+	it := cl.client.Entries(ctx,
+		logadmin.ResourceNames([]string{"projects/" + projectID}),
+		logadmin.Filter(buildLogFilter(buildID)),
+		logadmin.NewestFirst(),
+		logadmin.PageSize(1),
+	)
+
+	entry, err := it.Next()
+	if errors.Is(err, iterator.Done) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read build logs: %w", err)
+	}
+	return entry.Timestamp, nil
+}
+
+// TailLines returns up to the last n text lines written by the build, oldest
+// first.
+func (cl *CloudLogging) TailLines(ctx context.Context, projectID, buildID string, n int) ([]string, error) {
+	it := cl.client.Entries(ctx,
+		logadmin.ResourceNames([]string{"projects/" + projectID}),
+		logadmin.Filter(buildLogFilter(buildID)),
+		logadmin.NewestFirst(),
+		logadmin.PageSize(int32(n)),
+	)
+
+	lines := make([]string, 0, n)
+	for len(lines) < n {
+		entry, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build logs: %w", err)
+		}
+		if line, ok := entry.Payload.(string); ok {
+			lines = append(lines, line)
+		}
+	}
+
+	slices.Reverse(lines)
+	return lines, nil
+}
+
+// Close releases any resources held by the CloudLogging client.
+func (cl *CloudLogging) Close() error {
+	if err := cl.client.Close(); err != nil {
+		return fmt.Errorf("failed to close CloudLogging client: %w", err)
+	}
+	return nil
+}
+
+func buildLogFilter(buildID string) string {
+	return fmt.Sprintf(`resource.type="build" AND resource.labels.build_id=%q`, buildID)
+}
@@ -0,0 +1,45 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+type MockBuildLogReader struct {
+	latestEntryTimes map[string]time.Time
+	latestEntryErr   error
+	tailLines        map[string][]string
+	tailLinesErr     error
+}
+
+func (m *MockBuildLogReader) LatestEntryTime(ctx context.Context, projectID, buildID string) (time.Time, error) {
+	if m.latestEntryErr != nil {
+		return time.Time{}, m.latestEntryErr
+	}
+	return m.latestEntryTimes[buildID], nil
+}
+
+func (m *MockBuildLogReader) TailLines(ctx context.Context, projectID, buildID string, n int) ([]string, error) {
+	if m.tailLinesErr != nil {
+		return nil, m.tailLinesErr
+	}
+	return m.tailLines[buildID], nil
+}
+
+func (m *MockBuildLogReader) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+const (
+	cacheStepImage  = "gcr.io/google.com/cloudsdktool/cloud-sdk:slim"
+	cacheVolumeRoot = "/cache"
+)
+
+// addCacheSteps mounts the caches of the profile into the runner and wraps
+// the runner step with steps restoring the caches from, and saving them back
+// to, Cloud Storage.
+//
+// Each cache is a Cloud Build volume. Cloud Build backs volumes with named
+// Docker volumes on the worker, so the runner container started by the run
+// step mounts them by name. Caches are keyed by repository so jobs cannot
+// poison the caches of other repositories.
+func (s *Server) addCacheSteps(build *cloudbuildpb.Build, org, repo, profileName string, profile *RunnerProfile) {
+	if profile == nil || len(profile.Caches) == 0 || s.runnerCacheBucket == "" {
+		return
+	}
+
+	i := slices.IndexFunc(build.GetSteps(), func(step *cloudbuildpb.BuildStep) bool {
+		return step.GetId() == "run"
+	})
+	if i < 0 {
+		return
+	}
+	run := build.GetSteps()[i]
+
+	volumes := make([]*cloudbuildpb.Volume, 0, len(profile.Caches))
+	mounts := make([]string, 0, len(profile.Caches))
+	paths := make([]string, 0, len(profile.Caches))
+	restore := make([]string, 0, len(profile.Caches))
+	save := make([]string, 0, len(profile.Caches))
+	for _, c := range profile.Caches {
+		volume := "cache-" + c.Name
+		dir := fmt.Sprintf("%s/%s", cacheVolumeRoot, c.Name)
+		object := fmt.Sprintf("gs://%s/%s/%s/%s/%s.tar.gz", s.runnerCacheBucket, org, repo, profileName, c.Name)
+
+		volumes = append(volumes, &cloudbuildpb.Volume{Name: volume, Path: dir})
+		mounts = append(mounts, fmt.Sprintf("-v %s:%s", volume, c.Path))
+		paths = append(paths, c.Path)
+		restore = append(restore, fmt.Sprintf("(gcloud storage cat %s | tar -xzf - -C %s) || echo 'no cache restored for %s'", object, dir, c.Name))
+		save = append(save, fmt.Sprintf("tar -czf - -C %s . | gcloud storage cp - %s", dir, object))
+	}
+
+	run.Volumes = volumes
+	build.Substitutions["_CACHE_MOUNTS"] = strings.Join(mounts, " ")
+	build.Substitutions["_CACHE_PATHS"] = strings.Join(paths, ",")
+
+	// The caches are restored right before the run step and saved right
+	// after it, the other steps of a build template are kept.
+	build.Steps = slices.Concat(build.GetSteps()[:i], []*cloudbuildpb.BuildStep{
+		{
+			Id:           "restore-caches",
+			Name:         cacheStepImage,
+			Entrypoint:   "bash",
+			Args:         []string{"-c", strings.Join(restore, "\n")},
+			Volumes:      volumes,
+			AllowFailure: true,
+		},
+		run,
+		{
+			Id:           "save-caches",
+			Name:         cacheStepImage,
+			Entrypoint:   "bash",
+			Args:         []string{"-c", strings.Join(save, "\n")},
+			Volumes:      volumes,
+			AllowFailure: true,
+		},
+	}, build.GetSteps()[i+1:])
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// Image tracks, used as the track label of the runner image metrics.
+const (
+	imageTrackStable = "stable"
+	imageTrackCanary = "canary"
+)
+
+// imageTagRegexp matches Docker image tags.
+var imageTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// ImageCanary routes a share of the jobs of a profile to another runner image
+// tag, so a new image is validated on real jobs before it becomes the
+// RUNNER_IMAGE_TAG.
+type ImageCanary struct {
+	// Tag is the image tag the canary jobs run with.
+	Tag string `yaml:"tag"`
+
+	// Percent is the share of jobs, from 0 to 100, that run with the tag.
+	Percent int `yaml:"percent"`
+}
+
+func (c *ImageCanary) validate() error {
+	if !imageTagRegexp.MatchString(c.Tag) {
+		return fmt.Errorf("canary tag %q is not a valid image tag", c.Tag)
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("canary percent must be between 0 and 100, got %d", c.Percent)
+	}
+	return nil
+}
+
+// imageTrack returns the image tag and track of a runner of the profile. The
+// runner name decides the track, so redeliveries of a job's queued event pick
+// the same tag.
+func (s *Server) imageTrack(runnerName string, profile *RunnerProfile) (string, string) {
+	if profile == nil || profile.Canary == nil || profile.Canary.Percent == 0 {
+		return s.runnerImageTag, imageTrackStable
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(runnerName))
+	if int(h.Sum32()%100) < profile.Canary.Percent {
+		return profile.Canary.Tag, imageTrackCanary
+	}
+	return s.runnerImageTag, imageTrackStable
+}
+
+// canaryTags returns the canary image tags of all profiles, sorted.
+func (s *Server) canaryTags() []string {
+	var tags []string
+	for _, name := range slices.Sorted(maps.Keys(s.runnerProfiles)) {
+		p := s.runnerProfiles[name]
+		if p.Canary == nil || p.Canary.Percent == 0 || slices.Contains(tags, p.Canary.Tag) {
+			continue
+		}
+		tags = append(tags, p.Canary.Tag)
+	}
+	return tags
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImageTrack(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		profile     *RunnerProfile
+		wantCanary  int
+		wantMargin  int
+		wantTagOnly string
+	}{
+		{
+			name:        "no_profile",
+			wantTagOnly: "stable",
+		},
+		{
+			name:        "no_canary",
+			profile:     &RunnerProfile{},
+			wantTagOnly: "stable",
+		},
+		{
+			name:        "zero_percent",
+			profile:     &RunnerProfile{Canary: &ImageCanary{Tag: "canary", Percent: 0}},
+			wantTagOnly: "stable",
+		},
+		{
+			name:        "all",
+			profile:     &RunnerProfile{Canary: &ImageCanary{Tag: "canary", Percent: 100}},
+			wantTagOnly: "canary",
+		},
+		{
+			name:       "five_percent",
+			profile:    &RunnerProfile{Canary: &ImageCanary{Tag: "canary", Percent: 5}},
+			wantCanary: 500,
+			wantMargin: 150,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerImageTag: "stable"}
+
+			canary := 0
+			for i := range 10000 {
+				name := fmt.Sprintf("%s%d", runnerNamePrefix, 1000000+i)
+				tag, track := s.imageTrack(name, tc.profile)
+				if tc.wantTagOnly != "" && tag != tc.wantTagOnly {
+					t.Fatalf("expected tag %q to be %q", tag, tc.wantTagOnly)
+				}
+				if track == imageTrackCanary {
+					canary++
+				}
+
+				// The runner name decides the track.
+				if again, _ := s.imageTrack(name, tc.profile); again != tag {
+					t.Fatalf("expected tag %q to be %q for the same runner", again, tag)
+				}
+			}
+
+			if tc.wantTagOnly == "" && (canary < tc.wantCanary-tc.wantMargin || canary > tc.wantCanary+tc.wantMargin) {
+				t.Errorf("expected %d canary runners to be within %d of %d", canary, tc.wantMargin, tc.wantCanary)
+			}
+		})
+	}
+}
+
+func TestCanaryTags(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerProfiles: map[string]*RunnerProfile{
+			"go":      {Canary: &ImageCanary{Tag: "v2", Percent: 5}},
+			"default": {Canary: &ImageCanary{Tag: "v2", Percent: 10}},
+			"node":    {Canary: &ImageCanary{Tag: "v3", Percent: 1}},
+			"python":  {Canary: &ImageCanary{Tag: "v4", Percent: 0}},
+			"java":    {},
+		},
+	}
+
+	if diff := cmp.Diff([]string{"v2", "v3"}, s.canaryTags()); diff != "" {
+		t.Errorf("unexpected canary tags (-want, +got):\n%s", diff)
+	}
+}
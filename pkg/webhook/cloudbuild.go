@@ -16,10 +16,12 @@ package webhook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	cloudbuild "cloud.google.com/go/cloudbuild/apiv1/v2"
 	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"github.com/googleapis/gax-go/v2"
@@ -42,11 +44,77 @@ func NewCloudBuild(ctx context.Context, opts ...option.ClientOption) (*CloudBuil
 	}, nil
 }
 
-func (cb *CloudBuild) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) error {
-	if _, err := cb.client.CreateBuild(ctx, req); err != nil {
-		return fmt.Errorf("failed to create cloud build build: %w", err)
+// CreateBuild starts a build and returns the build as reported by the
+// operation metadata, without waiting for the build to finish.
+func (cb *CloudBuild) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	op, err := cb.client.CreateBuild(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud build build: %w", err)
 	}
-	return nil
+
+	md, err := op.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cloud build operation metadata: %w", err)
+	}
+	return md.GetBuild(), nil
+}
+
+// RunBuildTrigger runs a build trigger and returns the build it started as
+// reported by the operation metadata, without waiting for the build to finish.
+func (cb *CloudBuild) RunBuildTrigger(ctx context.Context, req *cloudbuildpb.RunBuildTriggerRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	op, err := cb.client.RunBuildTrigger(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run cloud build trigger: %w", err)
+	}
+
+	md, err := op.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cloud build operation metadata: %w", err)
+	}
+	return md.GetBuild(), nil
+}
+
+// GetBuild returns the current state of a build.
+func (cb *CloudBuild) GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	build, err := cb.client.GetBuild(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloud build build: %w", err)
+	}
+	return build, nil
+}
+
+// ListBuilds returns the builds matching the request, reading every page.
+func (cb *CloudBuild) ListBuilds(ctx context.Context, req *cloudbuildpb.ListBuildsRequest, opts ...gax.CallOption) ([]*cloudbuildpb.Build, error) {
+	var builds []*cloudbuildpb.Build
+	it := cb.client.ListBuilds(ctx, req, opts...)
+	for {
+		build, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return builds, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cloud build builds: %w", err)
+		}
+		builds = append(builds, build)
+	}
+}
+
+// CancelBuild cancels a build that has not finished yet.
+func (cb *CloudBuild) CancelBuild(ctx context.Context, req *cloudbuildpb.CancelBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	build, err := cb.client.CancelBuild(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel cloud build build: %w", err)
+	}
+	return build, nil
+}
+
+// GetWorkerPool returns the configuration of a private worker pool.
+func (cb *CloudBuild) GetWorkerPool(ctx context.Context, req *cloudbuildpb.GetWorkerPoolRequest, opts ...gax.CallOption) (*cloudbuildpb.WorkerPool, error) {
+	pool, err := cb.client.GetWorkerPool(ctx, req, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cloud build worker pool: %w", err)
+	}
+	return pool, nil
 }
 
 // Close releases any resources held by the CloudBuild client.
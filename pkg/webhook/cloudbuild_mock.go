@@ -16,6 +16,7 @@ package webhook
 
 import (
 	"context"
+	"fmt"
 
 	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
 
@@ -23,16 +24,82 @@ import (
 )
 
 type MockCloudBuildClient struct {
-	createBuildReq *cloudbuildpb.CreateBuildRequest
-	createBuildErr error
+	cancelBuildReqs []*cloudbuildpb.CancelBuildRequest
+	cancelBuildErr  error
+	createBuildReq  *cloudbuildpb.CreateBuildRequest
+	createBuildReqs []*cloudbuildpb.CreateBuildRequest
+	createBuildRes  *cloudbuildpb.Build
+	createBuildErr  error
+	// createBuildErrs are the errors of CreateBuild by parent, taking
+	// precedence over createBuildErr.
+	createBuildErrs map[string]error
+	getBuildRes     *cloudbuildpb.Build
+	getBuildErr     error
+	listBuildsReqs  []*cloudbuildpb.ListBuildsRequest
+	listBuildsRes   []*cloudbuildpb.Build
+	listBuildsErr   error
+	workerPools     map[string]*cloudbuildpb.WorkerPool
+	// runBuildTriggerReqs are the requests of RunBuildTrigger, which returns
+	// createBuildRes and createBuildErr like CreateBuild.
+	runBuildTriggerReqs []*cloudbuildpb.RunBuildTriggerRequest
 }
 
-func (m *MockCloudBuildClient) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) error {
+func (m *MockCloudBuildClient) CancelBuild(ctx context.Context, req *cloudbuildpb.CancelBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	m.cancelBuildReqs = append(m.cancelBuildReqs, req)
+	if m.cancelBuildErr != nil {
+		return nil, m.cancelBuildErr
+	}
+	return &cloudbuildpb.Build{Id: req.GetId(), Status: cloudbuildpb.Build_CANCELLED}, nil
+}
+
+func (m *MockCloudBuildClient) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
 	m.createBuildReq = req
+	m.createBuildReqs = append(m.createBuildReqs, req)
+	if err, ok := m.createBuildErrs[req.GetParent()]; ok {
+		if err != nil {
+			return nil, err
+		}
+	} else if m.createBuildErr != nil {
+		return nil, m.createBuildErr
+	}
+	if m.createBuildRes != nil {
+		return m.createBuildRes, nil
+	}
+	return &cloudbuildpb.Build{Id: "mock-build-id"}, nil
+}
+
+func (m *MockCloudBuildClient) GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	if m.getBuildErr != nil {
+		return nil, m.getBuildErr
+	}
+	return m.getBuildRes, nil
+}
+
+func (m *MockCloudBuildClient) ListBuilds(ctx context.Context, req *cloudbuildpb.ListBuildsRequest, opts ...gax.CallOption) ([]*cloudbuildpb.Build, error) {
+	m.listBuildsReqs = append(m.listBuildsReqs, req)
+	if m.listBuildsErr != nil {
+		return nil, m.listBuildsErr
+	}
+	return m.listBuildsRes, nil
+}
+
+func (m *MockCloudBuildClient) GetWorkerPool(ctx context.Context, req *cloudbuildpb.GetWorkerPoolRequest, opts ...gax.CallOption) (*cloudbuildpb.WorkerPool, error) {
+	pool, ok := m.workerPools[req.GetName()]
+	if !ok {
+		return nil, fmt.Errorf("worker pool %q not found", req.GetName())
+	}
+	return pool, nil
+}
+
+func (m *MockCloudBuildClient) RunBuildTrigger(ctx context.Context, req *cloudbuildpb.RunBuildTriggerRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	m.runBuildTriggerReqs = append(m.runBuildTriggerReqs, req)
 	if m.createBuildErr != nil {
-		return m.createBuildErr
+		return nil, m.createBuildErr
 	}
-	return nil
+	if m.createBuildRes != nil {
+		return m.createBuildRes, nil
+	}
+	return &cloudbuildpb.Build{Id: "mock-build-id"}, nil
 }
 
 func (m *MockCloudBuildClient) Close() error {
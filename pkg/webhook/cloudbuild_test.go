@@ -0,0 +1,70 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/github_actions_on_gcp/pkg/testing/fakecloudbuild"
+)
+
+func TestCloudBuild(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	fake, opts := fakecloudbuild.Start(t)
+
+	cb, err := NewCloudBuild(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cb.Close() })
+
+	created, err := cb.CreateBuild(ctx, &cloudbuildpb.CreateBuildRequest{
+		Parent:    "projects/p/locations/us-central1",
+		ProjectId: "p",
+		Build:     &cloudbuildpb.Build{Tags: []string{"delivery-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetId() == "" {
+		t.Fatalf("expected the created build to be read from the operation metadata")
+	}
+
+	if err := fake.SetBuildStatus(created.GetId(), cloudbuildpb.Build_FAILURE); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cb.GetBuild(ctx, &cloudbuildpb.GetBuildRequest{ProjectId: "p", Id: created.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.GetStatus(), cloudbuildpb.Build_FAILURE; got != want {
+		t.Errorf("expected status %s to be %s", got, want)
+	}
+
+	if _, err := cb.CancelBuild(ctx, &cloudbuildpb.CancelBuildRequest{ProjectId: "p", Id: created.GetId()}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected cancelling a finished build to fail, got %v", err)
+	}
+
+	fake.FailNext(fakecloudbuild.MethodCreateBuild, status.Error(codes.PermissionDenied, "denied"))
+	if _, err := cb.CreateBuild(ctx, &cloudbuildpb.CreateBuildRequest{ProjectId: "p", Build: &cloudbuildpb.Build{}}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected the scripted failure to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+const (
+	// cloudEventsContentType is the content type of a structured mode
+	// CloudEvent.
+	cloudEventsContentType = "application/cloudevents+json"
+
+	// Extension attributes used by relays to carry the original GitHub delivery
+	// headers. CloudEvents extension names are restricted to lowercase
+	// alphanumerics.
+	ceGitHubEventExt       = "githubevent"
+	ceGitHubDeliveryExt    = "githubdelivery"
+	ceGitHubSignatureExt   = "githubsignature256"
+	ceBinaryModeHeaderBase = "Ce-"
+
+	// maxCloudEventBytes bounds the size of a CloudEvent envelope, GitHub caps
+	// webhook payloads at 25MB.
+	maxCloudEventBytes = 25 << 20
+)
+
+// cloudEvent is the subset of a structured mode CloudEvent envelope that is
+// needed to reconstruct the original GitHub delivery.
+type cloudEvent struct {
+	SpecVersion        string          `json:"specversion"`
+	ID                 string          `json:"id"`
+	Type               string          `json:"type"`
+	Data               json.RawMessage `json:"data"`
+	DataBase64         string          `json:"data_base64"`
+	GitHubEvent        string          `json:"githubevent"`
+	GitHubDelivery     string          `json:"githubdelivery"`
+	GitHubSignature256 string          `json:"githubsignature256"`
+}
+
+// handleCloudEvent accepts GitHub deliveries wrapped in a CloudEvent (for
+// example when forwarded by Eventarc or an internal relay), unwraps them and
+// runs them through the same pipeline as direct webhook deliveries.
+func (s *Server) handleCloudEvent() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+
+		req, err := unwrapCloudEvent(r)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to unwrap cloud event", "error", err)
+			s.writeResponse(w, r, &apiResponse{http.StatusBadRequest, "invalid cloud event", err})
+			return
+		}
+
+		req = withDeliveryID(req)
+		req = req.WithContext(withDeliveryBudget(req.Context(), s.deliveryBudget, time.Now()))
+		s.writeResponse(w, req, s.processRequest(req))
+	})
+}
+
+// unwrapCloudEvent converts a binary or structured mode CloudEvent into an
+// equivalent GitHub webhook request carrying the original payload and headers.
+func unwrapCloudEvent(r *http.Request) (*http.Request, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCloudEventBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	var data []byte
+	var eventType, deliveryID, signature string
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == cloudEventsContentType {
+		var ce cloudEvent
+		if err := json.Unmarshal(body, &ce); err != nil {
+			return nil, fmt.Errorf("failed to parse structured cloud event: %w", err)
+		}
+		if ce.SpecVersion == "" {
+			return nil, fmt.Errorf("structured cloud event is missing specversion")
+		}
+
+		switch {
+		case ce.DataBase64 != "":
+			b, err := base64.StdEncoding.DecodeString(ce.DataBase64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data_base64: %w", err)
+			}
+			data = b
+		case len(ce.Data) > 0:
+			// The raw message preserves the exact bytes GitHub signed.
+			data = ce.Data
+		}
+
+		eventType = ce.GitHubEvent
+		deliveryID = ce.GitHubDelivery
+		if deliveryID == "" {
+			deliveryID = ce.ID
+		}
+		signature = ce.GitHubSignature256
+	} else {
+		if r.Header.Get(ceBinaryModeHeaderBase+"Specversion") == "" {
+			return nil, fmt.Errorf("request is neither a structured nor a binary mode cloud event")
+		}
+
+		data = body
+		eventType = firstHeader(r.Header, ceBinaryModeHeaderBase+ceGitHubEventExt, "X-GitHub-Event")
+		deliveryID = firstHeader(r.Header, ceBinaryModeHeaderBase+ceGitHubDeliveryExt, "X-GitHub-Delivery", ceBinaryModeHeaderBase+"Id")
+		signature = firstHeader(r.Header, ceBinaryModeHeaderBase+ceGitHubSignatureExt, "X-Hub-Signature-256")
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("cloud event has no data")
+	}
+	if eventType == "" {
+		return nil, fmt.Errorf("cloud event is missing the %q extension", ceGitHubEventExt)
+	}
+	if signature == "" {
+		return nil, fmt.Errorf("cloud event is missing the %q extension", ceGitHubSignatureExt)
+	}
+
+	req := r.Clone(r.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.Header = make(http.Header)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", eventType)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	if deliveryID != "" {
+		req.Header.Set("X-GitHub-Delivery", deliveryID)
+	}
+	return req, nil
+}
+
+// firstHeader returns the first non-empty value among the given header keys.
+func firstHeader(h http.Header, keys ...string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(h.Get(k)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
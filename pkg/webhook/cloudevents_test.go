@@ -0,0 +1,243 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestHandleCloudEvent(t *testing.T) {
+	t.Parallel()
+
+	action := "in_progress"
+	runID := int64(456)
+	jobID := int64(789)
+	payload, err := json.Marshal(&github.WorkflowJobEvent{
+		Action: &action,
+		WorkflowJob: &github.WorkflowJob{
+			RunID:  &runID,
+			ID:     &jobID,
+			Labels: []string{defaultRunnerLabel},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := fmt.Sprintf("sha256=%s", createSignature([]byte(serverGitHubWebhookSecret), payload))
+
+	structured := func(t *testing.T, envelope map[string]any) []byte {
+		t.Helper()
+		b, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	cases := []struct {
+		name          string
+		body          []byte
+		headers       map[string]string
+		expStatusCode int
+		expRespBody   string
+	}{
+		{
+			name: "structured_mode",
+			body: structured(t, map[string]any{
+				"specversion":        "1.0",
+				"id":                 "ce-id",
+				"type":               "com.github.workflow_job",
+				"source":             "//github.com",
+				"data":               json.RawMessage(payload),
+				"githubevent":        "workflow_job",
+				"githubdelivery":     "delivery-id",
+				"githubsignature256": signature,
+			}),
+			headers: map[string]string{
+				ContentTypeHeader: cloudEventsContentType,
+			},
+			expStatusCode: http.StatusOK,
+			expRespBody:   "workflow job in progress event logged",
+		},
+		{
+			name: "structured_mode_base64",
+			body: structured(t, map[string]any{
+				"specversion":        "1.0",
+				"id":                 "ce-id",
+				"data_base64":        base64.StdEncoding.EncodeToString(payload),
+				"githubevent":        "workflow_job",
+				"githubsignature256": signature,
+			}),
+			headers: map[string]string{
+				ContentTypeHeader: cloudEventsContentType + "; charset=utf-8",
+			},
+			expStatusCode: http.StatusOK,
+			expRespBody:   "workflow job in progress event logged",
+		},
+		{
+			name: "binary_mode",
+			body: payload,
+			headers: map[string]string{
+				ContentTypeHeader:        "application/json",
+				"Ce-Specversion":         "1.0",
+				"Ce-Id":                  "ce-id",
+				"Ce-Githubevent":         "workflow_job",
+				"Ce-Githubsignature256":  signature,
+				"Ce-Githubdelivery":      "delivery-id",
+				"X-Unrelated-Relay-Info": "ignored",
+			},
+			expStatusCode: http.StatusOK,
+			expRespBody:   "workflow job in progress event logged",
+		},
+		{
+			name: "binary_mode_forwarded_github_headers",
+			body: payload,
+			headers: map[string]string{
+				ContentTypeHeader:     "application/json",
+				"Ce-Specversion":      "1.0",
+				EventTypeHeader:       "workflow_job",
+				SHA256SignatureHeader: signature,
+			},
+			expStatusCode: http.StatusOK,
+			expRespBody:   "workflow job in progress event logged",
+		},
+		{
+			name: "bad_signature",
+			body: payload,
+			headers: map[string]string{
+				ContentTypeHeader:       "application/json",
+				"Ce-Specversion":        "1.0",
+				"Ce-Githubevent":        "workflow_job",
+				"Ce-Githubsignature256": "sha256=deadbeef",
+			},
+			expStatusCode: http.StatusUnauthorized,
+			expRespBody:   "invalid payload signature",
+		},
+		{
+			name: "missing_event_extension",
+			body: payload,
+			headers: map[string]string{
+				ContentTypeHeader:       "application/json",
+				"Ce-Specversion":        "1.0",
+				"Ce-Githubsignature256": signature,
+			},
+			expStatusCode: http.StatusBadRequest,
+			expRespBody:   "invalid cloud event",
+		},
+		{
+			name: "not_a_cloud_event",
+			body: payload,
+			headers: map[string]string{
+				ContentTypeHeader: "application/json",
+			},
+			expStatusCode: http.StatusBadRequest,
+			expRespBody:   "invalid cloud event",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/cloudevents", bytes.NewReader(tc.body))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+
+			srv := &Server{
+				webhookSecret: []byte(serverGitHubWebhookSecret),
+			}
+			srv.handleCloudEvent().ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.expStatusCode; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := strings.TrimSpace(resp.Body.String()), tc.expRespBody; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+// deadlineSecretStore records the deadline of the contexts secrets are
+// deleted with.
+type deadlineSecretStore struct {
+	MockSecretStore
+
+	deadlines []time.Time
+}
+
+func (d *deadlineSecretStore) DeleteSecret(ctx context.Context, name string) error {
+	deadline, _ := ctx.Deadline()
+	d.deadlines = append(d.deadlines, deadline)
+	return d.MockSecretStore.DeleteSecret(ctx, name)
+}
+
+func TestHandleCloudEvent_DeliveryBudget(t *testing.T) {
+	t.Parallel()
+
+	payload, err := json.Marshal(&github.WorkflowJobEvent{
+		Action: github.Ptr("in_progress"),
+		WorkflowJob: &github.WorkflowJob{
+			RunID:      github.Ptr(int64(456)),
+			ID:         github.Ptr(int64(789)),
+			Labels:     []string{defaultRunnerLabel},
+			RunnerName: github.Ptr("GCP-789"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/cloudevents", bytes.NewReader(payload))
+	req.Header.Set(ContentTypeHeader, "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Githubevent", "workflow_job")
+	req.Header.Set("Ce-Githubsignature256", fmt.Sprintf("sha256=%s", createSignature([]byte(serverGitHubWebhookSecret), payload)))
+	resp := httptest.NewRecorder()
+
+	secrets := &deadlineSecretStore{}
+	srv := &Server{
+		deliveryBudget:  10 * time.Second,
+		runnerProjectID: "runner-project",
+		secrets:         secrets,
+		webhookSecret:   []byte(serverGitHubWebhookSecret),
+	}
+	srv.handleCloudEvent().ServeHTTP(resp, req)
+	end := time.Now()
+
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+	}
+	// The external calls of the delivery are bounded by its budget.
+	if got, want := len(secrets.deadlines), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if deadline := secrets.deadlines[0]; deadline.IsZero() || deadline.After(end.Add(10*time.Second)) {
+		t.Errorf("expected a deadline within the delivery budget, got %s", deadline)
+	}
+}
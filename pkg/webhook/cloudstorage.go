@@ -0,0 +1,185 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/storage/v1"
+)
+
+// maxSettingsObjectSize bounds the size of the shared settings object.
+const maxSettingsObjectSize = 1 << 20
+
+// SettingsObject is a generation of the shared settings object.
+type SettingsObject struct {
+	Data       []byte
+	Generation int64
+}
+
+// errObjectNotFound is returned when a Cloud Storage object does not exist.
+var errObjectNotFound = errors.New("object not found")
+
+// CacheObject is the metadata of an object of the actions cache bucket.
+type CacheObject struct {
+	Name     string
+	Size     int64
+	Updated  time.Time
+	Metadata map[string]string
+}
+
+// ActionsCacheStore adheres to the interaction the webhook service has with
+// Cloud Storage to store the caches of GitHub Actions jobs.
+type ActionsCacheStore interface {
+	ReadObject(ctx context.Context, bucket, object string, limit int64) ([]byte, error)
+	WriteObject(ctx context.Context, bucket, object string, r io.Reader, metadata map[string]string) error
+	ListObjects(ctx context.Context, bucket, prefix string) ([]*CacheObject, error)
+	ComposeObjects(ctx context.Context, bucket, object string, sources []string, metadata map[string]string) error
+	DeleteObject(ctx context.Context, bucket, object string) error
+}
+
+// ForecastStore adheres to the interaction the webhook service has with Cloud
+// Storage to keep the demand forecast across restarts.
+type ForecastStore interface {
+	ReadObject(ctx context.Context, bucket, object string, limit int64) ([]byte, error)
+	WriteObject(ctx context.Context, bucket, object string, r io.Reader, metadata map[string]string) error
+}
+
+// CloudStorage reads the shared settings object from Cloud Storage, and
+// stores the caches of GitHub Actions jobs.
+type CloudStorage struct {
+	svc *storage.Service
+}
+
+// NewCloudStorage creates a new instance of a Cloud Storage client.
+func NewCloudStorage(ctx context.Context, opts ...option.ClientOption) (*CloudStorage, error) {
+	svc, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new cloud storage client: %w", err)
+	}
+
+	return &CloudStorage{
+		svc: svc,
+	}, nil
+}
+
+// ReadSettings returns the current generation of the object, or nil if it is
+// still the given generation. Only the object metadata is fetched when the
+// object did not change.
+func (cs *CloudStorage) ReadSettings(ctx context.Context, bucket, object string, generation int64) (*SettingsObject, error) {
+	obj, err := cs.svc.Objects.Get(bucket, object).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object metadata: %w", err)
+	}
+	if obj.Generation == generation {
+		return nil, nil
+	}
+
+	resp, err := cs.svc.Objects.Get(bucket, object).Generation(obj.Generation).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxSettingsObjectSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return &SettingsObject{Data: b, Generation: obj.Generation}, nil
+}
+
+// ReadObject returns at most limit bytes of the object, or errObjectNotFound.
+func (cs *CloudStorage) ReadObject(ctx context.Context, bucket, object string, limit int64) ([]byte, error) {
+	resp, err := cs.svc.Objects.Get(bucket, object).Context(ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", objectError(err))
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	return b, nil
+}
+
+// WriteObject uploads the object with the given custom metadata.
+func (cs *CloudStorage) WriteObject(ctx context.Context, bucket, object string, r io.Reader, metadata map[string]string) error {
+	if _, err := cs.svc.Objects.Insert(bucket, &storage.Object{
+		Name:     object,
+		Metadata: metadata,
+	}).Media(r).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+// ListObjects returns the objects whose names start with the prefix.
+func (cs *CloudStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]*CacheObject, error) {
+	var objects []*CacheObject
+	if err := cs.svc.Objects.List(bucket).Prefix(prefix).Pages(ctx, func(page *storage.Objects) error {
+		for _, obj := range page.Items {
+			updated, _ := time.Parse(time.RFC3339, obj.Updated)
+			objects = append(objects, &CacheObject{
+				Name:     obj.Name,
+				Size:     int64(obj.Size),
+				Updated:  updated,
+				Metadata: obj.Metadata,
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return objects, nil
+}
+
+// ComposeObjects concatenates at most 32 source objects into the object.
+func (cs *CloudStorage) ComposeObjects(ctx context.Context, bucket, object string, sources []string, metadata map[string]string) error {
+	req := &storage.ComposeRequest{
+		Destination: &storage.Object{Metadata: metadata},
+	}
+	for _, src := range sources {
+		req.SourceObjects = append(req.SourceObjects, &storage.ComposeRequestSourceObjects{Name: src})
+	}
+	if _, err := cs.svc.Objects.Compose(bucket, object, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to compose object: %w", objectError(err))
+	}
+	return nil
+}
+
+// DeleteObject deletes the object, or returns errObjectNotFound.
+func (cs *CloudStorage) DeleteObject(ctx context.Context, bucket, object string) error {
+	if err := cs.svc.Objects.Delete(bucket, object).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete object: %w", objectError(err))
+	}
+	return nil
+}
+
+// objectError wraps errObjectNotFound into not found errors of the API.
+func objectError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return fmt.Errorf("%w: %w", errObjectNotFound, err)
+	}
+	return err
+}
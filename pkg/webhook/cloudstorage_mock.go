@@ -0,0 +1,174 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockSettingsStore serves Object, or Err, and counts the reads.
+type MockSettingsStore struct {
+	mu     sync.Mutex
+	Object *SettingsObject
+	Err    error
+	Reads  int
+}
+
+func (m *MockSettingsStore) ReadSettings(ctx context.Context, bucket, object string, generation int64) (*SettingsObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Reads++
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Object == nil || m.Object.Generation == generation {
+		return nil, nil
+	}
+	return m.Object, nil
+}
+
+// MockActionsCacheStore keeps the objects of a single bucket in memory. Objects
+// are updated one second apart, in the order they are written.
+type MockActionsCacheStore struct {
+	mu      sync.Mutex
+	objects map[string]*mockCacheObject
+	writes  int64
+
+	WriteErr error
+}
+
+type mockCacheObject struct {
+	data     []byte
+	updated  time.Time
+	metadata map[string]string
+}
+
+func (m *MockActionsCacheStore) put(object string, data []byte, metadata map[string]string) {
+	if m.objects == nil {
+		m.objects = make(map[string]*mockCacheObject)
+	}
+	m.writes++
+	m.objects[object] = &mockCacheObject{
+		data:     data,
+		updated:  time.Unix(m.writes, 0).UTC(),
+		metadata: maps.Clone(metadata),
+	}
+}
+
+// Object returns the contents of the object and whether it exists.
+func (m *MockActionsCacheStore) Object(object string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[object]
+	if !ok {
+		return nil, false
+	}
+	return obj.data, true
+}
+
+// Names returns the sorted names of the stored objects.
+func (m *MockActionsCacheStore) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(m.objects))
+}
+
+func (m *MockActionsCacheStore) ReadObject(ctx context.Context, bucket, object string, limit int64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[object]
+	if !ok {
+		return nil, fmt.Errorf("failed to download object: %w", errObjectNotFound)
+	}
+	return obj.data[:min(int64(len(obj.data)), limit)], nil
+}
+
+func (m *MockActionsCacheStore) WriteObject(ctx context.Context, bucket, object string, r io.Reader, metadata map[string]string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.WriteErr != nil {
+		return m.WriteErr
+	}
+	m.put(object, data, metadata)
+	return nil
+}
+
+func (m *MockActionsCacheStore) ListObjects(ctx context.Context, bucket, prefix string) ([]*CacheObject, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var objects []*CacheObject
+	for _, name := range slices.Sorted(maps.Keys(m.objects)) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		obj := m.objects[name]
+		objects = append(objects, &CacheObject{
+			Name:     name,
+			Size:     int64(len(obj.data)),
+			Updated:  obj.updated,
+			Metadata: maps.Clone(obj.metadata),
+		})
+	}
+	return objects, nil
+}
+
+func (m *MockActionsCacheStore) ComposeObjects(ctx context.Context, bucket, object string, sources []string, metadata map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(sources) > maxComposeSources {
+		return fmt.Errorf("failed to compose object: too many source objects")
+	}
+	var buf bytes.Buffer
+	for _, src := range sources {
+		obj, ok := m.objects[src]
+		if !ok {
+			return fmt.Errorf("failed to compose object: %w", errObjectNotFound)
+		}
+		buf.Write(obj.data)
+	}
+	m.put(object, buf.Bytes(), metadata)
+	return nil
+}
+
+func (m *MockActionsCacheStore) DeleteObject(ctx context.Context, bucket, object string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[object]; !ok {
+		return fmt.Errorf("failed to delete object: %w", errObjectNotFound)
+	}
+	delete(m.objects, object)
+	return nil
+}
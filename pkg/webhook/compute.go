@@ -0,0 +1,157 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// ComputeEngine creates and deletes runner VMs, standalone or in managed
+// instance groups.
+type ComputeEngine struct {
+	svc *compute.Service
+}
+
+// NewComputeEngine creates a new instance of a Compute Engine client.
+func NewComputeEngine(ctx context.Context, opts ...option.ClientOption) (*ComputeEngine, error) {
+	svc, err := compute.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new compute client: %w", err)
+	}
+
+	return &ComputeEngine{
+		svc: svc,
+	}, nil
+}
+
+// CreateInstance adds an instance with the given name to the zonal managed
+// instance group, with the metadata preserved in its per-instance
+// configuration. It does not wait for the instance to start.
+func (c *ComputeEngine) CreateInstance(ctx context.Context, group *InstanceGroup, name string, metadata map[string]string) error {
+	req := &compute.InstanceGroupManagersCreateInstancesRequest{
+		Instances: []*compute.PerInstanceConfig{
+			{
+				Name:           name,
+				PreservedState: &compute.PreservedState{Metadata: metadata},
+			},
+		},
+	}
+	if _, err := c.svc.InstanceGroupManagers.CreateInstances(group.Project, group.Zone, group.Name, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to create instance %s in %s: %w", name, group, err)
+	}
+	return nil
+}
+
+// DeleteInstance deletes the instance with the given name from the zonal
+// managed instance group, reducing its target size. It does not wait for the
+// instance to stop.
+func (c *ComputeEngine) DeleteInstance(ctx context.Context, group *InstanceGroup, name string) error {
+	req := &compute.InstanceGroupManagersDeleteInstancesRequest{
+		Instances:                      []string{fmt.Sprintf("zones/%s/instances/%s", group.Zone, name)},
+		SkipInstancesOnValidationError: true,
+	}
+	if _, err := c.svc.InstanceGroupManagers.DeleteInstances(group.Project, group.Zone, group.Name, req).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete instance %s from %s: %w", name, group, err)
+	}
+	return nil
+}
+
+// CreateVM creates the VM from its instance template, with the metadata and
+// labels of the VM added to those of the template. When the VM has a maximum
+// run duration, Compute Engine deletes it once the duration elapses, and when
+// it runs on spot capacity, once it is preempted. In both cases the scheduling
+// of the template is replaced. A GPU of the VM replaces the accelerators of
+// the template. A tool cache disk is added to the disks of the template, as
+// its device name is not one of theirs. It does not wait for the VM to start.
+func (c *ComputeEngine) CreateVM(ctx context.Context, vm *RunnerVM) error {
+	instance := &compute.Instance{
+		Name:   vm.Name,
+		Labels: vm.Labels,
+	}
+	if len(vm.Metadata) > 0 {
+		instance.Metadata = &compute.Metadata{}
+		for _, key := range slices.Sorted(maps.Keys(vm.Metadata)) {
+			instance.Metadata.Items = append(instance.Metadata.Items, &compute.MetadataItems{
+				Key:   key,
+				Value: googleapi.String(vm.Metadata[key]),
+			})
+		}
+	}
+	if vm.MaxRunSeconds > 0 || vm.Spot {
+		instance.Scheduling = &compute.Scheduling{
+			InstanceTerminationAction: "DELETE",
+		}
+	}
+	if vm.MaxRunSeconds > 0 {
+		instance.Scheduling.MaxRunDuration = &compute.Duration{Seconds: vm.MaxRunSeconds}
+	}
+	if vm.Spot {
+		instance.Scheduling.ProvisioningModel = "SPOT"
+	}
+	if vm.Accelerator != "" {
+		instance.GuestAccelerators = []*compute.AcceleratorConfig{{
+			AcceleratorType:  fmt.Sprintf("zones/%s/acceleratorTypes/%s", vm.Zone, vm.Accelerator),
+			AcceleratorCount: 1,
+		}}
+		// VMs with GPUs cannot live migrate.
+		if instance.Scheduling == nil {
+			instance.Scheduling = &compute.Scheduling{}
+		}
+		instance.Scheduling.OnHostMaintenance = "TERMINATE"
+	}
+
+	if vm.ToolcacheSnapshot != "" {
+		instance.Disks = []*compute.AttachedDisk{{
+			DeviceName: vmToolcacheDevice,
+			AutoDelete: true,
+			InitializeParams: &compute.AttachedDiskInitializeParams{
+				SourceSnapshot: vm.ToolcacheSnapshot,
+			},
+		}}
+	}
+
+	call := c.svc.Instances.Insert(vm.Project, vm.Zone, instance).SourceInstanceTemplate(vm.Template)
+	if _, err := call.Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to create vm %s in %s/%s: %w", vm.Name, vm.Project, vm.Zone, err)
+	}
+	return nil
+}
+
+// VMPreempted reports whether Compute Engine preempted the spot VM, looking for
+// the preemption operation of the VM, which outlives the VM.
+func (c *ComputeEngine) VMPreempted(ctx context.Context, project, zone, name string) (bool, error) {
+	filter := fmt.Sprintf(`(operationType = "compute.instances.preempted") AND `+
+		`(targetLink = "https://www.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s")`, project, zone, name)
+	ops, err := c.svc.ZoneOperations.List(project, zone).Filter(filter).MaxResults(1).Context(ctx).Do()
+	if err != nil {
+		return false, fmt.Errorf("failed to list preemptions of vm %s in %s/%s: %w", name, project, zone, err)
+	}
+	return len(ops.Items) > 0, nil
+}
+
+// DeleteVM deletes the VM. It does not wait for the VM to stop.
+func (c *ComputeEngine) DeleteVM(ctx context.Context, project, zone, name string) error {
+	if _, err := c.svc.Instances.Delete(project, zone, name).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete vm %s in %s/%s: %w", name, project, zone, err)
+	}
+	return nil
+}
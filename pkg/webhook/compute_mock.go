@@ -0,0 +1,97 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MockInstanceGroupClient records the instances created and deleted, failing
+// with CreateErr and DeleteErr.
+type MockInstanceGroupClient struct {
+	CreateErr error
+	DeleteErr error
+
+	mu      sync.Mutex
+	Created map[string]map[string]string
+	Deleted []string
+}
+
+func (m *MockInstanceGroupClient) CreateInstance(ctx context.Context, group *InstanceGroup, name string, metadata map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateErr != nil {
+		return m.CreateErr
+	}
+	if m.Created == nil {
+		m.Created = make(map[string]map[string]string)
+	}
+	m.Created[name] = metadata
+	return nil
+}
+
+func (m *MockInstanceGroupClient) DeleteInstance(ctx context.Context, group *InstanceGroup, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Deleted = append(m.Deleted, name)
+	return m.DeleteErr
+}
+
+// MockVMClient records the VMs created and deleted, failing with CreateErr and
+// DeleteErr. The VMs in Preempted were preempted, and checking fails with
+// PreemptedErr.
+type MockVMClient struct {
+	CreateErr    error
+	DeleteErr    error
+	Preempted    map[string]bool
+	PreemptedErr error
+
+	mu      sync.Mutex
+	Created []*RunnerVM
+	Deleted []string
+}
+
+func (m *MockVMClient) CreateVM(ctx context.Context, vm *RunnerVM) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateErr != nil {
+		return m.CreateErr
+	}
+	m.Created = append(m.Created, vm)
+	return nil
+}
+
+func (m *MockVMClient) VMPreempted(ctx context.Context, project, zone, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.PreemptedErr != nil {
+		return false, m.PreemptedErr
+	}
+	return m.Preempted[fmt.Sprintf("projects/%s/zones/%s/instances/%s", project, zone, name)], nil
+}
+
+func (m *MockVMClient) DeleteVM(ctx context.Context, project, zone, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Deleted = append(m.Deleted, fmt.Sprintf("projects/%s/zones/%s/instances/%s", project, zone, name))
+	return m.DeleteErr
+}
@@ -17,6 +17,9 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/abcxyz/pkg/cfgloader"
 	"github.com/abcxyz/pkg/cli"
@@ -26,20 +29,166 @@ import (
 // Config defines the set of environment variables required
 // for running the webhook service.
 type Config struct {
-	Environment               string `env:"ENVIRONMENT,default=production"`
-	GitHubAPIBaseURL          string `env:"GITHUB_API_BASE_URL,default=https://api.github.com"`
-	GitHubAppID               string `env:"GITHUB_APP_ID,required"`
-	GitHubWebhookKeyMountPath string `env:"WEBHOOK_KEY_MOUNT_PATH,required"`
-	GitHubWebhookKeyName      string `env:"WEBHOOK_KEY_NAME,required"`
-	KMSAppPrivateKeyID        string `env:"KMS_APP_PRIVATE_KEY_ID,required"`
-	Port                      string `env:"PORT,default=8080"`
-	RunnerImageName           string `env:"RUNNER_IMAGE_NAME,default=default-runner"`
-	RunnerImageTag            string `env:"RUNNER_IMAGE_TAG,default=latest"`
-	RunnerLocation            string `env:"RUNNER_LOCATION,required"`
-	RunnerProjectID           string `env:"RUNNER_PROJECT_ID,required"`
-	RunnerRepositoryID        string `env:"RUNNER_REPOSITORY_ID,required"`
-	RunnerServiceAccount      string `env:"RUNNER_SERVICE_ACCOUNT,required"`
-	RunnerWorkerPoolID        string `env:"RUNNER_WORKER_POOL_ID"`
+	AdminTokenPath               string            `env:"ADMIN_TOKEN_PATH"`
+	CloudProfiler                bool              `env:"CLOUD_PROFILER"`
+	CloudProfilerProjectID       string            `env:"CLOUD_PROFILER_PROJECT_ID"`
+	CreateBuildTimeout           time.Duration     `env:"CREATE_BUILD_TIMEOUT,default=5s"`
+	DeliveryArchiveSize          int               `env:"DELIVERY_ARCHIVE_SIZE,default=200"`
+	DeliveryBudget               time.Duration     `env:"DELIVERY_BUDGET,default=9s"`
+	DispatchAPIAudience          string            `env:"DISPATCH_API_AUDIENCE"`
+	DispatchAPICallers           []string          `env:"DISPATCH_API_CALLERS"`
+	DispatchPolicyPath           string            `env:"DISPATCH_POLICY_PATH"`
+	Environment                  string            `env:"ENVIRONMENT,default=production"`
+	GitHubAPIBaseURL             string            `env:"GITHUB_API_BASE_URL,default=https://api.github.com"`
+	GitHubAppFallbackKeyPath     string            `env:"GITHUB_APP_FALLBACK_PRIVATE_KEY_PATH"`
+	GitHubAppID                  string            `env:"GITHUB_APP_ID,required"`
+	GitHubCABundlePath           string            `env:"GITHUB_CA_BUNDLE_PATH"`
+	GitHubCallTimeout            time.Duration     `env:"GITHUB_CALL_TIMEOUT,default=3s"`
+	GitHubProxyURL               string            `env:"GITHUB_PROXY_URL"`
+	GitHubWebhookKeyMountPath    string            `env:"WEBHOOK_KEY_MOUNT_PATH,required"`
+	GitHubWebhookKeyName         string            `env:"WEBHOOK_KEY_NAME,required"`
+	GRPCPort                     string            `env:"GRPC_PORT"`
+	GoogleChatRateInterval       time.Duration     `env:"GOOGLE_CHAT_RATE_INTERVAL,default=1m"`
+	GoogleChatWebhookURL         string            `env:"GOOGLE_CHAT_WEBHOOK_URL"`
+	GoogleCloudCABundlePath      string            `env:"GOOGLE_CLOUD_CA_BUNDLE_PATH"`
+	GoogleCloudProxyURL          string            `env:"GOOGLE_CLOUD_PROXY_URL"`
+	KMSAppFallbackPrivateKeyID   string            `env:"KMS_APP_FALLBACK_PRIVATE_KEY_ID"`
+	KMSAppPrivateKeyID           string            `env:"KMS_APP_PRIVATE_KEY_ID,required"`
+	LifecycleEventsTopic         string            `env:"LIFECYCLE_EVENTS_TOPIC"`
+	PagerDutyFailureRate         float64           `env:"PAGERDUTY_FAILURE_RATE_THRESHOLD,default=0.5"`
+	PagerDutyMinDispatches       int               `env:"PAGERDUTY_MIN_DISPATCHES,default=5"`
+	PagerDutyQueueAge            time.Duration     `env:"PAGERDUTY_QUEUE_AGE_THRESHOLD,default=15m"`
+	PagerDutyRoutingKey          string            `env:"PAGERDUTY_ROUTING_KEY"`
+	PagerDutySustainPeriod       time.Duration     `env:"PAGERDUTY_SUSTAIN_PERIOD,default=10m"`
+	PagerDutyWindow              time.Duration     `env:"PAGERDUTY_WINDOW,default=5m"`
+	Port                         string            `env:"PORT,default=8080"`
+	QueueTTL                     time.Duration     `env:"QUEUE_TTL"`
+	RecommendationInterval       time.Duration     `env:"RECOMMENDATION_INTERVAL"`
+	RecommendationTargetLatency  time.Duration     `env:"RECOMMENDATION_TARGET_LATENCY,default=1m"`
+	RecommendationWindow         time.Duration     `env:"RECOMMENDATION_WINDOW,default=24h"`
+	RepositoryDispatchEventType  string            `env:"REPOSITORY_DISPATCH_EVENT_TYPE,default=provision-runner"`
+	RepositoryDispatchMaxRunners int               `env:"REPOSITORY_DISPATCH_MAX_RUNNERS,default=10"`
+	RunnerActionsCacheBucket     string            `env:"RUNNER_ACTIONS_CACHE_BUCKET"`
+	RunnerActionsCacheEndpoint   string            `env:"RUNNER_ACTIONS_CACHE_ENDPOINT"`
+	RunnerActionsCacheSigner     string            `env:"RUNNER_ACTIONS_CACHE_SIGNER_SERVICE_ACCOUNT"`
+	RunnerActorLimits            map[string]string `env:"RUNNER_ACTOR_LIMITS"`
+	RunnerActorMaxRunners        int               `env:"RUNNER_ACTOR_MAX_RUNNERS"`
+	RunnerAdditionalProjectIDs   []string          `env:"RUNNER_ADDITIONAL_PROJECT_IDS"`
+	RunnerArtifactsBucket        string            `env:"RUNNER_ARTIFACTS_BUCKET"`
+	RunnerArtifactsEndpoint      string            `env:"RUNNER_ARTIFACTS_ENDPOINT"`
+	RunnerArtifactsSigner        string            `env:"RUNNER_ARTIFACTS_SIGNER_SERVICE_ACCOUNT"`
+	RunnerArtifactsURLTTL        time.Duration     `env:"RUNNER_ARTIFACTS_URL_TTL,default=15m"`
+	RunnerARM64ImageName         string            `env:"RUNNER_ARM64_IMAGE_NAME"`
+	RunnerARM64InstanceTemplate  string            `env:"RUNNER_ARM64_INSTANCE_TEMPLATE"`
+	RunnerARM64MachineType       string            `env:"RUNNER_ARM64_MACHINE_TYPE"`
+	RunnerARM64WorkerPool        string            `env:"RUNNER_ARM64_WORKER_POOL"`
+	RunnerBackend                string            `env:"RUNNER_BACKEND,default=cloudbuild"`
+	RunnerBatchAcceleratorCount  int               `env:"RUNNER_BATCH_ACCELERATOR_COUNT,default=1"`
+	RunnerBatchAcceleratorType   string            `env:"RUNNER_BATCH_ACCELERATOR_TYPE"`
+	RunnerBatchLocation          string            `env:"RUNNER_BATCH_LOCATION"`
+	RunnerBatchMachineType       string            `env:"RUNNER_BATCH_MACHINE_TYPE,default=n2-standard-16"`
+	RunnerBatchMaxRunDuration    time.Duration     `env:"RUNNER_BATCH_MAX_RUN_DURATION,default=24h"`
+	RunnerBatchServiceAccount    string            `env:"RUNNER_BATCH_SERVICE_ACCOUNT"`
+	RunnerBuildDiskSizeGB        int               `env:"RUNNER_BUILD_DISK_SIZE_GB"`
+	RunnerBuildMachineType       string            `env:"RUNNER_BUILD_MACHINE_TYPE"`
+	RunnerBuildTemplate          string            `env:"RUNNER_BUILD_TEMPLATE"`
+	RunnerBuildTimeout           time.Duration     `env:"RUNNER_BUILD_TIMEOUT"`
+	RunnerBuildTrigger           string            `env:"RUNNER_BUILD_TRIGGER"`
+	RunnerBlockedActors          []string          `env:"RUNNER_BLOCKED_ACTORS"`
+	RunnerCacheBucket            string            `env:"RUNNER_CACHE_BUCKET"`
+	RunnerCancelUnusedBuilds     bool              `env:"RUNNER_CANCEL_UNUSED_BUILDS"`
+	RunnerDeferredQueueSize      int               `env:"RUNNER_DEFERRED_QUEUE_SIZE,default=1000"`
+	RunnerDispatchBurst          int               `env:"RUNNER_DISPATCH_BURST,default=5"`
+	RunnerDispatchConcurrency    int               `env:"RUNNER_DISPATCH_CONCURRENCY,default=4"`
+	RunnerDispatchQueueSize      int               `env:"RUNNER_DISPATCH_QUEUE_SIZE,default=1000"`
+	RunnerDispatchRate           float64           `env:"RUNNER_DISPATCH_RATE"`
+	RunnerExtraEnv               map[string]string `env:"RUNNER_EXTRA_ENV"`
+	RunnerExtraSubstitutions     map[string]string `env:"RUNNER_EXTRA_SUBSTITUTIONS"`
+	RunnerFailureCheckInterval   time.Duration     `env:"RUNNER_FAILURE_CHECK_INTERVAL"`
+	RunnerFallbackInstanceGroup  string            `env:"RUNNER_FALLBACK_INSTANCE_GROUP"`
+	RunnerFallbackLocations      []string          `env:"RUNNER_FALLBACK_LOCATIONS"`
+	RunnerForecastAggressiveness float64           `env:"RUNNER_FORECAST_AGGRESSIVENESS,default=1"`
+	RunnerForecastInterval       time.Duration     `env:"RUNNER_FORECAST_INTERVAL"`
+	RunnerForecastMaxRunners     int               `env:"RUNNER_FORECAST_MAX_RUNNERS,default=10"`
+	RunnerForecastStateObject    string            `env:"RUNNER_FORECAST_STATE_OBJECT"`
+	RunnerGKECluster             string            `env:"RUNNER_GKE_CLUSTER"`
+	RunnerGKENamespace           string            `env:"RUNNER_GKE_NAMESPACE,default=default"`
+	RunnerGKEServiceAccount      string            `env:"RUNNER_GKE_SERVICE_ACCOUNT"`
+	RunnerGPUImageName           string            `env:"RUNNER_GPU_IMAGE_NAME,default=gpu-runner"`
+	RunnerGPUTypes               map[string]string `env:"RUNNER_GPU_TYPES"`
+	RunnerGroupMappingsPath      string            `env:"RUNNER_GROUP_MAPPINGS_PATH"`
+	RunnerHTTPProxy              string            `env:"RUNNER_HTTP_PROXY"`
+	RunnerHTTPSProxy             string            `env:"RUNNER_HTTPS_PROXY"`
+	RunnerIdleGracePeriod        time.Duration     `env:"RUNNER_IDLE_GRACE_PERIOD"`
+	RunnerImageAttestor          string            `env:"RUNNER_IMAGE_ATTESTOR"`
+	RunnerImageCheck             bool              `env:"RUNNER_IMAGE_CHECK"`
+	RunnerImageCheckTTL          time.Duration     `env:"RUNNER_IMAGE_CHECK_TTL,default=5m"`
+	RunnerImageMappingsPath      string            `env:"RUNNER_IMAGE_MAPPINGS_PATH"`
+	RunnerImageName              string            `env:"RUNNER_IMAGE_NAME,default=default-runner"`
+	RunnerImagePinDigest         bool              `env:"RUNNER_IMAGE_PIN_DIGEST"`
+	RunnerImageTag               string            `env:"RUNNER_IMAGE_TAG,default=latest"`
+	RunnerImageVariants          []string          `env:"RUNNER_IMAGE_VARIANTS"`
+	RunnerInsecureRegistries     []string          `env:"RUNNER_INSECURE_REGISTRIES"`
+	RunnerInstanceTemplate       string            `env:"RUNNER_INSTANCE_TEMPLATE"`
+	RunnerInstanceZone           string            `env:"RUNNER_INSTANCE_ZONE"`
+	RunnerJITConfigSecretTTL     time.Duration     `env:"RUNNER_JIT_CONFIG_SECRET_TTL,default=1h"`
+	RunnerJITConfigSecrets       bool              `env:"RUNNER_JIT_CONFIG_SECRETS"`
+	RunnerJobCompletedHook       string            `env:"RUNNER_JOB_COMPLETED_HOOK"`
+	RunnerJobStartedHook         string            `env:"RUNNER_JOB_STARTED_HOOK"`
+	RunnerJobTimeoutMargin       time.Duration     `env:"RUNNER_JOB_TIMEOUT_MARGIN,default=10m"`
+	RunnerLocation               string            `env:"RUNNER_LOCATION,required"`
+	RunnerLogsBucket             string            `env:"RUNNER_LOGS_BUCKET"`
+	RunnerMaxCount               int               `env:"RUNNER_MAX_COUNT,default=1"`
+	RunnerMaxLifetime            time.Duration     `env:"RUNNER_MAX_LIFETIME"`
+	RunnerMetadataEnv            map[string]string `env:"RUNNER_METADATA_ENV"`
+	RunnerLaunchQueueSize        int               `env:"RUNNER_LAUNCH_QUEUE_SIZE,default=1000"`
+	RunnerMultiBuildMaxRunners   int               `env:"RUNNER_MULTI_BUILD_MAX_RUNNERS,default=10"`
+	RunnerMultiBuildWindow       time.Duration     `env:"RUNNER_MULTI_BUILD_WINDOW"`
+	RunnerMaxConcurrentBuilds    int               `env:"RUNNER_MAX_CONCURRENT_BUILDS"`
+	RunnerNoProxy                string            `env:"RUNNER_NO_PROXY"`
+	RunnerOfflineCleanupInterval time.Duration     `env:"RUNNER_OFFLINE_CLEANUP_INTERVAL"`
+	RunnerOfflineThreshold       time.Duration     `env:"RUNNER_OFFLINE_THRESHOLD,default=30m"`
+	RunnerOrgMaxRunners          int               `env:"RUNNER_ORG_MAX_RUNNERS"`
+	RunnerPoolWarmInterval       time.Duration     `env:"RUNNER_POOL_WARM_INTERVAL"`
+	RunnerPrewarmOnApproval      bool              `env:"RUNNER_PREWARM_ON_APPROVAL"`
+	RunnerProfilesPath           string            `env:"RUNNER_PROFILES_PATH"`
+	RunnerProjectID              string            `env:"RUNNER_PROJECT_ID,required"`
+	RunnerProjectRoutesPath      string            `env:"RUNNER_PROJECT_ROUTES_PATH"`
+	RunnerProjectStrategy        string            `env:"RUNNER_PROJECT_STRATEGY,default=round-robin"`
+	RunnerPropagateJobTimeout    bool              `env:"RUNNER_PROPAGATE_JOB_TIMEOUT"`
+	RunnerReconcileInterval      time.Duration     `env:"RUNNER_RECONCILE_INTERVAL"`
+	RunnerRegistryMirrors        []string          `env:"RUNNER_REGISTRY_MIRRORS"`
+	RunnerRepoMaxRunners         int               `env:"RUNNER_REPO_MAX_RUNNERS"`
+	RunnerRepositories           map[string]string `env:"RUNNER_REPOSITORIES"`
+	RunnerRepositoryAssignments  map[string]string `env:"RUNNER_REPOSITORY_ASSIGNMENTS"`
+	RunnerRepositoryID           string            `env:"RUNNER_REPOSITORY_ID,required"`
+	RunnerRequirePrivateNetwork  bool              `env:"RUNNER_REQUIRE_PRIVATE_NETWORK"`
+	RunnerReuseMaxDuration       time.Duration     `env:"RUNNER_REUSE_MAX_DURATION,default=1h"`
+	RunnerReuseMaxJobs           int               `env:"RUNNER_REUSE_MAX_JOBS"`
+	RunnerScopeLimits            map[string]string `env:"RUNNER_SCOPE_LIMITS"`
+	RunnerSecrets                map[string]string `env:"RUNNER_SECRETS"`
+	RunnerSecurityMode           string            `env:"RUNNER_SECURITY_MODE,default=privileged"`
+	RunnerServiceAccount         string            `env:"RUNNER_SERVICE_ACCOUNT,required"`
+	RunnerSkipIfIdle             bool              `env:"RUNNER_SKIP_IF_IDLE"`
+	RunnerSpot                   bool              `env:"RUNNER_SPOT"`
+	RunnerSpotCheckInterval      time.Duration     `env:"RUNNER_SPOT_CHECK_INTERVAL,default=1m"`
+	RunnerSpotMaxRelaunches      int               `env:"RUNNER_SPOT_MAX_RELAUNCHES,default=3"`
+	RunnerStallCheckInterval     time.Duration     `env:"RUNNER_STALL_CHECK_INTERVAL,default=1m"`
+	RunnerStallThreshold         time.Duration     `env:"RUNNER_STALL_THRESHOLD"`
+	RunnerToolcacheBucket        string            `env:"RUNNER_TOOLCACHE_BUCKET"`
+	RunnerToolcacheCompat        bool              `env:"RUNNER_TOOLCACHE_COMPAT"`
+	RunnerToolcacheSnapshot      string            `env:"RUNNER_TOOLCACHE_SNAPSHOT"`
+	RunnerWorkerPoolID           string            `env:"RUNNER_WORKER_POOL_ID"`
+	RunnerWorkerPools            map[string]string `env:"RUNNER_WORKER_POOLS"`
+	ShadowMode                   bool              `env:"SHADOW_MODE"`
+	SharedSettingsObject         string            `env:"SHARED_SETTINGS_OBJECT"`
+	SharedSettingsPollInterval   time.Duration     `env:"SHARED_SETTINGS_POLL_INTERVAL,default=30s"`
+	SkipStartupChecks            bool              `env:"SKIP_STARTUP_CHECKS"`
+	StateStoreDatabase           string            `env:"STATE_STORE_DATABASE"`
+	StrictPayloadValidation      bool              `env:"STRICT_PAYLOAD_VALIDATION"`
+	VulnerabilityGate            string            `env:"VULNERABILITY_GATE"`
+	VulnerabilityMaxCritical     int               `env:"VULNERABILITY_MAX_CRITICAL"`
+	VulnerabilityScanTTL         time.Duration     `env:"VULNERABILITY_SCAN_TTL,default=10m"`
 }
 
 // Validate validates the webhook config after load.
@@ -64,6 +213,14 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("KMS_APP_PRIVATE_KEY_ID is required")
 	}
 
+	if cfg.KMSAppFallbackPrivateKeyID != "" && cfg.GitHubAppFallbackKeyPath != "" {
+		return fmt.Errorf("only one of KMS_APP_FALLBACK_PRIVATE_KEY_ID and GITHUB_APP_FALLBACK_PRIVATE_KEY_PATH can be set")
+	}
+
+	if cfg.KMSAppFallbackPrivateKeyID != "" && cfg.KMSAppFallbackPrivateKeyID == cfg.KMSAppPrivateKeyID {
+		return fmt.Errorf("KMS_APP_FALLBACK_PRIVATE_KEY_ID must differ from KMS_APP_PRIVATE_KEY_ID")
+	}
+
 	if cfg.RunnerLocation == "" {
 		return fmt.Errorf("RUNNER_LOCATION is required")
 	}
@@ -72,6 +229,10 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("RUNNER_PROJECT_ID is required")
 	}
 
+	if _, err := newProjectSpreader(cfg.RunnerProjectID, cfg.RunnerAdditionalProjectIDs, cfg.RunnerProjectStrategy); err != nil {
+		return fmt.Errorf("RUNNER_ADDITIONAL_PROJECT_IDS or RUNNER_PROJECT_STRATEGY is invalid: %w", err)
+	}
+
 	if cfg.RunnerRepositoryID == "" {
 		return fmt.Errorf("RUNNER_REPOSITORY_ID is required")
 	}
@@ -80,6 +241,498 @@ func (cfg *Config) Validate() error {
 		return fmt.Errorf("RUNNER_SERVICE_ACCOUNT is required")
 	}
 
+	for _, mirror := range cfg.RunnerRegistryMirrors {
+		u, err := url.Parse(mirror)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("RUNNER_REGISTRY_MIRRORS entries must be http(s) URLs, got %q", mirror)
+		}
+	}
+
+	for _, registry := range cfg.RunnerInsecureRegistries {
+		if registry == "" || strings.Contains(registry, "://") {
+			return fmt.Errorf("RUNNER_INSECURE_REGISTRIES entries must be a host[:port] or CIDR, got %q", registry)
+		}
+	}
+
+	for name, id := range cfg.RunnerRepositories {
+		if name == "" || id == "" || strings.Contains(id, "://") {
+			return fmt.Errorf("RUNNER_REPOSITORIES entries must be <name>=<location>-docker.pkg.dev/<project>/<repository>, got %q=%q", name, id)
+		}
+	}
+
+	for key, name := range cfg.RunnerRepositoryAssignments {
+		if _, ok := cfg.RunnerRepositories[name]; !ok {
+			return fmt.Errorf("RUNNER_REPOSITORY_ASSIGNMENTS assigns %q to %q, which is not defined in RUNNER_REPOSITORIES", key, name)
+		}
+	}
+
+	for _, setting := range cfg.secretSettings() {
+		if !isEncryptedValue(*setting.value) {
+			continue
+		}
+		if _, _, err := parseEncryptedValue(*setting.value); err != nil {
+			return fmt.Errorf("%s is invalid: %w", setting.name, err)
+		}
+	}
+
+	if err := validateProxyURLs(cfg); err != nil {
+		return err
+	}
+
+	if cfg.GRPCPort != "" {
+		if cfg.DispatchAPIAudience == "" {
+			return fmt.Errorf("DISPATCH_API_AUDIENCE is required when GRPC_PORT is set")
+		}
+		if len(cfg.DispatchAPICallers) == 0 {
+			return fmt.Errorf("DISPATCH_API_CALLERS is required when GRPC_PORT is set")
+		}
+	}
+
+	if _, err := runnerSecurityArgs(cfg.RunnerSecurityMode); err != nil {
+		return fmt.Errorf("RUNNER_SECURITY_MODE is invalid: %w", err)
+	}
+
+	if cfg.RunnerRequirePrivateNetwork && cfg.RunnerWorkerPoolID == "" {
+		return fmt.Errorf("RUNNER_WORKER_POOL_ID is required when RUNNER_REQUIRE_PRIVATE_NETWORK is set")
+	}
+
+	if cfg.RunnerPoolWarmInterval < 0 {
+		return fmt.Errorf("RUNNER_POOL_WARM_INTERVAL must not be negative, got %s", cfg.RunnerPoolWarmInterval)
+	}
+
+	if cfg.RunnerPoolWarmInterval > 0 && cfg.RunnerWorkerPoolID == "" && len(cfg.RunnerWorkerPools) == 0 {
+		return fmt.Errorf("RUNNER_POOL_WARM_INTERVAL requires RUNNER_WORKER_POOL_ID or RUNNER_WORKER_POOLS, the default pool cannot be warmed")
+	}
+
+	if cfg.QueueTTL < 0 {
+		return fmt.Errorf("QUEUE_TTL must not be negative, got %s", cfg.QueueTTL)
+	}
+
+	if cfg.RecommendationInterval < 0 {
+		return fmt.Errorf("RECOMMENDATION_INTERVAL must not be negative, got %s", cfg.RecommendationInterval)
+	}
+
+	if cfg.RecommendationInterval > 0 && cfg.RecommendationWindow <= 0 {
+		return fmt.Errorf("RECOMMENDATION_WINDOW must be positive, got %s", cfg.RecommendationWindow)
+	}
+
+	if cfg.RunnerForecastInterval < 0 {
+		return fmt.Errorf("RUNNER_FORECAST_INTERVAL must not be negative, got %s", cfg.RunnerForecastInterval)
+	}
+	if cfg.RunnerForecastInterval > 0 {
+		// Intervals are aligned on the day, so they line up across restarts.
+		if cfg.RunnerForecastInterval < time.Minute || (24*time.Hour)%cfg.RunnerForecastInterval != 0 {
+			return fmt.Errorf("RUNNER_FORECAST_INTERVAL must be at least 1m and divide a day, got %s", cfg.RunnerForecastInterval)
+		}
+		if cfg.RunnerForecastAggressiveness <= 0 {
+			return fmt.Errorf("RUNNER_FORECAST_AGGRESSIVENESS must be positive, got %v", cfg.RunnerForecastAggressiveness)
+		}
+		if cfg.RunnerForecastMaxRunners < 1 {
+			return fmt.Errorf("RUNNER_FORECAST_MAX_RUNNERS must be at least 1, got %d", cfg.RunnerForecastMaxRunners)
+		}
+		if cfg.RunnerForecastStateObject != "" {
+			if _, _, err := parseGCSObject(cfg.RunnerForecastStateObject); err != nil {
+				return fmt.Errorf("RUNNER_FORECAST_STATE_OBJECT is invalid: %w", err)
+			}
+		}
+	}
+
+	if err := validateExtraSubstitutions(cfg.RunnerExtraSubstitutions); err != nil {
+		return fmt.Errorf("RUNNER_EXTRA_SUBSTITUTIONS is invalid: %w", err)
+	}
+	if err := validateExtraEnv(cfg.RunnerExtraEnv); err != nil {
+		return fmt.Errorf("RUNNER_EXTRA_ENV is invalid: %w", err)
+	}
+	if err := validateMetadataEnv(cfg.RunnerMetadataEnv, cfg.RunnerExtraEnv); err != nil {
+		return fmt.Errorf("RUNNER_METADATA_ENV is invalid: %w", err)
+	}
+	if _, err := runnerSecretVersions(cfg.RunnerProjectID, cfg.RunnerSecrets); err != nil {
+		return fmt.Errorf("RUNNER_SECRETS is invalid: %w", err)
+	}
+	for name := range cfg.RunnerSecrets {
+		_, extra := cfg.RunnerExtraEnv[name]
+		_, metadata := cfg.RunnerMetadataEnv[name]
+		if extra || metadata {
+			return fmt.Errorf("RUNNER_SECRETS environment variable %q is also set by RUNNER_EXTRA_ENV or RUNNER_METADATA_ENV", name)
+		}
+	}
+
+	for name, pool := range cfg.RunnerWorkerPools {
+		if _, err := workerPoolLocation(pool); err != nil {
+			return fmt.Errorf("RUNNER_WORKER_POOLS entry %q is invalid: %w", name, err)
+		}
+	}
+
+	if cfg.RunnerARM64ImageName != "" {
+		switch cfg.RunnerBackend {
+		case runnerBackendCloudBuild:
+			// The default Cloud Build pool only has x86-64 machines.
+			if cfg.RunnerARM64WorkerPool == "" {
+				return fmt.Errorf("RUNNER_ARM64_WORKER_POOL is required when RUNNER_ARM64_IMAGE_NAME is set and RUNNER_BACKEND is %q", runnerBackendCloudBuild)
+			}
+		case runnerBackendCompute:
+			if cfg.RunnerARM64InstanceTemplate == "" {
+				return fmt.Errorf("RUNNER_ARM64_INSTANCE_TEMPLATE is required when RUNNER_ARM64_IMAGE_NAME is set and RUNNER_BACKEND is %q", runnerBackendCompute)
+			}
+		}
+		if cfg.RunnerBatchLocation != "" && cfg.RunnerARM64MachineType == "" {
+			return fmt.Errorf("RUNNER_ARM64_MACHINE_TYPE is required when RUNNER_ARM64_IMAGE_NAME and RUNNER_BATCH_LOCATION are set")
+		}
+	}
+	if cfg.RunnerARM64WorkerPool != "" {
+		if _, err := workerPoolLocation(cfg.RunnerARM64WorkerPool); err != nil {
+			return fmt.Errorf("RUNNER_ARM64_WORKER_POOL is invalid: %w", err)
+		}
+	}
+
+	if len(cfg.RunnerGPUTypes) > 0 {
+		if cfg.RunnerBackend != runnerBackendCompute && cfg.RunnerBatchLocation == "" {
+			return fmt.Errorf("RUNNER_GPU_TYPES requires RUNNER_BACKEND %q or RUNNER_BATCH_LOCATION", runnerBackendCompute)
+		}
+		for name, accelerator := range cfg.RunnerGPUTypes {
+			if name == "" || accelerator == "" {
+				return fmt.Errorf("RUNNER_GPU_TYPES entry %q=%q must have a name and an accelerator type", name, accelerator)
+			}
+		}
+	}
+
+	if cfg.RunnerBuildMachineType != "" {
+		if _, err := buildMachineType(cfg.RunnerBuildMachineType); err != nil {
+			return fmt.Errorf("RUNNER_BUILD_MACHINE_TYPE is invalid: %w", err)
+		}
+	}
+	if cfg.RunnerBuildDiskSizeGB < 0 || cfg.RunnerBuildDiskSizeGB > maxBuildDiskSizeGB {
+		return fmt.Errorf("RUNNER_BUILD_DISK_SIZE_GB must be between 0 and %d, got %d", maxBuildDiskSizeGB, cfg.RunnerBuildDiskSizeGB)
+	}
+	if cfg.RunnerBuildTimeout < 0 || cfg.RunnerBuildTimeout > maxBuildTimeout {
+		return fmt.Errorf("RUNNER_BUILD_TIMEOUT must be between 0 and %s, got %s", maxBuildTimeout, cfg.RunnerBuildTimeout)
+	}
+
+	for _, location := range cfg.RunnerFallbackLocations {
+		if location == "" || location == cfg.RunnerLocation {
+			return fmt.Errorf("RUNNER_FALLBACK_LOCATIONS must not contain empty locations or RUNNER_LOCATION, got %q", cfg.RunnerFallbackLocations)
+		}
+	}
+	if len(cfg.RunnerFallbackLocations) > 0 && cfg.RunnerWorkerPoolID != "" {
+		return fmt.Errorf("RUNNER_FALLBACK_LOCATIONS cannot be used with RUNNER_WORKER_POOL_ID, a worker pool is in a single location")
+	}
+
+	if strings.HasPrefix(cfg.RunnerBuildTemplate, "gs://") {
+		if _, _, err := parseGCSObject(cfg.RunnerBuildTemplate); err != nil {
+			return fmt.Errorf("RUNNER_BUILD_TEMPLATE is invalid: %w", err)
+		}
+	}
+
+	if cfg.StateStoreDatabase != "" {
+		if err := parseFirestoreDatabase(cfg.StateStoreDatabase); err != nil {
+			return fmt.Errorf("STATE_STORE_DATABASE is invalid: %w", err)
+		}
+	}
+
+	if cfg.SharedSettingsObject != "" {
+		if _, _, err := parseGCSObject(cfg.SharedSettingsObject); err != nil {
+			return fmt.Errorf("SHARED_SETTINGS_OBJECT is invalid: %w", err)
+		}
+		if cfg.SharedSettingsPollInterval <= 0 {
+			return fmt.Errorf("SHARED_SETTINGS_POLL_INTERVAL must be positive, got %s", cfg.SharedSettingsPollInterval)
+		}
+	}
+
+	switch cfg.VulnerabilityGate {
+	case "", vulnerabilityGateWarn, vulnerabilityGateEnforce:
+	default:
+		return fmt.Errorf("VULNERABILITY_GATE must be one of %q or %q, got %q",
+			vulnerabilityGateWarn, vulnerabilityGateEnforce, cfg.VulnerabilityGate)
+	}
+
+	if cfg.VulnerabilityMaxCritical < 0 {
+		return fmt.Errorf("VULNERABILITY_MAX_CRITICAL must not be negative, got %d", cfg.VulnerabilityMaxCritical)
+	}
+
+	if cfg.RunnerImageAttestor != "" && !attestorRegexp.MatchString(cfg.RunnerImageAttestor) {
+		return fmt.Errorf("RUNNER_IMAGE_ATTESTOR must be of the form projects/<project>/attestors/<name>, got %q", cfg.RunnerImageAttestor)
+	}
+	if (cfg.RunnerImageCheck || cfg.RunnerImagePinDigest || cfg.RunnerImageAttestor != "") && cfg.RunnerImageCheckTTL <= 0 {
+		return fmt.Errorf("RUNNER_IMAGE_CHECK_TTL must be positive, got %s", cfg.RunnerImageCheckTTL)
+	}
+
+	if cfg.RunnerMaxCount < 1 {
+		return fmt.Errorf("RUNNER_MAX_COUNT must be at least 1, got %d", cfg.RunnerMaxCount)
+	}
+
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"CREATE_BUILD_TIMEOUT", cfg.CreateBuildTimeout},
+		{"DELIVERY_BUDGET", cfg.DeliveryBudget},
+		{"GITHUB_CALL_TIMEOUT", cfg.GitHubCallTimeout},
+	} {
+		if d.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", d.name, d.value)
+		}
+	}
+
+	if cfg.DeliveryArchiveSize < 0 {
+		return fmt.Errorf("DELIVERY_ARCHIVE_SIZE must not be negative, got %d", cfg.DeliveryArchiveSize)
+	}
+
+	if cfg.RepositoryDispatchMaxRunners < 1 {
+		return fmt.Errorf("REPOSITORY_DISPATCH_MAX_RUNNERS must be at least 1, got %d", cfg.RepositoryDispatchMaxRunners)
+	}
+
+	if _, err := newActorLimits(cfg.RunnerBlockedActors, cfg.RunnerActorMaxRunners, cfg.RunnerActorLimits); err != nil {
+		return fmt.Errorf("RUNNER_ACTOR_MAX_RUNNERS or RUNNER_ACTOR_LIMITS is invalid: %w", err)
+	}
+
+	if _, err := newScopeLimits(cfg.RunnerRepoMaxRunners, cfg.RunnerOrgMaxRunners, cfg.RunnerScopeLimits); err != nil {
+		return fmt.Errorf("RUNNER_REPO_MAX_RUNNERS, RUNNER_ORG_MAX_RUNNERS or RUNNER_SCOPE_LIMITS is invalid: %w", err)
+	}
+
+	if cfg.RunnerReuseMaxJobs < 0 {
+		return fmt.Errorf("RUNNER_REUSE_MAX_JOBS must not be negative, got %d", cfg.RunnerReuseMaxJobs)
+	}
+	if cfg.RunnerReuseMaxJobs > 1 && cfg.RunnerReuseMaxDuration <= 0 {
+		return fmt.Errorf("RUNNER_REUSE_MAX_DURATION must be positive, got %s", cfg.RunnerReuseMaxDuration)
+	}
+
+	if cfg.RunnerIdleGracePeriod != 0 && cfg.RunnerIdleGracePeriod < time.Minute {
+		return fmt.Errorf("RUNNER_IDLE_GRACE_PERIOD must be at least 1m, got %s", cfg.RunnerIdleGracePeriod)
+	}
+
+	if cfg.RunnerMaxLifetime != 0 && cfg.RunnerMaxLifetime < time.Minute {
+		return fmt.Errorf("RUNNER_MAX_LIFETIME must be at least 1m, got %s", cfg.RunnerMaxLifetime)
+	}
+
+	if cfg.RunnerMaxConcurrentBuilds < 0 {
+		return fmt.Errorf("RUNNER_MAX_CONCURRENT_BUILDS must not be negative, got %d", cfg.RunnerMaxConcurrentBuilds)
+	}
+
+	if cfg.RunnerLaunchQueueSize < 1 {
+		return fmt.Errorf("RUNNER_LAUNCH_QUEUE_SIZE must be at least 1, got %d", cfg.RunnerLaunchQueueSize)
+	}
+
+	if cfg.RunnerDeferredQueueSize < 1 {
+		return fmt.Errorf("RUNNER_DEFERRED_QUEUE_SIZE must be at least 1, got %d", cfg.RunnerDeferredQueueSize)
+	}
+
+	if strings.Contains(cfg.RunnerLogsBucket, "/") {
+		return fmt.Errorf("RUNNER_LOGS_BUCKET must be a bucket name without the gs:// prefix or a path, got %q", cfg.RunnerLogsBucket)
+	}
+
+	if cfg.RunnerArtifactsBucket != "" {
+		if strings.Contains(cfg.RunnerArtifactsBucket, "/") {
+			return fmt.Errorf("RUNNER_ARTIFACTS_BUCKET must be a bucket name without the gs:// prefix or a path, got %q", cfg.RunnerArtifactsBucket)
+		}
+		u, err := url.Parse(cfg.RunnerArtifactsEndpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("RUNNER_ARTIFACTS_ENDPOINT must be the http(s) URL of this service when RUNNER_ARTIFACTS_BUCKET is set, got %q", cfg.RunnerArtifactsEndpoint)
+		}
+		if cfg.RunnerArtifactsSigner == "" {
+			return fmt.Errorf("RUNNER_ARTIFACTS_SIGNER_SERVICE_ACCOUNT is required when RUNNER_ARTIFACTS_BUCKET is set")
+		}
+		// V4 signed URLs are valid for at most 7 days.
+		if cfg.RunnerArtifactsURLTTL <= 0 || cfg.RunnerArtifactsURLTTL > 7*24*time.Hour {
+			return fmt.Errorf("RUNNER_ARTIFACTS_URL_TTL must be between 0 and 7 days, got %s", cfg.RunnerArtifactsURLTTL)
+		}
+	}
+
+	if cfg.RunnerActionsCacheBucket != "" {
+		if strings.Contains(cfg.RunnerActionsCacheBucket, "/") {
+			return fmt.Errorf("RUNNER_ACTIONS_CACHE_BUCKET must be a bucket name without the gs:// prefix or a path, got %q", cfg.RunnerActionsCacheBucket)
+		}
+		u, err := url.Parse(cfg.RunnerActionsCacheEndpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("RUNNER_ACTIONS_CACHE_ENDPOINT must be the http(s) URL of this service when RUNNER_ACTIONS_CACHE_BUCKET is set, got %q", cfg.RunnerActionsCacheEndpoint)
+		}
+		if cfg.RunnerActionsCacheSigner == "" {
+			return fmt.Errorf("RUNNER_ACTIONS_CACHE_SIGNER_SERVICE_ACCOUNT is required when RUNNER_ACTIONS_CACHE_BUCKET is set")
+		}
+	}
+
+	for _, hook := range []struct{ name, value string }{
+		{"RUNNER_JOB_STARTED_HOOK", cfg.RunnerJobStartedHook},
+		{"RUNNER_JOB_COMPLETED_HOOK", cfg.RunnerJobCompletedHook},
+	} {
+		if !strings.HasPrefix(hook.value, "gs://") {
+			continue
+		}
+		if _, _, err := parseGCSObject(hook.value); err != nil {
+			return fmt.Errorf("%s is invalid: %w", hook.name, err)
+		}
+	}
+
+	switch cfg.RunnerBackend {
+	case runnerBackendCloudBuild:
+	case runnerBackendCompute:
+		if cfg.RunnerInstanceTemplate == "" || cfg.RunnerInstanceZone == "" {
+			return fmt.Errorf("RUNNER_INSTANCE_TEMPLATE and RUNNER_INSTANCE_ZONE are required when RUNNER_BACKEND is %q", runnerBackendCompute)
+		}
+		if cfg.RunnerFallbackInstanceGroup != "" {
+			return fmt.Errorf("RUNNER_FALLBACK_INSTANCE_GROUP only applies when RUNNER_BACKEND is %q", runnerBackendCloudBuild)
+		}
+	case runnerBackendGKE:
+		if cfg.RunnerGKECluster == "" {
+			return fmt.Errorf("RUNNER_GKE_CLUSTER is required when RUNNER_BACKEND is %q", runnerBackendGKE)
+		}
+		if err := parseGKECluster(cfg.RunnerGKECluster); err != nil {
+			return fmt.Errorf("RUNNER_GKE_CLUSTER is invalid: %w", err)
+		}
+		if cfg.RunnerGKENamespace == "" {
+			return fmt.Errorf("RUNNER_GKE_NAMESPACE is required when RUNNER_BACKEND is %q", runnerBackendGKE)
+		}
+		// Pods cannot read Secret Manager secrets into their environment.
+		if len(cfg.RunnerSecrets) > 0 {
+			return fmt.Errorf("RUNNER_SECRETS cannot be used when RUNNER_BACKEND is %q", runnerBackendGKE)
+		}
+		if cfg.RunnerFallbackInstanceGroup != "" {
+			return fmt.Errorf("RUNNER_FALLBACK_INSTANCE_GROUP only applies when RUNNER_BACKEND is %q", runnerBackendCloudBuild)
+		}
+		if cfg.RunnerJITConfigSecrets {
+			return fmt.Errorf("RUNNER_JIT_CONFIG_SECRETS does not apply when RUNNER_BACKEND is %q, "+
+				"the JIT config is always passed in a Kubernetes Secret", runnerBackendGKE)
+		}
+	default:
+		return fmt.Errorf("RUNNER_BACKEND must be one of %q, %q or %q, got %q",
+			runnerBackendCloudBuild, runnerBackendCompute, runnerBackendGKE, cfg.RunnerBackend)
+	}
+
+	if cfg.RunnerBuildTrigger != "" {
+		if cfg.RunnerBackend != runnerBackendCloudBuild {
+			return fmt.Errorf("RUNNER_BUILD_TRIGGER only applies when RUNNER_BACKEND is %q", runnerBackendCloudBuild)
+		}
+		if strings.Contains(cfg.RunnerBuildTrigger, "/") {
+			return fmt.Errorf("RUNNER_BUILD_TRIGGER must be the ID or name of a trigger, got %q", cfg.RunnerBuildTrigger)
+		}
+		// Triggers run their own build and only take substitutions from the
+		// webhook service, so secrets and build templates cannot be applied.
+		if len(cfg.RunnerSecrets) > 0 || cfg.RunnerJITConfigSecrets {
+			return fmt.Errorf("RUNNER_SECRETS and RUNNER_JIT_CONFIG_SECRETS cannot be used with RUNNER_BUILD_TRIGGER")
+		}
+		if cfg.RunnerBuildTemplate != "" {
+			return fmt.Errorf("RUNNER_BUILD_TEMPLATE cannot be used with RUNNER_BUILD_TRIGGER")
+		}
+	}
+
+	if cfg.RunnerFallbackInstanceGroup != "" {
+		if _, err := parseInstanceGroup(cfg.RunnerFallbackInstanceGroup); err != nil {
+			return fmt.Errorf("RUNNER_FALLBACK_INSTANCE_GROUP is invalid: %w", err)
+		}
+	}
+
+	if cfg.RunnerBatchLocation != "" {
+		if cfg.RunnerBatchMachineType == "" {
+			return fmt.Errorf("RUNNER_BATCH_MACHINE_TYPE is required when RUNNER_BATCH_LOCATION is set")
+		}
+		if cfg.RunnerBatchAcceleratorType != "" && cfg.RunnerBatchAcceleratorCount <= 0 {
+			return fmt.Errorf("RUNNER_BATCH_ACCELERATOR_COUNT must be positive, got %d", cfg.RunnerBatchAcceleratorCount)
+		}
+		if cfg.RunnerBatchMaxRunDuration < 0 {
+			return fmt.Errorf("RUNNER_BATCH_MAX_RUN_DURATION must be positive, got %s", cfg.RunnerBatchMaxRunDuration)
+		}
+	}
+
+	if cfg.RunnerJITConfigSecrets && cfg.RunnerJITConfigSecretTTL <= 0 {
+		return fmt.Errorf("RUNNER_JIT_CONFIG_SECRET_TTL must be positive, got %s", cfg.RunnerJITConfigSecretTTL)
+	}
+
+	if strings.HasPrefix(cfg.RunnerToolcacheBucket, "gs://") {
+		return fmt.Errorf("RUNNER_TOOLCACHE_BUCKET must be a bucket name without the gs:// prefix, got %q", cfg.RunnerToolcacheBucket)
+	}
+	if cfg.RunnerToolcacheSnapshot != "" {
+		if !toolcacheSnapshotRegexp.MatchString(cfg.RunnerToolcacheSnapshot) {
+			return fmt.Errorf("RUNNER_TOOLCACHE_SNAPSHOT must be of the form projects/<project>/global/snapshots/<name>, got %q", cfg.RunnerToolcacheSnapshot)
+		}
+		if cfg.RunnerBackend != runnerBackendCompute {
+			return fmt.Errorf("RUNNER_TOOLCACHE_SNAPSHOT only applies when RUNNER_BACKEND is %q", runnerBackendCompute)
+		}
+		if cfg.RunnerToolcacheBucket != "" {
+			return fmt.Errorf("RUNNER_TOOLCACHE_SNAPSHOT cannot be combined with RUNNER_TOOLCACHE_BUCKET")
+		}
+	}
+
+	if cfg.RunnerDispatchRate < 0 {
+		return fmt.Errorf("RUNNER_DISPATCH_RATE must not be negative, got %v", cfg.RunnerDispatchRate)
+	}
+	if cfg.RunnerDispatchRate > 0 {
+		if cfg.RunnerDispatchBurst < 1 {
+			return fmt.Errorf("RUNNER_DISPATCH_BURST must be at least 1, got %d", cfg.RunnerDispatchBurst)
+		}
+		if cfg.RunnerDispatchConcurrency < 1 {
+			return fmt.Errorf("RUNNER_DISPATCH_CONCURRENCY must be at least 1, got %d", cfg.RunnerDispatchConcurrency)
+		}
+		if cfg.RunnerDispatchQueueSize < 1 {
+			return fmt.Errorf("RUNNER_DISPATCH_QUEUE_SIZE must be at least 1, got %d", cfg.RunnerDispatchQueueSize)
+		}
+	}
+
+	if cfg.RunnerMultiBuildWindow < 0 || cfg.RunnerMultiBuildWindow > maxMultiBuildWindow {
+		return fmt.Errorf("RUNNER_MULTI_BUILD_WINDOW must be between 0 and %s, got %s", maxMultiBuildWindow, cfg.RunnerMultiBuildWindow)
+	}
+	if cfg.RunnerMultiBuildWindow > 0 {
+		if cfg.RunnerMultiBuildMaxRunners < 2 {
+			return fmt.Errorf("RUNNER_MULTI_BUILD_MAX_RUNNERS must be at least 2, got %d", cfg.RunnerMultiBuildMaxRunners)
+		}
+		if cfg.RunnerBackend != runnerBackendCloudBuild {
+			return fmt.Errorf("RUNNER_MULTI_BUILD_WINDOW only applies when RUNNER_BACKEND is %q", runnerBackendCloudBuild)
+		}
+		// The runners of a build share its secrets and a trigger runs a
+		// single runner.
+		if cfg.RunnerJITConfigSecrets || cfg.RunnerBuildTrigger != "" {
+			return fmt.Errorf("RUNNER_MULTI_BUILD_WINDOW cannot be used with RUNNER_JIT_CONFIG_SECRETS or RUNNER_BUILD_TRIGGER")
+		}
+	}
+
+	if cfg.RunnerSpot && cfg.RunnerBackend != runnerBackendCompute {
+		return fmt.Errorf("RUNNER_SPOT only applies when RUNNER_BACKEND is %q, "+
+			"use the %q label for batch jobs", runnerBackendCompute, spotLabel)
+	}
+	if cfg.RunnerOfflineCleanupInterval != 0 && cfg.RunnerOfflineCleanupInterval < time.Minute {
+		return fmt.Errorf("RUNNER_OFFLINE_CLEANUP_INTERVAL must be at least 1m, got %s", cfg.RunnerOfflineCleanupInterval)
+	}
+	if cfg.RunnerOfflineThreshold <= 0 {
+		return fmt.Errorf("RUNNER_OFFLINE_THRESHOLD must be positive, got %s", cfg.RunnerOfflineThreshold)
+	}
+	if cfg.RunnerReconcileInterval != 0 && cfg.RunnerReconcileInterval < time.Minute {
+		return fmt.Errorf("RUNNER_RECONCILE_INTERVAL must be at least 1m, got %s", cfg.RunnerReconcileInterval)
+	}
+	// The reconciler only sees Cloud Build builds, it would remove the runners
+	// of the other backends as orphans.
+	if cfg.RunnerReconcileInterval != 0 && (cfg.RunnerBackend != runnerBackendCloudBuild ||
+		cfg.RunnerBatchLocation != "" || cfg.RunnerFallbackInstanceGroup != "") {
+		return fmt.Errorf("RUNNER_RECONCILE_INTERVAL only applies when runners run on Cloud Build only, " +
+			"without RUNNER_BATCH_LOCATION or RUNNER_FALLBACK_INSTANCE_GROUP")
+	}
+	if cfg.RunnerSpotCheckInterval < 0 {
+		return fmt.Errorf("RUNNER_SPOT_CHECK_INTERVAL must be positive, got %s", cfg.RunnerSpotCheckInterval)
+	}
+	if cfg.RunnerSpotMaxRelaunches < 0 {
+		return fmt.Errorf("RUNNER_SPOT_MAX_RELAUNCHES must not be negative, got %d", cfg.RunnerSpotMaxRelaunches)
+	}
+
+	if cfg.RunnerStallThreshold > 0 && cfg.RunnerStallCheckInterval <= 0 {
+		return fmt.Errorf("RUNNER_STALL_CHECK_INTERVAL must be positive, got %s", cfg.RunnerStallCheckInterval)
+	}
+
+	if cfg.PagerDutyRoutingKey != "" {
+		if cfg.PagerDutyFailureRate <= 0 || cfg.PagerDutyFailureRate > 1 {
+			return fmt.Errorf("PAGERDUTY_FAILURE_RATE_THRESHOLD must be in (0, 1], got %v", cfg.PagerDutyFailureRate)
+		}
+		if cfg.PagerDutyWindow <= 0 {
+			return fmt.Errorf("PAGERDUTY_WINDOW must be positive, got %s", cfg.PagerDutyWindow)
+		}
+	}
+
+	if cfg.CloudProfiler && cfg.CloudProfilerProjectID == "" {
+		return fmt.Errorf("CLOUD_PROFILER_PROJECT_ID is required when CLOUD_PROFILER is set")
+	}
+
+	if cfg.LifecycleEventsTopic != "" {
+		if _, _, err := parseTopicName(cfg.LifecycleEventsTopic); err != nil {
+			return fmt.Errorf("LIFECYCLE_EVENTS_TOPIC is invalid: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -96,6 +749,30 @@ func newConfig(ctx context.Context, lu envconfig.Lookuper) (*Config, error) {
 	return &cfg, nil
 }
 
+// validateProxyURLs checks the proxies are http(s) URLs. Proxies supplied as
+// KMS ciphertext are skipped.
+func validateProxyURLs(cfg *Config) error {
+	// The KMS client decrypting ciphertext connects through the proxy.
+	if isEncryptedValue(cfg.GoogleCloudProxyURL) {
+		return fmt.Errorf("GOOGLE_CLOUD_PROXY_URL cannot be KMS ciphertext")
+	}
+	for _, proxy := range []struct{ name, value string }{
+		{"GITHUB_PROXY_URL", cfg.GitHubProxyURL},
+		{"GOOGLE_CLOUD_PROXY_URL", cfg.GoogleCloudProxyURL},
+		{"RUNNER_HTTP_PROXY", cfg.RunnerHTTPProxy},
+		{"RUNNER_HTTPS_PROXY", cfg.RunnerHTTPSProxy},
+	} {
+		if proxy.value == "" || isEncryptedValue(proxy.value) {
+			continue
+		}
+		u, err := url.Parse(proxy.value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL, got %q", proxy.name, proxy.value)
+		}
+	}
+	return nil
+}
+
 // ToFlags binds the config to the [cli.FlagSet] and returns it.
 func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 	f := set.NewSection("COMMON SERVER OPTIONS")
@@ -123,6 +800,70 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:   `The GitHub API URL.`,
 	})
 
+	f.StringVar(&cli.StringVar{
+		Name:    "github-proxy-url",
+		Target:  &cfg.GitHubProxyURL,
+		EnvVar:  "GITHUB_PROXY_URL",
+		Example: "http://proxy.internal:3128",
+		Usage: `The proxy of the GitHub API requests, for example a Secure Web Proxy. ` +
+			`Defaults to the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, ` +
+			`which the Google Cloud clients use as well unless GOOGLE_CLOUD_PROXY_URL is set. ` +
+			`May be KMS ciphertext in the form kms://<key name>/<base64 ciphertext>, decrypted at startup.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "github-ca-bundle-path",
+		Target:  &cfg.GitHubCABundlePath,
+		EnvVar:  "GITHUB_CA_BUNDLE_PATH",
+		Example: "/etc/proxy/ca.pem",
+		Usage:   `The path of a PEM bundle of CA certificates trusted for the GitHub API requests on top of the system ones, for proxies that intercept TLS.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "google-cloud-proxy-url",
+		Target:  &cfg.GoogleCloudProxyURL,
+		EnvVar:  "GOOGLE_CLOUD_PROXY_URL",
+		Example: "http://proxy.internal:3128",
+		Usage: `The HTTP CONNECT proxy the gRPC Google Cloud clients, such as the KMS and Cloud Build ` +
+			`clients, connect through. Defaults to the proxy of the HTTPS_PROXY and NO_PROXY environment ` +
+			`variables. Cannot be KMS ciphertext, the KMS client connects through it.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "google-cloud-ca-bundle-path",
+		Target:  &cfg.GoogleCloudCABundlePath,
+		EnvVar:  "GOOGLE_CLOUD_CA_BUNDLE_PATH",
+		Example: "/etc/proxy/ca.pem",
+		Usage: `The path of a PEM bundle of CA certificates trusted by the gRPC Google Cloud clients ` +
+			`on top of the system ones, for proxies that intercept TLS.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "delivery-budget",
+		Target:  &cfg.DeliveryBudget,
+		EnvVar:  "DELIVERY_BUDGET",
+		Default: 9 * time.Second,
+		Usage: `The time a webhook delivery is processed within, GitHub gives up on deliveries after 10 seconds. ` +
+			`The external calls of a delivery are bounded by what remains of it. Disabled when 0.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "github-call-timeout",
+		Target:  &cfg.GitHubCallTimeout,
+		EnvVar:  "GITHUB_CALL_TIMEOUT",
+		Default: 3 * time.Second,
+		Usage: `The timeout of each GitHub installation, token, runner lookup and JIT configuration call, ` +
+			`bounded by the remaining delivery budget. Disabled when 0.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "create-build-timeout",
+		Target:  &cfg.CreateBuildTimeout,
+		EnvVar:  "CREATE_BUILD_TIMEOUT",
+		Default: 5 * time.Second,
+		Usage:   `The timeout of the Cloud Build call creating a runner build, bounded by the remaining delivery budget. Disabled when 0.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:   "github-app-id",
 		Target: &cfg.GitHubAppID,
@@ -137,6 +878,22 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:  `The KMS private key path in the form "projects/<project_id>/locations/<location>/keyRings/<key_ring_name>/cryptoKeys/<key_name>/cryptoKeyVersions/<version>".`,
 	})
 
+	f.StringVar(&cli.StringVar{
+		Name:   "kms-app-fallback-private-key-id",
+		Target: &cfg.KMSAppFallbackPrivateKeyID,
+		EnvVar: "KMS_APP_FALLBACK_PRIVATE_KEY_ID",
+		Usage: `A second KMS private key of the GitHub App, ideally in another region, ` +
+			`used when signing with the primary key fails. The key must be added to the GitHub App.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "github-app-fallback-private-key-path",
+		Target: &cfg.GitHubAppFallbackKeyPath,
+		EnvVar: "GITHUB_APP_FALLBACK_PRIVATE_KEY_PATH",
+		Usage: `Path to a PEM encoded private key of the GitHub App, for example mounted from Secret Manager, ` +
+			`used when signing with the KMS key fails. Cannot be combined with kms-app-fallback-private-key-id.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:   "runner-project-id",
 		Target: &cfg.RunnerProjectID,
@@ -144,6 +901,24 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:  `Google Cloud project ID where the runner will execute.`,
 	})
 
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:    "runner-additional-project-ids",
+		Target:  &cfg.RunnerAdditionalProjectIDs,
+		EnvVar:  "RUNNER_ADDITIONAL_PROJECT_IDS",
+		Example: "runners-2,runners-3",
+		Usage: `Additional projects runner builds are spread across, multiplying the Cloud Build ` +
+			`concurrency quota. The runner service account must be usable by Cloud Build in each project.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-project-strategy",
+		Target:  &cfg.RunnerProjectStrategy,
+		EnvVar:  "RUNNER_PROJECT_STRATEGY",
+		Default: projectStrategyRoundRobin,
+		Usage: `How builds are spread across the runner projects, "round-robin" or "least-loaded". ` +
+			`Least-loaded picks the project with the fewest active runners of this instance.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:    "port",
 		Target:  &cfg.Port,
@@ -152,6 +927,30 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:   `The port the retry server listens to.`,
 	})
 
+	f.StringVar(&cli.StringVar{
+		Name:   "grpc-port",
+		Target: &cfg.GRPCPort,
+		EnvVar: "GRPC_PORT",
+		Usage: `The port the gRPC dispatch API listens to, for internal platforms requesting runners ` +
+			`programmatically. The dispatch API is disabled when not set.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "dispatch-api-audience",
+		Target:  &cfg.DispatchAPIAudience,
+		EnvVar:  "DISPATCH_API_AUDIENCE",
+		Example: "https://webhook.example.com",
+		Usage:   `The audience of the Google ID tokens dispatch API callers authenticate with.`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:    "dispatch-api-callers",
+		Target:  &cfg.DispatchAPICallers,
+		EnvVar:  "DISPATCH_API_CALLERS",
+		Example: "scheduler@my-project.iam.gserviceaccount.com",
+		Usage:   `The service accounts allowed to call the dispatch API.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:   "github-webhook-key-mount-path",
 		Target: &cfg.GitHubWebhookKeyMountPath,
@@ -166,6 +965,38 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:  `GitHub webhook key name.`,
 	})
 
+	f.BoolVar(&cli.BoolVar{
+		Name:   "cloud-profiler",
+		Target: &cfg.CloudProfiler,
+		EnvVar: "CLOUD_PROFILER",
+		Usage: `Whether to continuously upload CPU and heap profiles to Cloud Profiler. ` +
+			`The service account needs the Cloud Profiler Agent role.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "cloud-profiler-project-id",
+		Target: &cfg.CloudProfilerProjectID,
+		EnvVar: "CLOUD_PROFILER_PROJECT_ID",
+		Usage:  `The project the profiles are uploaded to when CLOUD_PROFILER is set.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "admin-token-path",
+		Target: &cfg.AdminTokenPath,
+		EnvVar: "ADMIN_TOKEN_PATH",
+		Usage: `The path of the file, for example a mounted secret, holding the bearer token of the admin API ` +
+			`under /admin/. The admin API is disabled when not set.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "delivery-archive-size",
+		Target:  &cfg.DeliveryArchiveSize,
+		EnvVar:  "DELIVERY_ARCHIVE_SIZE",
+		Default: 200,
+		Usage: `The number of recent verified webhook deliveries each instance keeps in memory, so ` +
+			`POST /admin/replay/<delivery_id> can re-run their processing. Only kept when the admin API is enabled.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:    "runner-image-name",
 		Target:  &cfg.RunnerImageName,
@@ -174,6 +1005,126 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:   `The runner image name.`,
 	})
 
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:    "runner-image-variants",
+		Target:  &cfg.RunnerImageVariants,
+		EnvVar:  "RUNNER_IMAGE_VARIANTS",
+		Example: "ubuntu-22,ubuntu-24",
+		Usage: `The additional runner image names, in the same repository and with the same tag, ` +
+			`that jobs may select with an image=<name> label.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-arm64-image-name",
+		Target: &cfg.RunnerARM64ImageName,
+		EnvVar: "RUNNER_ARM64_IMAGE_NAME",
+		Usage: `The runner image name of jobs with the ARM64 label, in the same repository and ` +
+			`with the same tag as the default image. Jobs with the ARM64 label are rejected when unset.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-arm64-worker-pool",
+		Target:  &cfg.RunnerARM64WorkerPool,
+		EnvVar:  "RUNNER_ARM64_WORKER_POOL",
+		Example: "projects/<project>/locations/<location>/workerPools/<pool>",
+		Usage:   `The private worker pool with arm64 machines the builds of ARM64 jobs run in, unless they select a pool.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-arm64-instance-template",
+		Target: &cfg.RunnerARM64InstanceTemplate,
+		EnvVar: "RUNNER_ARM64_INSTANCE_TEMPLATE",
+		Usage:  `The instance template with an arm64 machine type of the VMs of ARM64 jobs when RUNNER_BACKEND is "compute".`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-arm64-machine-type",
+		Target:  &cfg.RunnerARM64MachineType,
+		EnvVar:  "RUNNER_ARM64_MACHINE_TYPE",
+		Example: "c4a-standard-16",
+		Usage:   `The arm64 machine type of the Batch jobs of ARM64 jobs.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-gpu-types",
+		Target:  &cfg.RunnerGPUTypes,
+		EnvVar:  "RUNNER_GPU_TYPES",
+		Example: "t4=nvidia-tesla-t4,l4=nvidia-l4",
+		Usage: `The GPU types jobs may request with a gcp-gpu-<name> label, mapped to their ` +
+			`accelerator type. GPU runners run on Batch when RUNNER_BATCH_LOCATION is set, and ` +
+			`otherwise on Compute Engine VMs, whose instance template must have a machine type supporting the GPUs.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-gpu-image-name",
+		Target:  &cfg.RunnerGPUImageName,
+		EnvVar:  "RUNNER_GPU_IMAGE_NAME",
+		Default: "gpu-runner",
+		Usage: `The runner image name of jobs requesting a GPU, with the GPU drivers installed, ` +
+			`in the same repository and with the same tag as the default image.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-image-check",
+		Target: &cfg.RunnerImageCheck,
+		EnvVar: "RUNNER_IMAGE_CHECK",
+		Usage: `Whether to check the runner image tag exists in Artifact Registry before dispatching, and refuse ` +
+			`runners whose build would fail to pull it. Images outside Artifact Registry, and images whose lookup ` +
+			`fails, are allowed.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-image-check-ttl",
+		Target:  &cfg.RunnerImageCheckTTL,
+		EnvVar:  "RUNNER_IMAGE_CHECK_TTL",
+		Default: 5 * time.Minute,
+		Usage: `How long the existence, digest and attestation verification of a runner image are cached ` +
+			`before it is looked up again.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-image-pin-digest",
+		Target: &cfg.RunnerImagePinDigest,
+		EnvVar: "RUNNER_IMAGE_PIN_DIGEST",
+		Usage: `Whether to resolve the runner image tag to its digest in Artifact Registry when dispatching, ` +
+			`and run the runner by digest, so a tag moved during the build does not change the image.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-image-attestor",
+		Target:  &cfg.RunnerImageAttestor,
+		EnvVar:  "RUNNER_IMAGE_ATTESTOR",
+		Example: "projects/my-project/attestors/runner-images",
+		Usage: `The Binary Authorization attestor that must verify an attestation of the runner image digest ` +
+			`before dispatching. Images it does not verify, images outside Artifact Registry and images whose ` +
+			`verification fails are refused.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "vulnerability-gate",
+		Target:  &cfg.VulnerabilityGate,
+		EnvVar:  "VULNERABILITY_GATE",
+		Example: "enforce",
+		Usage: `Whether to check the Artifact Analysis scanning results of the runner image before dispatching, ` +
+			`and "warn" about or "enforce" refusing images with more critical vulnerabilities than ` +
+			`VULNERABILITY_MAX_CRITICAL. Images whose results cannot be looked up are allowed. Disabled when empty.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "vulnerability-max-critical",
+		Target: &cfg.VulnerabilityMaxCritical,
+		EnvVar: "VULNERABILITY_MAX_CRITICAL",
+		Usage:  `The number of critical vulnerabilities a runner image may have before the vulnerability gate applies.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "vulnerability-scan-ttl",
+		Target:  &cfg.VulnerabilityScanTTL,
+		EnvVar:  "VULNERABILITY_SCAN_TTL",
+		Default: 10 * time.Minute,
+		Usage:   `How long the scanning results of a runner image are cached before they are looked up again.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:   "runner-image-tag",
 		Target: &cfg.RunnerImageTag,
@@ -188,6 +1139,76 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:  `The GAR repository that holds the runner image`,
 	})
 
+	f.BoolVar(&cli.BoolVar{
+		Name:   "strict-payload-validation",
+		Target: &cfg.StrictPayloadValidation,
+		EnvVar: "STRICT_PAYLOAD_VALIDATION",
+		Usage: `Whether to reject workflow_job deliveries missing any field GitHub documents for ` +
+			`their action with 400, instead of only the fields needed to process them.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "shadow-mode",
+		Target: &cfg.ShadowMode,
+		EnvVar: "SHADOW_MODE",
+		Usage: `Whether to only log the runner builds the server would create. A shadow deployment ` +
+			`receiving the same events as production evaluates the full dispatch pipeline without registering ` +
+			`runners, creating builds, removing runners, notifying or publishing lifecycle events.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "shared-settings-object",
+		Target:  &cfg.SharedSettingsObject,
+		EnvVar:  "SHARED_SETTINGS_OBJECT",
+		Example: "gs://my-bucket/webhook/settings.json",
+		Usage: `A Cloud Storage JSON object of settings every instance applies at runtime: maintenance_mode, ` +
+			`max_runners per instance and canary_percents by runner profile. Invalid generations are ignored.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "shared-settings-poll-interval",
+		Target:  &cfg.SharedSettingsPollInterval,
+		EnvVar:  "SHARED_SETTINGS_POLL_INTERVAL",
+		Default: 30 * time.Second,
+		Usage:   `How often the generation of the shared settings object is checked.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "skip-startup-checks",
+		Target: &cfg.SkipStartupChecks,
+		EnvVar: "SKIP_STARTUP_CHECKS",
+		Usage: `Whether to start without probing KMS signing, GitHub App authentication, the ` +
+			`runner images and the Cloud Build API. By default the server refuses to start if any probe fails.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "state-store-database",
+		Target:  &cfg.StateStoreDatabase,
+		EnvVar:  "STATE_STORE_DATABASE",
+		Example: "projects/<project_id>/databases/<database_id>",
+		Usage: `The Firestore database recording the delivery, job, runner, build and lifecycle timestamps ` +
+			`of every runner, so they survive restarts. The runners still active are restored on startup ` +
+			`and counted by every instance. The state is kept in memory only when unset.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-repositories",
+		Target:  &cfg.RunnerRepositories,
+		EnvVar:  "RUNNER_REPOSITORIES",
+		Example: "asia=asia-docker.pkg.dev/<project>/runners",
+		Usage: `Additional named GAR repositories holding the runner images, for example replicas ` +
+			`in the regions of the worker pools. Jobs select one with a registry=<name> label.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-repository-assignments",
+		Target:  &cfg.RunnerRepositoryAssignments,
+		EnvVar:  "RUNNER_REPOSITORY_ASSIGNMENTS",
+		Example: "my-org/my-repo=asia",
+		Usage: `Assigns organizations (<org>) or repositories (<org>/<repo>) to one of the ` +
+			`RUNNER_REPOSITORIES when their jobs do not have a registry=<name> label.`,
+	})
+
 	f.StringVar(&cli.StringVar{
 		Name:   "runner-service-account",
 		Target: &cfg.RunnerServiceAccount,
@@ -202,5 +1223,941 @@ func (cfg *Config) ToFlags(set *cli.FlagSet) *cli.FlagSet {
 		Usage:  `The private runner worker pool ID`,
 	})
 
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-require-private-network",
+		Target: &cfg.RunnerRequirePrivateNetwork,
+		EnvVar: "RUNNER_REQUIRE_PRIVATE_NETWORK",
+		Usage: `Whether to refuse to start unless every worker pool is peered with a VPC network ` +
+			`and has no public egress. Requires RUNNER_WORKER_POOL_ID.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-security-mode",
+		Target:  &cfg.RunnerSecurityMode,
+		EnvVar:  "RUNNER_SECURITY_MODE",
+		Default: runnerSecurityPrivileged,
+		Usage: `How the runner container runs: "privileged" with a root Docker daemon, "rootless" ` +
+			`unprivileged with a rootless Docker daemon, which still needs seccomp and AppArmor ` +
+			`unconfined, or "unprivileged" without any security override, where jobs cannot use Docker.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-http-proxy",
+		Target:  &cfg.RunnerHTTPProxy,
+		EnvVar:  "RUNNER_HTTP_PROXY",
+		Example: "http://proxy.internal:3128",
+		Usage: `The HTTP proxy set in the runner container and its Docker daemon. ` +
+			`May be KMS ciphertext in the form kms://<key name>/<base64 ciphertext>, decrypted at startup.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-https-proxy",
+		Target:  &cfg.RunnerHTTPSProxy,
+		EnvVar:  "RUNNER_HTTPS_PROXY",
+		Example: "http://proxy.internal:3128",
+		Usage: `The HTTPS proxy set in the runner container and its Docker daemon. ` +
+			`May be KMS ciphertext in the form kms://<key name>/<base64 ciphertext>, decrypted at startup.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-job-started-hook",
+		Target:  &cfg.RunnerJobStartedHook,
+		EnvVar:  "RUNNER_JOB_STARTED_HOOK",
+		Example: "gs://runner-hooks/job-started.sh",
+		Usage: `A script the runners run before every job, as ACTIONS_RUNNER_HOOK_JOB_STARTED. Either a ` +
+			`gs://<bucket>/<object> the runner service account can read, or the path of a local script of at most ` +
+			`16 KiB, for example a mounted secret, read at startup and passed to the runners in their build.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-job-completed-hook",
+		Target:  &cfg.RunnerJobCompletedHook,
+		EnvVar:  "RUNNER_JOB_COMPLETED_HOOK",
+		Example: "gs://runner-hooks/job-completed.sh",
+		Usage: `A script the runners run after every job, as ACTIONS_RUNNER_HOOK_JOB_COMPLETED. Accepts the ` +
+			`same locations as runner-job-started-hook.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-no-proxy",
+		Target:  &cfg.RunnerNoProxy,
+		EnvVar:  "RUNNER_NO_PROXY",
+		Example: ".internal,metadata.google.internal",
+		Usage:   `The comma separated hosts the runner reaches without going through the proxy.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-worker-pools",
+		Target:  &cfg.RunnerWorkerPools,
+		EnvVar:  "RUNNER_WORKER_POOLS",
+		Example: "private-xl=projects/<project>/locations/<location>/workerPools/<pool>",
+		Usage: `Additional named private worker pools, for example with VPC access to internal ` +
+			`services. Jobs select one with a pool=<name> label.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-pool-warm-interval",
+		Target: &cfg.RunnerPoolWarmInterval,
+		EnvVar: "RUNNER_POOL_WARM_INTERVAL",
+		Usage: `How often to run a build on each worker pool that pulls the runner images, keeping them ` +
+			`cached on the pool workers to cut the image pull from cold starts. Warming builds use pool ` +
+			`capacity and are tagged warm-pool. Disabled when 0.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "queue-ttl",
+		Target: &cfg.QueueTTL,
+		EnvVar: "QUEUE_TTL",
+		Usage: `The maximum age of a queued job a runner is launched for. Queued events of older jobs, ` +
+			`such as stale redeliveries and replays, are ignored. Disabled when 0.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "recommendation-interval",
+		Target: &cfg.RecommendationInterval,
+		EnvVar: "RECOMMENDATION_INTERVAL",
+		Usage: `How often to compute scaling recommendations (warm pool size per label, dispatch ` +
+			`concurrency and Cloud Build quota) from the provisioning latency and dispatch queue depth ` +
+			`this instance recorded, served at /admin/recommendations. Disabled when 0.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "recommendation-window",
+		Target:  &cfg.RecommendationWindow,
+		EnvVar:  "RECOMMENDATION_WINDOW",
+		Default: 24 * time.Hour,
+		Usage:   `How far back the history scaling recommendations are computed from goes.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "recommendation-target-latency",
+		Target:  &cfg.RecommendationTargetLatency,
+		EnvVar:  "RECOMMENDATION_TARGET_LATENCY",
+		Default: time.Minute,
+		Usage: `The p90 provisioning latency above which a warm pool is recommended for the jobs ` +
+			`requesting a set of labels.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-forecast-interval",
+		Target:  &cfg.RunnerForecastInterval,
+		EnvVar:  "RUNNER_FORECAST_INTERVAL",
+		Example: "15m",
+		Usage: `The interval queued jobs are counted over, per repository and set of labels, to forecast ` +
+			`the jobs of each interval of the week from the same interval of past weeks. Runners are ` +
+			`provisioned ahead of the forecast jobs at the start of each interval. Disabled when 0.`,
+	})
+
+	f.Float64Var(&cli.Float64Var{
+		Name:    "runner-forecast-aggressiveness",
+		Target:  &cfg.RunnerForecastAggressiveness,
+		EnvVar:  "RUNNER_FORECAST_AGGRESSIVENESS",
+		Default: 1,
+		Usage: `The factor applied to the forecast jobs of an interval to get the runners provisioned ` +
+			`ahead of them. Below 1 to only cover part of the expected demand.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-forecast-max-runners",
+		Target:  &cfg.RunnerForecastMaxRunners,
+		EnvVar:  "RUNNER_FORECAST_MAX_RUNNERS",
+		Default: 10,
+		Usage:   `The maximum number of runners provisioned ahead of the forecast jobs of an interval.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-forecast-state-object",
+		Target:  &cfg.RunnerForecastStateObject,
+		EnvVar:  "RUNNER_FORECAST_STATE_OBJECT",
+		Example: "gs://<bucket>/forecast.json",
+		Usage: `The Cloud Storage object the forecast learned from past weeks is kept in across restarts, ` +
+			`written after every interval. The forecast is kept in memory only when unset.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "google-chat-webhook-url",
+		Target: &cfg.GoogleChatWebhookURL,
+		EnvVar: "GOOGLE_CHAT_WEBHOOK_URL",
+		Usage: `The Google Chat space incoming webhook URL for operator notifications. Notifications are disabled when unset. ` +
+			`May be KMS ciphertext in the form kms://<key name>/<base64 ciphertext>, decrypted at startup.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "google-chat-rate-interval",
+		Target:  &cfg.GoogleChatRateInterval,
+		EnvVar:  "GOOGLE_CHAT_RATE_INTERVAL",
+		Default: time.Minute,
+		Usage:   `The minimum interval between Google Chat notifications once the initial burst is used up.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "lifecycle-events-topic",
+		Target:  &cfg.LifecycleEventsTopic,
+		EnvVar:  "LIFECYCLE_EVENTS_TOPIC",
+		Example: "projects/<project_id>/topics/<topic_id>",
+		Usage:   `The Pub/Sub topic that receives job lifecycle events. Publishing is disabled when unset.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "pagerduty-routing-key",
+		Target: &cfg.PagerDutyRoutingKey,
+		EnvVar: "PAGERDUTY_ROUTING_KEY",
+		Usage: `The PagerDuty Events API v2 integration routing key. Escalation is disabled when unset. The ` +
+			`queue is watched in the background, so the service must run with CPU always allocated. ` +
+			`May be KMS ciphertext in the form kms://<key name>/<base64 ciphertext>, decrypted at startup.`,
+	})
+
+	f.Float64Var(&cli.Float64Var{
+		Name:    "pagerduty-failure-rate-threshold",
+		Target:  &cfg.PagerDutyFailureRate,
+		EnvVar:  "PAGERDUTY_FAILURE_RATE_THRESHOLD",
+		Default: 0.5,
+		Usage:   `The fraction of failed runner dispatches within the window above which on-call is paged.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "pagerduty-min-dispatches",
+		Target:  &cfg.PagerDutyMinDispatches,
+		EnvVar:  "PAGERDUTY_MIN_DISPATCHES",
+		Default: 5,
+		Usage:   `The minimum number of dispatches within the window before the failure rate is evaluated.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "pagerduty-queue-age-threshold",
+		Target:  &cfg.PagerDutyQueueAge,
+		EnvVar:  "PAGERDUTY_QUEUE_AGE_THRESHOLD",
+		Default: 15 * time.Minute,
+		Usage: `The average job queue time within the window, or the time the oldest job still queued has ` +
+			`waited, above which on-call is paged. Set to 0 to disable.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "pagerduty-window",
+		Target:  &cfg.PagerDutyWindow,
+		EnvVar:  "PAGERDUTY_WINDOW",
+		Default: 5 * time.Minute,
+		Usage:   `The sliding window dispatch outcomes and queue times are aggregated over.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "pagerduty-sustain-period",
+		Target:  &cfg.PagerDutySustainPeriod,
+		EnvVar:  "PAGERDUTY_SUSTAIN_PERIOD",
+		Default: 10 * time.Minute,
+		Usage:   `How long a threshold must stay exceeded before on-call is paged.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-skip-if-idle",
+		Target: &cfg.RunnerSkipIfIdle,
+		EnvVar: "RUNNER_SKIP_IF_IDLE",
+		Usage: `Whether no runner is provisioned for a queued job when an online, idle runner of its ` +
+			`repository has all of its labels, such as one provisioned ahead of demand. Each idle ` +
+			`runner is only counted for one job at a time.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-reuse-max-jobs",
+		Target: &cfg.RunnerReuseMaxJobs,
+		EnvVar: "RUNNER_REUSE_MAX_JOBS",
+		Usage: `The number of jobs a runner serves before terminating. Above 1, runners are registered ` +
+			`as regular, non-ephemeral runners instead of just-in-time runners, and queued jobs are left ` +
+			`to idle runners of their repository, cutting the startup time of bursty repositories. ` +
+			`Reusable runners are tracked in memory, so the service must run on a single instance with ` +
+			`CPU always allocated. Set to 0 or 1 for ephemeral runners.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-reuse-max-duration",
+		Target:  &cfg.RunnerReuseMaxDuration,
+		EnvVar:  "RUNNER_REUSE_MAX_DURATION",
+		Default: time.Hour,
+		Usage: `How long after its dispatch a reusable runner takes new jobs. Idle runners past it are ` +
+			`drained, busy ones once their job completes.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-cancel-unused-builds",
+		Target: &cfg.RunnerCancelUnusedBuilds,
+		EnvVar: "RUNNER_CANCEL_UNUSED_BUILDS",
+		Usage: `Whether the runners launched for a job are cancelled when the job completes, or is ` +
+			`cancelled, without them, such as a runner still pulling its image. Runners that took ` +
+			`another job are left alone.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-spot",
+		Target: &cfg.RunnerSpot,
+		EnvVar: "RUNNER_SPOT",
+		Usage: `Whether all runner VMs run on spot capacity when RUNNER_BACKEND is "compute". ` +
+			`Jobs can also request spot capacity with the "gcp-spot" label.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-offline-cleanup-interval",
+		Target: &cfg.RunnerOfflineCleanupInterval,
+		EnvVar: "RUNNER_OFFLINE_CLEANUP_INTERVAL",
+		Usage: `How often the runners named with the GCP- prefix that crashed before deregistering are ` +
+			`removed, in every organization the app is installed in and in the repositories with ` +
+			`runners of this instance. Set to 0 to disable.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-offline-threshold",
+		Target:  &cfg.RunnerOfflineThreshold,
+		EnvVar:  "RUNNER_OFFLINE_THRESHOLD",
+		Default: 30 * time.Minute,
+		Usage: `How long a runner is seen offline before it is removed by the offline runner cleanup. ` +
+			`GitHub does not report since when a runner is offline, so it is counted from the first ` +
+			`cleanup that found it offline.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-reconcile-interval",
+		Target: &cfg.RunnerReconcileInterval,
+		EnvVar: "RUNNER_RECONCILE_INTERVAL",
+		Usage: `How often builds whose runner is no longer registered are cancelled and offline ` +
+			`runners whose build is gone are removed, across every runner project and location. Orphans ` +
+			`are only cleaned up once found twice in a row. Only applies when runners run on Cloud Build ` +
+			`only. Set to 0 to only reconcile through POST /admin/reconcile.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-spot-check-interval",
+		Target:  &cfg.RunnerSpotCheckInterval,
+		EnvVar:  "RUNNER_SPOT_CHECK_INTERVAL",
+		Default: time.Minute,
+		Usage: `How often spot runners are checked for preemption. Runners preempted before ` +
+			`picking up their job are relaunched. Set to 0 to disable.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-spot-max-relaunches",
+		Target:  &cfg.RunnerSpotMaxRelaunches,
+		EnvVar:  "RUNNER_SPOT_MAX_RELAUNCHES",
+		Default: 3,
+		Usage:   `How many times a preempted spot runner is relaunched on spot capacity before falling back to standard capacity.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-stall-threshold",
+		Target: &cfg.RunnerStallThreshold,
+		EnvVar: "RUNNER_STALL_THRESHOLD",
+		Usage: `How long an in progress runner may go without log output before it is flagged as stalled. ` +
+			`Runners are checked in the background, so the service must run with CPU always allocated. ` +
+			`Set to 0 to disable.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-stall-check-interval",
+		Target:  &cfg.RunnerStallCheckInterval,
+		EnvVar:  "RUNNER_STALL_CHECK_INTERVAL",
+		Default: time.Minute,
+		Usage:   `How often in progress runners are checked for stalled output.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-failure-check-interval",
+		Target: &cfg.RunnerFailureCheckInterval,
+		EnvVar: "RUNNER_FAILURE_CHECK_INTERVAL",
+		Usage: `How often the builds of runners that have not picked up their job yet are ` +
+			`checked for failures, which are reported to GitHub as a check run. Set to 0 to disable.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-backend",
+		Target:  &cfg.RunnerBackend,
+		EnvVar:  "RUNNER_BACKEND",
+		Default: runnerBackendCloudBuild,
+		Usage: `Where runners run: "cloudbuild" runs each runner in a Cloud Build build, "compute" on an ` +
+			`ephemeral Compute Engine VM created from RUNNER_INSTANCE_TEMPLATE, without the runtime limits ` +
+			`of builds, "gke" in a Kubernetes Job of RUNNER_GKE_CLUSTER. VMs and Jobs are deleted when ` +
+			`their job completes.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-instance-template",
+		Target:  &cfg.RunnerInstanceTemplate,
+		EnvVar:  "RUNNER_INSTANCE_TEMPLATE",
+		Example: "projects/my-project/global/instanceTemplates/runner",
+		Usage: `The instance template of the runner VMs when RUNNER_BACKEND is "compute". Its boot image ` +
+			`must run Docker, like Container-Optimized OS, and its service account must be able to pull ` +
+			`the runner images. The startup-script metadata is set by the webhook service.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-instance-zone",
+		Target:  &cfg.RunnerInstanceZone,
+		EnvVar:  "RUNNER_INSTANCE_ZONE",
+		Example: "us-central1-a",
+		Usage:   `The zone runner VMs are created in when RUNNER_BACKEND is "compute".`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-gke-cluster",
+		Target:  &cfg.RunnerGKECluster,
+		EnvVar:  "RUNNER_GKE_CLUSTER",
+		Example: "projects/my-project/locations/us-central1/clusters/runners",
+		Usage: `The GKE cluster runner Jobs are created in when RUNNER_BACKEND is "gke". The service ` +
+			`account of the webhook service must be able to create Jobs and Secrets in RUNNER_GKE_NAMESPACE, ` +
+			`and the nodes must allow privileged containers for the Docker daemon of the runner.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-gke-namespace",
+		Target:  &cfg.RunnerGKENamespace,
+		EnvVar:  "RUNNER_GKE_NAMESPACE",
+		Default: "default",
+		Usage:   `The Kubernetes namespace runner Jobs are created in when RUNNER_BACKEND is "gke".`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-gke-service-account",
+		Target:  &cfg.RunnerGKEServiceAccount,
+		EnvVar:  "RUNNER_GKE_SERVICE_ACCOUNT",
+		Example: "github-runner",
+		Usage: `The Kubernetes service account of runner pods when RUNNER_BACKEND is "gke", for example ` +
+			`one bound to a Google service account through Workload Identity. The default service ` +
+			`account of the namespace when empty.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-batch-location",
+		Target:  &cfg.RunnerBatchLocation,
+		EnvVar:  "RUNNER_BATCH_LOCATION",
+		Example: "us-central1",
+		Usage: `The region jobs with the "batch" label run in as Cloud Batch jobs of the runner project, ` +
+			`for machine shapes, GPUs and timeouts Cloud Build does not offer. The service account of ` +
+			`the webhook service must be able to create Batch jobs. Disabled when empty.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-batch-machine-type",
+		Target:  &cfg.RunnerBatchMachineType,
+		EnvVar:  "RUNNER_BATCH_MACHINE_TYPE",
+		Default: "n2-standard-16",
+		Usage:   `The machine type of the VMs of Batch runners.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-batch-accelerator-type",
+		Target:  &cfg.RunnerBatchAcceleratorType,
+		EnvVar:  "RUNNER_BATCH_ACCELERATOR_TYPE",
+		Example: "nvidia-l4",
+		Usage: `The GPU attached to the VMs of Batch runners, whose drivers Batch installs. No GPU ` +
+			`when empty.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-batch-accelerator-count",
+		Target:  &cfg.RunnerBatchAcceleratorCount,
+		EnvVar:  "RUNNER_BATCH_ACCELERATOR_COUNT",
+		Default: 1,
+		Usage:   `The number of GPUs attached to the VMs of Batch runners.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-batch-max-run-duration",
+		Target:  &cfg.RunnerBatchMaxRunDuration,
+		EnvVar:  "RUNNER_BATCH_MAX_RUN_DURATION",
+		Default: 24 * time.Hour,
+		Usage: `How long Batch runners may run before Batch stops them, unless the timeout of their ` +
+			`build is longer.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-batch-service-account",
+		Target:  &cfg.RunnerBatchServiceAccount,
+		EnvVar:  "RUNNER_BATCH_SERVICE_ACCOUNT",
+		Example: "batch-runner@my-project.iam.gserviceaccount.com",
+		Usage: `The email of the service account of the VMs of Batch runners. It must be able to pull ` +
+			`the runner images and, with RUNNER_JIT_CONFIG_SECRETS, access the JIT config secrets. ` +
+			`The Compute Engine default service account when empty.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-fallback-instance-group",
+		Target:  &cfg.RunnerFallbackInstanceGroup,
+		EnvVar:  "RUNNER_FALLBACK_INSTANCE_GROUP",
+		Example: "projects/my-project/zones/us-central1-a/instanceGroupManagers/runners",
+		Usage: `The zonal managed instance group runners are dispatched to when Cloud Build refuses ` +
+			`a runner build for exhausted quota. Each runner gets its own VM, whose runner-name and ` +
+			`jit-config (or jit-config-secret) metadata the instance template's startup script ` +
+			`reads to start the runner. Disabled when empty.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-propagate-job-timeout",
+		Target: &cfg.RunnerPropagateJobTimeout,
+		EnvVar: "RUNNER_PROPAGATE_JOB_TIMEOUT",
+		Usage: `Whether to read the timeout-minutes of the job from its workflow file and ` +
+			`set the build timeout to match. Requires the Actions and Contents read permissions.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-prewarm-on-approval",
+		Target: &cfg.RunnerPrewarmOnApproval,
+		EnvVar: "RUNNER_PREWARM_ON_APPROVAL",
+		Usage: `Whether to provision the runners of jobs waiting for a deployment environment ` +
+			`approval as soon as the deployment is approved, instead of when the job is queued. ` +
+			`Requires the app to subscribe to Deployment review events.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-job-timeout-margin",
+		Target:  &cfg.RunnerJobTimeoutMargin,
+		EnvVar:  "RUNNER_JOB_TIMEOUT_MARGIN",
+		Default: 10 * time.Minute,
+		Usage:   `The time added on top of the job timeout for the runner to start and clean up.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-jit-config-secrets",
+		Target: &cfg.RunnerJITConfigSecrets,
+		EnvVar: "RUNNER_JIT_CONFIG_SECRETS",
+		Usage: `Whether to hand runners their JIT configuration through a short-lived Secret Manager secret ` +
+			`instead of a build substitution anyone who can view the build can read. The webhook service account ` +
+			`needs to create and delete secrets in the runner project and set their IAM policy. It grants the runner ` +
+			`(and Batch) service account access to each runner's own secret, and deletes the secret once the job is ` +
+			`in progress. The VMs of the fallback instance group must run as the runner service account.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-jit-config-secret-ttl",
+		Target:  &cfg.RunnerJITConfigSecretTTL,
+		EnvVar:  "RUNNER_JIT_CONFIG_SECRET_TTL",
+		Default: time.Hour,
+		Usage:   `How long a JIT configuration secret lives if the webhook service does not delete it, for example because the job is never picked up.`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:   "runner-registry-mirrors",
+		Target: &cfg.RunnerRegistryMirrors,
+		EnvVar: "RUNNER_REGISTRY_MIRRORS",
+		Usage: `The registry mirrors the runner's Docker daemon pulls Docker Hub images through, ` +
+			`for example an Artifact Registry remote repository (https://us-docker.pkg.dev/project/dockerhub).`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:   "runner-insecure-registries",
+		Target: &cfg.RunnerInsecureRegistries,
+		EnvVar: "RUNNER_INSECURE_REGISTRIES",
+		Usage:  `The internal registries, as host[:port] or CIDR, the runner's Docker daemon may reach without TLS.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-toolcache-bucket",
+		Target: &cfg.RunnerToolcacheBucket,
+		EnvVar: "RUNNER_TOOLCACHE_BUCKET",
+		Usage: `The Cloud Storage bucket, optionally followed by /<dir>, holding a pre-populated ` +
+			`toolcache that is mounted into the runner with gcsfuse. The runner service account needs read access.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-toolcache-snapshot",
+		Target:  &cfg.RunnerToolcacheSnapshot,
+		EnvVar:  "RUNNER_TOOLCACHE_SNAPSHOT",
+		Example: "projects/my-project/global/snapshots/toolcache",
+		Usage: `The Compute Engine snapshot of a disk holding a pre-populated toolcache at its root. Each amd64 ` +
+			`runner VM gets its own disk created from it, mounted as the toolcache of the runner and deleted with ` +
+			`the VM. Only applies to the compute backend.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-logs-bucket",
+		Target: &cfg.RunnerLogsBucket,
+		EnvVar: "RUNNER_LOGS_BUCKET",
+		Usage: `The Cloud Storage bucket the runner build logs are streamed to, in addition to Cloud Logging, ` +
+			`under <org>/<repo>/<run ID>/<job ID>/. Its lifecycle rules set the retention. ` +
+			`The runner service account needs write access.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-artifacts-bucket",
+		Target: &cfg.RunnerArtifactsBucket,
+		EnvVar: "RUNNER_ARTIFACTS_BUCKET",
+		Usage: `The Cloud Storage bucket jobs upload large artifacts to through short-lived signed URLs, ` +
+			`under <org>/<repo>/<run ID>/<job ID>/. Runners request the URLs from POST /artifacts/upload-url ` +
+			`with a token scoped to their job, for example with the upload-artifact command of the runner image.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-artifacts-endpoint",
+		Target: &cfg.RunnerArtifactsEndpoint,
+		EnvVar: "RUNNER_ARTIFACTS_ENDPOINT",
+		Usage:  `The URL runners reach this service at to request artifact upload URLs.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-artifacts-signer-service-account",
+		Target: &cfg.RunnerArtifactsSigner,
+		EnvVar: "RUNNER_ARTIFACTS_SIGNER_SERVICE_ACCOUNT",
+		Usage: `The email of the service account signing artifact upload URLs. It needs write access to the ` +
+			`artifacts bucket, and the webhook service account needs the Service Account Token Creator role on it.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-artifacts-url-ttl",
+		Target:  &cfg.RunnerArtifactsURLTTL,
+		EnvVar:  "RUNNER_ARTIFACTS_URL_TTL",
+		Default: 15 * time.Minute,
+		Usage:   `How long artifact upload URLs are valid, at most 7 days.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-actions-cache-bucket",
+		Target: &cfg.RunnerActionsCacheBucket,
+		EnvVar: "RUNNER_ACTIONS_CACHE_BUCKET",
+		Usage: `The Cloud Storage bucket backing the actions/cache protocol served under /actions-cache/, ` +
+			`with entries under <org>/<repo>/. Runners are pointed at it with ACTIONS_CACHE_URL. Its lifecycle ` +
+			`rules evict entries and abandoned uploads. Chunks of 32 MiB exceed the HTTP/1 request limit of Cloud Run, ` +
+			`serve it with HTTP/2 end-to-end.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-actions-cache-endpoint",
+		Target: &cfg.RunnerActionsCacheEndpoint,
+		EnvVar: "RUNNER_ACTIONS_CACHE_ENDPOINT",
+		Usage:  `The URL runners reach this service at to read and write the actions cache.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-actions-cache-signer-service-account",
+		Target: &cfg.RunnerActionsCacheSigner,
+		EnvVar: "RUNNER_ACTIONS_CACHE_SIGNER_SERVICE_ACCOUNT",
+		Usage: `The email of the service account signing actions cache download URLs. It needs read access to the ` +
+			`actions cache bucket, and the webhook service account needs the Service Account Token Creator role on it.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:   "runner-toolcache-compat",
+		Target: &cfg.RunnerToolcacheCompat,
+		EnvVar: "RUNNER_TOOLCACHE_COMPAT",
+		Usage: `Whether to give the runner a writable tool cache at /opt/hostedtoolcache, with RUNNER_TOOL_CACHE ` +
+			`set, and check the runner image for the commands the setup-* actions need, whatever the base image. ` +
+			`Runner profiles can override it with toolcache_compat.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-profiles-path",
+		Target: &cfg.RunnerProfilesPath,
+		EnvVar: "RUNNER_PROFILES_PATH",
+		Usage: `The path of a YAML file defining runner profiles. Jobs select a profile with a ` +
+			`"profile=<name>" label and fall back to the "default" profile.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "dispatch-policy-path",
+		Target: &cfg.DispatchPolicyPath,
+		EnvVar: "DISPATCH_POLICY_PATH",
+		Usage: `The path of a YAML file with dispatch policy rules. Each rule is a CEL expression ` +
+			`over the runner request that allows or denies it and may override its profile and timeout.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-group-mappings-path",
+		Target: &cfg.RunnerGroupMappingsPath,
+		EnvVar: "RUNNER_GROUP_MAPPINGS_PATH",
+		Usage: `The path of a YAML file mapping repositories, by topic, custom property or team, ` +
+			`to an organization runner group and runner profile.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-extra-substitutions",
+		Target:  &cfg.RunnerExtraSubstitutions,
+		EnvVar:  "RUNNER_EXTRA_SUBSTITUTIONS",
+		Example: "_CACHE_BUCKET=team-cache,_FEATURE_X=on",
+		Usage: `Extra substitutions added to every runner build, for the steps of RUNNER_BUILD_TEMPLATE. ` +
+			`They do not override the substitutions of the webhook service.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-extra-env",
+		Target:  &cfg.RunnerExtraEnv,
+		EnvVar:  "RUNNER_EXTRA_ENV",
+		Example: "PIP_INDEX_URL=https://pypi.internal/simple,FEATURE_X=on",
+		Usage: `Extra environment variables passed to every runner container. Values must not ` +
+			`contain whitespace, quotes, $, ` + "`" + ` or \.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-secrets",
+		Target:  &cfg.RunnerSecrets,
+		EnvVar:  "RUNNER_SECRETS",
+		Example: "NPM_TOKEN=npm-token,SONAR_TOKEN=projects/shared/secrets/sonar-token/versions/3",
+		Usage: `Environment variables passed to every runner container, read from Secret Manager when ` +
+			`the runner starts. Secrets are named in RUNNER_PROJECT_ID or in full, and default to their ` +
+			`latest version. The runner service account needs to access each secret.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-metadata-env",
+		Target:  &cfg.RunnerMetadataEnv,
+		EnvVar:  "RUNNER_METADATA_ENV",
+		Example: "PIP_INDEX_URL=project/attributes/pip-index-url,GCP_PROJECT=project/project-id",
+		Usage: `Environment variables passed to every runner container, read from the given path ` +
+			`of the metadata server when the runner starts. A value that cannot be read is left empty.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:    "runner-multi-build-window",
+		Target:  &cfg.RunnerMultiBuildWindow,
+		EnvVar:  "RUNNER_MULTI_BUILD_WINDOW",
+		Example: "2s",
+		Usage: `How long runners of the same workflow run, like those of a matrix, wait for each other ` +
+			`to be launched in one build running them in parallel. Deliveries are answered once the ` +
+			`build is created, so it must be well within DELIVERY_BUDGET. Disabled when 0.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-multi-build-max-runners",
+		Target:  &cfg.RunnerMultiBuildMaxRunners,
+		EnvVar:  "RUNNER_MULTI_BUILD_MAX_RUNNERS",
+		Default: 10,
+		Usage: `The maximum number of runners launched in one build when RUNNER_MULTI_BUILD_WINDOW is set. ` +
+			`With RUNNER_DISPATCH_RATE, at most RUNNER_DISPATCH_CONCURRENCY runners wait for a build at once.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-build-machine-type",
+		Target:  &cfg.RunnerBuildMachineType,
+		EnvVar:  "RUNNER_BUILD_MACHINE_TYPE",
+		Example: "E2_HIGHCPU_8",
+		Usage: `The Cloud Build machine type of runner builds in the default pool. Builds in a ` +
+			`private worker pool run on the machine type of the pool.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-build-disk-size-gb",
+		Target: &cfg.RunnerBuildDiskSizeGB,
+		EnvVar: "RUNNER_BUILD_DISK_SIZE_GB",
+		Usage: `The disk size, in GB, of runner builds in the default pool, the Cloud Build default when 0. ` +
+			`Builds in a private worker pool have the disk size of the pool.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-build-timeout",
+		Target: &cfg.RunnerBuildTimeout,
+		EnvVar: "RUNNER_BUILD_TIMEOUT",
+		Usage: `The timeout of runner builds, the Cloud Build default of 1 hour when 0. The timeout of ` +
+			`the job, when RUNNER_PROPAGATE_JOB_TIMEOUT is set, and of dispatch policies take precedence.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-build-template",
+		Target:  &cfg.RunnerBuildTemplate,
+		EnvVar:  "RUNNER_BUILD_TEMPLATE",
+		Example: "gs://<bucket>/cloudbuild.yaml",
+		Usage: `The path, or Cloud Storage location, of a cloudbuild.yaml template whose steps, options, ` +
+			`timeout and substitutions replace those of the runner build. Its run step runs the runner ` +
+			`and must pass it the ENCODED_JIT_CONFIG environment variable, set to $_ENCODED_JIT_CONFIG.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "runner-build-trigger",
+		Target:  &cfg.RunnerBuildTrigger,
+		EnvVar:  "RUNNER_BUILD_TRIGGER",
+		Example: "github-runner",
+		Usage: `The ID or name of a build trigger, in the project and location of each runner, that ` +
+			`runner builds are run from instead of being created, so their definition, approvals and ` +
+			`IAM stay with the trigger. Only the substitutions of the runner build are passed to it: ` +
+			`its run step must pass $_ENCODED_JIT_CONFIG and $_IMAGE_TAG to the runner and it must ` +
+			`allow loose substitutions.`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:    "runner-fallback-locations",
+		Target:  &cfg.RunnerFallbackLocations,
+		EnvVar:  "RUNNER_FALLBACK_LOCATIONS",
+		Example: "us-east1,europe-west1",
+		Usage: `The locations, in order, runner builds are retried in when Cloud Build quota is ` +
+			`exhausted in RUNNER_LOCATION. Builds in a worker pool are not retried.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-project-routes-path",
+		Target: &cfg.RunnerProjectRoutesPath,
+		EnvVar: "RUNNER_PROJECT_ROUTES_PATH",
+		Usage: `The path of a YAML file routing the runner builds of organizations (<org>) or ` +
+			`repositories (<org>/<repo>) to their own project, location and service account. ` +
+			`The webhook service account needs to create builds in each project.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-image-mappings-path",
+		Target: &cfg.RunnerImageMappingsPath,
+		EnvVar: "RUNNER_IMAGE_MAPPINGS_PATH",
+		Usage: `The path of a YAML file mapping sets of runner labels to a runner image name, ` +
+			`tag and repository, so teams can request their own runner image.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "runner-cache-bucket",
+		Target: &cfg.RunnerCacheBucket,
+		EnvVar: "RUNNER_CACHE_BUCKET",
+		Usage: `The Cloud Storage bucket the dependency caches declared by runner profiles are persisted in. ` +
+			`The runner service account needs read and write access.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "repository-dispatch-event-type",
+		Target:  &cfg.RepositoryDispatchEventType,
+		EnvVar:  "REPOSITORY_DISPATCH_EVENT_TYPE",
+		Default: "provision-runner",
+		Usage:   `The repository_dispatch event type that provisions runners ahead of demand. Set to empty to disable.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "repository-dispatch-max-runners",
+		Target:  &cfg.RepositoryDispatchMaxRunners,
+		EnvVar:  "REPOSITORY_DISPATCH_MAX_RUNNERS",
+		Default: 10,
+		Usage:   `The maximum number of runners a single repository_dispatch event may provision.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-max-count",
+		Target:  &cfg.RunnerMaxCount,
+		EnvVar:  "RUNNER_MAX_COUNT",
+		Default: 1,
+		Usage: `The maximum number of runners a queued job may request with a count=<n> label, for ` +
+			`workflows about to fan out into many jobs. The runners beyond the job's own are provisioned ` +
+			`ahead of demand without the count label. Larger counts are capped, 1 disables the label.`,
+	})
+
+	f.StringSliceVar(&cli.StringSliceVar{
+		Name:    "runner-blocked-actors",
+		Target:  &cfg.RunnerBlockedActors,
+		EnvVar:  "RUNNER_BLOCKED_ACTORS",
+		Example: "octocat,compromised-bot",
+		Usage:   `The GitHub logins whose events never provision runners, for example compromised accounts.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-actor-max-runners",
+		Target: &cfg.RunnerActorMaxRunners,
+		EnvVar: "RUNNER_ACTOR_MAX_RUNNERS",
+		Usage: `The maximum number of runners the jobs triggered by a single GitHub login may have at once, ` +
+			`counted per instance. Set to 0 for no limit.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-actor-limits",
+		Target:  &cfg.RunnerActorLimits,
+		EnvVar:  "RUNNER_ACTOR_LIMITS",
+		Example: "release-bot=50",
+		Usage:   `Overrides RUNNER_ACTOR_MAX_RUNNERS for individual GitHub logins. Set to 0 for no limit.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-repo-max-runners",
+		Target: &cfg.RunnerRepoMaxRunners,
+		EnvVar: "RUNNER_REPO_MAX_RUNNERS",
+		Usage: `The maximum number of runners a single repository may have at once. ` +
+			`Runners of queued jobs beyond it are deferred until the repository's runners go away. ` +
+			`Runners are counted and deferred in memory, so the service must run on a single instance ` +
+			`with CPU always allocated, and deferred runners are lost on restart. Set to 0 for no limit.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-org-max-runners",
+		Target: &cfg.RunnerOrgMaxRunners,
+		EnvVar: "RUNNER_ORG_MAX_RUNNERS",
+		Usage: `The maximum number of runners a single organization may have at once. ` +
+			`Runners of queued jobs beyond it are deferred until the organization's runners go away. ` +
+			`Like RUNNER_REPO_MAX_RUNNERS, it requires a single instance with CPU always allocated. ` +
+			`Set to 0 for no limit.`,
+	})
+
+	f.StringMapVar(&cli.StringMapVar{
+		Name:    "runner-scope-limits",
+		Target:  &cfg.RunnerScopeLimits,
+		EnvVar:  "RUNNER_SCOPE_LIMITS",
+		Example: "my-org=200,my-org/monorepo=50",
+		Usage: `Overrides RUNNER_ORG_MAX_RUNNERS for individual organizations and RUNNER_REPO_MAX_RUNNERS ` +
+			`for individual org/repo repositories. Set to 0 for no limit.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:   "runner-max-concurrent-builds",
+		Target: &cfg.RunnerMaxConcurrentBuilds,
+		EnvVar: "RUNNER_MAX_CONCURRENT_BUILDS",
+		Usage: `The maximum number of runner builds running at once. Runners requested beyond it ` +
+			`wait in the launch queue and are launched, in order, as runners go away. Builds are counted ` +
+			`and queued in memory, so the service must run on a single instance with CPU always ` +
+			`allocated, and queued runners are lost on restart. Set to 0 for no limit.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-max-lifetime",
+		Target: &cfg.RunnerMaxLifetime,
+		EnvVar: "RUNNER_MAX_LIFETIME",
+		Usage: `The maximum time a runner may live from its dispatch, whatever its job is doing. Older ` +
+			`runners have their build or VM cancelled and are removed from GitHub, so a hung job does ` +
+			`not hold its machine indefinitely. Runners are checked in the background, so the service ` +
+			`must run with CPU always allocated. Set to 0 for no limit.`,
+	})
+
+	f.DurationVar(&cli.DurationVar{
+		Name:   "runner-idle-grace-period",
+		Target: &cfg.RunnerIdleGracePeriod,
+		EnvVar: "RUNNER_IDLE_GRACE_PERIOD",
+		Usage: `How long after its dispatch a runner may go without picking up a job. Runners of a job ` +
+			`that another runner took, or that was cancelled before being picked up, are torn down once ` +
+			`it passes and GitHub reports them idle. Runners are checked in the background, so the service ` +
+			`must run with CPU always allocated. Set to 0 to leave them to the backend timeout.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-launch-queue-size",
+		Target:  &cfg.RunnerLaunchQueueSize,
+		EnvVar:  "RUNNER_LAUNCH_QUEUE_SIZE",
+		Default: 1000,
+		Usage: `The maximum number of runners waiting for a build slot. Events beyond it are answered ` +
+			`with a 503 so they can be redelivered.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-deferred-queue-size",
+		Target:  &cfg.RunnerDeferredQueueSize,
+		EnvVar:  "RUNNER_DEFERRED_QUEUE_SIZE",
+		Default: 1000,
+		Usage: `The maximum number of runners deferred over a repository or organization limit. Queued ` +
+			`events beyond it are answered with a 503 so they can be redelivered.`,
+	})
+
+	f.Float64Var(&cli.Float64Var{
+		Name:   "runner-dispatch-rate",
+		Target: &cfg.RunnerDispatchRate,
+		EnvVar: "RUNNER_DISPATCH_RATE",
+		Usage: `The number of runners dispatched per second. When set, queued jobs are acknowledged ` +
+			`immediately and dispatched in the background to smooth out bursts. Set to 0 to dispatch synchronously.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-dispatch-burst",
+		Target:  &cfg.RunnerDispatchBurst,
+		EnvVar:  "RUNNER_DISPATCH_BURST",
+		Default: 5,
+		Usage:   `The number of runners that may be dispatched at once above RUNNER_DISPATCH_RATE.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-dispatch-concurrency",
+		Target:  &cfg.RunnerDispatchConcurrency,
+		EnvVar:  "RUNNER_DISPATCH_CONCURRENCY",
+		Default: 4,
+		Usage:   `The maximum number of runners being dispatched concurrently when RUNNER_DISPATCH_RATE is set.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "runner-dispatch-queue-size",
+		Target:  &cfg.RunnerDispatchQueueSize,
+		EnvVar:  "RUNNER_DISPATCH_QUEUE_SIZE",
+		Default: 1000,
+		Usage:   `The maximum number of runners waiting to be dispatched. Deliveries are rejected with 503 when the queue is full.`,
+	})
+
 	return set
 }
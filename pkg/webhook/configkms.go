@@ -0,0 +1,103 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// encryptedValuePrefix marks a setting supplied as KMS ciphertext, in the form
+// kms://<key name>/<base64 ciphertext>. The ciphertext is for example the
+// output of "gcloud kms encrypt", base64 encoded.
+const encryptedValuePrefix = "kms://"
+
+// secretSetting is a setting that may be supplied as KMS ciphertext.
+type secretSetting struct {
+	name  string
+	value *string
+}
+
+// secretSettings returns the settings holding credentials, which may be
+// supplied as KMS ciphertext instead of in plaintext.
+func (cfg *Config) secretSettings() []secretSetting {
+	return []secretSetting{
+		{"GITHUB_PROXY_URL", &cfg.GitHubProxyURL},
+		{"GOOGLE_CHAT_WEBHOOK_URL", &cfg.GoogleChatWebhookURL},
+		{"PAGERDUTY_ROUTING_KEY", &cfg.PagerDutyRoutingKey},
+		{"RUNNER_HTTP_PROXY", &cfg.RunnerHTTPProxy},
+		{"RUNNER_HTTPS_PROXY", &cfg.RunnerHTTPSProxy},
+	}
+}
+
+// hasEncryptedValues reports whether any setting is supplied as KMS
+// ciphertext.
+func (cfg *Config) hasEncryptedValues() bool {
+	return slices.ContainsFunc(cfg.secretSettings(), func(s secretSetting) bool {
+		return isEncryptedValue(*s.value)
+	})
+}
+
+func isEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix)
+}
+
+// parseEncryptedValue returns the name of the KMS key and the ciphertext of a
+// setting supplied as KMS ciphertext.
+func parseEncryptedValue(value string) (string, []byte, error) {
+	// The ciphertext is the rest of the value, base64 may contain slashes.
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedValuePrefix), "/", 9)
+	if len(parts) != 9 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "keyRings" ||
+		parts[6] != "cryptoKeys" || slices.Contains(parts, "") {
+		return "", nil, fmt.Errorf("must be in the form kms://projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>/<base64 ciphertext>")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[8])
+	if err != nil {
+		return "", nil, fmt.Errorf("ciphertext is not base64 encoded: %w", err)
+	}
+	return strings.Join(parts[:8], "/"), ciphertext, nil
+}
+
+// decryptConfig returns a copy of the config with the settings supplied as KMS
+// ciphertext decrypted with the key they name. The caller needs the Cloud KMS
+// CryptoKey Decrypter role on the keys.
+func decryptConfig(ctx context.Context, kmc KeyManagementClient, cfg *Config) (*Config, error) {
+	decrypted := *cfg
+	for _, setting := range decrypted.secretSettings() {
+		if !isEncryptedValue(*setting.value) {
+			continue
+		}
+		keyName, ciphertext, err := parseEncryptedValue(*setting.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s is invalid: %w", setting.name, err)
+		}
+		plaintext, err := kmc.Decrypt(ctx, keyName, ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", setting.name, err)
+		}
+		// Files encrypted with gcloud usually end with a newline.
+		*setting.value = strings.TrimSpace(string(plaintext))
+	}
+
+	// Encrypted proxies are validated once decrypted.
+	if err := validateProxyURLs(&decrypted); err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// countLabelPrefix selects the number of runners provisioned for a queued
+// job, for workflows about to fan out into many jobs with the same labels.
+const countLabelPrefix = "count="
+
+// runnerCount returns the number of runners requested by the count= label,
+// 1 without the label. Counts above maxCount are capped.
+func runnerCount(labels []string, maxCount int) (int, error) {
+	v, ok := labelValue(labels, countLabelPrefix)
+	if !ok {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 1, fmt.Errorf("count label %q is not a positive number", countLabelPrefix+v)
+	}
+	return min(n, maxCount), nil
+}
+
+// provisionExtraRunners provisions the runners a queued job requested beyond
+// its own. They are provisioned ahead of demand, for the jobs the workflow
+// fans out into: they are not tied to a job and do not carry the count= label,
+// so they match jobs with the same labels without it. Failures are logged and
+// stop the remaining runners, the job's own runner is already dispatched.
+func (s *Server) provisionExtraRunners(ctx context.Context, req *runnerRequest, count int, logFields []any) {
+	labels := make([]string, 0, len(req.Labels))
+	for _, label := range req.Labels {
+		if !strings.HasPrefix(label, countLabelPrefix) {
+			labels = append(labels, label)
+		}
+	}
+
+	for i := 1; i < count; i++ {
+		runnerID := fmt.Sprintf("%s-%d", req.RunnerName, i)
+		runnerFields := append(append([]any{}, logFields...), "runner_id", runnerID, "count_index", i)
+		extra := &runnerRequest{
+			InstallationID: req.InstallationID,
+			Org:            req.Org,
+			Repo:           req.Repo,
+			RunnerName:     runnerID,
+			Labels:         labels,
+			Actor:          req.Actor,
+			DeliveryID:     req.DeliveryID,
+		}
+
+		if s.dispatchQueue != nil {
+			if resp := s.enqueueRunner(ctx, extra, runnerFields); resp.Code != http.StatusAccepted {
+				return
+			}
+			continue
+		}
+
+		createdBuild, errResponse := s.provisionRunner(ctx, extra, runnerFields)
+		if errResponse != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "stopped provisioning runners requested by count label",
+				append(runnerFields, "count", count, "error", errResponse.Error, "response_message", errResponse.Message)...)
+			return
+		}
+		s.runnerDispatched(ctx, extra, createdBuild, runnerFields)
+	}
+}
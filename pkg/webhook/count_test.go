@@ -0,0 +1,135 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerCount(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		labels  []string
+		want    int
+		wantErr string
+	}{
+		{
+			name:   "no_label",
+			labels: []string{"self-hosted"},
+			want:   1,
+		},
+		{
+			name:   "count",
+			labels: []string{"self-hosted", "count=4"},
+			want:   4,
+		},
+		{
+			name:   "capped",
+			labels: []string{"self-hosted", "count=50"},
+			want:   10,
+		},
+		{
+			name:    "not_a_number",
+			labels:  []string{"self-hosted", "count=many"},
+			want:    1,
+			wantErr: `count label "count=many" is not a positive number`,
+		},
+		{
+			name:    "zero",
+			labels:  []string{"self-hosted", "count=0"},
+			want:    1,
+			wantErr: `count label "count=0" is not a positive number`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := runnerCount(tc.labels, 10)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d to be %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProvisionExtraRunners(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		count     int
+		queueSize int
+		wantNames []string
+	}{
+		{
+			name:      "single",
+			count:     1,
+			queueSize: 10,
+		},
+		{
+			name:      "extra_runners",
+			count:     3,
+			queueSize: 10,
+			wantNames: []string{"GCP-2-1", "GCP-2-2"},
+		},
+		{
+			name:      "queue_full",
+			count:     4,
+			queueSize: 1,
+			wantNames: []string{"GCP-2-1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{dispatchQueue: newDispatchQueue(1, 1, 1, tc.queueSize)}
+			s.provisionExtraRunners(t.Context(), &runnerRequest{
+				InstallationID: 123,
+				Org:            "google",
+				Repo:           "webhook",
+				RunnerName:     "GCP-2",
+				Labels:         []string{"self-hosted", "count=3", "pool=xl"},
+				DeliveryID:     "delivery-id",
+			}, tc.count, nil)
+			close(s.dispatchQueue.items)
+
+			var gotNames []string
+			for item := range s.dispatchQueue.items {
+				gotNames = append(gotNames, item.req.RunnerName)
+				if diff := cmp.Diff([]string{"self-hosted", "pool=xl"}, item.req.Labels); diff != "" {
+					t.Errorf("unexpected labels (-want, +got):\n%s", diff)
+				}
+				if item.req.Job != nil {
+					t.Errorf("expected extra runners not to be tied to a job")
+				}
+			}
+			if diff := cmp.Diff(tc.wantNames, gotNames); diff != "" {
+				t.Errorf("unexpected runners (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
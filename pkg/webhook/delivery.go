@@ -0,0 +1,56 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/google/uuid"
+)
+
+const deliveryIDLogKey = "delivery_id"
+
+type deliveryIDContextKey struct{}
+
+// withDeliveryID returns a shallow copy of r whose context carries the
+// X-GitHub-Delivery ID, and a logger that adds it to every record. Requests
+// without a delivery ID get a random one so they can still be correlated.
+func withDeliveryID(r *http.Request) *http.Request {
+	deliveryID := github.DeliveryID(r)
+	if deliveryID == "" {
+		deliveryID = uuid.NewString()
+	}
+
+	ctx := contextWithDeliveryID(r.Context(), deliveryID)
+	return r.WithContext(ctx)
+}
+
+// contextWithDeliveryID returns a context carrying the delivery ID, and a
+// logger that adds it to every record.
+func contextWithDeliveryID(ctx context.Context, deliveryID string) context.Context {
+	ctx = context.WithValue(ctx, deliveryIDContextKey{}, deliveryID)
+	return logging.WithLogger(ctx, logging.FromContext(ctx).With(deliveryIDLogKey, deliveryID))
+}
+
+// deliveryIDFromContext returns the delivery ID carried by ctx, or the empty
+// string if there is none.
+func deliveryIDFromContext(ctx context.Context) string {
+	deliveryID, _ := ctx.Value(deliveryIDContextKey{}).(string)
+	return deliveryID
+}
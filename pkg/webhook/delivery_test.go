@@ -0,0 +1,36 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithDeliveryID(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set(DeliveryIDHeader, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	if got, want := deliveryIDFromContext(withDeliveryID(req).Context()), "72d3162e-cc78-11e3-81ab-4c9367dc0958"; got != want {
+		t.Errorf("expected delivery id %q to be %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	if got := deliveryIDFromContext(withDeliveryID(req).Context()); got == "" {
+		t.Errorf("expected a delivery id to be generated")
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+const (
+	// runnerFailureCheckName is the name of the check run created on the
+	// commit when a runner fails to start.
+	runnerFailureCheckName = "github-actions-on-gcp / runner"
+
+	// runnerFailureLogLines is the number of trailing build log lines inspected
+	// to classify a runner failure.
+	runnerFailureLogLines = 200
+)
+
+// runnerFailure describes why a runner build failed before picking up a job.
+type runnerFailure struct {
+	Reason      string
+	Remediation string
+}
+
+// dindFailurePatterns maps substrings of the Docker daemon output to the
+// failure they indicate. The first match wins, so more specific patterns come
+// first.
+var dindFailurePatterns = []struct {
+	substr  string
+	failure *runnerFailure
+}{
+	{
+		substr: "apparmor",
+		failure: &runnerFailure{
+			Reason:      "The Docker daemon inside the runner was blocked by AppArmor.",
+			Remediation: "Run the runner container unconfined (--security-opt apparmor=unconfined) or use a worker pool that does not enforce an AppArmor profile.",
+		},
+	},
+	{
+		substr: "seccomp",
+		failure: &runnerFailure{
+			Reason:      "The Docker daemon inside the runner was blocked by seccomp.",
+			Remediation: "Run the runner container with --security-opt seccomp=unconfined or use a worker pool that allows the required syscalls.",
+		},
+	},
+	{
+		substr: "cgroup",
+		failure: &runnerFailure{
+			Reason:      "The Docker daemon inside the runner could not set up cgroups.",
+			Remediation: "Make sure the runner container is privileged and the host exposes a writable cgroup hierarchy (cgroup v2 requires --cgroupns=host).",
+		},
+	},
+	{
+		substr: "operation not permitted",
+		failure: &runnerFailure{
+			Reason:      "The Docker daemon inside the runner was denied a privileged operation.",
+			Remediation: "Make sure the runner container is started with --privileged.",
+		},
+	},
+	{
+		substr: "docker daemon did not become available",
+		failure: &runnerFailure{
+			Reason:      "The Docker daemon inside the runner did not start in time.",
+			Remediation: "Check the build log for the dockerd output and make sure the worker pool machine type has enough resources.",
+		},
+	},
+}
+
+// classifyRunnerFailure inspects the tail of a failed runner build log and
+// returns the most likely cause.
+func classifyRunnerFailure(build *cloudbuildpb.Build, lines []string) *runnerFailure {
+	for _, p := range dindFailurePatterns {
+		for _, line := range lines {
+			if strings.Contains(strings.ToLower(line), p.substr) {
+				return p.failure
+			}
+		}
+	}
+
+	if build.GetStatus() == cloudbuildpb.Build_TIMEOUT {
+		return &runnerFailure{
+			Reason:      "The runner build timed out before picking up the job.",
+			Remediation: "Check that the runner can reach GitHub and that the job labels match the runner.",
+		}
+	}
+
+	reason := "The runner build failed before picking up the job."
+	if detail := build.GetStatusDetail(); detail != "" {
+		reason = fmt.Sprintf("The runner build failed before picking up the job: %s.", detail)
+	}
+	return &runnerFailure{
+		Reason:      reason,
+		Remediation: "Check the runner build log for details.",
+	}
+}
+
+// runFailureMonitor periodically checks the builds of dispatched runners until
+// the context is cancelled.
+func (s *Server) runFailureMonitor(ctx context.Context) {
+	ticker := time.NewTicker(s.failureCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkFailedRunners(ctx)
+		}
+	}
+}
+
+// checkFailedRunners looks for runners whose build finished before their job
+// started. Those jobs would otherwise stay queued without any explanation, so
+// the failure is classified and reported on the commit as a check run.
+func (s *Server) checkFailedRunners(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	for _, r := range s.runners.List() {
+		state := r.Lifecycle.State()
+		if (state != lifecycle.StateProvisioning && state != lifecycle.StateOnline) || r.BuildID == "" {
+			continue
+		}
+
+		// The build may have been created in a fallback location.
+		location := r.Location
+		if location == "" {
+			location = s.runnerLocation
+		}
+		build, err := s.cbc.GetBuild(ctx, &cloudbuildpb.GetBuildRequest{
+			Name:      fmt.Sprintf("projects/%s/locations/%s/builds/%s", r.ProjectID, location, r.BuildID),
+			ProjectId: r.ProjectID,
+			Id:        r.BuildID,
+		})
+		if err != nil {
+			logger.WarnContext(ctx, "failed to get runner build",
+				"runner_id", r.RunnerName,
+				"build_id", r.BuildID,
+				"error", err)
+			continue
+		}
+
+		switch build.GetStatus() {
+		case cloudbuildpb.Build_STATUS_UNKNOWN, cloudbuildpb.Build_PENDING, cloudbuildpb.Build_QUEUED:
+			continue
+		case cloudbuildpb.Build_WORKING:
+			if state == lifecycle.StateProvisioning {
+				s.transitionRunner(ctx, r.RunnerName, lifecycle.StateOnline)
+			}
+			continue
+		}
+
+		// A runner whose build succeeded without picking up its job was
+		// orphaned, e.g. because another runner took the job.
+		to := lifecycle.StateFailed
+		if build.GetStatus() == cloudbuildpb.Build_SUCCESS {
+			to = lifecycle.StateOrphaned
+		}
+		s.transitionRunner(ctx, r.RunnerName, to)
+		if _, ok := s.runners.Remove(r.RunnerName); !ok || to == lifecycle.StateOrphaned {
+			continue
+		}
+
+		lines, err := s.logReader.TailLines(ctx, r.ProjectID, r.BuildID, runnerFailureLogLines)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to read runner build logs",
+				"runner_id", r.RunnerName,
+				"build_id", r.BuildID,
+				"error", err)
+		}
+
+		failure := classifyRunnerFailure(build, lines)
+		logger.ErrorContext(ctx, "runner build failed before starting job",
+			"runner_id", r.RunnerName,
+			"build_id", r.BuildID,
+			"build_status", build.GetStatus().String(),
+			"org", r.Org,
+			"repo", r.Repo,
+			"run_id", r.RunID,
+			"job_id", r.JobID,
+			"reason", failure.Reason)
+
+		if err := s.reportRunnerFailure(ctx, &r, build, failure); err != nil {
+			logger.ErrorContext(ctx, "failed to report runner failure to github",
+				"runner_id", r.RunnerName,
+				"error", err)
+		}
+		s.notify(ctx, &Notification{
+			Kind:  NotificationDispatchFailure,
+			Title: fmt.Sprintf("Runner failed to start for %s/%s", r.Org, r.Repo),
+			Text: fmt.Sprintf("Runner %s (build %s, run %d, job %d): %s",
+				r.RunnerName, r.BuildID, r.RunID, r.JobID, failure.Reason),
+		})
+	}
+}
+
+// reportRunnerFailure creates a failed check run on the commit of the job
+// describing why its runner did not start.
+func (s *Server) reportRunnerFailure(ctx context.Context, r *trackedRunner, build *cloudbuildpb.Build, failure *runnerFailure) error {
+	if r.HeadSHA == "" {
+		return nil
+	}
+
+	gh, err := s.installationClient(ctx, r.InstallationID, map[string]string{
+		"checks": "write",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to setup installation client: %w", err)
+	}
+
+	summary := fmt.Sprintf("%s\n\n**How to fix:** %s", failure.Reason, failure.Remediation)
+	text := fmt.Sprintf("Runner `%s` for job %d of run %d ended with build status `%s`.",
+		r.RunnerName, r.JobID, r.RunID, build.GetStatus().String())
+	if logURL := build.GetLogUrl(); logURL != "" {
+		text += fmt.Sprintf("\n\n[Build log](%s)", logURL)
+	}
+
+	if _, _, err := gh.Checks.CreateCheckRun(ctx, r.Org, r.Repo, github.CreateCheckRunOptions{
+		Name:       runnerFailureCheckName,
+		HeadSHA:    r.HeadSHA,
+		Status:     github.Ptr("completed"),
+		Conclusion: github.Ptr("failure"),
+		Output: &github.CheckRunOutput{
+			Title:   github.Ptr("Self-hosted runner failed to start"),
+			Summary: github.Ptr(summary),
+			Text:    github.Ptr(text),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create check run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+func TestClassifyRunnerFailure(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		build      *cloudbuildpb.Build
+		lines      []string
+		wantReason string
+	}{
+		{
+			name:  "apparmor",
+			build: &cloudbuildpb.Build{Status: cloudbuildpb.Build_FAILURE},
+			lines: []string{
+				"Waiting for Docker daemon to become available at /var/run/docker.sock...",
+				`failed to start daemon: Error initializing network controller: error creating default "bridge" network: AppArmor enabled on system but the docker-default profile could not be loaded`,
+			},
+			wantReason: "blocked by AppArmor",
+		},
+		{
+			name:       "cgroup",
+			build:      &cloudbuildpb.Build{Status: cloudbuildpb.Build_FAILURE},
+			lines:      []string{"failed to start daemon: Devices cgroup isn't mounted"},
+			wantReason: "could not set up cgroups",
+		},
+		{
+			name:       "daemon_timeout",
+			build:      &cloudbuildpb.Build{Status: cloudbuildpb.Build_FAILURE},
+			lines:      []string{"Timeout: Docker daemon did not become available after 60 seconds."},
+			wantReason: "did not start in time",
+		},
+		{
+			name:       "build_timeout",
+			build:      &cloudbuildpb.Build{Status: cloudbuildpb.Build_TIMEOUT},
+			wantReason: "timed out",
+		},
+		{
+			name:       "unknown",
+			build:      &cloudbuildpb.Build{Status: cloudbuildpb.Build_FAILURE, StatusDetail: "step exited with non-zero status: 1"},
+			lines:      []string{"something else went wrong"},
+			wantReason: "non-zero status",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := classifyRunnerFailure(tc.build, tc.lines)
+			if !strings.Contains(got.Reason, tc.wantReason) {
+				t.Errorf("expected reason to contain %q, got %q", tc.wantReason, got.Reason)
+			}
+		})
+	}
+}
+
+func TestCheckFailedRunners(t *testing.T) {
+	t.Parallel()
+
+	notifier := &recordingNotifier{}
+	s := &Server{
+		cbc: &MockCloudBuildClient{
+			getBuildRes: &cloudbuildpb.Build{Id: "build-1", Status: cloudbuildpb.Build_FAILURE},
+		},
+		logReader: &MockBuildLogReader{
+			tailLines: map[string][]string{"build-1": {"failed to start daemon: seccomp profile rejected"}},
+		},
+		notifier: notifier,
+		runners:  newRunnerTracker(),
+	}
+	s.runners.Dispatched(&trackedRunner{
+		RunnerName: "GCP-1",
+		BuildID:    "build-1",
+		Org:        "google",
+		Repo:       "repo",
+		Lifecycle:  testLifecycle(t, lifecycle.StateProvisioning, time.Now()),
+	})
+
+	s.checkFailedRunners(t.Context())
+
+	if got := len(s.runners.List()); got != 0 {
+		t.Errorf("expected failed runner to no longer be tracked, got %d runners", got)
+	}
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(notifier.notifications))
+	}
+	if got, want := notifier.notifications[0].Text, "blocked by seccomp"; !strings.Contains(got, want) {
+		t.Errorf("expected notification to contain %q, got %q", want, got)
+	}
+}
+
+func TestCheckFailedRunners_Online(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		cbc: &MockCloudBuildClient{
+			getBuildRes: &cloudbuildpb.Build{Id: "build-1", Status: cloudbuildpb.Build_WORKING},
+		},
+		runners: newRunnerTracker(),
+	}
+	s.runners.Dispatched(&trackedRunner{
+		RunnerName: "GCP-1",
+		BuildID:    "build-1",
+		Lifecycle:  testLifecycle(t, lifecycle.StateProvisioning, time.Now()),
+	})
+
+	s.checkFailedRunners(t.Context())
+
+	runners := s.runners.List()
+	if len(runners) != 1 {
+		t.Fatalf("expected runner to still be tracked, got %d runners", len(runners))
+	}
+	if got, want := runners[0].Lifecycle.State(), lifecycle.StateOnline; got != want {
+		t.Errorf("expected state %q to be %q", got, want)
+	}
+}
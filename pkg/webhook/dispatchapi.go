@@ -0,0 +1,230 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/uuid"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/github_actions_on_gcp/pkg/dispatchpb"
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// IDTokenValidator adheres to the interaction the webhook service has with
+// Google to validate the ID tokens of dispatch API callers.
+type IDTokenValidator interface {
+	Validate(ctx context.Context, idToken, audience string) (*idtoken.Payload, error)
+}
+
+// GRPCServer returns the gRPC server of the dispatch API, which requests
+// runners through the same pipeline as queued workflow_job events. Callers
+// authenticate with a Google ID token for the dispatch API audience, issued to
+// one of the allowed caller service accounts.
+func (s *Server) GRPCServer(ctx context.Context) *grpc.Server {
+	logger := logging.FromContext(ctx)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		logging.GRPCUnaryInterceptor(logger, s.runnerProjectID),
+		s.authenticateDispatchCaller,
+	))
+	dispatchpb.RegisterDispatchServiceServer(srv, &dispatchService{s: s})
+	return srv
+}
+
+// authenticateDispatchCaller rejects calls without a valid ID token of an
+// allowed caller.
+func (s *Server) authenticateDispatchCaller(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token, _ = strings.CutPrefix(values[0], "Bearer ")
+		}
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	logger := logging.FromContext(ctx)
+	payload, err := s.idTokens.Validate(ctx, token, s.dispatchAPIAudience)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected dispatch API call with an invalid ID token",
+			"method", info.FullMethod,
+			"error", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid ID token")
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+	if !verified || !slices.Contains(s.dispatchAPICallers, email) {
+		logger.WarnContext(ctx, "rejected dispatch API call of a caller that is not allowed",
+			"method", info.FullMethod,
+			"caller", email)
+		return nil, status.Errorf(codes.PermissionDenied, "caller %q is not allowed", email)
+	}
+
+	ctx = logging.WithLogger(ctx, logger.With("caller", email))
+	return handler(ctx, req)
+}
+
+// dispatchService implements the dispatch API.
+type dispatchService struct {
+	dispatchpb.UnimplementedDispatchServiceServer
+
+	s *Server
+}
+
+// DispatchRunner provisions a runner like for a queued job, generating a
+// runner name when the caller does not provide one.
+func (d *dispatchService) DispatchRunner(ctx context.Context, req *dispatchpb.DispatchRunnerRequest) (*dispatchpb.DispatchRunnerResponse, error) {
+	if req.GetInstallationId() == 0 || req.GetOrg() == "" || req.GetRepo() == "" {
+		return nil, status.Error(codes.InvalidArgument, "installation_id, org and repo are required")
+	}
+	runnerName := req.GetRunnerName()
+	if runnerName == "" {
+		runnerName = runnerNamePrefix + uuid.NewString()
+	}
+	// Completed jobs are only attributed to this service by the name prefix.
+	if !strings.HasPrefix(runnerName, runnerNamePrefix) {
+		return nil, status.Errorf(codes.InvalidArgument, "runner_name must start with %s", runnerNamePrefix)
+	}
+	if d.s.inMaintenance() {
+		return nil, status.Error(codes.Unavailable, maintenanceMsg)
+	}
+
+	build, err := d.s.ProvisionRunner(ctx, &RunnerRequest{
+		InstallationID: req.GetInstallationId(),
+		Org:            req.GetOrg(),
+		Repo:           req.GetRepo(),
+		RunnerName:     runnerName,
+		Labels:         req.GetLabels(),
+		Actor:          req.GetActor(),
+	})
+	if err != nil {
+		var dispatchErr *DispatchError
+		if !errors.As(err, &dispatchErr) {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return nil, status.Error(dispatchErrorCode(dispatchErr.Code), dispatchErr.Message)
+	}
+
+	return &dispatchpb.DispatchRunnerResponse{
+		RunnerName: runnerName,
+		BuildId:    build.GetId(),
+		ProjectId:  build.GetProjectId(),
+	}, nil
+}
+
+// dispatchErrorCode maps the status code a webhook delivery would have been
+// answered with to a gRPC code. Deliveries are answered with a 200 when the
+// runner is refused, e.g. by the dispatch policy or a limit.
+func dispatchErrorCode(code int) codes.Code {
+	switch code {
+	case http.StatusOK:
+		return codes.FailedPrecondition
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// CancelRunner cancels a runner this instance dispatched in its backend and
+// removes the runner from its repository, so it does not pick up a job.
+func (d *dispatchService) CancelRunner(ctx context.Context, req *dispatchpb.CancelRunnerRequest) (*dispatchpb.CancelRunnerResponse, error) {
+	s := d.s
+	logger := logging.FromContext(ctx)
+
+	r, ok := s.trackedRunner(req.GetRunnerName())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "runner %q was not dispatched by this instance", req.GetRunnerName())
+	}
+	backend := s.backendNamed(r.Backend)
+	if r.BackendID == "" || backend == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "runner %q has no build", r.RunnerName)
+	}
+	logFields := []any{
+		"org", r.Org,
+		"repo", r.Repo,
+		"runner_id", r.RunnerName,
+		"build_id", r.BuildID,
+		"backend", r.Backend,
+		"backend_id", r.BackendID,
+	}
+
+	if err := backend.Cancel(ctx, r.BackendID); err != nil {
+		logger.ErrorContext(ctx, "failed to cancel runner", append(logFields, "error", err)...)
+		return nil, status.Error(codes.Internal, "failed to cancel runner build")
+	}
+
+	if err := s.removeRunner(ctx, r.InstallationID, r.Org, r.Repo, r.RunnerName); err != nil {
+		logger.WarnContext(ctx, "failed to remove cancelled runner", append(logFields, "error", err)...)
+	}
+
+	s.transitionRunner(ctx, r.RunnerName, lifecycle.StateFailed)
+	s.runners.Remove(r.RunnerName)
+	logger.InfoContext(ctx, "cancelled runner", logFields...)
+
+	return &dispatchpb.CancelRunnerResponse{BuildId: r.BuildID}, nil
+}
+
+// GetJobStatus returns the runner this instance dispatched for a job, or
+// under a runner name.
+func (d *dispatchService) GetJobStatus(ctx context.Context, req *dispatchpb.GetJobStatusRequest) (*dispatchpb.GetJobStatusResponse, error) {
+	if req.GetJobId() == 0 && req.GetRunnerName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id or runner_name is required")
+	}
+
+	for _, r := range d.s.runners.List() {
+		if (req.GetJobId() != 0 && r.JobID == req.GetJobId()) || (req.GetJobId() == 0 && r.RunnerName == req.GetRunnerName()) {
+			return &dispatchpb.GetJobStatusResponse{
+				RunnerName: r.RunnerName,
+				State:      string(r.Lifecycle.State()),
+				BuildId:    r.BuildID,
+				ProjectId:  r.ProjectID,
+				RunId:      r.RunID,
+				JobId:      r.JobID,
+			}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "no runner dispatched by this instance matches the request")
+}
+
+// trackedRunner returns a copy of the tracked runner with the given name.
+func (s *Server) trackedRunner(runnerName string) (trackedRunner, bool) {
+	for _, r := range s.runners.List() {
+		if r.RunnerName == runnerName {
+			return r, true
+		}
+	}
+	return trackedRunner{}, false
+}
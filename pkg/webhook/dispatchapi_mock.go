@@ -0,0 +1,39 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/idtoken"
+)
+
+// MockIDTokenValidator returns the payload of the token in Payloads, or Err.
+type MockIDTokenValidator struct {
+	Payloads map[string]*idtoken.Payload
+	Err      error
+}
+
+func (m *MockIDTokenValidator) Validate(ctx context.Context, idToken, audience string) (*idtoken.Payload, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	payload, ok := m.Payloads[idToken]
+	if !ok || payload.Audience != audience {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return payload, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"golang.org/x/time/rate"
+)
+
+const runnerQueuedMsg = "runner queued for dispatch"
+
+// dispatchItem is a runner waiting in the dispatch queue.
+type dispatchItem struct {
+	req       *runnerRequest
+	logFields []any
+}
+
+// dispatchQueue smooths bursts of queued jobs by dispatching runners in the
+// background at a controlled rate, with a bounded number of dispatches in
+// flight. Items still in the queue when the server shuts down are lost.
+type dispatchQueue struct {
+	items       chan *dispatchItem
+	limiter     *rate.Limiter
+	concurrency int
+}
+
+// newDispatchQueue creates a queue dispatching perSecond runners on average,
+// up to burst at once and at most concurrency at the same time.
+func newDispatchQueue(perSecond float64, burst, concurrency, size int) *dispatchQueue {
+	return &dispatchQueue{
+		items:       make(chan *dispatchItem, size),
+		limiter:     rate.NewLimiter(rate.Limit(perSecond), burst),
+		concurrency: concurrency,
+	}
+}
+
+// enqueue adds the item to the queue without blocking and reports whether
+// there was room for it.
+func (q *dispatchQueue) enqueue(item *dispatchItem) bool {
+	select {
+	case q.items <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueueRunner adds the runner to the dispatch queue. It returns 503 when
+// the queue is full so the delivery is marked as failed and can be
+// redelivered.
+func (s *Server) enqueueRunner(ctx context.Context, req *runnerRequest, logFields []any) *apiResponse {
+	logger := logging.FromContext(ctx)
+
+	if !s.dispatchQueue.enqueue(&dispatchItem{req: req, logFields: logFields}) {
+		logger.WarnContext(ctx, "dispatch queue is full", logFields...)
+		return &apiResponse{http.StatusServiceUnavailable, "dispatch queue is full", nil}
+	}
+	s.provisioningHistory.recordQueueDepth(time.Now(), len(s.dispatchQueue.items))
+
+	logger.InfoContext(ctx, runnerQueuedMsg, append(logFields, "queue_depth", len(s.dispatchQueue.items))...)
+	return &apiResponse{http.StatusAccepted, runnerQueuedMsg, nil}
+}
+
+// runDispatchQueue dispatches queued runners until ctx is done.
+func (s *Server) runDispatchQueue(ctx context.Context) {
+	for range s.dispatchQueue.concurrency {
+		go s.runDispatchWorker(ctx)
+	}
+}
+
+func (s *Server) runDispatchWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-s.dispatchQueue.items:
+			ctx := ctx
+			if item.req.DeliveryID != "" {
+				ctx = contextWithDeliveryID(ctx, item.req.DeliveryID)
+			}
+			if err := s.dispatchQueue.limiter.Wait(ctx); err != nil {
+				return
+			}
+			// Failures are logged and notified by provisionRunner, there is no
+			// delivery left to respond to.
+			createdBuild, errResponse := s.provisionRunner(ctx, item.req, item.logFields)
+			if errResponse != nil {
+				continue
+			}
+			s.runnerDispatched(ctx, item.req, createdBuild, item.logFields)
+		}
+	}
+}
+
+// runnerDispatched records that the build running the runner was created.
+func (s *Server) runnerDispatched(ctx context.Context, req *runnerRequest, createdBuild *cloudbuildpb.Build, logFields []any) {
+	if req.Job != nil {
+		dispatched := newLifecycleEvent(LifecycleEventDispatched, req.Job)
+		dispatched.RunnerName = req.RunnerName
+		dispatched.BuildID = createdBuild.GetId()
+		dispatched.ProjectID = createdBuild.GetProjectId()
+		s.publishLifecycleEvent(ctx, dispatched)
+	}
+
+	fields := append(append([]any{}, logFields...), "build_id", createdBuild.GetId(),
+		"build_project_id", createdBuild.GetProjectId())
+	if req.Job != nil {
+		fields = append(fields, slog.Any(githubWebhookEventKey, req.Job))
+	}
+	logging.FromContext(ctx).InfoContext(ctx, runnerStartedMsg, fields...)
+}
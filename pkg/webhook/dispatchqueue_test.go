@@ -0,0 +1,88 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnqueueRunner(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		dispatchQueue: newDispatchQueue(1, 1, 1, 1),
+	}
+
+	resp := srv.enqueueRunner(t.Context(), &runnerRequest{RunnerName: "GCP-1"}, nil)
+	if got, want := resp.Code, http.StatusAccepted; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	resp = srv.enqueueRunner(t.Context(), &runnerRequest{RunnerName: "GCP-2"}, nil)
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestRunDispatchQueue(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var gotRunners []string
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotRunners = append(gotRunners, name)
+	})
+
+	srv := &Server{
+		appClient:     app,
+		cbc:           &MockCloudBuildClient{},
+		dispatchQueue: newDispatchQueue(100, 1, 2, 10),
+		ghAPIBaseURL:  ghURL,
+		runners:       newRunnerTracker(),
+	}
+
+	want := []string{"GCP-0", "GCP-1", "GCP-2"}
+	for _, name := range want {
+		req := &runnerRequest{InstallationID: 123, Org: "google", Repo: "webhook", RunnerName: name}
+		if resp := srv.enqueueRunner(t.Context(), req, nil); resp.Code != http.StatusAccepted {
+			t.Fatalf("failed to enqueue runner %s: %d %s", name, resp.Code, resp.Message)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	srv.runDispatchQueue(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.runners.List()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(gotRunners)
+	if diff := cmp.Diff(want, gotRunners); diff != "" {
+		t.Errorf("unexpected dispatched runners (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// githubAppClientTimeout bounds the requests of the GitHub App client, as the
+// default client of githubauth does.
+const githubAppClientTimeout = 10 * time.Second
+
+// newGitHubTransport returns the transport of the GitHub API requests. It
+// uses the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables unless a proxy URL is given, and trusts the CA certificates of the
+// PEM bundle on top of the system ones, for proxies that intercept TLS.
+func newGitHubTransport(proxyURL string, caBundle []byte) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url: %w", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if len(caBundle) > 0 {
+		pool, err := caCertPool(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+	return t, nil
+}
+
+// caCertPool returns the system CA certificates with those of the PEM bundle.
+func caCertPool(caBundle []byte) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("ca bundle contains no PEM certificates")
+	}
+	return pool, nil
+}
+
+// googleCloudClientOpts returns the options of the gRPC Google Cloud clients,
+// such as the KMS and Cloud Build clients, connecting through the proxy when a
+// proxy URL is given and trusting the CA certificates of the PEM bundle on top
+// of the system ones. Without a proxy URL, the clients use the proxy of the
+// HTTPS_PROXY and NO_PROXY environment variables.
+func googleCloudClientOpts(proxyURL string, caBundle []byte) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	var pool *x509.CertPool
+	if len(caBundle) > 0 {
+		var err error
+		if pool, err = caCertPool(caBundle); err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}))))
+	}
+
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url: %w", err)
+		}
+		opts = append(opts, option.WithGRPCDialOption(grpc.WithContextDialer(proxyDialer(u, pool))))
+	}
+	return opts, nil
+}
+
+// proxyDialer returns a dialer tunneling connections through the proxy with
+// HTTP CONNECT. Connections to an https proxy trust the CA certificates of the
+// pool, or the system ones when nil.
+func proxyDialer(proxy *url.URL, pool *x509.CertPool) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", proxyAddr(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial proxy: %w", err)
+		}
+		if proxy.Scheme == "https" {
+			conn = tls.Client(conn, &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				RootCAs:    pool,
+				ServerName: proxy.Hostname(),
+			})
+		}
+
+		// The handshake with the proxy is bounded by the dial deadline.
+		if deadline, ok := ctx.Deadline(); ok {
+			if err := conn.SetDeadline(deadline); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("failed to set proxy deadline: %w", err)
+			}
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if proxy.User != nil {
+			req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(proxy.User.String())))
+		}
+		if err := req.Write(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to send proxy CONNECT request: %w", err)
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to read proxy CONNECT response: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("proxy refused to connect to %s: %s", addr, resp.Status)
+		}
+
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to clear proxy deadline: %w", err)
+		}
+		// The proxy may have sent the first bytes of the tunnel already.
+		if br.Buffered() > 0 {
+			return &bufferedConn{Conn: conn, r: br}, nil
+		}
+		return conn, nil
+	}
+}
+
+// proxyAddr returns the host and port of the proxy, with the default port of
+// its scheme when it has none.
+func proxyAddr(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "80"
+	if proxy.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// bufferedConn is a connection whose first bytes were read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b) //nolint:wrapcheck // Errors of the connection.
+}
+
+// withClientOpts returns a copy of the options with opts added ahead of the
+// options of every Google Cloud client, so the options given for a client
+// take precedence.
+func (wco *WebhookClientOptions) withClientOpts(opts ...option.ClientOption) *WebhookClientOptions {
+	c := *wco
+	for _, clientOpts := range []*[]option.ClientOption{
+		&c.ArtifactAnalysisClientOpts,
+		&c.ArtifactRegistryClientOpts,
+		&c.BatchClientOpts,
+		&c.BinaryAuthorizationClientOpts,
+		&c.CloudBuildClientOpts,
+		&c.FirestoreClientOpts,
+		&c.ComputeClientOpts,
+		&c.GKEClientOpts,
+		&c.IAMCredentialsClientOpts,
+		&c.IDTokenClientOpts,
+		&c.KeyManagementClientOpts,
+		&c.LoggingClientOpts,
+		&c.PubSubClientOpts,
+		&c.SecretManagerClientOpts,
+		&c.StorageClientOpts,
+	} {
+		*clientOpts = slices.Concat(opts, *clientOpts)
+	}
+	return &c
+}
+
+// githubHTTPClient returns an HTTP client sending GitHub API requests through
+// the GitHub transport, authenticated with tokens of the token source.
+func (s *Server) githubHTTPClient(ts oauth2.TokenSource) *http.Client {
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, ts),
+			Base:   s.githubTransport,
+		},
+	}
+}
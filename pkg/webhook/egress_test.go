@@ -0,0 +1,248 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+func TestNewGitHubTransport(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "proxy.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "proxy.internal"}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	cases := []struct {
+		name      string
+		proxyURL  string
+		caBundle  []byte
+		wantProxy string
+		wantCA    bool
+		wantErr   string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:      "proxy",
+			proxyURL:  "http://proxy.internal:3128",
+			wantProxy: "http://proxy.internal:3128",
+		},
+		{
+			name:     "ca_bundle",
+			caBundle: caBundle,
+			wantCA:   true,
+		},
+		{
+			name:     "invalid_ca_bundle",
+			caBundle: []byte("not-a-certificate"),
+			wantErr:  "ca bundle contains no PEM certificates",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt, err := newGitHubTransport(tc.proxyURL, tc.caBundle)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			transport, ok := rt.(*http.Transport)
+			if !ok {
+				t.Fatalf("expected %T to be *http.Transport", rt)
+			}
+
+			if tc.wantProxy != "" {
+				proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "https://api.github.com/app", nil))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, want := proxy.String(), tc.wantProxy; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+			}
+
+			if got, want := transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil, tc.wantCA; got != want {
+				t.Errorf("expected custom root CAs %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+// recordingTransport records the requests it sends.
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, r)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestGitHubHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer installation-token"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	rt := &recordingTransport{}
+	s := &Server{githubTransport: rt}
+	client := s.githubHTTPClient(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "installation-token"}))
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got, want := len(rt.requests), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestProxyDialer(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	// The proxy accepts tunnels to kms.googleapis.com:443 and echoes what is
+	// sent through them, refusing any other destination.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					return
+				}
+				if req.Method != http.MethodConnect || req.Host != "kms.googleapis.com:443" {
+					io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\n\r\n")
+					return
+				}
+				if got, want := req.Header.Get("Proxy-Authorization"), "Basic dXNlcjpwYXNz"; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				io.Copy(conn, br)
+			}()
+		}
+	}()
+
+	dial := proxyDialer(&url.URL{Scheme: "http", Host: ln.Addr().String(), User: url.UserPassword("user", "pass")}, nil)
+
+	conn, err := dial(t.Context(), "kms.googleapis.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "ping"); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "ping"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	_, err = dial(t.Context(), "example.com:443")
+	if diff := testutil.DiffErrString(err, "proxy refused to connect to example.com:443: 403 Forbidden"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestGoogleCloudClientOpts(t *testing.T) {
+	t.Parallel()
+
+	opts, err := googleCloudClientOpts("http://proxy.internal:3128", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(opts), 1; got != want {
+		t.Errorf("expected %d options, got %d", want, got)
+	}
+
+	_, err = googleCloudClientOpts("", []byte("not-a-certificate"))
+	if diff := testutil.DiffErrString(err, "ca bundle contains no PEM certificates"); diff != "" {
+		t.Error(diff)
+	}
+
+	// The options given for a client come last, so they take precedence.
+	endpoint := option.WithEndpoint("localhost:8080")
+	wco := &WebhookClientOptions{CloudBuildClientOpts: []option.ClientOption{endpoint}}
+	got := wco.withClientOpts(opts...)
+	if got, want := len(got.CloudBuildClientOpts), 2; got != want {
+		t.Fatalf("expected %d options, got %d", want, got)
+	}
+	if got.CloudBuildClientOpts[1] != endpoint {
+		t.Errorf("expected the cloud build client option to come last")
+	}
+	if got, want := len(got.KeyManagementClientOpts), 1; got != want {
+		t.Errorf("expected %d options, got %d", want, got)
+	}
+	if got, want := len(wco.KeyManagementClientOpts), 0; got != want {
+		t.Errorf("expected the options to be left unchanged, got %d kms options", got)
+	}
+}
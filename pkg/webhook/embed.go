@@ -0,0 +1,277 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/renderer"
+	"google.golang.org/api/option"
+)
+
+// Option configures a server created with New.
+type Option func(o *WebhookClientOptions)
+
+// WithWebhookSecret sets the secret verifying webhook deliveries, instead of
+// reading it from the WEBHOOK_KEY_MOUNT_PATH file.
+func WithWebhookSecret(secret []byte) Option {
+	return func(o *WebhookClientOptions) { o.WebhookSecretOverride = secret }
+}
+
+// WithAdminToken enables the admin API with the bearer token, instead of
+// reading it from the ADMIN_TOKEN_PATH file.
+func WithAdminToken(token []byte) Option {
+	return func(o *WebhookClientOptions) { o.AdminTokenOverride = token }
+}
+
+// WithAppSigner sets the signer of the GitHub App JWTs, instead of the KMS
+// keys of the config.
+func WithAppSigner(signer crypto.Signer) Option {
+	return func(o *WebhookClientOptions) { o.AppSignerOverride = signer }
+}
+
+// WithRunnerProfiles sets the runner profiles, instead of loading them from
+// the RUNNER_PROFILES_PATH file.
+func WithRunnerProfiles(profiles map[string]*RunnerProfile) Option {
+	return func(o *WebhookClientOptions) { o.RunnerProfilesOverride = profiles }
+}
+
+// WithCloudBuildClient sets the Cloud Build client runner builds are created
+// with.
+func WithCloudBuildClient(client CloudBuildClient) Option {
+	return func(o *WebhookClientOptions) { o.CloudBuildClientOverride = client }
+}
+
+// WithEventPublisher sets the publisher of the lifecycle events.
+func WithEventPublisher(publisher EventPublisher) Option {
+	return func(o *WebhookClientOptions) { o.EventPublisherOverride = publisher }
+}
+
+// WithBuildLogReader sets the reader of the runner build logs.
+func WithBuildLogReader(reader BuildLogReader) Option {
+	return func(o *WebhookClientOptions) { o.BuildLogReaderOverride = reader }
+}
+
+// WithSecretStore sets the store of the runner JIT configuration secrets.
+func WithSecretStore(store SecretStore) Option {
+	return func(o *WebhookClientOptions) { o.SecretStoreOverride = store }
+}
+
+// WithSettingsStore sets the store of the shared settings object.
+func WithSettingsStore(store SettingsStore) Option {
+	return func(o *WebhookClientOptions) { o.SettingsStoreOverride = store }
+}
+
+// WithFileReader sets the reader of the files named by the config.
+func WithFileReader(fr FileReader) Option {
+	return func(o *WebhookClientOptions) { o.OSFileReaderOverride = fr }
+}
+
+// WithImageScanner sets the lookup of the runner image scanning results used
+// by the vulnerability gate.
+func WithImageScanner(scanner ImageScanner) Option {
+	return func(o *WebhookClientOptions) { o.ImageScannerOverride = scanner }
+}
+
+// WithInstanceGroupClient sets the client creating and deleting the VMs of
+// the fallback instance group.
+func WithInstanceGroupClient(c InstanceGroupClient) Option {
+	return func(o *WebhookClientOptions) { o.InstanceGroupClientOverride = c }
+}
+
+// WithVMClient sets the client creating and deleting the ephemeral VMs
+// runners run on when RUNNER_BACKEND is "compute".
+func WithVMClient(c VMClient) Option {
+	return func(o *WebhookClientOptions) { o.VMClientOverride = c }
+}
+
+// WithRunnerBackend replaces the backend runners run on, RUNNER_BACKEND, with
+// a custom one. Jobs with the batch label still run on Batch when
+// RUNNER_BATCH_LOCATION is set.
+func WithRunnerBackend(b RunnerBackend) Option {
+	return func(o *WebhookClientOptions) { o.RunnerBackendOverride = b }
+}
+
+// WithBatchClient sets the client creating and deleting the Cloud Batch jobs
+// runners of jobs with the "batch" label run in.
+func WithBatchClient(c BatchClient) Option {
+	return func(o *WebhookClientOptions) { o.BatchClientOverride = c }
+}
+
+// WithJobClient sets the client creating and deleting the Kubernetes Jobs
+// runners run in when RUNNER_BACKEND is "gke".
+func WithJobClient(c JobClient) Option {
+	return func(o *WebhookClientOptions) { o.JobClientOverride = c }
+}
+
+// WithGitHubTransport sets the transport of every GitHub API request, for
+// example one routing through the proxy of the embedding service.
+func WithGitHubTransport(rt http.RoundTripper) Option {
+	return func(o *WebhookClientOptions) { o.GitHubTransportOverride = rt }
+}
+
+// WithIDTokenValidator sets the validation of the ID tokens dispatch API
+// callers authenticate with.
+func WithIDTokenValidator(v IDTokenValidator) Option {
+	return func(o *WebhookClientOptions) { o.IDTokenValidatorOverride = v }
+}
+
+// WithClientOptions adds options to every Google Cloud client the server
+// creates.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(o *WebhookClientOptions) {
+		o.ArtifactAnalysisClientOpts = append(o.ArtifactAnalysisClientOpts, opts...)
+		o.BatchClientOpts = append(o.BatchClientOpts, opts...)
+		o.CloudBuildClientOpts = append(o.CloudBuildClientOpts, opts...)
+		o.ComputeClientOpts = append(o.ComputeClientOpts, opts...)
+		o.GKEClientOpts = append(o.GKEClientOpts, opts...)
+		o.IAMCredentialsClientOpts = append(o.IAMCredentialsClientOpts, opts...)
+		o.IDTokenClientOpts = append(o.IDTokenClientOpts, opts...)
+		o.KeyManagementClientOpts = append(o.KeyManagementClientOpts, opts...)
+		o.LoggingClientOpts = append(o.LoggingClientOpts, opts...)
+		o.PubSubClientOpts = append(o.PubSubClientOpts, opts...)
+		o.SecretManagerClientOpts = append(o.SecretManagerClientOpts, opts...)
+		o.StorageClientOpts = append(o.StorageClientOpts, opts...)
+	}
+}
+
+// DefaultConfig returns a config with the defaults of every setting, as if it
+// was loaded from an empty environment. Services embedding the server set the
+// fields they need on it instead of loading it from the environment.
+func DefaultConfig() (*Config, error) {
+	cfg := &Config{}
+	set := cli.NewFlagSet(cli.WithLookupEnv(func(string) (string, bool) { return "", false }))
+	cfg.ToFlags(set)
+	if err := set.Parse(nil); err != nil {
+		return nil, fmt.Errorf("failed to apply config defaults: %w", err)
+	}
+	return cfg, nil
+}
+
+// New creates a server for services embedding the webhook in-process. The
+// config holds the plain settings, see DefaultConfig, while secrets, clients
+// and profiles can be passed as options instead of files and
+// environment-configured clients. The caller serves Routes and starts the
+// background tasks with StartBackground.
+func New(ctx context.Context, cfg *Config, opts ...Option) (*Server, error) {
+	logger := logging.FromContext(ctx)
+
+	h, err := renderer.New(ctx, nil,
+		renderer.WithOnError(func(err error) {
+			logger.ErrorContext(ctx, "failed to render", "error", err)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renderer: %w", err)
+	}
+
+	wco := &WebhookClientOptions{}
+	for _, opt := range opts {
+		opt(wco)
+	}
+	return NewServer(ctx, h, cfg, wco)
+}
+
+// RunnerRequest requests a runner outside of a webhook delivery.
+type RunnerRequest struct {
+	InstallationID int64
+	Org            string
+	Repo           string
+
+	// RunnerName is the unique name the runner registers with. It should start
+	// with GCP- so completed jobs are attributed to this service.
+	RunnerName string
+
+	// Labels are the labels of the runner, including hints such as
+	// profile=<name> and pool=<name>.
+	Labels []string
+
+	// Actor is the login of the user the runner is requested for, subject to
+	// the actor limits.
+	Actor string
+
+	// DeliveryID correlates the runner's logs and build tags, optional.
+	DeliveryID string
+}
+
+// DispatchError is returned when the dispatch pipeline did not provision a
+// runner. Code and Message are what a webhook delivery would have been
+// answered with.
+type DispatchError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func (e *DispatchError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *DispatchError) Unwrap() error {
+	return e.Err
+}
+
+// ProvisionRunner runs the dispatch pipeline for the request: it registers a
+// just-in-time runner with GitHub and creates the build running it, applying
+// the dispatch policy, limits and profiles like for a queued job. It bypasses
+// the dispatch queue. On failure the error is a *DispatchError.
+func (s *Server) ProvisionRunner(ctx context.Context, req *RunnerRequest) (*cloudbuildpb.Build, error) {
+	if req.RunnerName == "" || req.Org == "" || req.Repo == "" || req.InstallationID == 0 {
+		return nil, fmt.Errorf("installation ID, org, repo and runner name are required")
+	}
+
+	if req.DeliveryID != "" {
+		ctx = contextWithDeliveryID(ctx, req.DeliveryID)
+	}
+	r := &runnerRequest{
+		InstallationID: req.InstallationID,
+		Org:            req.Org,
+		Repo:           req.Repo,
+		RunnerName:     req.RunnerName,
+		Labels:         req.Labels,
+		Actor:          req.Actor,
+		DeliveryID:     req.DeliveryID,
+	}
+	logFields := []any{
+		"org", req.Org,
+		"repo", req.Repo,
+		"runner_id", req.RunnerName,
+		"labels", req.Labels,
+	}
+
+	createdBuild, errResponse := s.provisionRunner(ctx, r, logFields)
+	if errResponse != nil {
+		return nil, &DispatchError{Code: errResponse.Code, Message: errResponse.Message, Err: errResponse.Error}
+	}
+	s.runnerDispatched(ctx, r, createdBuild, logFields)
+	return createdBuild, nil
+}
+
+// ProcessEvent processes a webhook payload of the event type whose signature
+// the caller verified, as the /webhook route does. It returns the status code
+// and message a delivery would be answered with, and any processing error.
+func (s *Server) ProcessEvent(ctx context.Context, deliveryID, eventType string, payload []byte) (int, string, error) {
+	ctx = contextWithDeliveryID(ctx, deliveryID)
+	resp := s.processEvent(ctx, eventType, payload, false)
+	return resp.Code, resp.Message, resp.Error
+}
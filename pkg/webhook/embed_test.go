@@ -0,0 +1,101 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.GitHubAPIBaseURL, "https://api.github.com"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	cfg.GitHubAppID = "app-id"
+	cfg.RunnerLocation = "us-central1"
+	cfg.RunnerProjectID = "runner-project"
+	cfg.RunnerRepositoryID = "us-docker.pkg.dev/runner-project/runners"
+	cfg.RunnerServiceAccount = "runner@runner-project.iam.gserviceaccount.com"
+	cfg.SkipStartupChecks = true
+
+	// No secret or key file is read and no KMS client is created.
+	s, err := New(ctx, cfg,
+		WithWebhookSecret([]byte("webhook-secret")),
+		WithAdminToken([]byte("admin-token")),
+		WithAppSigner(key),
+		WithCloudBuildClient(&MockCloudBuildClient{}),
+		WithRunnerProfiles(map[string]*RunnerProfile{"xl": {}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if got, want := string(s.webhookSecret), "webhook-secret"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if _, ok := s.runnerProfiles["xl"]; !ok {
+		t.Errorf("expected the runner profiles of the option")
+	}
+
+	payload := []byte(`{"action":"queued","workflow_job":{"id":2,"run_id":1,"labels":["other-label"]},` +
+		`"installation":{"id":123},"organization":{"login":"google"},"repository":{"name":"webhook"}}`)
+	code, msg, err := s.ProcessEvent(ctx, "delivery-id", "workflow_job", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := code, http.StatusOK; got != want {
+		t.Errorf("expected %d to be %d: %s", got, want, msg)
+	}
+
+	_, err = s.ProvisionRunner(ctx, &RunnerRequest{Org: "google", Repo: "webhook"})
+	if diff := testutil.DiffErrString(err, "installation ID, org, repo and runner name are required"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDispatchError(t *testing.T) {
+	t.Parallel()
+
+	err := &DispatchError{Code: http.StatusConflict, Message: "runner already exists", Err: errRunnerExists}
+	if diff := testutil.DiffErrString(err, "runner already exists: runner already exists"); diff != "" {
+		t.Error(diff)
+	}
+	if !errors.Is(err, errRunnerExists) {
+		t.Errorf("expected the error to wrap errRunnerExists")
+	}
+}
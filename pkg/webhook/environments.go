@@ -0,0 +1,248 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// waitingJobTTL bounds how long a job waiting for a deployment environment
+// approval is remembered. GitHub fails jobs after waiting 30 days.
+const waitingJobTTL = 30 * 24 * time.Hour
+
+// Stages of a job gated by a deployment environment.
+const (
+	gatedJobWaiting   = "waiting"
+	gatedJobPrewarmed = "prewarmed"
+	gatedJobApproved  = "approved"
+	gatedJobRejected  = "rejected"
+)
+
+// runnerPrewarmedMsg is the response to a queued event of a job whose runner
+// was provisioned when its deployment was approved.
+var runnerPrewarmedMsg = "runner already provisioned on approval"
+
+// waitingJob is a self-hosted job waiting for a deployment environment
+// approval. Runners are only provisioned once the job is queued, or when the
+// deployment is approved if pre-warming is enabled.
+type waitingJob struct {
+	event     *github.WorkflowJobEvent
+	since     time.Time
+	prewarmed bool
+}
+
+// waitingJobs keeps track of the jobs waiting for an approval, keyed by job ID.
+// A nil store is valid and tracks nothing.
+type waitingJobs struct {
+	mu   sync.Mutex
+	jobs map[int64]*waitingJob
+}
+
+func newWaitingJobs() *waitingJobs {
+	return &waitingJobs{
+		jobs: make(map[int64]*waitingJob),
+	}
+}
+
+// Add records a job waiting for an approval and forgets jobs that have waited
+// longer than waitingJobTTL.
+func (w *waitingJobs) Add(event *github.WorkflowJobEvent, now time.Time) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id, j := range w.jobs {
+		if now.Sub(j.since) > waitingJobTTL {
+			delete(w.jobs, id)
+		}
+	}
+	if _, ok := w.jobs[event.GetWorkflowJob().GetID()]; ok {
+		return
+	}
+	w.jobs[event.GetWorkflowJob().GetID()] = &waitingJob{event: event, since: now}
+}
+
+// Take stops tracking the job and returns it.
+func (w *waitingJobs) Take(jobID int64) (waitingJob, bool) {
+	if w == nil {
+		return waitingJob{}, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	j, ok := w.jobs[jobID]
+	if !ok {
+		return waitingJob{}, false
+	}
+	delete(w.jobs, jobID)
+	return *j, true
+}
+
+// Waiting reports whether the job is waiting for an approval.
+func (w *waitingJobs) Waiting(jobID int64) bool {
+	if w == nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.jobs[jobID]
+	return ok
+}
+
+// Prewarm marks the job as pre-warmed and returns its waiting event. It
+// returns false if the job is not waiting or was already pre-warmed.
+func (w *waitingJobs) Prewarm(jobID int64) (*github.WorkflowJobEvent, bool) {
+	if w == nil {
+		return nil, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	j, ok := w.jobs[jobID]
+	if !ok || j.prewarmed {
+		return nil, false
+	}
+	j.prewarmed = true
+	return j.event, true
+}
+
+// Unprewarm clears the pre-warmed mark of the job, so its queued event
+// provisions a runner.
+func (w *waitingJobs) Unprewarm(jobID int64) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if j, ok := w.jobs[jobID]; ok {
+		j.prewarmed = false
+	}
+}
+
+// jobApproved handles the queued event of a job. It returns true if the job
+// was waiting for an approval and its runner was already provisioned by this
+// instance when the deployment was approved.
+func (s *Server) jobApproved(ctx context.Context, event *github.WorkflowJobEvent, logFields []any) bool {
+	j, ok := s.waitingJobs.Take(event.GetWorkflowJob().GetID())
+	if !ok {
+		return false
+	}
+
+	waited := time.Since(j.since)
+	s.metrics.recordGatedJob(gatedJobApproved)
+	s.metrics.recordGatedJobWait(gatedJobApproved, waited)
+	logging.FromContext(ctx).InfoContext(ctx, "Workflow job approved",
+		append(logFields, "duration_waiting_seconds", waited.Seconds(), "prewarmed", j.prewarmed)...)
+
+	if !j.prewarmed {
+		return false
+	}
+	// The runner is tracked once its build was created, so the job is
+	// provisioned as usual if pre-warming failed.
+	return s.runners.Update(runnerNamePrefix+fmt.Sprintf("%d", event.GetWorkflowJob().GetID()), func(*trackedRunner) {})
+}
+
+// handleDeploymentReview provisions the runners of the waiting jobs of an
+// approved deployment when pre-warming is enabled, and forgets the jobs of a
+// rejected deployment.
+func (s *Server) handleDeploymentReview(ctx context.Context, event *github.DeploymentReviewEvent) *apiResponse {
+	logger := logging.FromContext(ctx)
+
+	switch event.GetAction() {
+	case "approved":
+		if !s.prewarmOnApproval {
+			return &apiResponse{http.StatusOK, "no action taken for approval without pre-warming", nil}
+		}
+
+		var prewarmed int
+		for _, run := range event.WorkflowJobRuns {
+			job, ok := s.waitingJobs.Prewarm(run.GetID())
+			if !ok {
+				continue
+			}
+
+			jobID := fmt.Sprintf("%d", run.GetID())
+			runnerID := runnerNamePrefix + jobID
+			logFields := []any{
+				"gh_run_id", job.GetWorkflowJob().GetRunID(),
+				"gh_job_id", run.GetID(),
+				"job_id", jobID,
+				"runner_id", runnerID,
+				"environment", run.GetEnvironment(),
+			}
+			logFields = append(logFields, workflowLogFields(job)...)
+
+			req := &runnerRequest{
+				InstallationID: job.GetInstallation().GetID(),
+				Org:            job.GetOrg().GetLogin(),
+				Repo:           job.GetRepo().GetName(),
+				RunnerName:     runnerID,
+				Labels:         job.GetWorkflowJob().Labels,
+				Actor:          job.GetSender().GetLogin(),
+				Job:            job,
+				DeliveryID:     deliveryIDFromContext(ctx),
+			}
+			if s.dispatchQueue != nil {
+				if resp := s.enqueueRunner(ctx, req, logFields); resp.Code != http.StatusAccepted {
+					s.waitingJobs.Unprewarm(run.GetID())
+					continue
+				}
+			} else {
+				createdBuild, errResponse := s.provisionRunner(ctx, req, logFields)
+				if errResponse != nil {
+					logger.WarnContext(ctx, "failed to pre-warm runner", append(logFields, "error", errResponse.Error, "body", errResponse.Message)...)
+					s.waitingJobs.Unprewarm(run.GetID())
+					continue
+				}
+				s.runnerDispatched(ctx, req, createdBuild, logFields)
+			}
+
+			prewarmed++
+			s.metrics.recordGatedJob(gatedJobPrewarmed)
+			logger.InfoContext(ctx, "Pre-warmed runner on approval", logFields...)
+		}
+		return &apiResponse{http.StatusOK, fmt.Sprintf("pre-warmed %d runners", prewarmed), nil}
+
+	case "rejected":
+		for _, run := range event.WorkflowJobRuns {
+			if j, ok := s.waitingJobs.Take(run.GetID()); ok {
+				s.metrics.recordGatedJob(gatedJobRejected)
+				s.metrics.recordGatedJobWait(gatedJobRejected, time.Since(j.since))
+			}
+		}
+		return &apiResponse{http.StatusOK, "deployment review rejected event logged", nil}
+
+	default:
+		logger.InfoContext(ctx, "no action taken for unhandled deployment review action type", "action", event.GetAction())
+		return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for action type: %q", event.GetAction()), nil}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func testWaitingEvent(jobID int64) *github.WorkflowJobEvent {
+	return &github.WorkflowJobEvent{
+		Action: github.Ptr("waiting"),
+		WorkflowJob: &github.WorkflowJob{
+			ID:     github.Ptr(jobID),
+			RunID:  github.Ptr(int64(456)),
+			Labels: []string{"self-hosted"},
+		},
+		Installation: &github.Installation{ID: github.Ptr(int64(123))},
+		Org:          &github.Organization{Login: github.Ptr("google")},
+		Repo:         &github.Repository{Name: github.Ptr("webhook")},
+	}
+}
+
+func TestWaitingJobs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	w := newWaitingJobs()
+	w.Add(testWaitingEvent(1), now.Add(-waitingJobTTL-time.Minute))
+	w.Add(testWaitingEvent(2), now)
+
+	if _, ok := w.Take(1); ok {
+		t.Errorf("expected job waiting longer than the TTL to be forgotten")
+	}
+
+	if !w.Waiting(2) {
+		t.Errorf("expected job to be waiting")
+	}
+	if _, ok := w.Prewarm(2); !ok {
+		t.Fatalf("expected waiting job to be pre-warmed")
+	}
+	if _, ok := w.Prewarm(2); ok {
+		t.Errorf("expected job to be pre-warmed only once")
+	}
+
+	j, ok := w.Take(2)
+	if !ok {
+		t.Fatalf("expected waiting job to be taken")
+	}
+	if !j.prewarmed {
+		t.Errorf("expected taken job to be pre-warmed")
+	}
+	if w.Waiting(2) {
+		t.Errorf("expected taken job to no longer be waiting")
+	}
+	if _, ok := w.Take(2); ok {
+		t.Errorf("expected job to be taken only once")
+	}
+}
+
+func TestHandleDeploymentReview(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		action       string
+		prewarm      bool
+		queueSize    int
+		wantQueued   int
+		wantWaiting  bool
+		wantApproved bool
+	}{
+		{
+			name:         "approved_prewarm",
+			action:       "approved",
+			prewarm:      true,
+			queueSize:    1,
+			wantQueued:   1,
+			wantWaiting:  true,
+			wantApproved: true,
+		},
+		{
+			name:        "approved_prewarm_failed",
+			action:      "approved",
+			prewarm:     true,
+			wantWaiting: true,
+		},
+		{
+			name:        "approved_without_prewarm",
+			action:      "approved",
+			queueSize:   1,
+			wantWaiting: true,
+		},
+		{
+			name:      "rejected",
+			action:    "rejected",
+			prewarm:   true,
+			queueSize: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				dispatchQueue:     newDispatchQueue(1, 1, 1, tc.queueSize),
+				prewarmOnApproval: tc.prewarm,
+				runners:           newRunnerTracker(),
+				waitingJobs:       newWaitingJobs(),
+			}
+			s.waitingJobs.Add(testWaitingEvent(789), time.Now())
+
+			resp := s.handleDeploymentReview(t.Context(), &github.DeploymentReviewEvent{
+				Action: github.Ptr(tc.action),
+				WorkflowJobRuns: []*github.WorkflowJobRun{
+					{ID: github.Ptr(int64(789)), Environment: github.Ptr("production")},
+					{ID: github.Ptr(int64(999)), Environment: github.Ptr("production")},
+				},
+			})
+			if resp.Error != nil {
+				t.Fatal(resp.Error)
+			}
+
+			if got, want := len(s.dispatchQueue.items), tc.wantQueued; got != want {
+				t.Errorf("expected %d queued runners to be %d", got, want)
+			}
+
+			j, ok := s.waitingJobs.Take(789)
+			if got, want := ok, tc.wantWaiting; got != want {
+				t.Fatalf("expected waiting %t to be %t", got, want)
+			}
+			if got, want := j.prewarmed, tc.wantApproved; got != want {
+				t.Errorf("expected pre-warmed %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestJobApproved(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		waiting  bool
+		prewarm  bool
+		tracked  bool
+		wantSkip bool
+	}{
+		{
+			name: "not_waiting",
+		},
+		{
+			name:    "waiting",
+			waiting: true,
+		},
+		{
+			name:     "prewarmed",
+			waiting:  true,
+			prewarm:  true,
+			tracked:  true,
+			wantSkip: true,
+		},
+		{
+			name:    "prewarm_not_dispatched",
+			waiting: true,
+			prewarm: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				runners:     newRunnerTracker(),
+				waitingJobs: newWaitingJobs(),
+			}
+			event := testWaitingEvent(789)
+			if tc.waiting {
+				s.waitingJobs.Add(event, time.Now())
+			}
+			if tc.prewarm {
+				s.waitingJobs.Prewarm(789)
+			}
+			if tc.tracked {
+				s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-789"})
+			}
+
+			if got, want := s.jobApproved(t.Context(), event, nil), tc.wantSkip; got != want {
+				t.Errorf("expected skip %t to be %t", got, want)
+			}
+			if _, ok := s.waitingJobs.Take(789); ok {
+				t.Errorf("expected job to no longer be waiting")
+			}
+		})
+	}
+}
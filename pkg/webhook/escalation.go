@@ -0,0 +1,317 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+const (
+	escalationFailureRateKey = "github-actions-on-gcp/dispatch-failure-rate"
+	escalationQueueAgeKey    = "github-actions-on-gcp/queue-age"
+)
+
+// escalationEvaluateInterval is how often the escalator is evaluated without
+// new observations, so a queue where no job starts still pages.
+const escalationEvaluateInterval = time.Minute
+
+// Pager opens and resolves incidents for on-call escalation.
+type Pager interface {
+	Trigger(ctx context.Context, dedupKey, summary string, details map[string]string) error
+	Resolve(ctx context.Context, dedupKey string) error
+}
+
+// escalationConfig holds the thresholds that decide when to page.
+type escalationConfig struct {
+	// FailureRateThreshold is the fraction of failed dispatches within the
+	// window above which the failure condition is breached.
+	FailureRateThreshold float64
+
+	// MinDispatches is the minimum number of dispatches in the window before
+	// the failure rate is considered meaningful.
+	MinDispatches int
+
+	// QueueAgeThreshold is the average queue time within the window, or the
+	// time the oldest job still queued has waited, above which the queue age
+	// condition is breached. Zero disables the check.
+	QueueAgeThreshold time.Duration
+
+	// Window is the sliding window observations are aggregated over.
+	Window time.Duration
+
+	// SustainPeriod is how long a condition must stay breached before paging.
+	SustainPeriod time.Duration
+}
+
+type timedSample struct {
+	at    time.Time
+	value float64
+}
+
+type escalationCondition struct {
+	breachedSince time.Time
+	triggered     bool
+}
+
+// escalator pages on-call when dispatch failures or queue age stay above
+// their thresholds for a sustained period, and resolves the incident once the
+// condition clears.
+type escalator struct {
+	pager Pager
+	cfg   *escalationConfig
+	now   func() time.Time
+
+	mu         sync.Mutex
+	dispatches []timedSample
+	queueAges  []timedSample
+	oldest     time.Duration
+	conditions map[string]*escalationCondition
+}
+
+func newEscalator(pager Pager, cfg *escalationConfig) *escalator {
+	return &escalator{
+		pager:      pager,
+		cfg:        cfg,
+		now:        time.Now,
+		conditions: make(map[string]*escalationCondition, 2),
+	}
+}
+
+// RecordDispatch records the outcome of a runner dispatch.
+func (e *escalator) RecordDispatch(ctx context.Context, failed bool) {
+	var v float64
+	if failed {
+		v = 1
+	}
+
+	e.mu.Lock()
+	e.dispatches = append(e.dispatches, timedSample{at: e.now(), value: v})
+	e.mu.Unlock()
+
+	e.evaluate(ctx)
+}
+
+// RecordQueueAge records how long a job waited before a runner picked it up.
+func (e *escalator) RecordQueueAge(ctx context.Context, age time.Duration) {
+	e.mu.Lock()
+	e.queueAges = append(e.queueAges, timedSample{at: e.now(), value: age.Seconds()})
+	e.mu.Unlock()
+
+	e.evaluate(ctx)
+}
+
+// ObserveQueue records how long the oldest job still waiting for a runner
+// has been queued, 0 if none is, and evaluates the conditions. Unlike
+// RecordQueueAge, it catches jobs that never start.
+func (e *escalator) ObserveQueue(ctx context.Context, oldest time.Duration) {
+	e.mu.Lock()
+	e.oldest = oldest
+	e.mu.Unlock()
+
+	e.evaluate(ctx)
+}
+
+type escalationAction struct {
+	key     string
+	trigger bool
+	summary string
+	details map[string]string
+}
+
+func (e *escalator) evaluate(ctx context.Context) {
+	e.mu.Lock()
+	now := e.now()
+	cutoff := now.Add(-e.cfg.Window)
+	e.dispatches = pruneSamples(e.dispatches, cutoff)
+	e.queueAges = pruneSamples(e.queueAges, cutoff)
+
+	var actions []*escalationAction
+
+	failures, total := sumSamples(e.dispatches)
+	rate := 0.0
+	if total > 0 {
+		rate = failures / float64(total)
+	}
+	failureBreached := total >= e.cfg.MinDispatches && rate > e.cfg.FailureRateThreshold
+	if a := e.transition(escalationFailureRateKey, failureBreached, now); a != nil {
+		a.summary = fmt.Sprintf("Runner dispatch failure rate %.0f%% over the last %s exceeds %.0f%%",
+			rate*100, e.cfg.Window, e.cfg.FailureRateThreshold*100)
+		a.details = map[string]string{
+			"failed_dispatches": fmt.Sprintf("%.0f", failures),
+			"total_dispatches":  fmt.Sprintf("%d", total),
+		}
+		actions = append(actions, a)
+	}
+
+	if e.cfg.QueueAgeThreshold > 0 {
+		sum, n := sumSamples(e.queueAges)
+		avg := time.Duration(0)
+		if n > 0 {
+			avg = time.Duration(sum / float64(n) * float64(time.Second))
+		}
+		queueBreached := (n > 0 && avg > e.cfg.QueueAgeThreshold) || e.oldest > e.cfg.QueueAgeThreshold
+		if a := e.transition(escalationQueueAgeKey, queueBreached, now); a != nil {
+			a.summary = fmt.Sprintf("Job queue time exceeds %s: %s on average over the last %s, %s for the oldest queued job",
+				e.cfg.QueueAgeThreshold, avg.Round(time.Second), e.cfg.Window, e.oldest.Round(time.Second))
+			a.details = map[string]string{
+				"average_queue_seconds": fmt.Sprintf("%.0f", avg.Seconds()),
+				"oldest_queue_seconds":  fmt.Sprintf("%.0f", e.oldest.Seconds()),
+				"samples":               fmt.Sprintf("%d", n),
+			}
+			actions = append(actions, a)
+		}
+	}
+	e.mu.Unlock()
+
+	logger := logging.FromContext(ctx)
+	for _, a := range actions {
+		if a.trigger {
+			logger.ErrorContext(ctx, "escalating sustained failure to pagerduty",
+				"dedup_key", a.key,
+				"summary", a.summary)
+			if err := e.pager.Trigger(ctx, a.key, a.summary, a.details); err != nil {
+				logger.ErrorContext(ctx, "failed to trigger pagerduty incident", "dedup_key", a.key, "error", err)
+			}
+			continue
+		}
+
+		logger.InfoContext(ctx, "resolving pagerduty incident", "dedup_key", a.key)
+		if err := e.pager.Resolve(ctx, a.key); err != nil {
+			logger.ErrorContext(ctx, "failed to resolve pagerduty incident", "dedup_key", a.key, "error", err)
+		}
+	}
+}
+
+// transition updates the state of the condition and returns the action to
+// take, if any. It must be called with the lock held.
+func (e *escalator) transition(key string, breached bool, now time.Time) *escalationAction {
+	c, ok := e.conditions[key]
+	if !ok {
+		c = &escalationCondition{}
+		e.conditions[key] = c
+	}
+
+	if !breached {
+		wasTriggered := c.triggered
+		c.breachedSince = time.Time{}
+		c.triggered = false
+		if wasTriggered {
+			return &escalationAction{key: key}
+		}
+		return nil
+	}
+
+	if c.breachedSince.IsZero() {
+		c.breachedSince = now
+	}
+	if !c.triggered && now.Sub(c.breachedSince) >= e.cfg.SustainPeriod {
+		c.triggered = true
+		return &escalationAction{key: key, trigger: true}
+	}
+	return nil
+}
+
+// recordDispatchOutcome feeds the escalator, if configured.
+func (s *Server) recordDispatchOutcome(ctx context.Context, failed bool) {
+	if s.escalator != nil {
+		s.escalator.RecordDispatch(ctx, failed)
+	}
+}
+
+// recordQueueAge feeds the escalator, if configured.
+func (s *Server) recordQueueAge(ctx context.Context, age time.Duration) {
+	if s.escalator != nil {
+		s.escalator.RecordQueueAge(ctx, age)
+	}
+}
+
+// oldestQueuedJob returns how long the oldest job a runner was dispatched for
+// has been queued without being picked up, 0 if there is none. Runners not
+// dispatched for a job, and reusable runners waiting for their next job, are
+// not counted. With a state store, the runners of every instance are counted,
+// otherwise only those of this instance, which is why escalation needs a
+// single instance without one.
+func (s *Server) oldestQueuedJob(ctx context.Context, now time.Time) time.Duration {
+	runners := make(map[string]trackedRunner)
+	for _, r := range s.runners.List() {
+		runners[r.RunnerName] = r
+	}
+	if s.stateStore != nil {
+		states, err := s.stateStore.ActiveRunners(ctx)
+		if err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to read active runners, counting the tracked runners only", "error", err)
+		}
+		for _, state := range states {
+			if r, err := trackedRunnerFromState(state); err == nil {
+				runners[r.RunnerName] = *r
+			}
+		}
+	}
+
+	var oldest time.Duration
+	for _, r := range runners {
+		if r.JobID == 0 || r.JobsServed > 0 {
+			continue
+		}
+		switch r.Lifecycle.State() {
+		case lifecycle.StateQueued, lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateOnline:
+		default:
+			continue
+		}
+		if age := now.Sub(r.Lifecycle.EnteredAt(lifecycle.StateQueued)); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
+// runEscalationMonitor evaluates the escalator with the oldest queued job
+// until ctx is done.
+func (s *Server) runEscalationMonitor(ctx context.Context) {
+	ticker := time.NewTicker(escalationEvaluateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.escalator.ObserveQueue(ctx, s.oldestQueuedJob(ctx, time.Now()))
+		}
+	}
+}
+
+func pruneSamples(samples []timedSample, cutoff time.Time) []timedSample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(cutoff) {
+		i++
+	}
+	return samples[i:]
+}
+
+func sumSamples(samples []timedSample) (float64, int) {
+	var sum float64
+	for _, s := range samples {
+		sum += s.value
+	}
+	return sum, len(samples)
+}
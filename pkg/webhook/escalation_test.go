@@ -0,0 +1,229 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+type fakePager struct {
+	calls []string
+}
+
+func (f *fakePager) Trigger(ctx context.Context, dedupKey, summary string, details map[string]string) error {
+	f.calls = append(f.calls, "trigger:"+dedupKey)
+	return nil
+}
+
+func (f *fakePager) Resolve(ctx context.Context, dedupKey string) error {
+	f.calls = append(f.calls, "resolve:"+dedupKey)
+	return nil
+}
+
+func TestEscalator_FailureRate(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pager := &fakePager{}
+	esc := newEscalator(pager, &escalationConfig{
+		FailureRateThreshold: 0.5,
+		MinDispatches:        3,
+		Window:               5 * time.Minute,
+		SustainPeriod:        2 * time.Minute,
+	})
+	esc.now = func() time.Time { return now }
+
+	// Below the minimum sample size nothing happens.
+	esc.RecordDispatch(ctx, true)
+	esc.RecordDispatch(ctx, true)
+	if len(pager.calls) != 0 {
+		t.Fatalf("expected no pages, got %v", pager.calls)
+	}
+
+	// Breached, but not yet sustained.
+	esc.RecordDispatch(ctx, true)
+	if len(pager.calls) != 0 {
+		t.Fatalf("expected no pages before the sustain period, got %v", pager.calls)
+	}
+
+	// Sustained long enough, page exactly once.
+	now = now.Add(3 * time.Minute)
+	esc.RecordDispatch(ctx, true)
+	esc.RecordDispatch(ctx, true)
+
+	// The failures age out of the window and successes bring the rate down.
+	now = now.Add(6 * time.Minute)
+	esc.RecordDispatch(ctx, false)
+	esc.RecordDispatch(ctx, false)
+	esc.RecordDispatch(ctx, false)
+
+	want := []string{
+		"trigger:" + escalationFailureRateKey,
+		"resolve:" + escalationFailureRateKey,
+	}
+	if diff := cmp.Diff(want, pager.calls); diff != "" {
+		t.Errorf("unexpected pager calls (-want, +got):\n%s", diff)
+	}
+}
+
+func TestEscalator_QueueAge(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pager := &fakePager{}
+	esc := newEscalator(pager, &escalationConfig{
+		FailureRateThreshold: 0.5,
+		MinDispatches:        1,
+		QueueAgeThreshold:    10 * time.Minute,
+		Window:               5 * time.Minute,
+		SustainPeriod:        0,
+	})
+	esc.now = func() time.Time { return now }
+
+	esc.RecordQueueAge(ctx, 2*time.Minute)
+	esc.RecordQueueAge(ctx, 30*time.Minute)
+
+	want := []string{"trigger:" + escalationQueueAgeKey}
+	if diff := cmp.Diff(want, pager.calls); diff != "" {
+		t.Errorf("unexpected pager calls (-want, +got):\n%s", diff)
+	}
+}
+
+func TestEscalator_StuckQueue(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pager := &fakePager{}
+	esc := newEscalator(pager, &escalationConfig{
+		FailureRateThreshold: 0.5,
+		MinDispatches:        1,
+		QueueAgeThreshold:    10 * time.Minute,
+		Window:               5 * time.Minute,
+		SustainPeriod:        2 * time.Minute,
+	})
+	esc.now = func() time.Time { return now }
+
+	// No job ever starts, only the oldest queued job grows older.
+	esc.ObserveQueue(ctx, 5*time.Minute)
+	now = now.Add(6 * time.Minute)
+	esc.ObserveQueue(ctx, 11*time.Minute)
+	if len(pager.calls) != 0 {
+		t.Fatalf("expected no pages before the sustain period, got %v", pager.calls)
+	}
+	now = now.Add(2 * time.Minute)
+	esc.ObserveQueue(ctx, 13*time.Minute)
+
+	// The job is picked up, the queue is empty.
+	now = now.Add(time.Minute)
+	esc.ObserveQueue(ctx, 0)
+
+	want := []string{
+		"trigger:" + escalationQueueAgeKey,
+		"resolve:" + escalationQueueAgeKey,
+	}
+	if diff := cmp.Diff(want, pager.calls); diff != "" {
+		t.Errorf("unexpected pager calls (-want, +got):\n%s", diff)
+	}
+}
+
+func TestServer_OldestQueuedJob(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC)
+	runnerIn := func(name string, jobID int64, queuedAt time.Time, states ...lifecycle.State) *trackedRunner {
+		l := lifecycle.New(queuedAt)
+		for _, st := range states {
+			if err := l.Transition(st, queuedAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &trackedRunner{RunnerName: name, JobID: jobID, Lifecycle: l}
+	}
+
+	s := &Server{runners: newRunnerTracker()}
+	if got := s.oldestQueuedJob(t.Context(), now); got != 0 {
+		t.Errorf("expected no queued job, got %s", got)
+	}
+
+	s.runners.Dispatched(runnerIn("GCP-provisioning", 1, now.Add(-20*time.Minute),
+		lifecycle.StateDispatching, lifecycle.StateProvisioning))
+	s.runners.Dispatched(runnerIn("GCP-online", 2, now.Add(-30*time.Minute),
+		lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateOnline))
+	s.runners.Dispatched(runnerIn("GCP-running", 3, now.Add(-time.Hour),
+		lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateRunning))
+	s.runners.Dispatched(runnerIn("GCP-prewarmed", 0, now.Add(-time.Hour),
+		lifecycle.StateDispatching, lifecycle.StateProvisioning))
+
+	if got, want := s.oldestQueuedJob(t.Context(), now), 30*time.Minute; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+
+	// The runners another instance dispatched are read from the state store.
+	store := newMemStateStore()
+	if err := store.PutRunner(t.Context(), runnerState(runnerIn("GCP-other-instance", 4, now.Add(-45*time.Minute),
+		lifecycle.StateDispatching, lifecycle.StateProvisioning))); err != nil {
+		t.Fatal(err)
+	}
+	s.stateStore = store
+	if got, want := s.oldestQueuedJob(t.Context(), now), 45*time.Minute; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+}
+
+func TestPagerDuty_Trigger(t *testing.T) {
+	t.Parallel()
+
+	var got pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+
+	pd := NewPagerDuty("routing-key")
+	pd.eventsURL = srv.URL
+	if err := pd.Trigger(t.Context(), "dedup", "summary", map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := pagerDutyEvent{
+		RoutingKey:  "routing-key",
+		EventAction: "trigger",
+		DedupKey:    "dedup",
+		Payload: &pagerDutyPayload{
+			Summary:       "summary",
+			Source:        "github-actions-on-gcp",
+			Severity:      "critical",
+			CustomDetails: map[string]string{"k": "v"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected event (-want, +got):\n%s", diff)
+	}
+}
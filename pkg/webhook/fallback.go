@@ -0,0 +1,211 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// rawObject is a JSON object whose members are decoded one at a time, so a
+// member whose type changed upstream does not fail the others.
+type rawObject map[string]json.RawMessage
+
+// decodeRawObject decodes a JSON object. It returns nil for a missing or null
+// member.
+func decodeRawObject(raw json.RawMessage) (rawObject, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	var obj rawObject
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("not an object: %w", err)
+	}
+	return obj, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// object returns the member object, nil if it is missing or not an object.
+func (o rawObject) object(name string) rawObject {
+	obj, err := decodeRawObject(o[name])
+	if err != nil {
+		return nil
+	}
+	return obj
+}
+
+// string returns the member string, nil if it is missing or not a string.
+func (o rawObject) string(name string) *string {
+	var s string
+	if isNull(o[name]) || json.Unmarshal(o[name], &s) != nil {
+		return nil
+	}
+	return &s
+}
+
+// timestamp returns the member RFC 3339 timestamp, nil if it is missing or
+// not a timestamp.
+func (o rawObject) timestamp(name string) *github.Timestamp {
+	s := o.string(name)
+	if s == nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, *s)
+	if err != nil {
+		return nil
+	}
+	return &github.Timestamp{Time: t}
+}
+
+// id returns the member ID, accepting numbers and numeric strings. It returns
+// nil if the member is missing and an error if it is not an ID.
+func (o rawObject) id(name string) (*int64, error) {
+	raw := o[name]
+	if isNull(raw) {
+		return nil, nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%s is not a number", name)
+		}
+		n = json.Number(s)
+	}
+	v, err := strconv.ParseInt(n.String(), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an integer: %w", name, err)
+	}
+	return &v, nil
+}
+
+// labels returns the member labels, accepting strings and objects with a
+// name. It returns nil if the member is missing and an error if it is
+// neither.
+func (o rawObject) labels(name string) ([]string, error) {
+	raw := o[name]
+	if isNull(raw) {
+		return nil, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, fmt.Errorf("%s is not a list", name)
+	}
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		var s string
+		if err := json.Unmarshal(item, &s); err == nil {
+			labels = append(labels, s)
+			continue
+		}
+		var named struct {
+			Name *string `json:"name"`
+		}
+		if err := json.Unmarshal(item, &named); err != nil || named.Name == nil {
+			return nil, fmt.Errorf("%s has a label that is neither a string nor named", name)
+		}
+		labels = append(labels, *named.Name)
+	}
+	return labels, nil
+}
+
+// fallbackWorkflowJobEvent extracts the fields dispatch needs from a
+// workflow_job payload go-github failed to decode, typically because a field
+// changed type upstream. Fields only used for logging are dropped if they do
+// not decode, the fields identifying the job and where its runner goes fail
+// the extraction. Missing fields are left nil for validateWorkflowJobEvent.
+func fallbackWorkflowJobEvent(payload []byte) (*github.WorkflowJobEvent, error) {
+	root, err := decodeRawObject(payload)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, fmt.Errorf("payload is null")
+	}
+
+	var event github.WorkflowJobEvent
+	if !isNull(root["action"]) {
+		if event.Action = root.string("action"); event.Action == nil {
+			return nil, fmt.Errorf("action is not a string")
+		}
+	}
+
+	job, err := decodeRawObject(root["workflow_job"])
+	if err != nil {
+		return nil, fmt.Errorf("workflow_job: %w", err)
+	}
+	if job != nil {
+		wj := &github.WorkflowJob{
+			Name:            job.string("name"),
+			HeadSHA:         job.string("head_sha"),
+			HeadBranch:      job.string("head_branch"),
+			WorkflowName:    job.string("workflow_name"),
+			Status:          job.string("status"),
+			Conclusion:      job.string("conclusion"),
+			RunnerName:      job.string("runner_name"),
+			RunnerGroupName: job.string("runner_group_name"),
+			CreatedAt:       job.timestamp("created_at"),
+			StartedAt:       job.timestamp("started_at"),
+			CompletedAt:     job.timestamp("completed_at"),
+		}
+		if wj.ID, err = job.id("id"); err != nil {
+			return nil, fmt.Errorf("workflow_job.%w", err)
+		}
+		if wj.RunID, err = job.id("run_id"); err != nil {
+			return nil, fmt.Errorf("workflow_job.%w", err)
+		}
+		if wj.Labels, err = job.labels("labels"); err != nil {
+			return nil, fmt.Errorf("workflow_job.%w", err)
+		}
+		// Optional IDs are dropped if they do not decode.
+		wj.RunAttempt, _ = job.id("run_attempt")
+		wj.RunnerGroupID, _ = job.id("runner_group_id")
+		event.WorkflowJob = wj
+	}
+
+	if installation := root.object("installation"); installation != nil {
+		id, err := installation.id("id")
+		if err != nil {
+			return nil, fmt.Errorf("installation.%w", err)
+		}
+		event.Installation = &github.Installation{ID: id}
+	}
+	if org := root.object("organization"); org != nil {
+		event.Org = &github.Organization{Login: org.string("login")}
+	}
+	if repo := root.object("repository"); repo != nil {
+		event.Repo = &github.Repository{
+			Name:     repo.string("name"),
+			FullName: repo.string("full_name"),
+		}
+		if owner := repo.object("owner"); owner != nil {
+			event.Repo.Owner = &github.User{Login: owner.string("login")}
+		}
+	}
+	if sender := root.object("sender"); sender != nil {
+		event.Sender = &github.User{Login: sender.string("login")}
+	}
+	return &event, nil
+}
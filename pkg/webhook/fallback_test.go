@@ -0,0 +1,132 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestFallbackWorkflowJobEvent(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		payload string
+		want    *github.WorkflowJobEvent
+		wantErr string
+	}{
+		{
+			name: "changed_types",
+			payload: `{
+				"action": "queued",
+				"workflow_job": {
+					"id": "1",
+					"run_id": 2,
+					"run_attempt": "first",
+					"name": {"text": "build"},
+					"labels": [{"name": "self-hosted"}, "linux"],
+					"created_at": "2025-01-01T00:00:00Z",
+					"started_at": 1735689600
+				},
+				"installation": {"id": 3, "node_id": 4},
+				"organization": {"login": "google"},
+				"repository": {"name": "webhook", "owner": {"login": "google"}},
+				"sender": {"login": ["octocat"]}
+			}`,
+			want: &github.WorkflowJobEvent{
+				Action: github.Ptr("queued"),
+				WorkflowJob: &github.WorkflowJob{
+					ID:        github.Ptr(int64(1)),
+					RunID:     github.Ptr(int64(2)),
+					Labels:    []string{"self-hosted", "linux"},
+					CreatedAt: &github.Timestamp{Time: createdAt},
+				},
+				Installation: &github.Installation{ID: github.Ptr(int64(3))},
+				Org:          &github.Organization{Login: github.Ptr("google")},
+				Repo: &github.Repository{
+					Name:  github.Ptr("webhook"),
+					Owner: &github.User{Login: github.Ptr("google")},
+				},
+				Sender: &github.User{},
+			},
+		},
+		{
+			name:    "missing_fields",
+			payload: `{"action": "completed", "workflow_job": null}`,
+			want:    &github.WorkflowJobEvent{Action: github.Ptr("completed")},
+		},
+		{
+			name:    "invalid_job_id",
+			payload: `{"action": "queued", "workflow_job": {"id": "not-a-number"}}`,
+			wantErr: "workflow_job.id is not an integer",
+		},
+		{
+			name:    "invalid_installation_id",
+			payload: `{"action": "queued", "installation": {"id": true}}`,
+			wantErr: "installation.id is not a number",
+		},
+		{
+			name:    "invalid_labels",
+			payload: `{"action": "queued", "workflow_job": {"labels": [1]}}`,
+			wantErr: "workflow_job.labels has a label that is neither a string nor named",
+		},
+		{
+			name:    "invalid_action",
+			payload: `{"action": 1}`,
+			wantErr: "action is not a string",
+		},
+		{
+			name:    "not_an_object",
+			payload: `[]`,
+			wantErr: "not an object",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := fallbackWorkflowJobEvent([]byte(tc.payload))
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected event (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProcessRequest_FallbackPayload(t *testing.T) {
+	t.Parallel()
+
+	// go-github fails to decode the name as a string.
+	resp := serveFuzzDelivery(t, "workflow_job", []byte(`{"action": "in_progress", "workflow_job": {"id": 1, "run_id": 2, "name": {"text": "build"}}}`), false)
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := resp.Body.String(), "workflow job in progress event logged"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
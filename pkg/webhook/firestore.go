@@ -0,0 +1,287 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+const (
+	// firestoreRunnersCollection holds a document per runner, named after
+	// the runner.
+	firestoreRunnersCollection = "runners"
+
+	// firestoreJobsCollection holds a document per job, listing the names of
+	// the runners provisioned for the job.
+	firestoreJobsCollection = "jobs"
+
+	// firestoreActiveRunnersCollection holds a copy of the document of each
+	// runner that did not reach a terminal state, so the active runners are
+	// listed without querying all runners.
+	firestoreActiveRunnersCollection = "active_runners"
+
+	// firestoreListPageSize is the number of documents listed per page.
+	firestoreListPageSize = 300
+)
+
+// FirestoreStateStore keeps the state of runners in Firestore.
+type FirestoreStateStore struct {
+	svc      *firestore.Service
+	database string
+}
+
+// NewFirestoreStateStore creates a new state store keeping the state of
+// runners in the Firestore database, formatted as
+// projects/<project_id>/databases/<database_id>.
+func NewFirestoreStateStore(ctx context.Context, database string, opts ...option.ClientOption) (*FirestoreStateStore, error) {
+	svc, err := firestore.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new firestore client: %w", err)
+	}
+
+	return &FirestoreStateStore{
+		svc:      svc,
+		database: database,
+	}, nil
+}
+
+// PutRunner writes the runner document, adds the runner to the document of
+// its job, and adds or removes its copy among the active runners, in a single
+// commit.
+func (fs *FirestoreStateStore) PutRunner(ctx context.Context, state *RunnerState) error {
+	fields := runnerStateFields(state)
+	writes := []*firestore.Write{{
+		Update: &firestore.Document{
+			Name:   fs.documentName(firestoreRunnersCollection, state.RunnerName),
+			Fields: fields,
+		},
+	}}
+	if state.State.Terminal() {
+		writes = append(writes, &firestore.Write{
+			Delete: fs.documentName(firestoreActiveRunnersCollection, state.RunnerName),
+		})
+	} else {
+		writes = append(writes, &firestore.Write{
+			Update: &firestore.Document{
+				Name:   fs.documentName(firestoreActiveRunnersCollection, state.RunnerName),
+				Fields: fields,
+			},
+		})
+	}
+	if state.JobID != 0 {
+		// An empty mask leaves the other fields of the job document alone,
+		// the transform appends the runner unless it is already listed.
+		writes = append(writes, &firestore.Write{
+			Update: &firestore.Document{
+				Name: fs.documentName(firestoreJobsCollection, strconv.FormatInt(state.JobID, 10)),
+			},
+			UpdateMask: &firestore.DocumentMask{},
+			UpdateTransforms: []*firestore.FieldTransform{{
+				FieldPath: "runner_names",
+				AppendMissingElements: &firestore.ArrayValue{
+					Values: []*firestore.Value{{StringValue: state.RunnerName}},
+				},
+			}},
+		})
+	}
+
+	if _, err := fs.svc.Projects.Databases.Documents.Commit(fs.database, &firestore.CommitRequest{
+		Writes: writes,
+	}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to commit runner state: %w", err)
+	}
+	return nil
+}
+
+// GetRunner returns the state of the runner, or errStateNotFound.
+func (fs *FirestoreStateStore) GetRunner(ctx context.Context, runnerName string) (*RunnerState, error) {
+	doc, err := fs.getDocument(ctx, firestoreRunnersCollection, runnerName)
+	if err != nil {
+		return nil, err
+	}
+	return runnerStateFromFields(doc.Fields), nil
+}
+
+// JobRunners returns the states of the runners provisioned for the job.
+// Runners listed on the job without a state of their own are skipped.
+func (fs *FirestoreStateStore) JobRunners(ctx context.Context, jobID int64) ([]*RunnerState, error) {
+	doc, err := fs.getDocument(ctx, firestoreJobsCollection, strconv.FormatInt(jobID, 10))
+	if err != nil {
+		if errors.Is(err, errStateNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var states []*RunnerState
+	for _, name := range stringArrayField(doc.Fields, "runner_names") {
+		state, err := fs.GetRunner(ctx, name)
+		if err != nil {
+			if errors.Is(err, errStateNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// ActiveRunners returns the states of the runners listed among the active
+// runners.
+func (fs *FirestoreStateStore) ActiveRunners(ctx context.Context) ([]*RunnerState, error) {
+	var states []*RunnerState
+	if err := fs.svc.Projects.Databases.Documents.List(fs.database+"/documents", firestoreActiveRunnersCollection).
+		PageSize(firestoreListPageSize).
+		Pages(ctx, func(resp *firestore.ListDocumentsResponse) error {
+			for _, doc := range resp.Documents {
+				states = append(states, runnerStateFromFields(doc.Fields))
+			}
+			return nil
+		}); err != nil {
+		return nil, fmt.Errorf("failed to list active runners: %w", err)
+	}
+	return states, nil
+}
+
+// getDocument returns the document of the collection, or errStateNotFound.
+func (fs *FirestoreStateStore) getDocument(ctx context.Context, collection, id string) (*firestore.Document, error) {
+	doc, err := fs.svc.Projects.Databases.Documents.Get(fs.documentName(collection, id)).Context(ctx).Do()
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %w", errStateNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return doc, nil
+}
+
+// documentName returns the full name of the document of the collection.
+func (fs *FirestoreStateStore) documentName(collection, id string) string {
+	return fs.database + "/documents/" + collection + "/" + id
+}
+
+// parseFirestoreDatabase validates the name of a Firestore database, formatted
+// as projects/<project_id>/databases/<database_id>.
+func parseFirestoreDatabase(name string) error {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "databases" || parts[1] == "" || parts[3] == "" {
+		return fmt.Errorf("invalid database name %q, expected projects/<project_id>/databases/<database_id>", name)
+	}
+	return nil
+}
+
+// runnerStateFields returns the fields of the runner document.
+func runnerStateFields(state *RunnerState) map[string]firestore.Value {
+	fields := map[string]firestore.Value{
+		"runner_name":     {StringValue: state.RunnerName},
+		"delivery_id":     {StringValue: state.DeliveryID},
+		"installation_id": {IntegerValue: state.InstallationID, ForceSendFields: []string{"IntegerValue"}},
+		"org":             {StringValue: state.Org},
+		"repo":            {StringValue: state.Repo},
+		"run_id":          {IntegerValue: state.RunID, ForceSendFields: []string{"IntegerValue"}},
+		"job_id":          {IntegerValue: state.JobID, ForceSendFields: []string{"IntegerValue"}},
+		"project_id":      {StringValue: state.ProjectID},
+		"location":        {StringValue: state.Location},
+		"build_id":        {StringValue: state.BuildID},
+		"backend":         {StringValue: state.Backend},
+		"backend_id":      {StringValue: state.BackendID},
+		"state":           {StringValue: string(state.State)},
+		"runner_group_id": {IntegerValue: state.RunnerGroupID, ForceSendFields: []string{"IntegerValue"}},
+		"actor":           {StringValue: state.Actor},
+		"spot":            {BooleanValue: state.Spot, ForceSendFields: []string{"BooleanValue"}},
+		"reusable":        {BooleanValue: state.Reusable, ForceSendFields: []string{"BooleanValue"}},
+		"jobs_served":     {IntegerValue: int64(state.JobsServed), ForceSendFields: []string{"IntegerValue"}},
+	}
+	for k, v := range fields {
+		if v.ForceSendFields == nil {
+			v.ForceSendFields = []string{"StringValue"}
+			fields[k] = v
+		}
+	}
+
+	enteredAt := make(map[string]firestore.Value, len(state.EnteredAt))
+	for s, at := range state.EnteredAt {
+		enteredAt[string(s)] = firestore.Value{TimestampValue: at.UTC().Format(time.RFC3339Nano)}
+	}
+	fields["entered_at"] = firestore.Value{MapValue: &firestore.MapValue{Fields: enteredAt}}
+
+	labels := make([]*firestore.Value, 0, len(state.Labels))
+	for _, label := range state.Labels {
+		labels = append(labels, &firestore.Value{StringValue: label})
+	}
+	fields["labels"] = firestore.Value{ArrayValue: &firestore.ArrayValue{Values: labels}}
+	return fields
+}
+
+// runnerStateFromFields returns the state of the fields of a runner document.
+func runnerStateFromFields(fields map[string]firestore.Value) *RunnerState {
+	state := &RunnerState{
+		RunnerName:     fields["runner_name"].StringValue,
+		DeliveryID:     fields["delivery_id"].StringValue,
+		InstallationID: fields["installation_id"].IntegerValue,
+		Org:            fields["org"].StringValue,
+		Repo:           fields["repo"].StringValue,
+		RunID:          fields["run_id"].IntegerValue,
+		JobID:          fields["job_id"].IntegerValue,
+		ProjectID:      fields["project_id"].StringValue,
+		Location:       fields["location"].StringValue,
+		BuildID:        fields["build_id"].StringValue,
+		Backend:        fields["backend"].StringValue,
+		BackendID:      fields["backend_id"].StringValue,
+		State:          lifecycle.State(fields["state"].StringValue),
+		RunnerGroupID:  fields["runner_group_id"].IntegerValue,
+		Actor:          fields["actor"].StringValue,
+		Labels:         stringArrayField(fields, "labels"),
+		Spot:           fields["spot"].BooleanValue,
+		Reusable:       fields["reusable"].BooleanValue,
+		JobsServed:     int(fields["jobs_served"].IntegerValue),
+		EnteredAt:      make(map[lifecycle.State]time.Time),
+	}
+	if m := fields["entered_at"].MapValue; m != nil {
+		for s, v := range m.Fields {
+			if at, err := time.Parse(time.RFC3339Nano, v.TimestampValue); err == nil {
+				state.EnteredAt[lifecycle.State(s)] = at
+			}
+		}
+	}
+	return state
+}
+
+// stringArrayField returns the strings of the array field.
+func stringArrayField(fields map[string]firestore.Value, name string) []string {
+	arr := fields[name].ArrayValue
+	if arr == nil || len(arr.Values) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(arr.Values))
+	for _, v := range arr.Values {
+		values = append(values, v.StringValue)
+	}
+	return values
+}
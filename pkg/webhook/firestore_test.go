@@ -0,0 +1,109 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/api/firestore/v1"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+func TestRunnerStateFields(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2025, 3, 1, 10, 0, 0, 123, time.UTC)
+	want := &RunnerState{
+		RunnerName:     "GCP-runner",
+		DeliveryID:     "delivery-1",
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		RunID:          5,
+		JobID:          1,
+		ProjectID:      "runner-project",
+		Location:       "us-central1",
+		BuildID:        "build-1",
+		Backend:        runnerBackendCloudBuild,
+		BackendID:      "projects/runner-project/locations/us-central1/builds/build-1",
+		State:          lifecycle.StateOnline,
+		RunnerGroupID:  3,
+		Actor:          "octocat",
+		Labels:         []string{"self-hosted", "gcp"},
+		Spot:           true,
+		Reusable:       true,
+		JobsServed:     2,
+		EnteredAt: map[lifecycle.State]time.Time{
+			lifecycle.StateQueued: created,
+			lifecycle.StateOnline: created.Add(time.Minute),
+		},
+	}
+
+	// The fields go through the JSON encoding of the API.
+	b, err := json.Marshal(&firestore.Document{Fields: runnerStateFields(want)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc firestore.Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, runnerStateFromFields(doc.Fields)); diff != "" {
+		t.Errorf("unexpected state (-want, +got):\n%s", diff)
+	}
+}
+
+func TestParseFirestoreDatabase(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			input: "projects/my-project/databases/(default)",
+		},
+		{
+			name:    "missing_database",
+			input:   "projects/my-project/databases/",
+			wantErr: "invalid database name",
+		},
+		{
+			name:    "wrong_collection",
+			input:   "projects/my-project/topics/my-topic",
+			wantErr: "invalid database name",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := parseFirestoreDatabase(tc.input)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
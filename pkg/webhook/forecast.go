@@ -0,0 +1,369 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/uuid"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+const (
+	// forecastWeek is the season of the demand forecast: queued jobs are
+	// expected to arrive at the same time of the week as in past weeks.
+	forecastWeek = 7 * 24 * time.Hour
+
+	// forecastSmoothing is the weight of the arrivals of the last week in the
+	// expected arrivals of an interval of the week, the previous expectation
+	// having the rest.
+	forecastSmoothing = 0.5
+
+	// forecastPruneThreshold is the expected arrivals under which every
+	// interval of a series must be for the series to be dropped.
+	forecastPruneThreshold = 0.01
+
+	// maxForecastStateSize bounds the size of the forecast state object.
+	maxForecastStateSize = 16 << 20
+)
+
+// demandSeries is the demand forecast of the runners of a repository
+// requesting a set of labels.
+type demandSeries struct {
+	InstallationID int64    `json:"installation_id"`
+	Org            string   `json:"org"`
+	Repo           string   `json:"repo"`
+	Labels         []string `json:"labels"`
+
+	// Slots are the expected arrivals of queued jobs in each interval of the
+	// week, learned from past weeks.
+	Slots []float64 `json:"slots"`
+
+	// arrivals counts the queued jobs of the current interval.
+	arrivals int
+}
+
+// forecastState is the persisted state of the demand forecast.
+type forecastState struct {
+	Interval string          `json:"interval"`
+	Series   []*demandSeries `json:"series"`
+}
+
+// forecastDemand is the number of runners to provision ahead of demand for
+// the jobs of a series in the coming interval.
+type forecastDemand struct {
+	InstallationID int64
+	Org            string
+	Repo           string
+	Labels         []string
+	Runners        int
+}
+
+// demandForecaster records the queued jobs of each repository and set of
+// labels per interval, and forecasts the jobs of the coming interval from
+// those of the same interval in past weeks, so runners can be provisioned
+// before the spikes of a weekly routine, such as morning merge trains. A nil
+// forecaster records nothing.
+type demandForecaster struct {
+	interval       time.Duration
+	aggressiveness float64
+
+	mu     sync.Mutex
+	series map[string]*demandSeries
+	// slot is the interval of the week being recorded, -1 until the first
+	// interval starts.
+	slot int
+}
+
+func newDemandForecaster(interval time.Duration, aggressiveness float64) *demandForecaster {
+	return &demandForecaster{
+		interval:       interval,
+		aggressiveness: aggressiveness,
+		series:         make(map[string]*demandSeries),
+		slot:           -1,
+	}
+}
+
+// slotOf returns the interval of the week the time falls in.
+func (f *demandForecaster) slotOf(t time.Time) int {
+	return int((t.UnixNano() % int64(forecastWeek)) / int64(f.interval))
+}
+
+// demandKey returns the key of the series of the repository and labels.
+func demandKey(org, repo string, labels []string) string {
+	return org + "/" + repo + "|" + labelsKey(labels)
+}
+
+// record counts a job queued for a runner of the repository with the labels.
+func (f *demandForecaster) record(installationID int64, org, repo string, labels []string) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := demandKey(org, repo, labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &demandSeries{
+			Org:    org,
+			Repo:   repo,
+			Labels: slices.Clone(labels),
+			Slots:  make([]float64, int(forecastWeek/f.interval)),
+		}
+		f.series[key] = s
+	}
+	// The installation of a repository may change.
+	s.InstallationID = installationID
+	s.arrivals++
+}
+
+// advance closes the interval being recorded, learning its arrivals, starts
+// the interval of now, and returns the runners expected to be needed in it.
+// The first interval is only partially recorded and is not learned.
+func (f *demandForecaster) advance(now time.Time) []*forecastDemand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	closed, current := f.slot, f.slotOf(now)
+	f.slot = current
+
+	var demands []*forecastDemand
+	for key, s := range f.series {
+		if closed >= 0 && closed != current {
+			s.Slots[closed] = forecastSmoothing*float64(s.arrivals) + (1-forecastSmoothing)*s.Slots[closed]
+		}
+		s.arrivals = 0
+
+		if slices.Max(s.Slots) < forecastPruneThreshold {
+			delete(f.series, key)
+			continue
+		}
+		if n := int(math.Round(s.Slots[current] * f.aggressiveness)); n > 0 {
+			demands = append(demands, &forecastDemand{
+				InstallationID: s.InstallationID,
+				Org:            s.Org,
+				Repo:           s.Repo,
+				Labels:         slices.Clone(s.Labels),
+				Runners:        n,
+			})
+		}
+	}
+
+	// The most demanded series are served first when runners are capped.
+	slices.SortFunc(demands, func(a, b *forecastDemand) int {
+		if c := b.Runners - a.Runners; c != 0 {
+			return c
+		}
+		return strings.Compare(demandKey(a.Org, a.Repo, a.Labels), demandKey(b.Org, b.Repo, b.Labels))
+	})
+	return demands
+}
+
+// state returns the learned series, to be persisted.
+func (f *demandForecaster) state() *forecastState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	st := &forecastState{Interval: f.interval.String()}
+	for _, key := range slices.Sorted(maps.Keys(f.series)) {
+		s := f.series[key]
+		st.Series = append(st.Series, &demandSeries{
+			InstallationID: s.InstallationID,
+			Org:            s.Org,
+			Repo:           s.Repo,
+			Labels:         slices.Clone(s.Labels),
+			Slots:          slices.Clone(s.Slots),
+		})
+	}
+	return st
+}
+
+// restore replaces the learned series with the persisted ones. State
+// persisted with another interval is rejected.
+func (f *demandForecaster) restore(st *forecastState) error {
+	if st.Interval != f.interval.String() {
+		return fmt.Errorf("state was recorded with interval %s, not %s", st.Interval, f.interval)
+	}
+
+	series := make(map[string]*demandSeries, len(st.Series))
+	for _, s := range st.Series {
+		if got, want := len(s.Slots), int(forecastWeek/f.interval); got != want {
+			return fmt.Errorf("series of %s/%s has %d slots, expected %d", s.Org, s.Repo, got, want)
+		}
+		series[demandKey(s.Org, s.Repo, s.Labels)] = s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.series = series
+	return nil
+}
+
+// runForecaster provisions the runners forecast for each interval until the
+// context is cancelled, restoring the forecast state first and persisting it
+// after every interval.
+func (s *Server) runForecaster(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	if err := s.loadForecastState(ctx); err != nil {
+		logger.WarnContext(ctx, "failed to load forecast state, starting from scratch", "error", err)
+	}
+
+	// Intervals are aligned on the week, so they line up across restarts.
+	now := time.Now()
+	s.forecaster.advance(now)
+	next := now.Truncate(s.forecaster.interval).Add(s.forecaster.interval)
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.forecast(ctx, time.Now())
+			next = next.Add(s.forecaster.interval)
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// forecast starts the interval of now, provisions the runners forecast for it
+// and persists the forecast state. Runners already waiting for a job ahead of
+// demand count towards the forecast. Failures are logged, forecasting is best
+// effort.
+func (s *Server) forecast(ctx context.Context, now time.Time) int {
+	logger := logging.FromContext(ctx)
+
+	idle := make(map[string]int)
+	for _, r := range s.runners.List() {
+		if r.JobID == 0 && r.Lifecycle.EnteredAt(lifecycle.StateRunning).IsZero() {
+			idle[demandKey(r.Org, r.Repo, r.Labels)]++
+		}
+	}
+
+	budget := s.forecastMaxRunners
+	provisioned := 0
+	for _, d := range s.forecaster.advance(now) {
+		n := min(d.Runners-idle[demandKey(d.Org, d.Repo, d.Labels)], budget)
+		if n <= 0 {
+			continue
+		}
+		budget -= n
+
+		logFields := []any{
+			"org", d.Org,
+			"repo", d.Repo,
+			"labels", d.Labels,
+			"forecast_runners", d.Runners,
+			"count", n,
+		}
+		logger.InfoContext(ctx, "provisioning runners for forecast demand", logFields...)
+		for range n {
+			if !s.provisionForecastRunner(ctx, d, logFields) {
+				break
+			}
+			provisioned++
+			s.metrics.recordForecastRunner()
+		}
+	}
+
+	if err := s.saveForecastState(ctx); err != nil {
+		logger.WarnContext(ctx, "failed to save forecast state", "error", err)
+	}
+	return provisioned
+}
+
+// provisionForecastRunner provisions a runner ahead of the forecast demand,
+// or queues it for dispatch, and reports whether it did.
+func (s *Server) provisionForecastRunner(ctx context.Context, d *forecastDemand, logFields []any) bool {
+	runnerID := runnerNamePrefix + uuid.NewString()
+	runnerFields := append(append([]any{}, logFields...), "runner_id", runnerID)
+	req := &runnerRequest{
+		InstallationID: d.InstallationID,
+		Org:            d.Org,
+		Repo:           d.Repo,
+		RunnerName:     runnerID,
+		Labels:         d.Labels,
+	}
+
+	if s.dispatchQueue != nil {
+		return s.enqueueRunner(ctx, req, runnerFields).Code == http.StatusAccepted
+	}
+
+	createdBuild, errResponse := s.provisionRunner(ctx, req, runnerFields)
+	if errResponse != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "stopped provisioning runners for forecast demand",
+			append(runnerFields, "error", errResponse.Error, "response_message", errResponse.Message)...)
+		return false
+	}
+	s.runnerDispatched(ctx, req, createdBuild, runnerFields)
+	return true
+}
+
+// loadForecastState restores the forecast state from its object, if any.
+func (s *Server) loadForecastState(ctx context.Context) error {
+	if s.forecastStore == nil {
+		return nil
+	}
+
+	b, err := s.forecastStore.ReadObject(ctx, s.forecastBucket, s.forecastObject, maxForecastStateSize)
+	if errors.Is(err, errObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read forecast state: %w", err)
+	}
+
+	var st forecastState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return fmt.Errorf("failed to parse forecast state: %w", err)
+	}
+	if err := s.forecaster.restore(&st); err != nil {
+		return fmt.Errorf("failed to restore forecast state: %w", err)
+	}
+	return nil
+}
+
+// saveForecastState persists the forecast state to its object, if any.
+func (s *Server) saveForecastState(ctx context.Context) error {
+	if s.forecastStore == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(s.forecaster.state())
+	if err != nil {
+		return fmt.Errorf("failed to marshal forecast state: %w", err)
+	}
+	if err := s.forecastStore.WriteObject(ctx, s.forecastBucket, s.forecastObject, bytes.NewReader(b), nil); err != nil {
+		return fmt.Errorf("failed to write forecast state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDemandForecaster(t *testing.T) {
+	t.Parallel()
+
+	f := newDemandForecaster(time.Hour, 1.5)
+	labels := []string{"self-hosted", "profile=go"}
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+	// The first interval is only partially recorded.
+	f.record(123, "google", "webhook", labels)
+	if got := f.advance(start); len(got) != 0 {
+		t.Errorf("expected no demand, got %v", got)
+	}
+
+	for range 4 {
+		f.record(123, "google", "webhook", labels)
+	}
+	if got := f.advance(start.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("expected no demand, got %v", got)
+	}
+
+	// A week later, the jobs of the interval are expected again.
+	got := f.advance(start.Add(forecastWeek))
+	want := []*forecastDemand{{
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		Labels:         labels,
+		Runners:        3,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected demand (-want, +got):\n%s", diff)
+	}
+
+	// Series without expected jobs are dropped, like those only recorded in
+	// the first interval.
+	f = newDemandForecaster(time.Hour, 1)
+	f.record(123, "google", "other", labels)
+	f.advance(start)
+	if got := f.state().Series; len(got) != 0 {
+		t.Errorf("expected no series, got %v", got)
+	}
+}
+
+func TestDemandForecaster_Restore(t *testing.T) {
+	t.Parallel()
+
+	f := newDemandForecaster(time.Hour, 1)
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	f.advance(start)
+	f.record(123, "google", "webhook", []string{"self-hosted"})
+	f.advance(start.Add(time.Hour))
+
+	restored := newDemandForecaster(time.Hour, 1)
+	if err := restored.restore(f.state()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(f.state(), restored.state(), cmpopts.IgnoreUnexported(demandSeries{})); diff != "" {
+		t.Errorf("unexpected state (-want, +got):\n%s", diff)
+	}
+
+	other := newDemandForecaster(30*time.Minute, 1)
+	if diff := testutil.DiffErrString(other.restore(f.state()), "state was recorded with interval 1h0m0s, not 30m0s"); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestServerForecast(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var registered []string
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		registered = append(registered, name)
+	})
+
+	f := newDemandForecaster(time.Hour, 1)
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	f.advance(start)
+	for range 8 {
+		f.record(123, "google", "webhook", []string{"self-hosted"})
+	}
+	f.advance(start.Add(time.Hour))
+
+	store := &MockActionsCacheStore{}
+	s := &Server{
+		appClient:          app,
+		cbc:                &MockCloudBuildClient{},
+		forecastBucket:     "forecast-bucket",
+		forecastMaxRunners: 3,
+		forecastObject:     "forecast.json",
+		forecastStore:      store,
+		forecaster:         f,
+		ghAPIBaseURL:       ghURL,
+		runners:            newRunnerTracker(),
+	}
+	// A runner already waiting for a job counts towards the forecast.
+	s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-idle", Org: "google", Repo: "webhook", Labels: []string{"self-hosted"}})
+
+	// 4 runners are forecast, one is idle and 3 are provisioned at most.
+	if got, want := s.forecast(t.Context(), start.Add(forecastWeek)), 3; got != want {
+		t.Errorf("expected %d runners to be provisioned, got %d", want, got)
+	}
+	if got, want := len(registered), 3; got != want {
+		t.Errorf("expected %d runners to be registered, got %d", want, got)
+	}
+
+	b, ok := store.Object("forecast.json")
+	if !ok {
+		t.Fatal("expected the forecast state to be saved")
+	}
+	var st forecastState
+	if err := json.Unmarshal(b, &st); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(st.Series), 1; got != want {
+		t.Errorf("expected %d series, got %d", want, got)
+	}
+
+	restored := &Server{forecaster: newDemandForecaster(time.Hour, 1), forecastBucket: "forecast-bucket", forecastObject: "forecast.json", forecastStore: store}
+	if err := restored.loadForecastState(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(f.state(), restored.forecaster.state(), cmpopts.IgnoreUnexported(demandSeries{})); diff != "" {
+		t.Errorf("unexpected state (-want, +got):\n%s", diff)
+	}
+}
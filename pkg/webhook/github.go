@@ -16,41 +16,39 @@ package webhook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/abcxyz/pkg/logging"
 	"golang.org/x/oauth2"
 
 	"github.com/google/go-github/v69/github"
 )
 
-func (s *Server) GenerateRepoJITConfig(ctx context.Context, installationID int64, org, repo, runnerName string) (*github.JITRunnerConfig, *apiResponse) {
-	return s.generateJITConfig(ctx, installationID, org, &repo, runnerName)
+// GenerateRepoJITConfig registers a just-in-time runner with the repository.
+// The runner has the default labels plus the given labels, so that it matches
+// jobs requesting label hints such as image=<name>.
+func (s *Server) GenerateRepoJITConfig(ctx context.Context, installationID int64, org, repo, runnerName string, labels ...string) (*github.JITRunnerConfig, *apiResponse) {
+	// Repository runners can only be in the default runner group.
+	return s.generateJITConfig(ctx, installationID, org, &repo, runnerName, 1, labels)
 }
 
-func (s *Server) GenerateOrgJITConfig(ctx context.Context, installationID int64, org, runnerName string) (*github.JITRunnerConfig, *apiResponse) {
-	return s.generateJITConfig(ctx, installationID, org, nil, runnerName)
+// GenerateOrgJITConfig registers a just-in-time runner with the given runner
+// group of the organization.
+func (s *Server) GenerateOrgJITConfig(ctx context.Context, installationID int64, org, runnerName string, runnerGroupID int64, labels ...string) (*github.JITRunnerConfig, *apiResponse) {
+	return s.generateJITConfig(ctx, installationID, org, nil, runnerName, runnerGroupID, labels)
 }
 
-func (s *Server) generateJITConfig(ctx context.Context, installationID int64, org string, repo *string, runnerName string) (*github.JITRunnerConfig, *apiResponse) {
-	installation, err := s.appClient.InstallationForID(ctx, strconv.FormatInt(installationID, 10))
-	if err != nil {
-		return nil, &apiResponse{http.StatusInternalServerError, "failed to setup installation client", err}
-	}
-
-	httpClient := oauth2.NewClient(ctx, (*installation).AllReposOAuth2TokenSource(ctx, map[string]string{
-		"administration": "write",
-	}))
-
-	gh := github.NewClient(httpClient)
-	baseURL, err := url.Parse(fmt.Sprintf("%s/", s.ghAPIBaseURL))
-	if err != nil {
-		return nil, &apiResponse{http.StatusInternalServerError, "failed to set github base URL", err}
+func (s *Server) generateJITConfig(ctx context.Context, installationID int64, org string, repo *string, runnerName string, runnerGroupID int64, labels []string) (*github.JITRunnerConfig, *apiResponse) {
+	gh, errResponse := s.registrationClient(ctx, installationID, org, repo, runnerName)
+	if errResponse != nil {
+		return nil, errResponse
 	}
-	gh.BaseURL = baseURL
-	gh.UploadURL = baseURL
 
 	// Note that even though event.WorkflowJob.RunID is used for a dynamic string, it's not
 	// guaranteed that particular job will run on this specific runner.
@@ -58,20 +56,208 @@ func (s *Server) generateJITConfig(ctx context.Context, installationID int64, or
 	// guaranteed that particular job will run on this specific runner.
 	jitRequest := &github.GenerateJITConfigRequest{
 		Name:          runnerName,
-		RunnerGroupID: 1,
-		Labels:        []string{defaultRunnerLabel, "Linux", "X64"},
+		RunnerGroupID: runnerGroupID,
+		Labels:        runnerLabels(labels),
 	}
 
 	var jitConfig *github.JITRunnerConfig
+	var err error
 
+	jctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
 	if repo != nil {
-		jitConfig, _, err = gh.Actions.GenerateRepoJITConfig(ctx, org, *repo, jitRequest)
+		jitConfig, _, err = gh.Actions.GenerateRepoJITConfig(jctx, org, *repo, jitRequest)
 	} else {
-		jitConfig, _, err = gh.Actions.GenerateOrgJITConfig(ctx, org, jitRequest)
+		jitConfig, _, err = gh.Actions.GenerateOrgJITConfig(jctx, org, jitRequest)
 	}
 
 	if err != nil {
+		var ghErr *github.ErrorResponse
+		switch {
+		case isGoneResponse(err):
+			err = fmt.Errorf("%w: %w", errRunnerTargetGone, err)
+		case errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusConflict:
+			// Another instance registered the runner since it was looked up.
+			return nil, &apiResponse{http.StatusConflict, "runner already exists", fmt.Errorf("%w: %w", errRunnerExists, err)}
+		}
 		return nil, &apiResponse{http.StatusInternalServerError, "failed to generate jitconfig", err}
 	}
 	return jitConfig, nil
 }
+
+// registrationClient returns an installation client allowed to register
+// runners with the repository, or with the organization if repo is nil, once
+// it checked that no runner is registered under the name yet.
+func (s *Server) registrationClient(ctx context.Context, installationID int64, org string, repo *string, runnerName string) (*github.Client, *apiResponse) {
+	var repos []string
+	permissions := map[string]string{
+		"organization_self_hosted_runners": "write",
+	}
+	if repo != nil {
+		// Scoping the token to the repository makes GitHub reject the request
+		// if the repository is not part of the installation.
+		repos = append(repos, *repo)
+		permissions = map[string]string{
+			"administration": "write",
+		}
+	}
+
+	gh, err := s.installationClient(ctx, installationID, permissions, repos...)
+	if err != nil {
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to setup installation client", err}
+	}
+
+	// Runner names are single use. A runner that already exists under the name
+	// was dispatched for a previous delivery of the same event, or its name
+	// collides with another runner, and must not be reused.
+	fctx, cancel := callContext(ctx, s.githubCallTimeout)
+	existing, err := findRunner(fctx, gh, org, repo, runnerName)
+	cancel()
+	if err != nil {
+		if isGoneResponse(err) {
+			err = fmt.Errorf("%w: %w", errRunnerTargetGone, err)
+		}
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to look up runner", err}
+	}
+	if existing != nil {
+		return nil, &apiResponse{http.StatusConflict, "runner already exists",
+			fmt.Errorf("%w: %s has id %d", errRunnerExists, runnerName, existing.GetID())}
+	}
+	return gh, nil
+}
+
+// findRunner returns the self-hosted runner registered with the repository, or
+// with the organization if repo is nil, under the given name. It returns nil if
+// there is none.
+func findRunner(ctx context.Context, gh *github.Client, org string, repo *string, name string) (*github.Runner, error) {
+	opts := &github.ListRunnersOptions{Name: &name}
+
+	var runners *github.Runners
+	var err error
+	if repo != nil {
+		runners, _, err = gh.Actions.ListRunners(ctx, org, *repo, opts)
+	} else {
+		runners, _, err = gh.Actions.ListOrganizationRunners(ctx, org, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runners: %w", err)
+	}
+
+	for _, r := range runners.Runners {
+		if r.GetName() == name {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// ensureRunnerRemoved checks that the runner of a completed job was removed
+// from the repository. GitHub removes JIT runners after their job, a runner
+// that is still registered could pick up another job and is removed.
+func (s *Server) ensureRunnerRemoved(ctx context.Context, event *github.WorkflowJobEvent, logFields []any) {
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return
+	}
+
+	logger := logging.FromContext(ctx)
+
+	runnerName := event.GetWorkflowJob().GetRunnerName()
+	installationID := event.GetInstallation().GetID()
+	org, repo := event.GetOrg().GetLogin(), event.GetRepo().GetName()
+	if !strings.HasPrefix(runnerName, runnerNamePrefix) || installationID == 0 || org == "" || repo == "" {
+		return
+	}
+
+	gh, err := s.installationClient(ctx, installationID, map[string]string{
+		"administration": "write",
+	}, repo)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to setup installation client to verify runner removal", append(logFields, "error", err)...)
+		return
+	}
+
+	runner, err := findRunner(ctx, gh, org, &repo, runnerName)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to verify runner removal", append(logFields, "error", err)...)
+		return
+	}
+	if runner == nil {
+		return
+	}
+
+	logger.WarnContext(ctx, "removing runner still registered after its job completed",
+		append(logFields, "gh_runner_id", runner.GetID(), "runner_status", runner.GetStatus())...)
+	if _, err := gh.Actions.RemoveRunner(ctx, org, repo, runner.GetID()); err != nil {
+		logger.ErrorContext(ctx, "failed to remove runner", append(logFields, "gh_runner_id", runner.GetID(), "error", err)...)
+	}
+}
+
+// removeRunner removes the runner from the repository, if it is still
+// registered.
+func (s *Server) removeRunner(ctx context.Context, installationID int64, org, repo, runnerName string) error {
+	gh, err := s.installationClient(ctx, installationID, map[string]string{
+		"administration": "write",
+	}, repo)
+	if err != nil {
+		return fmt.Errorf("failed to setup installation client: %w", err)
+	}
+
+	runner, err := findRunner(ctx, gh, org, &repo, runnerName)
+	if err != nil {
+		return err
+	}
+	if runner == nil {
+		return nil
+	}
+	if _, err := gh.Actions.RemoveRunner(ctx, org, repo, runner.GetID()); err != nil {
+		return fmt.Errorf("failed to remove runner %d: %w", runner.GetID(), err)
+	}
+	return nil
+}
+
+// installationClient returns a GitHub client authenticated as the app
+// installation with the given permissions on the given repositories, or on all
+// of its repositories if none are given. The installation and its token are
+// looked up right away, each bounded by the GitHub call timeout.
+func (s *Server) installationClient(ctx context.Context, installationID int64, permissions map[string]string, repos ...string) (*github.Client, error) {
+	ictx, cancel := callContext(ctx, s.githubCallTimeout)
+	installation, err := s.appClient.InstallationForID(ictx, strconv.FormatInt(installationID, 10))
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation: %w", err)
+	}
+
+	tctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
+	ts := installation.AllReposOAuth2TokenSource(tctx, permissions)
+	if len(repos) > 0 {
+		ts = installation.SelectedReposOAuth2TokenSource(tctx, permissions, repos...)
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installation token: %w", err)
+	}
+	httpClient := s.githubHTTPClient(oauth2.StaticTokenSource(token))
+
+	gh := github.NewClient(httpClient)
+	baseURL, err := url.Parse(fmt.Sprintf("%s/", s.ghAPIBaseURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set github base URL: %w", err)
+	}
+	gh.BaseURL = baseURL
+	gh.UploadURL = baseURL
+	return gh, nil
+}
+
+// runnerLabels returns the default runner labels followed by the given labels,
+// without duplicates. GitHub compares labels case-insensitively.
+func runnerLabels(labels []string) []string {
+	out := []string{defaultRunnerLabel, "Linux", runnerArchLabel(runnerArch(labels))}
+	for _, label := range labels {
+		if !slices.ContainsFunc(out, func(l string) bool { return strings.EqualFold(l, label) }) {
+			out = append(out, label)
+		}
+	}
+	return out
+}
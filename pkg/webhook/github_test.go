@@ -0,0 +1,116 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/abcxyz/pkg/githubauth"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestEnsureRunnerRemoved(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		runnerName  string
+		registered  bool
+		wantRemoved bool
+	}{
+		{
+			name:       "already_removed",
+			runnerName: "GCP-789",
+		},
+		{
+			name:        "still_registered",
+			runnerName:  "GCP-789",
+			registered:  true,
+			wantRemoved: true,
+		},
+		{
+			name:       "other_runner",
+			runnerName: "office-mac-mini",
+			registered: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var removed bool
+
+			mux := http.NewServeMux()
+			mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+			}))
+			mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+			}))
+			mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.registered {
+					fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+					return
+				}
+				fmt.Fprintf(w, `{"total_count": 1, "runners": [{"id": 42, "name": %q, "status": "online"}]}`, r.URL.Query().Get("name"))
+			}))
+			mux.Handle("DELETE /repos/google/webhook/actions/runners/42", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				removed = true
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			fakeGitHub := httptest.NewServer(mux)
+			t.Cleanup(fakeGitHub.Close)
+
+			rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app, err := githubauth.NewApp("app-id", rsaPrivateKey, githubauth.WithBaseURL(fakeGitHub.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Server{
+				appClient:    app,
+				ghAPIBaseURL: fakeGitHub.URL,
+			}
+			s.ensureRunnerRemoved(t.Context(), &github.WorkflowJobEvent{
+				Action:       github.Ptr("completed"),
+				WorkflowJob:  &github.WorkflowJob{RunnerName: github.Ptr(tc.runnerName)},
+				Installation: &github.Installation{ID: github.Ptr(int64(123))},
+				Org:          &github.Organization{Login: github.Ptr("google")},
+				Repo:         &github.Repository{Name: github.Ptr("webhook")},
+			}, nil)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got, want := removed, tc.wantRemoved; got != want {
+				t.Errorf("expected removed %t to be %t", got, want)
+			}
+		})
+	}
+}
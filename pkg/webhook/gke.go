@@ -0,0 +1,185 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
+)
+
+// GKE creates and deletes the Kubernetes Jobs runners run in, in a GKE
+// cluster. The cluster endpoint is looked up on first use.
+type GKE struct {
+	cluster     string
+	clusters    *container.Service
+	tokenSource oauth2.TokenSource
+
+	mu       sync.Mutex
+	endpoint string
+	client   *http.Client
+}
+
+// NewGKE creates a new instance of a client of the GKE cluster in the form
+// projects/<project>/locations/<location>/clusters/<name>.
+func NewGKE(ctx context.Context, cluster string, opts ...option.ClientOption) (*GKE, error) {
+	clusters, err := container.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new container client: %w", err)
+	}
+	creds, err := transport.Creds(ctx, append([]option.ClientOption{
+		option.WithScopes("https://www.googleapis.com/auth/cloud-platform"),
+	}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find credentials: %w", err)
+	}
+
+	return &GKE{
+		cluster:     cluster,
+		clusters:    clusters,
+		tokenSource: creds.TokenSource,
+	}, nil
+}
+
+// kubernetesClient returns the endpoint of the cluster and a client trusting
+// its certificate authority, authenticating with the Google credentials.
+func (g *GKE) kubernetesClient(ctx context.Context) (string, *http.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client != nil {
+		return g.endpoint, g.client, nil
+	}
+
+	cluster, err := g.clusters.Projects.Locations.Clusters.Get(g.cluster).Context(ctx).Do()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get cluster %s: %w", g.cluster, err)
+	}
+	ca, err := base64.StdEncoding.DecodeString(cluster.MasterAuth.ClusterCaCertificate)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode cluster ca certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return "", nil, fmt.Errorf("cluster %s has no valid ca certificate", g.cluster)
+	}
+
+	g.endpoint = "https://" + cluster.Endpoint
+	g.client = &http.Client{
+		Transport: &oauth2.Transport{
+			Source: g.tokenSource,
+			Base: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}
+	return g.endpoint, g.client, nil
+}
+
+// do sends a request to the Kubernetes API of the cluster and decodes the
+// response into out, if not nil.
+func (g *GKE) do(ctx context.Context, method, path string, body, out any) error {
+	endpoint, client, err := g.kubernetesClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, r)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(b, &status); err == nil && status.Message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, status.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateJob creates the Kubernetes Job running the runner, and the Secret
+// holding its secret environment variables. The Secret is owned by the Job,
+// so Kubernetes deletes it with the Job. The pod of the Job waits for the
+// Secret to exist.
+func (g *GKE) CreateJob(ctx context.Context, job *RunnerJob) error {
+	var created kubernetesObject
+	if err := g.do(ctx, http.MethodPost,
+		fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", url.PathEscape(job.Namespace)),
+		job.manifest(), &created); err != nil {
+		return fmt.Errorf("failed to create job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+
+	if len(job.SecretEnv) == 0 {
+		return nil
+	}
+	if err := g.do(ctx, http.MethodPost,
+		fmt.Sprintf("/api/v1/namespaces/%s/secrets", url.PathEscape(job.Namespace)),
+		job.secretManifest(created.Metadata.UID), nil); err != nil {
+		return fmt.Errorf("failed to create secret of job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	return nil
+}
+
+// DeleteJob deletes the Kubernetes Job, its pods and its Secret.
+func (g *GKE) DeleteJob(ctx context.Context, namespace, name string) error {
+	body := map[string]string{"kind": "DeleteOptions", "apiVersion": "v1", "propagationPolicy": "Background"}
+	if err := g.do(ctx, http.MethodDelete,
+		fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs/%s", url.PathEscape(namespace), url.PathEscape(name)),
+		body, nil); err != nil {
+		return fmt.Errorf("failed to delete job %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
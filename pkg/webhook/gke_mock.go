@@ -0,0 +1,50 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// MockJobClient records the Jobs created and deleted, failing with CreateErr
+// and DeleteErr.
+type MockJobClient struct {
+	CreateErr error
+	DeleteErr error
+
+	mu      sync.Mutex
+	Created []*RunnerJob
+	Deleted []string
+}
+
+func (m *MockJobClient) CreateJob(ctx context.Context, job *RunnerJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateErr != nil {
+		return m.CreateErr
+	}
+	m.Created = append(m.Created, job)
+	return nil
+}
+
+func (m *MockJobClient) DeleteJob(ctx context.Context, namespace, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Deleted = append(m.Deleted, namespace+"/"+name)
+	return m.DeleteErr
+}
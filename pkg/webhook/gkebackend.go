@@ -0,0 +1,233 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runnerJobTTL is how long Kubernetes keeps a finished runner Job whose
+// completion this instance did not see, in seconds.
+const runnerJobTTL = 3600
+
+// JobClient adheres to the interaction the webhook service has with the
+// Kubernetes API of a GKE cluster to run runners in Jobs.
+type JobClient interface {
+	CreateJob(ctx context.Context, job *RunnerJob) error
+	DeleteJob(ctx context.Context, namespace, name string) error
+}
+
+// RunnerJob is a Kubernetes Job running a runner in a single pod.
+type RunnerJob struct {
+	Namespace      string
+	Name           string
+	Image          string
+	ServiceAccount string
+	Labels         map[string]string
+	Env            map[string]string
+
+	// SecretEnv are the environment variables passed through a Secret owned by
+	// the Job, named after the Job with a -env suffix.
+	SecretEnv map[string]string
+
+	// DeadlineSeconds is how long the Job may run before Kubernetes stops it,
+	// 0 for no limit.
+	DeadlineSeconds int64
+
+	// Arch is the architecture of the node the pod is scheduled on, "" for
+	// any.
+	Arch string
+
+	// SecurityMode is the security mode of the runner container, privileged
+	// when "".
+	SecurityMode string
+}
+
+// SecretName returns the name of the Secret holding the secret environment of
+// the Job.
+func (j *RunnerJob) SecretName() string {
+	return j.Name + "-env"
+}
+
+// kubernetesObject is the subset of a Kubernetes object the webhook service
+// writes and reads.
+type kubernetesObject struct {
+	APIVersion string             `json:"apiVersion,omitempty"`
+	Kind       string             `json:"kind,omitempty"`
+	Metadata   kubernetesMetadata `json:"metadata"`
+	Spec       map[string]any     `json:"spec,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	StringData map[string]string  `json:"stringData,omitempty"`
+}
+
+type kubernetesMetadata struct {
+	Name            string                     `json:"name,omitempty"`
+	Namespace       string                     `json:"namespace,omitempty"`
+	UID             string                     `json:"uid,omitempty"`
+	Labels          map[string]string          `json:"labels,omitempty"`
+	OwnerReferences []kubernetesOwnerReference `json:"ownerReferences,omitempty"`
+}
+
+type kubernetesOwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+}
+
+// manifest returns the Kubernetes Job. It is not retried, a runner whose pod
+// failed is left for GitHub to remove like a failed build.
+func (j *RunnerJob) manifest() *kubernetesObject {
+	env := make([]map[string]any, 0, len(j.Env)+len(j.SecretEnv))
+	for _, name := range slices.Sorted(maps.Keys(j.Env)) {
+		env = append(env, map[string]any{"name": name, "value": j.Env[name]})
+	}
+	for _, name := range slices.Sorted(maps.Keys(j.SecretEnv)) {
+		env = append(env, map[string]any{
+			"name": name,
+			"valueFrom": map[string]any{
+				"secretKeyRef": map[string]any{"name": j.SecretName(), "key": name},
+			},
+		})
+	}
+
+	podSpec := map[string]any{
+		"restartPolicy": "Never",
+		"containers": []map[string]any{
+			{
+				"name":            "runner",
+				"image":           j.Image,
+				"env":             env,
+				"securityContext": runnerSecurityContext(j.SecurityMode),
+			},
+		},
+	}
+	if j.ServiceAccount != "" {
+		podSpec["serviceAccountName"] = j.ServiceAccount
+	}
+	if j.Arch != "" {
+		podSpec["nodeSelector"] = map[string]string{"kubernetes.io/arch": j.Arch}
+	}
+
+	spec := map[string]any{
+		"backoffLimit":            0,
+		"ttlSecondsAfterFinished": runnerJobTTL,
+		"template": map[string]any{
+			"metadata": map[string]any{"labels": j.Labels},
+			"spec":     podSpec,
+		},
+	}
+	if j.DeadlineSeconds > 0 {
+		spec["activeDeadlineSeconds"] = j.DeadlineSeconds
+	}
+
+	return &kubernetesObject{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata: kubernetesMetadata{
+			Name:      j.Name,
+			Namespace: j.Namespace,
+			Labels:    j.Labels,
+		},
+		Spec: spec,
+	}
+}
+
+// secretManifest returns the Secret holding the secret environment of the
+// Job, owned by the Job with the given UID.
+func (j *RunnerJob) secretManifest(jobUID string) *kubernetesObject {
+	return &kubernetesObject{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: kubernetesMetadata{
+			Name:      j.SecretName(),
+			Namespace: j.Namespace,
+			Labels:    j.Labels,
+			OwnerReferences: []kubernetesOwnerReference{
+				{APIVersion: "batch/v1", Kind: "Job", Name: j.Name, UID: jobUID},
+			},
+		},
+		Type:       "Opaque",
+		StringData: j.SecretEnv,
+	}
+}
+
+// gkeBackend runs each runner in a Kubernetes Job of the runner cluster. Its
+// IDs are the Jobs as <namespace>/<name>.
+type gkeBackend struct {
+	jobs           JobClient
+	namespace      string
+	serviceAccount string
+	timeout        time.Duration
+}
+
+// Provision creates the Job, with the environment the run step of the build
+// would pass the runner. The JIT configuration is passed through a Secret. The
+// other steps of the build are not run. The Job is limited to the timeout of
+// the build, if any, and deleted when its job completes.
+func (b *gkeBackend) Provision(ctx context.Context, spec *RunnerSpec) (string, error) {
+	encoded, _ := spec.JITConfig()
+	job := &RunnerJob{
+		Namespace:      b.namespace,
+		Name:           runnerInstanceName(spec.RunnerName),
+		Image:          spec.Image(),
+		ServiceAccount: b.serviceAccount,
+		Labels: map[string]string{
+			"app.kubernetes.io/managed-by": "github-actions-on-gcp",
+		},
+		Env:             spec.Env(),
+		SecretEnv:       map[string]string{jitConfigEnv: encoded},
+		DeadlineSeconds: spec.Build.GetTimeout().GetSeconds(),
+		Arch:            spec.Arch,
+		SecurityMode:    spec.SecurityMode(),
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.jobs.CreateJob(cctx, job); err != nil {
+		return "", fmt.Errorf("failed to create runner job: %w", err)
+	}
+	return job.Namespace + "/" + job.Name, nil
+}
+
+func (b *gkeBackend) Cancel(ctx context.Context, id string) error {
+	namespace, name, ok := strings.Cut(id, "/")
+	if !ok {
+		return fmt.Errorf("%q is not of the form <namespace>/<name>", id)
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.jobs.DeleteJob(cctx, namespace, name); err != nil {
+		return fmt.Errorf("failed to delete runner job: %w", err)
+	}
+	return nil
+}
+
+// parseGKECluster validates a GKE cluster in the form
+// projects/<project>/locations/<location>/clusters/<name>.
+func parseGKECluster(name string) error {
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "clusters" ||
+		parts[1] == "" || parts[3] == "" || parts[5] == "" {
+		return fmt.Errorf("%q must be in the form projects/<project>/locations/<location>/clusters/<name>", name)
+	}
+	return nil
+}
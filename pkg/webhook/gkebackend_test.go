@@ -0,0 +1,212 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerJobManifest(t *testing.T) {
+	t.Parallel()
+
+	job := &RunnerJob{
+		Namespace:       "runners",
+		Name:            "gcp-2",
+		Image:           "us-docker.pkg.dev/p/runners/default-runner:latest",
+		ServiceAccount:  "github-runner",
+		Env:             map[string]string{"HTTP_PROXY": "http://proxy:3128"},
+		SecretEnv:       map[string]string{"ENCODED_JIT_CONFIG": "encoded-jit-config"},
+		DeadlineSeconds: 3600,
+		Arch:            "arm64",
+	}
+
+	b, err := json.Marshal(job.manifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata":   map[string]any{"name": "gcp-2", "namespace": "runners"},
+		"spec": map[string]any{
+			"backoffLimit":            float64(0),
+			"ttlSecondsAfterFinished": float64(runnerJobTTL),
+			"activeDeadlineSeconds":   float64(3600),
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": nil},
+				"spec": map[string]any{
+					"restartPolicy":      "Never",
+					"serviceAccountName": "github-runner",
+					"nodeSelector":       map[string]any{"kubernetes.io/arch": "arm64"},
+					"containers": []any{
+						map[string]any{
+							"name":  "runner",
+							"image": "us-docker.pkg.dev/p/runners/default-runner:latest",
+							"env": []any{
+								map[string]any{"name": "HTTP_PROXY", "value": "http://proxy:3128"},
+								map[string]any{
+									"name": "ENCODED_JIT_CONFIG",
+									"valueFrom": map[string]any{
+										"secretKeyRef": map[string]any{"name": "gcp-2-env", "key": "ENCODED_JIT_CONFIG"},
+									},
+								},
+							},
+							"securityContext": map[string]any{"privileged": true},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected manifest (-want, +got):\n%s", diff)
+	}
+
+	secret := job.secretManifest("job-uid")
+	if got, want := secret.Metadata.Name, "gcp-2-env"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if diff := cmp.Diff([]kubernetesOwnerReference{
+		{APIVersion: "batch/v1", Kind: "Job", Name: "gcp-2", UID: "job-uid"},
+	}, secret.Metadata.OwnerReferences); diff != "" {
+		t.Errorf("unexpected owner references (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(job.SecretEnv, secret.StringData); diff != "" {
+		t.Errorf("unexpected secret data (-want, +got):\n%s", diff)
+	}
+}
+
+func TestGKEBackendProvision(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		timeout      time.Duration
+		createErr    error
+		wantJob      string
+		wantDeadline int64
+		wantErr      string
+	}{
+		{
+			name:    "success",
+			wantJob: "runners/gcp-2",
+		},
+		{
+			name:         "build_timeout",
+			timeout:      6 * time.Hour,
+			wantJob:      "runners/gcp-2",
+			wantDeadline: 6 * 60 * 60,
+		},
+		{
+			name:      "create_error",
+			createErr: fmt.Errorf("forbidden"),
+			wantErr:   "failed to create runner job: forbidden",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			jobs := &MockJobClient{CreateErr: tc.createErr}
+			b := &gkeBackend{jobs: jobs, namespace: "runners"}
+			s := &Server{
+				runnerHTTPProxy:    "http://proxy:3128",
+				runnerImageName:    "default-runner",
+				runnerImageTag:     "latest",
+				runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+			if tc.timeout > 0 {
+				build.Timeout = durationpb.New(tc.timeout)
+			}
+
+			got, err := b.Provision(t.Context(), &RunnerSpec{RunnerName: "GCP-2", Build: build})
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := got, tc.wantJob; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+
+			if got, want := len(jobs.Created), 1; got != want {
+				t.Fatalf("expected %d to be %d", got, want)
+			}
+			job := jobs.Created[0]
+			if got, want := job.Image, "us-docker.pkg.dev/p/runners/default-runner:latest"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := job.DeadlineSeconds, tc.wantDeadline; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := job.Env["HTTP_PROXY"], "http://proxy:3128"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if diff := cmp.Diff(map[string]string{"ENCODED_JIT_CONFIG": "encoded-jit-config"}, job.SecretEnv); diff != "" {
+				t.Errorf("unexpected secret env (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseGKECluster(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		cluster string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			cluster: "projects/p/locations/us-central1/clusters/runners",
+		},
+		{
+			name:    "missing_location",
+			cluster: "projects/p/clusters/runners",
+			wantErr: "must be in the form",
+		},
+		{
+			name:    "empty_name",
+			cluster: "projects/p/locations/us-central1/clusters/",
+			wantErr: "must be in the form",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(parseGKECluster(tc.cluster), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
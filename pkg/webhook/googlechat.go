@@ -0,0 +1,133 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"golang.org/x/time/rate"
+)
+
+// googleChatBurst is the number of notifications that may be sent back to back
+// before rate limiting kicks in.
+const googleChatBurst = 5
+
+// GoogleChatNotifier posts notifications to a Google Chat space through an
+// incoming webhook. Notifications beyond the configured rate are dropped and
+// summarized in the next message that gets through, so an incident does not
+// flood the space.
+type GoogleChatNotifier struct {
+	webhookURL string
+	httpClient *http.Client
+	limiter    *rate.Limiter
+
+	mu         sync.Mutex
+	suppressed int
+
+	// pending tracks the messages being posted.
+	pending sync.WaitGroup
+}
+
+// NewGoogleChatNotifier creates a notifier that sends at most one message per
+// interval after an initial burst.
+func NewGoogleChatNotifier(webhookURL string, interval time.Duration) *GoogleChatNotifier {
+	limit := rate.Inf
+	if interval > 0 {
+		limit = rate.Every(interval)
+	}
+
+	return &GoogleChatNotifier{
+		webhookURL: webhookURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		limiter: rate.NewLimiter(limit, googleChatBurst),
+	}
+}
+
+// Notify posts the notification to the Google Chat space, unless the rate
+// limit has been exceeded. The message is posted in the background, so Notify
+// does not hold up the delivery that triggered it; failures are logged.
+func (g *GoogleChatNotifier) Notify(ctx context.Context, n *Notification) error {
+	g.mu.Lock()
+	if !g.limiter.Allow() {
+		g.suppressed++
+		g.mu.Unlock()
+		return nil
+	}
+	suppressed := g.suppressed
+	g.suppressed = 0
+	g.mu.Unlock()
+
+	text := fmt.Sprintf("*%s*\n%s", n.Title, n.Text)
+	if suppressed > 0 {
+		text += fmt.Sprintf("\n_%d earlier notification(s) were suppressed by rate limiting._", suppressed)
+	}
+
+	// The message outlives the delivery, keep its logger but not its
+	// cancellation.
+	ctx = context.WithoutCancel(ctx)
+	g.pending.Add(1)
+	go func() {
+		defer g.pending.Done()
+
+		if err := g.post(ctx, text); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to send notification",
+				"notification_kind", n.Kind,
+				"error", err)
+		}
+	}()
+	return nil
+}
+
+// post sends the message to the Google Chat space.
+func (g *GoogleChatNotifier) post(ctx context.Context, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal google chat message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create google chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post google chat message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected google chat response status %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// Close waits for the messages being posted.
+func (g *GoogleChatNotifier) Close() error {
+	g.pending.Wait()
+	return nil
+}
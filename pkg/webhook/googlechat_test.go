@@ -0,0 +1,137 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestGoogleChatNotifier(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var messages []string
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode chat message: %v", err)
+		}
+		mu.Lock()
+		messages = append(messages, body.Text)
+		mu.Unlock()
+	}))
+	t.Cleanup(chat.Close)
+
+	// A long interval means only the initial burst gets through.
+	notifier := NewGoogleChatNotifier(chat.URL, time.Hour)
+	for range googleChatBurst + 3 {
+		if err := notifier.Notify(t.Context(), &Notification{
+			Kind:  NotificationDispatchFailure,
+			Title: "Runner dispatch failed",
+			Text:  "failed to run build",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notifier.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if got, want := len(messages), googleChatBurst; got != want {
+		t.Fatalf("expected %d messages to be %d", got, want)
+	}
+	if got, want := messages[0], "*Runner dispatch failed*\nfailed to run build"; got != want {
+		t.Errorf("expected message %q to be %q", got, want)
+	}
+	mu.Unlock()
+
+	if got, want := notifier.suppressed, 3; got != want {
+		t.Errorf("expected %d suppressed notifications to be %d", got, want)
+	}
+
+	// Once the limiter admits a message again, the suppressed count is
+	// reported.
+	notifier.limiter = rate.NewLimiter(rate.Inf, 1)
+	if err := notifier.Notify(t.Context(), &Notification{Title: "t", Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := messages[len(messages)-1]; !strings.Contains(got, "3 earlier notification(s) were suppressed") {
+		t.Errorf("expected suppressed summary in %q", got)
+	}
+}
+
+func TestGoogleChatNotifier_ErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(chat.Close)
+
+	notifier := NewGoogleChatNotifier(chat.URL, 0)
+	err := notifier.post(t.Context(), "x")
+	if err == nil || !strings.Contains(err.Error(), "unexpected google chat response status 403") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestGoogleChatNotifier_DoesNotWait(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	posted := make(chan struct{})
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		close(posted)
+	}))
+	t.Cleanup(chat.Close)
+
+	notifier := NewGoogleChatNotifier(chat.URL, 0)
+	if err := notifier.Notify(t.Context(), &Notification{Title: "t", Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The chat space has not answered yet.
+	select {
+	case <-posted:
+		t.Fatalf("expected the message to still be in flight")
+	default:
+	}
+	close(release)
+	if err := notifier.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-posted:
+	default:
+		t.Errorf("expected Close to wait for the message to be posted")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+// gpuLabelPrefix prefixes the runner label of jobs requesting a GPU, followed
+// by one of the configured GPU type names, for example gcp-gpu-t4.
+const gpuLabelPrefix = "gcp-gpu-"
+
+// runnerGPU returns the accelerator type attached to the runner, selected by
+// the gcp-gpu- label of the job, or "" when the job requests no GPU. The
+// second return value is the selected GPU type name, and the third is false if
+// the label selects a GPU type that is not configured.
+func (s *Server) runnerGPU(labels []string) (string, string, bool) {
+	name, ok := labelValue(labels, gpuLabelPrefix)
+	if !ok {
+		return "", "", true
+	}
+	accelerator, ok := s.runnerGPUTypes[name]
+	return accelerator, name, ok
+}
+
+// gpuBackend returns the backend GPU runners run on: Batch when configured,
+// otherwise the Compute Engine backend. Cloud Build and GKE cannot attach
+// GPUs, so it returns a nil backend when neither is configured.
+func (s *Server) gpuBackend() (string, RunnerBackend) {
+	if s.batchBackend != nil {
+		return runnerBackendBatch, s.batchBackend
+	}
+	if s.backend != nil && s.runnerBackend == runnerBackendCompute {
+		return runnerBackendCompute, s.backend
+	}
+	return "", nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+)
+
+func TestRunnerGPU(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		runnerGPUTypes: map[string]string{"t4": "nvidia-tesla-t4", "l4": "nvidia-l4"},
+	}
+
+	cases := []struct {
+		name            string
+		labels          []string
+		wantAccelerator string
+		wantName        string
+		wantOK          bool
+	}{
+		{
+			name:   "no_label",
+			labels: []string{"self-hosted"},
+			wantOK: true,
+		},
+		{
+			name:            "gpu",
+			labels:          []string{"self-hosted", "gcp-gpu-l4"},
+			wantAccelerator: "nvidia-l4",
+			wantName:        "l4",
+			wantOK:          true,
+		},
+		{
+			name:     "not_configured",
+			labels:   []string{"self-hosted", "gcp-gpu-a100"},
+			wantName: "a100",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			accelerator, name, ok := srv.runnerGPU(tc.labels)
+			if got, want := accelerator, tc.wantAccelerator; got != want {
+				t.Errorf("expected accelerator %q to be %q", got, want)
+			}
+			if got, want := name, tc.wantName; got != want {
+				t.Errorf("expected name %q to be %q", got, want)
+			}
+			if got, want := ok, tc.wantOK; got != want {
+				t.Errorf("expected ok %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestGPUBackend(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		srv     *Server
+		want    string
+		wantNil bool
+	}{
+		{
+			name: "batch",
+			srv: &Server{
+				backend:       &vmBackend{},
+				batchBackend:  &batchBackend{},
+				runnerBackend: runnerBackendCompute,
+			},
+			want: runnerBackendBatch,
+		},
+		{
+			name: "compute",
+			srv: &Server{
+				backend:       &vmBackend{},
+				runnerBackend: runnerBackendCompute,
+			},
+			want: runnerBackendCompute,
+		},
+		{
+			name: "gke",
+			srv: &Server{
+				backend:       &gkeBackend{},
+				runnerBackend: runnerBackendGKE,
+			},
+			wantNil: true,
+		},
+		{
+			name:    "cloudbuild",
+			srv:     &Server{},
+			wantNil: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			name, backend := tc.srv.gpuBackend()
+			if got, want := name, tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := backend == nil, tc.wantNil; got != want {
+				t.Errorf("expected nil backend %t to be %t", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// BuildLogReader adheres to the interaction the webhook service has with the
+// logs written by runner builds.
+type BuildLogReader interface {
+	Close() error
+	LatestEntryTime(ctx context.Context, projectID, buildID string) (time.Time, error)
+	TailLines(ctx context.Context, projectID, buildID string, n int) ([]string, error)
+}
+
+// runStallMonitor periodically checks in progress runners for stalled output
+// until the context is cancelled.
+func (s *Server) runStallMonitor(ctx context.Context) {
+	ticker := time.NewTicker(s.stallCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkStalledRunners(ctx, time.Now())
+		}
+	}
+}
+
+// checkStalledRunners flags in progress runners whose build has not logged
+// anything for longer than the stall threshold. The runner image emits a
+// heartbeat line while the runner is making progress, so silence means the
+// runner is wedged. Each runner is reported once per stall.
+func (s *Server) checkStalledRunners(ctx context.Context, now time.Time) {
+	logger := logging.FromContext(ctx)
+	s.syncRunners(ctx)
+
+	for _, r := range s.runners.List() {
+		if r.Lifecycle.State() != lifecycle.StateRunning || r.BuildID == "" {
+			continue
+		}
+
+		last, err := s.logReader.LatestEntryTime(ctx, r.ProjectID, r.BuildID)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to read runner build logs",
+				"runner_id", r.RunnerName,
+				"build_id", r.BuildID,
+				"error", err)
+			continue
+		}
+
+		// A runner that has not logged since its job started is measured from
+		// the start of the job.
+		if startedAt := r.Lifecycle.EnteredAt(lifecycle.StateRunning); last.Before(startedAt) {
+			last = startedAt
+		}
+
+		silence := now.Sub(last)
+		stalled := silence > s.stallThreshold
+		if stalled == r.Stalled {
+			continue
+		}
+
+		s.runners.Update(r.RunnerName, func(tr *trackedRunner) {
+			tr.Stalled = stalled
+		})
+
+		if !stalled {
+			logger.InfoContext(ctx, "runner output resumed",
+				"runner_id", r.RunnerName,
+				"build_id", r.BuildID)
+			continue
+		}
+
+		logger.WarnContext(ctx, "runner output stalled",
+			"runner_id", r.RunnerName,
+			"build_id", r.BuildID,
+			"org", r.Org,
+			"repo", r.Repo,
+			"run_id", r.RunID,
+			"job_id", r.JobID,
+			"silence_seconds", silence.Seconds())
+		s.notify(ctx, &Notification{
+			Kind:  NotificationRunnerStalled,
+			Title: fmt.Sprintf("Runner stalled for %s/%s", r.Org, r.Repo),
+			Text: fmt.Sprintf("Runner %s (build %s, run %d, job %d) has produced no output for %s",
+				r.RunnerName, r.BuildID, r.RunID, r.JobID, silence.Round(time.Second)),
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+type recordingNotifier struct {
+	notifications []*Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n *Notification) error {
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+// testLifecycle returns a lifecycle that moved through dispatch to the given
+// state, entering each state at the given time.
+func testLifecycle(tb testing.TB, to lifecycle.State, at time.Time) lifecycle.Lifecycle {
+	tb.Helper()
+
+	l := lifecycle.New(at)
+	for _, state := range []lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning, to} {
+		if l.State() == to {
+			break
+		}
+		if err := l.Transition(state, at); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return l
+}
+
+func TestCheckStalledRunners(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		state       lifecycle.State
+		startedAt   time.Time
+		lastLog     time.Time
+		wantStalled bool
+	}{
+		{
+			name:      "recent_output",
+			state:     lifecycle.StateRunning,
+			startedAt: now.Add(-time.Hour),
+			lastLog:   now.Add(-time.Minute),
+		},
+		{
+			name:        "silent_output",
+			state:       lifecycle.StateRunning,
+			startedAt:   now.Add(-time.Hour),
+			lastLog:     now.Add(-20 * time.Minute),
+			wantStalled: true,
+		},
+		{
+			name:      "recently_started_without_output",
+			state:     lifecycle.StateRunning,
+			startedAt: now.Add(-2 * time.Minute),
+		},
+		{
+			name:    "not_started",
+			state:   lifecycle.StateProvisioning,
+			lastLog: now.Add(-time.Hour),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			notifier := &recordingNotifier{}
+			s := &Server{
+				logReader: &MockBuildLogReader{
+					latestEntryTimes: map[string]time.Time{"build-1": tc.lastLog},
+				},
+				notifier:       notifier,
+				runners:        newRunnerTracker(),
+				stallThreshold: 10 * time.Minute,
+			}
+			s.runners.Dispatched(&trackedRunner{
+				RunnerName: "GCP-1",
+				BuildID:    "build-1",
+				Lifecycle:  testLifecycle(t, tc.state, tc.startedAt),
+			})
+
+			// Checking twice must only report the stall once.
+			s.checkStalledRunners(ctx, now)
+			s.checkStalledRunners(ctx, now)
+
+			got := s.runners.List()[0].Stalled
+			if got != tc.wantStalled {
+				t.Errorf("expected stalled to be %t, got %t", tc.wantStalled, got)
+			}
+
+			wantNotifications := 0
+			if tc.wantStalled {
+				wantNotifications = 1
+			}
+			if len(notifier.notifications) != wantNotifications {
+				t.Errorf("expected %d notifications, got %d", wantNotifications, len(notifier.notifications))
+			}
+		})
+	}
+}
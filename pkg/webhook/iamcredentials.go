@@ -0,0 +1,59 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+)
+
+// IAMCredentials signs blobs with the Google-managed keys of service accounts.
+type IAMCredentials struct {
+	svc *iamcredentials.Service
+}
+
+// NewIAMCredentials creates a new instance of an IAM Credentials client.
+func NewIAMCredentials(ctx context.Context, opts ...option.ClientOption) (*IAMCredentials, error) {
+	svc, err := iamcredentials.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new iam credentials client: %w", err)
+	}
+
+	return &IAMCredentials{
+		svc: svc,
+	}, nil
+}
+
+// SignBlob signs the payload with RSA SHA-256 using a key of the service
+// account. The caller needs the Service Account Token Creator role on the
+// service account.
+func (c *IAMCredentials) SignBlob(ctx context.Context, serviceAccount string, payload []byte) ([]byte, error) {
+	resp, err := c.svc.Projects.ServiceAccounts.SignBlob("projects/-/serviceAccounts/"+serviceAccount, &iamcredentials.SignBlobRequest{
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign blob: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(resp.SignedBlob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signed blob: %w", err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+)
+
+// MockBlobSigner returns Sig, or Err, for every blob and records the signed
+// payloads.
+type MockBlobSigner struct {
+	Sig []byte
+	Err error
+
+	Payloads [][]byte
+}
+
+func (m *MockBlobSigner) SignBlob(ctx context.Context, serviceAccount string, payload []byte) ([]byte, error) {
+	m.Payloads = append(m.Payloads, payload)
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	return m.Sig, nil
+}
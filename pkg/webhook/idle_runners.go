@@ -0,0 +1,115 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// idleRunnerClaimTTL is how long an idle runner a queued job was left to is
+// not counted for other queued jobs, long enough for GitHub to assign it the
+// job.
+const idleRunnerClaimTTL = 2 * time.Minute
+
+// runnerIdleMsg is the response to a queued event of a job left to an idle
+// runner.
+var runnerIdleMsg = "no runner provisioned, an idle runner is available"
+
+// idleRunnerClaims records the idle runners queued jobs were left to, so an
+// idle runner is only counted for one job while GitHub assigns it.
+type idleRunnerClaims struct {
+	mu     sync.Mutex
+	claims map[string]time.Time
+}
+
+func newIdleRunnerClaims() *idleRunnerClaims {
+	return &idleRunnerClaims{
+		claims: make(map[string]time.Time),
+	}
+}
+
+// claim claims the runner with the given key and reports whether it was not
+// claimed already. Expired claims are dropped.
+func (c *idleRunnerClaims) claim(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, at := range c.claims {
+		if now.Sub(at) >= idleRunnerClaimTTL {
+			delete(c.claims, k)
+		}
+	}
+	if _, ok := c.claims[key]; ok {
+		return false
+	}
+	c.claims[key] = now
+	return true
+}
+
+// claimIdleRunner returns the name of an online, idle runner of the
+// repository with all of the labels, claiming it for the queued job, or ""
+// when there is none. Runners of the organization are not considered.
+func (s *Server) claimIdleRunner(ctx context.Context, installationID int64, org, repo string, labels []string) (string, error) {
+	gh, err := s.installationClient(ctx, installationID, map[string]string{
+		"administration": "read",
+	}, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to setup installation client: %w", err)
+	}
+
+	opts := &github.ListRunnersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		lctx, cancel := callContext(ctx, s.githubCallTimeout)
+		runners, resp, err := gh.Actions.ListRunners(lctx, org, repo, opts)
+		cancel()
+		if err != nil {
+			return "", fmt.Errorf("failed to list runners: %w", err)
+		}
+
+		for _, r := range runners.Runners {
+			if r.GetStatus() != "online" || r.GetBusy() || !runnerHasLabels(r, labels) {
+				continue
+			}
+			if s.idleRunnerClaims.claim(fmt.Sprintf("%s/%s/%d", org, repo, r.GetID()), time.Now()) {
+				return r.GetName(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return "", nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// runnerHasLabels reports whether the runner has all of the labels, which
+// GitHub matches case-insensitively.
+func runnerHasLabels(r *github.Runner, labels []string) bool {
+	for _, label := range labels {
+		if !slices.ContainsFunc(r.Labels, func(l *github.RunnerLabels) bool {
+			return strings.EqualFold(l.GetName(), label)
+		}) {
+			return false
+		}
+	}
+	return true
+}
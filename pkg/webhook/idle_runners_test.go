@@ -0,0 +1,124 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/githubauth"
+)
+
+func TestClaimIdleRunner(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		runners string
+		labels  []string
+		want    []string
+	}{
+		{
+			name: "idle_runner",
+			runners: `[
+				{"id": 1, "name": "GCP-busy", "status": "online", "busy": true, "labels": [{"name": "self-hosted"}]},
+				{"id": 2, "name": "GCP-offline", "status": "offline", "busy": false, "labels": [{"name": "self-hosted"}]},
+				{"id": 3, "name": "GCP-idle", "status": "online", "busy": false, "labels": [{"name": "self-hosted"}, {"name": "Linux"}]}
+			]`,
+			labels: []string{"self-hosted", "linux"},
+			// The idle runner is only counted for one job.
+			want: []string{"GCP-idle", ""},
+		},
+		{
+			name: "missing_label",
+			runners: `[
+				{"id": 3, "name": "GCP-idle", "status": "online", "busy": false, "labels": [{"name": "self-hosted"}]}
+			]`,
+			labels: []string{"self-hosted", "profile=go"},
+			want:   []string{""},
+		},
+		{
+			name:    "no_runners",
+			runners: `[]`,
+			labels:  []string{"self-hosted"},
+			want:    []string{""},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+			}))
+			mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+			}))
+			mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"total_count": 3, "runners": %s}`, tc.runners)
+			}))
+			fakeGitHub := httptest.NewServer(mux)
+			t.Cleanup(fakeGitHub.Close)
+
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Server{
+				appClient:        app,
+				ghAPIBaseURL:     fakeGitHub.URL,
+				idleRunnerClaims: newIdleRunnerClaims(),
+			}
+			for _, want := range tc.want {
+				got, err := s.claimIdleRunner(t.Context(), 123, "google", "webhook", tc.labels)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIdleRunnerClaims(t *testing.T) {
+	t.Parallel()
+
+	c := newIdleRunnerClaims()
+	now := time.Now()
+	if !c.claim("google/webhook/1", now) {
+		t.Error("expected the first claim to succeed")
+	}
+	if c.claim("google/webhook/1", now.Add(time.Minute)) {
+		t.Error("expected the second claim to fail")
+	}
+	if !c.claim("google/webhook/1", now.Add(idleRunnerClaimTTL)) {
+		t.Error("expected the claim to succeed once the first one expired")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunnerImageMapping selects the runner image of jobs with all of its labels,
+// so teams can request their own runner image from the same webhook service.
+// Labels selecting an image, tag or repository explicitly take precedence.
+type RunnerImageMapping struct {
+	// Name identifies the mapping in logs.
+	Name string `yaml:"name"`
+
+	// Labels are the runner labels a job must all have, compared
+	// case-insensitively.
+	Labels []string `yaml:"labels"`
+
+	// Image is the runner image name.
+	Image string `yaml:"image"`
+
+	// Tag is the runner image tag, the default tag when unset. Pinning a tag
+	// opts the jobs out of canary rollouts.
+	Tag string `yaml:"tag"`
+
+	// Repository is the name of one of the RUNNER_REPOSITORIES the image is
+	// pulled from, the default repository when unset.
+	Repository string `yaml:"repository"`
+}
+
+// runnerImageMappingsFile is the format of the runner image mappings file.
+type runnerImageMappingsFile struct {
+	Mappings []*RunnerImageMapping `yaml:"mappings"`
+}
+
+// loadRunnerImageMappings reads and validates the runner image mappings file.
+// Mappings are evaluated in order and the first match applies.
+func loadRunnerImageMappings(fr FileReader, filename string, repositories map[string]string) ([]*RunnerImageMapping, error) {
+	b, err := fr.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runner image mappings: %w", err)
+	}
+
+	var f runnerImageMappingsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse runner image mappings: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(f.Mappings))
+	for i, m := range f.Mappings {
+		if m == nil || m.Name == "" {
+			return nil, fmt.Errorf("runner image mapping %d has no name", i)
+		}
+		if _, ok := seen[m.Name]; ok {
+			return nil, fmt.Errorf("duplicate runner image mapping %q", m.Name)
+		}
+		seen[m.Name] = struct{}{}
+
+		if len(m.Labels) == 0 {
+			return nil, fmt.Errorf("runner image mapping %q must match on labels", m.Name)
+		}
+		if m.Image == "" {
+			return nil, fmt.Errorf("runner image mapping %q must set image", m.Name)
+		}
+		if _, ok := repositories[m.Repository]; m.Repository != "" && !ok {
+			return nil, fmt.Errorf("runner image mapping %q: repository %q is not one of RUNNER_REPOSITORIES", m.Name, m.Repository)
+		}
+	}
+	return f.Mappings, nil
+}
+
+// matches reports whether the job has all the labels of the mapping.
+func (m *RunnerImageMapping) matches(labels []string) bool {
+	for _, want := range m.Labels {
+		if !slices.ContainsFunc(labels, func(got string) bool { return strings.EqualFold(got, want) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// runnerImageMapping returns the first image mapping matching the labels of
+// the job, or nil if none does.
+func (s *Server) runnerImageMapping(labels []string) *RunnerImageMapping {
+	for _, m := range s.runnerImageMappings {
+		if m.matches(labels) {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadRunnerImageMappings(t *testing.T) {
+	t.Parallel()
+
+	repositories := map[string]string{"mobile": "us-docker.pkg.dev/mobile/runners"}
+
+	cases := []struct {
+		name    string
+		content string
+		want    []*RunnerImageMapping
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+mappings:
+  - name: android
+    labels: [android, large]
+    image: android-runner
+    tag: v3
+    repository: mobile
+  - name: go
+    labels: [go]
+    image: go-runner
+`,
+			want: []*RunnerImageMapping{
+				{
+					Name:       "android",
+					Labels:     []string{"android", "large"},
+					Image:      "android-runner",
+					Tag:        "v3",
+					Repository: "mobile",
+				},
+				{
+					Name:   "go",
+					Labels: []string{"go"},
+					Image:  "go-runner",
+				},
+			},
+		},
+		{
+			name: "no_labels",
+			content: `
+mappings:
+  - name: android
+    image: android-runner
+`,
+			wantErr: "must match on labels",
+		},
+		{
+			name: "no_image",
+			content: `
+mappings:
+  - name: android
+    labels: [android]
+`,
+			wantErr: "must set image",
+		},
+		{
+			name: "unknown_repository",
+			content: `
+mappings:
+  - name: android
+    labels: [android]
+    image: android-runner
+    repository: missing
+`,
+			wantErr: `repository "missing" is not one of RUNNER_REPOSITORIES`,
+		},
+		{
+			name: "duplicate",
+			content: `
+mappings:
+  - name: android
+    labels: [android]
+    image: android-runner
+  - name: android
+    labels: [mobile]
+    image: android-runner
+`,
+			wantErr: "duplicate runner image mapping",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			got, err := loadRunnerImageMappings(fr, "mappings.yaml", repositories)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected mappings (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunnerImageMappingBuild(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		runnerImageMappings: []*RunnerImageMapping{
+			{Name: "android", Labels: []string{"android", "large"}, Image: "android-runner", Tag: "v3", Repository: "mobile"},
+			{Name: "go", Labels: []string{"go"}, Image: "go-runner"},
+		},
+		runnerImageName:    "default-runner",
+		runnerImageTag:     "latest",
+		runnerRepositories: map[string]string{"mobile": "us-docker.pkg.dev/mobile/runners"},
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+	}
+
+	cases := []struct {
+		name   string
+		labels []string
+		want   string
+	}{
+		{
+			name:   "no_mapping",
+			labels: []string{"self-hosted"},
+			want:   "us-docker.pkg.dev/p/runners/default-runner:latest",
+		},
+		{
+			name:   "all_labels",
+			labels: []string{"self-hosted", "Android", "large"},
+			want:   "us-docker.pkg.dev/mobile/runners/android-runner:v3",
+		},
+		{
+			name:   "some_labels",
+			labels: []string{"self-hosted", "android"},
+			want:   "us-docker.pkg.dev/p/runners/default-runner:latest",
+		},
+		{
+			name:   "image_only",
+			labels: []string{"self-hosted", "go"},
+			want:   "us-docker.pkg.dev/p/runners/go-runner:latest",
+		},
+		{
+			name:   "image_label",
+			labels: []string{"self-hosted", "go", "image=default-runner"},
+			want:   "us-docker.pkg.dev/p/runners/default-runner:latest",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			build := srv.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2", Labels: tc.labels}, "encoded-jit-config")
+			spec := &RunnerSpec{Build: build}
+			if got, want := spec.Image(), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
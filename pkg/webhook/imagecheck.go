@@ -0,0 +1,162 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// imageCheckTimeout bounds the lookup of a runner image, further bounded by
+// the delivery budget.
+const imageCheckTimeout = 3 * time.Second
+
+var (
+	runnerImageMissingMsg   = "no action taken for runner image missing from artifact registry"
+	runnerImageUntrustedMsg = "no action taken for runner image without a verified attestation"
+)
+
+// imageLookup is what is known of a tagged runner image.
+type imageLookup struct {
+	// digest is the digest the tag resolves to, "" if it does not exist.
+	digest string
+
+	// verified is whether the image attestor verified an attestation of the
+	// digest.
+	verified bool
+}
+
+// imageLookupCache caches the lookups of tagged images, so runners are not
+// each delayed by them. Lookups are kept for ttl, which bounds how long a
+// newly pushed or retagged image keeps its previous lookup.
+type imageLookupCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	lookups map[string]*cachedImageLookup
+}
+
+type cachedImageLookup struct {
+	lookup    *imageLookup
+	expiresAt time.Time
+}
+
+func newImageLookupCache(ttl time.Duration) *imageLookupCache {
+	return &imageLookupCache{
+		ttl:     ttl,
+		lookups: make(map[string]*cachedImageLookup),
+	}
+}
+
+func (c *imageLookupCache) get(image string, now time.Time) (*imageLookup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.lookups[image]
+	if !ok || !now.Before(cached.expiresAt) {
+		delete(c.lookups, image)
+		return nil, false
+	}
+	return cached.lookup, true
+}
+
+func (c *imageLookupCache) add(image string, lookup *imageLookup, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lookups[image] = &cachedImageLookup{lookup: lookup, expiresAt: now.Add(c.ttl)}
+}
+
+// lookupImage resolves the image to its digest, and verifies the digest when
+// an image attestor is configured.
+func (s *Server) lookupImage(ctx context.Context, image string) (*imageLookup, error) {
+	now := time.Now()
+	if lookup, ok := s.imageLookups.get(image, now); ok {
+		return lookup, nil
+	}
+
+	lctx, cancel := callContext(ctx, imageCheckTimeout)
+	defer cancel()
+
+	digest, err := s.imageRegistry.ImageDigest(lctx, image)
+	if err != nil {
+		return nil, err
+	}
+	lookup := &imageLookup{digest: digest}
+	if digest != "" && s.imageAttestor != "" {
+		lookup.verified, err = s.imageVerifier.VerifyImage(lctx, s.imageAttestor, image, digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.imageLookups.add(image, lookup, now)
+	return lookup, nil
+}
+
+// checkRunnerImage returns the digest to pin the runner image to, "" to keep
+// its tag, or the response refusing the runner. Images that do not exist
+// are refused, as the build would only fail to pull them. Without an image
+// attestor, images outside Artifact Registry and images whose lookup fails
+// are allowed unpinned, so an Artifact Registry outage does not stop the
+// fleet. With one, only images with a verified attestation are allowed.
+func (s *Server) checkRunnerImage(ctx context.Context, image string, logFields []any) (string, *apiResponse) {
+	if s.imageRegistry == nil {
+		return "", nil
+	}
+	logger := logging.FromContext(ctx)
+	logFields = append(logFields, "image", image)
+
+	untrusted := func(msg string, fields ...any) (string, *apiResponse) {
+		s.metrics.recordUntrustedImage(image)
+		logger.ErrorContext(ctx, msg, append(logFields, fields...)...)
+		return "", &apiResponse{http.StatusOK, runnerImageUntrustedMsg, nil}
+	}
+
+	if _, err := parseArtifactRegistryImage(image); err != nil {
+		if s.imageAttestor != "" {
+			return untrusted("refusing runner image outside artifact registry, its attestations cannot be verified")
+		}
+		return "", nil
+	}
+
+	lookup, err := s.lookupImage(ctx, image)
+	if err != nil {
+		if s.imageAttestor != "" {
+			return untrusted("failed to verify runner image, refusing image", "error", err)
+		}
+		logger.WarnContext(ctx, "failed to look up runner image, allowing image", append(logFields, "error", err)...)
+		return "", nil
+	}
+
+	if lookup.digest == "" {
+		s.metrics.recordMissingImage(image)
+		logger.ErrorContext(ctx, "refusing runner image missing from artifact registry", logFields...)
+		return "", &apiResponse{http.StatusOK, runnerImageMissingMsg, nil}
+	}
+	if s.imageAttestor != "" && !lookup.verified {
+		return untrusted("refusing runner image without a verified attestation",
+			"image_digest", lookup.digest,
+			"attestor", s.imageAttestor)
+	}
+
+	if !s.runnerImagePinDigest {
+		return "", nil
+	}
+	return lookup.digest, nil
+}
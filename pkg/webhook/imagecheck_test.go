@@ -0,0 +1,194 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckRunnerImage(t *testing.T) {
+	t.Parallel()
+
+	const (
+		image    = "us-docker.pkg.dev/my-project/runners/default-runner:latest"
+		digest   = "sha256:abc"
+		attestor = "projects/my-project/attestors/runner-images"
+	)
+
+	cases := []struct {
+		name         string
+		image        string
+		registry     *MockImageRegistry
+		verifier     *MockImageVerifier
+		pinDigest    bool
+		wantDigest   string
+		wantMsg      string
+		wantLookups  []string
+		wantVerifies []string
+	}{
+		{
+			name:  "disabled",
+			image: image,
+		},
+		{
+			name:        "exists",
+			image:       image,
+			registry:    &MockImageRegistry{Digests: map[string]string{image: digest}},
+			wantLookups: []string{image},
+		},
+		{
+			name:        "pin_digest",
+			image:       image,
+			registry:    &MockImageRegistry{Digests: map[string]string{image: digest}},
+			pinDigest:   true,
+			wantDigest:  digest,
+			wantLookups: []string{image},
+		},
+		{
+			name:        "missing",
+			image:       image,
+			registry:    &MockImageRegistry{},
+			wantMsg:     runnerImageMissingMsg,
+			wantLookups: []string{image},
+		},
+		{
+			name:        "lookup_error",
+			image:       image,
+			registry:    &MockImageRegistry{Err: fmt.Errorf("permission denied")},
+			pinDigest:   true,
+			wantLookups: []string{image},
+		},
+		{
+			name:     "other_registry",
+			image:    "ghcr.io/google/runner:latest",
+			registry: &MockImageRegistry{},
+		},
+		{
+			name:         "verified",
+			image:        image,
+			registry:     &MockImageRegistry{Digests: map[string]string{image: digest}},
+			verifier:     &MockImageVerifier{Verified: map[string]bool{digest: true}},
+			pinDigest:    true,
+			wantDigest:   digest,
+			wantLookups:  []string{image},
+			wantVerifies: []string{digest},
+		},
+		{
+			name:         "not_verified",
+			image:        image,
+			registry:     &MockImageRegistry{Digests: map[string]string{image: digest}},
+			verifier:     &MockImageVerifier{},
+			wantMsg:      runnerImageUntrustedMsg,
+			wantLookups:  []string{image},
+			wantVerifies: []string{digest},
+		},
+		{
+			name:         "verify_error",
+			image:        image,
+			registry:     &MockImageRegistry{Digests: map[string]string{image: digest}},
+			verifier:     &MockImageVerifier{Err: fmt.Errorf("permission denied")},
+			wantMsg:      runnerImageUntrustedMsg,
+			wantLookups:  []string{image},
+			wantVerifies: []string{digest},
+		},
+		{
+			name:        "verify_lookup_error",
+			image:       image,
+			registry:    &MockImageRegistry{Err: fmt.Errorf("permission denied")},
+			verifier:    &MockImageVerifier{},
+			wantMsg:     runnerImageUntrustedMsg,
+			wantLookups: []string{image},
+		},
+		{
+			name:     "verify_other_registry",
+			image:    "ghcr.io/google/runner:latest",
+			registry: &MockImageRegistry{},
+			verifier: &MockImageVerifier{},
+			wantMsg:  runnerImageUntrustedMsg,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerImagePinDigest: tc.pinDigest}
+			if tc.registry != nil {
+				s.imageRegistry = tc.registry
+				s.imageLookups = newImageLookupCache(time.Minute)
+			}
+			if tc.verifier != nil {
+				s.imageAttestor = attestor
+				s.imageVerifier = tc.verifier
+			}
+
+			gotDigest, resp := s.checkRunnerImage(t.Context(), tc.image, nil)
+			if got, want := gotDigest, tc.wantDigest; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			var gotMsg string
+			if resp != nil {
+				gotMsg = resp.Message
+			}
+			if got, want := gotMsg, tc.wantMsg; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if tc.registry != nil {
+				if diff := cmp.Diff(tc.wantLookups, tc.registry.Lookups); diff != "" {
+					t.Errorf("unexpected looked up images (-want, +got):\n%s", diff)
+				}
+			}
+			if tc.verifier != nil {
+				if diff := cmp.Diff(tc.wantVerifies, tc.verifier.Verifies); diff != "" {
+					t.Errorf("unexpected verified digests (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRunnerImage_cached(t *testing.T) {
+	t.Parallel()
+
+	const image = "us-docker.pkg.dev/my-project/runners/default-runner:latest"
+	registry := &MockImageRegistry{}
+	s := &Server{
+		imageRegistry: registry,
+		imageLookups:  newImageLookupCache(time.Minute),
+	}
+
+	for range 2 {
+		if _, resp := s.checkRunnerImage(t.Context(), image, nil); resp == nil {
+			t.Errorf("expected the image to be refused")
+		}
+	}
+	if diff := cmp.Diff([]string{image}, registry.Lookups); diff != "" {
+		t.Errorf("unexpected looked up images (-want, +got):\n%s", diff)
+	}
+
+	// Expired lookups are looked up again, a pushed image is then allowed.
+	registry.Digests = map[string]string{image: "sha256:abc"}
+	s.imageLookups.add(image, &imageLookup{}, time.Now().Add(-time.Minute))
+	if _, resp := s.checkRunnerImage(t.Context(), image, nil); resp != nil {
+		t.Errorf("expected the image to be allowed")
+	}
+	if got, want := len(registry.Lookups), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"slices"
+)
+
+const imageLabelPrefix = "image="
+
+// runnerImage returns the runner image name selected by the image= label of
+// the job, falling back to the image of the matching image mapping, to the
+// arm64 image for arm64 jobs, to the GPU image for jobs requesting a GPU and
+// to the default image otherwise. It returns false if the label selects an
+// image that is not one of the configured variants.
+func (s *Server) runnerImage(labels []string) (string, bool) {
+	v, ok := labelValue(labels, imageLabelPrefix)
+	if !ok {
+		if m := s.runnerImageMapping(labels); m != nil {
+			return m.Image, true
+		}
+		if runnerArch(labels) == runnerArchARM64 && s.runnerARM64ImageName != "" {
+			return s.runnerARM64ImageName, true
+		}
+		if _, gpu := labelValue(labels, gpuLabelPrefix); gpu && len(s.runnerGPUTypes) > 0 {
+			return s.runnerGPUImageName, true
+		}
+		return s.runnerImageName, true
+	}
+	gpuImage := len(s.runnerGPUTypes) > 0 && v == s.runnerGPUImageName
+	arm64Image := s.runnerARM64ImageName != "" && v == s.runnerARM64ImageName
+	return v, v == s.runnerImageName || gpuImage || arm64Image || slices.Contains(s.runnerImageVariants, v)
+}
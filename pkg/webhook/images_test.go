@@ -0,0 +1,113 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerImage(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		runnerARM64ImageName: "arm64-runner",
+		runnerGPUImageName:   "gpu-runner",
+		runnerGPUTypes:       map[string]string{"t4": "nvidia-tesla-t4"},
+		runnerImageName:      "default-runner",
+		runnerImageVariants:  []string{"ubuntu-22", "ubuntu-24"},
+	}
+
+	cases := []struct {
+		name      string
+		labels    []string
+		wantImage string
+		wantOK    bool
+	}{
+		{
+			name:      "no_label",
+			labels:    []string{"self-hosted"},
+			wantImage: "default-runner",
+			wantOK:    true,
+		},
+		{
+			name:      "variant",
+			labels:    []string{"self-hosted", "image=ubuntu-24"},
+			wantImage: "ubuntu-24",
+			wantOK:    true,
+		},
+		{
+			name:      "default_by_name",
+			labels:    []string{"self-hosted", "image=default-runner"},
+			wantImage: "default-runner",
+			wantOK:    true,
+		},
+		{
+			name:      "gpu",
+			labels:    []string{"self-hosted", "gcp-gpu-t4"},
+			wantImage: "gpu-runner",
+			wantOK:    true,
+		},
+		{
+			name:      "gpu_variant",
+			labels:    []string{"self-hosted", "gcp-gpu-t4", "image=ubuntu-24"},
+			wantImage: "ubuntu-24",
+			wantOK:    true,
+		},
+		{
+			name:      "arm64",
+			labels:    []string{"self-hosted", "arm64"},
+			wantImage: "arm64-runner",
+			wantOK:    true,
+		},
+		{
+			name:      "not_allowed",
+			labels:    []string{"self-hosted", "image=../attacker/image"},
+			wantImage: "../attacker/image",
+			wantOK:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			image, ok := srv.runnerImage(tc.labels)
+			if got, want := image, tc.wantImage; got != want {
+				t.Errorf("expected image %q to be %q", got, want)
+			}
+			if got, want := ok, tc.wantOK; got != want {
+				t.Errorf("expected ok %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestRunnerLabels(t *testing.T) {
+	t.Parallel()
+
+	got := runnerLabels([]string{"self-hosted", "linux", "image=ubuntu-24"})
+	want := []string{"self-hosted", "Linux", "X64", "image=ubuntu-24"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected labels (-want, +got):\n%s", diff)
+	}
+
+	got = runnerLabels([]string{"self-hosted", "linux", "ARM64"})
+	want = []string{"self-hosted", "Linux", "ARM64"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected arm64 labels (-want, +got):\n%s", diff)
+	}
+}
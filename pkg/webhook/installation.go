@@ -0,0 +1,179 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// installationRepoTTL is how long a verified repository to installation
+// mapping is trusted before it is checked again.
+const installationRepoTTL = 10 * time.Minute
+
+var (
+	// errRepoNotInInstallation is returned when the repository of an event is
+	// not part of the installation the event claims to come from.
+	errRepoNotInInstallation = errors.New("repository does not belong to installation")
+
+	// errRunnerTargetGone is returned when GitHub reports that the repository
+	// of an event was deleted, has Actions disabled or no longer has the app
+	// installed. Redelivering the event cannot succeed.
+	errRunnerTargetGone = errors.New("repository or installation is gone")
+
+	// errRunnerExists is returned when a runner with the name of a runner being
+	// dispatched is already registered.
+	errRunnerExists = errors.New("runner already exists")
+)
+
+// installationRepoCache remembers repositories recently verified to belong to
+// an installation. A nil cache is valid and caches nothing.
+type installationRepoCache struct {
+	mu       sync.Mutex
+	verified map[string]time.Time
+}
+
+func newInstallationRepoCache() *installationRepoCache {
+	return &installationRepoCache{
+		verified: make(map[string]time.Time),
+	}
+}
+
+func (c *installationRepoCache) has(key string, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.verified[key]
+	if !ok {
+		return false
+	}
+	if now.After(expires) {
+		delete(c.verified, key)
+		return false
+	}
+	return true
+}
+
+func (c *installationRepoCache) add(key string, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.verified[key] = now.Add(installationRepoTTL)
+}
+
+// forget drops the repositories verified to belong to the installation.
+func (c *installationRepoCache) forget(installationID int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := fmt.Sprintf("%d/", installationID)
+	for key := range c.verified {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.verified, key)
+		}
+	}
+}
+
+// verifyInstallationRepo confirms with GitHub, authenticated as the app, that
+// the repository is part of the installation. Deliveries are signed with a
+// single secret shared by all installations, so the installation and
+// repository fields of a payload cannot be trusted to belong together.
+func (s *Server) verifyInstallationRepo(ctx context.Context, installationID int64, org, repo string) error {
+	key := fmt.Sprintf("%d/%s/%s", installationID, strings.ToLower(org), strings.ToLower(repo))
+	now := time.Now()
+	if s.suspendedInstallations.has(installationID, now) {
+		return fmt.Errorf("%w: installation %d", errInstallationSuspended, installationID)
+	}
+	if s.installationRepos.has(key, now) {
+		return nil
+	}
+
+	gh, err := s.appGitHubClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	installation, _, err := gh.Apps.FindRepositoryInstallation(ctx, org, repo)
+	if err != nil {
+		if isGoneResponse(err) {
+			return fmt.Errorf("%w: app is not installed on %s/%s: %w", errRunnerTargetGone, org, repo, err)
+		}
+		return fmt.Errorf("failed to get installation for repository: %w", err)
+	}
+
+	if got := installation.GetID(); got != installationID {
+		return fmt.Errorf("%w: %s/%s belongs to installation %d, not %d", errRepoNotInInstallation, org, repo, got, installationID)
+	}
+
+	// Suspension events only reach one instance, the others learn about it
+	// here.
+	if installation.SuspendedAt != nil {
+		s.suspendedInstallations.suspend(installationID, now)
+		return fmt.Errorf("%w: installation %d was suspended at %s", errInstallationSuspended, installationID, installation.GetSuspendedAt())
+	}
+
+	s.installationRepos.add(key, now)
+	return nil
+}
+
+// appGitHubClient returns a GitHub client authenticated as the app itself.
+func (s *Server) appGitHubClient(ctx context.Context) (*github.Client, error) {
+	gh := github.NewClient(s.githubHTTPClient(s.appClient.OAuthAppTokenSource()))
+	baseURL, err := url.Parse(fmt.Sprintf("%s/", s.ghAPIBaseURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set github base URL: %w", err)
+	}
+	gh.BaseURL = baseURL
+	gh.UploadURL = baseURL
+	return gh, nil
+}
+
+// isGoneResponse reports whether a GitHub API error is a 403 or 404 that is not
+// caused by rate limiting. GitHub answers both when a repository was deleted,
+// Actions is disabled on it, or the app was uninstalled.
+func isGoneResponse(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return false
+	}
+
+	var ghErr *github.ErrorResponse
+	if !errors.As(err, &ghErr) || ghErr.Response == nil {
+		return false
+	}
+	code := ghErr.Response.StatusCode
+	return code == http.StatusForbidden || code == http.StatusNotFound
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+)
+
+// jitConfigEnv is the environment variable the runner reads its JIT
+// configuration from.
+const jitConfigEnv = "ENCODED_JIT_CONFIG"
+
+// SecretStore adheres to the interaction the webhook service has with Secret
+// Manager to hand runners their JIT configuration.
+type SecretStore interface {
+	CreateSecret(ctx context.Context, projectID, secretID string, payload []byte, ttl time.Duration, labels map[string]string, accessors []string) (string, error)
+	DeleteSecret(ctx context.Context, name string) error
+}
+
+// secretIDInvalidRegexp matches the characters secret IDs cannot contain.
+var secretIDInvalidRegexp = regexp.MustCompile(`[^\w-]+`)
+
+// jitConfigSecretID returns the ID of the secret holding the JIT configuration
+// of the runner.
+func jitConfigSecretID(runnerName string) string {
+	id := "jit-config-" + secretIDInvalidRegexp.ReplaceAllString(runnerName, "_")
+	if len(id) > 255 {
+		id = id[:255]
+	}
+	return id
+}
+
+// jitConfigSecretAccessors returns the IAM members granted access to the JIT
+// configuration secret of the runner build: the service account of the build
+// and, when set, the service account of Batch runners. They get access to that
+// one secret only, as they also run the jobs.
+func (s *Server) jitConfigSecretAccessors(build *cloudbuildpb.Build) []string {
+	var accessors []string
+	for _, sa := range []string{build.GetServiceAccount(), s.runnerBatchServiceAccount} {
+		if sa == "" {
+			continue
+		}
+		// Service accounts are either emails or of the form
+		// projects/<project>/serviceAccounts/<email>.
+		member := "serviceAccount:" + sa[strings.LastIndex(sa, "/")+1:]
+		if !slices.Contains(accessors, member) {
+			accessors = append(accessors, member)
+		}
+	}
+	return accessors
+}
+
+// moveJITConfigToSecret moves the JIT configuration of the runner build from
+// its substitutions, which anyone who can view the build can read, to a
+// secret. Cloud Build resolves the secret into the environment of the runner
+// step when the build starts, and the webhook service deletes it once the job
+// of the runner is in progress or completed. The secret expires after
+// jitConfigSecretTTL in case the job never gets there.
+//
+// It returns the name of the secret, "" if JIT configuration secrets are
+// disabled.
+func (s *Server) moveJITConfigToSecret(ctx context.Context, build *cloudbuildpb.Build, runnerName string) (string, error) {
+	if s.secrets == nil {
+		return "", nil
+	}
+
+	i := slices.IndexFunc(build.GetSteps(), func(step *cloudbuildpb.BuildStep) bool {
+		return step.GetId() == "run"
+	})
+	if i < 0 {
+		return "", fmt.Errorf("build has no run step")
+	}
+
+	secretID := jitConfigSecretID(runnerName)
+	version, err := s.secrets.CreateSecret(ctx, s.runnerProjectID, secretID,
+		[]byte(build.GetSubstitutions()["_ENCODED_JIT_CONFIG"]), s.jitConfigSecretTTL,
+		map[string]string{"managed-by": "github-actions-on-gcp"}, s.jitConfigSecretAccessors(build))
+	if err != nil {
+		return "", fmt.Errorf("failed to store jit config: %w", err)
+	}
+	secretName, _, _ := strings.Cut(version, "/versions/")
+
+	delete(build.Substitutions, "_ENCODED_JIT_CONFIG")
+	if build.AvailableSecrets == nil {
+		build.AvailableSecrets = &cloudbuildpb.Secrets{}
+	}
+	build.AvailableSecrets.SecretManager = append(build.AvailableSecrets.SecretManager,
+		&cloudbuildpb.SecretManagerSecret{VersionName: version, Env: jitConfigEnv})
+
+	run := build.GetSteps()[i]
+	run.Env = slices.DeleteFunc(run.Env, func(env string) bool {
+		return strings.HasPrefix(env, jitConfigEnv+"=")
+	})
+	run.SecretEnv = append(run.SecretEnv, jitConfigEnv)
+	return secretName, nil
+}
+
+// deleteJITConfigSecret deletes the JIT configuration secret of the runner,
+// which it no longer needs once it picked up a job. The secret expires anyway,
+// so failures are only logged.
+func (s *Server) deleteJITConfigSecret(ctx context.Context, runnerName string) {
+	if s.secrets == nil || runnerName == "" {
+		return
+	}
+
+	name := fmt.Sprintf("projects/%s/secrets/%s", s.runnerProjectID, jitConfigSecretID(runnerName))
+	cctx, cancel := callContext(ctx, 0)
+	defer cancel()
+	if err := s.secrets.DeleteSecret(cctx, name); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to delete JIT config secret",
+			"runner_name", runnerName, "secret", name, "error", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMoveJITConfigToSecret(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		secrets        *MockSecretStore
+		wantSecretName string
+		wantSteps      []string
+		wantEnv        []string
+		wantSecretEnv  []string
+		wantErr        string
+	}{
+		{
+			name:      "disabled",
+			wantSteps: []string{"run"},
+			wantEnv:   []string{"ENCODED_JIT_CONFIG=$_ENCODED_JIT_CONFIG"},
+		},
+		{
+			name:           "secret",
+			secrets:        &MockSecretStore{},
+			wantSecretName: "projects/runner-project/secrets/jit-config-GCP-2",
+			wantSteps:      []string{"run"},
+			wantEnv:        []string{},
+			wantSecretEnv:  []string{"ENCODED_JIT_CONFIG"},
+		},
+		{
+			name:      "create_error",
+			secrets:   &MockSecretStore{CreateErr: fmt.Errorf("permission denied")},
+			wantSteps: []string{"run"},
+			wantEnv:   []string{"ENCODED_JIT_CONFIG=$_ENCODED_JIT_CONFIG"},
+			wantErr:   "failed to store jit config: permission denied",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				jitConfigSecretTTL:   time.Hour,
+				runnerProjectID:      "runner-project",
+				runnerServiceAccount: "projects/runner-project/serviceAccounts/runner@runner-project.iam.gserviceaccount.com",
+			}
+			if tc.secrets != nil {
+				s.secrets = tc.secrets
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+			secretName, err := s.moveJITConfigToSecret(t.Context(), build, "GCP-2")
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := secretName, tc.wantSecretName; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			var gotSteps []string
+			for _, step := range build.GetSteps() {
+				gotSteps = append(gotSteps, step.GetId())
+			}
+			if diff := cmp.Diff(tc.wantSteps, gotSteps); diff != "" {
+				t.Errorf("unexpected steps (-want, +got):\n%s", diff)
+			}
+
+			run := build.GetSteps()[len(build.GetSteps())-1]
+			if diff := cmp.Diff(tc.wantEnv, run.GetEnv()); diff != "" {
+				t.Errorf("unexpected env (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantSecretEnv, run.GetSecretEnv()); diff != "" {
+				t.Errorf("unexpected secret env (-want, +got):\n%s", diff)
+			}
+
+			if tc.wantSecretName == "" {
+				if got, want := build.GetSubstitutions()["_ENCODED_JIT_CONFIG"], "encoded-jit-config"; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+				return
+			}
+
+			if _, ok := build.GetSubstitutions()["_ENCODED_JIT_CONFIG"]; ok {
+				t.Errorf("expected the JIT config substitution to be removed")
+			}
+			if got, want := string(tc.secrets.Secrets[tc.wantSecretName]), "encoded-jit-config"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			secrets := build.GetAvailableSecrets().GetSecretManager()
+			if got, want := len(secrets), 1; got != want {
+				t.Fatalf("expected %d to be %d", got, want)
+			}
+			if got, want := secrets[0].GetVersionName(), tc.wantSecretName+"/versions/1"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := secrets[0].GetEnv(), "ENCODED_JIT_CONFIG"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			wantAccessors := []string{"serviceAccount:runner@runner-project.iam.gserviceaccount.com"}
+			if diff := cmp.Diff(wantAccessors, tc.secrets.Accessors[tc.wantSecretName]); diff != "" {
+				t.Errorf("unexpected accessors (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJITConfigSecretAccessors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name                string
+		buildSA             string
+		batchServiceAccount string
+		want                []string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:    "email",
+			buildSA: "runner@p.iam.gserviceaccount.com",
+			want:    []string{"serviceAccount:runner@p.iam.gserviceaccount.com"},
+		},
+		{
+			name:                "batch",
+			buildSA:             "projects/p/serviceAccounts/runner@p.iam.gserviceaccount.com",
+			batchServiceAccount: "batch@p.iam.gserviceaccount.com",
+			want: []string{
+				"serviceAccount:runner@p.iam.gserviceaccount.com",
+				"serviceAccount:batch@p.iam.gserviceaccount.com",
+			},
+		},
+		{
+			name:                "same",
+			buildSA:             "projects/p/serviceAccounts/runner@p.iam.gserviceaccount.com",
+			batchServiceAccount: "runner@p.iam.gserviceaccount.com",
+			want:                []string{"serviceAccount:runner@p.iam.gserviceaccount.com"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerBatchServiceAccount: tc.batchServiceAccount}
+			got := s.jitConfigSecretAccessors(&cloudbuildpb.Build{ServiceAccount: tc.buildSA})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected accessors (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDeleteJITConfigSecret(t *testing.T) {
+	t.Parallel()
+
+	secrets := &MockSecretStore{}
+	s := &Server{
+		jitConfigSecretTTL: time.Hour,
+		runnerProjectID:    "runner-project",
+		secrets:            secrets,
+	}
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+	if _, err := s.moveJITConfigToSecret(t.Context(), build, "GCP-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.deleteJITConfigSecret(t.Context(), "GCP-2")
+
+	if diff := cmp.Diff([]string{"projects/runner-project/secrets/jit-config-GCP-2"}, secrets.Deleted); diff != "" {
+		t.Errorf("unexpected deleted secrets (-want, +got):\n%s", diff)
+	}
+	if got := len(secrets.Secrets); got != 0 {
+		t.Errorf("expected no secrets left, got %d", got)
+	}
+}
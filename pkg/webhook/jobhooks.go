@@ -0,0 +1,69 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+// maxInlineHookSize bounds the job hook scripts passed to the runner in the
+// build, larger scripts can be read by the runner from Cloud Storage.
+const maxInlineHookSize = 16 * 1024
+
+// loadJobHook returns how a job hook is passed to the runner. Hooks in Cloud
+// Storage, in the form gs://<bucket>/<object>, are passed as is and downloaded
+// by the runner when it starts. Other hooks are paths to a local script, for
+// example a mounted secret, passed inline and base64 encoded.
+func loadJobHook(fr FileReader, location string) (string, error) {
+	if location == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(location, "gs://") {
+		if _, _, err := parseGCSObject(location); err != nil {
+			return "", err
+		}
+		// The location is passed to the runner in its shell command.
+		if strings.ContainsAny(location, " \t\n'\"$`\\") {
+			return "", fmt.Errorf("%q must not contain whitespace, quotes, $, ` or \\", location)
+		}
+		return location, nil
+	}
+
+	script, err := fr.ReadFile(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	if len(script) > maxInlineHookSize {
+		return "", fmt.Errorf("%s is larger than %d bytes, store it in Cloud Storage instead", location, maxInlineHookSize)
+	}
+	return base64.StdEncoding.EncodeToString(script), nil
+}
+
+// addJobHooks passes the job started and job completed hooks to the runner,
+// which sets them as ACTIONS_RUNNER_HOOK_JOB_STARTED and
+// ACTIONS_RUNNER_HOOK_JOB_COMPLETED. Hooks in Cloud Storage are downloaded
+// with the credentials of the runner service account, from the metadata
+// server of the cloudbuild network.
+func (s *Server) addJobHooks(build *cloudbuildpb.Build) {
+	build.Substitutions["_JOB_STARTED_HOOK"] = s.jobStartedHook
+	build.Substitutions["_JOB_COMPLETED_HOOK"] = s.jobCompletedHook
+	if strings.HasPrefix(s.jobStartedHook, "gs://") || strings.HasPrefix(s.jobCompletedHook, "gs://") {
+		build.Substitutions["_DOCKER_NETWORK"] = "cloudbuild"
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestLoadJobHook(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		location string
+		file     *ReadFileResErr
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:     "cloud_storage",
+			location: "gs://runner-hooks/hooks/job-started.sh",
+			want:     "gs://runner-hooks/hooks/job-started.sh",
+		},
+		{
+			name:     "cloud_storage_without_object",
+			location: "gs://runner-hooks",
+			wantErr:  `"gs://runner-hooks" must be in the form gs://<bucket>/<object>`,
+		},
+		{
+			name:     "cloud_storage_shell_characters",
+			location: "gs://runner-hooks/$(reboot).sh",
+			wantErr:  "must not contain whitespace",
+		},
+		{
+			name:     "local_script",
+			location: "/hooks/job-started.sh",
+			file:     &ReadFileResErr{Res: []byte("#!/bin/bash\nrm -rf ~/.config/gcloud\n")},
+			want:     base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\nrm -rf ~/.config/gcloud\n")),
+		},
+		{
+			name:     "local_script_too_large",
+			location: "/hooks/job-started.sh",
+			file:     &ReadFileResErr{Res: []byte(strings.Repeat("#", maxInlineHookSize+1))},
+			wantErr:  "/hooks/job-started.sh is larger than 16384 bytes, store it in Cloud Storage instead",
+		},
+		{
+			name:     "local_script_missing",
+			location: "/hooks/job-started.sh",
+			file:     &ReadFileResErr{Err: fmt.Errorf("no such file or directory")},
+			wantErr:  "failed to read /hooks/job-started.sh: no such file or directory",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := loadJobHook(&MockFileReader{ReadFileMock: tc.file}, tc.location)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddJobHooks(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		started     string
+		completed   string
+		wantNetwork string
+	}{
+		{
+			name:        "no_hooks",
+			wantNetwork: "bridge",
+		},
+		{
+			name:        "inline",
+			started:     "IyEvYmluL2Jhc2g=",
+			wantNetwork: "bridge",
+		},
+		{
+			name:        "cloud_storage",
+			started:     "IyEvYmluL2Jhc2g=",
+			completed:   "gs://runner-hooks/job-completed.sh",
+			wantNetwork: "cloudbuild",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				jobCompletedHook: tc.completed,
+				jobStartedHook:   tc.started,
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+			if got, want := build.GetSubstitutions()["_JOB_STARTED_HOOK"], tc.started; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := build.GetSubstitutions()["_JOB_COMPLETED_HOOK"], tc.completed; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := build.GetSubstitutions()["_DOCKER_NETWORK"], tc.wantNetwork; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
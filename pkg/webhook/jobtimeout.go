@@ -0,0 +1,115 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// maxBuildTimeout is the longest timeout Cloud Build accepts for a build.
+const maxBuildTimeout = 24 * time.Hour
+
+// workflowFile is the subset of a workflow definition needed to find the
+// timeout of its jobs.
+type workflowFile struct {
+	Jobs map[string]*workflowFileJob `yaml:"jobs"`
+}
+
+type workflowFileJob struct {
+	Name           string `yaml:"name"`
+	TimeoutMinutes string `yaml:"timeout-minutes"`
+}
+
+// jobTimeout looks up the timeout-minutes of the job in the workflow file at
+// the commit the run was triggered for. It returns false when the job does not
+// declare a static timeout.
+func (s *Server) jobTimeout(ctx context.Context, event *github.WorkflowJobEvent) (time.Duration, bool, error) {
+	org := event.GetOrg().GetLogin()
+	repo := event.GetRepo().GetName()
+
+	gh, err := s.installationClient(ctx, event.GetInstallation().GetID(), map[string]string{
+		"actions":  "read",
+		"contents": "read",
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to setup installation client: %w", err)
+	}
+
+	run, _, err := gh.Actions.GetWorkflowRunByID(ctx, org, repo, event.GetWorkflowJob().GetRunID())
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get workflow run: %w", err)
+	}
+
+	file, _, _, err := gh.Repositories.GetContents(ctx, org, repo, run.GetPath(), &github.RepositoryContentGetOptions{
+		Ref: run.GetHeadSHA(),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get workflow file %q: %w", run.GetPath(), err)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to decode workflow file %q: %w", run.GetPath(), err)
+	}
+
+	return parseJobTimeout([]byte(content), event.GetWorkflowJob().GetName())
+}
+
+// parseJobTimeout returns the timeout-minutes of the named job in the workflow
+// definition. Matrix jobs are reported by GitHub as "name (values...)", so
+// they are matched on the part before the matrix values.
+func parseJobTimeout(content []byte, jobName string) (time.Duration, bool, error) {
+	var wf workflowFile
+	if err := yaml.Unmarshal(content, &wf); err != nil {
+		return 0, false, fmt.Errorf("failed to parse workflow file: %w", err)
+	}
+
+	baseName, _, _ := strings.Cut(jobName, " (")
+	for key, job := range wf.Jobs {
+		if job == nil {
+			continue
+		}
+
+		name := job.Name
+		if name == "" {
+			name = key
+		}
+		if name != jobName && name != baseName && key != jobName {
+			continue
+		}
+
+		// Expressions can only be evaluated by GitHub, treat them as unknown.
+		minutes, err := strconv.ParseFloat(job.TimeoutMinutes, 64)
+		if err != nil || minutes <= 0 {
+			return 0, false, nil
+		}
+		return time.Duration(minutes * float64(time.Minute)), true, nil
+	}
+	return 0, false, nil
+}
+
+// buildTimeout returns the build timeout for a job timeout, leaving room for
+// the runner to start and capped to the Cloud Build maximum.
+func buildTimeout(jobTimeout, margin time.Duration) time.Duration {
+	return min(jobTimeout+margin, maxBuildTimeout)
+}
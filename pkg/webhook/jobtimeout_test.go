@@ -0,0 +1,102 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+const testWorkflowFile = `
+name: ci
+on: push
+jobs:
+  lint:
+    runs-on: self-hosted
+    timeout-minutes: 5
+  test:
+    name: Unit tests
+    runs-on: self-hosted
+    timeout-minutes: 45
+    strategy:
+      matrix:
+        go: ['1.23', '1.24']
+  deploy:
+    runs-on: self-hosted
+    timeout-minutes: ${{ inputs.timeout }}
+  build:
+    runs-on: self-hosted
+`
+
+func TestParseJobTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		jobName string
+		want    time.Duration
+		wantOK  bool
+	}{
+		{
+			name:    "job_key",
+			jobName: "lint",
+			want:    5 * time.Minute,
+			wantOK:  true,
+		},
+		{
+			name:    "matrix_job_name",
+			jobName: "Unit tests (1.24)",
+			want:    45 * time.Minute,
+			wantOK:  true,
+		},
+		{
+			name:    "expression",
+			jobName: "deploy",
+		},
+		{
+			name:    "no_timeout",
+			jobName: "build",
+		},
+		{
+			name:    "unknown_job",
+			jobName: "release",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok, err := parseJobTimeout([]byte(testWorkflowFile), tc.jobName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("expected (%s, %t), got (%s, %t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestBuildTimeout(t *testing.T) {
+	t.Parallel()
+
+	if got, want := buildTimeout(30*time.Minute, 10*time.Minute), 40*time.Minute; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got, want := buildTimeout(48*time.Hour, 10*time.Minute), maxBuildTimeout; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -17,10 +17,13 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"hash/crc32"
 
 	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
 	"github.com/sethvargo/go-gcpkms/pkg/gcpkms"
 	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // KeyManagement provides a client and dataset identifiers.
@@ -49,6 +52,24 @@ func (km *KeyManagement) CreateSigner(ctx context.Context, kmsAppPrivateKeyID st
 	return signer, nil
 }
 
+// Decrypt decrypts the ciphertext with the symmetric key, verifying the
+// checksums of the ciphertext and the plaintext.
+func (km *KeyManagement) Decrypt(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	table := crc32.MakeTable(crc32.Castagnoli)
+	resp, err := km.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:             keyName,
+		Ciphertext:       ciphertext,
+		CiphertextCrc32C: wrapperspb.Int64(int64(crc32.Checksum(ciphertext, table))),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if int64(crc32.Checksum(resp.GetPlaintext(), table)) != resp.GetPlaintextCrc32C().GetValue() {
+		return nil, fmt.Errorf("failed to decrypt: plaintext checksum mismatch")
+	}
+	return resp.GetPlaintext(), nil
+}
+
 // Close releases any resources held by the KeyManagement client.
 func (km *KeyManagement) Close() error {
 	if err := km.client.Close(); err != nil {
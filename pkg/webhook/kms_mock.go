@@ -16,6 +16,7 @@ package webhook
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sethvargo/go-gcpkms/pkg/gcpkms"
 )
@@ -25,8 +26,16 @@ type CreateSignerRes struct {
 	Err error
 }
 
+// DecryptRes maps ciphertexts to the plaintexts Decrypt returns, or fails
+// with Err.
+type DecryptRes struct {
+	Plaintexts map[string][]byte
+	Err        error
+}
+
 type MockKMSClient struct {
 	CreateSignerMock *CreateSignerRes
+	DecryptMock      *DecryptRes
 }
 
 func (m *MockKMSClient) CreateSigner(ctx context.Context, kmsAppPrivateKeyID string) (*gcpkms.Signer, error) {
@@ -36,6 +45,20 @@ func (m *MockKMSClient) CreateSigner(ctx context.Context, kmsAppPrivateKeyID str
 	return m.CreateSignerMock.Res, nil
 }
 
+func (m *MockKMSClient) Decrypt(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	if m.DecryptMock == nil {
+		return nil, fmt.Errorf("unexpected decrypt")
+	}
+	if m.DecryptMock.Err != nil {
+		return nil, m.DecryptMock.Err
+	}
+	plaintext, ok := m.DecryptMock.Plaintexts[string(ciphertext)]
+	if !ok {
+		return nil, fmt.Errorf("ciphertext was not encrypted with %s", keyName)
+	}
+	return plaintext, nil
+}
+
 func (m *MockKMSClient) Close() error {
 	return nil
 }
@@ -0,0 +1,253 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// launchQueuePollInterval is how often the launch queue checks for capacity
+// freed by runners it was not told about.
+const launchQueuePollInterval = 5 * time.Second
+
+const runnerLaunchQueuedMsg = "runner queued until a build slot is free"
+
+// launchItem is a runner waiting in the launch queue.
+type launchItem struct {
+	*dispatchItem
+	queuedAt time.Time
+}
+
+// launchQueue bounds the number of runner builds running at once. Runners
+// requested at capacity wait in order and are launched as runners of this
+// instance go away. Runners still queued when the server shuts down, for
+// example on a deployment, are lost.
+//
+// Runners only go away when the events of their job reach the instance that
+// dispatched them, so the queue requires the service to run on a single
+// instance. The Terraform module caps the service at one instance, with CPU
+// always allocated for runLaunchQueue, when the limit is set.
+type launchQueue struct {
+	maxBuilds int
+	size      int
+	wake      chan struct{}
+
+	mu        sync.Mutex
+	items     []*launchItem
+	launching int
+}
+
+// newLaunchQueue creates a queue running at most maxBuilds builds and holding
+// at most size runners.
+func newLaunchQueue(maxBuilds, size int) *launchQueue {
+	return &launchQueue{
+		maxBuilds: maxBuilds,
+		size:      size,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// admit reports whether a new runner can launch right away, given the number
+// of runners already running, and takes a build slot for it if so. Runners
+// queue behind those already waiting. The caller must call done once the
+// runner is launched or given up.
+func (q *launchQueue) admit(running int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) > 0 || running+q.launching >= q.maxBuilds {
+		return false
+	}
+	q.launching++
+	return true
+}
+
+// add appends the item and reports whether there was room for it.
+func (q *launchQueue) add(item *launchItem) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) >= q.size {
+		return false
+	}
+	q.items = append(q.items, item)
+	return true
+}
+
+// next removes and returns the first item if a build slot is free, given the
+// number of runners already running. The caller must call done once the
+// runner is launched or given up.
+func (q *launchQueue) next(running int) (*launchItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 || running+q.launching >= q.maxBuilds {
+		return nil, false
+	}
+	item := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	q.launching++
+	return item, true
+}
+
+// done releases the slot taken by admit or next.
+func (q *launchQueue) done() {
+	q.mu.Lock()
+	q.launching--
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+// notify wakes the queue to check for free build slots. It is safe to call
+// on a nil queue.
+func (q *launchQueue) notify() {
+	if q == nil {
+		return
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drop removes the runner queued for the job and reports whether there was
+// one. A nil queue holds nothing.
+func (q *launchQueue) drop(jobID int64) bool {
+	if q == nil {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if item.req.Job.GetWorkflowJob().GetID() == jobID {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// len returns the number of queued runners.
+func (q *launchQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// checkLaunchCapacity returns the response to a runner request made while
+// every build slot is taken, after queueing the runner. If the runner can
+// launch right away, it returns a nil response and the function releasing
+// the build slot taken for it, which the caller must call once the runner is
+// tracked or given up.
+func (s *Server) checkLaunchCapacity(ctx context.Context, req *runnerRequest, logFields []any) (func(), *apiResponse) {
+	if s.launchQueue == nil || req.LaunchReleased {
+		return func() {}, nil
+	}
+
+	// The count only covers the runners of this instance, which is the only
+	// one, see launchQueue. The slot stays taken until the runner is counted
+	// as tracked, so concurrent requests cannot launch more builds than
+	// there are slots.
+	if s.launchQueue.admit(s.runners.Count()) {
+		return s.launchQueue.done, nil
+	}
+
+	logger := logging.FromContext(ctx)
+	req.LaunchReleased = true
+	if !s.launchQueue.add(&launchItem{dispatchItem: &dispatchItem{req: req, logFields: logFields}, queuedAt: time.Now()}) {
+		req.LaunchReleased = false
+		logger.WarnContext(ctx, "launch queue is full", logFields...)
+		return nil, &apiResponse{http.StatusServiceUnavailable, "launch queue is full", nil}
+	}
+	depth := s.launchQueue.len()
+	s.metrics.recordLaunchQueueDepth(depth)
+
+	logger.InfoContext(ctx, runnerLaunchQueuedMsg, append(logFields, "launch_queue_depth", depth)...)
+	return nil, &apiResponse{http.StatusAccepted, runnerLaunchQueuedMsg, nil}
+}
+
+// runLaunchQueue launches queued runners as build slots free up until ctx is
+// done.
+func (s *Server) runLaunchQueue(ctx context.Context) {
+	ticker := time.NewTicker(launchQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.launchQueue.wake:
+		}
+		s.releaseLaunches(ctx, time.Now())
+	}
+}
+
+// releaseLaunches launches, in order, as many queued runners as there are
+// free build slots, dropping those whose job went stale. It returns the
+// number of runners released.
+func (s *Server) releaseLaunches(ctx context.Context, now time.Time) int {
+	logger := logging.FromContext(ctx)
+	s.syncRunners(ctx)
+
+	released := 0
+	for {
+		item, ok := s.launchQueue.next(s.runners.Count())
+		if !ok {
+			break
+		}
+		s.metrics.recordLaunchQueueDepth(s.launchQueue.len())
+
+		ctx := ctx
+		if item.req.DeliveryID != "" {
+			ctx = contextWithDeliveryID(ctx, item.req.DeliveryID)
+		}
+		if age, stale := s.jobStale(item.req.Job, now); stale {
+			logger.WarnContext(ctx, "dropping queued runner for job older than the queue TTL",
+				append(item.logFields, "queue_age", age.String(), "queue_ttl", s.queueTTL.String())...)
+			s.launchQueue.done()
+			continue
+		}
+
+		wait := now.Sub(item.queuedAt)
+		s.metrics.recordLaunchWait(wait)
+		released++
+
+		go func() {
+			defer s.launchQueue.done()
+
+			logFields := append(item.logFields, "launch_wait", wait.String())
+			// Failures are logged and notified by provisionRunner, there is no
+			// delivery left to respond to.
+			createdBuild, errResponse := s.provisionRunner(ctx, item.req, logFields)
+			if errResponse != nil {
+				return
+			}
+			s.runnerDispatched(ctx, item.req, createdBuild, logFields)
+		}()
+	}
+	return released
+}
@@ -0,0 +1,300 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/googleapis/gax-go/v2"
+
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLaunchQueue(t *testing.T) {
+	t.Parallel()
+
+	q := newLaunchQueue(2, 2)
+	if !q.admit(0) {
+		t.Errorf("expected runner under the limit to be admitted")
+	}
+	if q.admit(1) {
+		t.Errorf("expected the admitted runner to take up a slot")
+	}
+	q.done()
+	<-q.wake
+	if q.admit(2) {
+		t.Errorf("expected runner at the limit not to be admitted")
+	}
+
+	for i := range 2 {
+		req := &runnerRequest{RunnerName: "GCP-1", Job: newDeferredJob(int64(i), time.Now())}
+		if !q.add(&launchItem{dispatchItem: &dispatchItem{req: req}}) {
+			t.Fatalf("expected room for runner %d", i)
+		}
+	}
+	if q.add(&launchItem{dispatchItem: &dispatchItem{req: &runnerRequest{}}}) {
+		t.Errorf("expected full queue to reject runner")
+	}
+	if q.admit(0) {
+		t.Errorf("expected runner not to be admitted ahead of queued runners")
+	}
+
+	if _, ok := q.next(2); ok {
+		t.Errorf("expected no runner released at the limit")
+	}
+	item, ok := q.next(1)
+	if !ok {
+		t.Fatal("expected a runner released under the limit")
+	}
+	if got, want := item.req.Job.GetWorkflowJob().GetID(), int64(0); got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if _, ok := q.next(1); ok {
+		t.Errorf("expected the launching runner to take up the free slot")
+	}
+
+	q.done()
+	select {
+	case <-q.wake:
+	default:
+		t.Errorf("expected done to wake the queue")
+	}
+
+	if !q.drop(1) {
+		t.Errorf("expected the queued runner of job 1 to be dropped")
+	}
+	if got, want := q.len(), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestCheckLaunchCapacity(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		launchQueue: newLaunchQueue(1, 1),
+		runners:     newRunnerTracker(),
+	}
+
+	release, resp := srv.checkLaunchCapacity(t.Context(), &runnerRequest{RunnerName: "GCP-1"}, nil)
+	if resp != nil {
+		t.Fatalf("expected runner under the limit to launch, got %d %s", resp.Code, resp.Message)
+	}
+
+	srv.runners.Dispatched(&trackedRunner{RunnerName: "GCP-1"})
+	release()
+
+	cases := []struct {
+		name        string
+		req         *runnerRequest
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:        "queued",
+			req:         &runnerRequest{RunnerName: "GCP-2"},
+			wantCode:    http.StatusAccepted,
+			wantMessage: runnerLaunchQueuedMsg,
+		},
+		{
+			name:        "queue_full",
+			req:         &runnerRequest{RunnerName: "GCP-3"},
+			wantCode:    http.StatusServiceUnavailable,
+			wantMessage: "launch queue is full",
+		},
+	}
+
+	// The cases share the launch queue, so they run in order.
+	for _, tc := range cases {
+		_, resp := srv.checkLaunchCapacity(t.Context(), tc.req, nil)
+		if resp == nil {
+			t.Fatalf("%s: expected runner at the limit not to launch", tc.name)
+		}
+		if got, want := resp.Code, tc.wantCode; got != want {
+			t.Errorf("%s: expected %d to be %d", tc.name, got, want)
+		}
+		if got, want := resp.Message, tc.wantMessage; got != want {
+			t.Errorf("%s: expected %q to be %q", tc.name, got, want)
+		}
+	}
+
+	if _, resp := srv.checkLaunchCapacity(t.Context(), &runnerRequest{RunnerName: "GCP-2", LaunchReleased: true}, nil); resp != nil {
+		t.Errorf("expected released runner to launch, got %d %s", resp.Code, resp.Message)
+	}
+}
+
+func TestReleaseLaunches(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var gotRunners []string
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotRunners = append(gotRunners, name)
+	})
+
+	srv := &Server{
+		appClient:    app,
+		cbc:          &MockCloudBuildClient{},
+		ghAPIBaseURL: ghURL,
+		launchQueue:  newLaunchQueue(1, 10),
+		metrics:      newWebhookMetrics(metrics.NewRegistry()),
+		queueTTL:     time.Hour,
+		runners:      newRunnerTracker(),
+	}
+	srv.runners.Dispatched(&trackedRunner{RunnerName: "GCP-0"})
+
+	now := time.Now()
+	for i, createdAt := range []time.Time{now.Add(-2 * time.Hour), now, now} {
+		req := &runnerRequest{
+			InstallationID: 123,
+			Org:            "google",
+			Repo:           "webhook",
+			RunnerName:     []string{"GCP-stale", "GCP-1", "GCP-2"}[i],
+			Job:            newDeferredJob(int64(i), createdAt),
+		}
+		if _, resp := srv.checkLaunchCapacity(t.Context(), req, nil); resp == nil || resp.Code != http.StatusAccepted {
+			t.Fatalf("expected runner %s to be queued, got %v", req.RunnerName, resp)
+		}
+	}
+	if got, want := srv.metrics.launchQueueDepth.Value(), 3.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+
+	if got, want := srv.releaseLaunches(t.Context(), now), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	srv.runners.Remove("GCP-0")
+	if got, want := srv.releaseLaunches(t.Context(), now.Add(time.Minute)), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.runners.List()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]string{"GCP-1"}, gotRunners); diff != "" {
+		t.Errorf("unexpected launched runners (-want, +got):\n%s", diff)
+	}
+	if got, want := srv.launchQueue.len(), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := srv.metrics.launchQueueDepth.Value(), 1.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+	if got, want := srv.metrics.launchReleases.Value(), 1.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+	if got := srv.metrics.launchWaitSeconds.Value(); got < 59 {
+		t.Errorf("expected launch wait of about a minute, got %v", got)
+	}
+}
+
+// slowCloudBuildClient is a CloudBuildClient whose builds take a while to
+// create, recording how many builds were created at most at once.
+type slowCloudBuildClient struct {
+	benchCloudBuildClient
+
+	mu          sync.Mutex
+	creating    int
+	maxCreating int
+	created     int
+}
+
+func (c *slowCloudBuildClient) CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error) {
+	c.mu.Lock()
+	c.creating++
+	c.maxCreating = max(c.maxCreating, c.creating)
+	c.mu.Unlock()
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.mu.Lock()
+	c.creating--
+	c.created++
+	c.mu.Unlock()
+	return c.benchCloudBuildClient.CreateBuild(ctx, req, opts...)
+}
+
+func TestProvisionRunner_ConcurrentLaunchCapacity(t *testing.T) {
+	t.Parallel()
+
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {})
+	cbc := &slowCloudBuildClient{}
+	srv := &Server{
+		appClient:    app,
+		cbc:          cbc,
+		ghAPIBaseURL: ghURL,
+		launchQueue:  newLaunchQueue(2, 10),
+		metrics:      newWebhookMetrics(metrics.NewRegistry()),
+		runners:      newRunnerTracker(),
+	}
+
+	// A matrix of jobs queued at once must not start more builds than there
+	// are slots.
+	codes := make([]int, 5)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := &runnerRequest{
+				InstallationID: 123,
+				Org:            "google",
+				Repo:           "webhook",
+				RunnerName:     fmt.Sprintf("GCP-%d", i),
+				Job:            newDeferredJob(int64(i), time.Now()),
+			}
+			codes[i] = http.StatusOK
+			if _, resp := srv.provisionRunner(t.Context(), req, nil); resp != nil {
+				codes[i] = resp.Code
+			}
+		}()
+	}
+	wg.Wait()
+
+	queued := 0
+	for _, code := range codes {
+		if code == http.StatusAccepted {
+			queued++
+		}
+	}
+	if got, want := srv.runners.Count(), 2; got != want {
+		t.Errorf("expected %d runners to be %d", got, want)
+	}
+	if got, want := queued, 3; got != want {
+		t.Errorf("expected %d queued runners to be %d", got, want)
+	}
+	if got, want := cbc.created, 2; got != want {
+		t.Errorf("expected %d builds to be %d", got, want)
+	}
+	if got, want := cbc.maxCreating, 2; got > want {
+		t.Errorf("expected at most %d builds created at once, got %d", want, got)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// LifecycleEventType identifies a point in a workflow job's life as observed
+// by the webhook.
+type LifecycleEventType string
+
+const (
+	// LifecycleEventQueued is emitted when a queued job targeting our runners
+	// is received.
+	LifecycleEventQueued LifecycleEventType = "queued"
+
+	// LifecycleEventDispatched is emitted once a runner build was created.
+	LifecycleEventDispatched LifecycleEventType = "dispatched"
+
+	// LifecycleEventOnline is emitted when a runner picked up the job.
+	LifecycleEventOnline LifecycleEventType = "online"
+
+	// LifecycleEventCompleted is emitted when the job finished.
+	LifecycleEventCompleted LifecycleEventType = "completed"
+)
+
+// LifecycleEvent is the structured message published for downstream
+// consumers of job lifecycle transitions.
+type LifecycleEvent struct {
+	Type           LifecycleEventType `json:"type"`
+	Timestamp      time.Time          `json:"timestamp"`
+	InstallationID int64              `json:"installation_id,omitempty"`
+	Org            string             `json:"org,omitempty"`
+	Repo           string             `json:"repo,omitempty"`
+	RunID          int64              `json:"run_id,omitempty"`
+	JobID          int64              `json:"job_id,omitempty"`
+	JobName        string             `json:"job_name,omitempty"`
+	Labels         []string           `json:"labels,omitempty"`
+	RunnerName     string             `json:"runner_name,omitempty"`
+	BuildID        string             `json:"build_id,omitempty"`
+	ProjectID      string             `json:"project_id,omitempty"`
+	Conclusion     string             `json:"conclusion,omitempty"`
+
+	QueuedDurationSeconds     float64 `json:"queued_duration_seconds,omitempty"`
+	InProgressDurationSeconds float64 `json:"in_progress_duration_seconds,omitempty"`
+	TotalDurationSeconds      float64 `json:"total_duration_seconds,omitempty"`
+}
+
+// EventPublisher publishes lifecycle events to downstream consumers.
+type EventPublisher interface {
+	Close() error
+	Publish(ctx context.Context, event *LifecycleEvent) error
+}
+
+// newLifecycleEvent populates the common fields of a lifecycle event from a
+// workflow job event.
+func newLifecycleEvent(typ LifecycleEventType, event *github.WorkflowJobEvent) *LifecycleEvent {
+	le := &LifecycleEvent{
+		Type:           typ,
+		Timestamp:      time.Now().UTC(),
+		InstallationID: event.GetInstallation().GetID(),
+		Org:            event.GetOrg().GetLogin(),
+		Repo:           event.GetRepo().GetName(),
+	}
+
+	if job := event.GetWorkflowJob(); job != nil {
+		le.RunID = job.GetRunID()
+		le.JobID = job.GetID()
+		le.JobName = job.GetName()
+		le.Labels = job.Labels
+		le.RunnerName = job.GetRunnerName()
+		le.Conclusion = job.GetConclusion()
+
+		if job.CreatedAt != nil && job.StartedAt != nil {
+			le.QueuedDurationSeconds = job.StartedAt.Sub(job.CreatedAt.Time).Seconds()
+		}
+		if job.StartedAt != nil && job.CompletedAt != nil {
+			le.InProgressDurationSeconds = job.CompletedAt.Sub(job.StartedAt.Time).Seconds()
+		}
+		if job.CreatedAt != nil && job.CompletedAt != nil {
+			le.TotalDurationSeconds = job.CompletedAt.Sub(job.CreatedAt.Time).Seconds()
+		}
+	}
+	return le
+}
+
+// publishLifecycleEvent publishes the event if a publisher is configured.
+// Failures are logged but never fail the webhook delivery, the event stream is
+// best effort.
+func (s *Server) publishLifecycleEvent(ctx context.Context, event *LifecycleEvent) {
+	if s.publisher == nil || s.shadowMode {
+		return
+	}
+
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to publish lifecycle event",
+			"event_type", event.Type,
+			"gh_job_id", event.JobID,
+			"error", err)
+	}
+}
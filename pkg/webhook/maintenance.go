@@ -0,0 +1,96 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// maintenanceToggle is the maintenance mode of this instance, toggled through
+// the admin API. It applies in addition to the maintenance_mode shared
+// setting, which every instance applies. A nil toggle is never on.
+type maintenanceToggle struct {
+	on atomic.Bool
+}
+
+func newMaintenanceToggle() *maintenanceToggle {
+	return &maintenanceToggle{}
+}
+
+// On reports whether the instance was put in maintenance.
+func (m *maintenanceToggle) On() bool {
+	if m == nil {
+		return false
+	}
+	return m.on.Load()
+}
+
+// Set puts the instance in or out of maintenance and returns the previous
+// mode.
+func (m *maintenanceToggle) Set(on bool) bool {
+	if m == nil {
+		return false
+	}
+	return m.on.Swap(on)
+}
+
+// inMaintenance reports whether webhook deliveries and dispatch requests are
+// refused, so GitHub redelivers them once maintenance is over.
+func (s *Server) inMaintenance() bool {
+	return s.maintenance.On() || s.settings.Get().MaintenanceMode
+}
+
+// maintenanceStatus is the maintenance mode of an instance and where it comes
+// from.
+type maintenanceStatus struct {
+	// MaintenanceMode is whether the instance refuses deliveries.
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// Instance is whether the instance was put in maintenance through the
+	// admin API.
+	Instance bool `json:"instance"`
+
+	// Shared is whether the maintenance_mode shared setting is on. It can only
+	// be turned off by changing the settings object.
+	Shared bool `json:"shared"`
+}
+
+// handleAdminMaintenance responds with the maintenance mode of the instance.
+// PUT puts the instance in maintenance and DELETE takes it out. The toggle
+// only applies to the instance serving the request, use the maintenance_mode
+// shared setting to put every instance in maintenance.
+func (s *Server) handleAdminMaintenance() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		switch r.Method {
+		case http.MethodPut, http.MethodDelete:
+			on := r.Method == http.MethodPut
+			if was := s.maintenance.Set(on); was != on {
+				logging.FromContext(ctx).WarnContext(ctx, "maintenance mode toggled through the admin API",
+					"maintenance_mode", on)
+			}
+		}
+
+		s.h.RenderJSON(w, http.StatusOK, &maintenanceStatus{
+			MaintenanceMode: s.inMaintenance(),
+			Instance:        s.maintenance.On(),
+			Shared:          s.settings.Get().MaintenanceMode,
+		})
+	})
+}
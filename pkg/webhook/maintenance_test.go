@@ -0,0 +1,135 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/renderer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleAdminMaintenance(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		on       bool
+		shared   bool
+		method   string
+		wantCode int
+		want     *maintenanceStatus
+	}{
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			wantCode: http.StatusOK,
+			want:     &maintenanceStatus{},
+		},
+		{
+			name:     "enable",
+			method:   http.MethodPut,
+			wantCode: http.StatusOK,
+			want:     &maintenanceStatus{MaintenanceMode: true, Instance: true},
+		},
+		{
+			name:     "enable_twice",
+			on:       true,
+			method:   http.MethodPut,
+			wantCode: http.StatusOK,
+			want:     &maintenanceStatus{MaintenanceMode: true, Instance: true},
+		},
+		{
+			name:     "disable",
+			on:       true,
+			method:   http.MethodDelete,
+			wantCode: http.StatusOK,
+			want:     &maintenanceStatus{},
+		},
+		{
+			name:     "disable_keeps_shared",
+			on:       true,
+			shared:   true,
+			method:   http.MethodDelete,
+			wantCode: http.StatusOK,
+			want:     &maintenanceStatus{MaintenanceMode: true, Shared: true},
+		},
+		{
+			name:     "unsupported_method",
+			method:   http.MethodPost,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+			s := &Server{
+				adminToken:  []byte("admin-token"),
+				h:           renderer.NewTesting(ctx, t, nil),
+				maintenance: newMaintenanceToggle(),
+				settings:    &sharedSettings{current: &SharedSettings{MaintenanceMode: tc.shared}},
+			}
+			s.maintenance.Set(tc.on)
+
+			req := httptest.NewRequestWithContext(ctx, tc.method, "/admin/maintenance", nil)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			resp := httptest.NewRecorder()
+			s.adminRoutes().ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.want == nil {
+				return
+			}
+
+			var got maintenanceStatus
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, &got); diff != "" {
+				t.Errorf("unexpected status (-want, +got):\n%s", diff)
+			}
+			if got, want := s.inMaintenance(), tc.want.MaintenanceMode; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestInstanceMaintenanceMode(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{maintenance: newMaintenanceToggle()}
+	s.maintenance.Set(true)
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/webhook", nil)
+	resp := s.processRequest(req)
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := resp.Message, maintenanceMsg; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
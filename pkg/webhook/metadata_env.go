@@ -0,0 +1,77 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// metadataServerURL is the base URL of the metadata paths environment
+// variables of the runner are read from.
+const metadataServerURL = "http://metadata.google.internal/computeMetadata/v1/"
+
+// metadataPathRegexp matches the metadata paths environment variables of the
+// runner may be read from, for example project/attributes/proxy-url. The
+// paths are passed to the runner in its shell command.
+var metadataPathRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_.-]+)*$`)
+
+// validateMetadataEnv checks the environment variables of the runner read from
+// the metadata server, by metadata path. They cannot override the variables
+// the webhook service sets, nor the extra environment variables.
+func validateMetadataEnv(env, extraEnv map[string]string) error {
+	for name, path := range env {
+		if !envNameRegexp.MatchString(name) {
+			return fmt.Errorf("environment variable name %q is invalid", name)
+		}
+		if _, ok := runnerEnvSubstitutions[name]; ok || name == jitConfigEnv {
+			return fmt.Errorf("environment variable %q is set by the webhook service", name)
+		}
+		if _, ok := extraEnv[name]; ok {
+			return fmt.Errorf("environment variable %q is also set by RUNNER_EXTRA_ENV", name)
+		}
+		if !metadataPathRegexp.MatchString(path) || strings.Contains(path, "..") {
+			return fmt.Errorf("metadata path %q of environment variable %q is invalid", path, name)
+		}
+	}
+	return nil
+}
+
+// metadataEnvArgs returns the docker run arguments passing the environment
+// variables read from the metadata server to the runner, sorted by name. The
+// run step reads them: on Cloud Build it can reach the metadata server,
+// unlike the runner container on the bridge network.
+func metadataEnvArgs(env map[string]string) string {
+	args := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		args = append(args, fmt.Sprintf(`-e %s="$(curl -fsS -H 'Metadata-Flavor: Google' %s%s)"`, name, metadataServerURL, env[name]))
+	}
+	return strings.Join(args, " ")
+}
+
+// metadataEnvList returns the environment variables read from the metadata
+// server as a comma separated list of <name>=<path>, sorted by name. Backends
+// that do not run the run step pass it to the runner, which reads the
+// variables itself.
+func metadataEnvList(env map[string]string) string {
+	entries := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		entries = append(entries, name+"="+env[name])
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateMetadataEnv(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		env      map[string]string
+		extraEnv map[string]string
+		wantErr  string
+	}{
+		{
+			name: "valid",
+			env:  map[string]string{"PIP_INDEX_URL": "project/attributes/pip-index-url", "GCP_PROJECT": "project/project-id"},
+		},
+		{
+			name:    "invalid_name",
+			env:     map[string]string{"PIP-INDEX": "project/attributes/pip-index-url"},
+			wantErr: `environment variable name "PIP-INDEX" is invalid`,
+		},
+		{
+			name:    "reserved",
+			env:     map[string]string{"HTTP_PROXY": "project/attributes/proxy"},
+			wantErr: `environment variable "HTTP_PROXY" is set by the webhook service`,
+		},
+		{
+			name:     "extra_env",
+			env:      map[string]string{"FEATURE_X": "project/attributes/feature-x"},
+			extraEnv: map[string]string{"FEATURE_X": "on"},
+			wantErr:  `environment variable "FEATURE_X" is also set by RUNNER_EXTRA_ENV`,
+		},
+		{
+			name:    "shell_characters",
+			env:     map[string]string{"FEATURE_X": "project/attributes/$(id)"},
+			wantErr: `metadata path "project/attributes/$(id)" of environment variable "FEATURE_X" is invalid`,
+		},
+		{
+			name:    "absolute",
+			env:     map[string]string{"FEATURE_X": "/project/project-id"},
+			wantErr: `metadata path "/project/project-id" of environment variable "FEATURE_X" is invalid`,
+		},
+		{
+			name:    "parent",
+			env:     map[string]string{"FEATURE_X": "project/../instance/hostname"},
+			wantErr: `metadata path "project/../instance/hostname" of environment variable "FEATURE_X" is invalid`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(validateMetadataEnv(tc.env, tc.extraEnv), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAddMetadataEnv(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerMetadataEnv:  map[string]string{"PIP_INDEX_URL": "project/attributes/pip-index-url", "GCP_PROJECT": "project/project-id"},
+		runnerImageName:    "default-runner",
+		runnerImageTag:     "latest",
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+	}
+
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+	wantArgs := `-e GCP_PROJECT="$(curl -fsS -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/project/project-id)" ` +
+		`-e PIP_INDEX_URL="$(curl -fsS -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/project/attributes/pip-index-url)"`
+	if got, want := build.GetSubstitutions()["_METADATA_ENV_ARGS"], wantArgs; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	// Backends without the run step have the runner read the variables.
+	env := (&RunnerSpec{Build: build}).Env()
+	want := map[string]string{
+		"RUNNER_METADATA_ENV":  "GCP_PROJECT=project/project-id,PIP_INDEX_URL=project/attributes/pip-index-url",
+		"RUNNER_SECURITY_MODE": runnerSecurityPrivileged,
+	}
+	if diff := cmp.Diff(want, env); diff != "" {
+		t.Errorf("unexpected env (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"time"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
+)
+
+const metricsNamespace = "github_actions_on_gcp_"
+
+// Outcomes of a workflow_job event, used as the outcome label of the
+// workflow job actions counter.
+const (
+	workflowJobOutcomeProcessed = "processed"
+	workflowJobOutcomeIgnored   = "ignored"
+	workflowJobOutcomeRejected  = "rejected"
+	workflowJobOutcomeInvalid   = "invalid"
+)
+
+// webhookMetrics are the metrics recorded by the server. All methods are safe
+// to call on a nil receiver.
+type webhookMetrics struct {
+	events             *metrics.Counter
+	workflowJobActions *metrics.Counter
+	jobRunners         *metrics.Counter
+	wastedDispatches   *metrics.Counter
+	runnerTransitions  *metrics.Counter
+	gatedJobs          *metrics.Counter
+	policyDecisions    *metrics.Counter
+	imageDispatches    *metrics.Counter
+	imageJobs          *metrics.Counter
+	shadowDispatches   *metrics.Counter
+	payloadFallbacks   *metrics.Counter
+	actorRejections    *metrics.Counter
+	signerFallbacks    *metrics.Counter
+	fallbackDispatches *metrics.Counter
+	locationFallbacks  *metrics.Counter
+	missingImages      *metrics.Counter
+	untrustedImages    *metrics.Counter
+	spotPreemptions    *metrics.Counter
+	forecastRunners    *metrics.Counter
+	deferredRunners    *metrics.Counter
+	launchQueueDepth   *metrics.Gauge
+	launchWaitSeconds  *metrics.Counter
+	launchReleases     *metrics.Counter
+	orphans            *metrics.Counter
+	offlineRunners     *metrics.Counter
+	expiredRunners     *metrics.Counter
+	wastedRunners      *metrics.Counter
+	gatedJobWait       *metrics.Counter
+	gatedJobsReviewed  *metrics.Counter
+	panics             *metrics.Counter
+}
+
+func newWebhookMetrics(r *metrics.Registry) *webhookMetrics {
+	return &webhookMetrics{
+		events: r.NewCounter(metricsNamespace+"webhook_events_total",
+			"GitHub webhook deliveries with a valid signature, by event type.",
+			"event_type"),
+		workflowJobActions: r.NewCounter(metricsNamespace+"workflow_job_actions_total",
+			"workflow_job events by action and outcome. Jobs without the self-hosted label are rejected.",
+			"action", "outcome"),
+		jobRunners: r.NewCounter(metricsNamespace+"workflow_job_runners_total",
+			"in_progress and completed events of self-hosted jobs, by whether a runner provisioned by this service (gcp), another runner (other) or no runner (unassigned) served the job.",
+			"action", "runner"),
+		wastedDispatches: r.NewCounter(metricsNamespace+"wasted_dispatches_total",
+			"Self-hosted jobs picked up by a runner not provisioned by this service."),
+		runnerTransitions: r.NewCounter(metricsNamespace+"runner_transitions_total",
+			"Lifecycle transitions of runners provisioned by this instance, by state left and state entered.",
+			"from", "to"),
+		gatedJobs: r.NewCounter(metricsNamespace+"gated_jobs_total",
+			"Self-hosted jobs gated by a deployment environment, by stage: waiting for approval, approved, rejected, or pre-warmed on approval.",
+			"stage"),
+		policyDecisions: r.NewCounter(metricsNamespace+"policy_decisions_total",
+			"Runner requests evaluated by the dispatch policy, by deciding rule and decision.",
+			"rule", "decision"),
+		imageDispatches: r.NewCounter(metricsNamespace+"runner_image_dispatches_total",
+			"Runners dispatched, by runner profile, image tag and whether the tag is the stable or the canary one.",
+			"profile", "tag", "track"),
+		imageJobs: r.NewCounter(metricsNamespace+"runner_image_jobs_total",
+			"Jobs completed by runners dispatched by this instance, by image tag and job conclusion.",
+			"tag", "conclusion"),
+		shadowDispatches: r.NewCounter(metricsNamespace+"shadow_dispatches_total",
+			"Runner builds a shadow deployment would have created, by runner profile.",
+			"profile"),
+		payloadFallbacks: r.NewCounter(metricsNamespace+"payload_fallbacks_total",
+			"Deliveries go-github failed to parse whose required fields were extracted from the raw JSON, by event type.",
+			"event_type"),
+		actorRejections: r.NewCounter(metricsNamespace+"actor_rejections_total",
+			"Runner requests rejected for the actor that triggered them, by reason: blocked actor or actor over its runner limit.",
+			"reason"),
+		signerFallbacks: r.NewCounter(metricsNamespace+"app_signer_fallbacks_total",
+			"GitHub App JWTs signed with the fallback key because the primary KMS key failed or is cooling down."),
+		fallbackDispatches: r.NewCounter(metricsNamespace+"fallback_dispatches_total",
+			"Runners dispatched to the fallback instance group because Cloud Build quota was exhausted."),
+		locationFallbacks: r.NewCounter(metricsNamespace+"location_fallbacks_total",
+			"Runner builds created in a fallback location because Cloud Build quota was exhausted, by the location that served them.",
+			"location"),
+		missingImages: r.NewCounter(metricsNamespace+"missing_runner_images_total",
+			"Runners not dispatched because their image does not exist in Artifact Registry, by image.",
+			"image"),
+		untrustedImages: r.NewCounter(metricsNamespace+"untrusted_runner_images_total",
+			"Runners not dispatched because the image attestor did not verify their image, by image.",
+			"image"),
+		spotPreemptions: r.NewCounter(metricsNamespace+"spot_preemptions_total",
+			"Spot runners preempted, by whether the runner was relaunched or its job rerun.", "action"),
+		forecastRunners: r.NewCounter(metricsNamespace+"forecast_runners_total",
+			"Runners provisioned, or queued for dispatch, ahead of the demand forecast from past weeks."),
+		deferredRunners: r.NewCounter(metricsNamespace+"deferred_runners_total",
+			"Runners of queued jobs deferred over a repository or organization runner limit, by scope.",
+			"scope"),
+		launchQueueDepth: r.NewGauge(metricsNamespace+"launch_queue_depth",
+			"Runners waiting in the launch queue for a free build slot."),
+		launchWaitSeconds: r.NewCounter(metricsNamespace+"launch_queue_wait_seconds_total",
+			"Total time runners released from the launch queue waited for a free build slot."),
+		launchReleases: r.NewCounter(metricsNamespace+"launch_queue_releases_total",
+			"Runners released from the launch queue."),
+		orphans: r.NewCounter(metricsNamespace+"reconciled_orphans_total",
+			"Builds cancelled because their runner was gone and runners removed because their build was gone, by kind.",
+			"kind"),
+		offlineRunners: r.NewCounter(metricsNamespace+"offline_runners_removed_total",
+			"Runners removed from GitHub after being offline for longer than the offline threshold."),
+		expiredRunners: r.NewCounter(metricsNamespace+"expired_runners_total",
+			"Runners cancelled for exceeding the maximum runner lifetime, by the state they were in.",
+			"state"),
+		wastedRunners: r.NewCounter(metricsNamespace+"wasted_runners_total",
+			"Runners torn down for not picking up a job within the idle grace period, by the state they were in.",
+			"state"),
+		gatedJobWait: r.NewCounter(metricsNamespace+"gated_job_wait_seconds_total",
+			"Total time self-hosted jobs gated by a deployment environment waited for a review, by outcome: approved or rejected. Divide by gated_jobs_reviewed_total for the average wait.",
+			"outcome"),
+		gatedJobsReviewed: r.NewCounter(metricsNamespace+"gated_jobs_reviewed_total",
+			"Self-hosted jobs gated by a deployment environment whose review ended their wait, by outcome: approved or rejected.",
+			"outcome"),
+		panics: r.NewCounter(metricsNamespace+"handler_panics_total",
+			"Panics recovered in the HTTP handlers, each answered with a 500."),
+	}
+}
+
+// recordEvent counts a delivery of the given event type.
+func (m *webhookMetrics) recordEvent(eventType string) {
+	if m == nil {
+		return
+	}
+	m.events.Inc(eventType)
+}
+
+// recordWorkflowJob counts a workflow_job event with the given action and
+// outcome.
+func (m *webhookMetrics) recordWorkflowJob(action, outcome string) {
+	if m == nil {
+		return
+	}
+	m.workflowJobActions.Inc(action, outcome)
+}
+
+// recordJobRunner counts which runner served a self-hosted job.
+func (m *webhookMetrics) recordJobRunner(action, origin string) {
+	if m == nil {
+		return
+	}
+	m.jobRunners.Inc(action, origin)
+}
+
+// recordWastedDispatch counts a job picked up by another runner.
+func (m *webhookMetrics) recordWastedDispatch() {
+	if m == nil {
+		return
+	}
+	m.wastedDispatches.Inc()
+}
+
+// recordRunnerTransition counts a runner moving between lifecycle states.
+func (m *webhookMetrics) recordRunnerTransition(from, to lifecycle.State) {
+	if m == nil {
+		return
+	}
+	m.runnerTransitions.Inc(string(from), string(to))
+}
+
+// recordGatedJob counts a job gated by a deployment environment reaching the
+// given stage.
+func (m *webhookMetrics) recordGatedJob(stage string) {
+	if m == nil {
+		return
+	}
+	m.gatedJobs.Inc(stage)
+}
+
+// recordGatedJobWait adds the time a gated job waited for the review with the
+// given outcome, and counts the review.
+func (m *webhookMetrics) recordGatedJobWait(outcome string, waited time.Duration) {
+	if m == nil {
+		return
+	}
+	m.gatedJobWait.Add(waited.Seconds(), outcome)
+	m.gatedJobsReviewed.Inc(outcome)
+}
+
+// recordPolicyDecision counts a dispatch policy decision taken by the rule.
+func (m *webhookMetrics) recordPolicyDecision(rule string, deny bool) {
+	if m == nil {
+		return
+	}
+	decision := policyAllow
+	if deny {
+		decision = policyDeny
+	}
+	m.policyDecisions.Inc(rule, decision)
+}
+
+// recordImageDispatch counts a runner dispatched with the image tag.
+func (m *webhookMetrics) recordImageDispatch(profile, tag, track string) {
+	if m == nil {
+		return
+	}
+	m.imageDispatches.Inc(profile, tag, track)
+}
+
+// recordImageJob counts a job completed by a runner with the image tag.
+func (m *webhookMetrics) recordImageJob(tag, conclusion string) {
+	if m == nil {
+		return
+	}
+	m.imageJobs.Inc(tag, conclusion)
+}
+
+// recordShadowDispatch counts a runner build not created in shadow mode.
+func (m *webhookMetrics) recordShadowDispatch(profile string) {
+	if m == nil {
+		return
+	}
+	m.shadowDispatches.Inc(profile)
+}
+
+// recordPayloadFallback counts a delivery parsed from the raw JSON.
+func (m *webhookMetrics) recordPayloadFallback(eventType string) {
+	if m == nil {
+		return
+	}
+	m.payloadFallbacks.Inc(eventType)
+}
+
+// recordActorRejection counts a runner request rejected for its actor.
+func (m *webhookMetrics) recordActorRejection(reason string) {
+	if m == nil {
+		return
+	}
+	m.actorRejections.Inc(reason)
+}
+
+// recordSignerFallback counts a JWT signed with the fallback key.
+func (m *webhookMetrics) recordSignerFallback() {
+	if m == nil {
+		return
+	}
+	m.signerFallbacks.Inc()
+}
+
+// recordFallbackDispatch counts a runner dispatched to the fallback instance
+// group.
+func (m *webhookMetrics) recordFallbackDispatch() {
+	if m == nil {
+		return
+	}
+	m.fallbackDispatches.Inc()
+}
+
+// recordLocationFallback counts a runner build created in a fallback location.
+func (m *webhookMetrics) recordLocationFallback(location string) {
+	if m == nil {
+		return
+	}
+	m.locationFallbacks.Inc(location)
+}
+
+// recordMissingImage counts a runner not dispatched because its image does
+// not exist.
+func (m *webhookMetrics) recordMissingImage(image string) {
+	if m == nil {
+		return
+	}
+	m.missingImages.Inc(image)
+}
+
+// recordUntrustedImage counts a runner not dispatched because its image was
+// not verified.
+func (m *webhookMetrics) recordUntrustedImage(image string) {
+	if m == nil {
+		return
+	}
+	m.untrustedImages.Inc(image)
+}
+
+// Actions taken for a preempted spot runner, used as the action label of the
+// spot preemptions counter.
+const (
+	spotPreemptionRelaunched = "relaunched"
+	spotPreemptionRerun      = "rerun"
+)
+
+// recordSpotPreemption counts a preempted spot runner.
+func (m *webhookMetrics) recordSpotPreemption(action string) {
+	if m == nil {
+		return
+	}
+	m.spotPreemptions.Inc(action)
+}
+
+// recordForecastRunner counts a runner provisioned ahead of forecast demand.
+func (m *webhookMetrics) recordForecastRunner() {
+	if m == nil {
+		return
+	}
+	m.forecastRunners.Inc()
+}
+
+// recordDeferredRunner counts a runner deferred over the limit of the scope.
+func (m *webhookMetrics) recordDeferredRunner(scope string) {
+	if m == nil {
+		return
+	}
+	m.deferredRunners.Inc(scope)
+}
+
+// recordLaunchQueueDepth records the number of runners in the launch queue.
+func (m *webhookMetrics) recordLaunchQueueDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.launchQueueDepth.Set(float64(depth))
+}
+
+// recordLaunchWait records a runner released from the launch queue after
+// waiting for wait.
+func (m *webhookMetrics) recordLaunchWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.launchWaitSeconds.Add(wait.Seconds())
+	m.launchReleases.Inc()
+}
+
+// recordOrphan counts an orphaned build or runner cleaned up.
+func (m *webhookMetrics) recordOrphan(kind string) {
+	if m == nil {
+		return
+	}
+	m.orphans.Inc(kind)
+}
+
+// recordOfflineRunnerRemoved counts a runner removed for being offline.
+func (m *webhookMetrics) recordOfflineRunnerRemoved() {
+	if m == nil {
+		return
+	}
+	m.offlineRunners.Inc()
+}
+
+// recordRunnerExpired counts a runner cancelled in the given state for
+// exceeding the maximum runner lifetime.
+func (m *webhookMetrics) recordRunnerExpired(state lifecycle.State) {
+	if m == nil {
+		return
+	}
+	m.expiredRunners.Inc(string(state))
+}
+
+// recordWastedRunner counts a runner torn down in the given state for never
+// picking up a job.
+func (m *webhookMetrics) recordWastedRunner(state lifecycle.State) {
+	if m == nil {
+		return
+	}
+	m.wastedRunners.Inc(string(state))
+}
+
+// recordPanic counts a panic recovered in a handler.
+func (m *webhookMetrics) recordPanic() {
+	if m == nil {
+		return
+	}
+	m.panics.Inc()
+}
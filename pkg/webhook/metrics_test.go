@@ -0,0 +1,89 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
+)
+
+func TestWebhookMetrics(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("test-secret")
+	registry := metrics.NewRegistry()
+	srv := &Server{
+		metrics:       newWebhookMetrics(registry),
+		webhookSecret: secret,
+	}
+
+	deliveries := []struct {
+		eventType string
+		payload   string
+	}{
+		{"ping", `{"zen": "Keep it logically awesome."}`},
+		{"workflow_job", `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "labels": ["ubuntu-latest"]}, "installation": {"id": 3}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`},
+		{"workflow_job", `{"action": "waiting", "workflow_job": {"id": 1, "run_id": 2}}`},
+		{"workflow_job", `{"action": "waiting", "workflow_job": {"id": 1, "run_id": 2}}`},
+		{"workflow_job", `{"action": "queued", "workflow_job": {"id": 1}}`},
+	}
+	for _, d := range deliveries {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(d.payload)))
+		req.Header.Set(EventTypeHeader, d.eventType)
+		req.Header.Set(ContentTypeHeader, "application/json")
+		req.Header.Set(SHA256SignatureHeader, "sha256="+createSignature(secret, []byte(d.payload)))
+		srv.handleWebhook().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	cases := []struct {
+		name    string
+		counter *metrics.Counter
+		labels  []string
+		want    float64
+	}{
+		{"ping_events", srv.metrics.events, []string{"ping"}, 1},
+		{"workflow_job_events", srv.metrics.events, []string{"workflow_job"}, 4},
+		{"invalid_queued", srv.metrics.workflowJobActions, []string{"queued", workflowJobOutcomeInvalid}, 1},
+		{"rejected_queued", srv.metrics.workflowJobActions, []string{"queued", workflowJobOutcomeRejected}, 1},
+		{"ignored_waiting", srv.metrics.workflowJobActions, []string{"waiting", workflowJobOutcomeIgnored}, 2},
+	}
+	for _, tc := range cases {
+		if got := tc.counter.Value(tc.labels...); got != tc.want {
+			t.Errorf("%s: expected %v to be %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWebhookMetrics_GatedJobWait(t *testing.T) {
+	t.Parallel()
+
+	m := newWebhookMetrics(metrics.NewRegistry())
+	m.recordGatedJobWait(gatedJobApproved, 2*time.Minute)
+	m.recordGatedJobWait(gatedJobApproved, 4*time.Minute)
+	m.recordGatedJobWait(gatedJobRejected, time.Minute)
+
+	// The average wait is the total wait over the reviews.
+	if got, want := m.gatedJobWait.Value(gatedJobApproved)/m.gatedJobsReviewed.Value(gatedJobApproved), 180.0; got != want {
+		t.Errorf("expected average wait %v to be %v", got, want)
+	}
+	if got, want := m.gatedJobsReviewed.Value(gatedJobRejected), 1.0; got != want {
+		t.Errorf("expected %v rejected reviews to be %v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// InstanceGroupClient adheres to the interaction the webhook service has with
+// Compute Engine to run runners on the VMs of a managed instance group.
+type InstanceGroupClient interface {
+	CreateInstance(ctx context.Context, group *InstanceGroup, name string, metadata map[string]string) error
+	DeleteInstance(ctx context.Context, group *InstanceGroup, name string) error
+}
+
+// InstanceGroup is a zonal managed instance group.
+type InstanceGroup struct {
+	Project string
+	Zone    string
+	Name    string
+}
+
+func (g *InstanceGroup) String() string {
+	return fmt.Sprintf("projects/%s/zones/%s/instanceGroupManagers/%s", g.Project, g.Zone, g.Name)
+}
+
+// parseInstanceGroup parses a managed instance group in the form
+// projects/<project>/zones/<zone>/instanceGroupManagers/<name>.
+func parseInstanceGroup(name string) (*InstanceGroup, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "zones" || parts[4] != "instanceGroupManagers" ||
+		parts[1] == "" || parts[3] == "" || parts[5] == "" {
+		return nil, fmt.Errorf("%q must be in the form projects/<project>/zones/<zone>/instanceGroupManagers/<name>", name)
+	}
+	return &InstanceGroup{
+		Project: parts[1],
+		Zone:    parts[3],
+		Name:    parts[5],
+	}, nil
+}
+
+// instanceNameInvalidRegexp matches the characters instance names cannot
+// contain.
+var instanceNameInvalidRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// runnerInstanceName returns the name of the VM running the runner. Instance
+// names are lowercase, start with a letter and are at most 63 characters.
+func runnerInstanceName(runnerName string) string {
+	name := instanceNameInvalidRegexp.ReplaceAllString(strings.ToLower(runnerName), "-")
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		name = "runner-" + name
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// fallbackBackend runs each runner on a new VM of the fallback instance group,
+// handing it the runner through the instance metadata. It is used when Cloud
+// Build refuses the build for exhausted quota, so jobs keep running at the
+// capacity of the group. Its IDs are the names of the instances.
+type fallbackBackend struct {
+	client  InstanceGroupClient
+	group   *InstanceGroup
+	metrics *webhookMetrics
+	timeout time.Duration
+}
+
+func (b *fallbackBackend) Provision(ctx context.Context, spec *RunnerSpec) (string, error) {
+	metadata := runnerVMMetadata(spec.Build, spec.RunnerName)
+
+	instance := runnerInstanceName(spec.RunnerName)
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.client.CreateInstance(cctx, b.group, instance, metadata); err != nil {
+		return "", fmt.Errorf("failed to fall back to instance group: %w", err)
+	}
+	b.metrics.recordFallbackDispatch()
+	return instance, nil
+}
+
+func (b *fallbackBackend) Cancel(ctx context.Context, id string) error {
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.client.DeleteInstance(cctx, b.group, id); err != nil {
+		return fmt.Errorf("failed to delete fallback instance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseInstanceGroup(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		group   string
+		want    *InstanceGroup
+		wantErr string
+	}{
+		{
+			name:  "zonal",
+			group: "projects/p/zones/us-central1-a/instanceGroupManagers/runners",
+			want:  &InstanceGroup{Project: "p", Zone: "us-central1-a", Name: "runners"},
+		},
+		{
+			name:    "regional",
+			group:   "projects/p/regions/us-central1/instanceGroupManagers/runners",
+			wantErr: "must be in the form",
+		},
+		{
+			name:    "missing_name",
+			group:   "projects/p/zones/us-central1-a/instanceGroupManagers/",
+			wantErr: "must be in the form",
+		},
+		{
+			name:    "name_only",
+			group:   "runners",
+			wantErr: "must be in the form",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseInstanceGroup(tc.group)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected instance group (-want, +got):\n%s", diff)
+			}
+			if got != nil {
+				if got, want := got.String(), tc.group; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunnerInstanceName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		runnerName string
+		want       string
+	}{
+		{
+			name:       "uuid",
+			runnerName: "GCP-2b5c5a5e-52b1-4c19-a4ab-0a5f0c4de9a1",
+			want:       "gcp-2b5c5a5e-52b1-4c19-a4ab-0a5f0c4de9a1",
+		},
+		{
+			name:       "invalid_characters",
+			runnerName: "GCP_runner.1",
+			want:       "gcp-runner-1",
+		},
+		{
+			name:       "leading_digit",
+			runnerName: "42",
+			want:       "runner-42",
+		},
+		{
+			name:       "too_long",
+			runnerName: "GCP-" + fmt.Sprintf("%070d", 0),
+			want:       "gcp-" + fmt.Sprintf("%059d", 0),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := runnerInstanceName(tc.runnerName), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFallbackBackendProvision(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		build        *cloudbuildpb.Build
+		createErr    error
+		wantInstance string
+		wantMetadata map[string]string
+		wantErr      string
+	}{
+		{
+			name: "jit_config",
+			build: &cloudbuildpb.Build{
+				Substitutions: map[string]string{"_ENCODED_JIT_CONFIG": "encoded-jit-config"},
+			},
+			wantInstance: "gcp-2",
+			wantMetadata: map[string]string{
+				"runner-name":  "GCP-2",
+				"runner-image": "$_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
+				"jit-config":   "encoded-jit-config",
+			},
+		},
+		{
+			name: "jit_config_secret",
+			build: &cloudbuildpb.Build{
+				AvailableSecrets: &cloudbuildpb.Secrets{
+					SecretManager: []*cloudbuildpb.SecretManagerSecret{
+						{VersionName: "projects/p/secrets/jit-config-GCP-2/versions/1", Env: jitConfigEnv},
+					},
+				},
+			},
+			wantInstance: "gcp-2",
+			wantMetadata: map[string]string{
+				"runner-name":       "GCP-2",
+				"runner-image":      "$_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
+				"jit-config-secret": "projects/p/secrets/jit-config-GCP-2/versions/1",
+			},
+		},
+		{
+			name:      "create_error",
+			build:     &cloudbuildpb.Build{},
+			createErr: fmt.Errorf("quota exceeded"),
+			wantErr:   "failed to fall back to instance group: quota exceeded",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			igc := &MockInstanceGroupClient{CreateErr: tc.createErr}
+			b := &fallbackBackend{
+				client: igc,
+				group:  &InstanceGroup{Project: "p", Zone: "us-central1-a", Name: "runners"},
+			}
+
+			instance, err := b.Provision(t.Context(), &RunnerSpec{RunnerName: "GCP-2", Build: tc.build})
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := instance, tc.wantInstance; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if diff := cmp.Diff(tc.wantMetadata, igc.Created[tc.wantInstance]); diff != "" {
+				t.Errorf("unexpected metadata (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
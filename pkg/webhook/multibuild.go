@@ -0,0 +1,248 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxMultiBuildWindow bounds how long runners wait for each other, so the
+// deliveries requesting them are answered before GitHub gives up on them.
+const maxMultiBuildWindow = 5 * time.Second
+
+// multiBuildKey identifies the runners that can share a build: those of the
+// same workflow run, in the same project and location, with the same build
+// options and service account.
+type multiBuildKey struct {
+	runID          int64
+	projectID      string
+	location       string
+	serviceAccount string
+	pool           string
+	machineType    cloudbuildpb.BuildOptions_MachineType
+	diskSizeGB     int64
+}
+
+// multiBuildResult is the outcome of provisioning a multi-runner build.
+type multiBuildResult struct {
+	id  string
+	err error
+}
+
+// pendingMultiBuild collects the runners of a multi-runner build until its
+// window elapses or it is full.
+type pendingMultiBuild struct {
+	specs   []*RunnerSpec
+	results []chan multiBuildResult
+	timer   *time.Timer
+}
+
+// multiBuilder launches the runners of matrix jobs, which are queued together,
+// in one Cloud Build build with a parallel run step per runner, reducing the
+// overhead of creating a build per runner. Runners requested within window of
+// the first one of their workflow run share its build, up to maxRunners.
+type multiBuilder struct {
+	window     time.Duration
+	maxRunners int
+
+	mu      sync.Mutex
+	pending map[multiBuildKey]*pendingMultiBuild
+}
+
+func newMultiBuilder(window time.Duration, maxRunners int) *multiBuilder {
+	return &multiBuilder{
+		window:     window,
+		maxRunners: maxRunners,
+		pending:    make(map[multiBuildKey]*pendingMultiBuild),
+	}
+}
+
+// provision adds the runner to the pending build of its workflow run, starting
+// one when there is none, and returns the ID of the build once it was
+// provisioned by backend.
+func (m *multiBuilder) provision(ctx context.Context, backend RunnerBackend, runID int64, spec *RunnerSpec) (string, error) {
+	key := multiBuildKey{
+		runID:          runID,
+		projectID:      spec.ProjectID,
+		location:       spec.Location,
+		serviceAccount: spec.Build.GetServiceAccount(),
+		pool:           spec.Build.GetOptions().GetPool().GetName(),
+		machineType:    spec.Build.GetOptions().GetMachineType(),
+		diskSizeGB:     spec.Build.GetOptions().GetDiskSizeGb(),
+	}
+	result := make(chan multiBuildResult, 1)
+
+	m.mu.Lock()
+	p, ok := m.pending[key]
+	if !ok {
+		p = &pendingMultiBuild{}
+		m.pending[key] = p
+		// The build outlives the request of the runner that started it.
+		bctx := context.WithoutCancel(ctx)
+		p.timer = time.AfterFunc(m.window, func() {
+			m.flush(bctx, backend, key, p)
+		})
+	}
+	p.specs = append(p.specs, spec)
+	p.results = append(p.results, result)
+	// A full build takes no more runners. It is flushed here unless its
+	// window elapsed concurrently.
+	flush := false
+	if len(p.specs) >= m.maxRunners {
+		delete(m.pending, key)
+		flush = p.timer.Stop()
+	}
+	m.mu.Unlock()
+
+	if flush {
+		m.flush(context.WithoutCancel(ctx), backend, key, p)
+	}
+
+	select {
+	case r := <-result:
+		return r.id, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to wait for multi-runner build: %w", context.Cause(ctx))
+	}
+}
+
+// provisionInBackend provisions the runner in backend, in the multi-runner
+// build of its workflow run when runners are launched together.
+func (s *Server) provisionInBackend(ctx context.Context, backendName string, backend RunnerBackend, req *runnerRequest, spec *RunnerSpec) (string, error) {
+	runID := req.Job.GetWorkflowJob().GetRunID()
+	if s.multiBuilder == nil || backendName != runnerBackendCloudBuild || runID == 0 {
+		return backend.Provision(ctx, spec)
+	}
+	return s.multiBuilder.provision(ctx, backend, runID, spec)
+}
+
+// flush provisions the pending build and reports its ID to its runners.
+func (m *multiBuilder) flush(ctx context.Context, backend RunnerBackend, key multiBuildKey, p *pendingMultiBuild) {
+	m.mu.Lock()
+	if m.pending[key] == p {
+		delete(m.pending, key)
+	}
+	m.mu.Unlock()
+
+	var id string
+	var err error
+	if len(p.specs) == 1 {
+		id, err = backend.Provision(ctx, p.specs[0])
+	} else {
+		spec := *p.specs[0]
+		spec.Build = mergeRunnerBuilds(p.specs)
+		id, err = backend.Provision(ctx, &spec)
+		if err == nil {
+			logging.FromContext(ctx).InfoContext(ctx, "launched runners in one build",
+				"backend_id", id, "run_id", key.runID, "build_runners", len(p.specs))
+		}
+	}
+	for _, result := range p.results {
+		result <- multiBuildResult{id: id, err: err}
+	}
+}
+
+// mergeRunnerBuilds returns a build running the steps of the builds of specs
+// in parallel. The substitutions of each build are expanded in its steps, and
+// its step IDs prefixed with the name of its runner. The options, service
+// account, logs bucket, secrets and tags of the first build are kept, and the
+// longest timeout.
+func mergeRunnerBuilds(specs []*RunnerSpec) *cloudbuildpb.Build {
+	first := specs[0].Build
+	merged := &cloudbuildpb.Build{
+		ServiceAccount:   first.GetServiceAccount(),
+		Options:          proto.Clone(first.GetOptions()).(*cloudbuildpb.BuildOptions),
+		Tags:             first.GetTags(),
+		LogsBucket:       first.GetLogsBucket(),
+		AvailableSecrets: first.GetAvailableSecrets(),
+		Timeout:          first.GetTimeout(),
+	}
+	for _, spec := range specs {
+		if spec.Build.GetTimeout().AsDuration() > merged.GetTimeout().AsDuration() {
+			merged.Timeout = spec.Build.GetTimeout()
+		}
+		merged.Steps = append(merged.Steps, runnerSteps(spec)...)
+	}
+	return merged
+}
+
+// runnerSteps returns the steps of the build of spec with its substitutions
+// expanded and their IDs prefixed with the name of the runner. The first step
+// starts with the build, and each step without dependencies waits for the
+// previous step of the runner only, as it did in its own build.
+func runnerSteps(spec *RunnerSpec) []*cloudbuildpb.BuildStep {
+	subs := spec.Build.GetSubstitutions()
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			if v, ok := subs[key]; ok {
+				return v
+			}
+			return "$" + key
+		})
+	}
+	stepID := func(i int, step *cloudbuildpb.BuildStep) string {
+		id := step.GetId()
+		if id == "" {
+			id = fmt.Sprintf("step-%d", i)
+		}
+		return spec.RunnerName + "-" + id
+	}
+
+	ids := make(map[string]string, len(spec.Build.GetSteps()))
+	for i, step := range spec.Build.GetSteps() {
+		if step.GetId() != "" {
+			ids[step.GetId()] = stepID(i, step)
+		}
+	}
+
+	steps := make([]*cloudbuildpb.BuildStep, 0, len(spec.Build.GetSteps()))
+	for i, step := range spec.Build.GetSteps() {
+		step = proto.Clone(step).(*cloudbuildpb.BuildStep)
+		step.Id = stepID(i, step)
+		step.Name = expand(step.GetName())
+		step.Entrypoint = expand(step.GetEntrypoint())
+		step.Dir = expand(step.GetDir())
+		step.Script = expand(step.GetScript())
+		for j := range step.Args {
+			step.Args[j] = expand(step.Args[j])
+		}
+		for j := range step.Env {
+			step.Env[j] = expand(step.Env[j])
+		}
+
+		switch {
+		case len(step.GetWaitFor()) > 0:
+			for j, dep := range step.WaitFor {
+				if id, ok := ids[dep]; ok {
+					step.WaitFor[j] = id
+				}
+			}
+		case i == 0:
+			step.WaitFor = []string{"-"}
+		default:
+			step.WaitFor = []string{steps[i-1].GetId()}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
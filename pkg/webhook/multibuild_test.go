@@ -0,0 +1,140 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeRunnerBuilds(t *testing.T) {
+	t.Parallel()
+
+	runnerSpec := func(name, jitConfig string, timeout time.Duration) *RunnerSpec {
+		return &RunnerSpec{
+			RunnerName: name,
+			Build: &cloudbuildpb.Build{
+				ServiceAccount: "runner@example.iam.gserviceaccount.com",
+				Timeout:        durationpb.New(timeout),
+				Steps: []*cloudbuildpb.BuildStep{
+					{Name: "gcr.io/cloud-builders/gsutil", Args: []string{"cp", "$_CACHE", "/cache"}},
+					{Id: "run", Env: []string{"ENCODED_JIT_CONFIG=$_ENCODED_JIT_CONFIG"}, Args: []string{"$PROJECT_ID"}},
+					{Id: "save", WaitFor: []string{"run"}},
+				},
+				Substitutions: map[string]string{"_ENCODED_JIT_CONFIG": jitConfig, "_CACHE": "gs://cache"},
+			},
+		}
+	}
+
+	merged := mergeRunnerBuilds([]*RunnerSpec{
+		runnerSpec("GCP-1", "jit-1", time.Hour),
+		runnerSpec("GCP-2", "jit-2", 2*time.Hour),
+	})
+
+	if got, want := merged.GetTimeout().AsDuration(), 2*time.Hour; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := merged.GetServiceAccount(), "runner@example.iam.gserviceaccount.com"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got := merged.GetSubstitutions(); len(got) > 0 {
+		t.Errorf("expected no substitutions, got %v", got)
+	}
+
+	type step struct {
+		ID      string
+		WaitFor []string
+		Args    []string
+		Env     []string
+	}
+	var got []step
+	for _, s := range merged.GetSteps() {
+		got = append(got, step{s.GetId(), s.GetWaitFor(), s.GetArgs(), s.GetEnv()})
+	}
+	want := []step{
+		{"GCP-1-step-0", []string{"-"}, []string{"cp", "gs://cache", "/cache"}, nil},
+		{"GCP-1-run", []string{"GCP-1-step-0"}, []string{"$PROJECT_ID"}, []string{"ENCODED_JIT_CONFIG=jit-1"}},
+		{"GCP-1-save", []string{"GCP-1-run"}, nil, nil},
+		{"GCP-2-step-0", []string{"-"}, []string{"cp", "gs://cache", "/cache"}, nil},
+		{"GCP-2-run", []string{"GCP-2-step-0"}, []string{"$PROJECT_ID"}, []string{"ENCODED_JIT_CONFIG=jit-2"}},
+		{"GCP-2-save", []string{"GCP-2-run"}, nil, nil},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("steps (-want,+got):\n%s", diff)
+	}
+}
+
+func TestMultiBuilder(t *testing.T) {
+	t.Parallel()
+
+	cbc := &MockCloudBuildClient{createBuildRes: &cloudbuildpb.Build{Id: "build-1"}}
+	backend := &cloudBuildBackend{cbc: cbc}
+	// The window is long enough that the build is only created once full.
+	m := newMultiBuilder(time.Minute, 3)
+
+	ids := make([]string, 3)
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := m.provision(t.Context(), backend, 7, &RunnerSpec{
+				RunnerName: "GCP-" + string(rune('a'+i)),
+				ProjectID:  "runner-project",
+				Location:   "us-central1",
+				Build: &cloudbuildpb.Build{
+					Steps: []*cloudbuildpb.BuildStep{{Id: "run"}},
+				},
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			ids[i] = id
+		}()
+	}
+	wg.Wait()
+
+	if got, want := len(cbc.createBuildReqs), 1; got != want {
+		t.Fatalf("expected %d builds to be created, got %d", want, got)
+	}
+	if got, want := len(cbc.createBuildReq.GetBuild().GetSteps()), 3; got != want {
+		t.Errorf("expected %d steps, got %d", want, got)
+	}
+	for _, id := range ids {
+		if got, want := id, "projects/runner-project/locations/us-central1/builds/build-1"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	}
+
+	// A runner alone in its window gets its own build, unchanged.
+	m = newMultiBuilder(time.Millisecond, 3)
+	if _, err := m.provision(t.Context(), backend, 8, &RunnerSpec{
+		RunnerName: "GCP-d",
+		ProjectID:  "runner-project",
+		Location:   "us-central1",
+		Build:      &cloudbuildpb.Build{Steps: []*cloudbuildpb.BuildStep{{Id: "run"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cbc.createBuildReq.GetBuild().GetSteps()[0].GetId(), "run"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+// verifyPrivateWorkerPools checks that every configured worker pool is peered
+// with a VPC network and has no public egress. Cloud Build configures these on
+// the pool, not on the build, so a misconfigured pool would silently give
+// runners access to the public internet.
+func verifyPrivateWorkerPools(ctx context.Context, cbc CloudBuildClient, defaultPool string, pools map[string]string) error {
+	names := []string{defaultPool}
+	for _, name := range slices.Sorted(maps.Keys(pools)) {
+		names = append(names, pools[name])
+	}
+
+	for _, name := range names {
+		pool, err := cbc.GetWorkerPool(ctx, &cloudbuildpb.GetWorkerPoolRequest{Name: name})
+		if err != nil {
+			return fmt.Errorf("failed to get worker pool %q: %w", name, err)
+		}
+
+		network := pool.GetPrivatePoolV1Config().GetNetworkConfig()
+		if network.GetPeeredNetwork() == "" {
+			return fmt.Errorf("worker pool %q is not peered with a VPC network", name)
+		}
+		if got, want := network.GetEgressOption(), cloudbuildpb.PrivatePoolV1Config_NetworkConfig_NO_PUBLIC_EGRESS; got != want {
+			return fmt.Errorf("worker pool %q has egress option %s, expected %s", name, got, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+)
+
+func TestVerifyPrivateWorkerPools(t *testing.T) {
+	t.Parallel()
+
+	privatePool := func(network string, egress cloudbuildpb.PrivatePoolV1Config_NetworkConfig_EgressOption) *cloudbuildpb.WorkerPool {
+		return &cloudbuildpb.WorkerPool{
+			Config: &cloudbuildpb.WorkerPool_PrivatePoolV1Config{
+				PrivatePoolV1Config: &cloudbuildpb.PrivatePoolV1Config{
+					NetworkConfig: &cloudbuildpb.PrivatePoolV1Config_NetworkConfig{
+						PeeredNetwork: network,
+						EgressOption:  egress,
+					},
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name    string
+		pools   map[string]*cloudbuildpb.WorkerPool
+		wantErr string
+	}{
+		{
+			name: "private",
+			pools: map[string]*cloudbuildpb.WorkerPool{
+				"default": privatePool("projects/p/global/networks/ci", cloudbuildpb.PrivatePoolV1Config_NetworkConfig_NO_PUBLIC_EGRESS),
+				"xl":      privatePool("projects/p/global/networks/ci", cloudbuildpb.PrivatePoolV1Config_NetworkConfig_NO_PUBLIC_EGRESS),
+			},
+		},
+		{
+			name: "public_egress",
+			pools: map[string]*cloudbuildpb.WorkerPool{
+				"default": privatePool("projects/p/global/networks/ci", cloudbuildpb.PrivatePoolV1Config_NetworkConfig_NO_PUBLIC_EGRESS),
+				"xl":      privatePool("projects/p/global/networks/ci", cloudbuildpb.PrivatePoolV1Config_NetworkConfig_PUBLIC_EGRESS),
+			},
+			wantErr: `worker pool "xl" has egress option PUBLIC_EGRESS`,
+		},
+		{
+			name: "not_peered",
+			pools: map[string]*cloudbuildpb.WorkerPool{
+				"default": privatePool("", cloudbuildpb.PrivatePoolV1Config_NetworkConfig_NO_PUBLIC_EGRESS),
+			},
+			wantErr: `worker pool "default" is not peered`,
+		},
+		{
+			name:    "missing",
+			wantErr: `failed to get worker pool "default"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cbc := &MockCloudBuildClient{workerPools: tc.pools}
+			err := verifyPrivateWorkerPools(t.Context(), cbc, "default", map[string]string{"private-xl": "xl"})
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
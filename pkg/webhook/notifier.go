@@ -0,0 +1,137 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// runnerBudgetWarningFraction is the fraction of the runner limit of the
+// instance at which operators are warned that the fleet is approaching it.
+const runnerBudgetWarningFraction = 0.8
+
+// NotificationKind categorizes operator notifications.
+type NotificationKind string
+
+const (
+	// NotificationDispatchFailure reports that a runner could not be
+	// provisioned for a queued job.
+	NotificationDispatchFailure NotificationKind = "dispatch_failure"
+
+	// NotificationBudgetWarning reports that the runners are approaching the
+	// runner limit of the instance.
+	NotificationBudgetWarning NotificationKind = "budget_warning"
+
+	// NotificationReconciliationSummary reports the outcome of a
+	// reconciliation pass.
+	NotificationReconciliationSummary NotificationKind = "reconciliation_summary"
+
+	// NotificationRunnerStalled reports that a runner stopped producing
+	// output while its job is in progress.
+	NotificationRunnerStalled NotificationKind = "runner_stalled"
+
+	// NotificationRunnerExpired reports that a runner was cancelled for
+	// exceeding the maximum runner lifetime.
+	NotificationRunnerExpired NotificationKind = "runner_expired"
+)
+
+// Notification is a human readable message for operators.
+type Notification struct {
+	Kind  NotificationKind
+	Title string
+	Text  string
+}
+
+// Notifier delivers operator notifications to an external channel.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// notify sends the notification if a notifier is configured. Failures are
+// logged, notifications never fail the request that triggered them.
+func (s *Server) notify(ctx context.Context, n *Notification) {
+	if s.notifier == nil {
+		return
+	}
+
+	if err := s.notifier.Notify(ctx, n); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to send notification",
+			"notification_kind", n.Kind,
+			"error", err)
+	}
+}
+
+// notifyDispatchFailure reports a failure to provision a runner.
+func (s *Server) notifyDispatchFailure(ctx context.Context, req *runnerRequest, reason string, err error) {
+	text := fmt.Sprintf("Runner %s: %s: %v", req.RunnerName, reason, err)
+	if job := req.Job.GetWorkflowJob(); job != nil {
+		text = fmt.Sprintf("Job %q (run %d, job %d): %s: %v",
+			job.GetName(), job.GetRunID(), job.GetID(), reason, err)
+	}
+
+	s.notify(ctx, &Notification{
+		Kind:  NotificationDispatchFailure,
+		Title: fmt.Sprintf("Runner dispatch failed for %s/%s", req.Org, req.Repo),
+		Text:  text,
+	})
+}
+
+// checkRunnerBudget warns operators once the runners of this instance reach
+// runnerBudgetWarningFraction of its runner limit, and not again until they
+// fell back under it.
+func (s *Server) checkRunnerBudget(ctx context.Context) {
+	limit := s.settings.Get().MaxRunners
+	if limit <= 0 {
+		return
+	}
+
+	count := s.runners.Count()
+	if float64(count) < runnerBudgetWarningFraction*float64(limit) {
+		s.notifierBudgetWarned.Store(false)
+		return
+	}
+	if s.notifierBudgetWarned.Swap(true) {
+		return
+	}
+	s.notify(ctx, &Notification{
+		Kind:  NotificationBudgetWarning,
+		Title: "Runners approaching the runner limit",
+		Text:  fmt.Sprintf("%d runners of the limit of %d are active, runners over the limit are not provisioned.", count, limit),
+	})
+}
+
+// notifyReconciliation reports a reconciliation pass that cleaned up orphans
+// or failed to reconcile some of them. Passes with nothing to report are not
+// notified.
+func (s *Server) notifyReconciliation(ctx context.Context, result *reconcileResult) {
+	if len(result.CancelledBuilds) == 0 && len(result.RemovedRunners) == 0 && len(result.Errors) == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("Cancelled %d builds without a runner, removed %d runners without a build.",
+		len(result.CancelledBuilds), len(result.RemovedRunners))
+	if len(result.Errors) > 0 {
+		text += fmt.Sprintf(" Failed to reconcile: %s.", strings.Join(result.Errors, "; "))
+	}
+	s.notify(ctx, &Notification{
+		Kind:  NotificationReconciliationSummary,
+		Title: "Orphaned runners reconciled",
+		Text:  text,
+	})
+}
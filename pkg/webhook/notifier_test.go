@@ -0,0 +1,132 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckRunnerBudget(t *testing.T) {
+	t.Parallel()
+
+	notifier := &recordingNotifier{}
+	s := &Server{
+		notifier: notifier,
+		runners:  newRunnerTracker(),
+		settings: &sharedSettings{current: &SharedSettings{MaxRunners: 5}},
+	}
+	dispatch := func(name string) {
+		t.Helper()
+		s.runners.Dispatched(&trackedRunner{RunnerName: name})
+		s.checkRunnerBudget(t.Context())
+	}
+
+	// Warned once on reaching 80% of the limit, not again above it.
+	for _, name := range []string{"GCP-1", "GCP-2", "GCP-3", "GCP-4", "GCP-5"} {
+		dispatch(name)
+	}
+	if got, want := len(notifier.notifications), 1; got != want {
+		t.Fatalf("expected %d notifications, got %d", want, got)
+	}
+	want := &Notification{
+		Kind:  NotificationBudgetWarning,
+		Title: "Runners approaching the runner limit",
+		Text:  "4 runners of the limit of 5 are active, runners over the limit are not provisioned.",
+	}
+	if diff := cmp.Diff(want, notifier.notifications[0]); diff != "" {
+		t.Errorf("unexpected notification (-want, +got):\n%s", diff)
+	}
+
+	// Warned again once the runners fell back under it.
+	for _, name := range []string{"GCP-3", "GCP-4", "GCP-5"} {
+		s.runners.Remove(name)
+	}
+	dispatch("GCP-6")
+	dispatch("GCP-7")
+	dispatch("GCP-8")
+	if got, want := len(notifier.notifications), 2; got != want {
+		t.Errorf("expected %d notifications, got %d", want, got)
+	}
+}
+
+func TestCheckRunnerBudget_Unlimited(t *testing.T) {
+	t.Parallel()
+
+	notifier := &recordingNotifier{}
+	s := &Server{notifier: notifier, runners: newRunnerTracker()}
+	s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-1"})
+	s.checkRunnerBudget(t.Context())
+
+	if got := len(notifier.notifications); got != 0 {
+		t.Errorf("expected no notifications, got %d", got)
+	}
+}
+
+func TestNotifyReconciliation(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		result *reconcileResult
+		want   []*Notification
+	}{
+		{
+			name:   "nothing",
+			result: &reconcileResult{CancelledBuilds: []string{}, RemovedRunners: []string{}, Suspects: 2},
+		},
+		{
+			name: "cleaned_up",
+			result: &reconcileResult{
+				CancelledBuilds: []string{"gone"},
+				RemovedRunners:  []string{"GCP-dead", "GCP-lost"},
+			},
+			want: []*Notification{{
+				Kind:  NotificationReconciliationSummary,
+				Title: "Orphaned runners reconciled",
+				Text:  "Cancelled 1 builds without a runner, removed 2 runners without a build.",
+			}},
+		},
+		{
+			name: "errors",
+			result: &reconcileResult{
+				CancelledBuilds: []string{},
+				RemovedRunners:  []string{},
+				Errors:          []string{"google/webhook: forbidden"},
+			},
+			want: []*Notification{{
+				Kind:  NotificationReconciliationSummary,
+				Title: "Orphaned runners reconciled",
+				Text: "Cancelled 0 builds without a runner, removed 0 runners without a build. " +
+					"Failed to reconcile: google/webhook: forbidden.",
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			notifier := &recordingNotifier{}
+			s := &Server{notifier: notifier}
+			s.notifyReconciliation(t.Context(), tc.result)
+
+			if diff := cmp.Diff(tc.want, notifier.notifications); diff != "" {
+				t.Errorf("unexpected notifications (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
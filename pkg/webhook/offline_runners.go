@@ -0,0 +1,230 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/go-github/v69/github"
+)
+
+// offlineRunners remembers since when the runners of this service were seen
+// offline, GitHub does not report it.
+type offlineRunners struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newOfflineRunners() *offlineRunners {
+	return &offlineRunners{since: make(map[string]time.Time)}
+}
+
+// offlineFor records the runner as offline at now and returns for how long
+// it was seen offline.
+func (o *offlineRunners) offlineFor(key string, now time.Time) time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	since, ok := o.since[key]
+	if !ok {
+		o.since[key] = now
+		return 0
+	}
+	return now.Sub(since)
+}
+
+// retain forgets the runners not in seen, which came back online or were
+// removed.
+func (o *offlineRunners) retain(seen map[string]struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for key := range o.since {
+		if _, ok := seen[key]; !ok {
+			delete(o.since, key)
+		}
+	}
+}
+
+// runOfflineRunnerCleanup removes offline runners until ctx is done.
+func (s *Server) runOfflineRunnerCleanup(ctx context.Context) {
+	ticker := time.NewTicker(s.offlineCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanupOfflineRunners(ctx, time.Now())
+		}
+	}
+}
+
+// cleanupOfflineRunners removes the runners of this service, named with the
+// runner name prefix, seen offline for longer than the offline threshold.
+// Ephemeral runners that crashed before deregistering otherwise pile up. It
+// covers the organization runners of every organization the app is installed
+// in and the repository runners of the repositories with tracked runners. It
+// returns the number of runners removed.
+func (s *Server) cleanupOfflineRunners(ctx context.Context, now time.Time) int {
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return 0
+	}
+
+	logger := logging.FromContext(ctx)
+	seen := make(map[string]struct{})
+	removed := 0
+
+	sweep := func(scope string, runners []*github.Runner, remove func(ctx context.Context, id int64) error) {
+		for _, r := range runners {
+			if !strings.HasPrefix(r.GetName(), runnerNamePrefix) || r.GetStatus() != "offline" || r.GetBusy() {
+				continue
+			}
+			key := fmt.Sprintf("%s/%d", scope, r.GetID())
+			seen[key] = struct{}{}
+			offline := s.offlineRunners.offlineFor(key, now)
+			if offline < s.offlineThreshold {
+				continue
+			}
+
+			fields := []any{"scope", scope, "runner_id", r.GetName(), "gh_runner_id", r.GetID(), "offline_for", offline.String()}
+			rctx, cancel := callContext(ctx, s.githubCallTimeout)
+			err := remove(rctx, r.GetID())
+			cancel()
+			if err != nil {
+				logger.WarnContext(ctx, "failed to remove offline runner", append(fields, "error", err)...)
+				continue
+			}
+			delete(seen, key)
+			s.metrics.recordOfflineRunnerRemoved()
+			removed++
+			logger.InfoContext(ctx, "removed offline runner", fields...)
+		}
+	}
+
+	orgs, err := s.installationOrgs(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to list app installations to clean up offline runners", "error", err)
+	}
+	for org, installationID := range orgs {
+		gh, err := s.installationClient(ctx, installationID, map[string]string{
+			"organization_self_hosted_runners": "write",
+		})
+		if err != nil {
+			logger.WarnContext(ctx, "failed to setup installation client to clean up offline runners", "org", org, "error", err)
+			continue
+		}
+		runners, err := s.listOrgRunners(ctx, gh, org)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to list organization runners", "org", org, "error", err)
+			continue
+		}
+		sweep(org, runners, func(ctx context.Context, id int64) error {
+			_, err := gh.Actions.RemoveOrganizationRunner(ctx, org, id)
+			return err //nolint:wrapcheck // Logged by sweep.
+		})
+	}
+
+	repos := make(map[runnerScope]struct{})
+	for _, r := range s.runners.List() {
+		if r.InstallationID != 0 && r.Org != "" && r.Repo != "" {
+			repos[runnerScope{InstallationID: r.InstallationID, Org: r.Org, Repo: r.Repo}] = struct{}{}
+		}
+	}
+	for scope := range repos {
+		gh, err := s.installationClient(ctx, scope.InstallationID, map[string]string{
+			"administration": "write",
+		}, scope.Repo)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to setup installation client to clean up offline runners",
+				"org", scope.Org, "repo", scope.Repo, "error", err)
+			continue
+		}
+		registered, err := s.listRepoRunners(ctx, gh, scope.Org, scope.Repo)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to list repository runners", "org", scope.Org, "repo", scope.Repo, "error", err)
+			continue
+		}
+		runners := make([]*github.Runner, 0, len(registered))
+		for _, r := range registered {
+			runners = append(runners, r)
+		}
+		sweep(scope.Org+"/"+scope.Repo, runners, func(ctx context.Context, id int64) error {
+			_, err := gh.Actions.RemoveRunner(ctx, scope.Org, scope.Repo, id)
+			return err //nolint:wrapcheck // Logged by sweep.
+		})
+	}
+
+	s.offlineRunners.retain(seen)
+	return removed
+}
+
+// installationOrgs returns the installations of the app in organizations, by
+// organization login.
+func (s *Server) installationOrgs(ctx context.Context) (map[string]int64, error) {
+	gh, err := s.appGitHubClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make(map[string]int64)
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		lctx, cancel := callContext(ctx, s.githubCallTimeout)
+		installations, resp, err := gh.Apps.ListInstallations(lctx, opts)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list installations: %w", err)
+		}
+
+		for _, i := range installations {
+			if i.GetAccount().GetType() == "Organization" && i.SuspendedAt == nil {
+				orgs[i.GetAccount().GetLogin()] = i.GetID()
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return orgs, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listOrgRunners returns the runners registered with the organization.
+func (s *Server) listOrgRunners(ctx context.Context, gh *github.Client, org string) ([]*github.Runner, error) {
+	var runners []*github.Runner
+	opts := &github.ListRunnersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		lctx, cancel := callContext(ctx, s.githubCallTimeout)
+		page, resp, err := gh.Actions.ListOrganizationRunners(lctx, org, opts)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organization runners: %w", err)
+		}
+		runners = append(runners, page.Runners...)
+
+		if resp.NextPage == 0 {
+			return runners, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
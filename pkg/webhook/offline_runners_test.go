@@ -0,0 +1,132 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/githubauth"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCleanupOfflineRunners(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var removed []string
+	remove := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[
+			{"id": 123, "account": {"login": "google", "type": "Organization"}},
+			{"id": 456, "account": {"login": "octocat", "type": "User"}}
+		]`)
+	}))
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /orgs/google/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 4, "runners": [
+			{"id": 1, "name": "GCP-crashed", "status": "offline", "busy": false},
+			{"id": 2, "name": "GCP-online", "status": "online", "busy": false},
+			{"id": 3, "name": "GCP-busy", "status": "offline", "busy": true},
+			{"id": 4, "name": "laptop", "status": "offline", "busy": false}
+		]}`)
+	}))
+	mux.Handle("DELETE /orgs/google/actions/runners/{id}", http.HandlerFunc(remove))
+	mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 1, "runners": [
+			{"id": 5, "name": "GCP-repo", "status": "offline", "busy": false}
+		]}`)
+	}))
+	mux.Handle("DELETE /repos/google/webhook/actions/runners/{id}", http.HandlerFunc(remove))
+	fakeGitHub := httptest.NewServer(mux)
+	t.Cleanup(fakeGitHub.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		appClient:        app,
+		ghAPIBaseURL:     fakeGitHub.URL,
+		offlineRunners:   newOfflineRunners(),
+		offlineThreshold: 30 * time.Minute,
+		runners:          newRunnerTracker(),
+	}
+	s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-other", InstallationID: 123, Org: "google", Repo: "webhook"})
+
+	now := time.Now()
+	if got, want := s.cleanupOfflineRunners(t.Context(), now), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := s.cleanupOfflineRunners(t.Context(), now.Add(29*time.Minute)), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := s.cleanupOfflineRunners(t.Context(), now.Add(30*time.Minute)), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(removed)
+	want := []string{"/orgs/google/actions/runners/1", "/repos/google/webhook/actions/runners/5"}
+	if diff := cmp.Diff(want, removed); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+}
+
+func TestOfflineRunners(t *testing.T) {
+	t.Parallel()
+
+	o := newOfflineRunners()
+	now := time.Now()
+	if got, want := o.offlineFor("google/1", now), time.Duration(0); got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := o.offlineFor("google/1", now.Add(time.Hour)), time.Hour; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+
+	// A runner that came back online starts over.
+	o.retain(map[string]struct{}{})
+	if got, want := o.offlineFor("google/1", now.Add(2*time.Hour)), time.Duration(0); got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultPagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends alerts through the PagerDuty Events API v2.
+type PagerDuty struct {
+	routingKey string
+	eventsURL  string
+	httpClient *http.Client
+}
+
+// NewPagerDuty creates a PagerDuty client for the integration routing key.
+func NewPagerDuty(routingKey string) *PagerDuty {
+	return &PagerDuty{
+		routingKey: routingKey,
+		eventsURL:  defaultPagerDutyEventsURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Trigger opens (or updates) the incident identified by dedupKey.
+func (p *PagerDuty) Trigger(ctx context.Context, dedupKey, summary string, details map[string]string) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: &pagerDutyPayload{
+			Summary:       summary,
+			Source:        "github-actions-on-gcp",
+			Severity:      "critical",
+			CustomDetails: details,
+		},
+	})
+}
+
+// Resolve closes the incident identified by dedupKey.
+func (p *PagerDuty) Resolve(ctx context.Context, dedupKey string) error {
+	return p.send(ctx, &pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "resolve",
+		DedupKey:    dedupKey,
+	})
+}
+
+func (p *PagerDuty) send(ctx context.Context, event *pagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pagerduty event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.eventsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create pagerduty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send pagerduty event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected pagerduty response status %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// Error codes returned in the response body of rejected deliveries.
+const (
+	payloadErrMalformed    = "malformed_payload"
+	payloadErrMissingField = "missing_field"
+	payloadErrInvalidField = "invalid_field"
+)
+
+// maxJobLabels bounds the labels accepted on a job. GitHub does not allow
+// more than a handful of labels in runs-on, anything above is hostile.
+const maxJobLabels = 64
+
+// payloadError describes why a delivery was rejected.
+type payloadError struct {
+	// Code is one of the payloadErr* codes.
+	Code string
+
+	// Field is the JSON path of the offending field, if any.
+	Field string
+}
+
+func (e *payloadError) Error() string {
+	if e.Field == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Field)
+}
+
+// response returns the 400 response rejecting the delivery.
+func (e *payloadError) response(cause error) *apiResponse {
+	if cause == nil {
+		cause = e
+	}
+	return &apiResponse{http.StatusBadRequest, "invalid payload: " + e.Error(), cause}
+}
+
+// parseError classifies an error returned by github.ParseWebHook. For known
+// event types the payload failed to decode. It returns nil for unknown event
+// types.
+func parseError(eventType string) *payloadError {
+	if github.EventForType(eventType) == nil {
+		return nil
+	}
+	return &payloadError{Code: payloadErrMalformed}
+}
+
+// validateWorkflowJobEvent checks the fields processRequest relies on up front.
+// The fields needed to process the action are always required. In strict mode
+// all fields GitHub documents for the action are required as well.
+func validateWorkflowJobEvent(event *github.WorkflowJobEvent, strict bool) *payloadError {
+	missing := func(field string) *payloadError {
+		return &payloadError{Code: payloadErrMissingField, Field: field}
+	}
+	invalid := func(field string) *payloadError {
+		return &payloadError{Code: payloadErrInvalidField, Field: field}
+	}
+
+	if event.Action == nil {
+		if strict {
+			return missing("action")
+		}
+		return nil
+	}
+
+	job := event.WorkflowJob
+	switch {
+	case job == nil:
+		return missing("workflow_job")
+	case job.ID == nil:
+		return missing("workflow_job.id")
+	case job.RunID == nil:
+		return missing("workflow_job.run_id")
+	case len(job.Labels) > maxJobLabels:
+		return invalid("workflow_job.labels")
+	}
+
+	if event.GetAction() == "queued" {
+		switch {
+		case event.Installation == nil || event.Installation.ID == nil:
+			return missing("installation.id")
+		case event.Org == nil || event.Org.Login == nil:
+			return missing("organization.login")
+		case event.Repo == nil || event.Repo.Name == nil:
+			return missing("repository.name")
+		}
+	}
+
+	if !strict {
+		return nil
+	}
+
+	switch {
+	case job.GetID() <= 0:
+		return invalid("workflow_job.id")
+	case job.GetRunID() <= 0:
+		return invalid("workflow_job.run_id")
+	case job.Name == nil:
+		return missing("workflow_job.name")
+	case job.HeadSHA == nil:
+		return missing("workflow_job.head_sha")
+	case job.Labels == nil:
+		return missing("workflow_job.labels")
+	case job.CreatedAt == nil:
+		return missing("workflow_job.created_at")
+	}
+	for _, label := range job.Labels {
+		if label == "" {
+			return invalid("workflow_job.labels")
+		}
+	}
+
+	switch event.GetAction() {
+	case "queued":
+		if event.GetInstallation().GetID() <= 0 {
+			return invalid("installation.id")
+		}
+	case "in_progress":
+		switch {
+		case job.RunnerName == nil:
+			return missing("workflow_job.runner_name")
+		case job.StartedAt == nil:
+			return missing("workflow_job.started_at")
+		}
+	case "completed":
+		if job.CompletedAt == nil {
+			return missing("workflow_job.completed_at")
+		}
+	}
+	return nil
+}
+
+// workflowLogFields returns the log fields describing the workflow a job
+// belongs to and what triggered it, so jobs can be triaged without looking up
+// their numeric IDs.
+func workflowLogFields(event *github.WorkflowJobEvent) []any {
+	job := event.GetWorkflowJob()
+	return []any{
+		"gh_event", "workflow_job",
+		"gh_workflow_name", job.GetWorkflowName(),
+		"gh_head_branch", job.GetHeadBranch(),
+		"gh_head_sha", job.GetHeadSHA(),
+		"gh_actor", event.GetSender().GetLogin(),
+	}
+}
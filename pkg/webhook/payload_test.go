@@ -0,0 +1,161 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestValidateWorkflowJobEvent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		payload string
+		strict  bool
+		want    string
+	}{
+		{
+			name:    "nil_action",
+			payload: `{"workflow_job": {"id": 1, "run_id": 2}}`,
+		},
+		{
+			name:    "nil_action_strict",
+			payload: `{"workflow_job": {"id": 1, "run_id": 2}}`,
+			strict:  true,
+			want:    "missing_field: action",
+		},
+		{
+			name:    "missing_job",
+			payload: `{"action": "in_progress"}`,
+			want:    "missing_field: workflow_job",
+		},
+		{
+			name:    "missing_run_id",
+			payload: `{"action": "in_progress", "workflow_job": {"id": 1}}`,
+			want:    "missing_field: workflow_job.run_id",
+		},
+		{
+			name:    "queued_missing_installation",
+			payload: `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+			want:    "missing_field: installation.id",
+		},
+		{
+			name:    "queued",
+			payload: `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2}, "installation": {"id": 3}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+		},
+		{
+			name:    "queued_strict_missing_head_sha",
+			payload: `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "name": "build"}, "installation": {"id": 3}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+			strict:  true,
+			want:    "missing_field: workflow_job.head_sha",
+		},
+		{
+			name:    "queued_strict",
+			payload: `{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "head_sha": "abc", "labels": ["self-hosted"], "created_at": "2025-01-01T00:00:00Z"}, "installation": {"id": 3}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`,
+			strict:  true,
+		},
+		{
+			name:    "in_progress_strict_missing_runner",
+			payload: `{"action": "in_progress", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "head_sha": "abc", "labels": [], "created_at": "2025-01-01T00:00:00Z"}}`,
+			strict:  true,
+			want:    "missing_field: workflow_job.runner_name",
+		},
+		{
+			name:    "strict_empty_label",
+			payload: `{"action": "completed", "workflow_job": {"id": 1, "run_id": 2, "name": "build", "head_sha": "abc", "labels": [""], "created_at": "2025-01-01T00:00:00Z"}}`,
+			strict:  true,
+			want:    "invalid_field: workflow_job.labels",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var event github.WorkflowJobEvent
+			if err := json.Unmarshal([]byte(tc.payload), &event); err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			if perr := validateWorkflowJobEvent(&event, tc.strict); perr != nil {
+				got = perr.Error()
+			}
+			if got != tc.want {
+				t.Errorf("expected error %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessRequest_MalformedPayload(t *testing.T) {
+	t.Parallel()
+
+	resp := serveFuzzDelivery(t, "workflow_job", []byte(`{"action": "queued", "workflow_job": {"id": "not-a-number"}}`), false)
+	if got, want := resp.Code, http.StatusBadRequest; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := resp.Body.String(), "invalid payload: malformed_payload"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+// FuzzProcessRequest feeds signed deliveries to the webhook handler. Seeds are
+// in testdata/fuzz/FuzzProcessRequest.
+func FuzzProcessRequest(f *testing.F) {
+	f.Add("workflow_job", []byte(`{"action": "queued", "workflow_job": {"id": 1, "run_id": 2, "labels": ["self-hosted"]}, "installation": {"id": 3}, "organization": {"login": "google"}, "repository": {"name": "webhook"}}`), false)
+	f.Add("workflow_job", []byte(`{"action": "in_progress", "workflow_job": {"id": 1, "run_id": 2, "created_at": "2025-01-01T00:00:00Z", "started_at": "2025-01-01T00:01:00Z"}}`), true)
+	f.Add("workflow_job", []byte(`{"action": "completed", "workflow_job": null}`), false)
+	f.Add("repository_dispatch", []byte(`{"action": "provision-runner", "client_payload": {"count": 1}}`), false)
+
+	f.Fuzz(func(t *testing.T, eventType string, payload []byte, strict bool) {
+		resp := serveFuzzDelivery(t, eventType, payload, strict)
+		if eventType == "workflow_job" && resp.Code == http.StatusInternalServerError {
+			t.Errorf("workflow_job delivery %q failed with %d: %s", payload, resp.Code, resp.Body.String())
+		}
+	})
+}
+
+// serveFuzzDelivery signs and serves a delivery. Runner dispatch goes through
+// a dispatch queue that is always full, so no external calls are made.
+func serveFuzzDelivery(tb testing.TB, eventType string, payload []byte, strict bool) *httptest.ResponseRecorder {
+	tb.Helper()
+
+	secret := []byte("fuzz-secret")
+	srv := &Server{
+		dispatchEventType:  "provision-runner",
+		dispatchMaxRunners: 10,
+		dispatchQueue:      newDispatchQueue(1, 1, 1, 0),
+		runners:            newRunnerTracker(),
+		strictPayloads:     strict,
+		webhookSecret:      secret,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	req.Header.Set(EventTypeHeader, eventType)
+	req.Header.Set(ContentTypeHeader, "application/json")
+	req.Header.Set(SHA256SignatureHeader, "sha256="+createSignature(secret, payload))
+
+	resp := httptest.NewRecorder()
+	srv.handleWebhook().ServeHTTP(resp, req)
+	return resp
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"slices"
+)
+
+// runnerBuildPlacement is a project and location runner builds are created
+// in.
+type runnerBuildPlacement struct {
+	ProjectID string
+	Location  string
+}
+
+// parent returns the Cloud Build parent of the builds of the placement.
+func (p runnerBuildPlacement) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", p.ProjectID, p.Location)
+}
+
+// buildName returns the full name of the build of the placement with the
+// given ID.
+func (p runnerBuildPlacement) buildName(id string) string {
+	return fmt.Sprintf("%s/builds/%s", p.parent(), id)
+}
+
+// runnerBuildPlacements returns every project and location runner builds may
+// be created in: the runner projects, including those of the project routes,
+// crossed with the runner location, the fallback locations, and the locations
+// of the project routes and worker pools. Some of them may never hold a build,
+// listing them is cheaper than missing the builds of the others.
+func (s *Server) runnerBuildPlacements() []runnerBuildPlacement {
+	projects := []string{s.runnerProjectID}
+	if s.runnerProjects != nil {
+		projects = append(projects, s.runnerProjects.projects...)
+	}
+	locations := append([]string{s.runnerLocation}, s.runnerFallbackLocations...)
+	for _, route := range s.runnerProjectRoutes {
+		projects = append(projects, route.ProjectID)
+		if route.Location != "" {
+			locations = append(locations, route.Location)
+		}
+	}
+	pools := []string{s.runnerWorkerPoolID, s.runnerARM64WorkerPool}
+	for _, pool := range s.runnerWorkerPools {
+		pools = append(pools, pool)
+	}
+	for _, pool := range pools {
+		if location, err := workerPoolLocation(pool); err == nil {
+			locations = append(locations, location)
+		}
+	}
+
+	slices.Sort(projects)
+	slices.Sort(locations)
+	var placements []runnerBuildPlacement
+	for _, project := range slices.Compact(projects) {
+		for _, location := range slices.Compact(locations) {
+			if project != "" && location != "" {
+				placements = append(placements, runnerBuildPlacement{ProjectID: project, Location: location})
+			}
+		}
+	}
+	return placements
+}
@@ -0,0 +1,198 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/google/github_actions_on_gcp/pkg/policy"
+)
+
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+
+	// policyDefaultRule names the decision taken when no rule matches.
+	policyDefaultRule = "default"
+)
+
+// runnerDeniedMsg is the response to a runner request denied by the dispatch
+// policy.
+var runnerDeniedMsg = "no action taken for runner denied by dispatch policy"
+
+// policyVariables are the variables dispatch policy expressions can refer to:
+//
+//	event       "workflow_job" or "repository_dispatch"
+//	org, repo   the repository the runner is requested for
+//	labels      the labels of the job or requested runners
+//	actor       the login of the user that triggered the event
+//	branch      the head branch of the job
+//	workflow    the name of the workflow of the job
+//	job         the name of the job
+//	run_attempt the attempt of the workflow run, starting at 1
+//	created_at  when the job was queued, or the request was received
+//	now         when the policy is evaluated
+var policyVariables = []string{
+	"event", "org", "repo", "labels", "actor", "branch", "workflow", "job", "run_attempt", "created_at", "now",
+}
+
+// DispatchPolicy decides whether runners are provisioned and how. Rules are
+// evaluated in order and the first rule whose condition holds decides. The
+// default decision applies when no rule matches.
+type DispatchPolicy struct {
+	// Default is "allow" or "deny", it is "allow" when unset.
+	Default string `yaml:"default"`
+
+	Rules []*PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is a rule of the dispatch policy.
+type PolicyRule struct {
+	// Name identifies the rule in logs and metrics.
+	Name string `yaml:"name"`
+
+	// If is a CEL expression over the policy variables returning a bool.
+	If string `yaml:"if"`
+
+	// Decision is "allow" or "deny", it is "allow" when unset.
+	Decision string `yaml:"decision"`
+
+	// Profile is the runner profile used by allowed runners, overriding the
+	// profile selected by labels or a runner group mapping.
+	Profile string `yaml:"profile"`
+
+	// Timeout overrides the build timeout of allowed runners.
+	Timeout time.Duration `yaml:"timeout"`
+
+	program *policy.Program
+}
+
+// policyDecision is the outcome of evaluating the dispatch policy.
+type policyDecision struct {
+	Rule    string
+	Deny    bool
+	Profile string
+	Timeout time.Duration
+}
+
+// loadDispatchPolicy reads the dispatch policy file and compiles its rules.
+func loadDispatchPolicy(fr FileReader, filename string, profiles map[string]*RunnerProfile) (*DispatchPolicy, error) {
+	b, err := fr.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispatch policy: %w", err)
+	}
+
+	var p DispatchPolicy
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse dispatch policy: %w", err)
+	}
+
+	if p.Default != "" && p.Default != policyAllow && p.Default != policyDeny {
+		return nil, fmt.Errorf("dispatch policy default must be %q or %q, got %q", policyAllow, policyDeny, p.Default)
+	}
+
+	seen := make(map[string]struct{}, len(p.Rules))
+	for i, r := range p.Rules {
+		if r == nil || r.Name == "" {
+			return nil, fmt.Errorf("dispatch policy rule %d has no name", i)
+		}
+		if r.Name == policyDefaultRule {
+			return nil, fmt.Errorf("dispatch policy rule name %q is reserved", r.Name)
+		}
+		if _, ok := seen[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate dispatch policy rule %q", r.Name)
+		}
+		seen[r.Name] = struct{}{}
+
+		switch r.Decision {
+		case "", policyAllow:
+		case policyDeny:
+			if r.Profile != "" || r.Timeout != 0 {
+				return nil, fmt.Errorf("dispatch policy rule %q denies runners and cannot set a profile or timeout", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("dispatch policy rule %q: decision must be %q or %q, got %q", r.Name, policyAllow, policyDeny, r.Decision)
+		}
+		if _, ok := profiles[r.Profile]; r.Profile != "" && !ok {
+			return nil, fmt.Errorf("dispatch policy rule %q: runner profile %q is not defined", r.Name, r.Profile)
+		}
+		if r.Timeout < 0 {
+			return nil, fmt.Errorf("dispatch policy rule %q: timeout must be positive, got %s", r.Name, r.Timeout)
+		}
+
+		if r.If == "" {
+			return nil, fmt.Errorf("dispatch policy rule %q has no condition", r.Name)
+		}
+		if r.program, err = policy.Compile(r.If, policyVariables...); err != nil {
+			return nil, fmt.Errorf("dispatch policy rule %q: %w", r.Name, err)
+		}
+	}
+	return &p, nil
+}
+
+// evaluate returns the decision of the first rule matching the variables. A
+// nil policy allows everything.
+func (p *DispatchPolicy) evaluate(vars map[string]any) (*policyDecision, error) {
+	if p == nil {
+		return &policyDecision{Rule: policyDefaultRule}, nil
+	}
+
+	for _, r := range p.Rules {
+		ok, err := r.program.EvalBool(vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate dispatch policy rule %q: %w", r.Name, err)
+		}
+		if ok {
+			return &policyDecision{
+				Rule:    r.Name,
+				Deny:    r.Decision == policyDeny,
+				Profile: r.Profile,
+				Timeout: r.Timeout,
+			}, nil
+		}
+	}
+	return &policyDecision{Rule: policyDefaultRule, Deny: p.Default == policyDeny}, nil
+}
+
+// policyVars returns the policy variables for a runner request.
+func policyVars(req *runnerRequest, now time.Time) map[string]any {
+	vars := map[string]any{
+		"event":       "repository_dispatch",
+		"org":         req.Org,
+		"repo":        req.Repo,
+		"labels":      req.Labels,
+		"actor":       req.Actor,
+		"branch":      "",
+		"workflow":    "",
+		"job":         "",
+		"run_attempt": int64(0),
+		"created_at":  now,
+		"now":         now,
+	}
+	if job := req.Job.GetWorkflowJob(); job != nil {
+		vars["event"] = "workflow_job"
+		vars["branch"] = job.GetHeadBranch()
+		vars["workflow"] = job.GetWorkflowName()
+		vars["job"] = job.GetName()
+		vars["run_attempt"] = job.GetRunAttempt()
+		if job.CreatedAt != nil {
+			vars["created_at"] = job.CreatedAt.Time
+		}
+	}
+	return vars
+}
@@ -0,0 +1,209 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestLoadDispatchPolicy(t *testing.T) {
+	t.Parallel()
+
+	profiles := map[string]*RunnerProfile{"large": {}}
+
+	cases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+default: deny
+rules:
+  - name: release
+    if: 'branch.startsWith("release/")'
+    profile: large
+    timeout: 2h
+  - name: everything-else
+    if: 'true'
+`,
+		},
+		{
+			name: "invalid_default",
+			content: `
+default: maybe
+`,
+			wantErr: `dispatch policy default must be "allow" or "deny", got "maybe"`,
+		},
+		{
+			name: "reserved_name",
+			content: `
+rules:
+  - name: default
+    if: 'true'
+`,
+			wantErr: `dispatch policy rule name "default" is reserved`,
+		},
+		{
+			name: "duplicate",
+			content: `
+rules:
+  - name: a
+    if: 'true'
+  - name: a
+    if: 'false'
+`,
+			wantErr: `duplicate dispatch policy rule "a"`,
+		},
+		{
+			name: "deny_with_override",
+			content: `
+rules:
+  - name: a
+    if: 'true'
+    decision: deny
+    profile: large
+`,
+			wantErr: "cannot set a profile or timeout",
+		},
+		{
+			name: "unknown_profile",
+			content: `
+rules:
+  - name: a
+    if: 'true'
+    profile: missing
+`,
+			wantErr: `runner profile "missing" is not defined`,
+		},
+		{
+			name: "no_condition",
+			content: `
+rules:
+  - name: a
+    decision: deny
+`,
+			wantErr: `dispatch policy rule "a" has no condition`,
+		},
+		{
+			name: "undeclared_variable",
+			content: `
+rules:
+  - name: a
+    if: 'owner == "google"'
+`,
+			wantErr: `undeclared reference to "owner"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			_, err := loadDispatchPolicy(fr, "policy.yaml", profiles)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDispatchPolicyEvaluate(t *testing.T) {
+	t.Parallel()
+
+	content := `
+rules:
+  - name: no-forks
+    if: 'repo.startsWith("fork-")'
+    decision: deny
+  - name: gpu-release
+    if: 'event == "workflow_job" && "gpu" in labels && branch.matches(r"^release/v\d+$")'
+    profile: gpu
+    timeout: 3h
+  - name: night-dispatch
+    if: 'event == "repository_dispatch" && (now.getHours() < 6 || run_attempt > 0)'
+    decision: deny
+`
+	fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(content)}}
+	p, err := loadDispatchPolicy(fr, "policy.yaml", map[string]*RunnerProfile{"gpu": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := func(branch string, labels ...string) *github.WorkflowJobEvent {
+		return &github.WorkflowJobEvent{
+			WorkflowJob: &github.WorkflowJob{
+				HeadBranch: github.Ptr(branch),
+				Labels:     labels,
+				RunAttempt: github.Ptr(int64(1)),
+			},
+		}
+	}
+
+	cases := []struct {
+		name string
+		req  *runnerRequest
+		now  time.Time
+		want *policyDecision
+	}{
+		{
+			name: "deny",
+			req:  &runnerRequest{Org: "google", Repo: "fork-webhook", Job: job("main", "self-hosted")},
+			want: &policyDecision{Rule: "no-forks", Deny: true},
+		},
+		{
+			name: "override",
+			req:  &runnerRequest{Org: "google", Repo: "webhook", Job: job("release/v2", "self-hosted", "gpu")},
+			want: &policyDecision{Rule: "gpu-release", Profile: "gpu", Timeout: 3 * time.Hour},
+		},
+		{
+			name: "dispatch_at_night",
+			req:  &runnerRequest{Org: "google", Repo: "webhook", Labels: []string{"self-hosted", "gpu"}},
+			now:  time.Date(2025, 6, 7, 3, 0, 0, 0, time.UTC),
+			want: &policyDecision{Rule: "night-dispatch", Deny: true},
+		},
+		{
+			name: "default",
+			req:  &runnerRequest{Org: "google", Repo: "webhook", Labels: []string{"self-hosted"}},
+			now:  time.Date(2025, 6, 7, 12, 0, 0, 0, time.UTC),
+			want: &policyDecision{Rule: policyDefaultRule},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.req.Job != nil {
+				tc.req.Labels = tc.req.Job.WorkflowJob.Labels
+			}
+			got, err := p.evaluate(policyVars(tc.req, tc.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected decision (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+)
+
+const poolLabelPrefix = "pool="
+
+// labelValue returns the value of the first label with the given prefix.
+func labelValue(labels []string, prefix string) (string, bool) {
+	for _, label := range labels {
+		if v, ok := strings.CutPrefix(label, prefix); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// runnerWorkerPool returns the worker pool the runner build runs in, selected
+// by the pool= label of the job and falling back to the arm64 worker pool for
+// arm64 jobs and to the default worker pool otherwise. The second return
+// value is the selected pool name, "" for the default pool, and the third is
+// false if the label selects a pool that is not configured.
+func (s *Server) runnerWorkerPool(labels []string) (string, string, bool) {
+	name, ok := labelValue(labels, poolLabelPrefix)
+	if !ok {
+		if runnerArch(labels) == runnerArchARM64 && s.runnerARM64WorkerPool != "" {
+			return s.runnerARM64WorkerPool, arm64PoolName, true
+		}
+		return s.runnerWorkerPoolID, "", true
+	}
+	id, ok := s.runnerWorkerPools[name]
+	return id, name, ok
+}
+
+// workerPoolLocation returns the location of a worker pool resource name of
+// the form projects/<project>/locations/<location>/workerPools/<pool>.
+func workerPoolLocation(pool string) (string, error) {
+	parts := strings.Split(pool, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "workerPools" {
+		return "", fmt.Errorf("worker pool %q is not of the form projects/<project>/locations/<location>/workerPools/<pool>", pool)
+	}
+	return parts[3], nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+)
+
+func TestRunnerWorkerPool(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		runnerARM64WorkerPool: "projects/p/locations/us-central1/workerPools/arm64",
+		runnerWorkerPoolID:    "projects/p/locations/us-central1/workerPools/default",
+		runnerWorkerPools: map[string]string{
+			"private-xl": "projects/p/locations/europe-west1/workerPools/private-xl",
+		},
+	}
+
+	cases := []struct {
+		name     string
+		labels   []string
+		wantPool string
+		wantOK   bool
+	}{
+		{
+			name:     "default",
+			labels:   []string{"self-hosted"},
+			wantPool: "projects/p/locations/us-central1/workerPools/default",
+			wantOK:   true,
+		},
+		{
+			name:     "selected",
+			labels:   []string{"self-hosted", "pool=private-xl"},
+			wantPool: "projects/p/locations/europe-west1/workerPools/private-xl",
+			wantOK:   true,
+		},
+		{
+			name:     "arm64",
+			labels:   []string{"self-hosted", "ARM64"},
+			wantPool: "projects/p/locations/us-central1/workerPools/arm64",
+			wantOK:   true,
+		},
+		{
+			name:     "arm64_selected",
+			labels:   []string{"self-hosted", "ARM64", "pool=private-xl"},
+			wantPool: "projects/p/locations/europe-west1/workerPools/private-xl",
+			wantOK:   true,
+		},
+		{
+			name:   "unknown",
+			labels: []string{"self-hosted", "pool=other"},
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool, _, ok := srv.runnerWorkerPool(tc.labels)
+			if got, want := pool, tc.wantPool; got != want {
+				t.Errorf("expected pool %q to be %q", got, want)
+			}
+			if got, want := ok, tc.wantOK; got != want {
+				t.Errorf("expected ok %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolLocation(t *testing.T) {
+	t.Parallel()
+
+	got, err := workerPoolLocation("projects/p/locations/europe-west1/workerPools/private-xl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "europe-west1"; got != want {
+		t.Errorf("expected location %q to be %q", got, want)
+	}
+
+	if _, err := workerPoolLocation("private-xl"); err == nil {
+		t.Errorf("expected an error for a pool that is not a resource name")
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/uuid"
+)
+
+// prewarmResult is the response of a pre-warm request.
+type prewarmResult struct {
+	// Runners are the names of the runners provisioned, or queued for
+	// dispatch, in order.
+	Runners []string `json:"runners"`
+
+	// Queued is whether the runners were queued for dispatch rather than
+	// provisioned.
+	Queued bool `json:"queued,omitempty"`
+
+	// Error is why the runner after the last of Runners was not provisioned,
+	// in which case the remaining runners were not provisioned either.
+	Error string `json:"error,omitempty"`
+}
+
+// handleAdminPrewarm provisions runners for a repository ahead of known busy
+// windows, for example from Cloud Scheduler before the morning merge train.
+// The repository and installation are given by the org, repo and
+// installation_id query parameters, along with the number of runners, count,
+// and their comma-separated labels. Runners are provisioned like those
+// requested by a repository_dispatch event.
+func (s *Server) handleAdminPrewarm() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := logging.FromContext(ctx)
+		query := r.URL.Query()
+
+		installationID, err := strconv.ParseInt(query.Get("installation_id"), 10, 64)
+		if err != nil || installationID <= 0 {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("installation_id %q is not a positive number", query.Get("installation_id")),
+			})
+			return
+		}
+		org, repo := query.Get("org"), query.Get("repo")
+		if org == "" || repo == "" {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "org and repo are required",
+			})
+			return
+		}
+
+		count := 1
+		if v := query.Get("count"); v != "" {
+			if count, err = strconv.Atoi(v); err != nil || count < 1 {
+				s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("count %q is not a positive number", v),
+				})
+				return
+			}
+		}
+		if count > s.dispatchMaxRunners {
+			s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("requested %d runners, at most %d are allowed", count, s.dispatchMaxRunners),
+			})
+			return
+		}
+
+		var labels []string
+		if v := query.Get("labels"); v != "" {
+			labels = strings.Split(v, ",")
+		}
+		if !slices.Contains(labels, defaultRunnerLabel) {
+			labels = append([]string{defaultRunnerLabel}, labels...)
+		}
+
+		if s.inMaintenance() {
+			s.h.RenderJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"error": maintenanceMsg,
+			})
+			return
+		}
+
+		logFields := []any{
+			"org", org,
+			"repo", repo,
+			"labels", labels,
+			"count", count,
+		}
+		logger.InfoContext(ctx, "pre-warming runners", logFields...)
+
+		result := &prewarmResult{Runners: []string{}, Queued: s.dispatchQueue != nil}
+		for range count {
+			runnerID := runnerNamePrefix + uuid.NewString()
+			runnerFields := append(append([]any{}, logFields...), "runner_id", runnerID)
+			req := &runnerRequest{
+				InstallationID: installationID,
+				Org:            org,
+				Repo:           repo,
+				RunnerName:     runnerID,
+				Labels:         labels,
+			}
+
+			if s.dispatchQueue != nil {
+				if resp := s.enqueueRunner(ctx, req, runnerFields); resp.Code != http.StatusAccepted {
+					result.Error = resp.Message
+					break
+				}
+				result.Runners = append(result.Runners, runnerID)
+				continue
+			}
+
+			createdBuild, errResponse := s.provisionRunner(ctx, req, runnerFields)
+			if errResponse != nil {
+				result.Error = errResponse.Message
+				break
+			}
+			s.runnerDispatched(ctx, req, createdBuild, runnerFields)
+			result.Runners = append(result.Runners, runnerID)
+		}
+
+		code := http.StatusOK
+		if result.Error != "" && len(result.Runners) == 0 {
+			code = http.StatusInternalServerError
+		}
+		s.h.RenderJSON(w, code, result)
+	})
+}
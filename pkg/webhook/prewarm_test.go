@@ -0,0 +1,157 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/abcxyz/pkg/renderer"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleAdminPrewarm(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		query       string
+		maintenance bool
+		wantCode    int
+		wantRunners int
+		wantLabels  []string
+		wantErr     string
+	}{
+		{
+			name:        "provisions_runners",
+			query:       "installation_id=123&org=google&repo=webhook&count=2&labels=profile%3Dgo",
+			wantCode:    http.StatusOK,
+			wantRunners: 2,
+			wantLabels:  []string{"self-hosted", "profile=go"},
+		},
+		{
+			name:        "defaults_to_one_runner",
+			query:       "installation_id=123&org=google&repo=webhook",
+			wantCode:    http.StatusOK,
+			wantRunners: 1,
+			wantLabels:  []string{"self-hosted"},
+		},
+		{
+			name:     "too_many_runners",
+			query:    "installation_id=123&org=google&repo=webhook&count=50",
+			wantCode: http.StatusBadRequest,
+			wantErr:  "requested 50 runners, at most 10 are allowed",
+		},
+		{
+			name:     "invalid_count",
+			query:    "installation_id=123&org=google&repo=webhook&count=0",
+			wantCode: http.StatusBadRequest,
+			wantErr:  `count "0" is not a positive number`,
+		},
+		{
+			name:     "missing_installation",
+			query:    "org=google&repo=webhook",
+			wantCode: http.StatusBadRequest,
+			wantErr:  `installation_id "" is not a positive number`,
+		},
+		{
+			name:     "missing_repo",
+			query:    "installation_id=123&org=google",
+			wantCode: http.StatusBadRequest,
+			wantErr:  "org and repo are required",
+		},
+		{
+			name:        "maintenance",
+			query:       "installation_id=123&org=google&repo=webhook",
+			maintenance: true,
+			wantCode:    http.StatusServiceUnavailable,
+			wantErr:     maintenanceMsg,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			var mu sync.Mutex
+			var registered []string
+			app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+				mu.Lock()
+				defer mu.Unlock()
+				registered = append(registered, name)
+			})
+
+			s := &Server{
+				adminToken:         []byte("admin-token"),
+				appClient:          app,
+				cbc:                &MockCloudBuildClient{},
+				dispatchMaxRunners: 10,
+				ghAPIBaseURL:       ghURL,
+				h:                  renderer.NewTesting(ctx, t, nil),
+				maintenance:        newMaintenanceToggle(),
+				runners:            newRunnerTracker(),
+			}
+			if tc.maintenance {
+				s.maintenance.Set(true)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/prewarm?"+tc.query, nil)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			resp := httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.wantErr != "" {
+				var got map[string]string
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got, want := got["error"], tc.wantErr; got != want {
+					t.Errorf("expected %q to be %q", got, want)
+				}
+				return
+			}
+
+			var got prewarmResult
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(registered, got.Runners); diff != "" {
+				t.Errorf("unexpected runners (-registered, +got):\n%s", diff)
+			}
+			if got, want := len(got.Runners), tc.wantRunners; got != want {
+				t.Errorf("expected %d runners, got %d", want, got)
+			}
+			for _, name := range got.Runners {
+				r, ok := s.trackedRunner(name)
+				if !ok {
+					t.Errorf("expected runner %q to be tracked", name)
+					continue
+				}
+				if diff := cmp.Diff(tc.wantLabels, r.Labels); diff != "" {
+					t.Errorf("unexpected labels (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
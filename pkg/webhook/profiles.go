@@ -0,0 +1,115 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// profileLabelPrefix is the prefix of the job label selecting a runner
+	// profile, e.g. "profile=go".
+	profileLabelPrefix = "profile="
+
+	// defaultProfileName is the profile used when a job does not select one.
+	defaultProfileName = "default"
+)
+
+var cacheNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// RunnerProfile customizes the runner build for jobs that select it.
+type RunnerProfile struct {
+	// Caches are directories in the runner persisted across runner builds.
+	Caches []*RunnerCache `yaml:"caches"`
+
+	// ToolcacheCompat overrides whether the runner gets a writable tool cache
+	// for the setup-* actions, see RUNNER_TOOLCACHE_COMPAT.
+	ToolcacheCompat *bool `yaml:"toolcache_compat"`
+
+	// Canary routes a share of the jobs of the profile to another image tag.
+	Canary *ImageCanary `yaml:"canary"`
+}
+
+// RunnerCache is a directory in the runner whose content is restored before
+// the runner starts and saved after it exits.
+type RunnerCache struct {
+	// Name identifies the cache within the profile.
+	Name string `yaml:"name"`
+
+	// Path is the absolute path of the directory in the runner container.
+	Path string `yaml:"path"`
+}
+
+// runnerProfilesFile is the format of the runner profiles file.
+type runnerProfilesFile struct {
+	Profiles map[string]*RunnerProfile `yaml:"profiles"`
+}
+
+// loadRunnerProfiles reads and validates the runner profiles file.
+func loadRunnerProfiles(fr FileReader, filename string) (map[string]*RunnerProfile, error) {
+	b, err := fr.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runner profiles: %w", err)
+	}
+
+	var f runnerProfilesFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse runner profiles: %w", err)
+	}
+
+	for name, p := range f.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("runner profile %q is empty", name)
+		}
+		if p.Canary != nil {
+			if err := p.Canary.validate(); err != nil {
+				return nil, fmt.Errorf("runner profile %q: %w", name, err)
+			}
+		}
+		seen := make(map[string]struct{}, len(p.Caches))
+		for _, c := range p.Caches {
+			if !cacheNameRegexp.MatchString(c.Name) {
+				return nil, fmt.Errorf("runner profile %q: cache name %q must match %s", name, c.Name, cacheNameRegexp)
+			}
+			if _, ok := seen[c.Name]; ok {
+				return nil, fmt.Errorf("runner profile %q: duplicate cache %q", name, c.Name)
+			}
+			seen[c.Name] = struct{}{}
+			if !path.IsAbs(c.Path) {
+				return nil, fmt.Errorf("runner profile %q: cache %q path must be absolute, got %q", name, c.Name, c.Path)
+			}
+		}
+	}
+	return f.Profiles, nil
+}
+
+// runnerProfile returns the profile selected by the job labels, falling back
+// to the default profile. It returns nil if no profile applies.
+func (s *Server) runnerProfile(labels []string) (string, *RunnerProfile) {
+	name, ok := labelValue(labels, profileLabelPrefix)
+	if !ok {
+		name = defaultProfileName
+	}
+
+	p, ok := s.runnerProfiles[name]
+	if !ok {
+		return name, nil
+	}
+	return name, p
+}
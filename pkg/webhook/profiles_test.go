@@ -0,0 +1,199 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestLoadRunnerProfiles(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]*RunnerProfile
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+profiles:
+  go:
+    caches:
+      - name: gomod
+        path: /home/runner/go/pkg/mod
+`,
+			want: map[string]*RunnerProfile{
+				"go": {Caches: []*RunnerCache{{Name: "gomod", Path: "/home/runner/go/pkg/mod"}}},
+			},
+		},
+		{
+			name: "toolcache_compat",
+			content: `
+profiles:
+  legacy:
+    toolcache_compat: false
+`,
+			want: map[string]*RunnerProfile{
+				"legacy": {ToolcacheCompat: github.Ptr(false)},
+			},
+		},
+		{
+			name: "canary",
+			content: `
+profiles:
+  default:
+    canary:
+      tag: v2
+      percent: 5
+`,
+			want: map[string]*RunnerProfile{
+				"default": {Canary: &ImageCanary{Tag: "v2", Percent: 5}},
+			},
+		},
+		{
+			name: "canary_percent_out_of_range",
+			content: `
+profiles:
+  default:
+    canary:
+      tag: v2
+      percent: 101
+`,
+			wantErr: "canary percent must be between 0 and 100",
+		},
+		{
+			name: "canary_invalid_tag",
+			content: `
+profiles:
+  default:
+    canary:
+      tag: "v2:latest"
+      percent: 5
+`,
+			wantErr: `canary tag "v2:latest" is not a valid image tag`,
+		},
+		{
+			name: "relative_path",
+			content: `
+profiles:
+  node:
+    caches:
+      - name: npm
+        path: .npm
+`,
+			wantErr: "path must be absolute",
+		},
+		{
+			name: "duplicate_cache",
+			content: `
+profiles:
+  maven:
+    caches:
+      - name: m2
+        path: /home/runner/.m2
+      - name: m2
+        path: /home/runner/.m2/repository
+`,
+			wantErr: "duplicate cache",
+		},
+		{
+			name: "invalid_name",
+			content: `
+profiles:
+  go:
+    caches:
+      - name: Go_Mod
+        path: /home/runner/go/pkg/mod
+`,
+			wantErr: "cache name",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			got, err := loadRunnerProfiles(fr, "profiles.yaml")
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected profiles (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddCacheSteps(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerCacheBucket: "cache-bucket",
+		runnerProfiles: map[string]*RunnerProfile{
+			"go": {Caches: []*RunnerCache{{Name: "gomod", Path: "/home/runner/go/pkg/mod"}}},
+		},
+	}
+
+	name, profile := s.runnerProfile([]string{"self-hosted", "profile=go"})
+	if name != "go" || profile == nil {
+		t.Fatalf("expected profile go to be selected, got %q", name)
+	}
+
+	build := &cloudbuildpb.Build{
+		Steps:         []*cloudbuildpb.BuildStep{{Id: "run"}},
+		Substitutions: map[string]string{},
+	}
+	s.addCacheSteps(build, "google", "repo", name, profile)
+
+	var gotSteps []string
+	for _, step := range build.GetSteps() {
+		gotSteps = append(gotSteps, step.GetId())
+	}
+	if diff := cmp.Diff([]string{"restore-caches", "run", "save-caches"}, gotSteps); diff != "" {
+		t.Errorf("unexpected steps (-want, +got):\n%s", diff)
+	}
+
+	if got, want := build.GetSubstitutions()["_CACHE_MOUNTS"], "-v cache-gomod:/home/runner/go/pkg/mod"; got != want {
+		t.Errorf("expected cache mounts %q to be %q", got, want)
+	}
+	if got, want := build.GetSteps()[2].GetArgs()[1], "gs://cache-bucket/google/repo/go/gomod.tar.gz"; !strings.Contains(got, want) {
+		t.Errorf("expected save step %q to contain %q", got, want)
+	}
+
+	// The other steps of a build template are kept around the cache steps.
+	build = &cloudbuildpb.Build{
+		Steps:         []*cloudbuildpb.BuildStep{{Id: "prime"}, {Id: "run"}, {Id: "ship-logs"}},
+		Substitutions: map[string]string{},
+	}
+	s.addCacheSteps(build, "google", "repo", name, profile)
+
+	gotSteps = nil
+	for _, step := range build.GetSteps() {
+		gotSteps = append(gotSteps, step.GetId())
+	}
+	if diff := cmp.Diff([]string{"prime", "restore-caches", "run", "save-caches", "ship-logs"}, gotSteps); diff != "" {
+		t.Errorf("unexpected template steps (-want, +got):\n%s", diff)
+	}
+}
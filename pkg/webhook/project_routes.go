@@ -0,0 +1,87 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunnerProjectRoute routes the runner builds of an organization or
+// repository to their own project, for example so each business unit pays
+// for and audits its own builds.
+type RunnerProjectRoute struct {
+	// ProjectID is the project the builds are created in.
+	ProjectID string `yaml:"project_id"`
+
+	// Location is the Cloud Build location of builds outside private worker
+	// pools, RUNNER_LOCATION when unset.
+	Location string `yaml:"location"`
+
+	// ServiceAccount is the service account the builds run as, in the form
+	// projects/<project>/serviceAccounts/<email>, RUNNER_SERVICE_ACCOUNT when
+	// unset.
+	ServiceAccount string `yaml:"service_account"`
+}
+
+// runnerProjectRoutesFile is the format of the runner project routes file.
+type runnerProjectRoutesFile struct {
+	// Routes are keyed by organization (<org>) or repository (<org>/<repo>).
+	Routes map[string]*RunnerProjectRoute `yaml:"routes"`
+}
+
+// loadRunnerProjectRoutes reads and validates the runner project routes file.
+func loadRunnerProjectRoutes(fr FileReader, filename string) (map[string]*RunnerProjectRoute, error) {
+	b, err := fr.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runner project routes: %w", err)
+	}
+
+	var f runnerProjectRoutesFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse runner project routes: %w", err)
+	}
+
+	for key, r := range f.Routes {
+		org, repo, hasRepo := strings.Cut(key, "/")
+		if org == "" || (hasRepo && (repo == "" || strings.Contains(repo, "/"))) {
+			return nil, fmt.Errorf("runner project route %q must be of the form <org> or <org>/<repo>", key)
+		}
+		if r == nil || r.ProjectID == "" {
+			return nil, fmt.Errorf("runner project route %q must set project_id", key)
+		}
+		if sa := r.ServiceAccount; sa != "" {
+			parts := strings.Split(sa, "/")
+			if len(parts) != 4 || parts[0] != "projects" || parts[2] != "serviceAccounts" || !strings.Contains(parts[3], "@") {
+				return nil, fmt.Errorf("runner project route %q: service account %q is not of the form "+
+					"projects/<project>/serviceAccounts/<email>", key, sa)
+			}
+		}
+	}
+	return f.Routes, nil
+}
+
+// runnerProjectRoute returns the route of the repository, falling back to the
+// route of its organization, or nil if neither is routed.
+func (s *Server) runnerProjectRoute(org, repo string) *RunnerProjectRoute {
+	for _, key := range []string{org + "/" + repo, org} {
+		if r, ok := s.runnerProjectRoutes[key]; ok {
+			return r
+		}
+	}
+	return nil
+}
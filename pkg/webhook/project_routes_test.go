@@ -0,0 +1,161 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadRunnerProjectRoutes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]*RunnerProjectRoute
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+routes:
+  payments:
+    project_id: payments-runners
+    location: us-east1
+    service_account: projects/payments-runners/serviceAccounts/runner@payments-runners.iam.gserviceaccount.com
+  google/webhook:
+    project_id: webhook-runners
+`,
+			want: map[string]*RunnerProjectRoute{
+				"payments": {
+					ProjectID:      "payments-runners",
+					Location:       "us-east1",
+					ServiceAccount: "projects/payments-runners/serviceAccounts/runner@payments-runners.iam.gserviceaccount.com",
+				},
+				"google/webhook": {ProjectID: "webhook-runners"},
+			},
+		},
+		{
+			name: "no_project",
+			content: `
+routes:
+  payments:
+    location: us-east1
+`,
+			wantErr: `runner project route "payments" must set project_id`,
+		},
+		{
+			name: "invalid_key",
+			content: `
+routes:
+  google/webhook/extra:
+    project_id: webhook-runners
+`,
+			wantErr: "must be of the form <org> or <org>/<repo>",
+		},
+		{
+			name: "invalid_service_account",
+			content: `
+routes:
+  payments:
+    project_id: payments-runners
+    service_account: runner@payments-runners.iam.gserviceaccount.com
+`,
+			wantErr: "is not of the form projects/<project>/serviceAccounts/<email>",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			got, err := loadRunnerProjectRoutes(fr, "routes.yaml")
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected routes (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunnerProjectRoute(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerImageName: "default-runner",
+		runnerImageTag:  "latest",
+		runnerProjectRoutes: map[string]*RunnerProjectRoute{
+			"payments":       {ProjectID: "payments-runners", ServiceAccount: "projects/payments-runners/serviceAccounts/runner@payments-runners.iam.gserviceaccount.com"},
+			"payments/infra": {ProjectID: "infra-runners"},
+		},
+		runnerRepositoryID:   "us-docker.pkg.dev/p/runners",
+		runnerServiceAccount: "projects/p/serviceAccounts/runner@p.iam.gserviceaccount.com",
+	}
+
+	cases := []struct {
+		name               string
+		org                string
+		repo               string
+		wantProject        string
+		wantServiceAccount string
+	}{
+		{
+			name:               "org",
+			org:                "payments",
+			repo:               "api",
+			wantProject:        "payments-runners",
+			wantServiceAccount: "projects/payments-runners/serviceAccounts/runner@payments-runners.iam.gserviceaccount.com",
+		},
+		{
+			name:               "repo",
+			org:                "payments",
+			repo:               "infra",
+			wantProject:        "infra-runners",
+			wantServiceAccount: "projects/p/serviceAccounts/runner@p.iam.gserviceaccount.com",
+		},
+		{
+			name:               "not_routed",
+			org:                "google",
+			repo:               "webhook",
+			wantServiceAccount: "projects/p/serviceAccounts/runner@p.iam.gserviceaccount.com",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotProject string
+			if route := s.runnerProjectRoute(tc.org, tc.repo); route != nil {
+				gotProject = route.ProjectID
+			}
+			if got, want := gotProject, tc.wantProject; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			build := s.runnerBuild(&runnerRequest{Org: tc.org, Repo: tc.repo, RunnerName: "GCP-2"}, "encoded-jit-config")
+			if got, want := build.GetServiceAccount(), tc.wantServiceAccount; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
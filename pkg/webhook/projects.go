@@ -0,0 +1,92 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// projectStrategyRoundRobin creates builds in each runner project in turn.
+	projectStrategyRoundRobin = "round-robin"
+
+	// projectStrategyLeastLoaded creates builds in the runner project with the
+	// fewest active runners.
+	projectStrategyLeastLoaded = "least-loaded"
+)
+
+// projectSpreader picks the runner project each build is created in. Spreading
+// builds across several projects multiplies the Cloud Build concurrency quota,
+// which is per project. A nil spreader is valid and has no projects.
+type projectSpreader struct {
+	projects []string
+	strategy string
+	next     atomic.Uint64
+}
+
+// newProjectSpreader creates a spreader over the primary runner project and
+// the additional projects.
+func newProjectSpreader(primary string, additional []string, strategy string) (*projectSpreader, error) {
+	if strategy != projectStrategyRoundRobin && strategy != projectStrategyLeastLoaded {
+		return nil, fmt.Errorf("strategy must be one of %q or %q, got %q",
+			projectStrategyRoundRobin, projectStrategyLeastLoaded, strategy)
+	}
+
+	projects := []string{primary}
+	seen := map[string]struct{}{primary: {}}
+	for _, p := range additional {
+		if p == "" {
+			return nil, fmt.Errorf("project IDs must not be empty")
+		}
+		if _, ok := seen[p]; ok {
+			return nil, fmt.Errorf("project %q is listed twice", p)
+		}
+		seen[p] = struct{}{}
+		projects = append(projects, p)
+	}
+
+	return &projectSpreader{
+		projects: projects,
+		strategy: strategy,
+	}, nil
+}
+
+// Pick returns the project the next build is created in. active returns the
+// number of active runners in a project, it is only called by the
+// least-loaded strategy. Ties are broken in round-robin order, so an idle
+// fleet still spreads its builds.
+func (p *projectSpreader) Pick(active func(projectID string) int) string {
+	if p == nil || len(p.projects) == 0 {
+		return ""
+	}
+	if len(p.projects) == 1 {
+		return p.projects[0]
+	}
+
+	start := int((p.next.Add(1) - 1) % uint64(len(p.projects)))
+	if p.strategy != projectStrategyLeastLoaded {
+		return p.projects[start]
+	}
+
+	best, bestActive := "", -1
+	for i := range p.projects {
+		project := p.projects[(start+i)%len(p.projects)]
+		if n := active(project); bestActive < 0 || n < bestActive {
+			best, bestActive = project, n
+		}
+	}
+	return best
+}
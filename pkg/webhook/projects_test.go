@@ -0,0 +1,124 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewProjectSpreader(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		additional []string
+		strategy   string
+		wantErr    string
+	}{
+		{
+			name:       "valid",
+			additional: []string{"runners-2", "runners-3"},
+			strategy:   projectStrategyLeastLoaded,
+		},
+		{
+			name:     "unknown_strategy",
+			strategy: "random",
+			wantErr:  `strategy must be one of "round-robin" or "least-loaded", got "random"`,
+		},
+		{
+			name:       "duplicate_primary",
+			additional: []string{"runners-1"},
+			strategy:   projectStrategyRoundRobin,
+			wantErr:    `project "runners-1" is listed twice`,
+		},
+		{
+			name:       "empty_project",
+			additional: []string{""},
+			strategy:   projectStrategyRoundRobin,
+			wantErr:    "project IDs must not be empty",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newProjectSpreader("runners-1", tc.additional, tc.strategy)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestProjectSpreaderPick(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		additional []string
+		strategy   string
+		active     map[string]int
+		want       []string
+	}{
+		{
+			name:     "single_project",
+			strategy: projectStrategyRoundRobin,
+			want:     []string{"runners-1", "runners-1", "runners-1"},
+		},
+		{
+			name:       "round_robin",
+			additional: []string{"runners-2", "runners-3"},
+			strategy:   projectStrategyRoundRobin,
+			active:     map[string]int{"runners-2": 100},
+			want:       []string{"runners-1", "runners-2", "runners-3", "runners-1"},
+		},
+		{
+			name:       "least_loaded",
+			additional: []string{"runners-2", "runners-3"},
+			strategy:   projectStrategyLeastLoaded,
+			active:     map[string]int{"runners-1": 5, "runners-2": 2, "runners-3": 7},
+			want:       []string{"runners-2", "runners-2", "runners-2"},
+		},
+		{
+			name:       "least_loaded_ties",
+			additional: []string{"runners-2", "runners-3"},
+			strategy:   projectStrategyLeastLoaded,
+			active:     map[string]int{"runners-3": 1},
+			want:       []string{"runners-1", "runners-2", "runners-1", "runners-1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := newProjectSpreader("runners-1", tc.additional, tc.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(tc.want))
+			for range tc.want {
+				got = append(got, p.Pick(func(projectID string) int { return tc.active[projectID] }))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected projects (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,617 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// runnerRequest describes a runner to provision.
+type runnerRequest struct {
+	InstallationID int64
+	Org            string
+	Repo           string
+	RunnerName     string
+	Labels         []string
+
+	// Actor is the login of the user that triggered the event requesting the
+	// runner.
+	Actor string
+
+	// Job is the queued job the runner is provisioned for. It is nil when the
+	// runner is provisioned ahead of demand.
+	Job *github.WorkflowJobEvent
+
+	// DeliveryID is the X-GitHub-Delivery ID of the event that requested the
+	// runner. It is added to the build tags.
+	DeliveryID string
+
+	// Relaunches is how many runners for the same job were preempted before
+	// this one.
+	Relaunches int
+
+	// LaunchReleased is set once the runner went through the launch queue,
+	// so it is not queued again.
+	LaunchReleased bool
+}
+
+// provisionRunner registers a just-in-time runner with GitHub and starts the
+// build running it. logFields are added to every log line.
+func (s *Server) provisionRunner(ctx context.Context, req *runnerRequest, logFields []any) (*cloudbuildpb.Build, *apiResponse) {
+	logger := logging.FromContext(ctx)
+
+	// Runners provisioned ahead of demand are queued when they are requested.
+	dispatchedAt := time.Now()
+	queuedAt := dispatchedAt
+	if job := req.Job.GetWorkflowJob(); job != nil && job.CreatedAt != nil {
+		queuedAt = job.CreatedAt.Time
+	}
+	state := lifecycle.New(queuedAt)
+
+	if err := s.verifyInstallationRepo(ctx, req.InstallationID, req.Org, req.Repo); err != nil {
+		if errors.Is(err, errRunnerTargetGone) {
+			return nil, s.runnerTargetGone(ctx, req, logFields, err)
+		}
+		if errors.Is(err, errInstallationSuspended) {
+			logger.WarnContext(ctx, "ignoring runner request for suspended installation", append(logFields, "error", err)...)
+			return nil, &apiResponse{http.StatusOK, runnerInstallationSuspendedMsg, nil}
+		}
+		if errors.Is(err, errRepoNotInInstallation) {
+			logger.WarnContext(ctx, "rejecting event for repository outside of installation", append(logFields, "error", err)...)
+			return nil, &apiResponse{http.StatusForbidden, "repository does not belong to installation", err}
+		}
+		logger.ErrorContext(ctx, "failed to verify repository installation", append(logFields, "error", err)...)
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to verify repository installation", err}
+	}
+
+	decision, err := s.dispatchPolicy.evaluate(policyVars(req, time.Now()))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to evaluate dispatch policy", append(logFields, "error", err)...)
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to evaluate dispatch policy", err}
+	}
+	if s.dispatchPolicy != nil {
+		logFields = append(logFields, "policy_rule", decision.Rule)
+		s.metrics.recordPolicyDecision(decision.Rule, decision.Deny)
+	}
+	if decision.Deny {
+		logger.WarnContext(ctx, "dispatch policy denied runner", logFields...)
+		return nil, &apiResponse{http.StatusOK, runnerDeniedMsg, nil}
+	}
+
+	// The counts cover the runners of every instance when a state store is
+	// configured, and only those of this instance otherwise, which makes the
+	// caps approximate when several instances dispatch.
+	s.syncRunners(ctx)
+	switch s.actorLimits.check(req.Actor, s.runners.CountActor(req.Actor)) {
+	case actorRejectBlocked:
+		logger.WarnContext(ctx, "rejecting runner requested by a blocked actor", append(logFields, "actor", req.Actor)...)
+		s.metrics.recordActorRejection(actorRejectBlocked)
+		return nil, &apiResponse{http.StatusOK, runnerActorBlockedMsg, nil}
+	case actorRejectLimit:
+		logger.WarnContext(ctx, "rejecting runner over the actor's runner limit", append(logFields, "actor", req.Actor)...)
+		s.metrics.recordActorRejection(actorRejectLimit)
+		return nil, &apiResponse{http.StatusOK, runnerActorLimitMsg, nil}
+	}
+
+	releaseScope, resp := s.checkScopeLimits(ctx, req, logFields)
+	if resp != nil {
+		return nil, resp
+	}
+	defer releaseScope()
+
+	releaseLaunch, resp := s.checkLaunchCapacity(ctx, req, logFields)
+	if resp != nil {
+		return nil, resp
+	}
+	defer releaseLaunch()
+
+	if limit := s.settings.Get().MaxRunners; limit > 0 && s.runners.Count() >= limit {
+		logger.WarnContext(ctx, "rejecting runner over the instance's runner limit", append(logFields, "max_runners", limit)...)
+		return nil, &apiResponse{http.StatusOK, runnerFleetLimitMsg, nil}
+	}
+
+	if image, ok := s.runnerImage(req.Labels); !ok {
+		err := fmt.Errorf("runner image %q is not one of the configured variants", image)
+		logger.WarnContext(ctx, "job selected a runner image that is not allowed", append(logFields, "image", image)...)
+		return nil, &apiResponse{http.StatusBadRequest, "runner image is not allowed", err}
+	}
+
+	if _, name, ok := s.runnerRepository(req.Org, req.Repo, req.Labels); !ok {
+		err := fmt.Errorf("runner repository %q is not configured", name)
+		logger.WarnContext(ctx, "job selected a runner repository that is not configured", append(logFields, "registry", name)...)
+		return nil, &apiResponse{http.StatusBadRequest, "runner repository is not configured", err}
+	}
+
+	if runnerArch(req.Labels) == runnerArchARM64 && s.runnerARM64ImageName == "" {
+		err := fmt.Errorf("no arm64 runner image is configured")
+		logger.WarnContext(ctx, "job requested an arm64 runner without arm64 runners configured", logFields...)
+		return nil, &apiResponse{http.StatusBadRequest, "arm64 runners are not available", err}
+	}
+
+	if _, name, ok := s.runnerGPU(req.Labels); !ok {
+		err := fmt.Errorf("gpu type %q is not configured", name)
+		logger.WarnContext(ctx, "job selected a gpu type that is not configured", append(logFields, "gpu", name)...)
+		return nil, &apiResponse{http.StatusBadRequest, "gpu type is not configured", err}
+	} else if name != "" {
+		if _, backend := s.gpuBackend(); backend == nil {
+			err := fmt.Errorf("gpu type %q requires the compute or batch backend", name)
+			logger.WarnContext(ctx, "job requested a gpu without a backend attaching gpus", append(logFields, "gpu", name)...)
+			return nil, &apiResponse{http.StatusBadRequest, "gpu runners are not available", err}
+		}
+	}
+
+	if _, name, ok := s.runnerWorkerPool(req.Labels); !ok {
+		err := fmt.Errorf("worker pool %q is not configured", name)
+		logger.WarnContext(ctx, "job selected a worker pool that is not configured", append(logFields, "pool", name)...)
+		return nil, &apiResponse{http.StatusBadRequest, "worker pool is not configured", err}
+	}
+
+	mapping, err := s.runnerGroupMapping(ctx, req.InstallationID, req.Org, req.Repo)
+	if err != nil {
+		if isGoneResponse(err) {
+			return nil, s.runnerTargetGone(ctx, req, logFields, err)
+		}
+		logger.ErrorContext(ctx, "failed to map repository to a runner group", append(logFields, "error", err)...)
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to map repository to a runner group", err}
+	}
+	if mapping != nil {
+		logFields = append(logFields, "runner_group_mapping", mapping.Name)
+	}
+
+	s.transitionLifecycle(&state, lifecycle.StateDispatching, dispatchedAt)
+
+	var jitConfig *github.JITRunnerConfig
+	var errResponse *apiResponse
+	switch {
+	case s.shadowMode:
+		// Registering the runner would take the job from production.
+		jitConfig = &github.JITRunnerConfig{EncodedJITConfig: github.Ptr(shadowJITConfig)}
+	case s.reusableRunners():
+		var runnerGroupID int64
+		if mapping != nil {
+			runnerGroupID = mapping.RunnerGroupID
+		}
+		jitConfig, errResponse = s.GenerateReusableConfig(ctx, req.InstallationID, req.Org, req.Repo, req.RunnerName, runnerGroupID, req.Labels...)
+	case mapping != nil && mapping.RunnerGroupID > 0:
+		jitConfig, errResponse = s.GenerateOrgJITConfig(ctx, req.InstallationID, req.Org, req.RunnerName, mapping.RunnerGroupID, req.Labels...)
+	default:
+		jitConfig, errResponse = s.GenerateRepoJITConfig(ctx, req.InstallationID, req.Org, req.Repo, req.RunnerName, req.Labels...)
+	}
+	switch {
+	case errResponse == nil:
+	case errors.Is(errResponse.Error, errRunnerTargetGone):
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, s.runnerTargetGone(ctx, req, logFields, errResponse.Error)
+	case errors.Is(errResponse.Error, errRunnerExists):
+		// Not a dispatch failure, the runner was already dispatched or the
+		// name belongs to another runner.
+		logger.WarnContext(ctx, "refusing to reuse existing runner", append(logFields, "error", errResponse.Error)...)
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, &apiResponse{errResponse.Code, errResponse.Message, nil}
+	default:
+		logger.ErrorContext(ctx, "failed to generate JIT config", append(logFields, "error", errResponse.Error, "response_message", errResponse.Message)...)
+		s.notifyDispatchFailure(ctx, req, errResponse.Message, errResponse.Error)
+		s.recordDispatchOutcome(ctx, true)
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, errResponse
+	}
+
+	build := s.runnerBuild(req, jitConfig.GetEncodedJITConfig())
+	// The group tag finds where the runner of a build was registered.
+	if mapping != nil && mapping.RunnerGroupID > 0 {
+		build.Tags = append(build.Tags, "group-"+strconv.FormatInt(mapping.RunnerGroupID, 10))
+	}
+
+	if s.propagateJobTimeout && req.Job != nil {
+		timeout, ok, err := s.jobTimeout(ctx, req.Job)
+		switch {
+		case err != nil:
+			// The build falls back to the default timeout, this is not fatal.
+			logger.WarnContext(ctx, "failed to determine job timeout", append(logFields, "error", err)...)
+		case ok:
+			build.Timeout = durationpb.New(buildTimeout(timeout, s.jobTimeoutMargin))
+		}
+	}
+	if decision.Timeout > 0 {
+		build.Timeout = durationpb.New(decision.Timeout)
+	}
+
+	profileName, profile := s.runnerProfile(req.Labels)
+	if mapping != nil && mapping.Profile != "" {
+		profileName, profile = mapping.Profile, s.runnerProfiles[mapping.Profile]
+	}
+	if decision.Profile != "" {
+		profileName, profile = decision.Profile, s.runnerProfiles[decision.Profile]
+	}
+	if profile == nil && profileName != defaultProfileName {
+		logger.WarnContext(ctx, "job selected an unknown runner profile", append(logFields, "profile", profileName)...)
+	}
+	s.addCacheSteps(build, req.Org, req.Repo, profileName, profile)
+
+	// Autopush builds running a pr- tag are not part of the rollout.
+	imageTag, track := build.GetSubstitutions()["_IMAGE_TAG"], ""
+	if imageTag == s.runnerImageTag {
+		imageTag, track = s.imageTrack(req.RunnerName, s.canaryProfile(profileName, profile))
+		build.Substitutions["_IMAGE_TAG"] = imageTag
+	}
+	s.addToolcacheSteps(build, profile)
+
+	// Like a failed build, a refused runner is left for GitHub to remove once
+	// its JIT configuration goes unused.
+	image := fmt.Sprintf("%s/%s:%s", build.GetSubstitutions()["_REPOSITORY_ID"], build.GetSubstitutions()["_IMAGE_NAME"], imageTag)
+	digest, refused := s.checkRunnerImage(ctx, image, logFields)
+	if refused != nil {
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, refused
+	}
+	if digest != "" {
+		// Docker ignores the tag of a reference with a digest, the tag is
+		// kept for the logs of the build.
+		build.Substitutions["_IMAGE_TAG"] = imageTag + "@" + digest
+		logFields = append(logFields, "image_digest", digest)
+	}
+	if s.imageVulnerable(ctx, image, logFields) {
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, &apiResponse{http.StatusOK, runnerImageVulnerableMsg, nil}
+	}
+
+	// Builds in a private pool must be created in the location of the pool.
+	route := s.runnerProjectRoute(req.Org, req.Repo)
+	location := s.runnerLocation
+	if route != nil && route.Location != "" {
+		location = route.Location
+	}
+	if pool, name, _ := s.runnerWorkerPool(req.Labels); name != "" {
+		poolLocation, err := workerPoolLocation(pool)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to determine worker pool location", append(logFields, "error", err)...)
+			return nil, &apiResponse{http.StatusInternalServerError, "invalid worker pool", err}
+		}
+		location = poolLocation
+	}
+
+	// The project is recorded on the tracked runner and the build, so jobs
+	// stay traceable to their build whichever project it runs in.
+	projectID := s.runnerProject()
+	if route != nil {
+		projectID = route.ProjectID
+	}
+	logFields = append(logFields, "runner_project_id", projectID)
+	buildReq := &cloudbuildpb.CreateBuildRequest{
+		Parent:    fmt.Sprintf("projects/%s/locations/%s", projectID, location),
+		ProjectId: projectID,
+		Build:     build,
+	}
+
+	if s.shadowMode {
+		return s.shadowDispatch(ctx, buildReq, profileName, logFields), nil
+	}
+
+	secretName, err := s.moveJITConfigToSecret(ctx, build, req.RunnerName)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to store JIT config in a secret", append(logFields, "error", err)...)
+		s.notifyDispatchFailure(ctx, req, "failed to store JIT config", err)
+		s.recordDispatchOutcome(ctx, true)
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to store JIT config", err}
+	}
+
+	accelerator, _, _ := s.runnerGPU(req.Labels)
+	spec := &RunnerSpec{
+		RunnerName:  req.RunnerName,
+		Labels:      req.Labels,
+		ProjectID:   projectID,
+		Location:    location,
+		Spot:        s.spotCapacity(req),
+		Accelerator: accelerator,
+		Arch:        runnerArch(req.Labels),
+		Build:       build,
+	}
+	backendName, backend := s.runnerBackendFor(req.Labels)
+	backendID, err := s.provisionInBackend(ctx, backendName, backend, req, spec)
+	if status.Code(err) == codes.ResourceExhausted {
+		s.provisioningHistory.recordQuotaExhausted(time.Now())
+	}
+	if backendName == runnerBackendCloudBuild && status.Code(err) == codes.ResourceExhausted {
+		backendID, err = s.provisionInFallbackLocations(ctx, backend, spec, err, logFields)
+	}
+	// The fallback instance group has x86-64 VMs only.
+	if s.fallbackBackend != nil && backendName == runnerBackendCloudBuild && spec.Arch != runnerArchARM64 &&
+		status.Code(err) == codes.ResourceExhausted {
+		logger.WarnContext(ctx, "cloud build quota exhausted, falling back to instance group",
+			append(logFields, "instance_group", s.fallbackGroup.String(), "error", err)...)
+		backendName = runnerBackendFallback
+		spec.ProjectID = s.fallbackGroup.Project
+		backendID, err = s.fallbackBackend.Provision(ctx, spec)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to provision runner", append(logFields, "backend", backendName, "error", err)...)
+		if secretName != "" {
+			if err := s.secrets.DeleteSecret(ctx, secretName); err != nil {
+				logger.WarnContext(ctx, "failed to delete JIT config secret", append(logFields, "secret", secretName, "error", err)...)
+			}
+		}
+		s.notifyDispatchFailure(ctx, req, "failed to run build", err)
+		s.recordDispatchOutcome(ctx, true)
+		s.transitionLifecycle(&state, lifecycle.StateFailed, time.Now())
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to run build", err}
+	}
+	logFields = append(logFields, "backend", backendName, "backend_id", backendID, "runner_location", spec.Location)
+
+	// Backends other than Cloud Build have no build, the dispatch records its
+	// project only.
+	createdBuild := &cloudbuildpb.Build{ProjectId: spec.ProjectID}
+	if backendName == runnerBackendCloudBuild {
+		createdBuild.Id = buildIDFromName(backendID)
+		createdBuild.LogsBucket = build.GetLogsBucket()
+	}
+
+	s.recordDispatchOutcome(ctx, false)
+	s.transitionLifecycle(&state, lifecycle.StateProvisioning, time.Now())
+	if track != "" {
+		s.metrics.recordImageDispatch(profileName, imageTag, track)
+	}
+
+	tracked := &trackedRunner{
+		RunnerName:     req.RunnerName,
+		DeliveryID:     req.DeliveryID,
+		InstallationID: req.InstallationID,
+		Org:            req.Org,
+		Repo:           req.Repo,
+		Actor:          req.Actor,
+		Labels:         req.Labels,
+		ProjectID:      projectID,
+		Location:       spec.Location,
+		BuildID:        createdBuild.GetId(),
+		ImageTag:       imageTag,
+		LogsObject:     runnerLogsObject(createdBuild),
+		Backend:        backendName,
+		BackendID:      backendID,
+		Spot:           spec.Spot,
+		Relaunches:     req.Relaunches,
+		Lifecycle:      state,
+		Reusable:       s.reusableRunners(),
+	}
+	if mapping != nil {
+		tracked.RunnerGroupID = mapping.RunnerGroupID
+	}
+	if job := req.Job.GetWorkflowJob(); job != nil {
+		tracked.RunID = job.GetRunID()
+		tracked.JobID = job.GetID()
+		tracked.HeadSHA = job.GetHeadSHA()
+	}
+	s.runners.Dispatched(tracked)
+	s.saveRunnerState(ctx, req.RunnerName)
+	s.checkRunnerBudget(ctx)
+	s.provisioningHistory.recordDispatch(time.Now(), s.runners.Count())
+
+	return createdBuild, nil
+}
+
+// runnerProject returns the project the next runner build is created in.
+func (s *Server) runnerProject() string {
+	if s.runnerProjects == nil {
+		return s.runnerProjectID
+	}
+	return s.runnerProjects.Pick(s.runners.CountProject)
+}
+
+// runnerTargetGone audits a runner request that can never be fulfilled because
+// the repository or installation is gone. It responds with success, an error
+// would make GitHub redeliver the event and page on a problem nobody can fix.
+func (s *Server) runnerTargetGone(ctx context.Context, req *runnerRequest, logFields []any, err error) *apiResponse {
+	logging.FromContext(ctx).WarnContext(ctx, "ignoring runner request for a repository or installation that is gone",
+		append(logFields,
+			"installation_id", req.InstallationID,
+			"org", req.Org,
+			"repo", req.Repo,
+			"error", err)...)
+	return &apiResponse{http.StatusOK, runnerTargetGoneMsg, nil}
+}
+
+// runnerBuild returns the Cloud Build build running a runner with the given
+// JIT configuration.
+func (s *Server) runnerBuild(req *runnerRequest, encodedJITConfig string) *cloudbuildpb.Build {
+	imageTag := s.runnerImageTag
+	if m := s.runnerImageMapping(req.Labels); m != nil && m.Tag != "" {
+		imageTag = m.Tag
+	}
+	if s.environment == "autopush" {
+		for _, label := range req.Labels {
+			if strings.HasPrefix(label, "pr-") {
+				imageTag = label
+				break
+			}
+		}
+	}
+
+	imageName, _ := s.runnerImage(req.Labels)
+	repositoryID, _, _ := s.runnerRepository(req.Org, req.Repo, req.Labels)
+
+	serviceAccount := s.runnerServiceAccount
+	if route := s.runnerProjectRoute(req.Org, req.Repo); route != nil && route.ServiceAccount != "" {
+		serviceAccount = route.ServiceAccount
+	}
+
+	securityMode := s.runnerSecurityMode
+	if securityMode == "" {
+		securityMode = runnerSecurityPrivileged
+	}
+	securityArgs, _ := runnerSecurityArgs(securityMode)
+
+	build := &cloudbuildpb.Build{
+		ServiceAccount: serviceAccount,
+		Steps: []*cloudbuildpb.BuildStep{
+			{
+				Id:         "run",
+				Name:       "gcr.io/cloud-builders/docker",
+				Entrypoint: "bash",
+				// The JIT configuration is passed through the environment, so
+				// it can be resolved from a secret instead.
+				Env: []string{jitConfigEnv + "=$_ENCODED_JIT_CONFIG"},
+				Args: []string{
+					"-c",
+					// The security args depend on how Docker-in-Docker runs, see
+					// runnerSecurityArgs.
+					// The cloudbuild network exposes the metadata server, which is needed to
+					// authenticate to Cloud Storage when the toolcache is mounted.
+					"docker run $_SECURITY_ARGS --network=$_DOCKER_NETWORK -e ENCODED_JIT_CONFIG -e RUNNER_SECURITY_MODE=$_SECURITY_MODE -e DOCKER_REGISTRY_MIRRORS=$_REGISTRY_MIRRORS -e DOCKER_INSECURE_REGISTRIES=$_INSECURE_REGISTRIES -e TOOLCACHE_GCS_BUCKET=$_TOOLCACHE_BUCKET -e RUNNER_CACHE_PATHS=$_CACHE_PATHS -e HTTP_PROXY=$_HTTP_PROXY -e HTTPS_PROXY=$_HTTPS_PROXY -e NO_PROXY=$_NO_PROXY -e ARTIFACTS_URL=$_ARTIFACTS_URL -e ARTIFACTS_TOKEN=$_ARTIFACTS_TOKEN -e ACTIONS_CACHE_URL=$_ACTIONS_CACHE_URL -e JOB_STARTED_HOOK=$_JOB_STARTED_HOOK -e JOB_COMPLETED_HOOK=$_JOB_COMPLETED_HOOK -e RUNNER_METADATA_ENV=$_METADATA_ENV $_EXTRA_ENV_ARGS $_METADATA_ENV_ARGS $_SECRET_ENV_ARGS $_TOOLCACHE_ARGS $_CACHE_MOUNTS $_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
+				},
+			},
+		},
+		Options: &cloudbuildpb.BuildOptions{
+			Logging: cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY,
+		},
+		Tags: buildTags(req),
+		Substitutions: map[string]string{
+			"_ENCODED_JIT_CONFIG":  encodedJITConfig,
+			"_REPOSITORY_ID":       repositoryID,
+			"_IMAGE_NAME":          imageName,
+			"_IMAGE_TAG":           imageTag,
+			"_REGISTRY_MIRRORS":    strings.Join(s.runnerRegistryMirrors, ","),
+			"_INSECURE_REGISTRIES": strings.Join(s.runnerInsecureRegistries, ","),
+			"_TOOLCACHE_BUCKET":    s.runnerToolcacheBucket,
+			"_TOOLCACHE_ARGS":      "",
+			"_DOCKER_NETWORK":      "bridge",
+			"_CACHE_MOUNTS":        "",
+			"_CACHE_PATHS":         "",
+			"_HTTP_PROXY":          s.runnerHTTPProxy,
+			"_HTTPS_PROXY":         s.runnerHTTPSProxy,
+			"_NO_PROXY":            s.runnerNoProxy,
+			"_ARTIFACTS_URL":       "",
+			"_ARTIFACTS_TOKEN":     "",
+			"_ACTIONS_CACHE_URL":   "",
+			"_JOB_STARTED_HOOK":    "",
+			"_JOB_COMPLETED_HOOK":  "",
+			"_EXTRA_ENV_ARGS":      "",
+			"_METADATA_ENV_ARGS":   "",
+			"_METADATA_ENV":        "",
+			"_SECRET_ENV_ARGS":     "",
+			"_SECURITY_MODE":       securityMode,
+			"_SECURITY_ARGS":       securityArgs,
+		},
+	}
+
+	if s.runnerToolcacheBucket != "" {
+		build.Substitutions["_DOCKER_NETWORK"] = "cloudbuild"
+	}
+
+	if s.runnerBuildTimeout > 0 {
+		build.Timeout = durationpb.New(s.runnerBuildTimeout)
+	}
+
+	// Machine type and disk size are set by the pool for builds in a private
+	// pool.
+	if pool, _, _ := s.runnerWorkerPool(req.Labels); pool != "" {
+		build.Options.Pool = &cloudbuildpb.BuildOptions_PoolOption{
+			Name: pool,
+		}
+	} else {
+		build.Options.MachineType = s.runnerBuildMachineType
+		build.Options.DiskSizeGb = s.runnerBuildDiskSizeGB
+	}
+	s.applyBuildTemplate(build)
+	s.addExtraSubstitutions(build)
+	s.addRunnerSecrets(build)
+	s.addLogsBucket(build, req)
+	s.addArtifactsToken(build, req, time.Now())
+	s.addActionsCacheURL(build, req, time.Now())
+	s.addJobHooks(build)
+	return build
+}
+
+// buildTags returns the tags of the build running the runner, so builds can be
+// found from the GitHub delivery, runner, job and repository that requested
+// them and filtered by the workflow, branch, commit, event and actor they ran
+// for.
+func buildTags(req *runnerRequest) []string {
+	event := "repository_dispatch"
+	if req.Job != nil {
+		event = "workflow_job"
+	}
+
+	var tags []string
+	// Delivery IDs are GUIDs, which are valid tags.
+	if req.DeliveryID != "" {
+		tags = append(tags, "delivery-"+req.DeliveryID)
+	}
+	tags = append(tags, "event-"+event)
+	// Runner names are valid tags, the tags find the builds of a job.
+	if req.RunnerName != "" {
+		tags = append(tags, "runner-"+req.RunnerName)
+	}
+	if id := req.Job.GetWorkflowJob().GetID(); id != 0 {
+		tags = append(tags, "job-"+strconv.FormatInt(id, 10))
+	}
+	// The repository tags find the runner of an orphaned build.
+	if req.InstallationID != 0 {
+		tags = append(tags, "installation-"+strconv.FormatInt(req.InstallationID, 10))
+	}
+	for _, kv := range [][2]string{{"org", req.Org}, {"repo", req.Repo}} {
+		if tag, ok := buildTag(kv[0], kv[1]); ok {
+			tags = append(tags, tag)
+		}
+	}
+	if tag, ok := buildTag("actor", req.Actor); ok {
+		tags = append(tags, tag)
+	}
+	if job := req.Job.GetWorkflowJob(); job != nil {
+		for _, kv := range [][2]string{
+			{"workflow", job.GetWorkflowName()},
+			{"branch", job.GetHeadBranch()},
+			{"sha", job.GetHeadSHA()},
+		} {
+			if tag, ok := buildTag(kv[0], kv[1]); ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// buildTagInvalidRegexp matches the characters Cloud Build tags cannot
+// contain.
+var buildTagInvalidRegexp = regexp.MustCompile(`[^\w.-]+`)
+
+// buildTag returns the tag "<key>-<value>", with the characters tags cannot
+// contain replaced and truncated to the maximum tag length. It returns false
+// if the value is empty.
+func buildTag(key, value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	tag := key + "-" + buildTagInvalidRegexp.ReplaceAllString(value, "_")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag, true
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestBuildTags(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		req  *runnerRequest
+		want []string
+	}{
+		{
+			name: "workflow_job",
+			req: &runnerRequest{
+				InstallationID: 123,
+				Org:            "google",
+				Repo:           "webhook",
+				Actor:          "octocat",
+				DeliveryID:     "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+				RunnerName:     "GCP-1234",
+				Job: &github.WorkflowJobEvent{
+					WorkflowJob: &github.WorkflowJob{
+						ID:           github.Ptr(int64(42)),
+						WorkflowName: github.Ptr("CI / Build & Test"),
+						HeadBranch:   github.Ptr("feature/new-runner"),
+						HeadSHA:      github.Ptr("d6fde92930d4715a2b49857d24b940956b26d2d3"),
+					},
+				},
+			},
+			want: []string{
+				"delivery-72d3162e-cc78-11e3-81ab-4c9367dc0958",
+				"event-workflow_job",
+				"runner-GCP-1234",
+				"job-42",
+				"installation-123",
+				"org-google",
+				"repo-webhook",
+				"actor-octocat",
+				"workflow-CI_Build_Test",
+				"branch-feature_new-runner",
+				"sha-d6fde92930d4715a2b49857d24b940956b26d2d3",
+			},
+		},
+		{
+			name: "repository_dispatch",
+			req: &runnerRequest{
+				Actor:      "deploy-bot[bot]",
+				DeliveryID: "delivery",
+			},
+			want: []string{"delivery-delivery", "event-repository_dispatch", "actor-deploy-bot_bot_"},
+		},
+		{
+			name: "long_branch",
+			req: &runnerRequest{
+				Job: &github.WorkflowJobEvent{
+					WorkflowJob: &github.WorkflowJob{
+						HeadBranch: github.Ptr(strings.Repeat("a", 200)),
+					},
+				},
+			},
+			want: []string{"event-workflow_job", "branch-" + strings.Repeat("a", 121)},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, buildTags(tc.req)); diff != "" {
+				t.Errorf("unexpected build tags (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
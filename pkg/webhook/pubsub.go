@@ -0,0 +1,113 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/api/option"
+)
+
+// PubSub publishes lifecycle events to a single Pub/Sub topic.
+type PubSub struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+
+	// pending tracks the published messages whose result is not known yet.
+	pending sync.WaitGroup
+}
+
+// NewPubSub creates a new instance of a PubSub publisher for the topic in the
+// form "projects/<project_id>/topics/<topic_id>".
+func NewPubSub(ctx context.Context, topicName string, opts ...option.ClientOption) (*PubSub, error) {
+	projectID, topicID, err := parseTopicName(topicName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new pubsub client: %w", err)
+	}
+
+	return &PubSub{
+		client: client,
+		topic:  client.Topic(topicID),
+	}, nil
+}
+
+// Publish queues the event as a JSON message. The event type and repository
+// are also attached as message attributes so subscribers can filter without
+// decoding the body. The client batches and sends the message in the
+// background, so Publish does not wait for the server to acknowledge it;
+// failures are logged once the result is known.
+func (p *PubSub) Publish(ctx context.Context, event *LifecycleEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lifecycle event: %w", err)
+	}
+
+	res := p.topic.Publish(ctx, &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			"event_type": string(event.Type),
+			"org":        event.Org,
+			"repo":       event.Repo,
+		},
+	})
+
+	// The result outlives the delivery, keep its logger but not its
+	// cancellation.
+	ctx = context.WithoutCancel(ctx)
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+
+		if _, err := res.Get(ctx); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to publish lifecycle event",
+				"event_type", event.Type,
+				"gh_job_id", event.JobID,
+				"error", err)
+		}
+	}()
+	return nil
+}
+
+// Close flushes pending messages and releases any resources held by the
+// PubSub client.
+func (p *PubSub) Close() error {
+	p.topic.Stop()
+	p.pending.Wait()
+	if err := p.client.Close(); err != nil {
+		return fmt.Errorf("failed to close PubSub client: %w", err)
+	}
+	return nil
+}
+
+// parseTopicName splits a fully qualified topic name into its project and
+// topic IDs.
+func parseTopicName(name string) (string, string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+		return "", "", fmt.Errorf("invalid topic name %q, expected projects/<project_id>/topics/<topic_id>", name)
+	}
+	return parts[1], parts[3], nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+type MockEventPublisher struct {
+	mu         sync.Mutex
+	events     []*LifecycleEvent
+	publishErr error
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, event *LifecycleEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, event)
+	return m.publishErr
+}
+
+func (m *MockEventPublisher) Close() error {
+	return nil
+}
+
+// Events returns a copy of the events published so far.
+func (m *MockEventPublisher) Events() []*LifecycleEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*LifecycleEvent(nil), m.events...)
+}
@@ -0,0 +1,81 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestPubSub_Publish(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	topicName := "projects/test-project/topics/lifecycle"
+	if _, err := srv.GServer.CreateTopic(ctx, &pubsubpb.Topic{Name: topicName}); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := NewPubSub(ctx, topicName,
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Publish must not wait for the delivery's context: the message is sent
+	// in the background, even once the delivery was answered.
+	reqCtx, cancel := context.WithCancel(ctx)
+	if err := ps.Publish(reqCtx, &LifecycleEvent{
+		Type:  LifecycleEventQueued,
+		Org:   "google",
+		Repo:  "repo",
+		JobID: 789,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if err := ps.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := srv.Messages()
+	if got, want := len(msgs), 1; got != want {
+		t.Fatalf("expected %d messages to be %d", got, want)
+	}
+	if got, want := msgs[0].Attributes["event_type"], string(LifecycleEventQueued); got != want {
+		t.Errorf("expected event_type %q to be %q", got, want)
+	}
+	var event LifecycleEvent
+	if err := json.Unmarshal(msgs[0].Data, &event); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := event.JobID, int64(789); got != want {
+		t.Errorf("expected job ID %d to be %d", got, want)
+	}
+}
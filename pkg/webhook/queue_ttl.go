@@ -0,0 +1,40 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// runnerJobStaleMsg is the response to a queued event of a job queued for
+// longer than QUEUE_TTL.
+var runnerJobStaleMsg = "no action taken for job older than the queue TTL"
+
+// jobStale reports whether the job of a queued event was created more than
+// QUEUE_TTL ago, and its age. A runner launched for such a job, usually from a
+// redelivered or replayed event, would find it picked up, cancelled or timed
+// out long ago. A job gated by a deployment environment is only queued once
+// approved, its age includes the wait for the approval and it is never stale
+// while known to be waiting.
+func (s *Server) jobStale(event *github.WorkflowJobEvent, now time.Time) (time.Duration, bool) {
+	createdAt := event.GetWorkflowJob().GetCreatedAt()
+	if s.queueTTL <= 0 || createdAt.IsZero() {
+		return 0, false
+	}
+	age := now.Sub(createdAt.Time)
+	return age, age > s.queueTTL && !s.waitingJobs.Waiting(event.GetWorkflowJob().GetID())
+}
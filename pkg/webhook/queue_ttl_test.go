@@ -0,0 +1,86 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestJobStale(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		queueTTL  time.Duration
+		createdAt *github.Timestamp
+		waiting   bool
+		wantAge   time.Duration
+		wantStale bool
+	}{
+		{
+			name:      "fresh",
+			queueTTL:  time.Hour,
+			createdAt: &github.Timestamp{Time: now.Add(-10 * time.Minute)},
+			wantAge:   10 * time.Minute,
+		},
+		{
+			name:      "stale",
+			queueTTL:  time.Hour,
+			createdAt: &github.Timestamp{Time: now.Add(-2 * time.Hour)},
+			wantAge:   2 * time.Hour,
+			wantStale: true,
+		},
+		{
+			name:      "waiting_for_approval",
+			queueTTL:  time.Hour,
+			createdAt: &github.Timestamp{Time: now.Add(-2 * time.Hour)},
+			waiting:   true,
+			wantAge:   2 * time.Hour,
+		},
+		{
+			name:      "disabled",
+			createdAt: &github.Timestamp{Time: now.Add(-48 * time.Hour)},
+		},
+		{
+			name:     "no_created_at",
+			queueTTL: time.Hour,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{queueTTL: tc.queueTTL, waitingJobs: newWaitingJobs()}
+			event := &github.WorkflowJobEvent{WorkflowJob: &github.WorkflowJob{ID: github.Ptr(int64(789)), CreatedAt: tc.createdAt}}
+			if tc.waiting {
+				s.waitingJobs.Add(event, now)
+			}
+
+			age, stale := s.jobStale(event, now)
+			if got, want := age, tc.wantAge; got != want {
+				t.Errorf("expected %s to be %s", got, want)
+			}
+			if got, want := stale, tc.wantStale; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
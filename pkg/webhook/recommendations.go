@@ -0,0 +1,367 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+const (
+	// maxHistorySamples bounds each series of the provisioning history, so a
+	// burst of jobs cannot grow it without limit.
+	maxHistorySamples = 10000
+
+	// quotaHeadroom is the margin above the peak number of runners in flight
+	// recommended when requesting more concurrent build quota.
+	quotaHeadroom = 1.25
+)
+
+// RecommendationReport holds the scaling recommendations computed from the
+// runners an instance dispatched over the recommendation window.
+type RecommendationReport struct {
+	GeneratedAt   time.Time                  `json:"generated_at"`
+	WindowSeconds float64                    `json:"window_seconds"`
+	WarmPools     []*WarmPoolRecommendation  `json:"warm_pools"`
+	Concurrency   *ConcurrencyRecommendation `json:"concurrency,omitempty"`
+	Quota         *QuotaRecommendation       `json:"quota"`
+}
+
+// WarmPoolRecommendation is the number of idle runners to keep ready for the
+// jobs requesting a set of labels, so they do not wait for a runner to
+// provision.
+type WarmPoolRecommendation struct {
+	Labels            string  `json:"labels"`
+	Runners           int     `json:"runners"`
+	P50LatencySeconds float64 `json:"p50_latency_seconds"`
+	P90LatencySeconds float64 `json:"p90_latency_seconds"`
+	Size              int     `json:"size"`
+	Reason            string  `json:"reason,omitempty"`
+}
+
+// ConcurrencyRecommendation is the number of runners to dispatch from the
+// dispatch queue at the same time, RUNNER_DISPATCH_CONCURRENCY.
+type ConcurrencyRecommendation struct {
+	Current       int    `json:"current"`
+	P90QueueDepth int    `json:"p90_queue_depth"`
+	MaxQueueDepth int    `json:"max_queue_depth"`
+	Recommended   int    `json:"recommended"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// QuotaRecommendation is the Cloud Build concurrent build quota to request,
+// set when builds were refused for exhausted quota.
+type QuotaRecommendation struct {
+	PeakRunners       int    `json:"peak_runners"`
+	Exhaustions       int    `json:"exhaustions"`
+	RecommendedBuilds int    `json:"recommended_concurrent_builds,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+}
+
+// historySample is a value recorded at a point in time, with the labels of
+// the runner it was recorded for, if any.
+type historySample struct {
+	at     time.Time
+	labels string
+	value  float64
+}
+
+// provisioningHistory records, over a sliding window, how long the runners
+// this instance dispatched took to provision, how many were in flight, how
+// deep the dispatch queue got and how often Cloud Build quota ran out, and
+// computes scaling recommendations from it. The history is kept in memory, so
+// every instance recommends from the runners it dispatched since it started.
+// A nil history records nothing.
+type provisioningHistory struct {
+	window        time.Duration
+	targetLatency time.Duration
+	started       time.Time
+
+	mu               sync.Mutex
+	latencies        []historySample
+	inFlight         []historySample
+	queueDepths      []historySample
+	quotaExhaustions []historySample
+	report           *RecommendationReport
+}
+
+func newProvisioningHistory(window, targetLatency time.Duration, now time.Time) *provisioningHistory {
+	return &provisioningHistory{
+		window:        window,
+		targetLatency: targetLatency,
+		started:       now,
+	}
+}
+
+// appendSample adds the sample to the series, dropping the samples older than
+// the window and the oldest samples past maxHistorySamples.
+func (h *provisioningHistory) appendSample(series []historySample, sample historySample) []historySample {
+	cutoff := sample.at.Add(-h.window)
+	i, _ := slices.BinarySearchFunc(series, cutoff, func(s historySample, t time.Time) int {
+		return s.at.Compare(t)
+	})
+	series = append(series[i:], sample)
+	if len(series) > maxHistorySamples {
+		series = series[len(series)-maxHistorySamples:]
+	}
+	return series
+}
+
+// recordLatency records how long a runner for the labels took from dispatch
+// to coming online.
+func (h *provisioningHistory) recordLatency(at time.Time, labels []string, latency time.Duration) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.latencies = h.appendSample(h.latencies, historySample{at: at, labels: labelsKey(labels), value: latency.Seconds()})
+}
+
+// recordDispatch records the number of runners in flight after dispatching
+// one.
+func (h *provisioningHistory) recordDispatch(at time.Time, inFlight int) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.inFlight = h.appendSample(h.inFlight, historySample{at: at, value: float64(inFlight)})
+}
+
+// recordQueueDepth records the depth of the dispatch queue after enqueuing a
+// runner.
+func (h *provisioningHistory) recordQueueDepth(at time.Time, depth int) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.queueDepths = h.appendSample(h.queueDepths, historySample{at: at, value: float64(depth)})
+}
+
+// recordQuotaExhausted records a runner build refused for exhausted quota.
+func (h *provisioningHistory) recordQuotaExhausted(at time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.quotaExhaustions = h.appendSample(h.quotaExhaustions, historySample{at: at, value: 1})
+}
+
+// Report returns the last computed report, nil if none was computed yet.
+func (h *provisioningHistory) Report() *RecommendationReport {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.report
+}
+
+// recommend computes the recommendations from the samples of the window
+// ending at now and keeps the report. concurrency and queueSize describe the
+// dispatch queue, 0 when it is disabled.
+func (h *provisioningHistory) recommend(now time.Time, concurrency, queueSize int) *RecommendationReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Rates are measured over the time the instance has been recording, so a
+	// young instance does not underestimate them.
+	cutoff := now.Add(-h.window)
+	elapsed := h.window
+	if h.started.After(cutoff) {
+		cutoff, elapsed = h.started, now.Sub(h.started)
+	}
+	inWindow := func(series []historySample) []historySample {
+		i, _ := slices.BinarySearchFunc(series, cutoff, func(s historySample, t time.Time) int {
+			return s.at.Compare(t)
+		})
+		return series[i:]
+	}
+
+	report := &RecommendationReport{
+		GeneratedAt:   now,
+		WindowSeconds: elapsed.Seconds(),
+		WarmPools:     make([]*WarmPoolRecommendation, 0),
+	}
+
+	byLabels := make(map[string][]float64)
+	for _, s := range inWindow(h.latencies) {
+		byLabels[s.labels] = append(byLabels[s.labels], s.value)
+	}
+	for _, labels := range slices.Sorted(maps.Keys(byLabels)) {
+		latencies := byLabels[labels]
+		slices.Sort(latencies)
+		rec := &WarmPoolRecommendation{
+			Labels:            labels,
+			Runners:           len(latencies),
+			P50LatencySeconds: percentile(latencies, 50),
+			P90LatencySeconds: percentile(latencies, 90),
+		}
+		// By Little's law, the runners provisioning at any time are the arrival
+		// rate times the provisioning latency. A pool of that size absorbs the
+		// jobs arriving while replacements provision.
+		if elapsed > 0 && rec.P90LatencySeconds > h.targetLatency.Seconds() {
+			rate := float64(rec.Runners) / elapsed.Seconds()
+			rec.Size = max(1, int(math.Ceil(rate*rec.P90LatencySeconds)))
+			rec.Reason = "p90 provisioning latency is above the target latency"
+		}
+		report.WarmPools = append(report.WarmPools, rec)
+	}
+
+	if concurrency > 0 {
+		var depths []float64
+		for _, s := range inWindow(h.queueDepths) {
+			depths = append(depths, s.value)
+		}
+		slices.Sort(depths)
+		rec := &ConcurrencyRecommendation{
+			Current:       concurrency,
+			P90QueueDepth: int(percentile(depths, 90)),
+			Recommended:   concurrency,
+		}
+		if len(depths) > 0 {
+			rec.MaxQueueDepth = int(depths[len(depths)-1])
+		}
+		if rec.P90QueueDepth > concurrency {
+			rec.Recommended = min(rec.P90QueueDepth, queueSize)
+			rec.Reason = "the dispatch queue is usually deeper than the dispatch concurrency"
+		}
+		report.Concurrency = rec
+	}
+
+	quota := &QuotaRecommendation{
+		Exhaustions: len(inWindow(h.quotaExhaustions)),
+	}
+	for _, s := range inWindow(h.inFlight) {
+		quota.PeakRunners = max(quota.PeakRunners, int(s.value))
+	}
+	if quota.Exhaustions > 0 {
+		quota.RecommendedBuilds = int(math.Ceil(float64(max(quota.PeakRunners, 1)) * quotaHeadroom))
+		quota.Reason = "Cloud Build refused runner builds for exhausted quota"
+	}
+	report.Quota = quota
+
+	h.report = report
+	return report
+}
+
+// percentile returns the nearest-rank percentile of the sorted values, 0 if
+// there are none.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// labelsKey returns the labels of a runner in a stable order, to group the
+// runners requesting the same labels.
+func labelsKey(labels []string) string {
+	return strings.Join(slices.Sorted(slices.Values(labels)), ",")
+}
+
+// recordProvisioningLatency records how long the runner took from dispatch to
+// coming online, or to picking up its job if it was not seen online.
+func (s *Server) recordProvisioningLatency(runnerName string) {
+	var r trackedRunner
+	if !s.runners.Update(runnerName, func(tr *trackedRunner) { r = *tr }) {
+		return
+	}
+
+	dispatched := r.Lifecycle.EnteredAt(lifecycle.StateDispatching)
+	online := r.Lifecycle.EnteredAt(lifecycle.StateOnline)
+	if online.IsZero() {
+		online = r.Lifecycle.EnteredAt(lifecycle.StateRunning)
+	}
+	// A reused runner was provisioned for its first job only.
+	if dispatched.IsZero() || online.IsZero() || r.JobsServed > 0 {
+		return
+	}
+	s.provisioningHistory.recordLatency(time.Now(), r.Labels, online.Sub(dispatched))
+}
+
+// runRecommender periodically computes the scaling recommendations until the
+// context is cancelled.
+func (s *Server) runRecommender(ctx context.Context) {
+	ticker := time.NewTicker(s.recommendationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.recommend(ctx)
+		}
+	}
+}
+
+// recommend computes and logs the scaling recommendations.
+func (s *Server) recommend(ctx context.Context) *RecommendationReport {
+	var concurrency, queueSize int
+	if s.dispatchQueue != nil {
+		concurrency, queueSize = s.dispatchQueue.concurrency, cap(s.dispatchQueue.items)
+	}
+	report := s.provisioningHistory.recommend(time.Now(), concurrency, queueSize)
+
+	logging.FromContext(ctx).InfoContext(ctx, "computed scaling recommendations",
+		"warm_pools", report.WarmPools,
+		"concurrency", report.Concurrency,
+		"quota", report.Quota)
+	return report
+}
+
+// handleAdminRecommendations responds with the last scaling recommendations,
+// computing them if none were computed yet.
+func (s *Server) handleAdminRecommendations() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.provisioningHistory == nil {
+			s.h.RenderJSON(w, http.StatusNotFound, map[string]string{
+				"error": "scaling recommendations are disabled, RECOMMENDATION_INTERVAL is not set",
+			})
+			return
+		}
+
+		report := s.provisioningHistory.Report()
+		if report == nil {
+			report = s.recommend(r.Context())
+		}
+		s.h.RenderJSON(w, http.StatusOK, report)
+	})
+}
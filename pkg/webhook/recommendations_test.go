@@ -0,0 +1,336 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/abcxyz/pkg/renderer"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+func TestProvisioningHistory_Recommend(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		record      func(h *provisioningHistory)
+		concurrency int
+		queueSize   int
+		want        *RecommendationReport
+	}{
+		{
+			name: "no_history",
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools:     []*WarmPoolRecommendation{},
+				Quota:         &QuotaRecommendation{},
+			},
+		},
+		{
+			name: "warm_pool_above_target",
+			record: func(h *provisioningHistory) {
+				// 36 runners an hour provisioning in 100s keep one runner in
+				// flight on average.
+				for i := range 36 {
+					h.recordLatency(start.Add(time.Duration(i)*time.Minute), []string{"self-hosted", "gpu"}, 100*time.Second)
+				}
+				for i := range 10 {
+					h.recordLatency(start.Add(time.Duration(i)*time.Minute), []string{"self-hosted"}, 20*time.Second)
+				}
+			},
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools: []*WarmPoolRecommendation{
+					{
+						Labels:            "gpu,self-hosted",
+						Runners:           36,
+						P50LatencySeconds: 100,
+						P90LatencySeconds: 100,
+						Size:              1,
+						Reason:            "p90 provisioning latency is above the target latency",
+					},
+					{
+						Labels:            "self-hosted",
+						Runners:           10,
+						P50LatencySeconds: 20,
+						P90LatencySeconds: 20,
+					},
+				},
+				Quota: &QuotaRecommendation{},
+			},
+		},
+		{
+			name: "samples_outside_window",
+			record: func(h *provisioningHistory) {
+				h.recordLatency(start.Add(-2*time.Hour), []string{"self-hosted"}, 100*time.Second)
+				h.recordLatency(start.Add(30*time.Minute), []string{"self-hosted"}, 10*time.Second)
+			},
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools: []*WarmPoolRecommendation{
+					{
+						Labels:            "self-hosted",
+						Runners:           1,
+						P50LatencySeconds: 10,
+						P90LatencySeconds: 10,
+					},
+				},
+				Quota: &QuotaRecommendation{},
+			},
+		},
+		{
+			name: "concurrency_below_queue_depth",
+			record: func(h *provisioningHistory) {
+				for i, depth := range []int{2, 8, 9, 9, 10, 10, 10, 12, 12, 40} {
+					h.recordQueueDepth(start.Add(time.Duration(i)*time.Minute), depth)
+				}
+			},
+			concurrency: 4,
+			queueSize:   1000,
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools:     []*WarmPoolRecommendation{},
+				Concurrency: &ConcurrencyRecommendation{
+					Current:       4,
+					P90QueueDepth: 12,
+					MaxQueueDepth: 40,
+					Recommended:   12,
+					Reason:        "the dispatch queue is usually deeper than the dispatch concurrency",
+				},
+				Quota: &QuotaRecommendation{},
+			},
+		},
+		{
+			name: "concurrency_sufficient",
+			record: func(h *provisioningHistory) {
+				h.recordQueueDepth(start, 1)
+			},
+			concurrency: 4,
+			queueSize:   1000,
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools:     []*WarmPoolRecommendation{},
+				Concurrency: &ConcurrencyRecommendation{
+					Current:       4,
+					P90QueueDepth: 1,
+					MaxQueueDepth: 1,
+					Recommended:   4,
+				},
+				Quota: &QuotaRecommendation{},
+			},
+		},
+		{
+			name: "quota_exhausted",
+			record: func(h *provisioningHistory) {
+				for i, inFlight := range []int{1, 5, 8, 6} {
+					h.recordDispatch(start.Add(time.Duration(i)*time.Minute), inFlight)
+				}
+				h.recordQuotaExhausted(start.Add(5 * time.Minute))
+				h.recordQuotaExhausted(start.Add(6 * time.Minute))
+			},
+			want: &RecommendationReport{
+				WindowSeconds: 3600,
+				WarmPools:     []*WarmPoolRecommendation{},
+				Quota: &QuotaRecommendation{
+					PeakRunners:       8,
+					Exhaustions:       2,
+					RecommendedBuilds: 10,
+					Reason:            "Cloud Build refused runner builds for exhausted quota",
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newProvisioningHistory(time.Hour, time.Minute, start.Add(-24*time.Hour))
+			if tc.record != nil {
+				tc.record(h)
+			}
+
+			now := start.Add(time.Hour)
+			got := h.recommend(now, tc.concurrency, tc.queueSize)
+			tc.want.GeneratedAt = now
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected report (-want, +got):\n%s", diff)
+			}
+			if h.Report() != got {
+				t.Errorf("expected the report to be kept")
+			}
+		})
+	}
+}
+
+func TestProvisioningHistory_YoungInstance(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := newProvisioningHistory(24*time.Hour, time.Minute, start)
+	for i := range 10 {
+		h.recordLatency(start.Add(time.Duration(i)*time.Minute), []string{"self-hosted"}, 6*time.Minute)
+	}
+
+	// 10 runners in 10 minutes, each provisioning for 6 minutes.
+	got := h.recommend(start.Add(10*time.Minute), 0, 0)
+	if got, want := got.WindowSeconds, 600.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+	if got, want := got.WarmPools[0].Size, 6; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestRecordProvisioningLatency(t *testing.T) {
+	t.Parallel()
+
+	dispatched := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name        string
+		states      []lifecycle.State
+		wantLatency float64
+		wantSamples int
+	}{
+		{
+			name:        "online",
+			states:      []lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateOnline, lifecycle.StateRunning},
+			wantLatency: 120,
+			wantSamples: 1,
+		},
+		{
+			name:        "running_before_online",
+			states:      []lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateRunning},
+			wantLatency: 120,
+			wantSamples: 1,
+		},
+		{
+			name:   "not_dispatched",
+			states: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := lifecycle.New(dispatched)
+			at := dispatched
+			for _, state := range tc.states {
+				if state == lifecycle.StateOnline || (state == lifecycle.StateRunning && l.State() == lifecycle.StateProvisioning) {
+					at = dispatched.Add(2 * time.Minute)
+				}
+				if err := l.Transition(state, at); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := &Server{
+				provisioningHistory: newProvisioningHistory(24*time.Hour, time.Minute, dispatched),
+				runners:             newRunnerTracker(),
+			}
+			s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-2", Labels: []string{"self-hosted"}, Lifecycle: l})
+			s.recordProvisioningLatency("GCP-2")
+
+			samples := s.provisioningHistory.latencies
+			if got, want := len(samples), tc.wantSamples; got != want {
+				t.Fatalf("expected %d to be %d", got, want)
+			}
+			if tc.wantSamples > 0 {
+				if got, want := samples[0].value, tc.wantLatency; got != want {
+					t.Errorf("expected %v to be %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleAdminRecommendations(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		disabled bool
+		wantCode int
+	}{
+		{
+			name:     "report",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "disabled",
+			disabled: true,
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := logging.WithLogger(t.Context(), logging.TestLogger(t))
+
+			s := &Server{
+				adminToken: []byte("admin-token"),
+				h:          renderer.NewTesting(ctx, t, nil),
+			}
+			if !tc.disabled {
+				s.provisioningHistory = newProvisioningHistory(time.Hour, time.Minute, time.Now())
+				s.provisioningHistory.recordQuotaExhausted(time.Now())
+			}
+
+			req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/admin/recommendations", nil)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			resp := httptest.NewRecorder()
+			s.adminRoutes().ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.disabled {
+				return
+			}
+
+			var got RecommendationReport
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			want := &QuotaRecommendation{
+				Exhaustions:       1,
+				RecommendedBuilds: 2,
+				Reason:            "Cloud Build refused runner builds for exhausted quota",
+			}
+			if diff := cmp.Diff(want, got.Quota); diff != "" {
+				t.Errorf("unexpected quota (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(&got, s.provisioningHistory.Report(), cmpopts.EquateApproxTime(time.Millisecond)); diff != "" {
+				t.Errorf("expected the computed report to be kept (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
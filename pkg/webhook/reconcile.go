@@ -0,0 +1,327 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// Kinds of orphans the reconciler cleans up, used as the kind label of the
+// reconciled orphans metric.
+const (
+	orphanBuild  = "build"
+	orphanRunner = "runner"
+)
+
+// activeBuildStatuses are the statuses of builds whose runner may still run.
+var activeBuildStatuses = []cloudbuildpb.Build_Status{
+	cloudbuildpb.Build_PENDING,
+	cloudbuildpb.Build_QUEUED,
+	cloudbuildpb.Build_WORKING,
+}
+
+// runnerScope is the repository a runner is registered with.
+type runnerScope struct {
+	InstallationID int64
+	Org            string
+	Repo           string
+}
+
+// reconcileResult is the outcome of a reconciliation pass.
+type reconcileResult struct {
+	// CancelledBuilds are the IDs of the builds cancelled because their
+	// runner was gone.
+	CancelledBuilds []string `json:"cancelled_builds"`
+
+	// RemovedRunners are the names of the runners removed because their
+	// build was gone.
+	RemovedRunners []string `json:"removed_runners"`
+
+	// Suspects is the number of builds and runners found orphaned for the
+	// first time, cleaned up if they still are on the next pass.
+	Suspects int `json:"suspects"`
+
+	// Errors are the repositories and builds that could not be reconciled.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// orphanSuspects are the builds and runners found orphaned by the previous
+// reconciliation pass. An orphan is only cleaned up once two passes in a row
+// found it, so builds running their last steps and runners still coming
+// online are left alone.
+type orphanSuspects struct {
+	mu   sync.Mutex
+	prev map[string]struct{}
+}
+
+func newOrphanSuspects() *orphanSuspects {
+	return &orphanSuspects{prev: make(map[string]struct{})}
+}
+
+// placedBuild is a runner build and the project and location it runs in.
+type placedBuild struct {
+	Build     *cloudbuildpb.Build
+	Placement runnerBuildPlacement
+}
+
+// reconcileUnsupported returns why the runners cannot be reconciled, "" if
+// they can. The reconciler only sees Cloud Build builds, it would remove the
+// runners of the other backends as orphans.
+func (s *Server) reconcileUnsupported() string {
+	switch {
+	case s.backend != nil:
+		return fmt.Sprintf("runners run on the %q backend", s.runnerBackend)
+	case s.batchBackend != nil:
+		return "runners run on Batch"
+	case s.fallbackBackend != nil:
+		return "runners fall back to an instance group"
+	}
+	return ""
+}
+
+// reconcileOrphans cross-references the runner builds still running in every
+// runner project and location, the runners registered with their repositories
+// and the tracked runners. It cancels the builds whose runner is no longer
+// registered, because its job completed or it was removed, and removes the
+// offline runners whose build is gone. Passes are serialized.
+func (s *Server) reconcileOrphans(ctx context.Context) (*reconcileResult, error) {
+	result := &reconcileResult{CancelledBuilds: []string{}, RemovedRunners: []string{}}
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return result, nil
+	}
+	if reason := s.reconcileUnsupported(); reason != "" {
+		return nil, fmt.Errorf("cannot reconcile runners: %s", reason)
+	}
+
+	s.orphans.mu.Lock()
+	defer s.orphans.mu.Unlock()
+
+	logger := logging.FromContext(ctx)
+
+	// Builds by scope and runner name.
+	builds := make(map[runnerScope]map[string]placedBuild)
+	for _, placement := range s.runnerBuildPlacements() {
+		for _, st := range activeBuildStatuses {
+			list, err := s.cbc.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{
+				Parent:    placement.parent(),
+				ProjectId: placement.ProjectID,
+				Filter:    fmt.Sprintf("status=%q", st.String()),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s builds in %s: %w", st, placement.parent(), err)
+			}
+			for _, build := range list {
+				scope, runnerName, ok := buildRunnerScope(build)
+				if !ok {
+					continue
+				}
+				if builds[scope] == nil {
+					builds[scope] = make(map[string]placedBuild)
+				}
+				builds[scope][runnerName] = placedBuild{Build: build, Placement: placement}
+			}
+		}
+	}
+
+	// Runners whose build died are found in the repositories of the tracked
+	// runners and of the running builds.
+	scopes := make(map[runnerScope]struct{}, len(builds))
+	for scope := range builds {
+		scopes[scope] = struct{}{}
+	}
+	for _, r := range s.runners.List() {
+		if r.InstallationID != 0 && r.Org != "" && r.Repo != "" {
+			scopes[runnerScope{InstallationID: r.InstallationID, Org: r.Org, Repo: r.Repo}] = struct{}{}
+		}
+	}
+
+	suspects := make(map[string]struct{})
+	suspect := func(key string) bool {
+		if _, ok := s.orphans.prev[key]; ok {
+			return true
+		}
+		suspects[key] = struct{}{}
+		return false
+	}
+
+	for scope := range scopes {
+		fields := []any{"installation_id", scope.InstallationID, "org", scope.Org, "repo", scope.Repo}
+
+		gh, err := s.installationClient(ctx, scope.InstallationID, map[string]string{
+			"administration": "write",
+		}, scope.Repo)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to setup installation client to reconcile runners", append(fields, "error", err)...)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s/%s: %v", scope.Org, scope.Repo, err))
+			continue
+		}
+		registered, err := s.listRepoRunners(ctx, gh, scope.Org, scope.Repo)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to list runners to reconcile", append(fields, "error", err)...)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s/%s: %v", scope.Org, scope.Repo, err))
+			continue
+		}
+
+		for runnerName, placed := range builds[scope] {
+			build := placed.Build
+			if _, ok := registered[runnerName]; ok {
+				continue
+			}
+			if !suspect("build/" + build.GetId()) {
+				continue
+			}
+
+			if err := s.cloudBuildBackend().Cancel(ctx, placed.Placement.buildName(build.GetId())); err != nil {
+				logger.WarnContext(ctx, "failed to cancel orphaned build", append(fields, "build_id", build.GetId(), "error", err)...)
+				result.Errors = append(result.Errors, fmt.Sprintf("build %s: %v", build.GetId(), err))
+				continue
+			}
+			s.forgetOrphan(ctx, runnerName)
+			s.metrics.recordOrphan(orphanBuild)
+			result.CancelledBuilds = append(result.CancelledBuilds, build.GetId())
+			logger.InfoContext(ctx, "cancelled build of unregistered runner", append(fields, "runner_id", runnerName, "build_id", build.GetId())...)
+		}
+
+		for runnerName, runner := range registered {
+			if _, ok := builds[scope][runnerName]; ok || runner.GetBusy() || runner.GetStatus() != "offline" {
+				continue
+			}
+			if !suspect(fmt.Sprintf("runner/%s/%s/%s", scope.Org, scope.Repo, runnerName)) {
+				continue
+			}
+
+			rctx, cancel := callContext(ctx, s.githubCallTimeout)
+			_, err := gh.Actions.RemoveRunner(rctx, scope.Org, scope.Repo, runner.GetID())
+			cancel()
+			if err != nil {
+				logger.WarnContext(ctx, "failed to remove orphaned runner", append(fields, "runner_id", runnerName, "error", err)...)
+				result.Errors = append(result.Errors, fmt.Sprintf("runner %s: %v", runnerName, err))
+				continue
+			}
+			s.forgetOrphan(ctx, runnerName)
+			s.metrics.recordOrphan(orphanRunner)
+			result.RemovedRunners = append(result.RemovedRunners, runnerName)
+			logger.InfoContext(ctx, "removed offline runner without a build", append(fields, "runner_id", runnerName)...)
+		}
+	}
+
+	s.orphans.prev = suspects
+	result.Suspects = len(suspects)
+	slices.Sort(result.CancelledBuilds)
+	slices.Sort(result.RemovedRunners)
+	s.notifyReconciliation(ctx, result)
+	return result, nil
+}
+
+// listRepoRunners returns the runners provisioned by this service registered
+// with the repository, by name.
+func (s *Server) listRepoRunners(ctx context.Context, gh *github.Client, org, repo string) (map[string]*github.Runner, error) {
+	runners := make(map[string]*github.Runner)
+	opts := &github.ListRunnersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		lctx, cancel := callContext(ctx, s.githubCallTimeout)
+		page, resp, err := gh.Actions.ListRunners(lctx, org, repo, opts)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list runners: %w", err)
+		}
+
+		for _, r := range page.Runners {
+			if strings.HasPrefix(r.GetName(), runnerNamePrefix) {
+				runners[r.GetName()] = r
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return runners, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// forgetOrphan stops tracking the runner of a cleaned up orphan.
+func (s *Server) forgetOrphan(ctx context.Context, runnerName string) {
+	s.transitionRunner(ctx, runnerName, lifecycle.StateOrphaned)
+	if _, ok := s.runners.Remove(runnerName); ok {
+		s.launchQueue.notify()
+	}
+}
+
+// buildRunnerScope returns the repository and name of the runner the build
+// runs, from its tags.
+func buildRunnerScope(build *cloudbuildpb.Build) (runnerScope, string, bool) {
+	var scope runnerScope
+	for _, tag := range build.GetTags() {
+		if v, ok := strings.CutPrefix(tag, "installation-"); ok {
+			scope.InstallationID, _ = strconv.ParseInt(v, 10, 64)
+		} else if v, ok := strings.CutPrefix(tag, "org-"); ok {
+			scope.Org = v
+		} else if v, ok := strings.CutPrefix(tag, "repo-"); ok {
+			scope.Repo = v
+		}
+	}
+	runnerName := buildRunnerName(build)
+	ok := scope.InstallationID != 0 && scope.Org != "" && scope.Repo != "" && runnerName != ""
+	return scope, runnerName, ok
+}
+
+// runReconciler reconciles orphaned builds and runners until ctx is done.
+func (s *Server) runReconciler(ctx context.Context) {
+	ticker := time.NewTicker(s.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.reconcileOrphans(ctx); err != nil {
+				logging.FromContext(ctx).WarnContext(ctx, "failed to reconcile orphaned runners", "error", err)
+			}
+		}
+	}
+}
+
+// handleAdminReconcile runs a reconciliation pass, for example from Cloud
+// Scheduler, and responds with its outcome.
+func (s *Server) handleAdminReconcile() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		result, err := s.reconcileOrphans(ctx)
+		if err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "failed to reconcile orphaned runners", "error", err)
+			s.h.RenderJSON(w, http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		s.h.RenderJSON(w, http.StatusOK, result)
+	})
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/githubauth"
+	"github.com/abcxyz/pkg/renderer"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/testing/fakecloudbuild"
+)
+
+func TestReconcileOrphans(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	var mu sync.Mutex
+	var removed []string
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 5, "runners": [
+			{"id": 1, "name": "GCP-live", "status": "online", "busy": true},
+			{"id": 2, "name": "GCP-dead", "status": "offline", "busy": false},
+			{"id": 3, "name": "GCP-booting", "status": "offline", "busy": false},
+			{"id": 4, "name": "laptop", "status": "offline", "busy": false},
+			{"id": 5, "name": "GCP-routed", "status": "offline", "busy": false}
+		]}`)
+	}))
+	mux.Handle("DELETE /repos/google/webhook/actions/runners/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	fakeGitHub := httptest.NewServer(mux)
+	t.Cleanup(fakeGitHub.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, opts := fakecloudbuild.Start(t)
+	cb, err := NewCloudBuild(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cb.Close() })
+
+	scopeTags := []string{"installation-123", "org-google", "repo-webhook"}
+	for id, build := range map[string]struct {
+		status cloudbuildpb.Build_Status
+		runner string
+	}{
+		"gone":     {cloudbuildpb.Build_WORKING, "GCP-gone"},
+		"live":     {cloudbuildpb.Build_WORKING, "GCP-live"},
+		"booting":  {cloudbuildpb.Build_QUEUED, "GCP-booting"},
+		"finished": {cloudbuildpb.Build_SUCCESS, "GCP-dead"},
+	} {
+		fake.AddBuild(&cloudbuildpb.Build{
+			Id:     id,
+			Status: build.status,
+			Tags:   append([]string{"event-workflow_job", "runner-" + build.runner}, scopeTags...),
+		})
+	}
+	// Builds in the project the repository is routed to are reconciled too.
+	for id, build := range map[string]struct {
+		status cloudbuildpb.Build_Status
+		runner string
+	}{
+		"routed":      {cloudbuildpb.Build_QUEUED, "GCP-routed"},
+		"routed-gone": {cloudbuildpb.Build_WORKING, "GCP-routed-gone"},
+	} {
+		fake.AddBuild(&cloudbuildpb.Build{
+			Id:     id,
+			Name:   "projects/routed-project/locations/europe-west1/builds/" + id,
+			Status: build.status,
+			Tags:   append([]string{"event-workflow_job", "runner-" + build.runner}, scopeTags...),
+		})
+	}
+	// Builds not started by the webhook are left alone.
+	fake.AddBuild(&cloudbuildpb.Build{Id: "unrelated", Status: cloudbuildpb.Build_WORKING})
+
+	notifier := &recordingNotifier{}
+	s := &Server{
+		adminToken:      []byte("admin-token"),
+		appClient:       app,
+		cbc:             cb,
+		ghAPIBaseURL:    fakeGitHub.URL,
+		h:               renderer.NewTesting(ctx, t, nil),
+		notifier:        notifier,
+		orphans:         newOrphanSuspects(),
+		runnerLocation:  "us-central1",
+		runnerProjectID: "runner-project",
+		runnerProjectRoutes: map[string]*RunnerProjectRoute{
+			"google/webhook": {ProjectID: "routed-project", Location: "europe-west1"},
+		},
+		runners: newRunnerTracker(),
+	}
+	s.runners.Dispatched(&trackedRunner{RunnerName: "GCP-gone", InstallationID: 123, Org: "google", Repo: "webhook"})
+
+	reconcile := func() *reconcileResult {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/reconcile", nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		resp := httptest.NewRecorder()
+		s.Routes(ctx).ServeHTTP(resp, req)
+
+		if got, want := resp.Code, http.StatusOK; got != want {
+			t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+		}
+		var result reconcileResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+
+	// The first pass only suspects the orphans.
+	if diff := cmp.Diff(&reconcileResult{CancelledBuilds: []string{}, RemovedRunners: []string{}, Suspects: 3}, reconcile()); diff != "" {
+		t.Errorf("unexpected first pass (-want, +got):\n%s", diff)
+	}
+	if got, want := s.runners.Count(), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	want := &reconcileResult{CancelledBuilds: []string{"gone", "routed-gone"}, RemovedRunners: []string{"GCP-dead"}}
+	if diff := cmp.Diff(want, reconcile()); diff != "" {
+		t.Errorf("unexpected second pass (-want, +got):\n%s", diff)
+	}
+	// Only the pass that cleaned up orphans is notified.
+	if got, want := len(notifier.notifications), 1; got != want {
+		t.Fatalf("expected %d notifications, got %d", want, got)
+	}
+	if got, want := notifier.notifications[0].Kind, NotificationReconciliationSummary; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	statuses := make(map[string]cloudbuildpb.Build_Status)
+	for _, b := range fake.Builds() {
+		statuses[b.GetId()] = b.GetStatus()
+	}
+	wantStatuses := map[string]cloudbuildpb.Build_Status{
+		"gone":      cloudbuildpb.Build_CANCELLED,
+		"live":      cloudbuildpb.Build_WORKING,
+		"booting":   cloudbuildpb.Build_QUEUED,
+		"finished":  cloudbuildpb.Build_SUCCESS,
+		"unrelated": cloudbuildpb.Build_WORKING,
+
+		"routed":      cloudbuildpb.Build_QUEUED,
+		"routed-gone": cloudbuildpb.Build_CANCELLED,
+	}
+	if diff := cmp.Diff(wantStatuses, statuses); diff != "" {
+		t.Errorf("unexpected build statuses (-want, +got):\n%s", diff)
+	}
+
+	var cancelled []string
+	for _, req := range fake.Requests(fakecloudbuild.MethodCancelBuild) {
+		cancelled = append(cancelled, req.(*cloudbuildpb.CancelBuildRequest).GetName())
+	}
+	slices.Sort(cancelled)
+	wantCancelled := []string{
+		"projects/routed-project/locations/europe-west1/builds/routed-gone",
+		"projects/runner-project/locations/us-central1/builds/gone",
+	}
+	if diff := cmp.Diff(wantCancelled, cancelled); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff([]string{"2"}, removed); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if got, want := s.runners.Count(), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestBuildRunnerScope(t *testing.T) {
+	t.Parallel()
+
+	scope, runnerName, ok := buildRunnerScope(&cloudbuildpb.Build{
+		Tags: []string{"event-workflow_job", "runner-GCP-1", "installation-123", "org-google", "repo-webhook"},
+	})
+	if !ok {
+		t.Fatal("expected the build to have a runner scope")
+	}
+	if diff := cmp.Diff(runnerScope{InstallationID: 123, Org: "google", Repo: "webhook"}, scope); diff != "" {
+		t.Errorf("unexpected scope (-want, +got):\n%s", diff)
+	}
+	if got, want := runnerName, "GCP-1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	if _, _, ok := buildRunnerScope(&cloudbuildpb.Build{Tags: []string{"runner-GCP-1"}}); ok {
+		t.Errorf("expected a build without repository tags to have no runner scope")
+	}
+}
+
+func TestReconcileOrphans_Unsupported(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		batchBackend: &batchBackend{},
+		orphans:      newOrphanSuspects(),
+	}
+	_, err := s.reconcileOrphans(t.Context())
+	if diff := testutil.DiffErrString(err, "cannot reconcile runners: runners run on Batch"); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// reportedErrorEventType marks a log entry as an error event for Error
+// Reporting, which groups it by its stack trace. See
+// https://cloud.google.com/error-reporting/docs/formatting-error-messages.
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// recoverPanics recovers panics in the handler chain, so a single malformed
+// delivery fails with a 500 instead of crashing the request with an
+// unstructured log. The panic is logged with its stack and the delivery ID in
+// the format Error Reporting ingests.
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// ErrAbortHandler is how handlers abort a response on purpose, the
+			// http server handles it without logging.
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+
+			ctx := r.Context()
+			deliveryID := firstHeader(r.Header, "X-GitHub-Delivery", ceBinaryModeHeaderBase+ceGitHubDeliveryExt)
+
+			s.metrics.recordPanic()
+			logging.FromContext(ctx).ErrorContext(ctx, "recovered panic in handler",
+				"@type", reportedErrorEventType,
+				"stack_trace", fmt.Sprintf("panic: %v\n\n%s", p, debug.Stack()),
+				deliveryIDLogKey, deliveryID,
+				"method", r.Method,
+				"path", r.URL.Path)
+
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(t.Context(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	s := &Server{}
+	h := s.recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job *github.WorkflowJob
+		w.Write([]byte(*job.Name))
+	}))
+
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/webhook", nil)
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if got, want := resp.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := resp.Body.String(), "internal server error"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entry["delivery_id"], "72d3162e-cc78-11e3-81ab-4c9367dc0958"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := entry["@type"], reportedErrorEventType; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	stack, _ := entry["stack_trace"].(string)
+	if want := "panic: runtime error: invalid memory address or nil pointer dereference"; !strings.HasPrefix(stack, want) {
+		t.Errorf("expected %q to start with %q", stack, want)
+	}
+	if want := "goroutine "; !strings.Contains(stack, want) {
+		t.Errorf("expected %q to contain %q", stack, want)
+	}
+}
+
+func TestRecoverPanics_abortHandler(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{}
+	h := s.recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		p := recover()
+		if err, ok := p.(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+			t.Errorf("expected %v to be %v", p, http.ErrAbortHandler)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/", nil))
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// provisionInFallbackLocations retries a runner build rejected for exhausted
+// Cloud Build quota in each of RUNNER_FALLBACK_LOCATIONS in turn, until one
+// of them accepts it. On success spec.Location is the location that serves
+// the build. Builds in a worker pool are not retried, a pool exists in a
+// single location.
+func (s *Server) provisionInFallbackLocations(ctx context.Context, backend RunnerBackend, spec *RunnerSpec, err error, logFields []any) (string, error) {
+	logger := logging.FromContext(ctx)
+
+	if spec.Build.GetOptions().GetPool() != nil {
+		return "", err
+	}
+
+	primary := spec.Location
+	for _, location := range s.runnerFallbackLocations {
+		if status.Code(err) != codes.ResourceExhausted {
+			break
+		}
+		if location == primary {
+			continue
+		}
+
+		logger.WarnContext(ctx, "cloud build quota exhausted, retrying in fallback location",
+			append(logFields, "runner_location", spec.Location, "fallback_location", location, "error", err)...)
+		spec.Location = location
+		var backendID string
+		backendID, err = backend.Provision(ctx, spec)
+		if err == nil {
+			s.metrics.recordLocationFallback(location)
+			return backendID, nil
+		}
+	}
+	spec.Location = primary
+	return "", err
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProvisionInFallbackLocations(t *testing.T) {
+	t.Parallel()
+
+	exhausted := status.Error(codes.ResourceExhausted, "quota exceeded")
+
+	cases := []struct {
+		name              string
+		fallbackLocations []string
+		build             *cloudbuildpb.Build
+		createErrs        map[string]error
+		err               error
+		wantID            string
+		wantLocation      string
+		wantParents       []string
+		wantErr           string
+	}{
+		{
+			name:              "first_fallback",
+			fallbackLocations: []string{"us-east1", "europe-west1"},
+			build:             &cloudbuildpb.Build{},
+			err:               exhausted,
+			wantID:            "projects/p/locations/us-east1/builds/build-1",
+			wantLocation:      "us-east1",
+			wantParents:       []string{"projects/p/locations/us-east1"},
+		},
+		{
+			name:              "second_fallback",
+			fallbackLocations: []string{"us-east1", "europe-west1"},
+			build:             &cloudbuildpb.Build{},
+			createErrs:        map[string]error{"projects/p/locations/us-east1": exhausted},
+			err:               exhausted,
+			wantID:            "projects/p/locations/europe-west1/builds/build-1",
+			wantLocation:      "europe-west1",
+			wantParents:       []string{"projects/p/locations/us-east1", "projects/p/locations/europe-west1"},
+		},
+		{
+			name:              "all_exhausted",
+			fallbackLocations: []string{"us-east1", "europe-west1"},
+			build:             &cloudbuildpb.Build{},
+			createErrs: map[string]error{
+				"projects/p/locations/us-east1":     exhausted,
+				"projects/p/locations/europe-west1": exhausted,
+			},
+			err:          exhausted,
+			wantLocation: "us-central1",
+			wantParents:  []string{"projects/p/locations/us-east1", "projects/p/locations/europe-west1"},
+			wantErr:      "quota exceeded",
+		},
+		{
+			name:              "other_error_stops",
+			fallbackLocations: []string{"us-east1", "europe-west1"},
+			build:             &cloudbuildpb.Build{},
+			createErrs:        map[string]error{"projects/p/locations/us-east1": status.Error(codes.PermissionDenied, "denied")},
+			err:               exhausted,
+			wantLocation:      "us-central1",
+			wantParents:       []string{"projects/p/locations/us-east1"},
+			wantErr:           "denied",
+		},
+		{
+			name:              "skips_primary",
+			fallbackLocations: []string{"us-central1", "us-east1"},
+			build:             &cloudbuildpb.Build{},
+			err:               exhausted,
+			wantID:            "projects/p/locations/us-east1/builds/build-1",
+			wantLocation:      "us-east1",
+			wantParents:       []string{"projects/p/locations/us-east1"},
+		},
+		{
+			name:              "worker_pool",
+			fallbackLocations: []string{"us-east1"},
+			build: &cloudbuildpb.Build{
+				Options: &cloudbuildpb.BuildOptions{
+					Pool: &cloudbuildpb.BuildOptions_PoolOption{Name: "projects/p/locations/us-central1/workerPools/pool"},
+				},
+			},
+			err:          exhausted,
+			wantLocation: "us-central1",
+			wantErr:      "quota exceeded",
+		},
+		{
+			name:         "no_fallback_locations",
+			build:        &cloudbuildpb.Build{},
+			err:          exhausted,
+			wantLocation: "us-central1",
+			wantErr:      "quota exceeded",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cbc := &MockCloudBuildClient{
+				createBuildRes:  &cloudbuildpb.Build{Id: "build-1"},
+				createBuildErrs: tc.createErrs,
+			}
+			s := &Server{runnerFallbackLocations: tc.fallbackLocations}
+			spec := &RunnerSpec{RunnerName: "GCP-2", ProjectID: "p", Location: "us-central1", Build: tc.build}
+
+			id, err := s.provisionInFallbackLocations(t.Context(), &cloudBuildBackend{cbc: cbc}, spec, tc.err, nil)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := id, tc.wantID; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := spec.Location, tc.wantLocation; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			var parents []string
+			for _, req := range cbc.createBuildReqs {
+				parents = append(parents, req.GetParent())
+			}
+			if diff := cmp.Diff(tc.wantParents, parents); diff != "" {
+				t.Errorf("unexpected build parents (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
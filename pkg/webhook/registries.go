@@ -0,0 +1,46 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+const registryLabelPrefix = "registry="
+
+// runnerRepository returns the Artifact Registry repository the runner image
+// is pulled from. It is selected, in order of precedence, by the registry=
+// label of the job, the matching image mapping, the assignment of the
+// repository, the assignment of the organization, falling back to the default
+// repository. The second return
+// value is the selected repository name, and the third is false if the label
+// selects a repository that is not configured.
+func (s *Server) runnerRepository(org, repo string, labels []string) (string, string, bool) {
+	if name, ok := labelValue(labels, registryLabelPrefix); ok {
+		id, ok := s.runnerRepositories[name]
+		return id, name, ok
+	}
+
+	if m := s.runnerImageMapping(labels); m != nil && m.Repository != "" {
+		id, ok := s.runnerRepositories[m.Repository]
+		return id, m.Repository, ok
+	}
+
+	for _, key := range []string{org + "/" + repo, org} {
+		if name, ok := s.runnerRepositoryAssignments[key]; ok {
+			if id, ok := s.runnerRepositories[name]; ok {
+				return id, name, true
+			}
+		}
+	}
+
+	return s.runnerRepositoryID, "", true
+}
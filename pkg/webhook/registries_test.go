@@ -0,0 +1,95 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+)
+
+func TestRunnerRepository(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{
+		runnerRepositoryID: "us-docker.pkg.dev/project/runners",
+		runnerRepositories: map[string]string{
+			"asia": "asia-docker.pkg.dev/project/runners",
+			"eu":   "europe-docker.pkg.dev/project/runners",
+		},
+		runnerRepositoryAssignments: map[string]string{
+			"google":         "eu",
+			"google/webhook": "asia",
+		},
+	}
+
+	cases := []struct {
+		name   string
+		org    string
+		repo   string
+		labels []string
+		wantID string
+		wantOK bool
+	}{
+		{
+			name:   "default",
+			org:    "abcxyz",
+			repo:   "pkg",
+			wantID: "us-docker.pkg.dev/project/runners",
+			wantOK: true,
+		},
+		{
+			name:   "org_assignment",
+			org:    "google",
+			repo:   "other",
+			wantID: "europe-docker.pkg.dev/project/runners",
+			wantOK: true,
+		},
+		{
+			name:   "repo_assignment",
+			org:    "google",
+			repo:   "webhook",
+			wantID: "asia-docker.pkg.dev/project/runners",
+			wantOK: true,
+		},
+		{
+			name:   "label_overrides_assignment",
+			org:    "google",
+			repo:   "webhook",
+			labels: []string{"self-hosted", "registry=eu"},
+			wantID: "europe-docker.pkg.dev/project/runners",
+			wantOK: true,
+		},
+		{
+			name:   "unknown_label",
+			org:    "google",
+			repo:   "webhook",
+			labels: []string{"self-hosted", "registry=mars"},
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			id, _, ok := srv.runnerRepository(tc.org, tc.repo, tc.labels)
+			if got, want := id, tc.wantID; got != want {
+				t.Errorf("expected repository %q to be %q", got, want)
+			}
+			if got, want := ok, tc.wantOK; got != want {
+				t.Errorf("expected ok %t to be %t", got, want)
+			}
+		})
+	}
+}
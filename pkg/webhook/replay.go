@@ -0,0 +1,162 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// archivedDelivery is a webhook delivery whose signature was verified.
+type archivedDelivery struct {
+	ID         string
+	Event      string
+	Payload    []byte
+	ReceivedAt time.Time
+}
+
+// deliveryArchive keeps the most recent verified deliveries of this instance
+// in a ring, so operators can replay a delivery whose processing was lost. A
+// nil archive is valid and keeps nothing.
+type deliveryArchive struct {
+	mu         sync.Mutex
+	deliveries []archivedDelivery
+	next       int
+	byID       map[string]int
+}
+
+func newDeliveryArchive(size int) *deliveryArchive {
+	return &deliveryArchive{
+		deliveries: make([]archivedDelivery, 0, size),
+		byID:       make(map[string]int, size),
+	}
+}
+
+// Add archives the delivery, evicting the oldest one when the archive is full.
+func (a *deliveryArchive) Add(d archivedDelivery) {
+	if a == nil || cap(a.deliveries) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if i, ok := a.byID[d.ID]; ok {
+		// A redelivery replaces the archived delivery.
+		a.deliveries[i] = d
+		return
+	}
+	if len(a.deliveries) < cap(a.deliveries) {
+		a.byID[d.ID] = len(a.deliveries)
+		a.deliveries = append(a.deliveries, d)
+		return
+	}
+	delete(a.byID, a.deliveries[a.next].ID)
+	a.deliveries[a.next] = d
+	a.byID[d.ID] = a.next
+	a.next = (a.next + 1) % len(a.deliveries)
+}
+
+// Get returns the archived delivery with the ID.
+func (a *deliveryArchive) Get(id string) (archivedDelivery, bool) {
+	if a == nil {
+		return archivedDelivery{}, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i, ok := a.byID[id]
+	if !ok {
+		return archivedDelivery{}, false
+	}
+	return a.deliveries[i], true
+}
+
+// replayResult is the outcome of a replayed delivery.
+type replayResult struct {
+	DeliveryID string    `json:"delivery_id"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"received_at"`
+	Force      bool      `json:"force"`
+	Code       int       `json:"code"`
+	Message    string    `json:"message"`
+}
+
+// handleAdminReplay re-runs the processing of an archived delivery, as if
+// GitHub redelivered it. A queued job whose runner already exists is refused
+// like any redelivery, unless force=true provisions a runner under a new name.
+// Only deliveries received by this instance can be replayed.
+func (s *Server) handleAdminReplay() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.deliveries == nil {
+			s.h.RenderJSON(w, http.StatusNotFound, map[string]string{
+				"error": "deliveries are not archived, DELIVERY_ARCHIVE_SIZE is 0",
+			})
+			return
+		}
+
+		force := false
+		if v := r.URL.Query().Get("force"); v != "" {
+			var err error
+			if force, err = strconv.ParseBool(v); err != nil {
+				s.h.RenderJSON(w, http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("force %q is not a boolean", v),
+				})
+				return
+			}
+		}
+
+		deliveryID := r.PathValue("delivery_id")
+		d, ok := s.deliveries.Get(deliveryID)
+		if !ok {
+			s.h.RenderJSON(w, http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("delivery %q is not archived by this instance", deliveryID),
+			})
+			return
+		}
+
+		// The replay keeps the delivery ID, so its logs and builds correlate
+		// with the original delivery.
+		ctx := contextWithDeliveryID(r.Context(), d.ID)
+		logger := logging.FromContext(ctx)
+		logger.InfoContext(ctx, "replaying delivery",
+			"event", d.Event,
+			"received_at", d.ReceivedAt,
+			"force", force)
+
+		resp := s.processEvent(ctx, d.Event, d.Payload, force)
+		if resp.Error != nil {
+			logger.ErrorContext(ctx, "error replaying delivery",
+				"error", resp.Error,
+				"code", resp.Code,
+				"body", resp.Message)
+		}
+
+		s.h.RenderJSON(w, http.StatusOK, &replayResult{
+			DeliveryID: d.ID,
+			Event:      d.Event,
+			ReceivedAt: d.ReceivedAt.UTC(),
+			Force:      force,
+			Code:       resp.Code,
+			Message:    resp.Message,
+		})
+	})
+}
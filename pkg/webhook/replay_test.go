@@ -0,0 +1,160 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/renderer"
+)
+
+func TestDeliveryArchive(t *testing.T) {
+	t.Parallel()
+
+	a := newDeliveryArchive(2)
+	for _, id := range []string{"d1", "d2", "d3"} {
+		a.Add(archivedDelivery{ID: id, Event: "workflow_job"})
+	}
+	a.Add(archivedDelivery{ID: "d3", Event: "ping"})
+
+	if _, ok := a.Get("d1"); ok {
+		t.Errorf("expected the oldest delivery to be evicted")
+	}
+	if _, ok := a.Get("d2"); !ok {
+		t.Errorf("expected d2 to be archived")
+	}
+	d, ok := a.Get("d3")
+	if !ok {
+		t.Fatalf("expected d3 to be archived")
+	}
+	if got, want := d.Event, "ping"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	var nilArchive *deliveryArchive
+	nilArchive.Add(archivedDelivery{ID: "d1"})
+	if _, ok := nilArchive.Get("d1"); ok {
+		t.Errorf("expected a nil archive to keep nothing")
+	}
+}
+
+func TestHandleAdminReplay(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"action":"queued","workflow_job":{"id":2,"run_id":1,"labels":["other-label"]},` +
+		`"installation":{"id":123},"organization":{"login":"google"},"repository":{"name":"webhook"}}`)
+
+	cases := []struct {
+		name        string
+		disabled    bool
+		path        string
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:        "replay",
+			path:        "/admin/replay/delivery-id",
+			wantCode:    http.StatusOK,
+			wantMessage: fmt.Sprintf("no action taken for labels: %s", []string{"other-label"}),
+		},
+		{
+			name:        "replay_force",
+			path:        "/admin/replay/delivery-id?force=true",
+			wantCode:    http.StatusOK,
+			wantMessage: fmt.Sprintf("no action taken for labels: %s", []string{"other-label"}),
+		},
+		{
+			name:     "invalid_force",
+			path:     "/admin/replay/delivery-id?force=maybe",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "not_archived",
+			path:     "/admin/replay/other-delivery-id",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "archive_disabled",
+			disabled: true,
+			path:     "/admin/replay/delivery-id",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+
+			s := &Server{
+				adminToken:    []byte("admin-token"),
+				deliveries:    newDeliveryArchive(10),
+				h:             renderer.NewTesting(ctx, t, nil),
+				webhookSecret: []byte("webhook-secret"),
+			}
+			if tc.disabled {
+				s.deliveries = nil
+			}
+
+			// The delivery is archived when it is received.
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+			req.Header.Add(DeliveryIDHeader, "delivery-id")
+			req.Header.Add(EventTypeHeader, "workflow_job")
+			req.Header.Add(ContentTypeHeader, "application/json")
+			req.Header.Add(SHA256SignatureHeader, "sha256="+createSignature(s.webhookSecret, payload))
+			resp := httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+			if got, want := resp.Code, http.StatusOK; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+
+			req = httptest.NewRequest(http.MethodPost, tc.path, nil)
+			req.Header.Set("Authorization", "Bearer admin-token")
+			resp = httptest.NewRecorder()
+			s.Routes(ctx).ServeHTTP(resp, req)
+
+			if got, want := resp.Code, tc.wantCode; got != want {
+				t.Fatalf("expected %d to be %d: %s", got, want, resp.Body.String())
+			}
+			if tc.wantMessage == "" {
+				return
+			}
+
+			var got replayResult
+			if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := got.Event, "workflow_job"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := got.Code, http.StatusOK; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := got.Message, tc.wantMessage; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if time.Since(got.ReceivedAt) > time.Minute {
+				t.Errorf("expected the receive time of the delivery, got %s", got.ReceivedAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/abcxyz/pkg/cli"
+)
+
+// ReportConfig is the configuration of a report of the scaling
+// recommendations of a webhook server.
+type ReportConfig struct {
+	ServerURL  string
+	AdminToken string
+}
+
+// Validate validates the report config after load.
+func (cfg *ReportConfig) Validate() error {
+	if cfg.ServerURL == "" {
+		return fmt.Errorf("WEBHOOK_SERVER_URL is required")
+	}
+	if u, err := url.Parse(cfg.ServerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("WEBHOOK_SERVER_URL must be an http or https URL, got %q", cfg.ServerURL)
+	}
+	if cfg.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN is required")
+	}
+	return nil
+}
+
+// ToFlags binds the config to the [cli.FlagSet] and returns it.
+func (cfg *ReportConfig) ToFlags(set *cli.FlagSet) *cli.FlagSet {
+	f := set.NewSection("REPORT OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:    "server-url",
+		Target:  &cfg.ServerURL,
+		EnvVar:  "WEBHOOK_SERVER_URL",
+		Example: "https://webhook-abc123-uc.a.run.app",
+		Usage:   `The URL of the webhook server to report the scaling recommendations of.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "admin-token",
+		Target: &cfg.AdminToken,
+		EnvVar: "ADMIN_TOKEN",
+		Usage:  `The admin token of the webhook server.`,
+	})
+
+	return set
+}
+
+// FetchRecommendations requests the scaling recommendations from the admin
+// API of the webhook server.
+func FetchRecommendations(ctx context.Context, client *http.Client, cfg *ReportConfig) (*RecommendationReport, error) {
+	endpoint := strings.TrimSuffix(cfg.ServerURL, "/") + "/admin/recommendations"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.AdminToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request recommendations: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recommendations: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("failed to request recommendations: %s: %s", resp.Status, apiErr.Error)
+		}
+		return nil, fmt.Errorf("failed to request recommendations: %s", resp.Status)
+	}
+
+	var report RecommendationReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse recommendations: %w", err)
+	}
+	return &report, nil
+}
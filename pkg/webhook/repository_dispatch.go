@@ -0,0 +1,122 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// provisionDispatchPayload is the client_payload of a repository_dispatch
+// event requesting runners ahead of demand.
+type provisionDispatchPayload struct {
+	// Labels are hints for the runners, such as "profile=<name>".
+	Labels []string `json:"labels"`
+
+	// Count is the number of runners to provision, defaults to 1.
+	Count int `json:"count"`
+}
+
+// handleRepositoryDispatch provisions runners for repository_dispatch events
+// with the configured event type, so teams can warm up runners from
+// workflows or scripts ahead of anticipated load.
+func (s *Server) handleRepositoryDispatch(ctx context.Context, deliveryID string, event *github.RepositoryDispatchEvent) *apiResponse {
+	logger := logging.FromContext(ctx)
+
+	eventType := event.GetAction()
+	if s.dispatchEventType == "" || eventType != s.dispatchEventType {
+		logger.InfoContext(ctx, "no action taken for repository dispatch event type", "event_type", eventType)
+		return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for repository dispatch event type: %q", eventType), nil}
+	}
+
+	org := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	installationID := event.GetInstallation().GetID()
+	if org == "" || repo == "" || installationID == 0 {
+		err := fmt.Errorf("event is missing required fields (installation, owner, or repo)")
+		logger.ErrorContext(ctx, "cannot provision runners due to missing event data", "error", err)
+		return &apiResponse{http.StatusBadRequest, "unexpected event payload struture", err}
+	}
+
+	var payload provisionDispatchPayload
+	if len(event.ClientPayload) > 0 {
+		if err := json.Unmarshal(event.ClientPayload, &payload); err != nil {
+			return &apiResponse{http.StatusBadRequest, "invalid client_payload", err}
+		}
+	}
+
+	count := payload.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > s.dispatchMaxRunners {
+		err := fmt.Errorf("requested %d runners, at most %d are allowed", count, s.dispatchMaxRunners)
+		return &apiResponse{http.StatusBadRequest, "too many runners requested", err}
+	}
+
+	labels := payload.Labels
+	if !slices.Contains(labels, defaultRunnerLabel) {
+		labels = append([]string{defaultRunnerLabel}, labels...)
+	}
+
+	logFields := []any{
+		"event_type", eventType,
+		"org", org,
+		"repo", repo,
+		"sender", event.GetSender().GetLogin(),
+		"labels", labels,
+		"count", count,
+	}
+	logger.InfoContext(ctx, "provisioning runners for repository dispatch", logFields...)
+
+	for i := range count {
+		runnerID := fmt.Sprintf("%s%s-%d", runnerNamePrefix, deliveryID, i)
+		runnerFields := append(append([]any{}, logFields...), "runner_id", runnerID)
+		req := &runnerRequest{
+			InstallationID: installationID,
+			Org:            org,
+			Repo:           repo,
+			RunnerName:     runnerID,
+			Labels:         labels,
+			Actor:          event.GetSender().GetLogin(),
+			DeliveryID:     deliveryID,
+		}
+
+		if s.dispatchQueue != nil {
+			if resp := s.enqueueRunner(ctx, req, runnerFields); resp.Code != http.StatusAccepted {
+				return resp
+			}
+			continue
+		}
+
+		createdBuild, errResponse := s.provisionRunner(ctx, req, runnerFields)
+		if errResponse != nil {
+			return errResponse
+		}
+		s.runnerDispatched(ctx, req, createdBuild, runnerFields)
+	}
+
+	if s.dispatchQueue != nil {
+		return &apiResponse{http.StatusAccepted, fmt.Sprintf("queued %d runners", count), nil}
+	}
+	return &apiResponse{http.StatusOK, fmt.Sprintf("provisioned %d runners", count), nil}
+}
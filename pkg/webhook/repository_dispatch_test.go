@@ -0,0 +1,168 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/abcxyz/pkg/githubauth"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestHandleRepositoryDispatch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		eventType     string
+		clientPayload string
+		expStatusCode int
+		expRespBody   string
+		expRunners    []string
+	}{
+		{
+			name:          "provisions_runners",
+			eventType:     "provision-runner",
+			clientPayload: `{"labels": ["profile=go"], "count": 2}`,
+			expStatusCode: http.StatusOK,
+			expRespBody:   "provisioned 2 runners",
+			expRunners:    []string{"GCP-delivery-0", "GCP-delivery-1"},
+		},
+		{
+			name:          "defaults_to_one_runner",
+			eventType:     "provision-runner",
+			expStatusCode: http.StatusOK,
+			expRespBody:   "provisioned 1 runners",
+			expRunners:    []string{"GCP-delivery-0"},
+		},
+		{
+			name:          "other_event_type",
+			eventType:     "deploy",
+			expStatusCode: http.StatusOK,
+			expRespBody:   `no action taken for repository dispatch event type: "deploy"`,
+		},
+		{
+			name:          "too_many_runners",
+			eventType:     "provision-runner",
+			clientPayload: `{"count": 50}`,
+			expStatusCode: http.StatusBadRequest,
+			expRespBody:   "too many runners requested",
+		},
+		{
+			name:          "invalid_payload",
+			eventType:     "provision-runner",
+			clientPayload: `["not", "an", "object"]`,
+			expStatusCode: http.StatusBadRequest,
+			expRespBody:   "invalid client_payload",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var gotRunners []string
+			app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+				mu.Lock()
+				defer mu.Unlock()
+				gotRunners = append(gotRunners, name)
+			})
+
+			srv := &Server{
+				appClient:          app,
+				cbc:                &MockCloudBuildClient{},
+				dispatchEventType:  "provision-runner",
+				dispatchMaxRunners: 10,
+				ghAPIBaseURL:       ghURL,
+				runners:            newRunnerTracker(),
+			}
+
+			event := &github.RepositoryDispatchEvent{
+				Action:        github.Ptr(tc.eventType),
+				ClientPayload: json.RawMessage(tc.clientPayload),
+				Repo: &github.Repository{
+					Name:  github.Ptr("webhook"),
+					Owner: &github.User{Login: github.Ptr("google")},
+				},
+				Installation: &github.Installation{ID: github.Ptr(int64(123))},
+			}
+
+			resp := srv.handleRepositoryDispatch(t.Context(), "delivery", event)
+			if got, want := resp.Code, tc.expStatusCode; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := resp.Message, tc.expRespBody; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if diff := cmp.Diff(tc.expRunners, gotRunners); diff != "" {
+				t.Errorf("unexpected runners (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// newFakeRunnerGitHub starts a fake GitHub API that serves the requests made
+// to provision a runner for google/webhook in installation 123. onJITConfig is
+// called with the name of every registered runner.
+func newFakeRunnerGitHub(tb testing.TB, onJITConfig func(name string)) (*githubauth.App, string) {
+	tb.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/installation", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": 123}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+	}))
+	mux.Handle("POST /repos/google/webhook/actions/runners/generate-jitconfig", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req github.GenerateJITConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			tb.Errorf("failed to decode jit request: %v", err)
+		}
+		onJITConfig(req.Name)
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"encoded_jit_config": "Hello"}`)
+	}))
+	fakeGitHub := httptest.NewServer(mux)
+	tb.Cleanup(fakeGitHub.Close)
+
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", rsaPrivateKey, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return app, fakeGitHub.URL
+}
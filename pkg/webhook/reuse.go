@@ -0,0 +1,233 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// reusableRunnerConfig registers a runner that is not ephemeral and serves
+// several jobs. It is passed to the runner image like a JIT configuration, in
+// ENCODED_JIT_CONFIG, as base64 encoded JSON. The runner image tells them apart
+// by the registration token.
+type reusableRunnerConfig struct {
+	URL               string   `json:"url"`
+	RegistrationToken string   `json:"registration_token"`
+	Name              string   `json:"name"`
+	Labels            []string `json:"labels"`
+	RunnerGroup       string   `json:"runner_group,omitempty"`
+
+	// RemoveToken deregisters the runner when it exits. GitHub expires it an
+	// hour after it was created, a runner exiting later is removed once
+	// offline instead.
+	RemoveToken string `json:"remove_token"`
+
+	// MaxJobs and MaxSeconds bound how many jobs the runner takes and for how
+	// long it takes new jobs.
+	MaxJobs    int   `json:"max_jobs"`
+	MaxSeconds int64 `json:"max_seconds"`
+}
+
+// encode returns the configuration as passed in ENCODED_JIT_CONFIG.
+func (c *reusableRunnerConfig) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal reusable runner config: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// reusableRunners reports whether runners serve several jobs before
+// terminating, instead of being ephemeral.
+func (s *Server) reusableRunners() bool {
+	return s.runnerReuseMaxJobs > 1
+}
+
+// GenerateReusableConfig registers a reusable runner with the repository, or
+// with the given runner group of the organization if runnerGroupID is
+// positive. The configuration is returned as the encoded JIT configuration,
+// so it reaches the runner like one.
+func (s *Server) GenerateReusableConfig(ctx context.Context, installationID int64, org, repo, runnerName string, runnerGroupID int64, labels ...string) (*github.JITRunnerConfig, *apiResponse) {
+	scope := &repo
+	if runnerGroupID > 0 {
+		scope = nil
+	}
+	gh, errResponse := s.registrationClient(ctx, installationID, org, scope, runnerName)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+
+	webURL, err := githubWebURL(s.ghAPIBaseURL)
+	if err != nil {
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to determine github url", err}
+	}
+	config := &reusableRunnerConfig{
+		URL:        webURL + "/" + org,
+		Name:       runnerName,
+		Labels:     runnerLabels(labels),
+		MaxJobs:    s.runnerReuseMaxJobs,
+		MaxSeconds: int64(s.runnerReuseMaxDuration / time.Second),
+	}
+
+	tctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
+	var token *github.RegistrationToken
+	var removeToken *github.RemoveToken
+	if scope != nil {
+		config.URL += "/" + repo
+		token, _, err = gh.Actions.CreateRegistrationToken(tctx, org, repo)
+		if err == nil {
+			removeToken, _, err = gh.Actions.CreateRemoveToken(tctx, org, repo)
+		}
+	} else {
+		var group *github.RunnerGroup
+		// The runner is configured with the name of its group.
+		group, _, err = gh.Actions.GetOrganizationRunnerGroup(tctx, org, runnerGroupID)
+		if err == nil {
+			config.RunnerGroup = group.GetName()
+			token, _, err = gh.Actions.CreateOrganizationRegistrationToken(tctx, org)
+		}
+		if err == nil {
+			removeToken, _, err = gh.Actions.CreateOrganizationRemoveToken(tctx, org)
+		}
+	}
+	if err != nil {
+		if isGoneResponse(err) {
+			err = fmt.Errorf("%w: %w", errRunnerTargetGone, err)
+		}
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to create registration token", err}
+	}
+	config.RegistrationToken = token.GetToken()
+	config.RemoveToken = removeToken.GetToken()
+
+	encoded, err := config.encode()
+	if err != nil {
+		return nil, &apiResponse{http.StatusInternalServerError, "failed to encode reusable runner config", err}
+	}
+	return &github.JITRunnerConfig{EncodedJITConfig: &encoded}, nil
+}
+
+// githubWebURL returns the URL runners register with from the URL of the
+// GitHub API, https://github.com for https://api.github.com and the host of
+// the API for GitHub Enterprise Server.
+func githubWebURL(apiBaseURL string) (string, error) {
+	u, err := url.Parse(apiBaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse github api url: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid github api url %q", apiBaseURL)
+	}
+	u.Host = strings.TrimPrefix(u.Host, "api.")
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3")
+	u.RawQuery, u.Fragment = "", ""
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// reuseRunner records that the reusable runner finished a job and puts it
+// back online if it can take another one. It reports whether the runner stays
+// for more jobs, otherwise it is removed like an ephemeral runner.
+func (s *Server) reuseRunner(ctx context.Context, runnerName string, now time.Time) bool {
+	reused := false
+	s.runners.Update(runnerName, func(r *trackedRunner) {
+		if !r.Reusable || r.Lifecycle.State() != lifecycle.StateRunning {
+			return
+		}
+		r.JobsServed++
+		r.Stalled = false
+		reused = r.JobsServed < s.runnerReuseMaxJobs && runnerAge(r, now) < s.runnerReuseMaxDuration
+	})
+	if !reused {
+		return false
+	}
+
+	s.transitionRunner(ctx, runnerName, lifecycle.StateOnline)
+	logging.FromContext(ctx).InfoContext(ctx, "reusable runner back online for another job", "runner_id", runnerName)
+	return true
+}
+
+// runReuseDrainer drains idle reusable runners until ctx is done.
+func (s *Server) runReuseDrainer(ctx context.Context) {
+	ticker := time.NewTicker(runnerTTLCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.drainReusableRunners(ctx, time.Now())
+		}
+	}
+}
+
+// drainReusableRunners removes the idle reusable runners that are past the
+// reuse duration from GitHub. An idle runner waits for a job until then, its
+// runner stops once its registration is gone and the build or VM ends. Busy
+// runners are drained when their job completes. It returns the number of
+// runners drained.
+func (s *Server) drainReusableRunners(ctx context.Context, now time.Time) int {
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return 0
+	}
+
+	s.syncRunners(ctx)
+	logger := logging.FromContext(ctx)
+	drained := 0
+	for _, r := range s.runners.List() {
+		if !r.Reusable || r.Lifecycle.State() != lifecycle.StateOnline || runnerAge(&r, now) < s.runnerReuseMaxDuration {
+			continue
+		}
+
+		logFields := []any{
+			"org", r.Org,
+			"repo", r.Repo,
+			"runner_id", r.RunnerName,
+			"jobs_served", r.JobsServed,
+		}
+		// GitHub refuses to remove a runner that just took a job, which is
+		// drained once the job completes.
+		if err := s.removeRunner(ctx, r.InstallationID, r.Org, r.Repo, r.RunnerName); err != nil {
+			var ghErr *github.ErrorResponse
+			if !errors.As(err, &ghErr) || ghErr.Response == nil || ghErr.Response.StatusCode != http.StatusUnprocessableEntity {
+				logger.WarnContext(ctx, "failed to drain reusable runner", append(logFields, "error", err)...)
+			}
+			continue
+		}
+
+		s.transitionRunner(ctx, r.RunnerName, lifecycle.StateCompleted)
+		if removed, ok := s.runners.Remove(r.RunnerName); ok {
+			s.releaseRunner(ctx, removed)
+		}
+		s.launchQueue.notify()
+		drained++
+		logger.InfoContext(ctx, "drained reusable runner", logFields...)
+	}
+	return drained
+}
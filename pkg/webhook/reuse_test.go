@@ -0,0 +1,321 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/githubauth"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// newFakeReuseGitHub returns an app and a fake GitHub API handling runner
+// registration and removal for google/webhook, recording the runners removed.
+func newFakeReuseGitHub(t *testing.T) (*githubauth.App, string, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var removed []string
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		if name == "GCP-new" {
+			fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+			return
+		}
+		fmt.Fprintf(w, `{"total_count": 1, "runners": [{"id": 7, "name": %q, "status": "online"}]}`, name)
+	}))
+	mux.Handle("GET /orgs/google/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+	}))
+	mux.Handle("DELETE /repos/google/webhook/actions/runners/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.Handle("POST /repos/google/webhook/actions/runners/registration-token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "repo-registration-token"}`)
+	}))
+	mux.Handle("POST /repos/google/webhook/actions/runners/remove-token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "repo-remove-token"}`)
+	}))
+	mux.Handle("GET /orgs/google/actions/runner-groups/7", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": 7, "name": "bursty"}`)
+	}))
+	mux.Handle("POST /orgs/google/actions/runners/registration-token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "org-registration-token"}`)
+	}))
+	mux.Handle("POST /orgs/google/actions/runners/remove-token", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "org-remove-token"}`)
+	}))
+	fakeGitHub := httptest.NewServer(mux)
+	t.Cleanup(fakeGitHub.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, fakeGitHub.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, removed...)
+	}
+}
+
+// reusableRunner returns a reusable runner of google/webhook dispatched at the
+// given time and moved through the states.
+func reusableRunner(t *testing.T, name string, dispatchedAt time.Time, states ...lifecycle.State) *trackedRunner {
+	t.Helper()
+
+	l := lifecycle.New(dispatchedAt)
+	for _, s := range append([]lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning}, states...) {
+		if err := l.Transition(s, dispatchedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &trackedRunner{
+		RunnerName:     name,
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		JobID:          1,
+		Lifecycle:      l,
+		Reusable:       true,
+	}
+}
+
+func TestGenerateReusableConfig(t *testing.T) {
+	t.Parallel()
+
+	app, baseURL, _ := newFakeReuseGitHub(t)
+
+	cases := []struct {
+		name          string
+		runnerName    string
+		runnerGroupID int64
+		want          *reusableRunnerConfig
+		wantErr       string
+	}{
+		{
+			name:       "repository",
+			runnerName: "GCP-new",
+			want: &reusableRunnerConfig{
+				URL:               baseURL + "/google/webhook",
+				RegistrationToken: "repo-registration-token",
+				Name:              "GCP-new",
+				Labels:            []string{"self-hosted", "Linux", "X64", "gpu=none"},
+				RemoveToken:       "repo-remove-token",
+				MaxJobs:           5,
+				MaxSeconds:        1800,
+			},
+		},
+		{
+			name:          "runner_group",
+			runnerName:    "GCP-new",
+			runnerGroupID: 7,
+			want: &reusableRunnerConfig{
+				URL:               baseURL + "/google",
+				RegistrationToken: "org-registration-token",
+				Name:              "GCP-new",
+				Labels:            []string{"self-hosted", "Linux", "X64", "gpu=none"},
+				RunnerGroup:       "bursty",
+				RemoveToken:       "org-remove-token",
+				MaxJobs:           5,
+				MaxSeconds:        1800,
+			},
+		},
+		{
+			name:       "runner_exists",
+			runnerName: "GCP-existing",
+			wantErr:    "runner already exists",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := &Server{
+				appClient:              app,
+				ghAPIBaseURL:           baseURL,
+				runnerReuseMaxDuration: 30 * time.Minute,
+				runnerReuseMaxJobs:     5,
+			}
+
+			jitConfig, errResponse := srv.GenerateReusableConfig(t.Context(), 123, "google", "webhook", tc.runnerName, tc.runnerGroupID, "gpu=none")
+			var err error
+			if errResponse != nil {
+				err = errResponse.Error
+			}
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+
+			b, err := base64.StdEncoding.DecodeString(jitConfig.GetEncodedJITConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got reusableRunnerConfig
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, &got); diff != "" {
+				t.Errorf("unexpected config (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitHubWebURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "github_com",
+			input: "https://api.github.com",
+			want:  "https://github.com",
+		},
+		{
+			name:  "enterprise_server",
+			input: "https://github.example.com/api/v3/",
+			want:  "https://github.example.com",
+		},
+		{
+			name:    "invalid",
+			input:   "api.github.com",
+			wantErr: "invalid github api url",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := githubWebURL(tc.input)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q to be %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReuseRunner(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := &Server{
+		runnerReuseMaxDuration: time.Hour,
+		runnerReuseMaxJobs:     2,
+		runners:                newRunnerTracker(),
+	}
+	srv.runners.Dispatched(reusableRunner(t, "GCP-reusable", now.Add(-time.Minute), lifecycle.StateRunning))
+	srv.runners.Dispatched(reusableRunner(t, "GCP-old", now.Add(-2*time.Hour), lifecycle.StateRunning))
+	ephemeral := reusableRunner(t, "GCP-ephemeral", now.Add(-time.Minute), lifecycle.StateRunning)
+	ephemeral.Reusable = false
+	srv.runners.Dispatched(ephemeral)
+
+	if !srv.reuseRunner(t.Context(), "GCP-reusable", now) {
+		t.Errorf("expected the runner to be reused after its first job")
+	}
+	r, _ := srv.trackedRunner("GCP-reusable")
+	if got, want := r.Lifecycle.State(), lifecycle.StateOnline; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := r.JobsServed, 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	srv.transitionRunner(t.Context(), "GCP-reusable", lifecycle.StateRunning)
+	if srv.reuseRunner(t.Context(), "GCP-reusable", now) {
+		t.Errorf("expected the runner not to be reused after its last job")
+	}
+	if srv.reuseRunner(t.Context(), "GCP-old", now) {
+		t.Errorf("expected the runner not to be reused past the reuse duration")
+	}
+	if srv.reuseRunner(t.Context(), "GCP-ephemeral", now) {
+		t.Errorf("expected an ephemeral runner not to be reused")
+	}
+}
+
+func TestDrainReusableRunners(t *testing.T) {
+	t.Parallel()
+
+	app, baseURL, removed := newFakeReuseGitHub(t)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := &Server{
+		appClient:              app,
+		ghAPIBaseURL:           baseURL,
+		runnerReuseMaxDuration: time.Hour,
+		runnerReuseMaxJobs:     5,
+		runners:                newRunnerTracker(),
+	}
+	srv.runners.Dispatched(reusableRunner(t, "GCP-idle", now.Add(-2*time.Hour), lifecycle.StateOnline))
+	srv.runners.Dispatched(reusableRunner(t, "GCP-busy", now.Add(-2*time.Hour), lifecycle.StateRunning))
+	srv.runners.Dispatched(reusableRunner(t, "GCP-young", now.Add(-time.Minute), lifecycle.StateOnline))
+
+	if got, want := srv.drainReusableRunners(t.Context(), now), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if diff := cmp.Diff([]string{"/repos/google/webhook/actions/runners/7"}, removed()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if _, ok := srv.trackedRunner("GCP-idle"); ok {
+		t.Errorf("expected the drained runner to no longer be tracked")
+	}
+	if got, want := srv.runners.Count(), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
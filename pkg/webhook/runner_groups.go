@@ -0,0 +1,271 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// repoMetadataTTL is how long the topics, custom properties and teams of a
+// repository are trusted before they are fetched again.
+const repoMetadataTTL = 10 * time.Minute
+
+// RunnerGroupMapping registers the runners of matching repositories with an
+// organization runner group and builds them with a profile. A repository
+// matches if it has any of the topics, any of the custom property values, or
+// is accessible to any of the teams.
+type RunnerGroupMapping struct {
+	// Name identifies the mapping in logs.
+	Name string `yaml:"name"`
+
+	// Topics are repository topics.
+	Topics []string `yaml:"topics"`
+
+	// Properties are custom property names and the value they must have.
+	Properties map[string]string `yaml:"properties"`
+
+	// Teams are slugs of teams with access to the repository.
+	Teams []string `yaml:"teams"`
+
+	// RunnerGroupID is the organization runner group the runners are
+	// registered with. Runners are registered with the repository when unset.
+	RunnerGroupID int64 `yaml:"runner_group_id"`
+
+	// Profile is the runner profile used regardless of the profile= label of
+	// the job.
+	Profile string `yaml:"profile"`
+}
+
+// runnerGroupMappingsFile is the format of the runner group mappings file.
+type runnerGroupMappingsFile struct {
+	Mappings []*RunnerGroupMapping `yaml:"mappings"`
+}
+
+// loadRunnerGroupMappings reads and validates the runner group mappings file.
+// Mappings are evaluated in order and the first match applies.
+func loadRunnerGroupMappings(fr FileReader, filename string, profiles map[string]*RunnerProfile) ([]*RunnerGroupMapping, error) {
+	b, err := fr.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runner group mappings: %w", err)
+	}
+
+	var f runnerGroupMappingsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse runner group mappings: %w", err)
+	}
+
+	seen := make(map[string]struct{}, len(f.Mappings))
+	for i, m := range f.Mappings {
+		if m == nil || m.Name == "" {
+			return nil, fmt.Errorf("runner group mapping %d has no name", i)
+		}
+		if _, ok := seen[m.Name]; ok {
+			return nil, fmt.Errorf("duplicate runner group mapping %q", m.Name)
+		}
+		seen[m.Name] = struct{}{}
+
+		if len(m.Topics) == 0 && len(m.Properties) == 0 && len(m.Teams) == 0 {
+			return nil, fmt.Errorf("runner group mapping %q must match on topics, properties or teams", m.Name)
+		}
+		if m.RunnerGroupID < 0 {
+			return nil, fmt.Errorf("runner group mapping %q: runner_group_id must be positive, got %d", m.Name, m.RunnerGroupID)
+		}
+		if m.RunnerGroupID == 0 && m.Profile == "" {
+			return nil, fmt.Errorf("runner group mapping %q must set runner_group_id or profile", m.Name)
+		}
+		if _, ok := profiles[m.Profile]; m.Profile != "" && !ok {
+			return nil, fmt.Errorf("runner group mapping %q: runner profile %q is not defined", m.Name, m.Profile)
+		}
+	}
+	return f.Mappings, nil
+}
+
+// repoMetadata is the repository metadata runner group mappings match on.
+type repoMetadata struct {
+	Topics     []string
+	Properties map[string][]string
+	Teams      []string
+}
+
+// matches reports whether the repository matches any criteria of the mapping.
+// Topics and team slugs are compared case-insensitively.
+func (m *RunnerGroupMapping) matches(md *repoMetadata) bool {
+	equalFold := func(want string) func(string) bool {
+		return func(got string) bool { return strings.EqualFold(got, want) }
+	}
+	for _, topic := range m.Topics {
+		if slices.ContainsFunc(md.Topics, equalFold(topic)) {
+			return true
+		}
+	}
+	for _, team := range m.Teams {
+		if slices.ContainsFunc(md.Teams, equalFold(team)) {
+			return true
+		}
+	}
+	for name, value := range m.Properties {
+		if slices.Contains(md.Properties[name], value) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoMetadataCache remembers recently fetched repository metadata. A nil
+// cache is valid and caches nothing.
+type repoMetadataCache struct {
+	mu      sync.Mutex
+	entries map[string]*repoMetadataEntry
+}
+
+type repoMetadataEntry struct {
+	md      *repoMetadata
+	expires time.Time
+}
+
+func newRepoMetadataCache() *repoMetadataCache {
+	return &repoMetadataCache{
+		entries: make(map[string]*repoMetadataEntry),
+	}
+}
+
+func (c *repoMetadataCache) get(key string, now time.Time) (*repoMetadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.md, true
+}
+
+func (c *repoMetadataCache) add(key string, md *repoMetadata, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &repoMetadataEntry{md: md, expires: now.Add(repoMetadataTTL)}
+}
+
+// runnerGroupMapping returns the first mapping matching the repository, or nil
+// if none does.
+func (s *Server) runnerGroupMapping(ctx context.Context, installationID int64, org, repo string) (*RunnerGroupMapping, error) {
+	if len(s.runnerGroupMappings) == 0 {
+		return nil, nil
+	}
+
+	md, err := s.repoMetadata(ctx, installationID, org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range s.runnerGroupMappings {
+		if m.matches(md) {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// repoMetadata fetches the metadata of the repository the mappings match on.
+// Only the kinds of metadata used by a mapping are fetched.
+func (s *Server) repoMetadata(ctx context.Context, installationID int64, org, repo string) (*repoMetadata, error) {
+	key := fmt.Sprintf("%d/%s/%s", installationID, strings.ToLower(org), strings.ToLower(repo))
+	now := time.Now()
+	if md, ok := s.repoMetadataCache.get(key, now); ok {
+		return md, nil
+	}
+
+	var needTopics, needProperties, needTeams bool
+	for _, m := range s.runnerGroupMappings {
+		needTopics = needTopics || len(m.Topics) > 0
+		needProperties = needProperties || len(m.Properties) > 0
+		needTeams = needTeams || len(m.Teams) > 0
+	}
+
+	gh, err := s.installationClient(ctx, installationID, map[string]string{
+		"administration": "read",
+		"metadata":       "read",
+	}, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	md := &repoMetadata{Properties: make(map[string][]string)}
+
+	if needTopics {
+		r, _, err := gh.Repositories.Get(ctx, org, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository: %w", err)
+		}
+		md.Topics = r.Topics
+	}
+
+	if needProperties {
+		values, _, err := gh.Repositories.GetAllCustomPropertyValues(ctx, org, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get custom property values: %w", err)
+		}
+		for _, v := range values {
+			switch value := v.Value.(type) {
+			case string:
+				md.Properties[v.PropertyName] = []string{value}
+			case []string:
+				md.Properties[v.PropertyName] = value
+			}
+		}
+	}
+
+	if needTeams {
+		opts := &github.ListOptions{PerPage: 100}
+		for {
+			teams, resp, err := gh.Repositories.ListTeams(ctx, org, repo, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list teams: %w", err)
+			}
+			for _, t := range teams {
+				md.Teams = append(md.Teams, t.GetSlug())
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	s.repoMetadataCache.add(key, md, now)
+	return md, nil
+}
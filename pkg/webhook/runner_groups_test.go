@@ -0,0 +1,231 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/abcxyz/pkg/githubauth"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadRunnerGroupMappings(t *testing.T) {
+	t.Parallel()
+
+	profiles := map[string]*RunnerProfile{"restricted": {}}
+
+	cases := []struct {
+		name    string
+		content string
+		want    []*RunnerGroupMapping
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+mappings:
+  - name: pci
+    topics: [pci]
+    properties:
+      sensitivity: high
+    teams: [security]
+    runner_group_id: 7
+    profile: restricted
+`,
+			want: []*RunnerGroupMapping{{
+				Name:          "pci",
+				Topics:        []string{"pci"},
+				Properties:    map[string]string{"sensitivity": "high"},
+				Teams:         []string{"security"},
+				RunnerGroupID: 7,
+				Profile:       "restricted",
+			}},
+		},
+		{
+			name: "no_criteria",
+			content: `
+mappings:
+  - name: everything
+    runner_group_id: 7
+`,
+			wantErr: "must match on topics, properties or teams",
+		},
+		{
+			name: "no_target",
+			content: `
+mappings:
+  - name: pci
+    topics: [pci]
+`,
+			wantErr: "must set runner_group_id or profile",
+		},
+		{
+			name: "unknown_profile",
+			content: `
+mappings:
+  - name: pci
+    topics: [pci]
+    profile: missing
+`,
+			wantErr: `runner profile "missing" is not defined`,
+		},
+		{
+			name: "duplicate",
+			content: `
+mappings:
+  - name: pci
+    topics: [pci]
+    runner_group_id: 7
+  - name: pci
+    teams: [security]
+    runner_group_id: 8
+`,
+			wantErr: "duplicate runner group mapping",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.content)}}
+			got, err := loadRunnerGroupMappings(fr, "mappings.yaml", profiles)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected mappings (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunnerGroupMapping(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		mappings     []*RunnerGroupMapping
+		want         string
+		wantRequests int32
+	}{
+		{
+			name:         "topic",
+			mappings:     []*RunnerGroupMapping{{Name: "pci", Topics: []string{"PCI"}, RunnerGroupID: 7}},
+			want:         "pci",
+			wantRequests: 1,
+		},
+		{
+			name:         "property",
+			mappings:     []*RunnerGroupMapping{{Name: "sensitive", Properties: map[string]string{"sensitivity": "high"}, RunnerGroupID: 7}},
+			want:         "sensitive",
+			wantRequests: 1,
+		},
+		{
+			name:         "team",
+			mappings:     []*RunnerGroupMapping{{Name: "security", Teams: []string{"security"}, RunnerGroupID: 7}},
+			want:         "security",
+			wantRequests: 1,
+		},
+		{
+			name: "first_match",
+			mappings: []*RunnerGroupMapping{
+				{Name: "other", Topics: []string{"other"}, RunnerGroupID: 5},
+				{Name: "first", Teams: []string{"security"}, RunnerGroupID: 6},
+				{Name: "second", Topics: []string{"pci"}, RunnerGroupID: 7},
+			},
+			want:         "first",
+			wantRequests: 2,
+		},
+		{
+			name:         "no_match",
+			mappings:     []*RunnerGroupMapping{{Name: "pci", Properties: map[string]string{"sensitivity": "low"}, RunnerGroupID: 7}},
+			wantRequests: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+			mux := http.NewServeMux()
+			mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+			}))
+			mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+			}))
+			mux.Handle("GET /repos/google/webhook", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				fmt.Fprintf(w, `{"name": "webhook", "topics": ["go", "pci"]}`)
+			}))
+			mux.Handle("GET /repos/google/webhook/properties/values", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				fmt.Fprintf(w, `[{"property_name": "sensitivity", "value": "high"}, {"property_name": "owners", "value": ["a", "b"]}]`)
+			}))
+			mux.Handle("GET /repos/google/webhook/teams", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				fmt.Fprintf(w, `[{"slug": "security"}]`)
+			}))
+			fakeGitHub := httptest.NewServer(mux)
+			t.Cleanup(fakeGitHub.Close)
+
+			rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app, err := githubauth.NewApp("app-id", rsaPrivateKey, githubauth.WithBaseURL(fakeGitHub.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Server{
+				appClient:           app,
+				ghAPIBaseURL:        fakeGitHub.URL,
+				repoMetadataCache:   newRepoMetadataCache(),
+				runnerGroupMappings: tc.mappings,
+			}
+
+			// The second lookup must be served from the cache.
+			for range 2 {
+				got, err := s.runnerGroupMapping(t.Context(), 123, "google", "webhook")
+				if err != nil {
+					t.Fatal(err)
+				}
+				var name string
+				if got != nil {
+					name = got.Name
+				}
+				if name != tc.want {
+					t.Errorf("expected mapping %q to be %q", name, tc.want)
+				}
+			}
+
+			if got, want := requests.Load(), tc.wantRequests; got != want {
+				t.Errorf("expected %d metadata requests, got %d", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// runnerNamePrefix prefixes the names of all runners provisioned by this
+// service.
+const runnerNamePrefix = "GCP-"
+
+// Origins of the runner serving a job, used as the runner label of the job
+// runners counter.
+const (
+	runnerOriginGCP        = "gcp"
+	runnerOriginOther      = "other"
+	runnerOriginUnassigned = "unassigned"
+)
+
+// jobRunnerOrigin reports whether the job ran on a runner provisioned by this
+// service, another runner, or no runner at all, e.g. when it was cancelled
+// while queued.
+func jobRunnerOrigin(job *github.WorkflowJob) string {
+	name := job.GetRunnerName()
+	switch {
+	case name == "":
+		return runnerOriginUnassigned
+	case strings.HasPrefix(name, runnerNamePrefix):
+		return runnerOriginGCP
+	default:
+		return runnerOriginOther
+	}
+}
+
+// recordJobRunner records which runner served a job this service dispatched a
+// runner for. A job picked up by another self-hosted runner is a wasted
+// dispatch: the runner provisioned for it idles until it picks up another job
+// or its build times out.
+func (s *Server) recordJobRunner(ctx context.Context, event *github.WorkflowJobEvent, logFields []any) {
+	job := event.GetWorkflowJob()
+	if !slices.Contains(job.Labels, defaultRunnerLabel) {
+		return
+	}
+
+	origin := jobRunnerOrigin(job)
+	s.metrics.recordJobRunner(event.GetAction(), origin)
+
+	// Only count in_progress, a completed event for the same job follows.
+	if origin != runnerOriginOther || event.GetAction() != "in_progress" {
+		return
+	}
+	s.metrics.recordWastedDispatch()
+	logging.FromContext(ctx).WarnContext(ctx, "job picked up by a runner not provisioned by this service",
+		append(logFields,
+			"runner_name", job.GetRunnerName(),
+			"runner_group_name", job.GetRunnerGroupName(),
+			"runner_group_id", job.GetRunnerGroupID())...)
+}
@@ -0,0 +1,106 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
+)
+
+func TestJobRunnerOrigin(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		runnerName string
+		want       string
+	}{
+		{
+			name:       "gcp",
+			runnerName: "GCP-1234",
+			want:       runnerOriginGCP,
+		},
+		{
+			name:       "other",
+			runnerName: "office-mac-mini",
+			want:       runnerOriginOther,
+		},
+		{
+			name: "unassigned",
+			want: runnerOriginUnassigned,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := &github.WorkflowJob{RunnerName: github.Ptr(tc.runnerName)}
+			if got, want := jobRunnerOrigin(job), tc.want; got != want {
+				t.Errorf("expected origin %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRecordJobRunner(t *testing.T) {
+	t.Parallel()
+
+	srv := &Server{metrics: newWebhookMetrics(metrics.NewRegistry())}
+
+	events := []struct {
+		action     string
+		runnerName string
+		labels     []string
+	}{
+		{"in_progress", "GCP-1", []string{defaultRunnerLabel}},
+		{"completed", "GCP-1", []string{defaultRunnerLabel}},
+		{"in_progress", "office-mac-mini", []string{defaultRunnerLabel}},
+		{"completed", "office-mac-mini", []string{defaultRunnerLabel}},
+		{"completed", "", []string{defaultRunnerLabel}},
+		{"in_progress", "GitHub Actions 2", []string{"ubuntu-latest"}},
+	}
+	for _, e := range events {
+		srv.recordJobRunner(t.Context(), &github.WorkflowJobEvent{
+			Action: github.Ptr(e.action),
+			WorkflowJob: &github.WorkflowJob{
+				RunnerName: github.Ptr(e.runnerName),
+				Labels:     e.labels,
+			},
+		}, nil)
+	}
+
+	cases := []struct {
+		name    string
+		counter *metrics.Counter
+		labels  []string
+		want    float64
+	}{
+		{"gcp_in_progress", srv.metrics.jobRunners, []string{"in_progress", runnerOriginGCP}, 1},
+		{"gcp_completed", srv.metrics.jobRunners, []string{"completed", runnerOriginGCP}, 1},
+		{"other_in_progress", srv.metrics.jobRunners, []string{"in_progress", runnerOriginOther}, 1},
+		{"other_completed", srv.metrics.jobRunners, []string{"completed", runnerOriginOther}, 1},
+		{"unassigned_completed", srv.metrics.jobRunners, []string{"completed", runnerOriginUnassigned}, 1},
+		{"wasted", srv.metrics.wastedDispatches, nil, 1},
+	}
+	for _, tc := range cases {
+		if got := tc.counter.Value(tc.labels...); got != tc.want {
+			t.Errorf("%s: expected %v to be %v", tc.name, got, tc.want)
+		}
+	}
+}
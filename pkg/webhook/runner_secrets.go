@@ -0,0 +1,114 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+var (
+	// secretNameRegexp matches the short names of secrets, in the runner
+	// project.
+	secretNameRegexp = regexp.MustCompile(`^[\w-]+$`)
+
+	// secretVersionRegexp matches the full names of secrets, with an optional
+	// version.
+	secretVersionRegexp = regexp.MustCompile(`^projects/[^/]+/secrets/[\w-]+(/versions/(latest|[0-9]+))?$`)
+)
+
+// runnerSecretVersions returns the Secret Manager secret versions the
+// environment variables of the runner are read from, by variable name.
+// Secrets are given by their name in the runner project or their full name,
+// with or without a version, and default to their latest version. The
+// variables cannot override those the webhook service sets.
+func runnerSecretVersions(projectID string, secrets map[string]string) (map[string]string, error) {
+	versions := make(map[string]string, len(secrets))
+	for name, secret := range secrets {
+		if !envNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("environment variable name %q is invalid", name)
+		}
+		if _, ok := runnerEnvSubstitutions[name]; ok || name == jitConfigEnv {
+			return nil, fmt.Errorf("environment variable %q is set by the webhook service", name)
+		}
+
+		switch {
+		case secretNameRegexp.MatchString(secret):
+			versions[name] = fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectID, secret)
+		case secretVersionRegexp.MatchString(secret):
+			versions[name] = secret
+			if !strings.Contains(secret, "/versions/") {
+				versions[name] += "/versions/latest"
+			}
+		default:
+			return nil, fmt.Errorf("secret %q of environment variable %q must be a secret name or in the form "+
+				"projects/<project>/secrets/<secret>[/versions/<version>]", secret, name)
+		}
+	}
+	return versions, nil
+}
+
+// addRunnerSecrets has Cloud Build read the secrets of the runner into the
+// environment of the run step when the build starts, which passes them on to
+// the runner. Their values never appear in the build.
+func (s *Server) addRunnerSecrets(build *cloudbuildpb.Build) {
+	if len(s.runnerSecrets) == 0 {
+		return
+	}
+	i := slices.IndexFunc(build.GetSteps(), func(step *cloudbuildpb.BuildStep) bool {
+		return step.GetId() == "run"
+	})
+	if i < 0 {
+		return
+	}
+
+	if build.AvailableSecrets == nil {
+		build.AvailableSecrets = &cloudbuildpb.Secrets{}
+	}
+	run := build.GetSteps()[i]
+	args := make([]string, 0, len(s.runnerSecrets))
+	for _, name := range slices.Sorted(maps.Keys(s.runnerSecrets)) {
+		build.AvailableSecrets.SecretManager = append(build.AvailableSecrets.SecretManager,
+			&cloudbuildpb.SecretManagerSecret{VersionName: s.runnerSecrets[name], Env: name})
+		run.SecretEnv = append(run.SecretEnv, name)
+		args = append(args, "-e "+name)
+	}
+	build.Substitutions["_SECRET_ENV_ARGS"] = strings.Join(args, " ")
+}
+
+// buildSecretEnv returns the environment variables of the runner build read
+// from Secret Manager, by secret version, nil for none.
+func buildSecretEnv(build *cloudbuildpb.Build) map[string]string {
+	var env map[string]string
+	for _, secret := range build.GetAvailableSecrets().GetSecretManager() {
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[secret.GetEnv()] = secret.GetVersionName()
+	}
+	return env
+}
+
+// SecretEnv returns the environment variables of the runner read from Secret
+// Manager, by secret version, including its JIT configuration when it was
+// moved to a secret.
+func (r *RunnerSpec) SecretEnv() map[string]string {
+	return buildSecretEnv(r.Build)
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerSecretVersions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		secrets map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "forms",
+			secrets: map[string]string{
+				"NPM_TOKEN":   "npm-token",
+				"SONAR_TOKEN": "projects/shared/secrets/sonar-token",
+				"PYPI_TOKEN":  "projects/shared/secrets/pypi-token/versions/3",
+			},
+			want: map[string]string{
+				"NPM_TOKEN":   "projects/runner-project/secrets/npm-token/versions/latest",
+				"SONAR_TOKEN": "projects/shared/secrets/sonar-token/versions/latest",
+				"PYPI_TOKEN":  "projects/shared/secrets/pypi-token/versions/3",
+			},
+		},
+		{
+			name:    "invalid_name",
+			secrets: map[string]string{"NPM-TOKEN": "npm-token"},
+			wantErr: `environment variable name "NPM-TOKEN" is invalid`,
+		},
+		{
+			name:    "reserved",
+			secrets: map[string]string{jitConfigEnv: "jit-config"},
+			wantErr: `environment variable "ENCODED_JIT_CONFIG" is set by the webhook service`,
+		},
+		{
+			name:    "invalid_secret",
+			secrets: map[string]string{"NPM_TOKEN": "projects/shared/secrets/npm-token/versions/"},
+			wantErr: `secret "projects/shared/secrets/npm-token/versions/" of environment variable "NPM_TOKEN" must be a secret name`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := runnerSecretVersions("runner-project", tc.secrets)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected secret versions (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddRunnerSecrets(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		jitConfigSecretTTL: time.Hour,
+		runnerProjectID:    "runner-project",
+		runnerImageName:    "default-runner",
+		runnerImageTag:     "latest",
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+		runnerSecrets: map[string]string{
+			"NPM_TOKEN":   "projects/runner-project/secrets/npm-token/versions/latest",
+			"SONAR_TOKEN": "projects/shared/secrets/sonar-token/versions/3",
+		},
+		secrets: &MockSecretStore{},
+	}
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+	if _, err := s.moveJITConfigToSecret(t.Context(), build, "GCP-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	var run []string
+	for _, step := range build.GetSteps() {
+		if step.GetId() == "run" {
+			run = step.GetSecretEnv()
+		}
+	}
+	if diff := cmp.Diff([]string{"NPM_TOKEN", "SONAR_TOKEN", jitConfigEnv}, run); diff != "" {
+		t.Errorf("unexpected run step secret env (-want, +got):\n%s", diff)
+	}
+	if got, want := build.GetSubstitutions()["_SECRET_ENV_ARGS"], "-e NPM_TOKEN -e SONAR_TOKEN"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	spec := &RunnerSpec{RunnerName: "GCP-2", Build: build}
+	wantSecretEnv := map[string]string{
+		"NPM_TOKEN":   "projects/runner-project/secrets/npm-token/versions/latest",
+		"SONAR_TOKEN": "projects/shared/secrets/sonar-token/versions/3",
+		jitConfigEnv:  "projects/runner-project/secrets/jit-config-GCP-2/versions/1",
+	}
+	if diff := cmp.Diff(wantSecretEnv, spec.SecretEnv()); diff != "" {
+		t.Errorf("unexpected secret env (-want, +got):\n%s", diff)
+	}
+	if _, got := spec.JITConfig(); got != wantSecretEnv[jitConfigEnv] {
+		t.Errorf("expected %q to be %q", got, wantSecretEnv[jitConfigEnv])
+	}
+
+	metadata := runnerVMMetadata(build, spec.RunnerName)
+	if got, want := metadata[vmJITConfigSecretKey], wantSecretEnv[jitConfigEnv]; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := metadata[vmRunnerSecretsKey], "NPM_TOKEN=projects/runner-project/secrets/npm-token/versions/latest,"+
+		"SONAR_TOKEN=projects/shared/secrets/sonar-token/versions/3"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
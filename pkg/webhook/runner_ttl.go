@@ -0,0 +1,274 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// runnerTTLCheckInterval is how often runners are checked against their
+// maximum lifetime.
+const runnerTTLCheckInterval = time.Minute
+
+// runRunnerTTLMonitor expires runners past their maximum lifetime until ctx
+// is done.
+func (s *Server) runRunnerTTLMonitor(ctx context.Context) {
+	ticker := time.NewTicker(runnerTTLCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expireRunners(ctx, time.Now())
+		}
+	}
+}
+
+// runnerAge returns how long ago the runner was dispatched, or queued if it
+// was not dispatched yet.
+func runnerAge(r *trackedRunner, now time.Time) time.Duration {
+	since := r.Lifecycle.EnteredAt(lifecycle.StateDispatching)
+	if since.IsZero() {
+		since = r.Lifecycle.EnteredAt(lifecycle.StateQueued)
+	}
+	return now.Sub(since)
+}
+
+// expireRunners cancels the backend of the runners older than the maximum
+// runner lifetime and removes them from their repository or organization,
+// whatever their job is doing. A hung job otherwise holds its machine until
+// the backend times out, if ever. The tracked runners are expired first, then
+// the runner builds of every runner project and location, which include the
+// runners dispatched before a restart or by another instance. It returns the
+// number of runners expired.
+func (s *Server) expireRunners(ctx context.Context, now time.Time) int {
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return 0
+	}
+
+	s.syncRunners(ctx)
+	logger := logging.FromContext(ctx)
+	expired := 0
+	tracked := make(map[string]struct{})
+	for _, r := range s.runners.List() {
+		tracked[r.RunnerName] = struct{}{}
+		state := r.Lifecycle.State()
+		age := runnerAge(&r, now)
+		if state.Terminal() || age < s.runnerMaxLifetime {
+			continue
+		}
+
+		logFields := []any{
+			"org", r.Org,
+			"repo", r.Repo,
+			"run_id", r.RunID,
+			"job_id", r.JobID,
+			"runner_id", r.RunnerName,
+			"backend", r.Backend,
+			"backend_id", r.BackendID,
+			"state", state,
+			"age", age.String(),
+		}
+
+		if backend := s.backendNamed(r.Backend); backend != nil && r.BackendID != "" {
+			if err := backend.Cancel(ctx, r.BackendID); err != nil {
+				logger.ErrorContext(ctx, "failed to cancel expired runner", append(logFields, "error", err)...)
+				continue
+			}
+		}
+		if r.InstallationID != 0 && r.Org != "" && r.Repo != "" {
+			if err := s.removeExpiredRunner(ctx, &r); err != nil {
+				logger.WarnContext(ctx, "failed to remove expired runner", append(logFields, "error", err)...)
+			}
+		}
+
+		s.transitionRunner(ctx, r.RunnerName, lifecycle.StateFailed)
+		s.runners.Remove(r.RunnerName)
+		s.launchQueue.notify()
+		s.metrics.recordRunnerExpired(state)
+		expired++
+
+		logger.WarnContext(ctx, "expired runner past its maximum lifetime", logFields...)
+		s.notify(ctx, &Notification{
+			Kind:  NotificationRunnerExpired,
+			Title: fmt.Sprintf("Runner expired for %s/%s", r.Org, r.Repo),
+			Text: fmt.Sprintf("Runner %s (build %s, run %d, job %d) was cancelled after %s, past the maximum runner lifetime of %s",
+				r.RunnerName, r.BuildID, r.RunID, r.JobID, age.Round(time.Second), s.runnerMaxLifetime),
+		})
+	}
+	return expired + s.expireRunnerBuilds(ctx, now, tracked)
+}
+
+// expireRunnerBuilds cancels the runner builds created before the maximum
+// runner lifetime in every runner project and location whose runner is not
+// tracked, and removes their runner from GitHub. It returns the number of
+// runners expired.
+func (s *Server) expireRunnerBuilds(ctx context.Context, now time.Time, tracked map[string]struct{}) int {
+	if s.cbc == nil {
+		return 0
+	}
+
+	logger := logging.FromContext(ctx)
+	cutoff := now.Add(-s.runnerMaxLifetime)
+	expired := 0
+	seen := make(map[string]struct{})
+	for _, placement := range s.runnerBuildPlacements() {
+		for _, st := range activeBuildStatuses {
+			builds, err := s.cbc.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{
+				Parent:    placement.parent(),
+				ProjectId: placement.ProjectID,
+				Filter:    fmt.Sprintf("status=%q AND create_time<%q", st.String(), cutoff.Format(time.RFC3339)),
+			})
+			if err != nil {
+				logger.WarnContext(ctx, "failed to list runner builds to expire",
+					"runner_project_id", placement.ProjectID,
+					"runner_location", placement.Location,
+					"status", st.String(),
+					"error", err)
+				continue
+			}
+
+			for _, build := range builds {
+				buildName := placement.buildName(build.GetId())
+				if _, ok := seen[buildName]; ok {
+					continue
+				}
+				seen[buildName] = struct{}{}
+
+				scope, runnerName, ok := buildRunnerScope(build)
+				if !ok || build.GetCreateTime() == nil || !build.GetCreateTime().AsTime().Before(cutoff) ||
+					!slices.Contains(activeBuildStatuses, build.GetStatus()) {
+					continue
+				}
+				if _, ok := tracked[runnerName]; ok {
+					continue
+				}
+				if s.expireRunnerBuild(ctx, now, placement, build, scope, runnerName) {
+					expired++
+				}
+			}
+		}
+	}
+	return expired
+}
+
+// expireRunnerBuild cancels the untracked runner build past the maximum
+// runner lifetime, removes its runner from GitHub and records it as failed in
+// the state store. It reports whether the build was cancelled.
+func (s *Server) expireRunnerBuild(ctx context.Context, now time.Time, placement runnerBuildPlacement, build *cloudbuildpb.Build,
+	scope runnerScope, runnerName string,
+) bool {
+	logger := logging.FromContext(ctx)
+	age := now.Sub(build.GetCreateTime().AsTime())
+	logFields := []any{
+		"org", scope.Org,
+		"repo", scope.Repo,
+		"runner_id", runnerName,
+		"build_id", build.GetId(),
+		"runner_project_id", placement.ProjectID,
+		"runner_location", placement.Location,
+		"age", age.String(),
+	}
+
+	// Without its stored state, a running build is assumed to be running a
+	// job.
+	state := lifecycle.StateProvisioning
+	if build.GetStatus() == cloudbuildpb.Build_WORKING {
+		state = lifecycle.StateRunning
+	}
+	var stored *RunnerState
+	if s.stateStore != nil {
+		got, err := s.stateStore.GetRunner(ctx, runnerName)
+		switch {
+		case errors.Is(err, errStateNotFound):
+		case err != nil:
+			logger.WarnContext(ctx, "failed to read state of expired runner", append(logFields, "error", err)...)
+		default:
+			stored = got
+			if !got.State.Terminal() {
+				state = got.State
+			}
+		}
+	}
+	logFields = append(logFields, "state", state)
+
+	if err := s.cloudBuildBackend().Cancel(ctx, placement.buildName(build.GetId())); err != nil {
+		logger.ErrorContext(ctx, "failed to cancel expired runner", append(logFields, "error", err)...)
+		return false
+	}
+
+	// The runner is removed where it was registered.
+	groupID, err := s.buildRunnerGroupID(ctx, build, scope.InstallationID, scope.Org, scope.Repo)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to determine runner group of expired runner", append(logFields, "error", err)...)
+	} else if err := s.removeExpiredRunner(ctx, &trackedRunner{
+		RunnerName:     runnerName,
+		InstallationID: scope.InstallationID,
+		Org:            scope.Org,
+		Repo:           scope.Repo,
+		RunnerGroupID:  groupID,
+	}); err != nil {
+		logger.WarnContext(ctx, "failed to remove expired runner", append(logFields, "error", err)...)
+	}
+
+	if stored != nil && !stored.State.Terminal() {
+		stored.State = lifecycle.StateFailed
+		if stored.EnteredAt == nil {
+			stored.EnteredAt = make(map[lifecycle.State]time.Time)
+		}
+		stored.EnteredAt[lifecycle.StateFailed] = now
+		if err := s.stateStore.PutRunner(ctx, stored); err != nil {
+			logger.WarnContext(ctx, "failed to save runner state", append(logFields, "error", err)...)
+		}
+	}
+	s.metrics.recordRunnerExpired(state)
+
+	logger.WarnContext(ctx, "expired runner past its maximum lifetime", logFields...)
+	s.notify(ctx, &Notification{
+		Kind:  NotificationRunnerExpired,
+		Title: fmt.Sprintf("Runner expired for %s/%s", scope.Org, scope.Repo),
+		Text: fmt.Sprintf("Runner %s (build %s) was cancelled after %s, past the maximum runner lifetime of %s",
+			runnerName, build.GetId(), age.Round(time.Second), s.runnerMaxLifetime),
+	})
+	return true
+}
+
+// removeExpiredRunner removes the runner from the repository or organization
+// it was registered with, busy or not: its build or VM was cancelled.
+func (s *Server) removeExpiredRunner(ctx context.Context, r *trackedRunner) error {
+	gh, registered, err := s.lookupRunner(ctx, r)
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return nil
+	}
+	if err := s.removeRegisteredRunner(ctx, gh, r, registered.GetID()); err != nil {
+		return fmt.Errorf("failed to remove runner %d: %w", registered.GetID(), err)
+	}
+	return nil
+}
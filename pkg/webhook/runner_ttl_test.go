@@ -0,0 +1,245 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/githubauth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+	"github.com/google/github_actions_on_gcp/pkg/testing/fakecloudbuild"
+)
+
+func TestExpireRunners(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var removed []string
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 1, "runners": [{"id": 7, "name": %q, "status": "online", "busy": true}]}`,
+			r.URL.Query().Get("name"))
+	}))
+	mux.Handle("DELETE /repos/google/webhook/actions/runners/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	fakeGitHub := httptest.NewServer(mux)
+	t.Cleanup(fakeGitHub.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	runner := func(name string, dispatchedAt time.Time, states ...lifecycle.State) *trackedRunner {
+		l := lifecycle.New(dispatchedAt.Add(-time.Minute))
+		for _, s := range append([]lifecycle.State{lifecycle.StateDispatching}, states...) {
+			if err := l.Transition(s, dispatchedAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &trackedRunner{
+			RunnerName:     name,
+			InstallationID: 123,
+			Org:            "google",
+			Repo:           "webhook",
+			JobID:          1,
+			Backend:        runnerBackendCloudBuild,
+			BackendID:      "projects/runner-project/locations/us-central1/builds/" + name,
+			Lifecycle:      l,
+		}
+	}
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		appClient:         app,
+		cbc:               cbc,
+		ghAPIBaseURL:      fakeGitHub.URL,
+		runnerMaxLifetime: 6 * time.Hour,
+		runners:           newRunnerTracker(),
+	}
+	srv.runners.Dispatched(runner("GCP-hung", now.Add(-7*time.Hour), lifecycle.StateProvisioning, lifecycle.StateRunning))
+	srv.runners.Dispatched(runner("GCP-young", now.Add(-time.Hour), lifecycle.StateProvisioning, lifecycle.StateRunning))
+
+	if got, want := srv.expireRunners(t.Context(), now), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	var cancelled []string
+	for _, req := range cbc.cancelBuildReqs {
+		cancelled = append(cancelled, req.GetId())
+	}
+	if diff := cmp.Diff([]string{"GCP-hung"}, cancelled); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"/repos/google/webhook/actions/runners/7"}, removed); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if _, ok := srv.trackedRunner("GCP-hung"); ok {
+		t.Errorf("expected the expired runner to no longer be tracked")
+	}
+	if _, ok := srv.trackedRunner("GCP-young"); !ok {
+		t.Errorf("expected the young runner to still be tracked")
+	}
+}
+
+func TestExpireRunners_ShadowMode(t *testing.T) {
+	t.Parallel()
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		cbc:               cbc,
+		runnerMaxLifetime: time.Minute,
+		runners:           newRunnerTracker(),
+		shadowMode:        true,
+	}
+	srv.runners.Dispatched(trackedRunnerIn(t, "GCP-runner", lifecycle.StateRunning))
+
+	if got, want := srv.expireRunners(t.Context(), time.Now().Add(time.Hour)), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if len(cbc.cancelBuildReqs) != 0 {
+		t.Errorf("expected no builds to be cancelled in shadow mode")
+	}
+}
+
+func TestExpireRunners_Builds(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Now()
+
+	fake, opts := fakecloudbuild.Start(t)
+	cb, err := NewCloudBuild(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cb.Close() })
+
+	build := func(name string, created time.Time, st cloudbuildpb.Build_Status, tags ...string) {
+		id := name[strings.LastIndex(name, "/")+1:]
+		fake.AddBuild(&cloudbuildpb.Build{
+			Id:         id,
+			Name:       name,
+			Status:     st,
+			CreateTime: timestamppb.New(created),
+			Tags:       append([]string{"runner-GCP-" + id, "installation-123", "org-google", "repo-webhook"}, tags...),
+		})
+	}
+	// Dispatched by another instance, or before a restart.
+	build("projects/routed-project/locations/europe-west1/builds/hung", now.Add(-7*time.Hour), cloudbuildpb.Build_WORKING)
+	build("projects/runner-project/locations/us-east1/builds/grouped", now.Add(-8*time.Hour), cloudbuildpb.Build_WORKING, "group-3")
+	build("projects/runner-project/locations/us-central1/builds/young", now.Add(-time.Hour), cloudbuildpb.Build_WORKING)
+	build("projects/runner-project/locations/us-central1/builds/done", now.Add(-7*time.Hour), cloudbuildpb.Build_SUCCESS)
+	// Tracked by this instance, which expires it by its dispatch time.
+	build("projects/runner-project/locations/us-central1/builds/tracked", now.Add(-7*time.Hour), cloudbuildpb.Build_QUEUED)
+
+	registry, app, ghURL := newFakeRunnerRegistry(t)
+	registry.register("GCP-hung", false, false)
+	registry.register("GCP-grouped", true, false)
+	registry.register("GCP-young", false, false)
+
+	store := newMemStateStore()
+	// Stored by another instance, the runner is restored and expired by its
+	// dispatch time.
+	if err := store.PutRunner(ctx, &RunnerState{
+		RunnerName:     "GCP-hung",
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		Backend:        runnerBackendCloudBuild,
+		BackendID:      "projects/routed-project/locations/europe-west1/builds/hung",
+		State:          lifecycle.StateRunning,
+		EnteredAt: map[lifecycle.State]time.Time{
+			lifecycle.StateDispatching: now.Add(-7 * time.Hour),
+			lifecycle.StateRunning:     now.Add(-7 * time.Hour),
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{
+		appClient:               app,
+		cbc:                     cb,
+		ghAPIBaseURL:            ghURL,
+		runnerFallbackLocations: []string{"us-east1"},
+		runnerLocation:          "us-central1",
+		runnerMaxLifetime:       6 * time.Hour,
+		runnerProjectID:         "runner-project",
+		runnerProjectRoutes: map[string]*RunnerProjectRoute{
+			"google": {ProjectID: "routed-project", Location: "europe-west1"},
+		},
+		runners:    newRunnerTracker(),
+		stateStore: store,
+	}
+	srv.runners.Dispatched(trackedRunnerIn(t, "GCP-tracked", lifecycle.StateProvisioning))
+
+	if got, want := srv.expireRunners(ctx, now), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	var cancelled []string
+	for _, req := range fake.Requests(fakecloudbuild.MethodCancelBuild) {
+		cancelled = append(cancelled, req.(*cloudbuildpb.CancelBuildRequest).GetName())
+	}
+	slices.Sort(cancelled)
+	want := []string{
+		"projects/routed-project/locations/europe-west1/builds/hung",
+		"projects/runner-project/locations/us-east1/builds/grouped",
+	}
+	if diff := cmp.Diff(want, cancelled); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-hung"}, registry.removedRunners()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+
+	state, err := store.GetRunner(ctx, "GCP-hung")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := state.State, lifecycle.StateFailed; got != want {
+		t.Errorf("expected stored state %s to be %s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+// runnerLogsPrefix returns the folder of the runner logs bucket the build logs
+// of a job's runner are written to. Runners provisioned ahead of demand do not
+// know their job yet and are keyed by runner name instead.
+func runnerLogsPrefix(req *runnerRequest) string {
+	if job := req.Job.GetWorkflowJob(); job != nil {
+		return jobLogsPrefix(req.Org, req.Repo, job.GetRunID(), job.GetID())
+	}
+	return fmt.Sprintf("%s/%s/runners/%s", req.Org, req.Repo, req.RunnerName)
+}
+
+// jobLogsPrefix returns the folder of the runner logs bucket holding the logs
+// of the job's runner.
+func jobLogsPrefix(org, repo string, runID, jobID int64) string {
+	return fmt.Sprintf("%s/%s/%d/%d", org, repo, runID, jobID)
+}
+
+// runnerLogsObject returns the object Cloud Build writes the logs of the build
+// to, "" if the build does not write its logs to a bucket.
+func runnerLogsObject(build *cloudbuildpb.Build) string {
+	if build.GetLogsBucket() == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/log-%s.txt", build.GetLogsBucket(), build.GetId())
+}
+
+// addLogsBucket streams the build logs to the runner logs bucket, in addition
+// to Cloud Logging, so they outlive the Cloud Build log retention.
+func (s *Server) addLogsBucket(build *cloudbuildpb.Build, req *runnerRequest) {
+	if s.runnerLogsBucket == "" {
+		return
+	}
+
+	build.LogsBucket = fmt.Sprintf("gs://%s/%s", s.runnerLogsBucket, runnerLogsPrefix(req))
+	build.Options.Logging = cloudbuildpb.BuildOptions_LEGACY
+	build.Options.LogStreamingOption = cloudbuildpb.BuildOptions_STREAM_ON
+}
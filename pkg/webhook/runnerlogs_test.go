@@ -0,0 +1,91 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestAddLogsBucket(t *testing.T) {
+	t.Parallel()
+
+	job := &github.WorkflowJobEvent{
+		WorkflowJob: &github.WorkflowJob{
+			ID:    github.Ptr(int64(2)),
+			RunID: github.Ptr(int64(1)),
+		},
+	}
+
+	cases := []struct {
+		name        string
+		bucket      string
+		job         *github.WorkflowJobEvent
+		wantBucket  string
+		wantLogging cloudbuildpb.BuildOptions_LoggingMode
+		wantObject  string
+	}{
+		{
+			name:        "disabled",
+			job:         job,
+			wantLogging: cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY,
+		},
+		{
+			name:        "job",
+			bucket:      "runner-logs",
+			job:         job,
+			wantBucket:  "gs://runner-logs/google/webhook/1/2",
+			wantLogging: cloudbuildpb.BuildOptions_LEGACY,
+			wantObject:  "gs://runner-logs/google/webhook/1/2/log-build-id.txt",
+		},
+		{
+			name:        "ahead_of_demand",
+			bucket:      "runner-logs",
+			wantBucket:  "gs://runner-logs/google/webhook/runners/GCP-dispatch-1",
+			wantLogging: cloudbuildpb.BuildOptions_LEGACY,
+			wantObject:  "gs://runner-logs/google/webhook/runners/GCP-dispatch-1/log-build-id.txt",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerLogsBucket: tc.bucket}
+			req := &runnerRequest{
+				Org:        "google",
+				Repo:       "webhook",
+				RunnerName: "GCP-dispatch-1",
+				Job:        tc.job,
+			}
+
+			build := s.runnerBuild(req, "jit")
+			if got, want := build.GetLogsBucket(), tc.wantBucket; got != want {
+				t.Errorf("expected logs bucket %q to be %q", got, want)
+			}
+			if got, want := build.GetOptions().GetLogging(), tc.wantLogging; got != want {
+				t.Errorf("expected logging %q to be %q", got, want)
+			}
+
+			build.Id = "build-id"
+			if got, want := runnerLogsObject(build), tc.wantObject; got != want {
+				t.Errorf("expected logs object %q to be %q", got, want)
+			}
+		})
+	}
+}
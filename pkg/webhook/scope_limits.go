@@ -0,0 +1,318 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+// deferredDispatchInterval is how often runners deferred over a repository
+// or organization limit are retried.
+const deferredDispatchInterval = 10 * time.Second
+
+// Scopes a runner limit applies to, used as the scope label of the deferred
+// runner metric.
+const (
+	scopeRepo = "repository"
+	scopeOrg  = "organization"
+)
+
+// Responses to runner requests over a repository or organization limit.
+var (
+	runnerDeferredMsg   = "runner deferred until the repository or organization is under its runner limit"
+	runnerScopeLimitMsg = "no action taken for runner over the repository or organization runner limit"
+)
+
+// scopeLimits caps the runners a single repository and a single organization
+// may have at once, so a runaway matrix does not start unbounded builds.
+// Names are compared case-insensitively. A nil scopeLimits allows everything.
+//
+// The runners are counted from the runners tracked in memory, which only
+// go away when the events of their job reach the instance that dispatched
+// them, so the limits require the service to run on a single instance. The
+// Terraform module caps the service at one instance, with CPU always
+// allocated for the deferred dispatcher, when a limit is set.
+type scopeLimits struct {
+	repoMaxRunners int
+	orgMaxRunners  int
+	overrides      map[string]int
+}
+
+// newScopeLimits returns the limits for the default maximum number of runners
+// per repository and per organization and the maximums of individual
+// organizations and repositories, given as org=count or org/repo=count. A
+// maximum of 0 is unlimited. It returns nil if nothing is limited.
+func newScopeLimits(repoMaxRunners, orgMaxRunners int, overrides map[string]string) (*scopeLimits, error) {
+	if repoMaxRunners < 0 {
+		return nil, fmt.Errorf("maximum runners per repository must not be negative, got %d", repoMaxRunners)
+	}
+	if orgMaxRunners < 0 {
+		return nil, fmt.Errorf("maximum runners per organization must not be negative, got %d", orgMaxRunners)
+	}
+
+	l := &scopeLimits{
+		repoMaxRunners: repoMaxRunners,
+		orgMaxRunners:  orgMaxRunners,
+		overrides:      make(map[string]int, len(overrides)),
+	}
+	for scope, v := range overrides {
+		key := strings.ToLower(strings.TrimSpace(scope))
+		if org, repo, ok := strings.Cut(key, "/"); key == "" || org == "" || (ok && (repo == "" || strings.Contains(repo, "/"))) {
+			return nil, fmt.Errorf("runner limit scope %q must be an organization or org/repo", scope)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("maximum runners for %q is not a number: %w", scope, err)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("maximum runners for %q must not be negative, got %d", scope, n)
+		}
+		l.overrides[key] = n
+	}
+
+	if l.repoMaxRunners == 0 && l.orgMaxRunners == 0 && len(l.overrides) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// repoMax returns the maximum number of runners of the repository, 0 if
+// unlimited.
+func (l *scopeLimits) repoMax(org, repo string) int {
+	if n, ok := l.overrides[strings.ToLower(org+"/"+repo)]; ok {
+		return n
+	}
+	return l.repoMaxRunners
+}
+
+// orgMax returns the maximum number of runners of the organization, 0 if
+// unlimited.
+func (l *scopeLimits) orgMax(org string) int {
+	if n, ok := l.overrides[strings.ToLower(org)]; ok {
+		return n
+	}
+	return l.orgMaxRunners
+}
+
+// check returns the scope whose limit a new runner for the repository
+// exceeds, given the number of runners the repository and its organization
+// already have, or "" if it is allowed.
+func (l *scopeLimits) check(org, repo string, repoRunners, orgRunners int) string {
+	if l == nil {
+		return ""
+	}
+	if n := l.repoMax(org, repo); n > 0 && repoRunners >= n {
+		return scopeRepo
+	}
+	if n := l.orgMax(org); n > 0 && orgRunners >= n {
+		return scopeOrg
+	}
+	return ""
+}
+
+// deferredRunners holds, in order, the runners of queued jobs deferred over
+// a repository or organization limit until a runner of theirs goes away.
+// Runners still deferred when the server shuts down, for example on a
+// deployment, are lost.
+type deferredRunners struct {
+	mu    sync.Mutex
+	items []*dispatchItem
+	size  int
+}
+
+func newDeferredRunners(size int) *deferredRunners {
+	return &deferredRunners{size: size}
+}
+
+// add appends the item and reports whether there was room for it.
+func (d *deferredRunners) add(item *dispatchItem) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.items) >= d.size {
+		return false
+	}
+	d.items = append(d.items, item)
+	return true
+}
+
+// drop removes the runner deferred for the job and reports whether there was
+// one. A nil deferredRunners holds nothing.
+func (d *deferredRunners) drop(jobID int64) bool {
+	if d == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, item := range d.items {
+		if item.req.Job.GetWorkflowJob().GetID() == jobID {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// take removes and returns, in order, the items accept returns true for.
+func (d *deferredRunners) take(accept func(*dispatchItem) bool) []*dispatchItem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var taken []*dispatchItem
+	kept := d.items[:0]
+	for _, item := range d.items {
+		if accept(item) {
+			taken = append(taken, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	clear(d.items[len(kept):])
+	d.items = kept
+	return taken
+}
+
+// len returns the number of deferred runners.
+func (d *deferredRunners) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.items)
+}
+
+// checkScopeLimits returns the response to a runner request over its
+// repository or organization limit. Runners of queued jobs are deferred until
+// the repository and organization are under their limits, runners
+// provisioned ahead of demand are dropped. If the runner is allowed, it
+// returns a nil response and the function releasing the reservation counting
+// the runner against the limits, which the caller must call once the runner
+// is tracked or given up.
+func (s *Server) checkScopeLimits(ctx context.Context, req *runnerRequest, logFields []any) (func(), *apiResponse) {
+	if s.scopeLimits == nil {
+		return func() {}, nil
+	}
+
+	logger := logging.FromContext(ctx)
+
+	// The counts only cover the runners of this instance, which is the only
+	// one, see scopeLimits. The runner is counted from the check on, so
+	// concurrent requests cannot all pass the same limit.
+	var scope string
+	if s.runners.Reserve(req.RunnerName, req.Org, req.Repo, func(repoRunners, orgRunners int) bool {
+		scope = s.scopeLimits.check(req.Org, req.Repo, repoRunners, orgRunners)
+		return scope == ""
+	}) {
+		return func() { s.runners.Release(req.RunnerName) }, nil
+	}
+
+	logFields = append(logFields, "limit_scope", scope)
+	if req.Job == nil {
+		logger.WarnContext(ctx, "rejecting runner over the repository or organization runner limit", logFields...)
+		return nil, &apiResponse{http.StatusOK, runnerScopeLimitMsg, nil}
+	}
+
+	if !s.deferredRunners.add(&dispatchItem{req: req, logFields: logFields}) {
+		logger.WarnContext(ctx, "deferred runner queue is full", logFields...)
+		return nil, &apiResponse{http.StatusServiceUnavailable, "deferred runner queue is full", nil}
+	}
+	s.metrics.recordDeferredRunner(scope)
+
+	logger.InfoContext(ctx, runnerDeferredMsg, append(logFields, "deferred_runners", s.deferredRunners.len())...)
+	return nil, &apiResponse{http.StatusAccepted, runnerDeferredMsg, nil}
+}
+
+// runDeferredDispatcher retries the deferred runners until ctx is done.
+func (s *Server) runDeferredDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(deferredDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.dispatchDeferred(ctx, time.Now())
+		}
+	}
+}
+
+// dispatchDeferred dispatches, in order, the deferred runners whose
+// repository and organization are now under their limits, and drops those
+// whose job went stale. It returns the number of runners dispatched.
+func (s *Server) dispatchDeferred(ctx context.Context, now time.Time) int {
+	logger := logging.FromContext(ctx)
+	s.syncRunners(ctx)
+
+	repoRunners := make(map[string]int)
+	orgRunners := make(map[string]int)
+	for _, r := range s.runners.List() {
+		repoRunners[strings.ToLower(r.Org+"/"+r.Repo)]++
+		orgRunners[strings.ToLower(r.Org)]++
+	}
+
+	ready := s.deferredRunners.take(func(item *dispatchItem) bool {
+		if _, stale := s.jobStale(item.req.Job, now); stale {
+			return true
+		}
+		repoKey := strings.ToLower(item.req.Org + "/" + item.req.Repo)
+		orgKey := strings.ToLower(item.req.Org)
+		if s.scopeLimits.check(item.req.Org, item.req.Repo, repoRunners[repoKey], orgRunners[orgKey]) != "" {
+			return false
+		}
+		// Count the runner so the items behind it see the limit it takes up.
+		repoRunners[repoKey]++
+		orgRunners[orgKey]++
+		return true
+	})
+
+	dispatched := 0
+	for _, item := range ready {
+		ctx := ctx
+		if item.req.DeliveryID != "" {
+			ctx = contextWithDeliveryID(ctx, item.req.DeliveryID)
+		}
+		if age, stale := s.jobStale(item.req.Job, now); stale {
+			logger.WarnContext(ctx, "dropping deferred runner for job older than the queue TTL",
+				append(item.logFields, "queue_age", age.String(), "queue_ttl", s.queueTTL.String())...)
+			continue
+		}
+
+		if s.dispatchQueue != nil {
+			if resp := s.enqueueRunner(ctx, item.req, item.logFields); resp.Code == http.StatusAccepted {
+				dispatched++
+			}
+			continue
+		}
+		// Failures are logged and notified by provisionRunner, there is no
+		// delivery left to respond to.
+		createdBuild, errResponse := s.provisionRunner(ctx, item.req, item.logFields)
+		if errResponse != nil {
+			continue
+		}
+		s.runnerDispatched(ctx, item.req, createdBuild, item.logFields)
+		dispatched++
+	}
+	return dispatched
+}
@@ -0,0 +1,408 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScopeLimitsCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		repoMax     int
+		orgMax      int
+		overrides   map[string]string
+		org         string
+		repo        string
+		repoRunners int
+		orgRunners  int
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "unlimited",
+			org:         "google",
+			repo:        "webhook",
+			repoRunners: 1000,
+			orgRunners:  1000,
+		},
+		{
+			name:        "under_limits",
+			repoMax:     20,
+			orgMax:      100,
+			org:         "google",
+			repo:        "webhook",
+			repoRunners: 19,
+			orgRunners:  99,
+		},
+		{
+			name:        "repo_at_limit",
+			repoMax:     20,
+			orgMax:      100,
+			org:         "google",
+			repo:        "webhook",
+			repoRunners: 20,
+			orgRunners:  20,
+			want:        scopeRepo,
+		},
+		{
+			name:        "org_at_limit",
+			repoMax:     20,
+			orgMax:      100,
+			org:         "google",
+			repo:        "webhook",
+			repoRunners: 5,
+			orgRunners:  100,
+			want:        scopeOrg,
+		},
+		{
+			name:        "repo_override_raises_limit",
+			repoMax:     20,
+			overrides:   map[string]string{"Google/Monorepo": "50"},
+			org:         "google",
+			repo:        "monorepo",
+			repoRunners: 20,
+		},
+		{
+			name:        "repo_override_removes_limit",
+			repoMax:     20,
+			overrides:   map[string]string{"google/monorepo": "0"},
+			org:         "google",
+			repo:        "monorepo",
+			repoRunners: 1000,
+		},
+		{
+			name:       "org_override_lowers_limit",
+			orgMax:     100,
+			overrides:  map[string]string{"google": "10"},
+			org:        "Google",
+			repo:       "webhook",
+			orgRunners: 10,
+			want:       scopeOrg,
+		},
+		{
+			name:        "repo_override_leaves_other_repos",
+			repoMax:     20,
+			overrides:   map[string]string{"google/monorepo": "50"},
+			org:         "google",
+			repo:        "webhook",
+			repoRunners: 20,
+			want:        scopeRepo,
+		},
+		{
+			name:    "negative_repo_max",
+			repoMax: -1,
+			wantErr: "maximum runners per repository must not be negative",
+		},
+		{
+			name:    "negative_org_max",
+			orgMax:  -1,
+			wantErr: "maximum runners per organization must not be negative",
+		},
+		{
+			name:      "invalid_scope",
+			overrides: map[string]string{"google/webhook/extra": "1"},
+			wantErr:   `runner limit scope "google/webhook/extra" must be an organization or org/repo`,
+		},
+		{
+			name:      "invalid_override",
+			overrides: map[string]string{"google": "many"},
+			wantErr:   `maximum runners for "google" is not a number`,
+		},
+		{
+			name:      "negative_override",
+			overrides: map[string]string{"google/webhook": "-1"},
+			wantErr:   `maximum runners for "google/webhook" must not be negative`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l, err := newScopeLimits(tc.repoMax, tc.orgMax, tc.overrides)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := l.check(tc.org, tc.repo, tc.repoRunners, tc.orgRunners), tc.want; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRunnerTrackerCountRepoOrg(t *testing.T) {
+	t.Parallel()
+
+	tracker := newRunnerTracker()
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-1", Org: "google", Repo: "webhook"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-2", Org: "Google", Repo: "Webhook"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-3", Org: "google", Repo: "other"})
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-4", Org: "abcxyz", Repo: "webhook"})
+
+	if got, want := tracker.CountRepo("google", "webhook"), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := tracker.CountOrg("google"), 3; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	tracker.Remove("GCP-1")
+	if got, want := tracker.CountRepo("google", "webhook"), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := tracker.CountOrg("google"), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Reserved runners count until they are dispatched or released.
+	allowUnder := func(limit int) func(repoRunners, orgRunners int) bool {
+		return func(repoRunners, orgRunners int) bool { return repoRunners < limit }
+	}
+	if !tracker.Reserve("GCP-5", "google", "webhook", allowUnder(2)) {
+		t.Fatal("expected runner under the limit to be reserved")
+	}
+	if tracker.Reserve("GCP-6", "google", "webhook", allowUnder(2)) {
+		t.Errorf("expected the reserved runner to take up the limit")
+	}
+	if got, want := tracker.CountRepo("google", "webhook"), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	tracker.Dispatched(&trackedRunner{RunnerName: "GCP-5", Org: "google", Repo: "webhook"})
+	tracker.Release("GCP-5")
+	if got, want := tracker.CountRepo("google", "webhook"), 2; got != want {
+		t.Errorf("expected the dispatched runner to stay counted, got %d, want %d", got, want)
+	}
+
+	tracker.Remove("GCP-5")
+	if !tracker.Reserve("GCP-6", "google", "webhook", allowUnder(2)) {
+		t.Fatal("expected runner under the limit to be reserved")
+	}
+	tracker.Release("GCP-6")
+	if got, want := tracker.CountOrg("google"), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+// newDeferredJob returns a queued job event for the google/webhook repository.
+func newDeferredJob(jobID int64, createdAt time.Time) *github.WorkflowJobEvent {
+	return &github.WorkflowJobEvent{
+		WorkflowJob: &github.WorkflowJob{
+			ID:        github.Ptr(jobID),
+			CreatedAt: &github.Timestamp{Time: createdAt},
+		},
+	}
+}
+
+func TestCheckScopeLimits(t *testing.T) {
+	t.Parallel()
+
+	limits, err := newScopeLimits(1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		deferredRunners: newDeferredRunners(1),
+		runners:         newRunnerTracker(),
+		scopeLimits:     limits,
+	}
+
+	req := &runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-1", Job: newDeferredJob(1, time.Now())}
+	release, resp := srv.checkScopeLimits(t.Context(), req, nil)
+	if resp != nil {
+		t.Fatalf("expected runner under the limit to be allowed, got %d %s", resp.Code, resp.Message)
+	}
+	release()
+
+	srv.runners.Dispatched(&trackedRunner{RunnerName: "GCP-0", Org: "google", Repo: "webhook"})
+
+	cases := []struct {
+		name        string
+		req         *runnerRequest
+		wantCode    int
+		wantMessage string
+	}{
+		{
+			name:        "deferred",
+			req:         req,
+			wantCode:    http.StatusAccepted,
+			wantMessage: runnerDeferredMsg,
+		},
+		{
+			name:        "queue_full",
+			req:         &runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2", Job: newDeferredJob(2, time.Now())},
+			wantCode:    http.StatusServiceUnavailable,
+			wantMessage: "deferred runner queue is full",
+		},
+		{
+			name:        "ahead_of_demand",
+			req:         &runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-3"},
+			wantCode:    http.StatusOK,
+			wantMessage: runnerScopeLimitMsg,
+		},
+	}
+
+	// The cases share the deferred runners, so they run in order.
+	for _, tc := range cases {
+		_, resp := srv.checkScopeLimits(t.Context(), tc.req, nil)
+		if resp == nil {
+			t.Fatalf("%s: expected runner over the limit to be rejected", tc.name)
+		}
+		if got, want := resp.Code, tc.wantCode; got != want {
+			t.Errorf("%s: expected %d to be %d", tc.name, got, want)
+		}
+		if got, want := resp.Message, tc.wantMessage; got != want {
+			t.Errorf("%s: expected %q to be %q", tc.name, got, want)
+		}
+	}
+
+	if !srv.deferredRunners.drop(1) {
+		t.Errorf("expected the deferred runner of job 1 to be dropped")
+	}
+	if srv.deferredRunners.drop(1) {
+		t.Errorf("expected no deferred runner left for job 1")
+	}
+}
+
+func TestDispatchDeferred(t *testing.T) {
+	t.Parallel()
+
+	var gotRunners []string
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {
+		gotRunners = append(gotRunners, name)
+	})
+
+	limits, err := newScopeLimits(1, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		appClient:       app,
+		cbc:             &MockCloudBuildClient{},
+		deferredRunners: newDeferredRunners(10),
+		ghAPIBaseURL:    ghURL,
+		queueTTL:        time.Hour,
+		runners:         newRunnerTracker(),
+		scopeLimits:     limits,
+	}
+	srv.runners.Dispatched(&trackedRunner{RunnerName: "GCP-0", Org: "google", Repo: "webhook"})
+
+	now := time.Now()
+	for i, createdAt := range []time.Time{now.Add(-2 * time.Hour), now, now} {
+		req := &runnerRequest{
+			InstallationID: 123,
+			Org:            "google",
+			Repo:           "webhook",
+			RunnerName:     []string{"GCP-stale", "GCP-1", "GCP-2"}[i],
+			Job:            newDeferredJob(int64(i), createdAt),
+		}
+		if _, resp := srv.checkScopeLimits(t.Context(), req, nil); resp == nil || resp.Code != http.StatusAccepted {
+			t.Fatalf("expected runner %s to be deferred, got %v", req.RunnerName, resp)
+		}
+	}
+
+	if got, want := srv.dispatchDeferred(t.Context(), now), 0; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := srv.deferredRunners.len(), 2; got != want {
+		t.Errorf("expected %d deferred runners to be %d", got, want)
+	}
+
+	srv.runners.Remove("GCP-0")
+	if got, want := srv.dispatchDeferred(t.Context(), now), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := srv.deferredRunners.len(), 1; got != want {
+		t.Errorf("expected %d deferred runners to be %d", got, want)
+	}
+	if diff := cmp.Diff([]string{"GCP-1"}, gotRunners); diff != "" {
+		t.Errorf("unexpected dispatched runners (-want, +got):\n%s", diff)
+	}
+}
+
+func TestProvisionRunner_ConcurrentScopeLimits(t *testing.T) {
+	t.Parallel()
+
+	limits, err := newScopeLimits(2, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, ghURL := newFakeRunnerGitHub(t, func(name string) {})
+	cbc := &slowCloudBuildClient{}
+	srv := &Server{
+		appClient:       app,
+		cbc:             cbc,
+		deferredRunners: newDeferredRunners(10),
+		ghAPIBaseURL:    ghURL,
+		runners:         newRunnerTracker(),
+		scopeLimits:     limits,
+	}
+
+	// A matrix of jobs queued at once must not start more runners than the
+	// repository is allowed.
+	codes := make([]int, 5)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := &runnerRequest{
+				InstallationID: 123,
+				Org:            "google",
+				Repo:           "webhook",
+				RunnerName:     fmt.Sprintf("GCP-%d", i),
+				Job:            newDeferredJob(int64(i), time.Now()),
+			}
+			codes[i] = http.StatusOK
+			if _, resp := srv.provisionRunner(t.Context(), req, nil); resp != nil {
+				codes[i] = resp.Code
+			}
+		}()
+	}
+	wg.Wait()
+
+	deferred := 0
+	for _, code := range codes {
+		if code == http.StatusAccepted {
+			deferred++
+		}
+	}
+	if got, want := srv.runners.CountRepo("google", "webhook"), 2; got != want {
+		t.Errorf("expected %d runners to be %d", got, want)
+	}
+	if got, want := deferred, 3; got != want {
+		t.Errorf("expected %d deferred runners to be %d", got, want)
+	}
+	if got, want := cbc.created, 2; got != want {
+		t.Errorf("expected %d builds to be %d", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/secretmanager/v1"
+)
+
+// SecretManager stores short-lived secrets in Secret Manager.
+type SecretManager struct {
+	svc *secretmanager.Service
+}
+
+// NewSecretManager creates a new instance of a Secret Manager client.
+func NewSecretManager(ctx context.Context, opts ...option.ClientOption) (*SecretManager, error) {
+	svc, err := secretmanager.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new secret manager client: %w", err)
+	}
+
+	return &SecretManager{
+		svc: svc,
+	}, nil
+}
+
+// CreateSecret creates a secret holding the payload that Secret Manager
+// deletes after the ttl, grants the accessors (IAM members) access to it, and
+// returns the name of its version.
+func (sm *SecretManager) CreateSecret(ctx context.Context, projectID, secretID string, payload []byte, ttl time.Duration, labels map[string]string, accessors []string) (string, error) {
+	secret, err := sm.svc.Projects.Secrets.Create("projects/"+projectID, &secretmanager.Secret{
+		Labels: labels,
+		Replication: &secretmanager.Replication{
+			Automatic: &secretmanager.Automatic{},
+		},
+		Ttl: fmt.Sprintf("%ds", int(ttl.Seconds())),
+	}).SecretId(secretID).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to create secret: %w", err)
+	}
+
+	if len(accessors) > 0 {
+		if _, err := sm.svc.Projects.Secrets.SetIamPolicy(secret.Name, &secretmanager.SetIamPolicyRequest{
+			Policy: &secretmanager.Policy{
+				Bindings: []*secretmanager.Binding{{
+					Role:    "roles/secretmanager.secretAccessor",
+					Members: accessors,
+				}},
+			},
+		}).Context(ctx).Do(); err != nil {
+			return "", fmt.Errorf("failed to grant secret access: %w", err)
+		}
+	}
+
+	version, err := sm.svc.Projects.Secrets.AddVersion(secret.Name, &secretmanager.AddSecretVersionRequest{
+		Payload: &secretmanager.SecretPayload{
+			Data: base64.StdEncoding.EncodeToString(payload),
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to add secret version: %w", err)
+	}
+	return version.Name, nil
+}
+
+// DeleteSecret deletes the secret and all of its versions. Secrets that do not
+// exist are ignored.
+func (sm *SecretManager) DeleteSecret(ctx context.Context, name string) error {
+	if _, err := sm.svc.Projects.Secrets.Delete(name).Context(ctx).Do(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MockSecretStore keeps secrets in memory. CreateErr fails every create.
+type MockSecretStore struct {
+	CreateErr error
+
+	mu        sync.Mutex
+	Secrets   map[string][]byte
+	Accessors map[string][]string
+	Deleted   []string
+}
+
+func (m *MockSecretStore) CreateSecret(ctx context.Context, projectID, secretID string, payload []byte, ttl time.Duration, labels map[string]string, accessors []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.CreateErr != nil {
+		return "", m.CreateErr
+	}
+	name := fmt.Sprintf("projects/%s/secrets/%s", projectID, secretID)
+	if m.Secrets == nil {
+		m.Secrets = make(map[string][]byte)
+	}
+	m.Secrets[name] = payload
+	if m.Accessors == nil {
+		m.Accessors = make(map[string][]string)
+	}
+	m.Accessors[name] = accessors
+	return name + "/versions/1", nil
+}
+
+func (m *MockSecretStore) DeleteSecret(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Secrets, name)
+	m.Deleted = append(m.Deleted, name)
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+)
+
+// Security modes of the runner container, chosen with RUNNER_SECURITY_MODE.
+const (
+	// runnerSecurityPrivileged runs the runner privileged and unconfined, with
+	// a root Docker daemon jobs can use.
+	runnerSecurityPrivileged = "privileged"
+
+	// runnerSecurityRootless runs the runner unprivileged with a rootless
+	// Docker daemon. The daemon still needs seccomp and AppArmor unconfined to
+	// create its user namespace.
+	runnerSecurityRootless = "rootless"
+
+	// runnerSecurityUnprivileged runs the runner without any elevated
+	// permission or security override. Jobs cannot use Docker.
+	runnerSecurityUnprivileged = "unprivileged"
+)
+
+// runnerSecurityArgs returns the docker run flags of the runner container in
+// the given security mode.
+func runnerSecurityArgs(mode string) (string, error) {
+	switch mode {
+	case runnerSecurityPrivileged:
+		// privileged and security-opts are needed to run Docker-in-Docker
+		// https://rootlesscontaine.rs/getting-started/common/apparmor/
+		return "--privileged --security-opt seccomp=unconfined --security-opt apparmor=unconfined", nil
+	case runnerSecurityRootless:
+		return "--security-opt seccomp=unconfined --security-opt apparmor=unconfined", nil
+	case runnerSecurityUnprivileged:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown runner security mode %q, must be one of %q, %q or %q",
+			mode, runnerSecurityPrivileged, runnerSecurityRootless, runnerSecurityUnprivileged)
+	}
+}
+
+// runnerSecurityContext returns the Kubernetes security context of the runner
+// container in the given security mode, the equivalent of runnerSecurityArgs.
+func runnerSecurityContext(mode string) map[string]any {
+	switch mode {
+	case runnerSecurityRootless:
+		return map[string]any{
+			"seccompProfile":  map[string]any{"type": "Unconfined"},
+			"appArmorProfile": map[string]any{"type": "Unconfined"},
+		}
+	case runnerSecurityUnprivileged:
+		return map[string]any{}
+	default:
+		return map[string]any{"privileged": true}
+	}
+}
+
+// SecurityMode returns the security mode the runner container runs in.
+// Builds without one, for example built by a custom backend, run privileged.
+func (r *RunnerSpec) SecurityMode() string {
+	if mode := r.Build.GetSubstitutions()["_SECURITY_MODE"]; mode != "" {
+		return mode
+	}
+	return runnerSecurityPrivileged
+}
+
+// SecurityArgs returns the docker run flags of the runner container in its
+// security mode.
+func (r *RunnerSpec) SecurityArgs() string {
+	args, _ := runnerSecurityArgs(r.SecurityMode())
+	return args
+}
@@ -0,0 +1,123 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerSecurityMode(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		mode           string
+		wantMode       string
+		wantArgs       string
+		wantContext    map[string]any
+		wantPrivileged bool
+		wantUnconfined bool
+	}{
+		{
+			name:           "default",
+			wantMode:       runnerSecurityPrivileged,
+			wantArgs:       "--privileged --security-opt seccomp=unconfined --security-opt apparmor=unconfined",
+			wantContext:    map[string]any{"privileged": true},
+			wantPrivileged: true,
+			wantUnconfined: true,
+		},
+		{
+			name:           "privileged",
+			mode:           runnerSecurityPrivileged,
+			wantMode:       runnerSecurityPrivileged,
+			wantArgs:       "--privileged --security-opt seccomp=unconfined --security-opt apparmor=unconfined",
+			wantContext:    map[string]any{"privileged": true},
+			wantPrivileged: true,
+			wantUnconfined: true,
+		},
+		{
+			name:     "rootless",
+			mode:     runnerSecurityRootless,
+			wantMode: runnerSecurityRootless,
+			wantArgs: "--security-opt seccomp=unconfined --security-opt apparmor=unconfined",
+			wantContext: map[string]any{
+				"seccompProfile":  map[string]any{"type": "Unconfined"},
+				"appArmorProfile": map[string]any{"type": "Unconfined"},
+			},
+			wantUnconfined: true,
+		},
+		{
+			name:        "unprivileged",
+			mode:        runnerSecurityUnprivileged,
+			wantMode:    runnerSecurityUnprivileged,
+			wantContext: map[string]any{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				runnerImageName:    "default-runner",
+				runnerImageTag:     "latest",
+				runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+				runnerSecurityMode: tc.mode,
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+			spec := &RunnerSpec{RunnerName: "GCP-2", Build: build}
+
+			if got, want := spec.SecurityMode(), tc.wantMode; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := spec.SecurityArgs(), tc.wantArgs; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := spec.Env()["RUNNER_SECURITY_MODE"], tc.wantMode; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if diff := cmp.Diff(tc.wantContext, runnerSecurityContext(spec.SecurityMode())); diff != "" {
+				t.Errorf("unexpected security context (-want, +got):\n%s", diff)
+			}
+
+			// VMs run the command of the run step with its substitutions
+			// resolved.
+			command := runnerVMMetadata(build, spec.RunnerName)[vmRunnerCommandKey]
+			if got, want := strings.Contains(command, "--privileged"), tc.wantPrivileged; got != want {
+				t.Errorf("expected %q to contain --privileged: %t", command, want)
+			}
+			if got, want := strings.Contains(command, "seccomp=unconfined"), tc.wantUnconfined; got != want {
+				t.Errorf("expected %q to contain seccomp=unconfined: %t", command, want)
+			}
+			if got, want := strings.Contains(command, "-e RUNNER_SECURITY_MODE="+tc.wantMode+" "), true; got != want {
+				t.Errorf("expected %q to pass RUNNER_SECURITY_MODE=%s", command, tc.wantMode)
+			}
+		})
+	}
+}
+
+func TestRunnerSecurityArgs(t *testing.T) {
+	t.Parallel()
+
+	_, err := runnerSecurityArgs("root")
+	if diff := testutil.DiffErrString(err, `unknown runner security mode "root"`); diff != "" {
+		t.Error(diff)
+	}
+}
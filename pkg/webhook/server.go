@@ -16,9 +16,15 @@
 package webhook
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
 	"github.com/abcxyz/pkg/githubauth"
@@ -26,28 +32,163 @@ import (
 	"github.com/abcxyz/pkg/logging"
 	"github.com/abcxyz/pkg/renderer"
 	"github.com/sethvargo/go-gcpkms/pkg/gcpkms"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/idtoken"
 	"google.golang.org/api/option"
 
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
 	"github.com/google/github_actions_on_gcp/pkg/version"
 	"github.com/googleapis/gax-go/v2"
 )
 
 // Server provides the server implementation.
 type Server struct {
-	appClient            *githubauth.App
-	cbc                  CloudBuildClient
-	environment          string
-	ghAPIBaseURL         string
-	h                    *renderer.Renderer
-	kmc                  KeyManagementClient
-	runnerLocation       string
-	runnerProjectID      string
-	runnerImageName      string
-	runnerImageTag       string
-	runnerRepositoryID   string
-	runnerServiceAccount string
-	runnerWorkerPoolID   string
-	webhookSecret        []byte
+	actionsCacheBucket          string
+	actionsCacheEndpoint        string
+	actionsCacheKey             []byte
+	actionsCacheServiceAccount  string
+	actionsCacheStore           ActionsCacheStore
+	actorLimits                 *actorLimits
+	adminToken                  []byte
+	appClient                   *githubauth.App
+	artifactsBucket             string
+	artifactsEndpoint           string
+	artifactsKey                []byte
+	artifactsServiceAccount     string
+	artifactsURLTTL             time.Duration
+	backend                     RunnerBackend
+	batchBackend                RunnerBackend
+	blobSigner                  BlobSigner
+	buildTemplate               *cloudbuildpb.Build
+	cancelUnused                bool
+	cbc                         CloudBuildClient
+	createBuildTimeout          time.Duration
+	deferredRunners             *deferredRunners
+	deliveries                  *deliveryArchive
+	deliveryBudget              time.Duration
+	dispatchAPIAudience         string
+	dispatchAPICallers          []string
+	dispatchEventType           string
+	dispatchMaxRunners          int
+	dispatchPolicy              *DispatchPolicy
+	dispatchQueue               *dispatchQueue
+	environment                 string
+	escalator                   *escalator
+	failureCheckInterval        time.Duration
+	fallbackBackend             RunnerBackend
+	fallbackGroup               *InstanceGroup
+	forecastBucket              string
+	forecastMaxRunners          int
+	forecastObject              string
+	forecastStore               ForecastStore
+	forecaster                  *demandForecaster
+	ghAPIBaseURL                string
+	githubCallTimeout           time.Duration
+	githubTransport             http.RoundTripper
+	h                           *renderer.Renderer
+	idTokens                    IDTokenValidator
+	idleGracePeriod             time.Duration
+	idleRunnerClaims            *idleRunnerClaims
+	imageAttestor               string
+	imageLookups                *imageLookupCache
+	imageRegistry               ImageRegistry
+	imageScanner                ImageScanner
+	imageScans                  *imageScanCache
+	imageVerifier               ImageVerifier
+	installationRepos           *installationRepoCache
+	jitConfigSecretTTL          time.Duration
+	jobCompletedHook            string
+	jobStartedHook              string
+	jobTimeoutMargin            time.Duration
+	kmc                         KeyManagementClient
+	launchQueue                 *launchQueue
+	logReader                   BuildLogReader
+	maintenance                 *maintenanceToggle
+	metrics                     *webhookMetrics
+	metricsRegistry             *metrics.Registry
+	multiBuilder                *multiBuilder
+	notifier                    Notifier
+	notifierBudgetWarned        atomic.Bool
+	offlineCleanupInterval      time.Duration
+	offlineRunners              *offlineRunners
+	offlineThreshold            time.Duration
+	orphans                     *orphanSuspects
+	poolWarmInterval            time.Duration
+	prewarmOnApproval           bool
+	preemptedJobs               *preemptedJobs
+	propagateJobTimeout         bool
+	provisioningHistory         *provisioningHistory
+	publisher                   EventPublisher
+	queueTTL                    time.Duration
+	reconcileInterval           time.Duration
+	recommendationInterval      time.Duration
+	repoMetadataCache           *repoMetadataCache
+	runnerARM64ImageName        string
+	runnerARM64WorkerPool       string
+	runnerBackend               string
+	runnerBuildDiskSizeGB       int64
+	runnerBuildMachineType      cloudbuildpb.BuildOptions_MachineType
+	runnerBuildTimeout          time.Duration
+	runnerBuildTrigger          string
+	runnerCacheBucket           string
+	runnerExtraEnv              map[string]string
+	runnerExtraSubstitutions    map[string]string
+	runnerGPUImageName          string
+	runnerGPUTypes              map[string]string
+	runnerGroupMappings         []*RunnerGroupMapping
+	runnerHTTPProxy             string
+	runnerHTTPSProxy            string
+	runnerImageMappings         []*RunnerImageMapping
+	runnerImageName             string
+	runnerImagePinDigest        bool
+	runnerImageTag              string
+	runnerImageVariants         []string
+	runnerInsecureRegistries    []string
+	runnerFallbackLocations     []string
+	runnerLocation              string
+	runnerLogsBucket            string
+	runnerMaxCount              int
+	runnerMaxLifetime           time.Duration
+	runnerMetadataEnv           map[string]string
+	runnerNoProxy               string
+	runnerProfiles              map[string]*RunnerProfile
+	runnerProjectID             string
+	runnerProjectRoutes         map[string]*RunnerProjectRoute
+	runnerProjects              *projectSpreader
+	runnerRegistryMirrors       []string
+	runnerRepositories          map[string]string
+	runnerRepositoryAssignments map[string]string
+	runnerRepositoryID          string
+	runnerReuseMaxDuration      time.Duration
+	runnerReuseMaxJobs          int
+	runners                     *runnerTracker
+	runnerSecrets               map[string]string
+	runnerSecurityMode          string
+	runnerServiceAccount        string
+	runnerBatchServiceAccount   string
+	runnerSpot                  bool
+	runnerToolcacheBucket       string
+	runnerToolcacheCompat       bool
+	runnerWorkerPoolID          string
+	runnerWorkerPools           map[string]string
+	scopeLimits                 *scopeLimits
+	secrets                     SecretStore
+	settings                    *sharedSettings
+	settingsPollInterval        time.Duration
+	shadowMode                  bool
+	skipIfIdle                  bool
+	spotCheckInterval           time.Duration
+	spotMaxRelaunches           int
+	stallCheckInterval          time.Duration
+	stallThreshold              time.Duration
+	stateStore                  StateStore
+	stateSync                   *stateSync
+	strictPayloads              bool
+	suspendedInstallations      *suspendedInstallations
+	vulnerabilityGate           string
+	vulnerabilityMaxCritical    int64
+	waitingJobs                 *waitingJobs
+	webhookSecret               []byte
 }
 
 // FileReader can read a file and return the content.
@@ -59,22 +200,63 @@ type FileReader interface {
 type KeyManagementClient interface {
 	Close() error
 	CreateSigner(ctx context.Context, kmsAppPrivateKeyID string) (*gcpkms.Signer, error)
+	Decrypt(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error)
 }
 
 // CloudBuildClient adheres to the interaction the webhook service has with a subset of Cloud Build APIs.
 type CloudBuildClient interface {
+	CancelBuild(ctx context.Context, req *cloudbuildpb.CancelBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
 	Close() error
-	CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) error
+	CreateBuild(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
+	GetBuild(ctx context.Context, req *cloudbuildpb.GetBuildRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
+	ListBuilds(ctx context.Context, req *cloudbuildpb.ListBuildsRequest, opts ...gax.CallOption) ([]*cloudbuildpb.Build, error)
+	GetWorkerPool(ctx context.Context, req *cloudbuildpb.GetWorkerPoolRequest, opts ...gax.CallOption) (*cloudbuildpb.WorkerPool, error)
+	RunBuildTrigger(ctx context.Context, req *cloudbuildpb.RunBuildTriggerRequest, opts ...gax.CallOption) (*cloudbuildpb.Build, error)
 }
 
 // WebhookClientOptions encapsulate client config options as well as dependency implementation overrides.
 type WebhookClientOptions struct {
-	CloudBuildClientOpts    []option.ClientOption
-	KeyManagementClientOpts []option.ClientOption
+	ArtifactAnalysisClientOpts    []option.ClientOption
+	ArtifactRegistryClientOpts    []option.ClientOption
+	BatchClientOpts               []option.ClientOption
+	BinaryAuthorizationClientOpts []option.ClientOption
+	CloudBuildClientOpts          []option.ClientOption
+	FirestoreClientOpts           []option.ClientOption
+	ComputeClientOpts             []option.ClientOption
+	GKEClientOpts                 []option.ClientOption
+	IAMCredentialsClientOpts      []option.ClientOption
+	IDTokenClientOpts             []option.ClientOption
+	KeyManagementClientOpts       []option.ClientOption
+	LoggingClientOpts             []option.ClientOption
+	PubSubClientOpts              []option.ClientOption
+	SecretManagerClientOpts       []option.ClientOption
+	StorageClientOpts             []option.ClientOption
 
 	OSFileReaderOverride        FileReader
+	ActionsCacheStoreOverride   ActionsCacheStore
+	AdminTokenOverride          []byte
+	AppSignerOverride           crypto.Signer
+	BatchClientOverride         BatchClient
+	BlobSignerOverride          BlobSigner
+	BuildLogReaderOverride      BuildLogReader
 	CloudBuildClientOverride    CloudBuildClient
+	EventPublisherOverride      EventPublisher
+	GitHubTransportOverride     http.RoundTripper
+	IDTokenValidatorOverride    IDTokenValidator
+	ImageRegistryOverride       ImageRegistry
+	ImageScannerOverride        ImageScanner
+	ImageVerifierOverride       ImageVerifier
+	InstanceGroupClientOverride InstanceGroupClient
+	JobClientOverride           JobClient
 	KeyManagementClientOverride KeyManagementClient
+	RunnerBackendOverride       RunnerBackend
+	RunnerProfilesOverride      map[string]*RunnerProfile
+	SecretStoreOverride         SecretStore
+	ForecastStoreOverride       ForecastStore
+	StateStoreOverride          StateStore
+	SettingsStoreOverride       SettingsStore
+	VMClientOverride            VMClient
+	WebhookSecretOverride       []byte
 }
 
 // NewServer creates a new HTTP server implementation that will handle
@@ -85,13 +267,144 @@ func NewServer(ctx context.Context, h *renderer.Renderer, cfg *Config, wco *Webh
 		fr = NewOSFileReader()
 	}
 
-	webhookSecret, err := fr.ReadFile(fmt.Sprintf("%s/%s", cfg.GitHubWebhookKeyMountPath, cfg.GitHubWebhookKeyName))
+	webhookSecret := wco.WebhookSecretOverride
+	if webhookSecret == nil {
+		b, err := fr.ReadFile(fmt.Sprintf("%s/%s", cfg.GitHubWebhookKeyMountPath, cfg.GitHubWebhookKeyName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+		}
+		webhookSecret = b
+	}
+
+	adminToken := wco.AdminTokenOverride
+	if adminToken == nil && cfg.AdminTokenPath != "" {
+		b, err := fr.ReadFile(cfg.AdminTokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin token: %w", err)
+		}
+		if adminToken = bytes.TrimSpace(b); len(adminToken) == 0 {
+			return nil, fmt.Errorf("admin token %s is empty", cfg.AdminTokenPath)
+		}
+	}
+
+	// Deliveries are only archived to be replayed through the admin API.
+	var deliveries *deliveryArchive
+	if len(adminToken) > 0 && cfg.DeliveryArchiveSize > 0 {
+		deliveries = newDeliveryArchive(cfg.DeliveryArchiveSize)
+	}
+
+	var err error
+	runnerProfiles := wco.RunnerProfilesOverride
+	if runnerProfiles == nil && cfg.RunnerProfilesPath != "" {
+		runnerProfiles, err = loadRunnerProfiles(fr, cfg.RunnerProfilesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runner profiles: %w", err)
+		}
+	}
+	for name, p := range runnerProfiles {
+		if len(p.Caches) > 0 && cfg.RunnerCacheBucket == "" {
+			return nil, fmt.Errorf("runner profile %q declares caches but RUNNER_CACHE_BUCKET is not set", name)
+		}
+	}
+
+	var runnerGroupMappings []*RunnerGroupMapping
+	if cfg.RunnerGroupMappingsPath != "" {
+		runnerGroupMappings, err = loadRunnerGroupMappings(fr, cfg.RunnerGroupMappingsPath, runnerProfiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runner group mappings: %w", err)
+		}
+	}
+
+	var runnerProjectRoutes map[string]*RunnerProjectRoute
+	if cfg.RunnerProjectRoutesPath != "" {
+		runnerProjectRoutes, err = loadRunnerProjectRoutes(fr, cfg.RunnerProjectRoutesPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runner project routes: %w", err)
+		}
+	}
+	for key, route := range runnerProjectRoutes {
+		// The default worker pool has a fixed location.
+		if route.Location != "" && cfg.RunnerWorkerPoolID != "" {
+			return nil, fmt.Errorf("runner project route %q sets a location, which RUNNER_WORKER_POOL_ID does not allow", key)
+		}
+	}
+
+	var runnerImageMappings []*RunnerImageMapping
+	if cfg.RunnerImageMappingsPath != "" {
+		runnerImageMappings, err = loadRunnerImageMappings(fr, cfg.RunnerImageMappingsPath, cfg.RunnerRepositories)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runner image mappings: %w", err)
+		}
+	}
+
+	jobStartedHook, err := loadJobHook(fr, cfg.RunnerJobStartedHook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job started hook: %w", err)
+	}
+	jobCompletedHook, err := loadJobHook(fr, cfg.RunnerJobCompletedHook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job completed hook: %w", err)
+	}
+
+	var dispatchPolicy *DispatchPolicy
+	if cfg.DispatchPolicyPath != "" {
+		dispatchPolicy, err = loadDispatchPolicy(fr, cfg.DispatchPolicyPath, runnerProfiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dispatch policy: %w", err)
+		}
+	}
+
+	scopeLimits, err := newScopeLimits(cfg.RunnerRepoMaxRunners, cfg.RunnerOrgMaxRunners, cfg.RunnerScopeLimits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read webhook secret: %w", err)
+		return nil, fmt.Errorf("failed to parse runner scope limits: %w", err)
+	}
+
+	var launches *launchQueue
+	if cfg.RunnerMaxConcurrentBuilds > 0 {
+		launches = newLaunchQueue(cfg.RunnerMaxConcurrentBuilds, cfg.RunnerLaunchQueueSize)
 	}
 
+	var deferred *deferredRunners
+	if scopeLimits != nil {
+		deferred = newDeferredRunners(cfg.RunnerDeferredQueueSize)
+	}
+
+	actorLimits, err := newActorLimits(cfg.RunnerBlockedActors, cfg.RunnerActorMaxRunners, cfg.RunnerActorLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse actor limits: %w", err)
+	}
+
+	runnerProjects, err := newProjectSpreader(cfg.RunnerProjectID, cfg.RunnerAdditionalProjectIDs, cfg.RunnerProjectStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure runner projects: %w", err)
+	}
+
+	runnerSecrets, err := runnerSecretVersions(cfg.RunnerProjectID, cfg.RunnerSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure runner secrets: %w", err)
+	}
+
+	if cfg.GoogleCloudProxyURL != "" || cfg.GoogleCloudCABundlePath != "" {
+		var caBundle []byte
+		if cfg.GoogleCloudCABundlePath != "" {
+			b, err := fr.ReadFile(cfg.GoogleCloudCABundlePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read google cloud ca bundle: %w", err)
+			}
+			caBundle = b
+		}
+		opts, err := googleCloudClientOpts(cfg.GoogleCloudProxyURL, caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure google cloud clients: %w", err)
+		}
+		wco = wco.withClientOpts(opts...)
+	}
+
+	// The KMS client is only needed to create the app signer and to decrypt
+	// the settings supplied as ciphertext.
 	kmc := wco.KeyManagementClientOverride
-	if kmc == nil {
+	signer := wco.AppSignerOverride
+	if kmc == nil && (signer == nil || cfg.hasEncryptedValues()) {
 		km, err := NewKeyManagement(ctx, wco.KeyManagementClientOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create kms client: %w", err)
@@ -99,13 +412,41 @@ func NewServer(ctx context.Context, h *renderer.Renderer, cfg *Config, wco *Webh
 		kmc = km
 	}
 
-	signer, err := kmc.CreateSigner(ctx, cfg.KMSAppPrivateKeyID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create app signer: %w", err)
+	if cfg.hasEncryptedValues() {
+		cfg, err = decryptConfig(ctx, kmc, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if signer == nil {
+		signer, err = appSigner(ctx, kmc, fr, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	githubTransport := wco.GitHubTransportOverride
+	if githubTransport == nil {
+		var caBundle []byte
+		if cfg.GitHubCABundlePath != "" {
+			caBundle, err = fr.ReadFile(cfg.GitHubCABundlePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read github ca bundle: %w", err)
+			}
+		}
+		githubTransport, err = newGitHubTransport(cfg.GitHubProxyURL, caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github transport: %w", err)
+		}
 	}
 
 	options := []githubauth.Option{
 		githubauth.WithBaseURL(cfg.GitHubAPIBaseURL),
+		githubauth.WithHTTPClient(&http.Client{
+			Timeout:   githubAppClientTimeout,
+			Transport: githubTransport,
+		}),
 	}
 
 	appClient, err := githubauth.NewApp(cfg.GitHubAppID, signer, options...)
@@ -122,22 +463,529 @@ func NewServer(ctx context.Context, h *renderer.Renderer, cfg *Config, wco *Webh
 		cbc = cb
 	}
 
-	return &Server{
-		appClient:            appClient,
-		cbc:                  cbc,
-		environment:          cfg.Environment,
-		ghAPIBaseURL:         cfg.GitHubAPIBaseURL,
-		h:                    h,
-		kmc:                  kmc,
-		runnerLocation:       cfg.RunnerLocation,
-		runnerImageName:      cfg.RunnerImageName,
-		runnerImageTag:       cfg.RunnerImageTag,
-		runnerProjectID:      cfg.RunnerProjectID,
-		runnerRepositoryID:   cfg.RunnerRepositoryID,
-		runnerServiceAccount: cfg.RunnerServiceAccount,
-		runnerWorkerPoolID:   cfg.RunnerWorkerPoolID,
-		webhookSecret:        webhookSecret,
-	}, nil
+	if cfg.RunnerRequirePrivateNetwork {
+		if err := verifyPrivateWorkerPools(ctx, cbc, cfg.RunnerWorkerPoolID, cfg.RunnerWorkerPools); err != nil {
+			return nil, fmt.Errorf("failed to verify private worker pools: %w", err)
+		}
+	}
+
+	// A shadow deployment does not publish, notify or page on behalf of the
+	// deployment it shadows.
+	publisher := wco.EventPublisherOverride
+	if publisher == nil && cfg.LifecycleEventsTopic != "" && !cfg.ShadowMode {
+		ps, err := NewPubSub(ctx, cfg.LifecycleEventsTopic, wco.PubSubClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pubsub client: %w", err)
+		}
+		publisher = ps
+	}
+
+	logReader := wco.BuildLogReaderOverride
+	if logReader == nil && (cfg.RunnerStallThreshold > 0 || cfg.RunnerFailureCheckInterval > 0) {
+		cl, err := NewCloudLogging(ctx, cfg.RunnerProjectID, wco.LoggingClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create logging client: %w", err)
+		}
+		logReader = cl
+	}
+
+	blobSigner := wco.BlobSignerOverride
+	if blobSigner == nil && (cfg.RunnerArtifactsBucket != "" || cfg.RunnerActionsCacheBucket != "") {
+		ic, err := NewIAMCredentials(ctx, wco.IAMCredentialsClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create iam credentials client: %w", err)
+		}
+		blobSigner = ic
+	}
+
+	idTokens := wco.IDTokenValidatorOverride
+	if idTokens == nil && cfg.GRPCPort != "" {
+		v, err := idtoken.NewValidator(ctx, wco.IDTokenClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create id token validator: %w", err)
+		}
+		idTokens = v
+	}
+
+	var imageRegistry ImageRegistry
+	var imageLookups *imageLookupCache
+	if cfg.RunnerImageCheck || cfg.RunnerImagePinDigest || cfg.RunnerImageAttestor != "" {
+		imageRegistry = wco.ImageRegistryOverride
+		if imageRegistry == nil {
+			ar, err := NewArtifactRegistry(ctx, wco.ArtifactRegistryClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create artifact registry client: %w", err)
+			}
+			imageRegistry = ar
+		}
+		imageLookups = newImageLookupCache(cfg.RunnerImageCheckTTL)
+	}
+
+	imageVerifier := wco.ImageVerifierOverride
+	if imageVerifier == nil && cfg.RunnerImageAttestor != "" {
+		ba, err := NewBinaryAuthorization(ctx, wco.BinaryAuthorizationClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create binary authorization client: %w", err)
+		}
+		imageVerifier = ba
+	}
+
+	var imageScanner ImageScanner
+	var imageScans *imageScanCache
+	if cfg.VulnerabilityGate != "" {
+		imageScanner = wco.ImageScannerOverride
+		if imageScanner == nil {
+			aa, err := NewArtifactAnalysis(ctx, wco.ArtifactAnalysisClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create artifact analysis client: %w", err)
+			}
+			imageScanner = aa
+		}
+		imageScans = newImageScanCache(cfg.VulnerabilityScanTTL)
+	}
+
+	var fallbackGroup *InstanceGroup
+	var instanceGroups InstanceGroupClient
+	if cfg.RunnerFallbackInstanceGroup != "" && !cfg.ShadowMode {
+		group, err := parseInstanceGroup(cfg.RunnerFallbackInstanceGroup)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallback instance group: %w", err)
+		}
+		fallbackGroup = group
+		instanceGroups = wco.InstanceGroupClientOverride
+		if instanceGroups == nil {
+			ce, err := NewComputeEngine(ctx, wco.ComputeClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create compute client: %w", err)
+			}
+			instanceGroups = ce
+		}
+	}
+
+	var secrets SecretStore
+	if cfg.RunnerJITConfigSecrets && !cfg.ShadowMode {
+		secrets = wco.SecretStoreOverride
+		if secrets == nil {
+			sm, err := NewSecretManager(ctx, wco.SecretManagerClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+			}
+			secrets = sm
+		}
+	}
+
+	store := wco.SettingsStoreOverride
+	if store == nil && (cfg.SharedSettingsObject != "" || strings.HasPrefix(cfg.RunnerBuildTemplate, "gs://")) {
+		cs, err := NewCloudStorage(ctx, wco.StorageClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
+		}
+		store = cs
+	}
+
+	actionsCacheStore := wco.ActionsCacheStoreOverride
+	if actionsCacheStore == nil && cfg.RunnerActionsCacheBucket != "" {
+		cs, err := NewCloudStorage(ctx, wco.StorageClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
+		}
+		actionsCacheStore = cs
+	}
+
+	var forecaster *demandForecaster
+	var forecastStore ForecastStore
+	var forecastBucket, forecastObject string
+	if cfg.RunnerForecastInterval > 0 {
+		forecaster = newDemandForecaster(cfg.RunnerForecastInterval, cfg.RunnerForecastAggressiveness)
+		if cfg.RunnerForecastStateObject != "" {
+			forecastBucket, forecastObject, err = parseGCSObject(cfg.RunnerForecastStateObject)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse forecast state object: %w", err)
+			}
+			forecastStore = wco.ForecastStoreOverride
+			if forecastStore == nil {
+				cs, err := NewCloudStorage(ctx, wco.StorageClientOpts...)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
+				}
+				forecastStore = cs
+			}
+		}
+	}
+
+	// A shadow deployment does not provision runners, recording state would
+	// clobber the state of the deployment it shadows.
+	stateStore := wco.StateStoreOverride
+	if stateStore == nil && cfg.StateStoreDatabase != "" && !cfg.ShadowMode {
+		fs, err := NewFirestoreStateStore(ctx, cfg.StateStoreDatabase, wco.FirestoreClientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create firestore client: %w", err)
+		}
+		stateStore = fs
+	}
+
+	var settings *sharedSettings
+	if cfg.SharedSettingsObject != "" {
+		settings, err = newSharedSettings(store, cfg.SharedSettingsObject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure shared settings: %w", err)
+		}
+	}
+
+	var runnerBuildMachineType cloudbuildpb.BuildOptions_MachineType
+	if cfg.RunnerBuildMachineType != "" {
+		runnerBuildMachineType, err = buildMachineType(cfg.RunnerBuildMachineType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse runner build machine type: %w", err)
+		}
+	}
+
+	var buildTemplate *cloudbuildpb.Build
+	if cfg.RunnerBuildTemplate != "" {
+		buildTemplate, err = loadBuildTemplate(ctx, fr, store, cfg.RunnerBuildTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load runner build template: %w", err)
+		}
+	}
+
+	// A shadow deployment does not create builds.
+	poolWarmInterval := cfg.RunnerPoolWarmInterval
+	if cfg.ShadowMode {
+		poolWarmInterval = 0
+	}
+
+	var history *provisioningHistory
+	if cfg.RecommendationInterval > 0 {
+		history = newProvisioningHistory(cfg.RecommendationWindow, cfg.RecommendationTargetLatency, time.Now())
+	}
+
+	metricsRegistry := metrics.NewRegistry()
+	webhookMetrics := newWebhookMetrics(metricsRegistry)
+
+	runnerBackend := cfg.RunnerBackend
+	var backend RunnerBackend
+	switch {
+	case cfg.ShadowMode:
+	case wco.RunnerBackendOverride != nil:
+		runnerBackend, backend = runnerBackendCustom, wco.RunnerBackendOverride
+	case cfg.RunnerBackend == runnerBackendCompute:
+		vms := wco.VMClientOverride
+		if vms == nil {
+			ce, err := NewComputeEngine(ctx, wco.ComputeClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create compute client: %w", err)
+			}
+			vms = ce
+		}
+		backend = &vmBackend{
+			vms:      vms,
+			template: cfg.RunnerInstanceTemplate,
+			zone:     cfg.RunnerInstanceZone,
+			timeout:  cfg.CreateBuildTimeout,
+
+			arm64Template:     cfg.RunnerARM64InstanceTemplate,
+			toolcacheSnapshot: cfg.RunnerToolcacheSnapshot,
+		}
+	case cfg.RunnerBackend == runnerBackendGKE:
+		jobs := wco.JobClientOverride
+		if jobs == nil {
+			gke, err := NewGKE(ctx, cfg.RunnerGKECluster, wco.GKEClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create gke client: %w", err)
+			}
+			jobs = gke
+		}
+		backend = &gkeBackend{
+			jobs:           jobs,
+			namespace:      cfg.RunnerGKENamespace,
+			serviceAccount: cfg.RunnerGKEServiceAccount,
+			timeout:        cfg.CreateBuildTimeout,
+		}
+	}
+
+	var batchRunners RunnerBackend
+	if cfg.RunnerBatchLocation != "" && !cfg.ShadowMode {
+		client := wco.BatchClientOverride
+		if client == nil {
+			b, err := NewBatch(ctx, wco.BatchClientOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create batch client: %w", err)
+			}
+			client = b
+		}
+		batchRunners = &batchBackend{
+			client:           client,
+			location:         cfg.RunnerBatchLocation,
+			machineType:      cfg.RunnerBatchMachineType,
+			acceleratorType:  cfg.RunnerBatchAcceleratorType,
+			acceleratorCount: cfg.RunnerBatchAcceleratorCount,
+			serviceAccount:   cfg.RunnerBatchServiceAccount,
+			maxRunDuration:   cfg.RunnerBatchMaxRunDuration,
+			timeout:          cfg.CreateBuildTimeout,
+
+			arm64MachineType: cfg.RunnerARM64MachineType,
+		}
+	}
+
+	var fallback RunnerBackend
+	if fallbackGroup != nil {
+		fallback = &fallbackBackend{
+			client:  instanceGroups,
+			group:   fallbackGroup,
+			metrics: webhookMetrics,
+			timeout: cfg.CreateBuildTimeout,
+		}
+	}
+
+	var dq *dispatchQueue
+	if cfg.RunnerDispatchRate > 0 {
+		dq = newDispatchQueue(cfg.RunnerDispatchRate, cfg.RunnerDispatchBurst, cfg.RunnerDispatchConcurrency, cfg.RunnerDispatchQueueSize)
+	}
+
+	var mb *multiBuilder
+	if cfg.RunnerMultiBuildWindow > 0 {
+		mb = newMultiBuilder(cfg.RunnerMultiBuildWindow, cfg.RunnerMultiBuildMaxRunners)
+	}
+
+	var notifier Notifier
+	if cfg.GoogleChatWebhookURL != "" && !cfg.ShadowMode {
+		notifier = NewGoogleChatNotifier(cfg.GoogleChatWebhookURL, cfg.GoogleChatRateInterval)
+	}
+
+	var esc *escalator
+	if cfg.PagerDutyRoutingKey != "" && !cfg.ShadowMode {
+		esc = newEscalator(NewPagerDuty(cfg.PagerDutyRoutingKey), &escalationConfig{
+			FailureRateThreshold: cfg.PagerDutyFailureRate,
+			MinDispatches:        cfg.PagerDutyMinDispatches,
+			QueueAgeThreshold:    cfg.PagerDutyQueueAge,
+			Window:               cfg.PagerDutyWindow,
+			SustainPeriod:        cfg.PagerDutySustainPeriod,
+		})
+	}
+
+	srv := &Server{
+		actionsCacheBucket:          cfg.RunnerActionsCacheBucket,
+		actionsCacheEndpoint:        strings.TrimSuffix(cfg.RunnerActionsCacheEndpoint, "/"),
+		actionsCacheKey:             actionsCacheTokenKey(webhookSecret),
+		actionsCacheServiceAccount:  cfg.RunnerActionsCacheSigner,
+		actionsCacheStore:           actionsCacheStore,
+		actorLimits:                 actorLimits,
+		adminToken:                  adminToken,
+		appClient:                   appClient,
+		artifactsBucket:             cfg.RunnerArtifactsBucket,
+		artifactsEndpoint:           strings.TrimSuffix(cfg.RunnerArtifactsEndpoint, "/"),
+		artifactsKey:                artifactTokenKey(webhookSecret),
+		artifactsServiceAccount:     cfg.RunnerArtifactsSigner,
+		artifactsURLTTL:             cfg.RunnerArtifactsURLTTL,
+		backend:                     backend,
+		batchBackend:                batchRunners,
+		blobSigner:                  blobSigner,
+		buildTemplate:               buildTemplate,
+		cancelUnused:                cfg.RunnerCancelUnusedBuilds,
+		cbc:                         cbc,
+		createBuildTimeout:          cfg.CreateBuildTimeout,
+		deferredRunners:             deferred,
+		deliveries:                  deliveries,
+		deliveryBudget:              cfg.DeliveryBudget,
+		dispatchAPIAudience:         cfg.DispatchAPIAudience,
+		dispatchAPICallers:          cfg.DispatchAPICallers,
+		dispatchEventType:           cfg.RepositoryDispatchEventType,
+		dispatchMaxRunners:          cfg.RepositoryDispatchMaxRunners,
+		dispatchPolicy:              dispatchPolicy,
+		dispatchQueue:               dq,
+		environment:                 cfg.Environment,
+		escalator:                   esc,
+		failureCheckInterval:        cfg.RunnerFailureCheckInterval,
+		fallbackBackend:             fallback,
+		fallbackGroup:               fallbackGroup,
+		forecastBucket:              forecastBucket,
+		forecastMaxRunners:          cfg.RunnerForecastMaxRunners,
+		forecastObject:              forecastObject,
+		forecastStore:               forecastStore,
+		forecaster:                  forecaster,
+		ghAPIBaseURL:                cfg.GitHubAPIBaseURL,
+		githubCallTimeout:           cfg.GitHubCallTimeout,
+		githubTransport:             githubTransport,
+		h:                           h,
+		idTokens:                    idTokens,
+		idleGracePeriod:             cfg.RunnerIdleGracePeriod,
+		idleRunnerClaims:            newIdleRunnerClaims(),
+		imageAttestor:               cfg.RunnerImageAttestor,
+		imageLookups:                imageLookups,
+		imageRegistry:               imageRegistry,
+		imageScanner:                imageScanner,
+		imageScans:                  imageScans,
+		imageVerifier:               imageVerifier,
+		installationRepos:           newInstallationRepoCache(),
+		jitConfigSecretTTL:          cfg.RunnerJITConfigSecretTTL,
+		jobCompletedHook:            jobCompletedHook,
+		jobStartedHook:              jobStartedHook,
+		jobTimeoutMargin:            cfg.RunnerJobTimeoutMargin,
+		kmc:                         kmc,
+		launchQueue:                 launches,
+		logReader:                   logReader,
+		maintenance:                 newMaintenanceToggle(),
+		metrics:                     webhookMetrics,
+		metricsRegistry:             metricsRegistry,
+		multiBuilder:                mb,
+		notifier:                    notifier,
+		offlineCleanupInterval:      cfg.RunnerOfflineCleanupInterval,
+		offlineRunners:              newOfflineRunners(),
+		offlineThreshold:            cfg.RunnerOfflineThreshold,
+		orphans:                     newOrphanSuspects(),
+		poolWarmInterval:            poolWarmInterval,
+		prewarmOnApproval:           cfg.RunnerPrewarmOnApproval,
+		preemptedJobs:               newPreemptedJobs(),
+		propagateJobTimeout:         cfg.RunnerPropagateJobTimeout,
+		provisioningHistory:         history,
+		publisher:                   publisher,
+		queueTTL:                    cfg.QueueTTL,
+		reconcileInterval:           cfg.RunnerReconcileInterval,
+		recommendationInterval:      cfg.RecommendationInterval,
+		repoMetadataCache:           newRepoMetadataCache(),
+		runnerFallbackLocations:     cfg.RunnerFallbackLocations,
+		runnerLocation:              cfg.RunnerLocation,
+		runnerLogsBucket:            cfg.RunnerLogsBucket,
+		runnerMaxCount:              cfg.RunnerMaxCount,
+		runnerMaxLifetime:           cfg.RunnerMaxLifetime,
+		runnerMetadataEnv:           cfg.RunnerMetadataEnv,
+		runnerNoProxy:               cfg.RunnerNoProxy,
+		runnerARM64ImageName:        cfg.RunnerARM64ImageName,
+		runnerARM64WorkerPool:       cfg.RunnerARM64WorkerPool,
+		runnerBackend:               runnerBackend,
+		runnerBuildDiskSizeGB:       int64(cfg.RunnerBuildDiskSizeGB),
+		runnerBuildMachineType:      runnerBuildMachineType,
+		runnerBuildTimeout:          cfg.RunnerBuildTimeout,
+		runnerBuildTrigger:          cfg.RunnerBuildTrigger,
+		runnerCacheBucket:           cfg.RunnerCacheBucket,
+		runnerExtraEnv:              cfg.RunnerExtraEnv,
+		runnerExtraSubstitutions:    cfg.RunnerExtraSubstitutions,
+		runnerGPUImageName:          cfg.RunnerGPUImageName,
+		runnerGPUTypes:              cfg.RunnerGPUTypes,
+		runnerGroupMappings:         runnerGroupMappings,
+		runnerHTTPProxy:             cfg.RunnerHTTPProxy,
+		runnerHTTPSProxy:            cfg.RunnerHTTPSProxy,
+		runnerImageMappings:         runnerImageMappings,
+		runnerImageName:             cfg.RunnerImageName,
+		runnerImagePinDigest:        cfg.RunnerImagePinDigest,
+		runnerImageTag:              cfg.RunnerImageTag,
+		runnerImageVariants:         cfg.RunnerImageVariants,
+		runnerInsecureRegistries:    cfg.RunnerInsecureRegistries,
+		runnerProfiles:              runnerProfiles,
+		runnerProjectID:             cfg.RunnerProjectID,
+		runnerProjectRoutes:         runnerProjectRoutes,
+		runnerProjects:              runnerProjects,
+		runnerRegistryMirrors:       cfg.RunnerRegistryMirrors,
+		runnerRepositories:          cfg.RunnerRepositories,
+		runnerRepositoryAssignments: cfg.RunnerRepositoryAssignments,
+		runnerRepositoryID:          cfg.RunnerRepositoryID,
+		runnerReuseMaxDuration:      cfg.RunnerReuseMaxDuration,
+		runnerReuseMaxJobs:          cfg.RunnerReuseMaxJobs,
+		runnerSecrets:               runnerSecrets,
+		runnerSecurityMode:          cfg.RunnerSecurityMode,
+		runnerServiceAccount:        cfg.RunnerServiceAccount,
+		runnerBatchServiceAccount:   cfg.RunnerBatchServiceAccount,
+		runnerSpot:                  cfg.RunnerSpot,
+		runnerToolcacheBucket:       cfg.RunnerToolcacheBucket,
+		runnerToolcacheCompat:       cfg.RunnerToolcacheCompat,
+		runnerWorkerPoolID:          cfg.RunnerWorkerPoolID,
+		runnerWorkerPools:           cfg.RunnerWorkerPools,
+		runners:                     newRunnerTracker(),
+		scopeLimits:                 scopeLimits,
+		secrets:                     secrets,
+		settings:                    settings,
+		settingsPollInterval:        cfg.SharedSettingsPollInterval,
+		shadowMode:                  cfg.ShadowMode,
+		skipIfIdle:                  cfg.RunnerSkipIfIdle,
+		spotCheckInterval:           cfg.RunnerSpotCheckInterval,
+		spotMaxRelaunches:           cfg.RunnerSpotMaxRelaunches,
+		stallCheckInterval:          cfg.RunnerStallCheckInterval,
+		stallThreshold:              cfg.RunnerStallThreshold,
+		stateStore:                  stateStore,
+		stateSync:                   newStateSync(),
+		strictPayloads:              cfg.StrictPayloadValidation,
+		suspendedInstallations:      newSuspendedInstallations(),
+		vulnerabilityGate:           cfg.VulnerabilityGate,
+		vulnerabilityMaxCritical:    int64(cfg.VulnerabilityMaxCritical),
+		waitingJobs:                 newWaitingJobs(),
+		webhookSecret:               webhookSecret,
+	}
+
+	if fs, ok := signer.(*fallbackSigner); ok {
+		fs.metrics = srv.metrics
+	}
+
+	if !cfg.SkipStartupChecks {
+		probeSigner := signer
+		if kmsSigner, ok := signer.(*gcpkms.Signer); ok {
+			probeSigner = kmsSigner.WithContext(ctx)
+		}
+		registryClient, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create registry client: %w", err)
+		}
+		if err := runStartupProbes(ctx, srv.startupProbes(probeSigner, registryClient)); err != nil {
+			return nil, fmt.Errorf("startup checks failed: %w", err)
+		}
+	}
+
+	return srv, nil
+}
+
+// StartBackground starts the periodic background tasks of the server. They
+// run until the context is cancelled.
+func (s *Server) StartBackground(ctx context.Context) {
+	if n := s.restoreRunners(ctx); n > 0 {
+		logging.FromContext(ctx).InfoContext(ctx, "restored runners from the state store", "runners", n)
+	}
+	if s.logReader != nil && s.stallThreshold > 0 {
+		go s.runStallMonitor(ctx)
+	}
+	if s.logReader != nil && s.failureCheckInterval > 0 {
+		go s.runFailureMonitor(ctx)
+	}
+	if s.poolWarmInterval > 0 {
+		go s.runPoolWarmer(ctx)
+	}
+	if s.dispatchQueue != nil {
+		s.runDispatchQueue(ctx)
+	}
+	if s.settings != nil {
+		go s.runSettingsPoller(ctx)
+	}
+	if s.provisioningHistory != nil {
+		go s.runRecommender(ctx)
+	}
+	if s.forecaster != nil {
+		go s.runForecaster(ctx)
+	}
+	if s.deferredRunners != nil {
+		go s.runDeferredDispatcher(ctx)
+	}
+	if s.launchQueue != nil {
+		go s.runLaunchQueue(ctx)
+	}
+	if s.reconcileInterval > 0 {
+		go s.runReconciler(ctx)
+	}
+	if s.offlineCleanupInterval > 0 {
+		go s.runOfflineRunnerCleanup(ctx)
+	}
+	if s.runnerMaxLifetime > 0 {
+		go s.runRunnerTTLMonitor(ctx)
+	}
+	if s.reusableRunners() {
+		go s.runReuseDrainer(ctx)
+	}
+	if s.idleGracePeriod > 0 {
+		go s.runWastedRunnerMonitor(ctx)
+	}
+	if s.spotCheckInterval > 0 {
+		go s.runPreemptionMonitor(ctx)
+	}
+	if s.escalator != nil {
+		go s.runEscalationMonitor(ctx)
+	}
 }
 
 // Routes creates a ServeMux of all of the routes that
@@ -147,10 +995,23 @@ func (s *Server) Routes(ctx context.Context) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("/healthz", healthcheck.HandleHTTPHealthCheck())
 	mux.Handle("/webhook", s.handleWebhook())
+	mux.Handle("/cloudevents", s.handleCloudEvent())
 	mux.Handle("/version", s.handleVersion())
+	if s.metricsRegistry != nil {
+		mux.Handle("/metrics", s.metricsRegistry)
+	}
+	if len(s.adminToken) > 0 {
+		mux.Handle("/admin/", s.adminRoutes())
+	}
+	if s.artifactsBucket != "" {
+		mux.Handle("POST /artifacts/upload-url", s.handleArtifactUploadURL())
+	}
+	if s.actionsCacheBucket != "" {
+		mux.Handle("/actions-cache/", s.actionsCacheRoutes())
+	}
 
 	// Middleware
-	root := logging.HTTPInterceptor(logger, s.runnerProjectID)(mux)
+	root := logging.HTTPInterceptor(logger, s.runnerProjectID)(s.recoverPanics(mux))
 
 	return root
 }
@@ -167,12 +1028,33 @@ func (s *Server) handleVersion() http.Handler {
 
 // Close handles the graceful shutdown of the webhook server.
 func (s *Server) Close() error {
-	if err := s.kmc.Close(); err != nil {
-		return fmt.Errorf("failed to shutdown kms client connection: %w", err)
+	if s.kmc != nil {
+		if err := s.kmc.Close(); err != nil {
+			return fmt.Errorf("failed to shutdown kms client connection: %w", err)
+		}
 	}
 
 	if err := s.cbc.Close(); err != nil {
 		return fmt.Errorf("failed to shutdown cloud build client connection: %w", err)
 	}
+
+	if s.publisher != nil {
+		if err := s.publisher.Close(); err != nil {
+			return fmt.Errorf("failed to shutdown pubsub client connection: %w", err)
+		}
+	}
+
+	if s.logReader != nil {
+		if err := s.logReader.Close(); err != nil {
+			return fmt.Errorf("failed to shutdown logging client connection: %w", err)
+		}
+	}
+
+	// Notifications still being sent are flushed.
+	if c, ok := s.notifier.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("failed to shutdown notifier: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,198 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+var (
+	maintenanceMsg      = "service in maintenance, retry later"
+	runnerFleetLimitMsg = "no action taken for runner over the instance's runner limit"
+)
+
+// SettingsStore adheres to the interaction the webhook service has with the
+// Cloud Storage object holding the shared settings.
+type SettingsStore interface {
+	ReadSettings(ctx context.Context, bucket, object string, generation int64) (*SettingsObject, error)
+}
+
+// SharedSettings are the runtime-tunable settings every instance applies, read
+// from a JSON object in Cloud Storage. Changing the object changes the
+// settings of all instances within the poll interval, without a redeploy.
+type SharedSettings struct {
+	// MaintenanceMode answers webhook deliveries with a 503 instead of
+	// processing them.
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// MaxRunners caps the runners each instance tracks at once, runners
+	// requested over the cap are not provisioned. 0 disables the cap.
+	MaxRunners int `json:"max_runners"`
+
+	// CanaryPercents overrides the canary percent of runner profiles, by
+	// profile name.
+	CanaryPercents map[string]int `json:"canary_percents"`
+}
+
+func (s *SharedSettings) validate() error {
+	if s.MaxRunners < 0 {
+		return fmt.Errorf("max_runners must not be negative, got %d", s.MaxRunners)
+	}
+	for name, percent := range s.CanaryPercents {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("canary percent of profile %q must be between 0 and 100, got %d", name, percent)
+		}
+	}
+	return nil
+}
+
+// sharedSettings holds the last valid generation of the shared settings
+// object. The zero settings apply until it is first read.
+type sharedSettings struct {
+	store  SettingsStore
+	bucket string
+	object string
+
+	mu         sync.RWMutex
+	current    *SharedSettings
+	generation int64
+}
+
+// newSharedSettings returns the shared settings read from the object in the
+// form gs://<bucket>/<object>.
+func newSharedSettings(store SettingsStore, location string) (*sharedSettings, error) {
+	bucket, object, err := parseGCSObject(location)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedSettings{
+		store:   store,
+		bucket:  bucket,
+		object:  object,
+		current: &SharedSettings{},
+	}, nil
+}
+
+// parseGCSObject splits a location in the form gs://<bucket>/<object>.
+func parseGCSObject(location string) (string, string, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "gs" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return "", "", fmt.Errorf("%q must be in the form gs://<bucket>/<object>", location)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Get returns the current settings. It is safe to call on a nil receiver,
+// which returns the zero settings.
+func (s *sharedSettings) Get() *SharedSettings {
+	if s == nil {
+		return &SharedSettings{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.current
+}
+
+// refresh reads the object if its generation changed. An invalid generation
+// is rejected and the previous settings are kept. It returns the generation
+// applied, or 0 if the settings did not change.
+func (s *sharedSettings) refresh(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	generation := s.generation
+	s.mu.RUnlock()
+
+	obj, err := s.store.ReadSettings(ctx, s.bucket, s.object, generation)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read shared settings: %w", err)
+	}
+	if obj == nil {
+		return 0, nil
+	}
+
+	settings := &SharedSettings{}
+	if err := json.Unmarshal(obj.Data, settings); err != nil {
+		return 0, fmt.Errorf("failed to parse shared settings generation %d: %w", obj.Generation, err)
+	}
+	if err := settings.validate(); err != nil {
+		return 0, fmt.Errorf("invalid shared settings generation %d: %w", obj.Generation, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current = settings
+	s.generation = obj.Generation
+	return obj.Generation, nil
+}
+
+// runSettingsPoller reads the shared settings right away and then every
+// settings poll interval, until the context is cancelled.
+func (s *Server) runSettingsPoller(ctx context.Context) {
+	ticker := time.NewTicker(s.settingsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshSettings(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) refreshSettings(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	generation, err := s.settings.refresh(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to refresh shared settings, keeping the current settings", "error", err)
+		return
+	}
+	if generation != 0 {
+		current := s.settings.Get()
+		logger.InfoContext(ctx, "applied shared settings",
+			"generation", generation,
+			"maintenance_mode", current.MaintenanceMode,
+			"max_runners", current.MaxRunners,
+			"canary_percents", current.CanaryPercents)
+	}
+}
+
+// canaryProfile returns the profile with the canary percent of the shared
+// settings, if they override it.
+func (s *Server) canaryProfile(name string, profile *RunnerProfile) *RunnerProfile {
+	percent, ok := s.settings.Get().CanaryPercents[name]
+	if !ok || profile == nil || profile.Canary == nil {
+		return profile
+	}
+
+	p := *profile
+	canary := *profile.Canary
+	canary.Percent = percent
+	p.Canary = &canary
+	return &p
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSharedSettingsRefresh(t *testing.T) {
+	t.Parallel()
+
+	store := &MockSettingsStore{}
+	settings, err := newSharedSettings(store, "gs://my-bucket/webhook/settings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name           string
+		object         *SettingsObject
+		err            error
+		wantGeneration int64
+		wantErr        string
+		want           *SharedSettings
+	}{
+		{
+			name: "no_object",
+			want: &SharedSettings{},
+		},
+		{
+			name:           "first_generation",
+			object:         &SettingsObject{Data: []byte(`{"maintenance_mode":true,"max_runners":20}`), Generation: 1},
+			wantGeneration: 1,
+			want:           &SharedSettings{MaintenanceMode: true, MaxRunners: 20},
+		},
+		{
+			name:   "unchanged",
+			object: &SettingsObject{Data: []byte(`{"maintenance_mode":true,"max_runners":20}`), Generation: 1},
+			want:   &SharedSettings{MaintenanceMode: true, MaxRunners: 20},
+		},
+		{
+			name:    "malformed",
+			object:  &SettingsObject{Data: []byte(`{"maintenance_mode":`), Generation: 2},
+			wantErr: "failed to parse shared settings generation 2",
+			want:    &SharedSettings{MaintenanceMode: true, MaxRunners: 20},
+		},
+		{
+			name:    "invalid",
+			object:  &SettingsObject{Data: []byte(`{"canary_percents":{"default":150}}`), Generation: 3},
+			wantErr: `canary percent of profile "default" must be between 0 and 100, got 150`,
+			want:    &SharedSettings{MaintenanceMode: true, MaxRunners: 20},
+		},
+		{
+			name:    "read_error",
+			err:     fmt.Errorf("permission denied"),
+			wantErr: "failed to read shared settings: permission denied",
+			want:    &SharedSettings{MaintenanceMode: true, MaxRunners: 20},
+		},
+		{
+			name:           "next_generation",
+			object:         &SettingsObject{Data: []byte(`{"canary_percents":{"default":10}}`), Generation: 4},
+			wantGeneration: 4,
+			want:           &SharedSettings{CanaryPercents: map[string]int{"default": 10}},
+		},
+	}
+
+	// The steps run in order against the same settings.
+	for _, step := range steps {
+		store.Object, store.Err = step.object, step.err
+
+		generation, err := settings.refresh(t.Context())
+		if diff := testutil.DiffErrString(err, step.wantErr); diff != "" {
+			t.Fatalf("%s: %s", step.name, diff)
+		}
+		if got, want := generation, step.wantGeneration; got != want {
+			t.Errorf("%s: expected %d to be %d", step.name, got, want)
+		}
+		if diff := cmp.Diff(step.want, settings.Get()); diff != "" {
+			t.Errorf("%s: unexpected settings (-want, +got):\n%s", step.name, diff)
+		}
+	}
+}
+
+func TestParseGCSObject(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		location   string
+		wantBucket string
+		wantObject string
+		wantErr    string
+	}{
+		{
+			name:       "object",
+			location:   "gs://my-bucket/webhook/settings.json",
+			wantBucket: "my-bucket",
+			wantObject: "webhook/settings.json",
+		},
+		{
+			name:     "no_object",
+			location: "gs://my-bucket/",
+			wantErr:  "must be in the form gs://<bucket>/<object>",
+		},
+		{
+			name:     "not_gcs",
+			location: "https://storage.googleapis.com/my-bucket/settings.json",
+			wantErr:  "must be in the form gs://<bucket>/<object>",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bucket, object, err := parseGCSObject(tc.location)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := bucket, tc.wantBucket; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := object, tc.wantObject; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCanaryProfile(t *testing.T) {
+	t.Parallel()
+
+	profile := &RunnerProfile{Canary: &ImageCanary{Tag: "canary", Percent: 5}}
+	s := &Server{settings: &sharedSettings{current: &SharedSettings{CanaryPercents: map[string]int{"default": 50}}}}
+
+	if got, want := s.canaryProfile("default", profile).Canary.Percent, 50; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := s.canaryProfile("other", profile).Canary.Percent, 5; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	// The profile itself is not modified.
+	if got, want := profile.Canary.Percent, 5; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{settings: &sharedSettings{current: &SharedSettings{MaintenanceMode: true}}}
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/webhook", nil)
+	resp := s.processRequest(req)
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := resp.Message, maintenanceMsg; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+)
+
+// shadowJITConfig stands in for the JIT configuration of runners that are not
+// registered in shadow mode.
+const shadowJITConfig = "shadow"
+
+// shadowDispatch logs and counts the build a shadow deployment would have
+// created, and returns it in place of the created build.
+func (s *Server) shadowDispatch(ctx context.Context, req *cloudbuildpb.CreateBuildRequest, profileName string, logFields []any) *cloudbuildpb.Build {
+	build := req.GetBuild()
+	sub := build.GetSubstitutions()
+
+	steps := make([]string, 0, len(build.GetSteps()))
+	for _, step := range build.GetSteps() {
+		steps = append(steps, step.GetId())
+	}
+
+	logging.FromContext(ctx).InfoContext(ctx, "shadow mode: would create runner build",
+		append(logFields,
+			"parent", req.GetParent(),
+			"image", fmt.Sprintf("%s/%s:%s", sub["_REPOSITORY_ID"], sub["_IMAGE_NAME"], sub["_IMAGE_TAG"]),
+			"profile", profileName,
+			"worker_pool", build.GetOptions().GetPool().GetName(),
+			"timeout", build.GetTimeout().AsDuration().String(),
+			"steps", steps)...)
+	s.metrics.recordShadowDispatch(profileName)
+	return build
+}
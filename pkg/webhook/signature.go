@@ -0,0 +1,98 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	signature256Header    = "X-Hub-Signature-256"
+	legacySignatureHeader = "X-Hub-Signature"
+	signature256Prefix    = "sha256="
+)
+
+var (
+	// errMissingSignature is returned when a delivery is not signed.
+	errMissingSignature = errors.New("missing " + signature256Header + " header")
+
+	// errLegacySignature is returned when a delivery is only signed with the
+	// legacy SHA-1 signature.
+	errLegacySignature = errors.New(signature256Header + " header is required, SHA-1 " + legacySignatureHeader + " signatures are not accepted")
+
+	// errInvalidSignature is returned when the signature does not match the
+	// payload.
+	errInvalidSignature = errors.New("payload signature does not match")
+)
+
+// validatePayload verifies the HMAC-SHA256 signature of the delivery and
+// returns its JSON payload. Unlike github.ValidatePayload, it never falls back
+// to the SHA-1 signature.
+func validatePayload(r *http.Request, secret []byte) ([]byte, error) {
+	signature := r.Header.Get(signature256Header)
+	if signature == "" {
+		if r.Header.Get(legacySignatureHeader) != "" {
+			return nil, errLegacySignature
+		}
+		return nil, errMissingSignature
+	}
+
+	hexMAC, ok := strings.CutPrefix(signature, signature256Prefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected signature format", errInvalidSignature)
+	}
+	gotMAC, err := hex.DecodeString(hexMAC)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature is not hex encoded", errInvalidSignature)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(gotMAC, mac.Sum(nil)) {
+		return nil, errInvalidSignature
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content type: %w", err)
+	}
+
+	switch mediaType {
+	case "application/json":
+		return body, nil
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse form payload: %w", err)
+		}
+		return []byte(form.Get("payload")), nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // used to produce legacy signatures under test
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestValidatePayload(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("test-secret")
+	payload := []byte(`{"action":"queued"}`)
+	form := []byte("payload=" + url.QueryEscape(string(payload)))
+
+	legacyMAC := hmac.New(sha1.New, secret)
+	legacyMAC.Write(payload)
+	legacySignature := "sha1=" + hex.EncodeToString(legacyMAC.Sum(nil))
+
+	cases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		headers     map[string]string
+		wantErr     error
+	}{
+		{
+			name:        "json",
+			body:        payload,
+			contentType: "application/json",
+			headers:     map[string]string{signature256Header: "sha256=" + createSignature(secret, payload)},
+		},
+		{
+			name:        "form",
+			body:        form,
+			contentType: "application/x-www-form-urlencoded",
+			headers:     map[string]string{signature256Header: "sha256=" + createSignature(secret, form)},
+		},
+		{
+			name:        "missing",
+			body:        payload,
+			contentType: "application/json",
+			wantErr:     errMissingSignature,
+		},
+		{
+			name:        "legacy_only",
+			body:        payload,
+			contentType: "application/json",
+			headers:     map[string]string{legacySignatureHeader: legacySignature},
+			wantErr:     errLegacySignature,
+		},
+		{
+			name:        "legacy_in_sha256_header",
+			body:        payload,
+			contentType: "application/json",
+			headers:     map[string]string{signature256Header: legacySignature},
+			wantErr:     errInvalidSignature,
+		},
+		{
+			name:        "wrong_secret",
+			body:        payload,
+			contentType: "application/json",
+			headers:     map[string]string{signature256Header: "sha256=" + createSignature([]byte("other"), payload)},
+			wantErr:     errInvalidSignature,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			got, err := validatePayload(req, secret)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && !bytes.Equal(got, payload) {
+				t.Errorf("expected payload %q, got %q", payload, got)
+			}
+		})
+	}
+}
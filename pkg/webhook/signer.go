@@ -0,0 +1,172 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/sethvargo/go-gcpkms/pkg/gcpkms"
+)
+
+const (
+	// signerCooldown is how long the fallback signer is used after the
+	// primary signer failed, before the primary is tried again.
+	signerCooldown = time.Minute
+
+	// primarySignTimeout bounds a signature by the primary KMS key, so an
+	// unreachable region fails over instead of stalling every GitHub call.
+	primarySignTimeout = 5 * time.Second
+)
+
+// fallbackSigner signs with the primary signer and falls back to the
+// secondary signer when the primary fails, for example because the KMS key is
+// disabled or its region is down. Both keys must be private keys of the
+// GitHub App.
+type fallbackSigner struct {
+	primary  crypto.Signer
+	fallback crypto.Signer
+	logger   *slog.Logger
+	metrics  *webhookMetrics
+
+	mu              sync.Mutex
+	primaryFailedAt time.Time
+}
+
+// Public returns the public key of the primary signer. GitHub verifies the
+// signature with any key of the app, so it is only informative.
+func (s *fallbackSigner) Public() crypto.PublicKey {
+	return s.primary.Public()
+}
+
+// Sign signs the digest with the primary signer, or with the fallback signer
+// if the primary failed within the last signerCooldown.
+func (s *fallbackSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var primaryErr error
+	if !s.primaryCoolingDown(time.Now()) {
+		sig, err := signWithTimeout(s.primary, rand, digest, opts)
+		if err == nil {
+			return sig, nil
+		}
+		primaryErr = err
+
+		s.mu.Lock()
+		s.primaryFailedAt = time.Now()
+		s.mu.Unlock()
+		s.logger.Warn("primary app signer failed, using the fallback signer",
+			"error", err,
+			"cooldown", signerCooldown.String())
+	}
+
+	sig, err := s.fallback.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, errors.Join(primaryErr, fmt.Errorf("fallback app signer failed: %w", err))
+	}
+	s.metrics.recordSignerFallback()
+	return sig, nil
+}
+
+func (s *fallbackSigner) primaryCoolingDown(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.primaryFailedAt.IsZero() && now.Sub(s.primaryFailedAt) < signerCooldown
+}
+
+// signWithTimeout signs with the signer, bounding KMS signers by
+// primarySignTimeout.
+func signWithTimeout(signer crypto.Signer, rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if kmsSigner, ok := signer.(*gcpkms.Signer); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), primarySignTimeout)
+		defer cancel()
+		signer = kmsSigner.WithContext(ctx)
+	}
+	return signer.Sign(rand, digest, opts)
+}
+
+// appSigner returns the signer of the GitHub App JWTs: the KMS key, with the
+// fallback KMS key or PEM file as a fallback when configured. When only one of
+// the keys can be loaded at startup, it is used alone.
+func appSigner(ctx context.Context, kmc KeyManagementClient, fr FileReader, cfg *Config) (crypto.Signer, error) {
+	logger := logging.FromContext(ctx)
+
+	primary, primaryErr := kmc.CreateSigner(ctx, cfg.KMSAppPrivateKeyID)
+
+	var fallback crypto.Signer
+	var fallbackErr error
+	switch {
+	case cfg.KMSAppFallbackPrivateKeyID != "":
+		fallback, fallbackErr = kmc.CreateSigner(ctx, cfg.KMSAppFallbackPrivateKeyID)
+	case cfg.GitHubAppFallbackKeyPath != "":
+		fallback, fallbackErr = readPrivateKey(fr, cfg.GitHubAppFallbackKeyPath)
+	default:
+		if primaryErr != nil {
+			return nil, fmt.Errorf("failed to create app signer: %w", primaryErr)
+		}
+		return primary, nil
+	}
+
+	switch {
+	case primaryErr != nil && fallbackErr != nil:
+		return nil, fmt.Errorf("failed to create app signer: %w", errors.Join(primaryErr, fallbackErr))
+	case primaryErr != nil:
+		logger.WarnContext(ctx, "failed to create primary app signer, using the fallback signer only", "error", primaryErr)
+		return fallback, nil
+	case fallbackErr != nil:
+		logger.WarnContext(ctx, "failed to create fallback app signer, using the primary signer only", "error", fallbackErr)
+		return primary, nil
+	}
+
+	return &fallbackSigner{
+		primary:  primary,
+		fallback: fallback,
+		logger:   logger,
+	}, nil
+}
+
+// readPrivateKey reads a PEM encoded RSA private key, as downloaded from the
+// GitHub App settings.
+func readPrivateKey(fr FileReader, path string) (crypto.Signer, error) {
+	b, err := fr.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("private key %s is not PEM encoded", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key %s cannot sign", path)
+	}
+	return signer, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+)
+
+// failingSigner is a signer whose key is unavailable.
+type failingSigner struct {
+	crypto.Signer
+	calls int
+}
+
+func (s *failingSigner) Sign(_ io.Reader, _ []byte, _ crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return nil, fmt.Errorf("key version is disabled")
+}
+
+func TestFallbackSigner(t *testing.T) {
+	t.Parallel()
+
+	primaryKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallbackKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("jwt"))
+
+	verifiedBy := func(sig []byte) string {
+		if rsa.VerifyPKCS1v15(&primaryKey.PublicKey, crypto.SHA256, digest[:], sig) == nil {
+			return "primary"
+		}
+		if rsa.VerifyPKCS1v15(&fallbackKey.PublicKey, crypto.SHA256, digest[:], sig) == nil {
+			return "fallback"
+		}
+		return "none"
+	}
+
+	t.Run("primary_available", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fallbackSigner{primary: primaryKey, fallback: fallbackKey, logger: slog.New(slog.DiscardHandler)}
+		sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := verifiedBy(sig), "primary"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	})
+
+	t.Run("primary_failing", func(t *testing.T) {
+		t.Parallel()
+
+		primary := &failingSigner{Signer: primaryKey}
+		s := &fallbackSigner{primary: primary, fallback: fallbackKey, logger: slog.New(slog.DiscardHandler)}
+		for range 2 {
+			sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := verifiedBy(sig), "fallback"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		}
+		// The primary is not retried during the cooldown.
+		if got, want := primary.calls, 1; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+
+		// The primary is retried after the cooldown.
+		s.primaryFailedAt = time.Now().Add(-signerCooldown)
+		if _, err := s.Sign(rand.Reader, digest[:], crypto.SHA256); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := primary.calls, 2; got != want {
+			t.Errorf("expected %d to be %d", got, want)
+		}
+	})
+
+	t.Run("both_failing", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fallbackSigner{
+			primary:  &failingSigner{Signer: primaryKey},
+			fallback: &failingSigner{Signer: fallbackKey},
+			logger:   slog.New(slog.DiscardHandler),
+		}
+		_, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if diff := testutil.DiffErrString(err, "fallback app signer failed: key version is disabled"); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestReadPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		content []byte
+		wantErr string
+	}{
+		{
+			name:    "pkcs1",
+			content: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name:    "pkcs8",
+			content: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name:    "not_pem",
+			content: []byte("not-a-key"),
+			wantErr: "private key /keys/app.pem is not PEM encoded",
+		},
+		{
+			name:    "invalid_key",
+			content: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+			wantErr: "failed to parse private key /keys/app.pem",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: tc.content}}
+			signer, err := readPrivateKey(fr, "/keys/app.pem")
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			if !key.PublicKey.Equal(signer.Public()) {
+				t.Errorf("expected the public key of the PEM key")
+			}
+		})
+	}
+}
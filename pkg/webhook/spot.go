@@ -0,0 +1,283 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// spotLabel is the runner label of jobs run on spot capacity when the backend
+// has it.
+const spotLabel = "gcp-spot"
+
+// preemptedRerunTTL is how long the rerun of a job that failed on a preempted
+// runner is retried. GitHub only reruns the jobs of a finished workflow run.
+const preemptedRerunTTL = 6 * time.Hour
+
+// spotCapacity returns whether the runner requests spot capacity. A runner
+// relaunched more than the maximum number of times after preemptions falls
+// back to standard capacity, so its job is not starved.
+func (s *Server) spotCapacity(req *runnerRequest) bool {
+	if req.Relaunches > 0 && req.Relaunches >= s.spotMaxRelaunches {
+		return false
+	}
+	return s.runnerSpot || slices.Contains(req.Labels, spotLabel)
+}
+
+// relaunchedRunnerName returns the name of the runner relaunched for the n-th
+// time in place of the given runner.
+func relaunchedRunnerName(runnerName string, n int) string {
+	return fmt.Sprintf("%s-r%d", strings.TrimSuffix(runnerName, fmt.Sprintf("-r%d", n-1)), n)
+}
+
+// preemptedJob is a job that failed because its spot runner was preempted,
+// waiting for GitHub to accept its rerun.
+type preemptedJob struct {
+	InstallationID int64
+	Org            string
+	Repo           string
+	JobID          int64
+	Since          time.Time
+}
+
+// preemptedJobs are the jobs waiting to be rerun, keyed by job ID. A nil
+// preemptedJobs is valid and keeps nothing.
+type preemptedJobs struct {
+	mu   sync.Mutex
+	jobs map[int64]*preemptedJob
+}
+
+func newPreemptedJobs() *preemptedJobs {
+	return &preemptedJobs{
+		jobs: make(map[int64]*preemptedJob),
+	}
+}
+
+// Add keeps the job until it is rerun.
+func (p *preemptedJobs) Add(job *preemptedJob) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs[job.JobID] = job
+}
+
+// Remove forgets the job.
+func (p *preemptedJobs) Remove(jobID int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.jobs, jobID)
+}
+
+// List returns the jobs waiting to be rerun.
+func (p *preemptedJobs) List() []*preemptedJob {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	jobs := make([]*preemptedJob, 0, len(p.jobs))
+	for _, job := range p.jobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// runPreemptionMonitor periodically relaunches the spot runners preempted
+// before they picked up their job, and retries the reruns of the jobs of
+// preempted runners, until the context is cancelled.
+func (s *Server) runPreemptionMonitor(ctx context.Context) {
+	ticker := time.NewTicker(s.spotCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkPreemptedRunners(ctx)
+			s.retryPreemptedJobs(ctx, time.Now())
+		}
+	}
+}
+
+// runnerPreempted reports whether the spot runner was preempted. Runners on
+// backends without spot capacity never are.
+func (s *Server) runnerPreempted(ctx context.Context, r trackedRunner) (bool, error) {
+	if !r.Spot || r.BackendID == "" {
+		return false, nil
+	}
+	checker, ok := s.backendNamed(r.Backend).(PreemptionChecker)
+	if !ok {
+		return false, nil
+	}
+	return checker.Preempted(ctx, r.BackendID)
+}
+
+// checkPreemptedRunners relaunches the spot runners that were preempted before
+// they picked up their job, which would otherwise stay queued. Runners that
+// picked up their job are handled when the job completes.
+func (s *Server) checkPreemptedRunners(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	s.syncRunners(ctx)
+
+	for _, r := range s.runners.List() {
+		if state := r.Lifecycle.State(); state != lifecycle.StateProvisioning && state != lifecycle.StateOnline {
+			continue
+		}
+		preempted, err := s.runnerPreempted(ctx, r)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to check runner preemption",
+				"runner_id", r.RunnerName, "backend_id", r.BackendID, "error", err)
+			continue
+		}
+		if preempted {
+			s.relaunchRunner(ctx, r)
+		}
+	}
+}
+
+// relaunchRunner replaces a runner preempted before it picked up its job with
+// a new runner for the same job.
+func (s *Server) relaunchRunner(ctx context.Context, r trackedRunner) {
+	logger := logging.FromContext(ctx)
+
+	req := &runnerRequest{
+		InstallationID: r.InstallationID,
+		Org:            r.Org,
+		Repo:           r.Repo,
+		RunnerName:     relaunchedRunnerName(r.RunnerName, r.Relaunches+1),
+		Labels:         r.Labels,
+		Actor:          r.Actor,
+		Relaunches:     r.Relaunches + 1,
+	}
+	logFields := []any{
+		"org", r.Org,
+		"repo", r.Repo,
+		"runner_id", req.RunnerName,
+		"preempted_runner_id", r.RunnerName,
+		"relaunches", req.Relaunches,
+	}
+
+	s.transitionRunner(ctx, r.RunnerName, lifecycle.StateFailed)
+	s.runners.Remove(r.RunnerName)
+	s.metrics.recordSpotPreemption(spotPreemptionRelaunched)
+	if err := s.removeRunner(ctx, r.InstallationID, r.Org, r.Repo, r.RunnerName); err != nil {
+		logger.WarnContext(ctx, "failed to remove preempted runner", append(logFields, "error", err)...)
+	}
+
+	createdBuild, errResponse := s.provisionRunner(ctx, req, logFields)
+	if errResponse != nil {
+		logger.ErrorContext(ctx, "failed to relaunch preempted runner", append(logFields, "error", errResponse.Error)...)
+		return
+	}
+	s.runners.Update(req.RunnerName, func(t *trackedRunner) {
+		t.RunID = r.RunID
+		t.JobID = r.JobID
+		t.HeadSHA = r.HeadSHA
+	})
+	s.runnerDispatched(ctx, req, createdBuild, logFields)
+	logger.InfoContext(ctx, "relaunched preempted runner", logFields...)
+}
+
+// rerunPreemptedJob reruns the job that failed because its spot runner was
+// preempted. When GitHub does not accept the rerun yet, it is retried until
+// the preempted rerun TTL elapses.
+func (s *Server) rerunPreemptedJob(ctx context.Context, r trackedRunner, job *github.WorkflowJob) {
+	logger := logging.FromContext(ctx)
+
+	if job.GetConclusion() != "failure" {
+		return
+	}
+	preempted, err := s.runnerPreempted(ctx, r)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to check runner preemption",
+			"runner_id", r.RunnerName, "backend_id", r.BackendID, "error", err)
+		return
+	}
+	if !preempted {
+		return
+	}
+
+	s.metrics.recordSpotPreemption(spotPreemptionRerun)
+	p := &preemptedJob{
+		InstallationID: r.InstallationID,
+		Org:            r.Org,
+		Repo:           r.Repo,
+		JobID:          job.GetID(),
+		Since:          time.Now(),
+	}
+	if err := s.rerunJob(ctx, p); err != nil {
+		logger.WarnContext(ctx, "failed to rerun job of preempted runner, will retry",
+			"runner_id", r.RunnerName, "job_id", p.JobID, "error", err)
+		s.preemptedJobs.Add(p)
+		return
+	}
+	logger.InfoContext(ctx, "reran job of preempted runner", "runner_id", r.RunnerName, "job_id", p.JobID)
+}
+
+// retryPreemptedJobs retries the reruns GitHub did not accept yet, giving up
+// on those older than the preempted rerun TTL.
+func (s *Server) retryPreemptedJobs(ctx context.Context, now time.Time) {
+	logger := logging.FromContext(ctx)
+
+	for _, p := range s.preemptedJobs.List() {
+		if now.Sub(p.Since) > preemptedRerunTTL {
+			logger.ErrorContext(ctx, "giving up on rerunning job of preempted runner",
+				"org", p.Org, "repo", p.Repo, "job_id", p.JobID)
+			s.preemptedJobs.Remove(p.JobID)
+			continue
+		}
+		if err := s.rerunJob(ctx, p); err != nil {
+			logger.DebugContext(ctx, "job of preempted runner not rerun yet",
+				"org", p.Org, "repo", p.Repo, "job_id", p.JobID, "error", err)
+			continue
+		}
+		s.preemptedJobs.Remove(p.JobID)
+		logger.InfoContext(ctx, "reran job of preempted runner", "org", p.Org, "repo", p.Repo, "job_id", p.JobID)
+	}
+}
+
+// rerunJob asks GitHub to rerun the job.
+func (s *Server) rerunJob(ctx context.Context, p *preemptedJob) error {
+	gh, err := s.installationClient(ctx, p.InstallationID, map[string]string{
+		"actions": "write",
+	}, p.Repo)
+	if err != nil {
+		return fmt.Errorf("failed to setup installation client: %w", err)
+	}
+
+	cctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
+	if _, err := gh.Actions.RerunJobByID(cctx, p.Org, p.Repo, p.JobID); err != nil {
+		return fmt.Errorf("failed to rerun job %d: %w", p.JobID, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSpotCapacity(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		runnerSpot bool
+		req        *runnerRequest
+		want       bool
+	}{
+		{
+			name: "standard",
+			req:  &runnerRequest{Labels: []string{"self-hosted"}},
+		},
+		{
+			name: "label",
+			req:  &runnerRequest{Labels: []string{"self-hosted", "gcp-spot"}},
+			want: true,
+		},
+		{
+			name:       "config",
+			runnerSpot: true,
+			req:        &runnerRequest{Labels: []string{"self-hosted"}},
+			want:       true,
+		},
+		{
+			name: "relaunched",
+			req:  &runnerRequest{Labels: []string{"gcp-spot"}, Relaunches: 2},
+			want: true,
+		},
+		{
+			name: "max_relaunches",
+			req:  &runnerRequest{Labels: []string{"gcp-spot"}, Relaunches: 3},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerSpot: tc.runnerSpot, spotMaxRelaunches: 3}
+			if got, want := s.spotCapacity(tc.req), tc.want; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestRelaunchedRunnerName(t *testing.T) {
+	t.Parallel()
+
+	if got, want := relaunchedRunnerName("GCP-2", 1), "GCP-2-r1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := relaunchedRunnerName("GCP-2-r1", 2), "GCP-2-r2"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestRunnerPreempted(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		runner   trackedRunner
+		checkErr error
+		want     bool
+		wantErr  string
+	}{
+		{
+			name:   "preempted_vm",
+			runner: trackedRunner{Spot: true, Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-2"},
+			want:   true,
+		},
+		{
+			name:   "running_vm",
+			runner: trackedRunner{Spot: true, Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-3"},
+		},
+		{
+			name:   "preempted_batch_job",
+			runner: trackedRunner{Spot: true, Backend: runnerBackendBatch, BackendID: "projects/p/locations/us-central1/jobs/gcp-2"},
+			want:   true,
+		},
+		{
+			name:   "standard_capacity",
+			runner: trackedRunner{Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-2"},
+		},
+		{
+			name:   "no_preemption_check",
+			runner: trackedRunner{Spot: true, Backend: runnerBackendCloudBuild, BackendID: "projects/p/locations/us-central1/builds/build-1"},
+		},
+		{
+			name:     "check_error",
+			runner:   trackedRunner{Spot: true, Backend: runnerBackendCompute, BackendID: "projects/p/zones/us-central1-a/instances/gcp-2"},
+			checkErr: fmt.Errorf("permission denied"),
+			wantErr:  "permission denied",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				backend: &vmBackend{vms: &MockVMClient{
+					PreemptedErr: tc.checkErr,
+					Preempted:    map[string]bool{"projects/p/zones/us-central1-a/instances/gcp-2": true},
+				}},
+				batchBackend: &batchBackend{client: &MockBatchClient{
+					Preempted: map[string]bool{"projects/p/locations/us-central1/jobs/gcp-2": true},
+				}},
+				cbc:           &MockCloudBuildClient{},
+				runnerBackend: runnerBackendCompute,
+			}
+
+			got, err := s.runnerPreempted(t.Context(), tc.runner)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := got, tc.want; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestRetryPreemptedJobs(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &Server{preemptedJobs: newPreemptedJobs()}
+	s.preemptedJobs.Add(&preemptedJob{Org: "google", Repo: "webhook", JobID: 1, Since: now.Add(-7 * time.Hour)})
+
+	s.retryPreemptedJobs(t.Context(), now)
+
+	if diff := cmp.Diff([]*preemptedJob{}, s.preemptedJobs.List()); diff != "" {
+		t.Errorf("unexpected preempted jobs (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// startupProbeBuildID is the ID of a build that does not exist. Getting it
+// fails with NotFound when the Cloud Build API is reachable and the service
+// account may read builds.
+const startupProbeBuildID = "00000000-0000-0000-0000-000000000000"
+
+// startupProbe checks a dependency the server needs to serve requests.
+type startupProbe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// runStartupProbes runs all probes and returns the failures of all of them,
+// so a misconfigured revision reports everything that is wrong at once.
+func runStartupProbes(ctx context.Context, probes []startupProbe) error {
+	logger := logging.FromContext(ctx)
+
+	var merr error
+	for _, p := range probes {
+		if err := p.check(ctx); err != nil {
+			merr = errors.Join(merr, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+		logger.DebugContext(ctx, "startup probe passed", "probe", p.name)
+	}
+	return merr
+}
+
+// startupProbes returns the probes for the dependencies of the server. The
+// signer is the KMS backed key of the GitHub App, and registryClient is
+// authorized to read the runner image repositories.
+func (s *Server) startupProbes(signer crypto.Signer, registryClient *http.Client) []startupProbe {
+	probes := []startupProbe{
+		{"kms signing", func(ctx context.Context) error {
+			return checkSigner(signer)
+		}},
+		{"github app authentication", s.checkGitHubApp},
+		{"cloud build api", func(ctx context.Context) error {
+			return s.checkCloudBuild(ctx, s.runnerProjectID, s.runnerLocation)
+		}},
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.runnerProjectRoutes)) {
+		route := s.runnerProjectRoutes[key]
+		location := s.runnerLocation
+		if route.Location != "" {
+			location = route.Location
+		}
+		probes = append(probes, startupProbe{"cloud build api for " + key, func(ctx context.Context) error {
+			return s.checkCloudBuild(ctx, route.ProjectID, location)
+		}})
+	}
+	for _, image := range s.startupProbeImages() {
+		probes = append(probes, startupProbe{"runner image " + image, func(ctx context.Context) error {
+			return checkImage(ctx, registryClient, image)
+		}})
+	}
+	return probes
+}
+
+// checkSigner signs a digest with the signer.
+func checkSigner(signer crypto.Signer) error {
+	h := crypto.SHA256.New()
+	h.Write([]byte("github-actions-on-gcp startup probe"))
+	if _, err := signer.Sign(nil, h.Sum(nil), crypto.SHA256); err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+	return nil
+}
+
+// checkGitHubApp fetches the GitHub App authenticated as the app.
+func (s *Server) checkGitHubApp(ctx context.Context) error {
+	gh := github.NewClient(s.githubHTTPClient(s.appClient.OAuthAppTokenSource()))
+	baseURL, err := url.Parse(fmt.Sprintf("%s/", s.ghAPIBaseURL))
+	if err != nil {
+		return fmt.Errorf("failed to set github base URL: %w", err)
+	}
+	gh.BaseURL = baseURL
+
+	if _, _, err := gh.Apps.Get(ctx, ""); err != nil {
+		return fmt.Errorf("failed to get app: %w", err)
+	}
+	return nil
+}
+
+// checkCloudBuild gets a build that does not exist in the runner project.
+func (s *Server) checkCloudBuild(ctx context.Context, projectID, location string) error {
+	_, err := s.cbc.GetBuild(ctx, &cloudbuildpb.GetBuildRequest{
+		Name:      fmt.Sprintf("projects/%s/locations/%s/builds/%s", projectID, location, startupProbeBuildID),
+		ProjectId: projectID,
+		Id:        startupProbeBuildID,
+	})
+	if err == nil || status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return fmt.Errorf("failed to get build: %w", err)
+}
+
+// startupProbeImages returns the runner images jobs can select without a
+// pr- tag: the default image, its variants, and the GPU and arm64 images when
+// configured in the default repository, the default image with each canary
+// tag, the default image in each additional repository, and the image of
+// each image mapping.
+func (s *Server) startupProbeImages() []string {
+	images := []string{fmt.Sprintf("%s/%s:%s", s.runnerRepositoryID, s.runnerImageName, s.runnerImageTag)}
+	for _, tag := range s.canaryTags() {
+		images = append(images, fmt.Sprintf("%s/%s:%s", s.runnerRepositoryID, s.runnerImageName, tag))
+	}
+	for _, variant := range s.runnerImageVariants {
+		images = append(images, fmt.Sprintf("%s/%s:%s", s.runnerRepositoryID, variant, s.runnerImageTag))
+	}
+	if len(s.runnerGPUTypes) > 0 {
+		images = append(images, fmt.Sprintf("%s/%s:%s", s.runnerRepositoryID, s.runnerGPUImageName, s.runnerImageTag))
+	}
+	if s.runnerARM64ImageName != "" {
+		images = append(images, fmt.Sprintf("%s/%s:%s", s.runnerRepositoryID, s.runnerARM64ImageName, s.runnerImageTag))
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.runnerRepositories)) {
+		images = append(images, fmt.Sprintf("%s/%s:%s", s.runnerRepositories[name], s.runnerImageName, s.runnerImageTag))
+	}
+	for _, m := range s.runnerImageMappings {
+		repositoryID, tag := s.runnerRepositoryID, s.runnerImageTag
+		if m.Repository != "" {
+			repositoryID = s.runnerRepositories[m.Repository]
+		}
+		if m.Tag != "" {
+			tag = m.Tag
+		}
+		images = append(images, fmt.Sprintf("%s/%s:%s", repositoryID, m.Image, tag))
+	}
+	return images
+}
+
+// checkImage requests the manifest of an image in the form
+// <location>-docker.pkg.dev/<project>/<repository>/<image>:<tag> from the
+// registry API.
+func checkImage(ctx context.Context, client *http.Client, image string) error {
+	host, path, ok := strings.Cut(image, "/")
+	if !ok {
+		return fmt.Errorf("image %q has no registry host", image)
+	}
+	i := strings.LastIndex(path, ":")
+	if i < 0 {
+		return fmt.Errorf("image %q has no tag", image)
+	}
+
+	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path[:i], path[i+1:])
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ","))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get manifest: unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunStartupProbes(t *testing.T) {
+	t.Parallel()
+
+	err := runStartupProbes(t.Context(), []startupProbe{
+		{"ok", func(ctx context.Context) error { return nil }},
+		{"first", func(ctx context.Context) error { return fmt.Errorf("broken") }},
+		{"second", func(ctx context.Context) error { return fmt.Errorf("also broken") }},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"first: broken", "second: also broken"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error %q to contain %q", err, want)
+		}
+	}
+}
+
+func TestCheckCloudBuild(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "not_found",
+			err:  status.Error(codes.NotFound, "build not found"),
+		},
+		{
+			name:    "permission_denied",
+			err:     status.Error(codes.PermissionDenied, "permission denied"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				cbc: &MockCloudBuildClient{getBuildErr: tc.err},
+			}
+			if got, want := s.checkCloudBuild(t.Context(), "project", "us-central1") != nil, tc.wantErr; got != want {
+				t.Errorf("expected error %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckImage(t *testing.T) {
+	t.Parallel()
+
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/v2/project/runners/default-runner/manifests/latest" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(registry.Close)
+
+	host := strings.TrimPrefix(registry.URL, "https://")
+
+	cases := []struct {
+		name    string
+		image   string
+		wantErr bool
+	}{
+		{
+			name:  "present",
+			image: host + "/project/runners/default-runner:latest",
+		},
+		{
+			name:    "missing_tag",
+			image:   host + "/project/runners/default-runner:missing",
+			wantErr: true,
+		},
+		{
+			name:    "no_tag",
+			image:   host + "/project/runners/default-runner",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkImage(t.Context(), registry.Client(), tc.image)
+			if got, want := err != nil, tc.wantErr; got != want {
+				t.Errorf("expected error %t to be %t: %v", got, want, err)
+			}
+		})
+	}
+}
+
+func TestStartupProbeImages(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerImageName:     "default-runner",
+		runnerImageTag:      "v1",
+		runnerImageVariants: []string{"ubuntu-24"},
+		runnerProfiles: map[string]*RunnerProfile{
+			"default": {Canary: &ImageCanary{Tag: "v2", Percent: 5}},
+		},
+		runnerRepositories: map[string]string{"asia": "asia-docker.pkg.dev/p/runners"},
+		runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+	}
+
+	got := s.startupProbeImages()
+	want := []string{
+		"us-docker.pkg.dev/p/runners/default-runner:v1",
+		"us-docker.pkg.dev/p/runners/default-runner:v2",
+		"us-docker.pkg.dev/p/runners/ubuntu-24:v1",
+		"asia-docker.pkg.dev/p/runners/default-runner:v1",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected images %q to be %q", got, want)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// errStateNotFound is returned when the state store has no state for a runner.
+var errStateNotFound = errors.New("runner state not found")
+
+// RunnerState is the persisted state of a runner, from the delivery that
+// requested it to the end of its lifecycle.
+type RunnerState struct {
+	RunnerName     string
+	DeliveryID     string
+	InstallationID int64
+	Org            string
+	Repo           string
+	RunID          int64
+	JobID          int64
+	ProjectID      string
+	Location       string
+	BuildID        string
+	Backend        string
+	BackendID      string
+	State          lifecycle.State
+
+	// RunnerGroupID is the organization runner group the runner was
+	// registered with, 0 for a repository runner.
+	RunnerGroupID int64
+
+	// Actor, Labels, Spot, Reusable and JobsServed restore the runner in the
+	// tracker of another instance or after a restart.
+	Actor      string
+	Labels     []string
+	Spot       bool
+	Reusable   bool
+	JobsServed int
+
+	// EnteredAt are the times the runner entered each of the lifecycle
+	// states it went through.
+	EnteredAt map[lifecycle.State]time.Time
+}
+
+// StateStore adheres to the interaction the webhook service has with the
+// store keeping the state of runners across restarts and instances.
+type StateStore interface {
+	// PutRunner creates or replaces the state of the runner, and records it
+	// as a runner of its job.
+	PutRunner(ctx context.Context, state *RunnerState) error
+
+	// GetRunner returns the state of the runner, or errStateNotFound.
+	GetRunner(ctx context.Context, runnerName string) (*RunnerState, error)
+
+	// JobRunners returns the states of the runners provisioned for the job.
+	JobRunners(ctx context.Context, jobID int64) ([]*RunnerState, error)
+
+	// ActiveRunners returns the states of the runners that did not reach a
+	// terminal state, whichever instance provisioned them.
+	ActiveRunners(ctx context.Context) ([]*RunnerState, error)
+}
+
+// runnerState returns the state of the tracked runner to persist.
+func runnerState(r *trackedRunner) *RunnerState {
+	state := &RunnerState{
+		RunnerName:     r.RunnerName,
+		DeliveryID:     r.DeliveryID,
+		InstallationID: r.InstallationID,
+		Org:            r.Org,
+		Repo:           r.Repo,
+		RunID:          r.RunID,
+		JobID:          r.JobID,
+		ProjectID:      r.ProjectID,
+		Location:       r.Location,
+		BuildID:        r.BuildID,
+		Backend:        r.Backend,
+		BackendID:      r.BackendID,
+		State:          r.Lifecycle.State(),
+		RunnerGroupID:  r.RunnerGroupID,
+		Actor:          r.Actor,
+		Labels:         slices.Clone(r.Labels),
+		Spot:           r.Spot,
+		Reusable:       r.Reusable,
+		JobsServed:     r.JobsServed,
+		EnteredAt:      make(map[lifecycle.State]time.Time),
+	}
+	for _, s := range lifecycle.States {
+		if at := r.Lifecycle.EnteredAt(s); !at.IsZero() {
+			state.EnteredAt[s] = at
+		}
+	}
+	return state
+}
+
+// saveRunnerState persists the current state of the tracked runner. Failures
+// are only logged, the in-memory tracker stays authoritative for this
+// instance.
+func (s *Server) saveRunnerState(ctx context.Context, runnerName string) {
+	if s.stateStore == nil {
+		return
+	}
+
+	var state *RunnerState
+	if !s.runners.Update(runnerName, func(r *trackedRunner) {
+		state = runnerState(r)
+	}) {
+		return
+	}
+	if err := s.stateStore.PutRunner(ctx, state); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to save runner state",
+			"runner_id", runnerName,
+			"error", err)
+	}
+}
+
+// runnerStateSyncInterval is the minimum time between two refreshes of the
+// tracker from the state store.
+const runnerStateSyncInterval = 10 * time.Second
+
+// stateSync rate-limits the refreshes of the tracker from the state store. A
+// nil stateSync refreshes on every call.
+type stateSync struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newStateSync() *stateSync {
+	return &stateSync{}
+}
+
+// due reports whether a refresh is due at the given time, and records it as
+// the time of the last refresh if it is. Concurrent callers do not wait for a
+// refresh in progress, they use the runners tracked so far.
+func (ss *stateSync) due(now time.Time) bool {
+	if ss == nil {
+		return true
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if !ss.last.IsZero() && now.Sub(ss.last) < runnerStateSyncInterval {
+		return false
+	}
+	ss.last = now
+	return true
+}
+
+// trackedRunnerFromState returns the tracked runner of a stored state.
+func trackedRunnerFromState(state *RunnerState) (*trackedRunner, error) {
+	l, err := lifecycle.Restore(state.State, state.EnteredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedRunner{
+		RunnerName:     state.RunnerName,
+		DeliveryID:     state.DeliveryID,
+		InstallationID: state.InstallationID,
+		Org:            state.Org,
+		Repo:           state.Repo,
+		Actor:          state.Actor,
+		Labels:         slices.Clone(state.Labels),
+		RunID:          state.RunID,
+		JobID:          state.JobID,
+		ProjectID:      state.ProjectID,
+		Location:       state.Location,
+		BuildID:        state.BuildID,
+		Backend:        state.Backend,
+		BackendID:      state.BackendID,
+		Spot:           state.Spot,
+		Lifecycle:      l,
+		RunnerGroupID:  state.RunnerGroupID,
+		Reusable:       state.Reusable,
+		JobsServed:     state.JobsServed,
+	}, nil
+}
+
+// restoreRunners tracks the runners the state store holds as active, those
+// provisioned before a restart or by another instance, so the limits and the
+// background monitors account for them. It returns the number of runners
+// restored.
+func (s *Server) restoreRunners(ctx context.Context) int {
+	if s.stateStore == nil {
+		return 0
+	}
+	s.stateSync.due(time.Now())
+	return s.refreshRunners(ctx)
+}
+
+// syncRunners refreshes the tracker from the state store ahead of a limit
+// check or a monitor pass, at most once per runnerStateSyncInterval. Without a
+// state store the tracker only holds the runners of this instance.
+func (s *Server) syncRunners(ctx context.Context) {
+	if s.stateStore == nil || !s.stateSync.due(time.Now()) {
+		return
+	}
+	s.refreshRunners(ctx)
+}
+
+// refreshRunners tracks the active runners of the state store that are not
+// tracked, updates the tracked runners whose stored state is more recent, and
+// stops tracking the runners the store recorded as terminal. Runners not
+// stored yet are left alone. It returns the number of runners added. Failures
+// are only logged, the tracker keeps the runners it has.
+func (s *Server) refreshRunners(ctx context.Context) int {
+	logger := logging.FromContext(ctx)
+
+	states, err := s.stateStore.ActiveRunners(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to read active runners from the state store", "error", err)
+		return 0
+	}
+
+	added := 0
+	active := make(map[string]struct{}, len(states))
+	for _, state := range states {
+		active[state.RunnerName] = struct{}{}
+		restored, err := trackedRunnerFromState(state)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to restore stored runner",
+				"runner_id", state.RunnerName,
+				"error", err)
+			continue
+		}
+		if s.runners.Update(state.RunnerName, func(r *trackedRunner) {
+			current := r.Lifecycle.EnteredAt(r.Lifecycle.State())
+			if restored.Lifecycle.EnteredAt(restored.Lifecycle.State()).After(current) {
+				r.Lifecycle = restored.Lifecycle
+				r.JobsServed = restored.JobsServed
+			}
+		}) {
+			continue
+		}
+		s.runners.Dispatched(restored)
+		added++
+	}
+
+	removed := false
+	for _, r := range s.runners.List() {
+		if _, ok := active[r.RunnerName]; ok {
+			continue
+		}
+		state, err := s.stateStore.GetRunner(ctx, r.RunnerName)
+		if err != nil {
+			if !errors.Is(err, errStateNotFound) {
+				logger.WarnContext(ctx, "failed to read runner state", "runner_id", r.RunnerName, "error", err)
+			}
+			continue
+		}
+		if state.State.Terminal() {
+			s.runners.Remove(r.RunnerName)
+			removed = true
+		}
+	}
+	if added > 0 || removed {
+		s.launchQueue.notify()
+	}
+	return added
+}
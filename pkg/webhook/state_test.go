@@ -0,0 +1,291 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// memStateStore is an in-memory StateStore.
+type memStateStore struct {
+	mu      sync.Mutex
+	runners map[string]*RunnerState
+	jobs    map[int64][]string
+	putErr  error
+}
+
+func newMemStateStore() *memStateStore {
+	return &memStateStore{
+		runners: make(map[string]*RunnerState),
+		jobs:    make(map[int64][]string),
+	}
+}
+
+func (m *memStateStore) PutRunner(ctx context.Context, state *RunnerState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.putErr != nil {
+		return m.putErr
+	}
+	if _, ok := m.runners[state.RunnerName]; !ok && state.JobID != 0 {
+		m.jobs[state.JobID] = append(m.jobs[state.JobID], state.RunnerName)
+	}
+	c := *state
+	m.runners[state.RunnerName] = &c
+	return nil
+}
+
+func (m *memStateStore) GetRunner(ctx context.Context, runnerName string) (*RunnerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.runners[runnerName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errStateNotFound, runnerName)
+	}
+	c := *state
+	return &c, nil
+}
+
+func (m *memStateStore) JobRunners(ctx context.Context, jobID int64) ([]*RunnerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var states []*RunnerState
+	for _, name := range m.jobs[jobID] {
+		c := *m.runners[name]
+		states = append(states, &c)
+	}
+	return states, nil
+}
+
+func (m *memStateStore) ActiveRunners(ctx context.Context) ([]*RunnerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var states []*RunnerState
+	for _, state := range m.runners {
+		if !state.State.Terminal() {
+			c := *state
+			states = append(states, &c)
+		}
+	}
+	return states, nil
+}
+
+func TestRunnerState(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	l := lifecycle.New(created)
+	if err := l.Transition(lifecycle.StateDispatching, created.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Transition(lifecycle.StateProvisioning, created.Add(2*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := runnerState(&trackedRunner{
+		RunnerName:     "GCP-runner",
+		DeliveryID:     "delivery-1",
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		RunID:          5,
+		JobID:          1,
+		ProjectID:      "runner-project",
+		Location:       "us-central1",
+		BuildID:        "build-1",
+		Backend:        runnerBackendCloudBuild,
+		BackendID:      "projects/runner-project/locations/us-central1/builds/build-1",
+		Lifecycle:      l,
+		RunnerGroupID:  3,
+	})
+
+	want := &RunnerState{
+		RunnerName:     "GCP-runner",
+		DeliveryID:     "delivery-1",
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		RunID:          5,
+		JobID:          1,
+		ProjectID:      "runner-project",
+		Location:       "us-central1",
+		BuildID:        "build-1",
+		Backend:        runnerBackendCloudBuild,
+		BackendID:      "projects/runner-project/locations/us-central1/builds/build-1",
+		State:          lifecycle.StateProvisioning,
+		RunnerGroupID:  3,
+		EnteredAt: map[lifecycle.State]time.Time{
+			lifecycle.StateQueued:       created,
+			lifecycle.StateDispatching:  created.Add(time.Second),
+			lifecycle.StateProvisioning: created.Add(2 * time.Second),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected state (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSaveRunnerState(t *testing.T) {
+	t.Parallel()
+
+	store := newMemStateStore()
+	srv := &Server{
+		runners:    newRunnerTracker(),
+		stateStore: store,
+	}
+	srv.runners.Dispatched(trackedRunnerIn(t, "GCP-runner", lifecycle.StateProvisioning))
+
+	srv.transitionRunner(t.Context(), "GCP-runner", lifecycle.StateRunning)
+
+	got, err := store.GetRunner(t.Context(), "GCP-runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := got.State, lifecycle.StateRunning; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got.EnteredAt[lifecycle.StateRunning].IsZero() {
+		t.Errorf("expected the time the runner started running to be recorded")
+	}
+
+	states, err := store.JobRunners(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(states), 1; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Untracked runners are not saved.
+	srv.saveRunnerState(t.Context(), "GCP-unknown")
+	if _, err := store.GetRunner(t.Context(), "GCP-unknown"); err == nil {
+		t.Errorf("expected no state for an untracked runner")
+	}
+}
+
+func TestRestoreRunners(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := newMemStateStore()
+
+	// The instance before the restart provisioned two runners, one of which
+	// completed.
+	before := &Server{
+		runners:    newRunnerTracker(),
+		stateStore: store,
+	}
+	for _, name := range []string{"GCP-running", "GCP-completed"} {
+		r := trackedRunnerIn(t, name, lifecycle.StateRunning)
+		r.Org, r.Repo, r.Actor = "google", "webhook", "octocat"
+		before.runners.Dispatched(r)
+		before.saveRunnerState(ctx, name)
+	}
+	before.transitionRunner(ctx, "GCP-completed", lifecycle.StateCompleted)
+
+	after := &Server{
+		runners:    newRunnerTracker(),
+		stateStore: store,
+	}
+	if got, want := after.restoreRunners(ctx), 1; got != want {
+		t.Errorf("expected %d restored runners to be %d", got, want)
+	}
+	r, ok := after.trackedRunner("GCP-running")
+	if !ok {
+		t.Fatalf("expected the running runner to be restored")
+	}
+	if got, want := r.Lifecycle.State(), lifecycle.StateRunning; got != want {
+		t.Errorf("expected restored state %q to be %q", got, want)
+	}
+	if r.Lifecycle.EnteredAt(lifecycle.StateDispatching).IsZero() {
+		t.Errorf("expected the dispatch time to be restored")
+	}
+	if _, ok := after.trackedRunner("GCP-completed"); ok {
+		t.Errorf("expected the completed runner to not be restored")
+	}
+	// The limits count the restored runners.
+	if got, want := after.runners.CountOrg("google"), 1; got != want {
+		t.Errorf("expected %d runners of the organization to be %d", got, want)
+	}
+	if got, want := after.runners.CountActor("octocat"), 1; got != want {
+		t.Errorf("expected %d runners of the actor to be %d", got, want)
+	}
+
+	// The restored runner transitions like a runner this instance
+	// provisioned.
+	after.transitionRunner(ctx, "GCP-running", lifecycle.StateCompleted)
+	if got, err := store.GetRunner(ctx, "GCP-running"); err != nil {
+		t.Fatal(err)
+	} else if got, want := got.State, lifecycle.StateCompleted; got != want {
+		t.Errorf("expected stored state %q to be %q", got, want)
+	}
+}
+
+func TestSyncRunners(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := newMemStateStore()
+
+	other := &Server{
+		runners:    newRunnerTracker(),
+		stateStore: store,
+	}
+	srv := &Server{
+		runners:    newRunnerTracker(),
+		stateStore: store,
+		stateSync:  newStateSync(),
+	}
+
+	// Provisioned by another instance.
+	other.runners.Dispatched(trackedRunnerIn(t, "GCP-other", lifecycle.StateProvisioning))
+	other.saveRunnerState(ctx, "GCP-other")
+	// Provisioned by this instance, its state not saved yet.
+	srv.runners.Dispatched(trackedRunnerIn(t, "GCP-unsaved", lifecycle.StateProvisioning))
+
+	srv.syncRunners(ctx)
+	if got, want := srv.runners.Count(), 2; got != want {
+		t.Errorf("expected %d tracked runners to be %d", got, want)
+	}
+
+	// The other instance saw the build fail.
+	other.transitionRunner(ctx, "GCP-other", lifecycle.StateFailed)
+
+	// Refreshes are rate-limited.
+	srv.syncRunners(ctx)
+	if _, ok := srv.trackedRunner("GCP-other"); !ok {
+		t.Errorf("expected the runner to still be tracked until the next refresh")
+	}
+
+	srv.refreshRunners(ctx)
+	if _, ok := srv.trackedRunner("GCP-other"); ok {
+		t.Errorf("expected the runner failed on the other instance to no longer be tracked")
+	}
+	if _, ok := srv.trackedRunner("GCP-unsaved"); !ok {
+		t.Errorf("expected the unsaved runner to still be tracked")
+	}
+}
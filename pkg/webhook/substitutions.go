@@ -0,0 +1,106 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+var (
+	// substitutionKeyRegexp matches the user-defined substitutions Cloud Build
+	// accepts.
+	substitutionKeyRegexp = regexp.MustCompile(`^_[A-Z0-9_]+$`)
+
+	// envNameRegexp matches the environment variable names passed to the
+	// runner.
+	envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validateExtraSubstitutions checks the extra substitutions of runner builds.
+func validateExtraSubstitutions(subs map[string]string) error {
+	for key := range subs {
+		if !substitutionKeyRegexp.MatchString(key) {
+			return fmt.Errorf("substitution %q must start with _ and contain only uppercase letters, digits and _", key)
+		}
+	}
+	return nil
+}
+
+// validateExtraEnv checks the extra environment variables of the runner. The
+// values are passed to the runner in its shell command, and the variables
+// the webhook service sets cannot be overridden.
+func validateExtraEnv(env map[string]string) error {
+	for name, value := range env {
+		if !envNameRegexp.MatchString(name) {
+			return fmt.Errorf("environment variable name %q is invalid", name)
+		}
+		if _, ok := runnerEnvSubstitutions[name]; ok || name == jitConfigEnv {
+			return fmt.Errorf("environment variable %q is set by the webhook service", name)
+		}
+		if strings.ContainsAny(value, " \t\n'\"$`\\") {
+			return fmt.Errorf("value of environment variable %q must not contain whitespace, quotes, $, ` or \\", name)
+		}
+	}
+	return nil
+}
+
+// extraEnvArgs returns the docker run arguments passing the extra environment
+// variables to the runner, sorted by name.
+func extraEnvArgs(env map[string]string) string {
+	args := make([]string, 0, len(env))
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		args = append(args, fmt.Sprintf("-e %s=%s", name, env[name]))
+	}
+	return strings.Join(args, " ")
+}
+
+// parseExtraEnvArgs returns the extra environment variables passed by the
+// docker run arguments of extraEnvArgs.
+func parseExtraEnvArgs(args string) map[string]string {
+	env := make(map[string]string)
+	fields := strings.Fields(args)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if name, value, ok := strings.Cut(fields[i+1], "="); fields[i] == "-e" && ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// addExtraSubstitutions merges the extra substitutions into the runner build,
+// without overriding those of the webhook service, and passes the extra
+// environment variables and those read from the metadata server to the
+// runner. Extra substitutions are meant for the steps of build templates, so
+// they may go unused.
+func (s *Server) addExtraSubstitutions(build *cloudbuildpb.Build) {
+	build.Substitutions["_EXTRA_ENV_ARGS"] = extraEnvArgs(s.runnerExtraEnv)
+	build.Substitutions["_METADATA_ENV_ARGS"] = metadataEnvArgs(s.runnerMetadataEnv)
+	build.Substitutions["_METADATA_ENV"] = metadataEnvList(s.runnerMetadataEnv)
+	if len(s.runnerExtraSubstitutions) == 0 {
+		return
+	}
+	for key, value := range s.runnerExtraSubstitutions {
+		if _, ok := build.Substitutions[key]; !ok {
+			build.Substitutions[key] = value
+		}
+	}
+	build.Options.SubstitutionOption = cloudbuildpb.BuildOptions_ALLOW_LOOSE
+}
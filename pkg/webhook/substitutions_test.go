@@ -0,0 +1,141 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateExtraSubstitutions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		subs    map[string]string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			subs: map[string]string{"_CACHE_BUCKET": "team-cache", "_FEATURE_X": "on"},
+		},
+		{
+			name:    "no_underscore",
+			subs:    map[string]string{"CACHE_BUCKET": "team-cache"},
+			wantErr: `substitution "CACHE_BUCKET" must start with _`,
+		},
+		{
+			name:    "lowercase",
+			subs:    map[string]string{"_cache": "team-cache"},
+			wantErr: `substitution "_cache" must start with _`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(validateExtraSubstitutions(tc.subs), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestValidateExtraEnv(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			env:  map[string]string{"PIP_INDEX_URL": "https://pypi.internal/simple", "FEATURE_X": "on"},
+		},
+		{
+			name:    "invalid_name",
+			env:     map[string]string{"FEATURE-X": "on"},
+			wantErr: `environment variable name "FEATURE-X" is invalid`,
+		},
+		{
+			name:    "reserved",
+			env:     map[string]string{"HTTP_PROXY": "http://other:3128"},
+			wantErr: `environment variable "HTTP_PROXY" is set by the webhook service`,
+		},
+		{
+			name:    "jit_config",
+			env:     map[string]string{"ENCODED_JIT_CONFIG": "x"},
+			wantErr: `environment variable "ENCODED_JIT_CONFIG" is set by the webhook service`,
+		},
+		{
+			name:    "shell_injection",
+			env:     map[string]string{"FEATURE_X": "on; curl attacker"},
+			wantErr: `value of environment variable "FEATURE_X" must not contain whitespace`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := testutil.DiffErrString(validateExtraEnv(tc.env), tc.wantErr); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestAddExtraSubstitutions(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerExtraEnv:           map[string]string{"PIP_INDEX_URL": "https://pypi.internal/simple", "FEATURE_X": "on"},
+		runnerExtraSubstitutions: map[string]string{"_CACHE_BUCKET": "team-cache", "_IMAGE_TAG": "ignored"},
+		runnerImageName:          "default-runner",
+		runnerImageTag:           "latest",
+		runnerRepositoryID:       "us-docker.pkg.dev/p/runners",
+	}
+
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+	if got, want := build.GetSubstitutions()["_CACHE_BUCKET"], "team-cache"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := build.GetSubstitutions()["_IMAGE_TAG"], "latest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := build.GetOptions().GetSubstitutionOption(), cloudbuildpb.BuildOptions_ALLOW_LOOSE; got != want {
+		t.Errorf("expected %s to be %s", got, want)
+	}
+	if got, want := build.GetSubstitutions()["_EXTRA_ENV_ARGS"], "-e FEATURE_X=on -e PIP_INDEX_URL=https://pypi.internal/simple"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	env := (&RunnerSpec{Build: build}).Env()
+	want := map[string]string{
+		"FEATURE_X":            "on",
+		"PIP_INDEX_URL":        "https://pypi.internal/simple",
+		"RUNNER_SECURITY_MODE": runnerSecurityPrivileged,
+	}
+	if diff := cmp.Diff(want, env); diff != "" {
+		t.Errorf("unexpected env (-want, +got):\n%s", diff)
+	}
+}
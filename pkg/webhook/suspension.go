@@ -0,0 +1,127 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/go-github/v69/github"
+)
+
+// errInstallationSuspended is returned when the installation of a runner
+// request is suspended. GitHub refuses to issue its tokens.
+var errInstallationSuspended = errors.New("installation is suspended")
+
+// runnerInstallationSuspendedMsg is the response to a runner request of a
+// suspended installation.
+var runnerInstallationSuspendedMsg = "no action taken for suspended installation"
+
+// suspendedInstallations remembers the installations known to be suspended.
+// The installation event only reaches one instance, so entries expire after
+// installationRepoTTL, after which the installation is checked with GitHub
+// again. A nil set is valid and remembers nothing.
+type suspendedInstallations struct {
+	mu    sync.Mutex
+	until map[int64]time.Time
+}
+
+func newSuspendedInstallations() *suspendedInstallations {
+	return &suspendedInstallations{
+		until: make(map[int64]time.Time),
+	}
+}
+
+func (c *suspendedInstallations) has(installationID int64, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[installationID]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(c.until, installationID)
+		return false
+	}
+	return true
+}
+
+func (c *suspendedInstallations) suspend(installationID int64, now time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.until[installationID] = now.Add(installationRepoTTL)
+}
+
+func (c *suspendedInstallations) unsuspend(installationID int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.until, installationID)
+}
+
+// handleInstallation disables dispatch for an installation when it is
+// suspended and enables it again when it is unsuspended.
+func (s *Server) handleInstallation(ctx context.Context, event *github.InstallationEvent) *apiResponse {
+	logger := logging.FromContext(ctx)
+
+	installation := event.GetInstallation()
+	logFields := []any{
+		"action", event.GetAction(),
+		"installation_id", installation.GetID(),
+		"account", installation.GetAccount().GetLogin(),
+		"sender", event.GetSender().GetLogin(),
+	}
+
+	switch event.GetAction() {
+	case "suspend":
+		s.suspendedInstallations.suspend(installation.GetID(), time.Now())
+		// Repositories verified before the suspension are checked again
+		// once it is lifted.
+		s.installationRepos.forget(installation.GetID())
+		logger.WarnContext(ctx, "installation suspended, dispatch disabled",
+			append(logFields, "suspended_by", installation.GetSuspendedBy().GetLogin())...)
+		return &apiResponse{http.StatusOK, "installation suspended", nil}
+
+	case "unsuspend":
+		s.suspendedInstallations.unsuspend(installation.GetID())
+		s.installationRepos.forget(installation.GetID())
+		logger.InfoContext(ctx, "installation unsuspended, dispatch enabled", logFields...)
+		return &apiResponse{http.StatusOK, "installation unsuspended", nil}
+
+	default:
+		logger.InfoContext(ctx, "no action taken for installation action", logFields...)
+		return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for installation action: %q", event.GetAction()), nil}
+	}
+}
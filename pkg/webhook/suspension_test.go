@@ -0,0 +1,88 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v69/github"
+)
+
+func TestHandleInstallation(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	now := time.Now()
+
+	s := &Server{
+		installationRepos:      newInstallationRepoCache(),
+		suspendedInstallations: newSuspendedInstallations(),
+	}
+	s.installationRepos.add("123/google/webhook", now)
+	s.installationRepos.add("456/google/other", now)
+
+	event := func(action string) *github.InstallationEvent {
+		return &github.InstallationEvent{
+			Action:       github.Ptr(action),
+			Installation: &github.Installation{ID: github.Ptr(int64(123))},
+		}
+	}
+
+	if got, want := s.handleInstallation(ctx, event("suspend")).Message, "installation suspended"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if !s.suspendedInstallations.has(123, now) {
+		t.Errorf("expected installation to be suspended")
+	}
+	if s.installationRepos.has("123/google/webhook", now) {
+		t.Errorf("expected verified repositories of the suspended installation to be forgotten")
+	}
+	if !s.installationRepos.has("456/google/other", now) {
+		t.Errorf("expected verified repositories of other installations to be kept")
+	}
+
+	// Dispatch is refused without calling GitHub.
+	if err := s.verifyInstallationRepo(ctx, 123, "google", "webhook"); !errors.Is(err, errInstallationSuspended) {
+		t.Errorf("expected %v to be %v", err, errInstallationSuspended)
+	}
+
+	if got, want := s.handleInstallation(ctx, event("unsuspend")).Message, "installation unsuspended"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if s.suspendedInstallations.has(123, now) {
+		t.Errorf("expected installation not to be suspended")
+	}
+
+	if got, want := s.handleInstallation(ctx, event("new_permissions_accepted")).Message, `no action taken for installation action: "new_permissions_accepted"`; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestSuspendedInstallationsExpire(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	c := newSuspendedInstallations()
+	c.suspend(123, now)
+
+	if !c.has(123, now.Add(installationRepoTTL-time.Second)) {
+		t.Errorf("expected installation to be suspended before the TTL")
+	}
+	if c.has(123, now.Add(installationRepoTTL+time.Second)) {
+		t.Errorf("expected suspension to expire after the TTL")
+	}
+}
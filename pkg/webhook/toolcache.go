@@ -0,0 +1,99 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+const (
+	// toolcachePath is where the setup-* actions look for the tool cache on
+	// GitHub-hosted runners.
+	toolcachePath   = "/opt/hostedtoolcache"
+	toolcacheVolume = "toolcache"
+)
+
+// toolcacheSnapshotRegexp matches the snapshots tool cache disks of runner
+// VMs are created from.
+var toolcacheSnapshotRegexp = regexp.MustCompile(`^projects/[^/]+/global/snapshots/[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// toolcacheCommands are the commands the setup-* actions need to unpack the
+// runtimes they download.
+var toolcacheCommands = []string{"tar", "gzip", "xz", "unzip"}
+
+// toolcacheCompat returns whether the runner gets a writable tool cache,
+// the profile overrides the server default.
+func (s *Server) toolcacheCompat(profile *RunnerProfile) bool {
+	if profile != nil && profile.ToolcacheCompat != nil {
+		return *profile.ToolcacheCompat
+	}
+	return s.runnerToolcacheCompat
+}
+
+// addToolcacheSteps mounts a tool cache volume into the runner and sets
+// RUNNER_TOOL_CACHE to it, so the setup-* actions work whatever the base
+// image of the runner.
+//
+// A step before the runner mounts the volume into the runner image as root.
+// Docker copies the tools the image has at the path into the empty volume,
+// then the step hands the volume to the user the image runs as and checks
+// the image for the commands the setup-* actions need. The check fails the
+// step without failing the build, the job itself reports what broke.
+func (s *Server) addToolcacheSteps(build *cloudbuildpb.Build, profile *RunnerProfile) {
+	if !s.toolcacheCompat(profile) {
+		return
+	}
+
+	i := slices.IndexFunc(build.GetSteps(), func(step *cloudbuildpb.BuildStep) bool {
+		return step.GetId() == "run"
+	})
+	if i < 0 {
+		return
+	}
+	run := build.GetSteps()[i]
+
+	volume := &cloudbuildpb.Volume{Name: toolcacheVolume, Path: "/" + toolcacheVolume}
+	run.Volumes = append(run.Volumes, volume)
+	build.Substitutions["_TOOLCACHE_ARGS"] = fmt.Sprintf("-e RUNNER_TOOL_CACHE=%[1]s -e AGENT_TOOLSDIRECTORY=%[1]s -v %[2]s:%[1]s",
+		toolcachePath, toolcacheVolume)
+
+	// Cloud Build substitutes $VAR, shell variables are escaped as $$VAR.
+	check := fmt.Sprintf(`mkdir -p %[1]s && find %[1]s -maxdepth 1 -exec chown "$$TOOLCACHE_USER" {} + && `+
+		`missing="" && for c in %[2]s; do command -v "$$c" >/dev/null 2>&1 || missing="$$missing $$c"; done && `+
+		`if [ -n "$$missing" ]; then echo "runner image is missing commands the setup-* actions need:$$missing"; exit 1; fi`,
+		toolcachePath, strings.Join(toolcacheCommands, " "))
+	image := "$_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG"
+	script := strings.Join([]string{
+		"docker pull " + image,
+		fmt.Sprintf(`user="$$(docker image inspect -f '{{.Config.User}}' %s)"`, image),
+		fmt.Sprintf(`docker run --rm --user 0 --entrypoint sh -e TOOLCACHE_USER="$${user:-0}" -v %s:%s %s -c '%s'`,
+			toolcacheVolume, toolcachePath, image, check),
+	}, "\n")
+
+	prepare := &cloudbuildpb.BuildStep{
+		Id:           "prepare-toolcache",
+		Name:         "gcr.io/cloud-builders/docker",
+		Entrypoint:   "bash",
+		Args:         []string{"-c", script},
+		Volumes:      []*cloudbuildpb.Volume{volume},
+		AllowFailure: true,
+	}
+	build.Steps = slices.Insert(build.Steps, i, prepare)
+}
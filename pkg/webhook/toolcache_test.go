@@ -0,0 +1,119 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v69/github"
+)
+
+func TestAddToolcacheSteps(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		compat    bool
+		profile   *RunnerProfile
+		wantSteps []string
+	}{
+		{
+			name:      "disabled",
+			wantSteps: []string{"restore-caches", "run", "save-caches"},
+		},
+		{
+			name:      "enabled",
+			compat:    true,
+			wantSteps: []string{"restore-caches", "prepare-toolcache", "run", "save-caches"},
+		},
+		{
+			name:      "enabled_by_profile",
+			profile:   &RunnerProfile{ToolcacheCompat: github.Ptr(true)},
+			wantSteps: []string{"restore-caches", "prepare-toolcache", "run", "save-caches"},
+		},
+		{
+			name:      "disabled_by_profile",
+			compat:    true,
+			profile:   &RunnerProfile{ToolcacheCompat: github.Ptr(false)},
+			wantSteps: []string{"restore-caches", "run", "save-caches"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{runnerToolcacheCompat: tc.compat}
+
+			build := &cloudbuildpb.Build{
+				Steps: []*cloudbuildpb.BuildStep{
+					{Id: "restore-caches"},
+					{Id: "run", Volumes: []*cloudbuildpb.Volume{{Name: "cache-gomod", Path: "/cache/gomod"}}},
+					{Id: "save-caches"},
+				},
+				Substitutions: map[string]string{"_TOOLCACHE_ARGS": ""},
+			}
+			s.addToolcacheSteps(build, tc.profile)
+
+			var gotSteps []string
+			for _, step := range build.GetSteps() {
+				gotSteps = append(gotSteps, step.GetId())
+			}
+			if diff := cmp.Diff(tc.wantSteps, gotSteps); diff != "" {
+				t.Fatalf("unexpected steps (-want, +got):\n%s", diff)
+			}
+
+			args := build.GetSubstitutions()["_TOOLCACHE_ARGS"]
+			if len(tc.wantSteps) == 3 {
+				if args != "" {
+					t.Errorf("expected no toolcache args, got %q", args)
+				}
+				return
+			}
+
+			if got, want := args, "-e RUNNER_TOOL_CACHE=/opt/hostedtoolcache -e AGENT_TOOLSDIRECTORY=/opt/hostedtoolcache -v toolcache:/opt/hostedtoolcache"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			run := build.GetSteps()[2]
+			if got, want := len(run.GetVolumes()), 2; got != want {
+				t.Fatalf("expected %d run volumes to be %d", got, want)
+			}
+			if got, want := run.GetVolumes()[1].GetName(), "toolcache"; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+
+			prepare := build.GetSteps()[1]
+			if !prepare.GetAllowFailure() {
+				t.Errorf("expected the prepare-toolcache step to allow failure")
+			}
+			script := prepare.GetArgs()[1]
+			for _, want := range []string{
+				"docker pull $_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
+				"-v toolcache:/opt/hostedtoolcache $_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
+				`chown "$$TOOLCACHE_USER"`,
+				"for c in tar gzip xz unzip",
+			} {
+				if !strings.Contains(script, want) {
+					t.Errorf("expected script %q to contain %q", script, want)
+				}
+			}
+		})
+	}
+}
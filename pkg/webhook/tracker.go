@@ -0,0 +1,349 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// errRunnerNotTracked is returned when transitioning a runner this instance
+// did not provision.
+var errRunnerNotTracked = errors.New("runner is not tracked")
+
+// trackedRunner is the in-memory record of a runner this instance
+// provisioned.
+type trackedRunner struct {
+	RunnerName     string
+	DeliveryID     string
+	InstallationID int64
+	Org            string
+	Repo           string
+	Actor          string
+	Labels         []string
+	RunID          int64
+	JobID          int64
+	HeadSHA        string
+	ProjectID      string
+	Location       string
+	BuildID        string
+	ImageTag       string
+	LogsObject     string
+	Backend        string
+	BackendID      string
+	Spot           bool
+	Relaunches     int
+	Lifecycle      lifecycle.Lifecycle
+	Stalled        bool
+
+	// RunnerGroupID is the organization runner group the runner was
+	// registered with, 0 for a repository runner.
+	RunnerGroupID int64
+
+	// Reusable runners serve several jobs, JobsServed counts the jobs they
+	// completed.
+	Reusable   bool
+	JobsServed int
+}
+
+// runnerReservation is a runner counted against the repository and
+// organization limits while it is being provisioned.
+type runnerReservation struct {
+	Org  string
+	Repo string
+}
+
+// runnerTracker keeps track of the runners provisioned by this instance,
+// and with a state store those restored from it, keyed by runner name, from
+// dispatch until their job completes. A nil tracker is valid and tracks
+// nothing.
+type runnerTracker struct {
+	mu           sync.Mutex
+	runners      map[string]*trackedRunner
+	reservations map[string]runnerReservation
+}
+
+func newRunnerTracker() *runnerTracker {
+	return &runnerTracker{
+		runners:      make(map[string]*trackedRunner),
+		reservations: make(map[string]runnerReservation),
+	}
+}
+
+// Dispatched records a newly provisioned runner, taking over its
+// reservation.
+func (t *runnerTracker) Dispatched(r *trackedRunner) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.runners[r.RunnerName] = r
+	delete(t.reservations, r.RunnerName)
+}
+
+// Reserve counts the runner against the repository and organization until it
+// is dispatched or released, if allow returns true given the number of
+// runners, tracked or reserved, the repository and organization already
+// have. Checking and reserving in one step keeps concurrent requests from
+// all passing the same check. It reports whether the runner was reserved.
+func (t *runnerTracker) Reserve(runnerName, org, repo string, allow func(repoRunners, orgRunners int) bool) bool {
+	if t == nil {
+		return allow(0, 0)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	repoRunners, orgRunners := 0, 0
+	count := func(name, o, r string) {
+		if name == runnerName || !strings.EqualFold(o, org) {
+			return
+		}
+		orgRunners++
+		if strings.EqualFold(r, repo) {
+			repoRunners++
+		}
+	}
+	for _, r := range t.runners {
+		count(r.RunnerName, r.Org, r.Repo)
+	}
+	for name, r := range t.reservations {
+		count(name, r.Org, r.Repo)
+	}
+
+	if !allow(repoRunners, orgRunners) {
+		return false
+	}
+	t.reservations[runnerName] = runnerReservation{Org: org, Repo: repo}
+	return true
+}
+
+// Release drops the reservation of the runner, if it was not dispatched.
+func (t *runnerTracker) Release(runnerName string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.reservations, runnerName)
+}
+
+// Transition moves the runner to the given lifecycle state and returns the
+// state it left. It returns errRunnerNotTracked if the runner is not tracked
+// by this instance.
+func (t *runnerTracker) Transition(runnerName string, to lifecycle.State, at time.Time) (lifecycle.State, error) {
+	if t == nil {
+		return "", errRunnerNotTracked
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.runners[runnerName]
+	if !ok {
+		return "", errRunnerNotTracked
+	}
+	from := r.Lifecycle.State()
+	return from, r.Lifecycle.Transition(to, at)
+}
+
+// Update applies fn to the tracked runner. It returns false if the runner is
+// not tracked by this instance.
+func (t *runnerTracker) Update(runnerName string, fn func(r *trackedRunner)) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.runners[runnerName]
+	if !ok {
+		return false
+	}
+	fn(r)
+	return true
+}
+
+// Remove stops tracking the runner and returns its last known state.
+func (t *runnerTracker) Remove(runnerName string) (trackedRunner, bool) {
+	if t == nil {
+		return trackedRunner{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.runners[runnerName]
+	if !ok {
+		return trackedRunner{}, false
+	}
+	delete(t.runners, runnerName)
+	return *r, true
+}
+
+// List returns a snapshot of all tracked runners.
+func (t *runnerTracker) List() []trackedRunner {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]trackedRunner, 0, len(t.runners))
+	for _, r := range t.runners {
+		out = append(out, *r)
+	}
+	return out
+}
+
+// Count returns the number of tracked runners.
+func (t *runnerTracker) Count() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.runners)
+}
+
+// CountActor returns the number of tracked runners requested by the actor,
+// compared case-insensitively.
+func (t *runnerTracker) CountActor(actor string) int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, r := range t.runners {
+		if strings.EqualFold(r.Actor, actor) {
+			n++
+		}
+	}
+	return n
+}
+
+// CountRepo returns the number of tracked and reserved runners of the
+// repository, compared case-insensitively.
+func (t *runnerTracker) CountRepo(org, repo string) int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, r := range t.runners {
+		if strings.EqualFold(r.Org, org) && strings.EqualFold(r.Repo, repo) {
+			n++
+		}
+	}
+	for _, r := range t.reservations {
+		if strings.EqualFold(r.Org, org) && strings.EqualFold(r.Repo, repo) {
+			n++
+		}
+	}
+	return n
+}
+
+// CountOrg returns the number of tracked and reserved runners of the
+// organization, compared case-insensitively.
+func (t *runnerTracker) CountOrg(org string) int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, r := range t.runners {
+		if strings.EqualFold(r.Org, org) {
+			n++
+		}
+	}
+	for _, r := range t.reservations {
+		if strings.EqualFold(r.Org, org) {
+			n++
+		}
+	}
+	return n
+}
+
+// CountProject returns the number of tracked runners whose build runs in the
+// project.
+func (t *runnerTracker) CountProject(projectID string) int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, r := range t.runners {
+		if r.ProjectID == projectID {
+			n++
+		}
+	}
+	return n
+}
+
+// transitionRunner moves a tracked runner to the given lifecycle state and
+// counts the transition. Runners provisioned by other instances are ignored
+// and invalid transitions are logged, events can arrive out of order.
+func (s *Server) transitionRunner(ctx context.Context, runnerName string, to lifecycle.State) {
+	from, err := s.runners.Transition(runnerName, to, time.Now())
+	switch {
+	case errors.Is(err, errRunnerNotTracked):
+		return
+	case err != nil:
+		logging.FromContext(ctx).WarnContext(ctx, "ignoring runner lifecycle transition",
+			"runner_id", runnerName,
+			"error", err)
+		return
+	}
+	s.metrics.recordRunnerTransition(from, to)
+	s.saveRunnerState(ctx, runnerName)
+}
+
+// transitionLifecycle moves the lifecycle of a runner that is not tracked yet
+// to the given state and counts the transition.
+func (s *Server) transitionLifecycle(l *lifecycle.Lifecycle, to lifecycle.State, at time.Time) {
+	from := l.State()
+	if err := l.Transition(to, at); err != nil {
+		return
+	}
+	s.metrics.recordRunnerTransition(from, to)
+}
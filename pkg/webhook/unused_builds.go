@@ -0,0 +1,249 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// cancelUnusedBuilds cancels the runners launched for a completed job that
+// did not take it, such as a runner still pulling its image when the job was
+// cancelled or picked up by another runner. Each runner is removed from
+// GitHub before its build or VM is cancelled, GitHub refuses to remove a
+// runner that took another job, whose in_progress event may not have
+// arrived yet, and the runner is left alone. Failures are only logged, an
+// unused runner eventually times out.
+func (s *Server) cancelUnusedBuilds(ctx context.Context, event *github.WorkflowJobEvent, logFields []any) {
+	// The runners belong to the deployment being shadowed.
+	if !s.cancelUnused || s.shadowMode {
+		return
+	}
+
+	jobID := event.GetWorkflowJob().GetID()
+	ranBy := event.GetWorkflowJob().GetRunnerName()
+	if jobID == 0 {
+		return
+	}
+
+	tracked := false
+	for _, r := range s.runners.List() {
+		if r.JobID != jobID || r.RunnerName == ranBy {
+			continue
+		}
+		tracked = true
+		// A reusable runner that served other jobs is not unused.
+		if r.Lifecycle.State() == lifecycle.StateRunning || r.JobsServed > 0 {
+			continue
+		}
+		s.cancelUnusedRunner(ctx, r, logFields)
+	}
+
+	// Runners dispatched before a restart, or by another instance, are found
+	// from their stored state, or else from the tags of their build.
+	if !tracked && !s.cancelUnusedStoredRunners(ctx, jobID, ranBy, logFields) {
+		s.cancelUnusedTaggedBuilds(ctx, event, logFields)
+	}
+}
+
+// cancelUnusedStoredRunners cancels the runners the state store recorded for
+// the completed job that did not take it and did not finish. It returns false
+// when the store has no runners for the job.
+func (s *Server) cancelUnusedStoredRunners(ctx context.Context, jobID int64, ranBy string, logFields []any) bool {
+	if s.stateStore == nil {
+		return false
+	}
+	logger := logging.FromContext(ctx)
+
+	states, err := s.stateStore.JobRunners(ctx, jobID)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to read stored runners of completed job", append(logFields, "error", err)...)
+		return false
+	}
+	if len(states) == 0 {
+		return false
+	}
+
+	for _, state := range states {
+		if state.RunnerName == ranBy || state.State == lifecycle.StateRunning || state.State.Terminal() {
+			continue
+		}
+
+		fields := append(append([]any{}, logFields...), "unused_runner_id", state.RunnerName, "backend", state.Backend, "backend_id", state.BackendID)
+		backend := s.backendNamed(state.Backend)
+		if backend == nil || state.BackendID == "" {
+			continue
+		}
+		if !s.deregisterUnusedRunner(ctx, &trackedRunner{
+			RunnerName:     state.RunnerName,
+			InstallationID: state.InstallationID,
+			Org:            state.Org,
+			Repo:           state.Repo,
+			RunnerGroupID:  state.RunnerGroupID,
+		}, fields) {
+			continue
+		}
+		if err := backend.Cancel(ctx, state.BackendID); err != nil {
+			logger.WarnContext(ctx, "failed to cancel unused runner", append(fields, "error", err)...)
+			continue
+		}
+
+		state.State = lifecycle.StateOrphaned
+		state.EnteredAt[lifecycle.StateOrphaned] = time.Now()
+		if err := s.stateStore.PutRunner(ctx, state); err != nil {
+			logger.WarnContext(ctx, "failed to save runner state", append(fields, "error", err)...)
+		}
+		logger.InfoContext(ctx, "cancelled unused runner of completed job", fields...)
+	}
+	return true
+}
+
+// cancelUnusedRunner cancels a tracked runner that did not take its job.
+func (s *Server) cancelUnusedRunner(ctx context.Context, r trackedRunner, logFields []any) {
+	logger := logging.FromContext(ctx)
+
+	logFields = append(logFields, "unused_runner_id", r.RunnerName, "backend", r.Backend, "backend_id", r.BackendID)
+	backend := s.backendNamed(r.Backend)
+	if backend == nil || r.BackendID == "" {
+		return
+	}
+	if !s.deregisterUnusedRunner(ctx, &r, logFields) {
+		return
+	}
+	if err := backend.Cancel(ctx, r.BackendID); err != nil {
+		logger.WarnContext(ctx, "failed to cancel unused runner", append(logFields, "error", err)...)
+		return
+	}
+	s.transitionRunner(ctx, r.RunnerName, lifecycle.StateOrphaned)
+	s.runners.Remove(r.RunnerName)
+	s.launchQueue.notify()
+	logger.InfoContext(ctx, "cancelled unused runner of completed job", logFields...)
+}
+
+// cancelUnusedTaggedBuilds cancels the unfinished builds tagged with the
+// completed job in every runner project and location whose runner is not
+// busy.
+func (s *Server) cancelUnusedTaggedBuilds(ctx context.Context, event *github.WorkflowJobEvent, logFields []any) {
+	logger := logging.FromContext(ctx)
+
+	installationID := event.GetInstallation().GetID()
+	org, repo := event.GetOrg().GetLogin(), event.GetRepo().GetName()
+	for _, placement := range s.runnerBuildPlacements() {
+		builds, err := s.cbc.ListBuilds(ctx, &cloudbuildpb.ListBuildsRequest{
+			Parent:    placement.parent(),
+			ProjectId: placement.ProjectID,
+			Filter:    fmt.Sprintf("tags=%q", "job-"+strconv.FormatInt(event.GetWorkflowJob().GetID(), 10)),
+		})
+		if err != nil {
+			logger.WarnContext(ctx, "failed to list builds of completed job",
+				append(logFields, "runner_project_id", placement.ProjectID, "runner_location", placement.Location, "error", err)...)
+			continue
+		}
+
+		for _, build := range builds {
+			switch build.GetStatus() {
+			case cloudbuildpb.Build_PENDING, cloudbuildpb.Build_QUEUED, cloudbuildpb.Build_WORKING:
+			default:
+				continue
+			}
+			runnerName := buildRunnerName(build)
+			if runnerName == "" || runnerName == event.GetWorkflowJob().GetRunnerName() {
+				continue
+			}
+
+			fields := append(append([]any{}, logFields...), "unused_runner_id", runnerName, "build_id", build.GetId(),
+				"runner_project_id", placement.ProjectID, "runner_location", placement.Location)
+			// The runner is looked up where it was registered.
+			groupID, err := s.buildRunnerGroupID(ctx, build, installationID, org, repo)
+			if err != nil {
+				logger.WarnContext(ctx, "failed to determine runner group of unused runner", append(fields, "error", err)...)
+				continue
+			}
+			if !s.deregisterUnusedRunner(ctx, &trackedRunner{
+				RunnerName:     runnerName,
+				InstallationID: installationID,
+				Org:            org,
+				Repo:           repo,
+				RunnerGroupID:  groupID,
+			}, fields) {
+				continue
+			}
+
+			if err := s.cloudBuildBackend().Cancel(ctx, placement.buildName(build.GetId())); err != nil {
+				logger.WarnContext(ctx, "failed to cancel unused runner", append(fields, "error", err)...)
+				continue
+			}
+			logger.InfoContext(ctx, "cancelled unused runner of completed job", fields...)
+		}
+	}
+}
+
+// deregisterUnusedRunner removes an unused runner from GitHub and reports
+// whether its build or VM can be cancelled. A busy runner took another job
+// and is left alone.
+func (s *Server) deregisterUnusedRunner(ctx context.Context, r *trackedRunner, logFields []any) bool {
+	if _, err := s.deregisterRunner(ctx, r); err != nil {
+		if !errors.Is(err, errRunnerBusy) {
+			logging.FromContext(ctx).WarnContext(ctx, "failed to remove unused runner", append(logFields, "error", err)...)
+		}
+		return false
+	}
+	return true
+}
+
+// buildRunnerGroupID returns the organization runner group the runner of the
+// build was registered with, 0 for a repository runner. Builds without a
+// group tag predate it, their group is mapped from the repository again.
+func (s *Server) buildRunnerGroupID(ctx context.Context, build *cloudbuildpb.Build, installationID int64, org, repo string) (int64, error) {
+	for _, tag := range build.GetTags() {
+		if v, ok := strings.CutPrefix(tag, "group-"); ok {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid runner group tag %q: %w", tag, err)
+			}
+			return id, nil
+		}
+	}
+
+	mapping, err := s.runnerGroupMapping(ctx, installationID, org, repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to map repository to a runner group: %w", err)
+	}
+	if mapping == nil {
+		return 0, nil
+	}
+	return mapping.RunnerGroupID, nil
+}
+
+// buildRunnerName returns the name of the runner the build runs, from its
+// tags.
+func buildRunnerName(build *cloudbuildpb.Build) string {
+	for _, tag := range build.GetTags() {
+		if name, ok := strings.CutPrefix(tag, "runner-"); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,448 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/githubauth"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+	"github.com/google/github_actions_on_gcp/pkg/testing/fakecloudbuild"
+)
+
+// newCompletedJob returns a completed job event of google/webhook run by the
+// runner.
+func newCompletedJob(jobID int64, runnerName string) *github.WorkflowJobEvent {
+	return &github.WorkflowJobEvent{
+		Action: github.Ptr("completed"),
+		WorkflowJob: &github.WorkflowJob{
+			ID:         github.Ptr(jobID),
+			RunnerName: github.Ptr(runnerName),
+		},
+		Installation: &github.Installation{ID: github.Ptr(int64(123))},
+		Org:          &github.Organization{Login: github.Ptr("google")},
+		Repo:         &github.Repository{Name: github.Ptr("webhook")},
+	}
+}
+
+// trackedRunnerIn returns a runner of job 1 whose build is in the state.
+func trackedRunnerIn(t *testing.T, name string, state lifecycle.State) *trackedRunner {
+	t.Helper()
+
+	l := lifecycle.New(time.Now())
+	for _, s := range []lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning, lifecycle.StateRunning} {
+		if l.State() == state {
+			break
+		}
+		if err := l.Transition(s, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &trackedRunner{
+		RunnerName: name,
+		JobID:      1,
+		Backend:    runnerBackendCloudBuild,
+		BackendID:  "projects/runner-project/locations/us-central1/builds/" + name,
+		Lifecycle:  l,
+	}
+}
+
+// fakeRunnerRegistry is a fake GitHub API registering runners with the
+// google/webhook repository or the google organization. Like GitHub, it
+// refuses to remove a busy runner.
+type fakeRunnerRegistry struct {
+	mu      sync.Mutex
+	runners map[string]*fakeRegisteredRunner
+	removed []string
+}
+
+type fakeRegisteredRunner struct {
+	id   int64
+	org  bool
+	busy bool
+	// takesJob makes the runner pick up a job as it is being removed.
+	takesJob bool
+}
+
+// newFakeRunnerRegistry starts the fake GitHub API and returns an app
+// authenticating with it and its URL.
+func newFakeRunnerRegistry(t *testing.T) (*fakeRunnerRegistry, *githubauth.App, string) {
+	t.Helper()
+
+	f := &fakeRunnerRegistry{runners: make(map[string]*fakeRegisteredRunner)}
+	list := func(org bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			name := r.URL.Query().Get("name")
+			if reg, ok := f.runners[name]; ok && reg.org == org {
+				fmt.Fprintf(w, `{"total_count": 1, "runners": [{"id": %d, "name": %q, "status": "online", "busy": %t}]}`,
+					reg.id, name, reg.busy)
+				return
+			}
+			fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+		}
+	}
+	remove := func(org bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			for name, reg := range f.runners {
+				if fmt.Sprint(reg.id) != r.PathValue("id") || reg.org != org {
+					continue
+				}
+				if reg.busy || reg.takesJob {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					fmt.Fprintf(w, `{"message": "Bad request - Runner %q is still running a job"}`, name)
+					return
+				}
+				delete(f.runners, name)
+				f.removed = append(f.removed, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
+	}))
+	mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
+	}))
+	mux.Handle("GET /repos/google/webhook/actions/runners", list(false))
+	mux.Handle("GET /orgs/google/actions/runners", list(true))
+	mux.Handle("DELETE /repos/google/webhook/actions/runners/{id}", remove(false))
+	mux.Handle("DELETE /orgs/google/actions/runners/{id}", remove(true))
+	fakeGitHub := httptest.NewServer(mux)
+	t.Cleanup(fakeGitHub.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := githubauth.NewApp("app-id", key, githubauth.WithBaseURL(fakeGitHub.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, app, fakeGitHub.URL
+}
+
+// register registers the runner with the repository, or with the
+// organization if org is set.
+func (f *fakeRunnerRegistry) register(name string, org, busy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.runners[name] = &fakeRegisteredRunner{id: int64(len(f.runners) + len(f.removed) + 1), org: org, busy: busy}
+}
+
+// takeJobOnRemove makes the registered runner pick up a job between being
+// looked up and being removed.
+func (f *fakeRunnerRegistry) takeJobOnRemove(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.runners[name].takesJob = true
+}
+
+// removedRunners returns the sorted names of the runners removed so far.
+func (f *fakeRunnerRegistry) removedRunners() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Sorted(slices.Values(f.removed))
+}
+
+func TestCancelUnusedBuilds_Tracked(t *testing.T) {
+	t.Parallel()
+
+	registry, app, ghURL := newFakeRunnerRegistry(t)
+	registry.register("GCP-pulling", false, false)
+	// The in_progress event of the other job has not arrived yet.
+	registry.register("GCP-took-other-job", false, true)
+	registry.register("GCP-grouped", true, false)
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		appClient:    app,
+		cancelUnused: true,
+		cbc:          cbc,
+		ghAPIBaseURL: ghURL,
+		runners:      newRunnerTracker(),
+	}
+	for _, r := range []*trackedRunner{
+		trackedRunnerIn(t, "GCP-pulling", lifecycle.StateProvisioning),
+		trackedRunnerIn(t, "GCP-took-other-job", lifecycle.StateProvisioning),
+		trackedRunnerIn(t, "GCP-grouped", lifecycle.StateProvisioning),
+		trackedRunnerIn(t, "GCP-other-job", lifecycle.StateRunning),
+		trackedRunnerIn(t, "GCP-ran-job", lifecycle.StateRunning),
+	} {
+		r.InstallationID, r.Org, r.Repo = 123, "google", "webhook"
+		if r.RunnerName == "GCP-grouped" {
+			r.RunnerGroupID = 3
+		}
+		srv.runners.Dispatched(r)
+	}
+
+	srv.cancelUnusedBuilds(t.Context(), newCompletedJob(1, "GCP-ran-job"), nil)
+
+	var got []string
+	for _, req := range cbc.cancelBuildReqs {
+		got = append(got, req.GetId())
+	}
+	slices.Sort(got)
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-pulling"}, got); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-pulling"}, registry.removedRunners()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if got, want := srv.runners.Count(), 3; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if len(cbc.listBuildsReqs) != 0 {
+		t.Errorf("expected no builds to be listed for a tracked job")
+	}
+}
+
+func TestCancelUnusedBuilds_Tagged(t *testing.T) {
+	t.Parallel()
+
+	registry, app, ghURL := newFakeRunnerRegistry(t)
+	registry.register("GCP-queued", false, false)
+	registry.register("GCP-idle", false, false)
+	registry.register("GCP-busy", false, true)
+	registry.register("GCP-ran-job", false, true)
+	registry.register("GCP-grouped-busy", true, true)
+	registry.register("GCP-grouped-idle", true, false)
+
+	cbc := &MockCloudBuildClient{
+		listBuildsRes: []*cloudbuildpb.Build{
+			{Id: "queued", Status: cloudbuildpb.Build_QUEUED, Tags: []string{"runner-GCP-queued", "job-1"}},
+			{Id: "idle", Status: cloudbuildpb.Build_WORKING, Tags: []string{"runner-GCP-idle", "job-1"}},
+			{Id: "busy", Status: cloudbuildpb.Build_WORKING, Tags: []string{"runner-GCP-busy", "job-1"}},
+			{Id: "ran-job", Status: cloudbuildpb.Build_WORKING, Tags: []string{"runner-GCP-ran-job", "job-1"}},
+			{Id: "finished", Status: cloudbuildpb.Build_SUCCESS, Tags: []string{"runner-GCP-finished", "job-1"}},
+			// Organization runners are looked up in the organization.
+			{Id: "grouped-busy", Status: cloudbuildpb.Build_WORKING, Tags: []string{"runner-GCP-grouped-busy", "job-1", "group-3"}},
+			{Id: "grouped-idle", Status: cloudbuildpb.Build_WORKING, Tags: []string{"runner-GCP-grouped-idle", "job-1", "group-3"}},
+		},
+	}
+	srv := &Server{
+		appClient:       app,
+		cancelUnused:    true,
+		cbc:             cbc,
+		ghAPIBaseURL:    ghURL,
+		runnerLocation:  "us-central1",
+		runnerProjectID: "runner-project",
+		runners:         newRunnerTracker(),
+	}
+
+	srv.cancelUnusedBuilds(t.Context(), newCompletedJob(1, "GCP-ran-job"), nil)
+
+	if got, want := len(cbc.listBuildsReqs), 1; got != want {
+		t.Fatalf("expected %d to be %d", got, want)
+	}
+	if got, want := cbc.listBuildsReqs[0].GetFilter(), `tags="job-1"`; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := cbc.listBuildsReqs[0].GetParent(), "projects/runner-project/locations/us-central1"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	var got []string
+	for _, req := range cbc.cancelBuildReqs {
+		got = append(got, req.GetName())
+	}
+	want := []string{
+		"projects/runner-project/locations/us-central1/builds/queued",
+		"projects/runner-project/locations/us-central1/builds/idle",
+		"projects/runner-project/locations/us-central1/builds/grouped-idle",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GCP-grouped-idle", "GCP-idle", "GCP-queued"}, registry.removedRunners()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+}
+
+func TestCancelUnusedBuilds_TaggedPlacements(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	fake, opts := fakecloudbuild.Start(t)
+	cb, err := NewCloudBuild(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cb.Close() })
+
+	for _, name := range []string{
+		"projects/routed-project/locations/europe-west1/builds/routed",
+		"projects/runner-project/locations/us-east1/builds/fallback",
+	} {
+		fake.AddBuild(&cloudbuildpb.Build{
+			Id:     name[strings.LastIndex(name, "/")+1:],
+			Name:   name,
+			Status: cloudbuildpb.Build_QUEUED,
+			Tags:   []string{"runner-GCP-" + name[strings.LastIndex(name, "/")+1:], "job-1"},
+		})
+	}
+
+	// The runners never started, they are not registered.
+	_, app, ghURL := newFakeRunnerRegistry(t)
+	srv := &Server{
+		appClient:               app,
+		cancelUnused:            true,
+		cbc:                     cb,
+		ghAPIBaseURL:            ghURL,
+		runnerFallbackLocations: []string{"us-east1"},
+		runnerLocation:          "us-central1",
+		runnerProjectID:         "runner-project",
+		runnerProjectRoutes: map[string]*RunnerProjectRoute{
+			"google": {ProjectID: "routed-project", Location: "europe-west1"},
+		},
+		runners: newRunnerTracker(),
+	}
+
+	srv.cancelUnusedBuilds(ctx, newCompletedJob(1, "GCP-ran-job"), nil)
+
+	var got []string
+	for _, req := range fake.Requests(fakecloudbuild.MethodCancelBuild) {
+		got = append(got, req.(*cloudbuildpb.CancelBuildRequest).GetName())
+	}
+	slices.Sort(got)
+	want := []string{
+		"projects/routed-project/locations/europe-west1/builds/routed",
+		"projects/runner-project/locations/us-east1/builds/fallback",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+}
+
+func TestCancelUnusedBuilds_Stored(t *testing.T) {
+	t.Parallel()
+
+	registry, app, ghURL := newFakeRunnerRegistry(t)
+	registry.register("GCP-pulling", false, false)
+	// Stored as provisioning by the instance that dispatched it, the runner
+	// took another job whose in_progress event went to another instance.
+	registry.register("GCP-took-other-job", false, true)
+	registry.register("GCP-grouped", true, false)
+
+	store := newMemStateStore()
+	for name, state := range map[string]lifecycle.State{
+		"GCP-pulling":        lifecycle.StateProvisioning,
+		"GCP-took-other-job": lifecycle.StateProvisioning,
+		"GCP-grouped":        lifecycle.StateProvisioning,
+		"GCP-other-job":      lifecycle.StateRunning,
+		"GCP-failed":         lifecycle.StateFailed,
+		"GCP-ran-job":        lifecycle.StateRunning,
+	} {
+		var groupID int64
+		if name == "GCP-grouped" {
+			groupID = 3
+		}
+		if err := store.PutRunner(t.Context(), &RunnerState{
+			RunnerName:     name,
+			InstallationID: 123,
+			Org:            "google",
+			Repo:           "webhook",
+			JobID:          1,
+			Backend:        runnerBackendCloudBuild,
+			BackendID:      "projects/runner-project/locations/us-central1/builds/" + name,
+			State:          state,
+			RunnerGroupID:  groupID,
+			EnteredAt:      make(map[lifecycle.State]time.Time),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		appClient:    app,
+		cancelUnused: true,
+		cbc:          cbc,
+		ghAPIBaseURL: ghURL,
+		runners:      newRunnerTracker(),
+		stateStore:   store,
+	}
+
+	srv.cancelUnusedBuilds(t.Context(), newCompletedJob(1, "GCP-ran-job"), nil)
+
+	var got []string
+	for _, req := range cbc.cancelBuildReqs {
+		got = append(got, req.GetId())
+	}
+	slices.Sort(got)
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-pulling"}, got); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-pulling"}, registry.removedRunners()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if len(cbc.listBuildsReqs) != 0 {
+		t.Errorf("expected no builds to be listed for a stored job")
+	}
+
+	state, err := store.GetRunner(t.Context(), "GCP-pulling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := state.State, lifecycle.StateOrphaned; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+}
+
+func TestCancelUnusedBuilds_Disabled(t *testing.T) {
+	t.Parallel()
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		cbc:     cbc,
+		runners: newRunnerTracker(),
+	}
+	srv.runners.Dispatched(trackedRunnerIn(t, "GCP-pulling", lifecycle.StateProvisioning))
+
+	srv.cancelUnusedBuilds(t.Context(), newCompletedJob(1, "GCP-ran-job"), nil)
+
+	if len(cbc.cancelBuildReqs) != 0 || len(cbc.listBuildsReqs) != 0 {
+		t.Errorf("expected no builds to be cancelled or listed")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+)
+
+// Metadata keys of the VMs runners run on. The VM reads its JIT configuration
+// from jit-config, or from the Secret Manager secret version named by
+// jit-config-secret when JIT configuration secrets are enabled, the secrets of
+// the runner listed by runner-secrets, and runs runner-command.
+const (
+	vmRunnerNameKey      = "runner-name"
+	vmJITConfigKey       = "jit-config"
+	vmJITConfigSecretKey = "jit-config-secret"
+	vmRunnerImageKey     = "runner-image"
+	vmRunnerCommandKey   = "runner-command"
+	vmRunnerSecretsKey   = "runner-secrets"
+	vmStartupScriptKey   = "startup-script"
+)
+
+// vmToolcacheDevice is the device name of the tool cache disk of runner VMs,
+// which the startup script mounts into the runner.
+const vmToolcacheDevice = "toolcache"
+
+// vmStartupScript runs the runner of an ephemeral VM.
+//
+//go:embed vmstartup.sh
+var vmStartupScript string
+
+// VMClient adheres to the interaction the webhook service has with Compute
+// Engine to run runners on ephemeral VMs.
+type VMClient interface {
+	CreateVM(ctx context.Context, vm *RunnerVM) error
+	VMPreempted(ctx context.Context, project, zone, name string) (bool, error)
+	DeleteVM(ctx context.Context, project, zone, name string) error
+}
+
+// RunnerVM is an ephemeral VM created from an instance template to run a
+// runner.
+type RunnerVM struct {
+	Project  string
+	Zone     string
+	Name     string
+	Template string
+	Metadata map[string]string
+	Labels   map[string]string
+
+	// Spot runs the VM on spot capacity, which Compute Engine may preempt at
+	// any time.
+	Spot bool
+
+	// Accelerator is the type of the GPU attached to the VM, "" to keep the
+	// accelerators of the template. The machine type of the template must
+	// support it.
+	Accelerator string
+
+	// MaxRunSeconds is how long the VM may run before Compute Engine deletes
+	// it, 0 for no limit.
+	MaxRunSeconds int64
+
+	// ToolcacheSnapshot is the snapshot the tool cache disk of the VM is
+	// created from, "" for none. The disk is deleted with the VM.
+	ToolcacheSnapshot string
+}
+
+// runnerVMMetadata returns the metadata handing a VM the runner of the build:
+// its JIT configuration, or the secret version holding it, the runner image
+// and the command running it. The command is the run step of the build with
+// its substitutions resolved, so the runner gets the same environment as on
+// Cloud Build. The build's Cloud Build network and volumes do not exist on
+// VMs, and the other steps of the build are not run. The startup script passes
+// the tool cache disk of the VM, if any, in place of the tool cache volume.
+func runnerVMMetadata(build *cloudbuildpb.Build, runnerName string) map[string]string {
+	subs := maps.Clone(build.GetSubstitutions())
+	if subs == nil {
+		subs = make(map[string]string)
+	}
+	subs["_DOCKER_NETWORK"] = "bridge"
+	subs["_TOOLCACHE_ARGS"] = "$TOOLCACHE_ARGS"
+	subs["_CACHE_MOUNTS"] = ""
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			if v, ok := subs[key]; ok {
+				return v
+			}
+			return "$" + key
+		})
+	}
+
+	metadata := map[string]string{
+		vmRunnerNameKey:  runnerName,
+		vmRunnerImageKey: expand("$_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG"),
+	}
+	secretEnv := buildSecretEnv(build)
+	if version, ok := secretEnv[jitConfigEnv]; ok {
+		metadata[vmJITConfigSecretKey] = version
+		delete(secretEnv, jitConfigEnv)
+	} else {
+		metadata[vmJITConfigKey] = subs["_ENCODED_JIT_CONFIG"]
+	}
+	if len(secretEnv) > 0 {
+		secrets := make([]string, 0, len(secretEnv))
+		for _, name := range slices.Sorted(maps.Keys(secretEnv)) {
+			secrets = append(secrets, name+"="+secretEnv[name])
+		}
+		metadata[vmRunnerSecretsKey] = strings.Join(secrets, ",")
+	}
+
+	i := slices.IndexFunc(build.GetSteps(), func(step *cloudbuildpb.BuildStep) bool {
+		return step.GetId() == "run"
+	})
+	if i >= 0 {
+		if args := build.GetSteps()[i].GetArgs(); len(args) > 0 {
+			metadata[vmRunnerCommandKey] = expand(args[len(args)-1])
+		}
+	}
+	return metadata
+}
+
+// vmBackend runs each runner on a new ephemeral VM of the runner project,
+// created from the runner instance template. Its IDs are the full names of the
+// VMs.
+type vmBackend struct {
+	vms      VMClient
+	template string
+	zone     string
+	timeout  time.Duration
+
+	// arm64Template is the instance template of arm64 runner VMs.
+	arm64Template string
+
+	// toolcacheSnapshot is the snapshot of the tool cache disk of amd64 runner
+	// VMs, the tools it holds do not run on arm64.
+	toolcacheSnapshot string
+}
+
+// Provision creates the VM. It is limited to the timeout of the build, if any,
+// and deleted when its job completes.
+func (b *vmBackend) Provision(ctx context.Context, spec *RunnerSpec) (string, error) {
+	metadata := runnerVMMetadata(spec.Build, spec.RunnerName)
+	metadata[vmStartupScriptKey] = vmStartupScript
+
+	template, toolcacheSnapshot := b.template, b.toolcacheSnapshot
+	if spec.Arch == runnerArchARM64 {
+		template, toolcacheSnapshot = b.arm64Template, ""
+	}
+
+	vm := &RunnerVM{
+		Project:       spec.ProjectID,
+		Zone:          b.zone,
+		Name:          runnerInstanceName(spec.RunnerName),
+		Template:      template,
+		Metadata:      metadata,
+		Labels:        map[string]string{"managed-by": "github-actions-on-gcp"},
+		MaxRunSeconds: spec.Build.GetTimeout().GetSeconds(),
+		Spot:          spec.Spot,
+		Accelerator:   spec.Accelerator,
+
+		ToolcacheSnapshot: toolcacheSnapshot,
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.vms.CreateVM(cctx, vm); err != nil {
+		return "", fmt.Errorf("failed to create runner vm: %w", err)
+	}
+	return fmt.Sprintf("projects/%s/zones/%s/instances/%s", vm.Project, vm.Zone, vm.Name), nil
+}
+
+func (b *vmBackend) Preempted(ctx context.Context, id string) (bool, error) {
+	parts, err := vmNameParts(id)
+	if err != nil {
+		return false, err
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	preempted, err := b.vms.VMPreempted(cctx, parts[1], parts[3], parts[5])
+	if err != nil {
+		return false, fmt.Errorf("failed to check runner vm preemption: %w", err)
+	}
+	return preempted, nil
+}
+
+func (b *vmBackend) Cancel(ctx context.Context, id string) error {
+	parts, err := vmNameParts(id)
+	if err != nil {
+		return err
+	}
+
+	cctx, cancel := callContext(ctx, b.timeout)
+	defer cancel()
+	if err := b.vms.DeleteVM(cctx, parts[1], parts[3], parts[5]); err != nil {
+		return fmt.Errorf("failed to delete runner vm: %w", err)
+	}
+	return nil
+}
+
+// vmNameParts splits the full name of a VM in the form
+// projects/<project>/zones/<zone>/instances/<name>.
+func vmNameParts(name string) ([]string, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "zones" || parts[4] != "instances" {
+		return nil, fmt.Errorf("%q is not of the form projects/<project>/zones/<zone>/instances/<name>", name)
+	}
+	return parts, nil
+}
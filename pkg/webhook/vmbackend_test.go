@@ -0,0 +1,226 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/testutil"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerVMMetadata(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerImageName:       "default-runner",
+		runnerImageTag:        "latest",
+		runnerRepositoryID:    "us-docker.pkg.dev/p/runners",
+		runnerToolcacheBucket: "toolcache",
+		runnerHTTPProxy:       "http://proxy:3128",
+	}
+	build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+
+	got := runnerVMMetadata(build, "GCP-2")
+	if got, want := got["runner-name"], "GCP-2"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := got["runner-image"], "us-docker.pkg.dev/p/runners/default-runner:latest"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+	if got, want := got["jit-config"], "encoded-jit-config"; got != want {
+		t.Errorf("expected %q to be %q", got, want)
+	}
+
+	command := got["runner-command"]
+	for _, want := range []string{
+		"--network=bridge ",
+		"-e ENCODED_JIT_CONFIG ",
+		"-e HTTP_PROXY=http://proxy:3128 ",
+		" $TOOLCACHE_ARGS ",
+		" us-docker.pkg.dev/p/runners/default-runner:latest",
+	} {
+		if !strings.Contains(command, want) {
+			t.Errorf("expected command %q to contain %q", command, want)
+		}
+	}
+	// The JIT configuration is read from the metadata by the startup script,
+	// not passed in the command.
+	if strings.Contains(command, "encoded-jit-config") || strings.Contains(command, "$_") {
+		t.Errorf("expected command %q to have its substitutions resolved without the JIT config", command)
+	}
+}
+
+func TestVMBackendProvision(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		timeout      time.Duration
+		secret       string
+		spot         bool
+		accelerator  string
+		arch         string
+		snapshot     string
+		createErr    error
+		wantVM       string
+		wantTemplate string
+		wantMaxRun   int64
+		wantSnapshot string
+		wantJITKeys  []string
+		wantErr      string
+	}{
+		{
+			name:        "jit_config",
+			wantVM:      "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantJITKeys: []string{"jit-config"},
+		},
+		{
+			name:        "jit_config_secret",
+			secret:      "projects/runner-project/secrets/jit-config-GCP-2/versions/1",
+			wantVM:      "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantJITKeys: []string{"jit-config-secret"},
+		},
+		{
+			name:        "build_timeout",
+			timeout:     6 * time.Hour,
+			wantVM:      "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantMaxRun:  6 * 60 * 60,
+			wantJITKeys: []string{"jit-config"},
+		},
+		{
+			name:        "spot",
+			spot:        true,
+			wantVM:      "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantJITKeys: []string{"jit-config"},
+		},
+		{
+			name:        "gpu",
+			accelerator: "nvidia-tesla-t4",
+			wantVM:      "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantJITKeys: []string{"jit-config"},
+		},
+		{
+			name:         "arm64",
+			arch:         runnerArchARM64,
+			wantVM:       "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantTemplate: "projects/runner-project/global/instanceTemplates/runner-arm64",
+			wantJITKeys:  []string{"jit-config"},
+		},
+		{
+			name:         "toolcache_snapshot",
+			snapshot:     "projects/runner-project/global/snapshots/toolcache",
+			wantVM:       "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantSnapshot: "projects/runner-project/global/snapshots/toolcache",
+			wantJITKeys:  []string{"jit-config"},
+		},
+		{
+			name:         "arm64_toolcache_snapshot",
+			arch:         runnerArchARM64,
+			snapshot:     "projects/runner-project/global/snapshots/toolcache",
+			wantVM:       "projects/runner-project/zones/us-central1-a/instances/gcp-2",
+			wantTemplate: "projects/runner-project/global/instanceTemplates/runner-arm64",
+			wantJITKeys:  []string{"jit-config"},
+		},
+		{
+			name:      "create_error",
+			createErr: fmt.Errorf("quota exceeded"),
+			wantErr:   "failed to create runner vm: quota exceeded",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			vms := &MockVMClient{CreateErr: tc.createErr}
+			b := &vmBackend{
+				vms:      vms,
+				template: "projects/runner-project/global/instanceTemplates/runner",
+				zone:     "us-central1-a",
+
+				arm64Template:     "projects/runner-project/global/instanceTemplates/runner-arm64",
+				toolcacheSnapshot: tc.snapshot,
+			}
+			s := &Server{
+				runnerImageName:    "default-runner",
+				runnerImageTag:     "latest",
+				runnerRepositoryID: "us-docker.pkg.dev/p/runners",
+			}
+			build := s.runnerBuild(&runnerRequest{Org: "google", Repo: "webhook", RunnerName: "GCP-2"}, "encoded-jit-config")
+			if tc.timeout > 0 {
+				build.Timeout = durationpb.New(tc.timeout)
+			}
+			if tc.secret != "" {
+				build.AvailableSecrets = &cloudbuildpb.Secrets{
+					SecretManager: []*cloudbuildpb.SecretManagerSecret{{VersionName: tc.secret, Env: jitConfigEnv}},
+				}
+			}
+
+			got, err := b.Provision(t.Context(), &RunnerSpec{RunnerName: "GCP-2", ProjectID: "runner-project", Spot: tc.spot, Accelerator: tc.accelerator, Arch: tc.arch, Build: build})
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if got, want := got, tc.wantVM; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+
+			if got, want := len(vms.Created), 1; got != want {
+				t.Fatalf("expected %d to be %d", got, want)
+			}
+			vm := vms.Created[0]
+			wantTemplate := "projects/runner-project/global/instanceTemplates/runner"
+			if tc.wantTemplate != "" {
+				wantTemplate = tc.wantTemplate
+			}
+			if got, want := vm.Template, wantTemplate; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := vm.MaxRunSeconds, tc.wantMaxRun; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if got, want := vm.Spot, tc.spot; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+			if got, want := vm.Accelerator, tc.accelerator; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := vm.ToolcacheSnapshot, tc.wantSnapshot; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if vm.Metadata["startup-script"] != vmStartupScript {
+				t.Errorf("expected the startup script to be set")
+			}
+			var gotJITKeys []string
+			for _, key := range []string{"jit-config", "jit-config-secret"} {
+				if _, ok := vm.Metadata[key]; ok {
+					gotJITKeys = append(gotJITKeys, key)
+				}
+			}
+			if diff := cmp.Diff(tc.wantJITKeys, gotJITKeys); diff != "" {
+				t.Errorf("unexpected JIT config keys (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+#!/bin/bash
+# Startup script of the ephemeral VMs runners are dispatched to. It reads the
+# JIT configuration and secrets of the runner from the instance metadata and
+# Secret Manager, runs the runner image with the command the webhook service
+# prepared, and powers the VM off once the runner exits. The webhook service
+# deletes the VM when its job completes.
+set -euo pipefail
+
+METADATA_URL="http://metadata.google.internal/computeMetadata/v1"
+
+metadata() {
+    curl -sSf -H "Metadata-Flavor: Google" "${METADATA_URL}/instance/$1"
+}
+
+access_token() {
+    metadata "service-accounts/default/token" | sed -E 's/.*"access_token":"([^"]+)".*/\1/'
+}
+
+secret_value() {
+    curl -sSf -H "Authorization: Bearer $(access_token)" \
+        "https://secretmanager.googleapis.com/v1/$1:access" |
+        sed -E 's/.*"data": *"([^"]+)".*/\1/' | base64 -d
+}
+
+# The VM is single use, a runner that failed to start does not get a retry.
+trap 'poweroff' EXIT
+
+if ! ENCODED_JIT_CONFIG="$(metadata "attributes/jit-config" 2>/dev/null)"; then
+    ENCODED_JIT_CONFIG="$(secret_value "$(metadata "attributes/jit-config-secret")")"
+fi
+export ENCODED_JIT_CONFIG
+
+# The runner command passes the secrets on to the runner container. They are
+# listed as <name>=<secret version>, separated by commas. Like on Cloud Build,
+# the runner does not start when a secret cannot be read.
+IFS=',' read -ra RUNNER_SECRETS <<< "$(metadata "attributes/runner-secrets" 2>/dev/null || true)"
+for entry in "${RUNNER_SECRETS[@]}"; do
+    value="$(secret_value "${entry#*=}")"
+    export "${entry%%=*}=${value}"
+done
+
+# The tool cache disk of the VM, created from a snapshot of pre-populated
+# toolchains, replaces the tool cache volume of Cloud Build. The disk is the
+# VM's own, so jobs can add tools to it.
+TOOLCACHE_DEVICE="/dev/disk/by-id/google-toolcache"
+TOOLCACHE_ARGS=""
+if [ -e "${TOOLCACHE_DEVICE}" ]; then
+    mkdir -p /mnt/toolcache
+    mount -o discard,defaults "${TOOLCACHE_DEVICE}" /mnt/toolcache
+    TOOLCACHE_ARGS="-e RUNNER_TOOL_CACHE=/opt/hostedtoolcache -e AGENT_TOOLSDIRECTORY=/opt/hostedtoolcache -v /mnt/toolcache:/opt/hostedtoolcache"
+    echo "Mounted tool cache disk"
+fi
+export TOOLCACHE_ARGS
+
+RUNNER_IMAGE="$(metadata "attributes/runner-image")"
+access_token | docker login -u oauth2accesstoken --password-stdin "https://${RUNNER_IMAGE%%/*}"
+
+RUNNER_COMMAND="$(metadata "attributes/runner-command")"
+echo "Starting runner $(metadata "attributes/runner-name")"
+bash -c "${RUNNER_COMMAND}"
@@ -0,0 +1,128 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+)
+
+const (
+	// vulnerabilityGateWarn logs runners dispatched with images over the
+	// critical vulnerability threshold.
+	vulnerabilityGateWarn = "warn"
+
+	// vulnerabilityGateEnforce refuses to dispatch runners with images over
+	// the critical vulnerability threshold.
+	vulnerabilityGateEnforce = "enforce"
+
+	// imageScanTimeout bounds the lookup of the scanning results of an image,
+	// further bounded by the delivery budget.
+	imageScanTimeout = 3 * time.Second
+)
+
+var runnerImageVulnerableMsg = "no action taken for runner image with critical vulnerabilities"
+
+// ImageScanner adheres to the interaction the webhook service has with
+// Artifact Registry and Artifact Analysis to look up image scanning results.
+type ImageScanner interface {
+	ScanImage(ctx context.Context, image string) (*ImageScan, error)
+}
+
+// imageScanCache caches the scanning results of images by tagged image, so
+// runners are not each delayed by the lookup. Results are kept for ttl, which
+// bounds how long a retagged or rescanned image keeps its previous result.
+type imageScanCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	scans map[string]*cachedImageScan
+}
+
+type cachedImageScan struct {
+	scan      *ImageScan
+	expiresAt time.Time
+}
+
+func newImageScanCache(ttl time.Duration) *imageScanCache {
+	return &imageScanCache{
+		ttl:   ttl,
+		scans: make(map[string]*cachedImageScan),
+	}
+}
+
+func (c *imageScanCache) get(image string, now time.Time) (*ImageScan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.scans[image]
+	if !ok || !now.Before(cached.expiresAt) {
+		delete(c.scans, image)
+		return nil, false
+	}
+	return cached.scan, true
+}
+
+func (c *imageScanCache) add(image string, scan *ImageScan, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scans[image] = &cachedImageScan{scan: scan, expiresAt: now.Add(c.ttl)}
+}
+
+// imageVulnerable reports whether the runner must not be dispatched with the
+// image because its critical vulnerabilities exceed the threshold and the
+// gate is enforced. Images whose scanning results cannot be looked up are
+// allowed, so an Artifact Analysis outage does not stop the fleet.
+func (s *Server) imageVulnerable(ctx context.Context, image string, logFields []any) bool {
+	if s.imageScanner == nil {
+		return false
+	}
+	logger := logging.FromContext(ctx)
+	logFields = append(logFields, "image", image)
+
+	now := time.Now()
+	scan, ok := s.imageScans.get(image, now)
+	if !ok {
+		sctx, cancel := callContext(ctx, imageScanTimeout)
+		defer cancel()
+
+		var err error
+		scan, err = s.imageScanner.ScanImage(sctx, image)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to look up runner image vulnerabilities, allowing image", append(logFields, "error", err)...)
+			return false
+		}
+		s.imageScans.add(image, scan, now)
+	}
+
+	if scan.Critical <= s.vulnerabilityMaxCritical {
+		return false
+	}
+
+	logFields = append(logFields,
+		"image_digest", scan.Digest,
+		"critical_vulnerabilities", scan.Critical,
+		"max_critical_vulnerabilities", s.vulnerabilityMaxCritical)
+	if s.vulnerabilityGate != vulnerabilityGateEnforce {
+		logger.WarnContext(ctx, "runner image has critical vulnerabilities", logFields...)
+		return false
+	}
+	logger.ErrorContext(ctx, "refusing runner image with critical vulnerabilities", logFields...)
+	return true
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/testutil"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImageVulnerable(t *testing.T) {
+	t.Parallel()
+
+	const image = "us-docker.pkg.dev/my-project/runners/default-runner:latest"
+
+	cases := []struct {
+		name        string
+		gate        string
+		maxCritical int64
+		scanner     *MockImageScanner
+		want        bool
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:        "within_threshold",
+			gate:        vulnerabilityGateEnforce,
+			maxCritical: 2,
+			scanner:     &MockImageScanner{Scans: map[string]*ImageScan{image: {Digest: "sha256:abc", Critical: 2}}},
+		},
+		{
+			name:    "warn",
+			gate:    vulnerabilityGateWarn,
+			scanner: &MockImageScanner{Scans: map[string]*ImageScan{image: {Digest: "sha256:abc", Critical: 1}}},
+		},
+		{
+			name:    "enforce",
+			gate:    vulnerabilityGateEnforce,
+			scanner: &MockImageScanner{Scans: map[string]*ImageScan{image: {Digest: "sha256:abc", Critical: 1}}},
+			want:    true,
+		},
+		{
+			name:    "scan_error",
+			gate:    vulnerabilityGateEnforce,
+			scanner: &MockImageScanner{Err: fmt.Errorf("permission denied")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{
+				vulnerabilityGate:        tc.gate,
+				vulnerabilityMaxCritical: tc.maxCritical,
+			}
+			if tc.scanner != nil {
+				s.imageScanner = tc.scanner
+				s.imageScans = newImageScanCache(time.Minute)
+			}
+
+			if got, want := s.imageVulnerable(t.Context(), image, nil), tc.want; got != want {
+				t.Errorf("expected %t to be %t", got, want)
+			}
+		})
+	}
+}
+
+func TestImageVulnerable_cached(t *testing.T) {
+	t.Parallel()
+
+	const image = "us-docker.pkg.dev/my-project/runners/default-runner:latest"
+	scanner := &MockImageScanner{Scans: map[string]*ImageScan{image: {Digest: "sha256:abc", Critical: 1}}}
+	s := &Server{
+		imageScanner:      scanner,
+		imageScans:        newImageScanCache(time.Minute),
+		vulnerabilityGate: vulnerabilityGateEnforce,
+	}
+
+	for range 2 {
+		if !s.imageVulnerable(t.Context(), image, nil) {
+			t.Errorf("expected the image to be refused")
+		}
+	}
+	if diff := cmp.Diff([]string{image}, scanner.Scanned); diff != "" {
+		t.Errorf("unexpected scanned images (-want, +got):\n%s", diff)
+	}
+
+	// Expired results are looked up again.
+	s.imageScans.add(image, &ImageScan{}, time.Now().Add(-time.Minute))
+	if !s.imageVulnerable(t.Context(), image, nil) {
+		t.Errorf("expected the image to be refused")
+	}
+	if got, want := len(scanner.Scanned), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+}
+
+func TestParseArtifactRegistryImage(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name            string
+		image           string
+		wantTag         string
+		wantResourceURL string
+		wantErr         string
+	}{
+		{
+			name:            "image",
+			image:           "us-docker.pkg.dev/my-project/runners/default-runner:latest",
+			wantTag:         "projects/my-project/locations/us/repositories/runners/packages/default-runner/tags/latest",
+			wantResourceURL: "https://us-docker.pkg.dev/my-project/runners/default-runner@sha256:abc",
+		},
+		{
+			name:            "nested_image",
+			image:           "europe-west1-docker.pkg.dev/my-project/runners/ci/ubuntu-24:v2",
+			wantTag:         "projects/my-project/locations/europe-west1/repositories/runners/packages/ci%2Fubuntu-24/tags/v2",
+			wantResourceURL: "https://europe-west1-docker.pkg.dev/my-project/runners/ci/ubuntu-24@sha256:abc",
+		},
+		{
+			name:    "other_registry",
+			image:   "ghcr.io/google/runner:latest",
+			wantErr: `image "ghcr.io/google/runner:latest" is not in an artifact registry docker repository`,
+		},
+		{
+			name:    "no_tag",
+			image:   "us-docker.pkg.dev/my-project/runners/default-runner",
+			wantErr: "has no tag",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ref, err := parseArtifactRegistryImage(tc.image)
+			if diff := testutil.DiffErrString(err, tc.wantErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := ref.tagName(), tc.wantTag; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+			if got, want := ref.resourceURL("sha256:abc"), tc.wantResourceURL; got != want {
+				t.Errorf("expected %q to be %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+	"github.com/abcxyz/pkg/logging"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// warmBuildTag tags the warming builds, so they can be told apart from
+	// runner builds.
+	warmBuildTag = "warm-pool"
+
+	// warmBuildTimeout bounds a warming build, which only pulls images.
+	warmBuildTimeout = 10 * time.Minute
+)
+
+// runPoolWarmer periodically runs a warming build on each worker pool until
+// the context is cancelled.
+func (s *Server) runPoolWarmer(ctx context.Context) {
+	ticker := time.NewTicker(s.poolWarmInterval)
+	defer ticker.Stop()
+
+	s.warmPools(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.warmPools(ctx)
+		}
+	}
+}
+
+// warmPools runs a build on each worker pool that pulls the runner images, so
+// the images are cached on the pool workers when the next runner starts and
+// its cold start skips the image pull. Failures are logged, warming is best
+// effort.
+func (s *Server) warmPools(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	for _, pool := range s.warmPoolIDs() {
+		location, err := workerPoolLocation(pool)
+		if err != nil {
+			logger.WarnContext(ctx, "skipping warming of invalid worker pool", "pool", pool, "error", err)
+			continue
+		}
+
+		build, err := s.cbc.CreateBuild(ctx, &cloudbuildpb.CreateBuildRequest{
+			Parent:    fmt.Sprintf("projects/%s/locations/%s", s.runnerProjectID, location),
+			ProjectId: s.runnerProjectID,
+			Build:     s.warmBuild(pool),
+		})
+		if err != nil {
+			logger.WarnContext(ctx, "failed to start warming build", "pool", pool, "error", err)
+			continue
+		}
+		logger.DebugContext(ctx, "started warming build", "pool", pool, "build_id", build.GetId())
+	}
+}
+
+// warmPoolIDs returns the configured worker pools, each once.
+func (s *Server) warmPoolIDs() []string {
+	pools := slices.Sorted(maps.Values(s.runnerWorkerPools))
+	if s.runnerWorkerPoolID != "" {
+		pools = append(pools, s.runnerWorkerPoolID)
+	}
+	slices.Sort(pools)
+	return slices.Compact(pools)
+}
+
+// warmBuild returns a build pulling the runner images and the Docker builder
+// the runner step runs in onto a worker of the pool. Each step exits right
+// after its image is pulled, the steps run in parallel.
+func (s *Server) warmBuild(pool string) *cloudbuildpb.Build {
+	images := append([]string{"gcr.io/cloud-builders/docker"}, s.startupProbeImages()...)
+
+	steps := make([]*cloudbuildpb.BuildStep, 0, len(images))
+	for i, image := range images {
+		steps = append(steps, &cloudbuildpb.BuildStep{
+			Id:         fmt.Sprintf("pull-%d", i),
+			Name:       image,
+			Entrypoint: "true",
+			WaitFor:    []string{"-"},
+		})
+	}
+
+	return &cloudbuildpb.Build{
+		ServiceAccount: s.runnerServiceAccount,
+		Steps:          steps,
+		Timeout:        durationpb.New(warmBuildTimeout),
+		Options: &cloudbuildpb.BuildOptions{
+			Logging: cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY,
+			Pool: &cloudbuildpb.BuildOptions_PoolOption{
+				Name: pool,
+			},
+		},
+		Tags: []string{warmBuildTag},
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWarmPools(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		defaultPool string
+		pools       map[string]string
+		createErr   error
+		wantParents []string
+		wantPools   []string
+	}{
+		{
+			name:        "default_and_named_pools",
+			defaultPool: "projects/p/locations/us-central1/workerPools/default",
+			pools: map[string]string{
+				"xl":      "projects/p/locations/us-east1/workerPools/xl",
+				"default": "projects/p/locations/us-central1/workerPools/default",
+			},
+			wantParents: []string{
+				"projects/runner-project/locations/us-central1",
+				"projects/runner-project/locations/us-east1",
+			},
+			wantPools: []string{
+				"projects/p/locations/us-central1/workerPools/default",
+				"projects/p/locations/us-east1/workerPools/xl",
+			},
+		},
+		{
+			name: "invalid_pool_skipped",
+			pools: map[string]string{
+				"broken": "my-pool",
+				"xl":     "projects/p/locations/us-east1/workerPools/xl",
+			},
+			wantParents: []string{"projects/runner-project/locations/us-east1"},
+			wantPools:   []string{"projects/p/locations/us-east1/workerPools/xl"},
+		},
+		{
+			name:        "create_error",
+			defaultPool: "projects/p/locations/us-central1/workerPools/default",
+			pools: map[string]string{
+				"xl": "projects/p/locations/us-east1/workerPools/xl",
+			},
+			createErr: fmt.Errorf("quota exceeded"),
+			// Every pool is attempted.
+			wantParents: []string{
+				"projects/runner-project/locations/us-central1",
+				"projects/runner-project/locations/us-east1",
+			},
+			wantPools: []string{
+				"projects/p/locations/us-central1/workerPools/default",
+				"projects/p/locations/us-east1/workerPools/xl",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cbc := &MockCloudBuildClient{createBuildErr: tc.createErr}
+			s := &Server{
+				cbc:                  cbc,
+				runnerImageName:      "default-runner",
+				runnerImageTag:       "latest",
+				runnerProjectID:      "runner-project",
+				runnerRepositoryID:   "us-docker.pkg.dev/p/runners",
+				runnerServiceAccount: "runner@p.iam.gserviceaccount.com",
+				runnerWorkerPoolID:   tc.defaultPool,
+				runnerWorkerPools:    tc.pools,
+			}
+			s.warmPools(t.Context())
+
+			var gotParents, gotPools []string
+			for _, req := range cbc.createBuildReqs {
+				gotParents = append(gotParents, req.GetParent())
+				gotPools = append(gotPools, req.GetBuild().GetOptions().GetPool().GetName())
+			}
+			if diff := cmp.Diff(tc.wantParents, gotParents); diff != "" {
+				t.Errorf("unexpected parents (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantPools, gotPools); diff != "" {
+				t.Errorf("unexpected pools (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWarmBuild(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		runnerImageName:     "default-runner",
+		runnerImageTag:      "latest",
+		runnerImageVariants: []string{"gpu-runner"},
+		runnerRepositoryID:  "us-docker.pkg.dev/p/runners",
+	}
+	build := s.warmBuild("projects/p/locations/us-east1/workerPools/xl")
+
+	var gotImages []string
+	for _, step := range build.GetSteps() {
+		gotImages = append(gotImages, step.GetName())
+		if got, want := step.GetEntrypoint(), "true"; got != want {
+			t.Errorf("expected %q to be %q", got, want)
+		}
+	}
+	wantImages := []string{
+		"gcr.io/cloud-builders/docker",
+		"us-docker.pkg.dev/p/runners/default-runner:latest",
+		"us-docker.pkg.dev/p/runners/gpu-runner:latest",
+	}
+	if diff := cmp.Diff(wantImages, gotImages); diff != "" {
+		t.Errorf("unexpected images (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{warmBuildTag}, build.GetTags()); diff != "" {
+		t.Errorf("unexpected tags (-want, +got):\n%s", diff)
+	}
+}
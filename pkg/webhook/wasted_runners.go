@@ -0,0 +1,217 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abcxyz/pkg/logging"
+	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+)
+
+// runWastedRunnerMonitor tears down runners that never picked up a job until
+// ctx is done.
+func (s *Server) runWastedRunnerMonitor(ctx context.Context) {
+	ticker := time.NewTicker(runnerTTLCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reapWastedRunners(ctx, time.Now())
+		}
+	}
+}
+
+// reapWastedRunners tears down the runners dispatched for a job that did not
+// pick up any job within the idle grace period of their dispatch, because
+// another runner took the job or the job was cancelled before it was picked
+// up. A runner is considered idle while no in_progress event moved it to
+// running. It is removed from GitHub before its build or VM is cancelled,
+// GitHub refuses to remove a runner that picked up a job in the meantime.
+// Runners provisioned ahead of demand and reusable runners that served a job
+// wait for jobs by design and are left alone. It returns the number of
+// runners torn down.
+func (s *Server) reapWastedRunners(ctx context.Context, now time.Time) int {
+	// The runners belong to the deployment being shadowed.
+	if s.shadowMode {
+		return 0
+	}
+
+	s.syncRunners(ctx)
+	logger := logging.FromContext(ctx)
+	reaped := 0
+	for _, r := range s.runners.List() {
+		state := r.Lifecycle.State()
+		switch state {
+		case lifecycle.StateProvisioning, lifecycle.StateOnline:
+		default:
+			continue
+		}
+		if r.JobID == 0 || r.JobsServed > 0 || runnerAge(&r, now) < s.idleGracePeriod {
+			continue
+		}
+
+		logFields := []any{
+			"org", r.Org,
+			"repo", r.Repo,
+			"run_id", r.RunID,
+			"job_id", r.JobID,
+			"runner_id", r.RunnerName,
+			"backend", r.Backend,
+			"backend_id", r.BackendID,
+			"state", state,
+		}
+
+		registered, err := s.deregisterRunner(ctx, &r)
+		switch {
+		case errors.Is(err, errRunnerBusy):
+			// The in_progress event of its job may still be on its way.
+			continue
+		case err != nil:
+			logger.WarnContext(ctx, "failed to remove idle runner", append(logFields, "error", err)...)
+			continue
+		case !registered:
+			// A runner that ran a job deregistered itself, the in_progress
+			// event of the job did not reach this instance.
+			s.forgetEndedRunner(ctx, r, logFields)
+			continue
+		}
+
+		if backend := s.backendNamed(r.Backend); backend != nil && r.BackendID != "" {
+			if err := backend.Cancel(ctx, r.BackendID); err != nil {
+				// The runner is gone from GitHub, its build or VM is
+				// forgotten once it ended.
+				logger.ErrorContext(ctx, "failed to cancel wasted runner", append(logFields, "error", err)...)
+				continue
+			}
+		}
+
+		s.transitionRunner(ctx, r.RunnerName, lifecycle.StateOrphaned)
+		s.runners.Remove(r.RunnerName)
+		s.launchQueue.notify()
+		s.metrics.recordWastedRunner(state)
+		reaped++
+		logger.InfoContext(ctx, "tore down runner that never picked up a job", logFields...)
+	}
+	return reaped
+}
+
+// forgetEndedRunner stops tracking a runner that is no longer registered
+// with GitHub once its build or VM ended. The runner is not counted as
+// wasted, it most likely ran a job. Runners on backends that cannot tell
+// whether a runner still runs are left to the runner TTL.
+func (s *Server) forgetEndedRunner(ctx context.Context, r trackedRunner, logFields []any) {
+	checker, ok := s.backendNamed(r.Backend).(RunnerStatusChecker)
+	if !ok || r.BackendID == "" {
+		return
+	}
+	active, err := checker.Active(ctx, r.BackendID)
+	if err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed to check status of unregistered runner", append(logFields, "error", err)...)
+		return
+	}
+	if active {
+		return
+	}
+
+	s.transitionRunner(ctx, r.RunnerName, lifecycle.StateOrphaned)
+	s.runners.Remove(r.RunnerName)
+	s.launchQueue.notify()
+	logging.FromContext(ctx).InfoContext(ctx, "stopped tracking unregistered runner whose backend ended", logFields...)
+}
+
+// errRunnerBusy is returned when deregistering a runner that is running a
+// job.
+var errRunnerBusy = errors.New("runner is busy")
+
+// deregisterRunner removes the runner from the repository or organization it
+// was registered with, ahead of tearing down its build or VM. GitHub refuses
+// to remove a busy runner, so a runner that picked up a job in the meantime
+// is never torn down mid-job: errRunnerBusy is returned instead. It reports
+// whether the runner was registered, a runner that is not has deregistered
+// itself or was never started.
+func (s *Server) deregisterRunner(ctx context.Context, r *trackedRunner) (bool, error) {
+	gh, registered, err := s.lookupRunner(ctx, r)
+	if err != nil {
+		return false, err
+	}
+	if registered == nil {
+		return false, nil
+	}
+	if registered.GetBusy() {
+		return true, errRunnerBusy
+	}
+
+	if err := s.removeRegisteredRunner(ctx, gh, r, registered.GetID()); err != nil {
+		var ghErr *github.ErrorResponse
+		if errors.As(err, &ghErr) && ghErr.Response != nil && ghErr.Response.StatusCode == http.StatusUnprocessableEntity {
+			return true, fmt.Errorf("%w: %w", errRunnerBusy, err)
+		}
+		return true, fmt.Errorf("failed to remove runner %d: %w", registered.GetID(), err)
+	}
+	return true, nil
+}
+
+// removeRegisteredRunner removes the runner with the given ID from the
+// organization or repository it was registered with.
+func (s *Server) removeRegisteredRunner(ctx context.Context, gh *github.Client, r *trackedRunner, id int64) error {
+	rctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
+	var err error
+	if r.RunnerGroupID > 0 {
+		_, err = gh.Actions.RemoveOrganizationRunner(rctx, r.Org, id)
+	} else {
+		_, err = gh.Actions.RemoveRunner(rctx, r.Org, r.Repo, id)
+	}
+	return err
+}
+
+// lookupRunner returns the runner as registered with GitHub, nil if it is not
+// registered, and a client allowed to remove it.
+func (s *Server) lookupRunner(ctx context.Context, r *trackedRunner) (*github.Client, *github.Runner, error) {
+	var repo *string
+	var repos []string
+	permissions := map[string]string{
+		"organization_self_hosted_runners": "write",
+	}
+	if r.RunnerGroupID == 0 {
+		repo = &r.Repo
+		repos = append(repos, r.Repo)
+		permissions = map[string]string{
+			"administration": "write",
+		}
+	}
+
+	gh, err := s.installationClient(ctx, r.InstallationID, permissions, repos...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to setup installation client: %w", err)
+	}
+	fctx, cancel := callContext(ctx, s.githubCallTimeout)
+	defer cancel()
+	registered, err := findRunner(fctx, gh, r.Org, repo, r.RunnerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gh, registered, nil
+}
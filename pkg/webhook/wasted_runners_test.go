@@ -0,0 +1,166 @@
+// Copyright 2025 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
+	"github.com/google/github_actions_on_gcp/pkg/metrics"
+)
+
+// newWastedRunner returns a runner of job 1 of google/webhook dispatched at
+// the given time and moved through the states.
+func newWastedRunner(t *testing.T, name string, dispatchedAt time.Time, states ...lifecycle.State) *trackedRunner {
+	t.Helper()
+
+	l := lifecycle.New(dispatchedAt)
+	for _, s := range append([]lifecycle.State{lifecycle.StateDispatching, lifecycle.StateProvisioning}, states...) {
+		if err := l.Transition(s, dispatchedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &trackedRunner{
+		RunnerName:     name,
+		InstallationID: 123,
+		Org:            "google",
+		Repo:           "webhook",
+		JobID:          1,
+		Backend:        runnerBackendCloudBuild,
+		BackendID:      "projects/runner-project/locations/us-central1/builds/" + name,
+		Lifecycle:      l,
+	}
+}
+
+func TestReapWastedRunners(t *testing.T) {
+	t.Parallel()
+
+	registry, app, ghURL := newFakeRunnerRegistry(t)
+	registry.register("GCP-idle", false, false)
+	registry.register("GCP-busy", false, true)
+	registry.register("GCP-took-job", false, false)
+	registry.takeJobOnRemove("GCP-took-job")
+	registry.register("GCP-grouped", true, false)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-time.Hour)
+
+	cbc := &MockCloudBuildClient{}
+	srv := &Server{
+		appClient:       app,
+		cbc:             cbc,
+		ghAPIBaseURL:    ghURL,
+		idleGracePeriod: 15 * time.Minute,
+		metrics:         newWebhookMetrics(metrics.NewRegistry()),
+		runners:         newRunnerTracker(),
+	}
+	srv.runners.Dispatched(newWastedRunner(t, "GCP-idle", old, lifecycle.StateOnline))
+	srv.runners.Dispatched(newWastedRunner(t, "GCP-busy", old, lifecycle.StateOnline))
+	srv.runners.Dispatched(newWastedRunner(t, "GCP-took-job", old, lifecycle.StateOnline))
+	srv.runners.Dispatched(newWastedRunner(t, "GCP-running", old, lifecycle.StateRunning))
+	srv.runners.Dispatched(newWastedRunner(t, "GCP-young", now.Add(-time.Minute), lifecycle.StateOnline))
+	grouped := newWastedRunner(t, "GCP-grouped", old, lifecycle.StateOnline)
+	grouped.RunnerGroupID = 3
+	srv.runners.Dispatched(grouped)
+	ahead := newWastedRunner(t, "GCP-ahead", old, lifecycle.StateOnline)
+	ahead.JobID = 0
+	srv.runners.Dispatched(ahead)
+
+	if got, want := srv.reapWastedRunners(t.Context(), now), 2; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+
+	// Runners that picked up a job, even while being removed, keep their
+	// build.
+	var cancelled []string
+	for _, req := range cbc.cancelBuildReqs {
+		cancelled = append(cancelled, req.GetId())
+	}
+	sort.Strings(cancelled)
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-idle"}, cancelled); diff != "" {
+		t.Errorf("unexpected cancelled builds (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"GCP-grouped", "GCP-idle"}, registry.removedRunners()); diff != "" {
+		t.Errorf("unexpected removed runners (-want, +got):\n%s", diff)
+	}
+	if got, want := srv.runners.Count(), 5; got != want {
+		t.Errorf("expected %d to be %d", got, want)
+	}
+	if got, want := srv.metrics.wastedRunners.Value(string(lifecycle.StateOnline)), 2.0; got != want {
+		t.Errorf("expected %v to be %v", got, want)
+	}
+}
+
+func TestReapWastedRunners_Unregistered(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		buildStatus cloudbuildpb.Build_Status
+		wantTracked bool
+	}{
+		{
+			name:        "build_running",
+			buildStatus: cloudbuildpb.Build_WORKING,
+			wantTracked: true,
+		},
+		{
+			name:        "build_ended",
+			buildStatus: cloudbuildpb.Build_SUCCESS,
+			wantTracked: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The runner ran a job and deregistered itself, the in_progress
+			// event of the job went elsewhere.
+			_, app, ghURL := newFakeRunnerRegistry(t)
+			now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			cbc := &MockCloudBuildClient{getBuildRes: &cloudbuildpb.Build{Status: tc.buildStatus}}
+			srv := &Server{
+				appClient:       app,
+				cbc:             cbc,
+				ghAPIBaseURL:    ghURL,
+				idleGracePeriod: 15 * time.Minute,
+				metrics:         newWebhookMetrics(metrics.NewRegistry()),
+				runners:         newRunnerTracker(),
+			}
+			srv.runners.Dispatched(newWastedRunner(t, "GCP-ran-job", now.Add(-time.Hour), lifecycle.StateOnline))
+
+			if got, want := srv.reapWastedRunners(t.Context(), now), 0; got != want {
+				t.Errorf("expected %d to be %d", got, want)
+			}
+			if len(cbc.cancelBuildReqs) != 0 {
+				t.Errorf("expected no builds to be cancelled")
+			}
+			if got, want := srv.runners.Count() == 1, tc.wantTracked; got != want {
+				t.Errorf("expected runner tracked to be %t", want)
+			}
+			if got, want := srv.metrics.wastedRunners.Value(string(lifecycle.StateOnline)), 0.0; got != want {
+				t.Errorf("expected %v to be %v", got, want)
+			}
+		})
+	}
+}
@@ -15,23 +15,25 @@
 package webhook
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html"
-	"log/slog"
 	"net/http"
 	"slices"
-	"strings"
 	"time"
 
-	"cloud.google.com/go/cloudbuild/apiv1/v2/cloudbuildpb"
 	"github.com/abcxyz/pkg/logging"
 
 	"github.com/google/go-github/v69/github"
+
+	"github.com/google/github_actions_on_gcp/pkg/lifecycle"
 )
 
 var (
 	defaultRunnerLabel    = "self-hosted"
 	runnerStartedMsg      = "runner started"
+	runnerTargetGoneMsg   = "no action taken for repository or installation that is gone"
 	githubWebhookEventKey = "github_webhook_event"
 )
 
@@ -46,51 +48,102 @@ type apiResponse struct {
 
 func (s *Server) handleWebhook() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		logger := logging.FromContext(ctx)
-
-		resp := s.processRequest(r)
-		if resp.Error != nil {
-			logger.ErrorContext(ctx, "error processing request",
-				"error", resp.Error,
-				"code", resp.Code,
-				"body", resp.Message)
-		}
-
-		w.WriteHeader(resp.Code)
-		fmt.Fprint(w, html.EscapeString(resp.Message))
+		r = withDeliveryID(r)
+		r = r.WithContext(withDeliveryBudget(r.Context(), s.deliveryBudget, time.Now()))
+		s.writeResponse(w, r, s.processRequest(r))
 	})
 }
 
-func (s *Server) processRequest(r *http.Request) *apiResponse {
+// writeResponse logs any error attached to the response and writes the status
+// code and escaped message back to the caller.
+func (s *Server) writeResponse(w http.ResponseWriter, r *http.Request, resp *apiResponse) {
 	ctx := r.Context()
 	logger := logging.FromContext(ctx)
 
-	payload, err := github.ValidatePayload(r, s.webhookSecret)
+	if resp.Error != nil {
+		logger.ErrorContext(ctx, "error processing request",
+			"error", resp.Error,
+			"code", resp.Code,
+			"body", resp.Message)
+	}
+
+	w.WriteHeader(resp.Code)
+	fmt.Fprint(w, html.EscapeString(resp.Message))
+}
+
+func (s *Server) processRequest(r *http.Request) *apiResponse {
+	ctx := r.Context()
+
+	// GitHub can redeliver the deliveries refused during maintenance.
+	if s.inMaintenance() {
+		return &apiResponse{http.StatusServiceUnavailable, maintenanceMsg, nil}
+	}
+
+	payload, err := validatePayload(r, s.webhookSecret)
 	if err != nil {
-		return &apiResponse{http.StatusInternalServerError, "failed to validate payload", err}
+		if errors.Is(err, errMissingSignature) || errors.Is(err, errLegacySignature) || errors.Is(err, errInvalidSignature) {
+			return &apiResponse{http.StatusUnauthorized, "invalid payload signature", err}
+		}
+		return &apiResponse{http.StatusBadRequest, "failed to validate payload", err}
 	}
 
-	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	s.deliveries.Add(archivedDelivery{
+		ID:         deliveryIDFromContext(ctx),
+		Event:      github.WebHookType(r),
+		Payload:    payload,
+		ReceivedAt: time.Now(),
+	})
+
+	return s.processEvent(ctx, github.WebHookType(r), payload, false)
+}
+
+// processEvent processes a verified webhook payload of the given event type.
+// forceNewRunner provisions a queued job's runner under a new name, so a
+// replayed job is not refused because its runner already exists.
+func (s *Server) processEvent(ctx context.Context, eventType string, payload []byte, forceNewRunner bool) *apiResponse {
+	logger := logging.FromContext(ctx)
+
+	s.metrics.recordEvent(eventType)
+
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil && eventType == "workflow_job" {
+		// Keep dispatching while go-github lags behind a payload change.
+		if fallback, ferr := fallbackWorkflowJobEvent(payload); ferr == nil {
+			logger.WarnContext(ctx, "parsed workflow_job payload from raw JSON", "error", err)
+			s.metrics.recordPayloadFallback(eventType)
+			event, err = fallback, nil
+		}
+	}
 	if err != nil {
+		if perr := parseError(eventType); perr != nil {
+			logger.WarnContext(ctx, "rejecting malformed payload", "error", err)
+			return perr.response(err)
+		}
 		return &apiResponse{http.StatusInternalServerError, "failed to parse webhook", err}
 	}
 
 	switch event := event.(type) {
 	case *github.WorkflowJobEvent:
-		// Check for nil action first to avoid nil pointer dereference
+		if perr := validateWorkflowJobEvent(event, s.strictPayloads); perr != nil {
+			s.metrics.recordWorkflowJob(event.GetAction(), workflowJobOutcomeInvalid)
+			logger.WarnContext(ctx, "rejecting invalid workflow job payload", "error", perr)
+			return perr.response(nil)
+		}
+
 		if event.Action == nil {
+			s.metrics.recordWorkflowJob("", workflowJobOutcomeIgnored)
 			logger.InfoContext(ctx, "no action taken for nil action type")
 			return &apiResponse{http.StatusOK, "no action taken for nil action type", nil}
 		}
 
-		// Common attributes to always include for WorkflowJobEvent
-		var jobID string
-		if event.WorkflowJob != nil && event.WorkflowJob.ID != nil {
-			jobID = fmt.Sprintf("%d", *event.WorkflowJob.ID)
-		}
+		// Common attributes to always include for WorkflowJobEvent, the fields
+		// are validated above.
+		jobID := fmt.Sprintf("%d", *event.WorkflowJob.ID)
 
-		runnerID := fmt.Sprintf("GCP-%s", jobID)
+		runnerID := runnerNamePrefix + jobID
+		if forceNewRunner {
+			runnerID = fmt.Sprintf("%s-replay-%d", runnerID, time.Now().Unix())
+		}
 
 		// Base log fields that will be common to most WorkflowJob logs
 		baseLogFields := []any{
@@ -101,6 +154,7 @@ func (s *Server) processRequest(r *http.Request) *apiResponse {
 			"job_id", jobID,
 			"runner_id", runnerID,
 		}
+		baseLogFields = append(baseLogFields, workflowLogFields(event)...)
 
 		// Add all available timestamps to base log fields (they might be nil depending on event action)
 		if event.WorkflowJob.CreatedAt != nil {
@@ -113,83 +167,109 @@ func (s *Server) processRequest(r *http.Request) *apiResponse {
 			baseLogFields = append(baseLogFields, "completed_at", getTimeString(event.WorkflowJob.CompletedAt))
 		}
 
+		outcome := workflowJobOutcomeProcessed
+		defer func() { s.metrics.recordWorkflowJob(*event.Action, outcome) }()
+
 		switch *event.Action {
 		case "queued":
 			logger.InfoContext(ctx, "Workflow job queued", baseLogFields...)
 
 			if !slices.Contains(event.WorkflowJob.Labels, defaultRunnerLabel) {
+				outcome = workflowJobOutcomeRejected
 				logger.WarnContext(ctx, "no action taken for labels", append(baseLogFields, "labels", event.WorkflowJob.Labels)...)
 				return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for labels: %s", event.WorkflowJob.Labels), nil}
 			}
 
-			imageTag := s.runnerImageTag
-			if s.environment == "autopush" {
-				for _, label := range event.WorkflowJob.Labels {
-					if strings.HasPrefix(label, "pr-") {
-						imageTag = label
-						break
-					}
-				}
+			if age, stale := s.jobStale(event, time.Now()); stale {
+				outcome = workflowJobOutcomeIgnored
+				logger.WarnContext(ctx, "no action taken for job queued longer than the queue TTL",
+					append(baseLogFields, "queue_age", age.String(), "queue_ttl", s.queueTTL.String())...)
+				return &apiResponse{http.StatusOK, runnerJobStaleMsg, nil}
 			}
 
-			if event.Installation == nil || event.Installation.ID == nil || event.Org == nil || event.Org.Login == nil || event.Repo == nil || event.Repo.Name == nil {
-				err := fmt.Errorf("event is missing required fields (installation, org, or repo)")
-				logger.ErrorContext(ctx, "cannot generate JIT config due to missing event data", append(baseLogFields, "error", err)...)
-				return &apiResponse{http.StatusBadRequest, "unexpected event payload struture", err}
-			}
+			s.publishLifecycleEvent(ctx, newLifecycleEvent(LifecycleEventQueued, event))
 
-			jitConfig, errResponse := s.GenerateRepoJITConfig(ctx, *event.Installation.ID, *event.Org.Login, *event.Repo.Name, runnerID)
-			if errResponse != nil {
-				logger.ErrorContext(ctx, "failed to generate JIT config", append(baseLogFields, "error", errResponse.Error, "response_message", errResponse.Message)...)
-				return errResponse
+			if s.jobApproved(ctx, event, baseLogFields) {
+				return &apiResponse{http.StatusOK, runnerPrewarmedMsg, nil}
 			}
 
-			build := &cloudbuildpb.Build{
-				ServiceAccount: s.runnerServiceAccount,
-				Steps: []*cloudbuildpb.BuildStep{
-					{
-						Id:         "run",
-						Name:       "gcr.io/cloud-builders/docker",
-						Entrypoint: "bash",
-						Args: []string{
-							"-c",
-							// privileged and security-opts are needed to run Docker-in-Docker
-							// https://rootlesscontaine.rs/getting-started/common/apparmor/
-							"docker run --privileged --security-opt seccomp=unconfined --security-opt apparmor=unconfined -e ENCODED_JIT_CONFIG=$_ENCODED_JIT_CONFIG $_REPOSITORY_ID/$_IMAGE_NAME:$_IMAGE_TAG",
-						},
-					},
-				},
-				Options: &cloudbuildpb.BuildOptions{
-					Logging: cloudbuildpb.BuildOptions_CLOUD_LOGGING_ONLY,
-				},
-				Substitutions: map[string]string{
-					"_ENCODED_JIT_CONFIG": *jitConfig.EncodedJITConfig,
-					"_REPOSITORY_ID":      s.runnerRepositoryID,
-					"_IMAGE_NAME":         s.runnerImageName,
-					"_IMAGE_TAG":          imageTag,
-				},
-			}
-
-			if s.runnerWorkerPoolID != "" {
-				build.Options.Pool = &cloudbuildpb.BuildOptions_PoolOption{
-					Name: s.runnerWorkerPoolID,
+			req := &runnerRequest{
+				InstallationID: *event.Installation.ID,
+				Org:            *event.Org.Login,
+				Repo:           *event.Repo.Name,
+				RunnerName:     runnerID,
+				Labels:         event.WorkflowJob.Labels,
+				Actor:          event.GetSender().GetLogin(),
+				Job:            event,
+				DeliveryID:     deliveryIDFromContext(ctx),
+			}
+			s.forecaster.record(req.InstallationID, req.Org, req.Repo, req.Labels)
+
+			// Reusable runners wait online for the next job of their
+			// repository.
+			if s.skipIfIdle || s.reusableRunners() {
+				idle, err := s.claimIdleRunner(ctx, req.InstallationID, req.Org, req.Repo, req.Labels)
+				switch {
+				case err != nil:
+					// The job gets a runner of its own, as if no runner was idle.
+					logger.WarnContext(ctx, "failed to look up idle runners", append(baseLogFields, "error", err)...)
+				case idle != "":
+					logger.InfoContext(ctx, "leaving job to idle runner", append(baseLogFields, "idle_runner", idle)...)
+					return &apiResponse{http.StatusOK, runnerIdleMsg, nil}
 				}
 			}
 
-			buildReq := &cloudbuildpb.CreateBuildRequest{
-				Parent:    fmt.Sprintf("projects/%s/locations/%s", s.runnerProjectID, s.runnerLocation),
-				ProjectId: s.runnerProjectID,
-				Build:     build,
+			count, err := runnerCount(req.Labels, s.runnerMaxCount)
+			if err != nil {
+				logger.WarnContext(ctx, "ignoring invalid count label", append(baseLogFields, "error", err)...)
 			}
 
-			if err := s.cbc.CreateBuild(ctx, buildReq); err != nil {
-				logger.ErrorContext(ctx, "failed to run Cloud Build for runner", append(baseLogFields, "error", err)...)
-				return &apiResponse{http.StatusInternalServerError, "failed to run build", err}
+			if s.dispatchQueue != nil {
+				resp := s.enqueueRunner(ctx, req, baseLogFields)
+				if resp.Code == http.StatusAccepted {
+					s.provisionExtraRunners(ctx, req, count, baseLogFields)
+				}
+				return resp
 			}
 
-			logger.InfoContext(ctx, runnerStartedMsg, slog.Any(githubWebhookEventKey, event))
+			createdBuild, errResponse := s.provisionRunner(ctx, req, baseLogFields)
+			if errResponse != nil {
+				switch errResponse.Message {
+				case runnerTargetGoneMsg, runnerInstallationSuspendedMsg:
+					outcome = workflowJobOutcomeIgnored
+				case runnerDeniedMsg, runnerActorBlockedMsg, runnerActorLimitMsg:
+					outcome = workflowJobOutcomeRejected
+				}
+				return errResponse
+			}
+			s.runnerDispatched(ctx, req, createdBuild, baseLogFields)
+			s.provisionExtraRunners(ctx, req, count, baseLogFields)
 			return &apiResponse{http.StatusOK, runnerStartedMsg, nil}
 
+		case "waiting":
+			if !slices.Contains(event.WorkflowJob.Labels, defaultRunnerLabel) {
+				outcome = workflowJobOutcomeIgnored
+				logger.InfoContext(ctx, "no action taken for unhandled workflow job action type", append(baseLogFields, "action", *event.Action)...)
+				return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for action type: %q", *event.Action), nil}
+			}
+
+			// Runners are only provisioned once the job is approved and queued,
+			// so they do not sit idle during the review.
+			now := time.Now()
+			s.waitingJobs.Add(event, now)
+			s.metrics.recordGatedJob(gatedJobWaiting)
+
+			logFields := append([]any{}, baseLogFields...)
+			logFields = append(logFields, "waiting_at", now.UTC().Format(time.RFC3339))
+			if event.WorkflowJob.CreatedAt != nil {
+				logFields = append(logFields, "duration_before_waiting_seconds", now.Sub(event.WorkflowJob.CreatedAt.Time).Seconds())
+			}
+			if env := event.GetDeployment().GetEnvironment(); env != "" {
+				logFields = append(logFields, "environment", env)
+			}
+			logger.InfoContext(ctx, "Workflow job waiting for approval", logFields...)
+			return &apiResponse{http.StatusOK, "workflow job waiting event logged", nil}
+
 		case "in_progress":
 			// Calculate and log "queued duration"
 			logFields := append([]any{}, baseLogFields...) // Create a mutable copy
@@ -198,9 +278,16 @@ func (s *Server) processRequest(r *http.Request) *apiResponse {
 				queuedDuration := event.WorkflowJob.StartedAt.Time.Sub(event.WorkflowJob.CreatedAt.Time)
 
 				logFields = append(logFields, "duration_queued_seconds", queuedDuration.Seconds())
+				s.recordQueueAge(ctx, queuedDuration)
 			}
 
+			s.transitionRunner(ctx, event.GetWorkflowJob().GetRunnerName(), lifecycle.StateRunning)
+			s.deleteJITConfigSecret(ctx, event.GetWorkflowJob().GetRunnerName())
+			s.recordProvisioningLatency(event.GetWorkflowJob().GetRunnerName())
+			s.recordJobRunner(ctx, event, logFields)
+
 			logger.InfoContext(ctx, "Workflow job in progress", logFields...)
+			s.publishLifecycleEvent(ctx, newLifecycleEvent(LifecycleEventOnline, event))
 			return &apiResponse{http.StatusOK, "workflow job in progress event logged", nil}
 
 		case "completed":
@@ -222,15 +309,51 @@ func (s *Server) processRequest(r *http.Request) *apiResponse {
 				logFields = append(logFields, "duration_total_seconds", totalDuration.Seconds())
 			}
 
+			s.waitingJobs.Take(*event.WorkflowJob.ID)
+			if s.deferredRunners.drop(*event.WorkflowJob.ID) {
+				logger.InfoContext(ctx, "dropped deferred runner for completed job", baseLogFields...)
+			}
+			if s.launchQueue.drop(*event.WorkflowJob.ID) {
+				logger.InfoContext(ctx, "dropped queued runner for completed job", baseLogFields...)
+			}
+			if s.reuseRunner(ctx, event.GetWorkflowJob().GetRunnerName(), time.Now()) {
+				if r, ok := s.trackedRunner(event.GetWorkflowJob().GetRunnerName()); ok {
+					s.metrics.recordImageJob(r.ImageTag, event.GetWorkflowJob().GetConclusion())
+				}
+			} else {
+				s.transitionRunner(ctx, event.GetWorkflowJob().GetRunnerName(), lifecycle.StateCompleted)
+				if r, ok := s.runners.Remove(event.GetWorkflowJob().GetRunnerName()); ok {
+					s.metrics.recordImageJob(r.ImageTag, event.GetWorkflowJob().GetConclusion())
+					s.rerunPreemptedJob(ctx, r, event.GetWorkflowJob())
+					s.releaseRunner(ctx, r)
+					s.launchQueue.notify()
+				}
+				s.ensureRunnerRemoved(ctx, event, logFields)
+			}
+			s.recordJobRunner(ctx, event, logFields)
+			s.deleteJITConfigSecret(ctx, event.GetWorkflowJob().GetRunnerName())
+			s.cancelUnusedBuilds(ctx, event, logFields)
+
 			logger.InfoContext(ctx, "Workflow job completed", logFields...)
+			s.publishLifecycleEvent(ctx, newLifecycleEvent(LifecycleEventCompleted, event))
 			return &apiResponse{http.StatusOK, "workflow job completed event logged", nil}
 
 		default:
 			// Log other unhandled workflow job actions
+			outcome = workflowJobOutcomeIgnored
 			logger.InfoContext(ctx, "no action taken for unhandled workflow job action type", append(baseLogFields, "action", *event.Action)...)
 			return &apiResponse{http.StatusOK, fmt.Sprintf("no action taken for action type: %q", *event.Action), nil}
 		}
 
+	case *github.DeploymentReviewEvent:
+		return s.handleDeploymentReview(ctx, event)
+
+	case *github.RepositoryDispatchEvent:
+		return s.handleRepositoryDispatch(ctx, deliveryIDFromContext(ctx), event)
+
+	case *github.InstallationEvent:
+		return s.handleInstallation(ctx, event)
+
 	default:
 		// Log other unhandled webhook event types
 		logger.ErrorContext(ctx, "Received unhandled event type",
@@ -31,6 +31,7 @@ import (
 
 	"github.com/abcxyz/pkg/githubauth"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v69/github"
 )
 
@@ -75,6 +76,16 @@ func TestHandleWebhook(t *testing.T) {
 		expRespBody          string
 		expectBuild          bool
 		expectedImageTag     string
+		expEventTypes        []LifecycleEventType
+		repoInstallationID   int64
+		repoInstallationCode int
+		suspended            bool
+		jitConfigCode        int
+		existingRunner       bool
+		dispatchPolicy       string
+		shadowMode           bool
+		queueTTL             time.Duration
+		imageDigest          string
 	}{
 		{
 			name:                 "Workflow Job Queued - Default Label",
@@ -93,6 +104,166 @@ func TestHandleWebhook(t *testing.T) {
 			expRespBody:          runnerStartedMsg,
 			expectBuild:          true,
 			expectedImageTag:     "latest",
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued, LifecycleEventDispatched},
+		},
+		{
+			name:                 "Workflow Job Queued - Pinned Image Digest",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerStartedMsg,
+			expectBuild:          true,
+			expectedImageTag:     "latest@sha256:abc",
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued, LifecycleEventDispatched},
+			imageDigest:          "sha256:abc",
+		},
+		{
+			name:                 "Workflow Job Queued - Older Than Queue TTL",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerJobStaleMsg,
+			expectBuild:          false,
+			queueTTL:             10 * time.Minute,
+		},
+		{
+			name:                 "Workflow Job Queued - Repository Outside Installation",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        403,
+			expRespBody:          "repository does not belong to installation",
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			repoInstallationID:   999,
+		},
+		{
+			name:                 "Workflow Job Queued - Repository Gone",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerTargetGoneMsg,
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			repoInstallationCode: http.StatusNotFound,
+		},
+		{
+			name:                 "Workflow Job Queued - Installation Suspended",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerInstallationSuspendedMsg,
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			suspended:            true,
+		},
+		{
+			name:                 "Workflow Job Queued - Actions Disabled",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerTargetGoneMsg,
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			jitConfigCode:        http.StatusForbidden,
+		},
+		{
+			name:                 "Workflow Job Queued - Runner Already Exists",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        409,
+			expRespBody:          "runner already exists",
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			existingRunner:       true,
+		},
+		{
+			name:                 "Workflow Job Queued - Denied By Policy",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerDeniedMsg,
+			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued},
+			dispatchPolicy: `
+rules:
+  - name: no-webhook
+    if: 'org == "google" && repo == "webhook"'
+    decision: deny
+`,
+		},
+		{
+			name:                 "Workflow Job Queued - Shadow Mode",
+			payloadType:          payloadType,
+			action:               queuedAction,
+			runnerLabels:         []string{defaultRunnerLabel},
+			payloadWebhookSecret: serverGitHubWebhookSecret,
+			contentType:          contentType,
+			createdAt:            &queuedTime,
+			runID:                &runID,
+			jobID:                &jobID,
+			jobName:              &jobName,
+			expStatusCode:        200,
+			expRespBody:          runnerStartedMsg,
+			expectBuild:          false,
+			// Registering a runner would fail the request.
+			jitConfigCode: http.StatusInternalServerError,
+			shadowMode:    true,
 		},
 		{
 			name:                 "Workflow Job Queued - Dynamic Label Autopush",
@@ -111,6 +282,7 @@ func TestHandleWebhook(t *testing.T) {
 			expRespBody:          runnerStartedMsg,
 			expectBuild:          true,
 			expectedImageTag:     "pr-123-abc",
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued, LifecycleEventDispatched},
 		},
 		{
 			name:                 "Workflow Job Queued - Dynamic Label Production",
@@ -129,6 +301,7 @@ func TestHandleWebhook(t *testing.T) {
 			expRespBody:          runnerStartedMsg,
 			expectBuild:          true,
 			expectedImageTag:     "latest", // Should ignore dynamic label in prod
+			expEventTypes:        []LifecycleEventType{LifecycleEventQueued, LifecycleEventDispatched},
 		},
 		{
 			name:                 "Workflow Job Queued - No Matching Label",
@@ -163,6 +336,7 @@ func TestHandleWebhook(t *testing.T) {
 			expStatusCode:        200,
 			expRespBody:          "workflow job in progress event logged",
 			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventOnline},
 		},
 		{
 			name:                 "Workflow Job Completed - Success",
@@ -180,6 +354,7 @@ func TestHandleWebhook(t *testing.T) {
 			expStatusCode:        200,
 			expRespBody:          "workflow job completed event logged",
 			expectBuild:          false,
+			expEventTypes:        []LifecycleEventType{LifecycleEventCompleted},
 		},
 	}
 
@@ -239,11 +414,39 @@ func TestHandleWebhook(t *testing.T) {
 				mux.Handle("GET /app/installations/123", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					fmt.Fprintf(w, `{"access_tokens_url": "http://%s/app/installations/123/access_tokens"}`, r.Host)
 				}))
+				mux.Handle("GET /repos/google/webhook/installation", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tc.repoInstallationCode != 0 {
+						w.WriteHeader(tc.repoInstallationCode)
+						fmt.Fprintf(w, `{"message": "Not Found"}`)
+						return
+					}
+					id := tc.repoInstallationID
+					if id == 0 {
+						id = installationID
+					}
+					if tc.suspended {
+						fmt.Fprintf(w, `{"id": %d, "suspended_at": "2025-01-01T00:00:00Z"}`, id)
+						return
+					}
+					fmt.Fprintf(w, `{"id": %d}`, id)
+				}))
 				mux.Handle("POST /app/installations/123/access_tokens", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 					w.WriteHeader(201)
 					fmt.Fprintf(w, `{"token": "this-is-the-token-from-github"}`)
 				}))
+				mux.Handle("GET /repos/google/webhook/actions/runners", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tc.existingRunner {
+						fmt.Fprintf(w, `{"total_count": 1, "runners": [{"id": 42, "name": %q}]}`, r.URL.Query().Get("name"))
+						return
+					}
+					fmt.Fprintf(w, `{"total_count": 0, "runners": []}`)
+				}))
 				mux.Handle("POST /repos/google/webhook/actions/runners/generate-jitconfig", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tc.jitConfigCode != 0 {
+						w.WriteHeader(tc.jitConfigCode)
+						fmt.Fprintf(w, `{"message": "Actions is disabled for this repository"}`)
+						return
+					}
 					w.WriteHeader(201)
 					fmt.Fprintf(w, "%s", string(jitPayload))
 				}))
@@ -273,14 +476,37 @@ func TestHandleWebhook(t *testing.T) {
 			}
 
 			mockCloudBuildClient := &MockCloudBuildClient{}
+			mockPublisher := &MockEventPublisher{}
 
 			srv := &Server{
 				webhookSecret:  []byte(tc.payloadWebhookSecret),
 				appClient:      app,
 				cbc:            mockCloudBuildClient,
+				publisher:      mockPublisher,
 				ghAPIBaseURL:   fakeGitHub.URL,
 				runnerImageTag: "latest",
 				environment:    testEnv,
+				shadowMode:     tc.shadowMode,
+				queueTTL:       tc.queueTTL,
+
+				runnerRegistryMirrors: []string{"https://mirror.gcr.io", "https://us-docker.pkg.dev/project/dockerhub"},
+				runnerToolcacheBucket: "toolcache-bucket/linux-x64",
+			}
+			if tc.imageDigest != "" {
+				srv.runnerRepositoryID = "us-docker.pkg.dev/my-project/runners"
+				srv.runnerImageName = "default-runner"
+				srv.runnerImagePinDigest = true
+				srv.imageRegistry = &MockImageRegistry{Digests: map[string]string{
+					"us-docker.pkg.dev/my-project/runners/default-runner:latest": tc.imageDigest,
+				}}
+				srv.imageLookups = newImageLookupCache(time.Minute)
+			}
+			if tc.dispatchPolicy != "" {
+				fr := &MockFileReader{ReadFileMock: &ReadFileResErr{Res: []byte(tc.dispatchPolicy)}}
+				srv.dispatchPolicy, err = loadDispatchPolicy(fr, "policy.yaml", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
 			}
 			srv.handleWebhook().ServeHTTP(resp, req)
 
@@ -299,11 +525,37 @@ func TestHandleWebhook(t *testing.T) {
 				if got, want := mockCloudBuildClient.createBuildReq.GetBuild().GetSubstitutions()["_IMAGE_TAG"], tc.expectedImageTag; got != want {
 					t.Errorf("expected image tag %q to be %q", got, want)
 				}
+				if got, want := mockCloudBuildClient.createBuildReq.GetBuild().GetSubstitutions()["_REGISTRY_MIRRORS"], "https://mirror.gcr.io,https://us-docker.pkg.dev/project/dockerhub"; got != want {
+					t.Errorf("expected registry mirrors %q to be %q", got, want)
+				}
+				if got, want := mockCloudBuildClient.createBuildReq.GetBuild().GetSubstitutions()["_DOCKER_NETWORK"], "cloudbuild"; got != want {
+					t.Errorf("expected docker network %q to be %q", got, want)
+				}
+				if diff := cmp.Diff([]string{
+					"delivery-delivery-id", "event-workflow_job", "runner-GCP-789", "job-789",
+					"installation-123", "org-google", "repo-webhook",
+				}, mockCloudBuildClient.createBuildReq.GetBuild().GetTags()); diff != "" {
+					t.Errorf("unexpected build tags (-want, +got):\n%s", diff)
+				}
 			} else {
 				if mockCloudBuildClient.createBuildReq != nil {
 					t.Errorf("expected no build to be created, but a build was created with request: %v", mockCloudBuildClient.createBuildReq)
 				}
 			}
+
+			var gotEventTypes []LifecycleEventType
+			for _, ev := range mockPublisher.Events() {
+				gotEventTypes = append(gotEventTypes, ev.Type)
+				if got, want := ev.JobID, jobID; got != want {
+					t.Errorf("expected lifecycle event job id %d to be %d", got, want)
+				}
+				if ev.Type == LifecycleEventDispatched && ev.BuildID == "" {
+					t.Errorf("expected dispatched lifecycle event to carry a build id")
+				}
+			}
+			if diff := cmp.Diff(tc.expEventTypes, gotEventTypes); diff != "" {
+				t.Errorf("unexpected lifecycle events (-want, +got):\n%s", diff)
+			}
 		})
 	}
 }
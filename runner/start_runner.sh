@@ -1,69 +1,170 @@
 #!/bin/bash
 set -e
 
-echo "Attempting to start Docker daemon..."
+# The webhook service runs the runner container in a security mode:
+# "privileged" with a root Docker daemon, "rootless" unprivileged with a
+# rootless Docker daemon, or "unprivileged" without any Docker daemon.
+RUNNER_SECURITY_MODE="${RUNNER_SECURITY_MODE:-privileged}"
+echo "Runner security mode: ${RUNNER_SECURITY_MODE}"
 
-# Determine the GID of the 'docker' group. This group is created in the Dockerfile.
-DOCKER_GROUP_ID=$(getent group docker | cut -d: -f3)
+# Environment variables read from the metadata server are passed as a comma
+# separated list of <name>=<path>. On Cloud Build and VMs they are read before
+# the runner starts, the others are read here.
+IFS=',' read -ra METADATA_ENV <<< "${RUNNER_METADATA_ENV:-}"
+for entry in "${METADATA_ENV[@]}"; do
+    name="${entry%%=*}"
+    path="${entry#*=}"
+    if [ -n "${!name:-}" ]; then
+        continue
+    fi
+    if value=$(curl -fsS --noproxy '*' -H "Metadata-Flavor: Google" \
+            "http://metadata.google.internal/computeMetadata/v1/${path}"); then
+        export "${name}=${value}"
+    else
+        echo "WARNING: failed to read ${name} from metadata path ${path}."
+    fi
+done
+
+# Registry mirrors and insecure registries are passed as comma separated lists
+# by the webhook service, so image pulls inside jobs can go through Artifact
+# Registry remote repositories instead of Docker Hub.
+DOCKERD_REGISTRY_FLAGS=""
+IFS=',' read -ra REGISTRY_MIRRORS <<< "${DOCKER_REGISTRY_MIRRORS:-}"
+for mirror in "${REGISTRY_MIRRORS[@]}"; do
+    echo "Using registry mirror: ${mirror}"
+    DOCKERD_REGISTRY_FLAGS="${DOCKERD_REGISTRY_FLAGS} --registry-mirror=${mirror}"
+done
+IFS=',' read -ra INSECURE_REGISTRIES <<< "${DOCKER_INSECURE_REGISTRIES:-}"
+for registry in "${INSECURE_REGISTRIES[@]}"; do
+    echo "Allowing insecure registry: ${registry}"
+    DOCKERD_REGISTRY_FLAGS="${DOCKERD_REGISTRY_FLAGS} --insecure-registry=${registry}"
+done
 
-if [ -z "$DOCKER_GROUP_ID" ]; then
-    echo "Error: The 'docker' group GID was not found."
-    exit 1
-else
-    echo "The 'docker' group GID is: $DOCKER_GROUP_ID"
-    DOCKER_SOCKET_GROUP="$DOCKER_GROUP_ID"
+# Proxy settings are passed by the webhook service for networks without direct
+# internet access. Export the lower case variants too, as tools disagree on
+# which ones they read.
+if [ -n "${HTTP_PROXY:-}" ] || [ -n "${HTTPS_PROXY:-}" ]; then
+    echo "Using proxy HTTP_PROXY=${HTTP_PROXY:-} HTTPS_PROXY=${HTTPS_PROXY:-} NO_PROXY=${NO_PROXY:-}"
 fi
+export http_proxy="${HTTP_PROXY:-}"
+export https_proxy="${HTTPS_PROXY:-}"
+export no_proxy="${NO_PROXY:-}"
 
-# Start the Docker daemon in the background using sudo.
-# overlay2 doesn't work in the Docker-in-Docker on GCB scenario.
-sudo sh -c "dockerd \
-    --host=unix:///var/run/docker.sock \
-    --host=tcp://0.0.0.0:2375 \
-    --group=\"$DOCKER_SOCKET_GROUP\" \
-    --storage-driver=vfs \
-    > /var/log/dockerd.log 2>&1" &
-
-# Wait for the Docker socket to be available and the daemon to be responsive
-DOCKER_SOCKET="/var/run/docker.sock"
-TIMEOUT_SECONDS=60
-WAIT_INTERVAL_SECONDS=1
-ELAPSED_SECONDS=0
-
-echo "Waiting for Docker daemon to become available at ${DOCKER_SOCKET}..."
-while true; do
-    if [ ${ELAPSED_SECONDS} -ge ${TIMEOUT_SECONDS} ]; then
-        echo "Timeout: Docker daemon did not become available after ${TIMEOUT_SECONDS} seconds."
-        echo "Please check Docker daemon logs for errors: sudo cat /var/log/dockerd.log"
-        sudo cat /var/log/dockerd.log
-        echo "Current status of ${DOCKER_SOCKET}:"
-        sudo ls -l "${DOCKER_SOCKET}" || echo "Socket ${DOCKER_SOCKET} not found."
-        echo "Unable to configure docker daemon, exiting."
+# start_dockerd starts a root Docker daemon, in the privileged security mode.
+start_dockerd() {
+    echo "Attempting to start Docker daemon..."
+
+    # Determine the GID of the 'docker' group. This group is created in the Dockerfile.
+    DOCKER_GROUP_ID=$(getent group docker | cut -d: -f3)
+
+    if [ -z "$DOCKER_GROUP_ID" ]; then
+        echo "Error: The 'docker' group GID was not found."
         exit 1
+    else
+        echo "The 'docker' group GID is: $DOCKER_GROUP_ID"
+        DOCKER_SOCKET_GROUP="$DOCKER_GROUP_ID"
     fi
 
-    # Check if socket file exists and then if 'sudo docker info' works
-    if [ -S "${DOCKER_SOCKET}" ] && sudo -n docker info > /dev/null 2>&1; then
-        echo # Newline for cleaner output
-        echo "Docker daemon socket detected at ${DOCKER_SOCKET} and is responsive to 'sudo docker info'."
-        # Allow system to stabilize socket permissions fully
-        sleep 2
-        break
+    # Start the Docker daemon in the background using sudo.
+    # overlay2 doesn't work in the Docker-in-Docker on GCB scenario.
+    # dockerd reads the proxy settings from its environment.
+    sudo --preserve-env=HTTP_PROXY,HTTPS_PROXY,NO_PROXY sh -c "dockerd \
+        --host=unix:///var/run/docker.sock \
+        --host=tcp://0.0.0.0:2375 \
+        --group=\"$DOCKER_SOCKET_GROUP\" \
+        --storage-driver=vfs \
+        ${DOCKERD_REGISTRY_FLAGS} \
+        > /var/log/dockerd.log 2>&1" &
+
+    # Wait for the Docker socket to be available and the daemon to be responsive
+    DOCKER_SOCKET="/var/run/docker.sock"
+    TIMEOUT_SECONDS=60
+    WAIT_INTERVAL_SECONDS=1
+    ELAPSED_SECONDS=0
+
+    echo "Waiting for Docker daemon to become available at ${DOCKER_SOCKET}..."
+    while true; do
+        if [ ${ELAPSED_SECONDS} -ge ${TIMEOUT_SECONDS} ]; then
+            echo "Timeout: Docker daemon did not become available after ${TIMEOUT_SECONDS} seconds."
+            echo "Please check Docker daemon logs for errors: sudo cat /var/log/dockerd.log"
+            sudo cat /var/log/dockerd.log
+            echo "Current status of ${DOCKER_SOCKET}:"
+            sudo ls -l "${DOCKER_SOCKET}" || echo "Socket ${DOCKER_SOCKET} not found."
+            echo "Unable to configure docker daemon, exiting."
+            exit 1
+        fi
+
+        # Check if socket file exists and then if 'sudo docker info' works
+        if [ -S "${DOCKER_SOCKET}" ] && sudo -n docker info > /dev/null 2>&1; then
+            echo # Newline for cleaner output
+            echo "Docker daemon socket detected at ${DOCKER_SOCKET} and is responsive to 'sudo docker info'."
+            # Allow system to stabilize socket permissions fully
+            sleep 2
+            break
+        fi
+
+        # Progress indicator
+        echo -n "."
+
+        sleep ${WAIT_INTERVAL_SECONDS}
+        ELAPSED_SECONDS=$((ELAPSED_SECONDS + WAIT_INTERVAL_SECONDS))
+    done
+
+    # Final check: can the current user ('runner') access Docker without sudo?
+    if docker info > /dev/null 2>&1; then
+        echo "SUCCESS: Docker daemon is responsive to the 'runner' user."
+    else
+        echo "ERROR: 'docker info' as 'runner' user (UID $(id -u)) failed."
+        exit 1
     fi
+}
 
-    # Progress indicator
-    echo -n "."
+# start_rootless_dockerd starts a Docker daemon as the runner user, in the
+# rootless security mode. The container is not privileged, so the daemon runs
+# in its own user namespace and jobs reach it through DOCKER_HOST.
+start_rootless_dockerd() {
+    echo "Attempting to start rootless Docker daemon..."
 
-    sleep ${WAIT_INTERVAL_SECONDS}
-    ELAPSED_SECONDS=$((ELAPSED_SECONDS + WAIT_INTERVAL_SECONDS))
-done
+    export XDG_RUNTIME_DIR="/run/user/$(id -u)"
+    export DOCKER_HOST="unix://${XDG_RUNTIME_DIR}/docker.sock"
+    sudo mkdir -p "${XDG_RUNTIME_DIR}"
+    sudo chown "$(id -u):$(id -g)" "${XDG_RUNTIME_DIR}"
 
-# Final check: can the current user ('runner') access Docker without sudo?
-if docker info > /dev/null 2>&1; then
-    echo "SUCCESS: Docker daemon is responsive to the 'runner' user."
-else
-    echo "ERROR: 'docker info' as 'runner' user (UID $(id -u)) failed."
-    exit 1
-fi
+    # overlay2 needs privileges the container does not have.
+    dockerd-rootless.sh \
+        --storage-driver=vfs \
+        ${DOCKERD_REGISTRY_FLAGS} \
+        > /tmp/dockerd-rootless.log 2>&1 &
+
+    local timeout_seconds=60
+    local elapsed_seconds=0
+    echo "Waiting for rootless Docker daemon to become available at ${DOCKER_HOST}..."
+    until docker info > /dev/null 2>&1; do
+        if [ ${elapsed_seconds} -ge ${timeout_seconds} ]; then
+            echo "Timeout: rootless Docker daemon did not become available after ${timeout_seconds} seconds."
+            cat /tmp/dockerd-rootless.log
+            echo "Unable to configure rootless docker daemon, exiting."
+            exit 1
+        fi
+        echo -n "."
+        sleep 1
+        elapsed_seconds=$((elapsed_seconds + 1))
+    done
+    echo
+    echo "SUCCESS: rootless Docker daemon is responsive to the 'runner' user."
+}
+
+case "${RUNNER_SECURITY_MODE}" in
+    rootless)
+        start_rootless_dockerd
+        ;;
+    unprivileged)
+        echo "Not starting a Docker daemon, jobs cannot use Docker in the unprivileged security mode."
+        ;;
+    *)
+        start_dockerd
+        ;;
+esac
 
 # This ensures Docker CLI commands (i.e. login) run by actions
 # will use a writable location for their configuration.
@@ -75,6 +176,174 @@ export DOCKER_CONFIG="${HOME}/.docker-runner-default"
 mkdir -p "${DOCKER_CONFIG}"
 echo "Default DOCKER_CONFIG for this runner session set to: ${DOCKER_CONFIG}"
 
+# Containers started by jobs inherit the proxy settings through the Docker CLI
+# configuration.
+if [ -n "${HTTP_PROXY:-}" ] || [ -n "${HTTPS_PROXY:-}" ]; then
+    cat > "${DOCKER_CONFIG}/config.json" <<EOF
+{
+  "proxies": {
+    "default": {
+      "httpProxy": "${HTTP_PROXY:-}",
+      "httpsProxy": "${HTTPS_PROXY:-}",
+      "noProxy": "${NO_PROXY:-}"
+    }
+  }
+}
+EOF
+fi
+
+# Mount the shared toolcache from Cloud Storage when configured, so setup-*
+# actions find pre-populated toolchains instead of downloading them. The bucket
+# is mounted read-only and overlaid with a local writable layer, so actions can
+# still add tools for the lifetime of this runner.
+TOOLCACHE_DIR="/opt/hostedtoolcache"
+if [ -n "${TOOLCACHE_GCS_BUCKET:-}" ]; then
+    TOOLCACHE_BUCKET="${TOOLCACHE_GCS_BUCKET%%/*}"
+    TOOLCACHE_ONLY_DIR=""
+    if [ "${TOOLCACHE_GCS_BUCKET}" != "${TOOLCACHE_BUCKET}" ]; then
+        TOOLCACHE_ONLY_DIR="--only-dir=${TOOLCACHE_GCS_BUCKET#*/}"
+    fi
+
+    echo "Mounting toolcache from gs://${TOOLCACHE_GCS_BUCKET}..."
+    sudo mkdir -p /mnt/toolcache/lower /mnt/toolcache/upper /mnt/toolcache/work "${TOOLCACHE_DIR}"
+    if sudo gcsfuse -o ro,allow_other --implicit-dirs ${TOOLCACHE_ONLY_DIR} \
+            "${TOOLCACHE_BUCKET}" /mnt/toolcache/lower \
+        && sudo mount -t overlay overlay \
+            -o lowerdir=/mnt/toolcache/lower,upperdir=/mnt/toolcache/upper,workdir=/mnt/toolcache/work \
+            "${TOOLCACHE_DIR}"; then
+        echo "Toolcache mounted at ${TOOLCACHE_DIR}."
+    else
+        echo "WARNING: failed to mount the toolcache, tools will be downloaded on demand."
+    fi
+fi
+sudo mkdir -p "${TOOLCACHE_DIR}"
+sudo chown "$(id -u):$(id -g)" "${TOOLCACHE_DIR}"
+export RUNNER_TOOL_CACHE="${TOOLCACHE_DIR}"
+export AGENT_TOOLSDIRECTORY="${TOOLCACHE_DIR}"
+
+# Caches declared by the runner profile are mounted from volumes owned by root,
+# make them writable by the runner user.
+IFS=',' read -ra CACHE_PATHS <<< "${RUNNER_CACHE_PATHS:-}"
+for cache_path in "${CACHE_PATHS[@]}"; do
+    echo "Using persistent cache at ${cache_path}"
+    sudo mkdir -p "${cache_path}"
+    sudo chown -R "$(id -u):$(id -g)" "${cache_path}"
+done
+
+# Job hooks configured by the operator run before and after every job, see
+# https://docs.github.com/en/actions/hosting-your-own-runners/managing-self-hosted-runners/running-scripts-before-or-after-a-job.
+# The webhook service passes each hook either inline, base64 encoded, or as a
+# gs:// object read with the credentials of the runner service account. A hook
+# that cannot be installed stops the runner, so jobs never run without it.
+HOOKS_DIR="${HOME}/.job-hooks"
+install_hook() {
+    local name="$1"
+    local source="$2"
+    local path="${HOOKS_DIR}/${name}.sh"
+
+    mkdir -p "${HOOKS_DIR}"
+    if [[ "${source}" == gs://* ]]; then
+        local location="${source#gs://}"
+        local bucket="${location%%/*}"
+        local object
+        object=$(jq -rn --arg object "${location#*/}" '$object | @uri')
+        local token
+        token=$(curl -fsS --noproxy '*' -H "Metadata-Flavor: Google" \
+            "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token" | jq -r .access_token)
+        curl -fsS -H "Authorization: Bearer ${token}" -o "${path}" \
+            "https://storage.googleapis.com/storage/v1/b/${bucket}/o/${object}?alt=media"
+        echo "Installed ${name} job hook from ${source}"
+    else
+        echo "${source}" | base64 -d > "${path}"
+        echo "Installed ${name} job hook"
+    fi
+    chmod +x "${path}"
+}
+if [ -n "${JOB_STARTED_HOOK:-}" ]; then
+    install_hook job-started "${JOB_STARTED_HOOK}"
+    export ACTIONS_RUNNER_HOOK_JOB_STARTED="${HOOKS_DIR}/job-started.sh"
+fi
+if [ -n "${JOB_COMPLETED_HOOK:-}" ]; then
+    install_hook job-completed "${JOB_COMPLETED_HOOK}"
+    export ACTIONS_RUNNER_HOOK_JOB_COMPLETED="${HOOKS_DIR}/job-completed.sh"
+fi
+
+# Jobs inherit ACTIONS_CACHE_URL, pointing them at the actions cache proxy of
+# the webhook service, which stores caches in Cloud Storage next to the
+# runners. The runner still overrides it for actions when GitHub hands it the
+# URL of its own cache service. The URL embeds a token, only log its host.
+if [ -n "${ACTIONS_CACHE_URL:-}" ]; then
+    echo "Using actions cache at ${ACTIONS_CACHE_URL%%/actions-cache/*}"
+    export ACTIONS_CACHE_URL
+fi
+
+# Emit a heartbeat line to the build log while the runner keeps writing to its
+# diagnostic logs. The webhook service flags runners whose build log goes quiet
+# for too long while their job is in progress.
+HEARTBEAT_INTERVAL_SECONDS="${RUNNER_HEARTBEAT_INTERVAL:-60}"
+heartbeat() {
+    local last_marker=""
+    while true; do
+        sleep "${HEARTBEAT_INTERVAL_SECONDS}"
+        local marker
+        marker=$(find /actions-runner/_diag -type f -printf '%T@ %p\n' 2>/dev/null | sort -n | tail -n 1)
+        if [ -n "${marker}" ] && [ "${marker}" != "${last_marker}" ]; then
+            echo "runner heartbeat: $(date -u +%Y-%m-%dT%H:%M:%SZ)"
+            last_marker="${marker}"
+        fi
+    done
+}
+heartbeat &
+HEARTBEAT_PID=$!
+trap 'kill ${HEARTBEAT_PID} 2>/dev/null || true' EXIT
+
+# A reusable runner gets a registration token instead of a JIT config. It is
+# registered as a regular runner and takes up to max_jobs jobs, starting new
+# jobs for max_seconds. The webhook service drains an idle runner past it by
+# removing its registration, which stops the runner. Unlike a JIT runner, its
+# registration outlives it, so it deregisters itself with the remove token
+# whenever it exits. The token expires after an hour, a runner that cannot
+# deregister is removed by the webhook service once offline.
+if REUSE_CONFIG=$(echo "${ENCODED_JIT_CONFIG}" | base64 -d 2>/dev/null | jq -ec 'select(.registration_token != null)' 2>/dev/null); then
+    REMOVE_TOKEN=$(jq -r '.remove_token // ""' <<< "${REUSE_CONFIG}")
+    deregister() {
+        kill ${HEARTBEAT_PID} 2>/dev/null || true
+        if [ -z "${REMOVE_TOKEN}" ]; then
+            echo "WARNING: no remove token, the runner stays registered until removed by the webhook service."
+        elif ! /actions-runner/config.sh remove --token "${REMOVE_TOKEN}"; then
+            echo "WARNING: failed to deregister the runner, it may already be removed."
+        fi
+    }
+    trap deregister EXIT
+
+    CONFIG_ARGS=(
+        --unattended
+        --url "$(jq -r .url <<< "${REUSE_CONFIG}")"
+        --token "$(jq -r .registration_token <<< "${REUSE_CONFIG}")"
+        --name "$(jq -r .name <<< "${REUSE_CONFIG}")"
+        --labels "$(jq -r '.labels | join(",")' <<< "${REUSE_CONFIG}")"
+        --no-default-labels
+    )
+    RUNNER_GROUP=$(jq -r '.runner_group // ""' <<< "${REUSE_CONFIG}")
+    if [ -n "${RUNNER_GROUP}" ]; then
+        CONFIG_ARGS+=(--runnergroup "${RUNNER_GROUP}")
+    fi
+    /actions-runner/config.sh "${CONFIG_ARGS[@]}"
+
+    MAX_JOBS=$(jq -r .max_jobs <<< "${REUSE_CONFIG}")
+    DEADLINE=$(( $(date +%s) + $(jq -r .max_seconds <<< "${REUSE_CONFIG}") ))
+    JOBS=0
+    while [ "${JOBS}" -lt "${MAX_JOBS}" ] && [ "$(date +%s)" -lt "${DEADLINE}" ]; do
+        /actions-runner/run.sh --once &
+        if ! wait $!; then
+            break
+        fi
+        JOBS=$((JOBS + 1))
+    done
+    echo "Reusable runner served ${JOBS} jobs"
+    exit 0
+fi
+
 # Finally register a github runner using the jit config env variable.
 /actions-runner/run.sh --jitconfig $ENCODED_JIT_CONFIG &
 wait $!
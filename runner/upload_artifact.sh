@@ -0,0 +1,41 @@
+#!/bin/bash
+# Uploads a file to the artifacts bucket of the job through a short-lived
+# signed URL issued by the webhook service, so large artifacts go directly to
+# Cloud Storage instead of through the GitHub artifact service. The runner is
+# handed the endpoint and a token scoped to its job when artifact offload is
+# enabled.
+#
+# Usage: upload-artifact <file> [name]
+#
+# The artifact is written to gs://<bucket>/<org>/<repo>/<run ID>/<job ID>/<name>,
+# name defaults to the file name. The object is printed on success.
+set -euo pipefail
+
+if [ $# -lt 1 ] || [ $# -gt 2 ]; then
+    echo "Usage: upload-artifact <file> [name]" >&2
+    exit 2
+fi
+
+if [ -z "${ARTIFACTS_URL:-}" ] || [ -z "${ARTIFACTS_TOKEN:-}" ]; then
+    echo "Error: artifact offload is not enabled for this runner." >&2
+    exit 1
+fi
+
+FILE="$1"
+NAME="${2:-$(basename "${FILE}")}"
+
+if [ ! -f "${FILE}" ]; then
+    echo "Error: ${FILE} is not a file." >&2
+    exit 1
+fi
+
+RESPONSE=$(curl -fsS -X POST \
+    -H "Authorization: Bearer ${ARTIFACTS_TOKEN}" \
+    -H "Content-Type: application/json" \
+    --data "$(jq -n --arg name "${NAME}" '{name: $name}')" \
+    "${ARTIFACTS_URL}/artifacts/upload-url")
+
+UPLOAD_URL=$(jq -r .url <<< "${RESPONSE}")
+curl -fsS -X PUT --upload-file "${FILE}" "${UPLOAD_URL}"
+
+jq -r .object <<< "${RESPONSE}"
@@ -21,4 +21,6 @@ module "runner" {
 
   name                       = var.name
   run_service_account_member = google_service_account.run_service_account.member
+  logs_retention_days        = var.runner_logs_retention_days
+  jit_config_secrets         = var.runner_jit_config_secrets
 }
@@ -48,6 +48,14 @@ resource "google_project_iam_member" "build_trigger_permission" {
   member = var.run_service_account_member
 }
 
+# Allow the webhook to check that the runner images exist at startup
+resource "google_project_iam_member" "image_reader_permission" {
+  project = var.project_id
+
+  role   = "roles/artifactregistry.reader"
+  member = var.run_service_account_member
+}
+
 # Allow the webhook project to run as the runner service account
 resource "google_service_account_iam_member" "build_runner_permission" {
   service_account_id = google_service_account.runner_service_account.name
@@ -55,3 +63,100 @@ resource "google_service_account_iam_member" "build_runner_permission" {
   role   = "roles/iam.serviceAccountUser"
   member = var.run_service_account_member
 }
+
+# Holds the runner build logs, keyed by <org>/<repo>/<run ID>/<job ID>, past
+# the Cloud Build log retention
+resource "google_storage_bucket" "runner_logs" {
+  count = var.logs_retention_days > 0 ? 1 : 0
+
+  project = var.project_id
+
+  name                        = "${var.project_id}-${var.name}-runner-logs"
+  location                    = "US"
+  uniform_bucket_level_access = true
+  public_access_prevention    = "enforced"
+
+  lifecycle_rule {
+    condition {
+      age = var.logs_retention_days
+    }
+    action {
+      type = "Delete"
+    }
+  }
+}
+
+# Allow the runner builds to stream their logs to the bucket. The runners
+# only create log objects, they must not read or delete the logs of other jobs.
+resource "google_storage_bucket_iam_member" "runner_logs_writer" {
+  count = var.logs_retention_days > 0 ? 1 : 0
+
+  bucket = google_storage_bucket.runner_logs[0].name
+
+  role   = "roles/storage.objectCreator"
+  member = google_service_account.runner_service_account.member
+}
+
+# Cloud Build looks up the logs bucket before streaming to it.
+resource "google_storage_bucket_iam_member" "runner_logs_bucket_reader" {
+  count = var.logs_retention_days > 0 ? 1 : 0
+
+  bucket = google_storage_bucket.runner_logs[0].name
+
+  role   = "roles/storage.legacyBucketReader"
+  member = google_service_account.runner_service_account.member
+}
+
+data "google_project" "runner" {
+  count = var.jit_config_secrets ? 1 : 0
+
+  project_id = var.project_id
+}
+
+resource "google_project_service" "secretmanager" {
+  count = var.jit_config_secrets ? 1 : 0
+
+  project = var.project_id
+
+  service                    = "secretmanager.googleapis.com"
+  disable_on_destroy         = false
+  disable_dependent_services = false
+}
+
+# The permissions the webhook needs to hand each runner its JIT configuration
+# secret: create it, add its version, grant the runner access to it and
+# delete it once the job is in progress.
+resource "google_project_iam_custom_role" "jit_config_secrets_writer" {
+  count = var.jit_config_secrets ? 1 : 0
+
+  project = var.project_id
+
+  role_id     = "${replace(var.name, "-", "_")}_jit_config_secrets_writer"
+  title       = "${var.name} JIT config secrets writer"
+  description = "Create, grant access to and delete the runner JIT configuration secrets."
+  permissions = [
+    "secretmanager.secrets.create",
+    "secretmanager.secrets.delete",
+    "secretmanager.secrets.setIamPolicy",
+    "secretmanager.versions.add",
+  ]
+}
+
+# Allow the webhook to store the runner JIT configurations. Limited to the JIT
+# configuration secrets. The runner service account gets no project-level
+# access, the webhook grants it access to each runner's own secret as it
+# creates it, as the runner service account also runs the jobs.
+resource "google_project_iam_member" "jit_config_secrets_writer" {
+  count = var.jit_config_secrets ? 1 : 0
+
+  project = var.project_id
+
+  role   = google_project_iam_custom_role.jit_config_secrets_writer[0].id
+  member = var.run_service_account_member
+
+  condition {
+    title       = "jit-config-secrets"
+    description = "Only the runner JIT configuration secrets."
+    expression  = "resource.name.startsWith(\"projects/${data.google_project.runner[0].number}/secrets/jit-config-\")"
+  }
+}
@@ -16,3 +16,8 @@ output "runner_service_account" {
   description = "The runner service account."
   value       = google_service_account.runner_service_account
 }
+
+output "logs_bucket" {
+  description = "The name of the runner logs bucket, null if the logs are only written to Cloud Logging."
+  value       = try(google_storage_bucket.runner_logs[0].name, null)
+}
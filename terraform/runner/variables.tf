@@ -26,3 +26,15 @@ variable "run_service_account_member" {
   description = "The service account member from the webhook module."
   type        = string
 }
+
+variable "logs_retention_days" {
+  description = "The number of days the runner build logs are kept in the logs bucket. No bucket is created when 0."
+  type        = number
+  default     = 0
+}
+
+variable "jit_config_secrets" {
+  description = "Whether the webhook stores the runner JIT configurations in Secret Manager."
+  type        = bool
+  default     = false
+}
@@ -95,11 +95,22 @@ variable "alerts" {
   }
 }
 
+variable "max_instances" {
+  description = "The maximum number of instances of the webhook service. Forced to 1 when RUNNER_REPO_MAX_RUNNERS, RUNNER_ORG_MAX_RUNNERS, RUNNER_SCOPE_LIMITS, RUNNER_MAX_CONCURRENT_BUILDS, RUNNER_MAX_LIFETIME, RUNNER_STALL_THRESHOLD, RUNNER_IDLE_GRACE_PERIOD, PAGERDUTY_ROUTING_KEY, RUNNER_REUSE_MAX_JOBS or RUNNER_REUSE_MAX_DURATION is set in envvars, as their state is kept in memory or checked by a background loop."
+  type        = number
+  default     = 100
+  validation {
+    condition     = var.max_instances >= 1
+    error_message = "The maximum number of instances must be at least 1."
+  }
+}
+
 variable "envvars" {
   type = map(string)
   default = {
     # GITHUB_APP_ID            = ""
     # KMS_APP_PRIVATE_KEY_ID   = ""
+    # KMS_APP_FALLBACK_PRIVATE_KEY_ID = "" # second app key, e.g. in another region
     # BUILD_LOCATION           = ""
     # PROJECT_ID               = ""
     # WEBHOOK_KEY_MOUNT_PATH   = "/etc/secrets/webhook/key"
@@ -157,3 +168,19 @@ variable "runner_project_ids" {
     error_message = "Exactly one runner project must be specified."
   }
 }
+
+variable "runner_logs_retention_days" {
+  description = "The number of days the runner build logs streamed to Cloud Storage are kept. Set to 0 to only write them to Cloud Logging."
+  type        = number
+  default     = 0
+  validation {
+    condition     = var.runner_logs_retention_days >= 0
+    error_message = "The runner logs retention must not be negative."
+  }
+}
+
+variable "runner_jit_config_secrets" {
+  description = "Whether to hand runners their JIT configuration through short-lived Secret Manager secrets instead of build substitutions."
+  type        = bool
+  default     = false
+}
@@ -100,6 +100,33 @@ module "gclb" {
   domains          = var.domains
 }
 
+locals {
+  # The per-repository and per-organization runner limits and the limit of
+  # concurrent builds count the runners of, and hold the runners over them in,
+  # the memory of the instance. Events of one runner may reach any instance,
+  # so the limits need the service to run on a single instance, whose CPU is
+  # always allocated for the background loops launching the held runners.
+  # The maximum runner lifetime, the stall and idle runner checks and the
+  # PagerDuty escalation are background loops over the runners in memory as
+  # well, which only run while the CPU is allocated. Reusable runners are
+  # tracked in memory by the instance that dispatched them, which puts them
+  # back online after each job and drains them in the background.
+  webhook_single_instance = anytrue([
+    for name in [
+      "RUNNER_REPO_MAX_RUNNERS",
+      "RUNNER_ORG_MAX_RUNNERS",
+      "RUNNER_SCOPE_LIMITS",
+      "RUNNER_MAX_CONCURRENT_BUILDS",
+      "RUNNER_MAX_LIFETIME",
+      "RUNNER_STALL_THRESHOLD",
+      "RUNNER_IDLE_GRACE_PERIOD",
+      "PAGERDUTY_ROUTING_KEY",
+      "RUNNER_REUSE_MAX_JOBS",
+      "RUNNER_REUSE_MAX_DURATION",
+    ] : !contains(["", "0"], lookup(var.envvars, name, ""))
+  ])
+}
+
 module "cloud_run" {
   source = "git::https://github.com/abcxyz/terraform-modules.git//modules/cloud_run?ref=1467eaf0115f71613727212b0b51b3f99e699842"
 
@@ -109,6 +136,7 @@ module "cloud_run" {
   image                 = var.image
   ingress               = var.enable_gclb ? "internal-and-cloud-load-balancing" : "all"
   min_instances         = 1
+  max_instances         = local.webhook_single_instance ? 1 : var.max_instances
   secrets               = ["webhook-secret-file"]
   service_account_email = google_service_account.run_service_account.email
   args                  = ["webhook", "server"]
@@ -124,13 +152,23 @@ module "cloud_run" {
     "run.googleapis.com/invoker-iam-disabled" : true
   }
 
+  additional_revision_annotations = local.webhook_single_instance ? {
+    "run.googleapis.com/cpu-throttling" : false
+  } : {}
+
   envvars = merge(
     var.envvars,
     {
       "KMS_APP_PRIVATE_KEY_ID" : format("%s/cryptoKeyVersions/%s", google_kms_crypto_key.webhook_app_private_key.id, var.kms_key_version)
       "RUNNER_PROJECT_ID" : var.runner_project_ids[0]
       "RUNNER_SERVICE_ACCOUNT" : one(values(module.runner)).runner_service_account.email
-    }
+    },
+    var.runner_logs_retention_days > 0 ? {
+      "RUNNER_LOGS_BUCKET" : one(values(module.runner)).logs_bucket
+    } : {},
+    var.runner_jit_config_secrets ? {
+      "RUNNER_JIT_CONFIG_SECRETS" : "true"
+    } : {}
   )
 
   secret_envvars = {}